@@ -6,7 +6,9 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"net/url"
 	"os"
+	"strconv"
 	"strings"
 	"time"
 )
@@ -193,6 +195,7 @@ func (s *MCPServer) GetTools() []Tool {
 				"start_date":    {Type: "string", Description: "开始日期 YYYY-MM-DD"},
 				"pm_user_id":    {Type: "string", Description: "项目经理用户ID"},
 				"skip_weekends": {Type: "boolean", Description: "是否跳过周末"},
+				"calendar_id":   {Type: "string", Description: "工作日历ID（按节假日/调休排期，为空时跳过周末则用默认日历）"},
 			}, Required: []string{"template_id", "project_code", "project_name", "start_date", "pm_user_id"}},
 		},
 		{
@@ -298,6 +301,40 @@ func (s *MCPServer) GetTools() []Tool {
 			InputSchema: InputSchema{Type: "object"},
 		},
 
+		// Workload
+		{
+			Name:        "plm_get_workload",
+			Description: "查询跨项目资源负荷：按人和任务角色统计每周工时与产能，列出超负荷周并给出在自由时差内后移任务的削峰建议",
+			InputSchema: InputSchema{Type: "object", Properties: map[string]Property{
+				"from":            {Type: "string", Description: "开始日期 YYYY-MM-DD（可选，默认本周）"},
+				"to":              {Type: "string", Description: "结束日期 YYYY-MM-DD（可选，默认 8 周）"},
+				"user_id":         {Type: "string", Description: "只看某人（可选）"},
+				"role":            {Type: "string", Description: "任务角色编码（可选）"},
+				"weekly_capacity": {Type: "number", Description: "周产能小时数（可选，默认取系统配置）"},
+			}},
+		},
+
+		// State Engine
+		{
+			Name:        "plm_available_events",
+			Description: "查询实体当前状态下可触发的事件及每条规则的条件评估结果",
+			InputSchema: InputSchema{Type: "object", Properties: map[string]Property{
+				"entity_type": {Type: "string", Description: "实体类型（状态机名称），如 plm_task、srm_purchase_order"},
+				"entity_id":   {Type: "string", Description: "实体ID"},
+				"event_data":  {Type: "object", Description: "条件评估上下文（可选）"},
+			}, Required: []string{"entity_type", "entity_id"}},
+		},
+		{
+			Name:        "plm_simulate_transitions",
+			Description: "模拟执行一串状态机事件（不落库），返回状态路径和将执行的动作",
+			InputSchema: InputSchema{Type: "object", Properties: map[string]Property{
+				"entity_type": {Type: "string", Description: "实体类型（状态机名称），如 plm_task、srm_purchase_order"},
+				"entity_id":   {Type: "string", Description: "从该实体当前状态开始（可选）"},
+				"from_state":  {Type: "string", Description: "指定起始状态（可选）"},
+				"events":      {Type: "array", Description: "事件列表: [{\"event\": \"start\", \"event_data\": {...}}]"},
+			}, Required: []string{"entity_type", "events"}},
+		},
+
 		// System
 		{
 			Name:        "plm_health_check",
@@ -411,6 +448,36 @@ func (s *MCPServer) CallTool(name string, args map[string]interface{}) (string,
 		resp, err := s.plm.Request("GET", "/api/v1/auth/me", nil)
 		return string(resp), err
 
+	// Workload
+	case "plm_get_workload":
+		params := url.Values{}
+		for _, key := range []string{"from", "to", "user_id", "role"} {
+			if v, ok := args[key].(string); ok && v != "" {
+				params.Set(key, v)
+			}
+		}
+		if v, ok := args["weekly_capacity"].(float64); ok && v > 0 {
+			params.Set("weekly_capacity", strconv.FormatFloat(v, 'f', -1, 64))
+		}
+		path := "/api/v1/workload"
+		if len(params) > 0 {
+			path += "?" + params.Encode()
+		}
+		resp, err := s.plm.Request("GET", path, nil)
+		return string(resp), err
+
+	// State Engine
+	case "plm_available_events":
+		path := "/api/v1/state-engine/entities/" + args["entity_type"].(string) + "/" + args["entity_id"].(string) + "/events"
+		resp, err := s.plm.Request("POST", path, map[string]interface{}{
+			"event_data": args["event_data"],
+		})
+		return string(resp), err
+
+	case "plm_simulate_transitions":
+		resp, err := s.plm.Request("POST", "/api/v1/state-engine/simulate", args)
+		return string(resp), err
+
 	// System
 	case "plm_health_check":
 		resp, err := s.plm.Request("GET", "/health/live", nil)
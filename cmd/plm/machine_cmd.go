@@ -0,0 +1,144 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/bitfantasy/nimo/internal/shared/engine"
+)
+
+// builtinMachines 内置（Go 代码定义）的状态机
+var builtinMachines = map[string]func() *engine.StateMachineDefinition{
+	"plm_task":             engine.NewPLMTaskMachine,
+	"srm_purchase_order":   engine.NewSRMPurchaseOrderMachine,
+	"srm_pr_item":          engine.NewSRMPRItemMachine,
+	"srm_sampling_request": engine.NewSRMSamplingMachine,
+}
+
+// runMachineCommand 状态机离线工具
+//
+//	plm machine validate [file|dir ...]           校验状态机文件（默认 configs/state_machines 及内置定义）
+//	plm machine diagram [-format mermaid|dot] <file|name>  导出流程图
+func runMachineCommand(args []string) int {
+	if len(args) == 0 {
+		fmt.Fprintln(os.Stderr, "用法: plm machine validate [file|dir ...] | plm machine diagram [-format mermaid|dot] <file|name>")
+		return 2
+	}
+
+	switch args[0] {
+	case "validate":
+		return validateMachines(args[1:])
+	case "diagram":
+		return renderMachineDiagram(args[1:])
+	default:
+		fmt.Fprintf(os.Stderr, "未知子命令: %s\n", args[0])
+		return 2
+	}
+}
+
+// validateMachines 校验状态机文件，任一失败返回 1
+func validateMachines(paths []string) int {
+	failed := false
+	report := func(source string, def *engine.StateMachineDefinition, err error) {
+		if err == nil {
+			fmt.Printf("OK    %s (%s)\n", def.Name, source)
+			return
+		}
+		failed = true
+		var verr *engine.ValidationError
+		if errors.As(err, &verr) {
+			fmt.Printf("FAIL  %s (%s)\n", verr.Machine, source)
+			for _, issue := range verr.Issues {
+				fmt.Printf("      - %s\n", issue)
+			}
+			return
+		}
+		fmt.Printf("FAIL  %s: %v\n", source, err)
+	}
+
+	if len(paths) == 0 {
+		for name, build := range builtinMachines {
+			def := build()
+			report("内置:"+name, def, engine.ValidateMachine(def))
+		}
+		if _, err := os.Stat(stateMachineDir); err == nil {
+			paths = []string{stateMachineDir}
+		}
+	}
+
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			report(path, nil, err)
+			continue
+		}
+		if !info.IsDir() {
+			def, err := engine.LoadMachineFile(path)
+			report(path, def, err)
+			continue
+		}
+		defs, err := engine.LoadMachineDir(path)
+		if err != nil {
+			report(path, nil, err)
+			continue
+		}
+		for _, def := range defs {
+			report(path, def, nil)
+		}
+	}
+
+	if failed {
+		return 1
+	}
+	return 0
+}
+
+// renderMachineDiagram 导出流程图到标准输出
+// 参数为文件路径时加载该文件；否则按名称在 configs/state_machines 和内置定义中查找
+func renderMachineDiagram(args []string) int {
+	fs := flag.NewFlagSet("diagram", flag.ContinueOnError)
+	format := fs.String("format", engine.DiagramMermaid, "流程图格式: mermaid / dot")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if fs.NArg() != 1 {
+		fmt.Fprintln(os.Stderr, "用法: plm machine diagram [-format mermaid|dot] <file|name>")
+		return 2
+	}
+
+	def, err := findMachine(fs.Arg(0))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	out, err := engine.RenderDiagram(def, *format)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	fmt.Print(out)
+	return 0
+}
+
+// findMachine 按文件路径或名称查找状态机定义
+func findMachine(arg string) (*engine.StateMachineDefinition, error) {
+	if info, err := os.Stat(arg); err == nil && !info.IsDir() {
+		return engine.LoadMachineFile(arg)
+	}
+
+	defs, err := engine.LoadMachineDir(stateMachineDir)
+	if err != nil {
+		return nil, err
+	}
+	for _, def := range defs {
+		if def.Name == arg {
+			return def, nil
+		}
+	}
+	if build, ok := builtinMachines[arg]; ok {
+		return build(), nil
+	}
+	return nil, fmt.Errorf("未找到状态机: %s", arg)
+}
@@ -8,6 +8,7 @@ import (
 	"net/http"
 	"os"
 	"os/signal"
+	"sort"
 	"syscall"
 
 	"github.com/bitfantasy/nimo/internal/config"
@@ -16,6 +17,7 @@ import (
 	"github.com/bitfantasy/nimo/internal/plm/handler"
 	"github.com/bitfantasy/nimo/internal/plm/repository"
 	"github.com/bitfantasy/nimo/internal/plm/service"
+	"github.com/bitfantasy/nimo/internal/shared/cron"
 	"github.com/bitfantasy/nimo/internal/shared/engine"
 	"github.com/bitfantasy/nimo/internal/shared/feishu"
 	srmentity "github.com/bitfantasy/nimo/internal/srm/entity"
@@ -37,7 +39,19 @@ var (
 	BuildTime = "unknown"
 )
 
+// stateMachineDir 声明式状态机文件目录（默认没有生效的文件，全部使用内置定义；示例见目录下的 *.example）
+const stateMachineDir = "configs/state_machines"
+
 func main() {
+	// 子命令: plm machine validate|diagram（离线校验/导出状态机，不启动服务）
+	if len(os.Args) > 1 && os.Args[1] == "machine" {
+		os.Exit(runMachineCommand(os.Args[2:]))
+	}
+	// 子命令: plm state rebuild（按转换日志重建实体状态，不启动服务）
+	if len(os.Args) > 1 && os.Args[1] == "state" {
+		os.Exit(runStateCommand(os.Args[2:]))
+	}
+
 	// 加载 .env 文件
 	if err := godotenv.Load(); err != nil {
 		log.Printf("Warning: .env file not found, using environment variables")
@@ -71,6 +85,7 @@ func main() {
 	if err := db.AutoMigrate(
 		&entity.TaskForm{},
 		&entity.TaskFormSubmission{},
+		&entity.TaskFormDraft{},
 		&entity.TemplateTaskForm{},
 	); err != nil {
 		zapLogger.Warn("AutoMigrate task form tables warning", zap.Error(err))
@@ -197,6 +212,56 @@ func main() {
 			updated_at TIMESTAMP DEFAULT NOW(),
 			UNIQUE(entity_type, entity_id)
 		)`,
+		// 状态机引擎: 乐观锁版本号
+		`ALTER TABLE entity_states ADD COLUMN IF NOT EXISTS version BIGINT NOT NULL DEFAULT 0`,
+		// 状态机引擎: 定义版本化（同名多版本，实体固定版本）
+		`ALTER TABLE state_machine_definitions ADD COLUMN IF NOT EXISTS version INT NOT NULL DEFAULT 1`,
+		`ALTER TABLE state_machine_definitions ADD COLUMN IF NOT EXISTS checksum VARCHAR(64)`,
+		`ALTER TABLE state_machine_definitions DROP CONSTRAINT IF EXISTS state_machine_definitions_name_key`,
+		`CREATE UNIQUE INDEX IF NOT EXISTS idx_machine_name_version ON state_machine_definitions(name, version)`,
+		`ALTER TABLE entity_states ADD COLUMN IF NOT EXISTS machine_version INT DEFAULT 0`,
+		`UPDATE entity_states es SET machine_version = d.version FROM state_machine_definitions d WHERE es.machine_id = d.id AND es.machine_version = 0`,
+		// 状态机引擎: 动作发件箱
+		`CREATE TABLE IF NOT EXISTS state_action_outbox (
+			id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+			transition_log_id UUID,
+			entity_type VARCHAR(50) NOT NULL,
+			entity_id UUID NOT NULL,
+			from_state VARCHAR(50),
+			to_state VARCHAR(50) NOT NULL,
+			event VARCHAR(100) NOT NULL,
+			event_data JSONB,
+			action_type VARCHAR(100) NOT NULL,
+			action_config JSONB,
+			status VARCHAR(20) NOT NULL DEFAULT 'pending',
+			attempts INT NOT NULL DEFAULT 0,
+			next_attempt_at TIMESTAMP DEFAULT NOW(),
+			locked_until TIMESTAMP,
+			last_error TEXT,
+			processed_at TIMESTAMP,
+			created_at TIMESTAMP DEFAULT NOW(),
+			updated_at TIMESTAMP DEFAULT NOW()
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_state_action_outbox_due ON state_action_outbox(status, next_attempt_at)`,
+		`CREATE INDEX IF NOT EXISTS idx_state_action_outbox_log ON state_action_outbox(transition_log_id)`,
+		// 状态机引擎: 状态定时器
+		`CREATE TABLE IF NOT EXISTS state_timers (
+			id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+			entity_type VARCHAR(50) NOT NULL,
+			entity_id UUID NOT NULL,
+			machine_id UUID,
+			state VARCHAR(50) NOT NULL,
+			event VARCHAR(100) NOT NULL,
+			fire_at TIMESTAMP NOT NULL,
+			status VARCHAR(20) NOT NULL DEFAULT 'pending',
+			locked_until TIMESTAMP,
+			last_error TEXT,
+			fired_at TIMESTAMP,
+			created_at TIMESTAMP DEFAULT NOW(),
+			updated_at TIMESTAMP DEFAULT NOW()
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_state_timers_due ON state_timers(status, fire_at)`,
+		`CREATE INDEX IF NOT EXISTS idx_state_timers_entity ON state_timers(entity_type, entity_id)`,
 
 		// Phase 3: 工作流集成表
 		`CREATE TABLE IF NOT EXISTS template_phase_roles (
@@ -653,6 +718,263 @@ func main() {
 		)`,
 		// Add unique index to prevent future duplicates
 		`CREATE UNIQUE INDEX IF NOT EXISTS idx_project_templates_name_type ON project_templates(name, template_type)`,
+
+		// V24: 进度基线
+		`CREATE TABLE IF NOT EXISTS project_baselines (
+			id VARCHAR(32) PRIMARY KEY,
+			project_id VARCHAR(32) NOT NULL,
+			name VARCHAR(128) NOT NULL,
+			description TEXT,
+			source VARCHAR(16) NOT NULL DEFAULT 'manual',
+			phase_id VARCHAR(32),
+			phase VARCHAR(16),
+			is_active BOOLEAN DEFAULT false,
+			planned_start DATE,
+			planned_end DATE,
+			total_hours DECIMAL(10,2) DEFAULT 0,
+			task_count INT DEFAULT 0,
+			created_by VARCHAR(32),
+			created_at TIMESTAMP DEFAULT NOW()
+		)`,
+		"CREATE INDEX IF NOT EXISTS idx_project_baselines_project ON project_baselines(project_id)",
+		`CREATE TABLE IF NOT EXISTS task_baselines (
+			id VARCHAR(32) PRIMARY KEY,
+			baseline_id VARCHAR(32) NOT NULL REFERENCES project_baselines(id) ON DELETE CASCADE,
+			task_id VARCHAR(32) NOT NULL,
+			code VARCHAR(64),
+			title VARCHAR(256),
+			planned_start DATE,
+			planned_end DATE,
+			estimated_hours DECIMAL(8,2) DEFAULT 0
+		)`,
+		"CREATE INDEX IF NOT EXISTS idx_task_baselines_baseline ON task_baselines(baseline_id)",
+
+		// V25: 工作日历
+		`CREATE TABLE IF NOT EXISTS work_calendars (
+			id VARCHAR(32) PRIMARY KEY,
+			code VARCHAR(64) NOT NULL UNIQUE,
+			name VARCHAR(128) NOT NULL,
+			description TEXT,
+			work_week VARCHAR(16) NOT NULL DEFAULT '12345',
+			is_default BOOLEAN DEFAULT false,
+			created_by VARCHAR(32),
+			created_at TIMESTAMP DEFAULT NOW(),
+			updated_at TIMESTAMP DEFAULT NOW()
+		)`,
+		`CREATE TABLE IF NOT EXISTS work_calendar_days (
+			id VARCHAR(32) PRIMARY KEY,
+			calendar_id VARCHAR(32) NOT NULL REFERENCES work_calendars(id) ON DELETE CASCADE,
+			date DATE NOT NULL,
+			is_workday BOOLEAN NOT NULL DEFAULT false,
+			name VARCHAR(64),
+			source VARCHAR(16) DEFAULT 'manual',
+			created_at TIMESTAMP DEFAULT NOW(),
+			UNIQUE(calendar_id, date)
+		)`,
+		`CREATE TABLE IF NOT EXISTS user_calendar_exceptions (
+			id VARCHAR(32) PRIMARY KEY,
+			user_id VARCHAR(32) NOT NULL,
+			start_date DATE NOT NULL,
+			end_date DATE NOT NULL,
+			type VARCHAR(16) NOT NULL,
+			is_workday BOOLEAN NOT NULL DEFAULT false,
+			reason VARCHAR(256),
+			created_by VARCHAR(32),
+			created_at TIMESTAMP DEFAULT NOW()
+		)`,
+		"CREATE INDEX IF NOT EXISTS idx_user_calendar_exceptions_user ON user_calendar_exceptions(user_id, start_date)",
+		"ALTER TABLE projects ADD COLUMN IF NOT EXISTS calendar_id VARCHAR(32)",
+
+		// V26: 自动化规则引擎
+		`CREATE TABLE IF NOT EXISTS automation_rules (
+			id VARCHAR(36) PRIMARY KEY,
+			name VARCHAR(128),
+			rule_type VARCHAR(30) NOT NULL,
+			trigger_condition JSONB NOT NULL DEFAULT '{}',
+			action_type VARCHAR(30) NOT NULL,
+			action_config JSONB NOT NULL,
+			is_active BOOLEAN DEFAULT true,
+			project_id VARCHAR(32),
+			template_id VARCHAR(36),
+			priority INTEGER DEFAULT 0,
+			created_by VARCHAR(32),
+			created_at TIMESTAMP DEFAULT NOW(),
+			updated_at TIMESTAMP DEFAULT NOW()
+		)`,
+		`CREATE TABLE IF NOT EXISTS automation_logs (
+			id VARCHAR(36) PRIMARY KEY,
+			rule_id VARCHAR(36),
+			rule_type VARCHAR(30),
+			project_id VARCHAR(32),
+			task_id VARCHAR(32),
+			trigger_event JSONB NOT NULL,
+			action_result JSONB,
+			status VARCHAR(20) NOT NULL,
+			error_message TEXT,
+			executed_at TIMESTAMP DEFAULT NOW()
+		)`,
+		"ALTER TABLE automation_rules ADD COLUMN IF NOT EXISTS name VARCHAR(128)",
+		"ALTER TABLE automation_rules ADD COLUMN IF NOT EXISTS created_by VARCHAR(32)",
+		"ALTER TABLE automation_logs ADD COLUMN IF NOT EXISTS rule_type VARCHAR(30)",
+		"ALTER TABLE automation_logs ADD COLUMN IF NOT EXISTS project_id VARCHAR(32)",
+		"ALTER TABLE automation_logs ADD COLUMN IF NOT EXISTS task_id VARCHAR(32)",
+		"CREATE INDEX IF NOT EXISTS idx_automation_rules_scope ON automation_rules(rule_type, project_id, template_id)",
+		"CREATE INDEX IF NOT EXISTS idx_automation_logs_project ON automation_logs(project_id, executed_at)",
+		"CREATE INDEX IF NOT EXISTS idx_automation_logs_rule_task ON automation_logs(rule_id, task_id)",
+
+		// V27: 后台定时任务
+		`CREATE TABLE IF NOT EXISTS scheduler_job_runs (
+			id VARCHAR(32) PRIMARY KEY,
+			job_name VARCHAR(64) NOT NULL,
+			trigger VARCHAR(16) NOT NULL,
+			instance VARCHAR(128),
+			schedule_at TIMESTAMP,
+			started_at TIMESTAMP,
+			finished_at TIMESTAMP,
+			duration_ms BIGINT DEFAULT 0,
+			status VARCHAR(16) NOT NULL,
+			output TEXT,
+			error TEXT
+		)`,
+		"CREATE INDEX IF NOT EXISTS idx_scheduler_job_runs_job ON scheduler_job_runs(job_name, started_at)",
+		`CREATE TABLE IF NOT EXISTS scheduler_locks (
+			lock_key VARCHAR(128) PRIMARY KEY,
+			owner VARCHAR(128),
+			expires_at TIMESTAMP NOT NULL
+		)`,
+
+		// V28: 评审会议（字段同 003 脚本，关联 ID 按当前主键类型使用 VARCHAR）
+		`CREATE TABLE IF NOT EXISTS review_meetings (
+			id VARCHAR(36) PRIMARY KEY,
+			title VARCHAR(200) NOT NULL,
+			meeting_type VARCHAR(20) NOT NULL,
+			project_id VARCHAR(32),
+			task_id VARCHAR(32),
+			feishu_calendar_event_id VARCHAR(100),
+			feishu_meeting_id VARCHAR(100),
+			scheduled_at TIMESTAMP NOT NULL,
+			duration_minutes INTEGER NOT NULL DEFAULT 60,
+			location VARCHAR(200),
+			organizer_id VARCHAR(64) NOT NULL,
+			attendees JSONB NOT NULL DEFAULT '[]',
+			agenda TEXT,
+			documents JSONB,
+			status VARCHAR(20) NOT NULL DEFAULT 'SCHEDULED',
+			conclusion TEXT,
+			action_items JSONB,
+			minutes_doc_id VARCHAR(36),
+			created_at TIMESTAMP DEFAULT NOW(),
+			updated_at TIMESTAMP DEFAULT NOW()
+		)`,
+		"ALTER TABLE review_meetings ADD COLUMN IF NOT EXISTS phase_id VARCHAR(32)",
+		"ALTER TABLE review_meetings ADD COLUMN IF NOT EXISTS bom_id VARCHAR(32)",
+		"ALTER TABLE review_meetings ADD COLUMN IF NOT EXISTS ecn_id VARCHAR(32)",
+		"ALTER TABLE review_meetings ADD COLUMN IF NOT EXISTS concluded_by VARCHAR(32)",
+		"ALTER TABLE review_meetings ADD COLUMN IF NOT EXISTS concluded_at TIMESTAMP",
+		"ALTER TABLE review_meetings ADD COLUMN IF NOT EXISTS created_by VARCHAR(32)",
+		"CREATE INDEX IF NOT EXISTS idx_review_meetings_project ON review_meetings(project_id)",
+		"CREATE INDEX IF NOT EXISTS idx_review_meetings_task ON review_meetings(task_id, status)",
+
+		// V29: 飞书任务同步记录（飞书任务创建失败时 feishu_task_id 为空，重试后回填）
+		`CREATE TABLE IF NOT EXISTS feishu_task_sync (
+			id VARCHAR(36) PRIMARY KEY,
+			task_id VARCHAR(32) NOT NULL UNIQUE,
+			feishu_task_id VARCHAR(100) UNIQUE,
+			feishu_task_guid VARCHAR(100),
+			sync_status VARCHAR(20) NOT NULL DEFAULT 'SYNCED',
+			last_sync_at TIMESTAMP,
+			sync_error TEXT,
+			created_at TIMESTAMP DEFAULT NOW(),
+			updated_at TIMESTAMP DEFAULT NOW()
+		)`,
+		"ALTER TABLE feishu_task_sync ALTER COLUMN feishu_task_id DROP NOT NULL",
+		"CREATE INDEX IF NOT EXISTS idx_feishu_task_sync_status ON feishu_task_sync(sync_status)",
+
+		// V30: 阶段门禁（模板按阶段配置门禁条件和交付物，强制放行须填写理由）
+		`CREATE TABLE IF NOT EXISTS template_phase_gates (
+			id VARCHAR(36) PRIMARY KEY,
+			template_id VARCHAR(36) NOT NULL,
+			phase VARCHAR(20) NOT NULL,
+			require_deliverables BOOLEAN DEFAULT true,
+			require_critical_tasks BOOLEAN DEFAULT false,
+			max_open_ecns INT,
+			require_bom_released BOOLEAN DEFAULT false,
+			deliverables JSONB DEFAULT '[]',
+			created_at TIMESTAMP DEFAULT NOW(),
+			updated_at TIMESTAMP DEFAULT NOW(),
+			UNIQUE(template_id, phase)
+		)`,
+		`CREATE TABLE IF NOT EXISTS phase_gate_overrides (
+			id VARCHAR(32) PRIMARY KEY,
+			project_id VARCHAR(32) NOT NULL,
+			phase_id VARCHAR(32) NOT NULL,
+			justification TEXT NOT NULL,
+			failed_checks JSONB,
+			overridden_by VARCHAR(32) NOT NULL,
+			created_at TIMESTAMP DEFAULT NOW()
+		)`,
+		"CREATE INDEX IF NOT EXISTS idx_phase_gate_overrides_phase ON phase_gate_overrides(phase_id)",
+		"ALTER TABLE phase_deliverables ADD COLUMN IF NOT EXISTS task_id VARCHAR(32)",
+		"CREATE INDEX IF NOT EXISTS idx_phase_deliverables_task ON phase_deliverables(task_id)",
+
+		// V31: 条件模板任务（按项目属性决定是否纳入）
+		"ALTER TABLE template_tasks ADD COLUMN IF NOT EXISTS inclusion_condition JSONB",
+		"ALTER TABLE projects ADD COLUMN IF NOT EXISTS attributes JSONB",
+
+		// V32: 表单草稿按人保存，迁出原 version=0 的草稿记录（系统控件写入的保留）
+		`INSERT INTO task_form_drafts (id, form_id, task_id, user_id, data, created_at, updated_at)
+			SELECT id, form_id, task_id, submitted_by, data, submitted_at, submitted_at
+			FROM task_form_submissions WHERE version = 0 AND submitted_by <> 'system'
+			ON CONFLICT DO NOTHING`,
+		"DELETE FROM task_form_submissions WHERE version = 0 AND submitted_by <> 'system'",
+
+		// V33: 任务检查项（从模板任务 checklist 实例化，记录勾选人和时间）
+		`CREATE TABLE IF NOT EXISTS task_checklist_items (
+			id VARCHAR(32) PRIMARY KEY,
+			project_id VARCHAR(32) NOT NULL,
+			task_id VARCHAR(32) NOT NULL,
+			title VARCHAR(500) NOT NULL,
+			description TEXT,
+			is_mandatory BOOLEAN DEFAULT true,
+			sort_order INT DEFAULT 0,
+			is_checked BOOLEAN DEFAULT false,
+			checked_by VARCHAR(32),
+			checked_at TIMESTAMP,
+			note TEXT,
+			created_by VARCHAR(32),
+			created_at TIMESTAMP DEFAULT NOW(),
+			updated_at TIMESTAMP DEFAULT NOW()
+		)`,
+		"CREATE INDEX IF NOT EXISTS idx_task_checklist_items_task ON task_checklist_items(task_id)",
+		"CREATE INDEX IF NOT EXISTS idx_task_checklist_items_project ON task_checklist_items(project_id)",
+
+		// V34: 审批人解析（直属上级、部门负责人、角色），解析结果记录在审批实例上
+		"ALTER TABLE users ADD COLUMN IF NOT EXISTS manager_id VARCHAR(32)",
+		"CREATE INDEX IF NOT EXISTS idx_users_manager_id ON users(manager_id)",
+		"ALTER TABLE approval_requests ADD COLUMN IF NOT EXISTS approver_resolutions JSONB",
+
+		// V35: 审批流程图（条件分支、并行拆分/汇合），按分支记录实例进度
+		`CREATE TABLE IF NOT EXISTS approval_branches (
+			id VARCHAR(36) PRIMARY KEY,
+			approval_id VARCHAR(36) NOT NULL,
+			parent_id VARCHAR(36),
+			split_node_id VARCHAR(64),
+			name VARCHAR(100),
+			node_id VARCHAR(64),
+			node_index INT DEFAULT 0,
+			status VARCHAR(20) NOT NULL,
+			path JSONB,
+			created_at TIMESTAMP DEFAULT NOW(),
+			updated_at TIMESTAMP DEFAULT NOW()
+		)`,
+		"CREATE INDEX IF NOT EXISTS idx_approval_branches_approval ON approval_branches(approval_id)",
+		"CREATE INDEX IF NOT EXISTS idx_approval_branches_parent ON approval_branches(parent_id)",
+
+		// V36: 检查项来源（模板实例化的检查项只有项目经理或管理员可以删除）
+		"ALTER TABLE task_checklist_items ADD COLUMN IF NOT EXISTS source VARCHAR(16) DEFAULT 'template'",
+
+		// V37: 发起人自选审批人保存在实例上，流转到自选节点时解析
+		"ALTER TABLE approval_requests ADD COLUMN IF NOT EXISTS selected_approvers JSONB",
 	}
 	for _, sql := range migrationSQL {
 		if err := db.Exec(sql).Error; err != nil {
@@ -703,11 +1025,10 @@ func main() {
 	services := service.NewServices(repos, rdb, cfg)
 
 	// 初始化状态机引擎 (Phase 3)
-	stateEngine := engine.NewEngine(db, nil)
-	plmTaskMachine := engine.NewPLMTaskMachine()
-	if err := stateEngine.RegisterMachine(plmTaskMachine); err != nil {
-		zapLogger.Warn("Failed to register PLM task state machine", zap.Error(err))
-	}
+	// 动作按类型分发到各业务执行器，未注册的类型仅记录日志
+	actionExecutor := engine.NewCompositeActionExecutor(engine.NewLoggingActionExecutor())
+	stateEngine := engine.NewEngine(db, actionExecutor)
+	registerStateMachines(stateEngine, zapLogger)
 
 	// 初始化飞书客户端 (Phase 3 — 工作流用)
 	var feishuWorkflowClient *feishu.FeishuClient
@@ -738,6 +1059,7 @@ func main() {
 	// V4: 设置审批和管理员处理器
 	handlers.Approval = handler.NewApprovalHandler(approvalSvc)
 	handlers.Admin = handler.NewAdminHandler(contactSyncSvc)
+	handlers.StateEngine = handler.NewStateEngineHandler(stateEngine)
 
 	// V5: 审批定义服务
 	approvalDefSvc := service.NewApprovalDefinitionService(db, feishuWorkflowClient, approvalSvc)
@@ -749,6 +1071,10 @@ func main() {
 	services.Project.SetBOMService(services.ProjectBOM)
 	services.Project.SetFeishuClient(feishuWorkflowClient, repos.User)
 	approvalSvc.SetProjectService(services.Project)
+	approvalSvc.SetWorkflowService(workflowSvc)
+	taskStates := service.NewTaskStateMirror(stateEngine)
+	approvalSvc.SetTaskStateMirror(taskStates)
+	services.Project.SetTaskStateMirror(taskStates)
 	services.Template.SetProjectService(services.Project)
 
 	// V9: 智能路由 (Phase 4)
@@ -758,6 +1084,106 @@ func main() {
 	workflowSvc.SetTaskFormRepo(repos.TaskForm)
 	workflowSvc.SetBOMRepo(repos.ProjectBOM)
 
+	// 工作日历：节假日/调休/个人请假，项目排期和逾期按日历计算
+	calendarSvc := service.NewCalendarService(db)
+	if err := calendarSvc.SeedDefaultCalendar(context.Background()); err != nil {
+		zapLogger.Warn("Seed default work calendar failed", zap.Error(err))
+	}
+	services.Project.SetCalendarService(calendarSvc)
+	stateEngine.SetWorkCalendar(calendarSvc.EngineCalendar())
+	services.Template.SetCalendarService(calendarSvc)
+	handlers.Calendar = handler.NewCalendarHandler(calendarSvc)
+
+	// 进度计划：依赖类型感知的关键路径排程，任务变化后自动重排
+	scheduleSvc := service.NewScheduleService(db)
+	scheduleSvc.SetCalendarService(calendarSvc)
+	services.Project.SetScheduleService(scheduleSvc)
+	workflowSvc.SetScheduleService(scheduleSvc)
+	handlers.Schedule = handler.NewScheduleHandler(scheduleSvc)
+
+	// 进度基线 + 挣值分析
+	baselineSvc := service.NewBaselineService(db)
+	baselineSvc.SetCalendarService(calendarSvc)
+	services.Project.SetBaselineService(baselineSvc)
+	handlers.Baseline = handler.NewBaselineHandler(baselineSvc)
+
+	// 资源负荷：跨项目按人/角色统计周负荷，超负荷时给出削峰建议
+	workloadSvc := service.NewWorkloadService(db, repos.SystemConfig)
+	workloadSvc.SetCalendarService(calendarSvc)
+	workloadSvc.SetScheduleService(scheduleSvc)
+	handlers.Workload = handler.NewWorkloadHandler(workloadSvc)
+
+	// 自动化规则：项目/模板/全局规则按优先级响应任务开始、完成、逾期和阶段完成
+	automationSvc := service.NewAutomationService(db)
+	automationSvc.SetProjectService(services.Project)
+	automationSvc.SetApprovalService(approvalSvc)
+	automationSvc.SetFeishuClient(feishuWorkflowClient)
+	services.Automation = automationSvc
+	services.Project.SetAutomationService(automationSvc)
+	workflowSvc.SetAutomationService(automationSvc)
+	handlers.Automation = handler.NewAutomationHandler(automationSvc)
+
+	// 后台定时任务：到期提醒、逾期升级、每日摘要；多实例时通过配置的锁（scheduler.lock）保证只执行一次
+	// 所有实例必须使用同一种锁，因此锁后端不可用时直接启动失败，不自动切换
+	var jobLocker cron.Locker
+	lockBackend := cfg.Scheduler.Lock
+	if lockBackend == "" {
+		lockBackend = "db"
+	}
+	switch lockBackend {
+	case "db":
+		jobLocker = service.NewDBJobLocker(db)
+	case "redis":
+		if err := rdb.Ping(context.Background()).Err(); err != nil {
+			zapLogger.Fatal("Scheduler lock backend redis unavailable", zap.Error(err))
+		}
+		jobLocker = cron.NewRedisLocker(rdb)
+	default:
+		zapLogger.Fatal("Unknown scheduler lock backend", zap.String("lock", lockBackend))
+	}
+	zapLogger.Info("Scheduler lock backend", zap.String("lock", lockBackend))
+	schedulerSvc := service.NewSchedulerService(db, repos.SystemConfig, jobLocker)
+	schedulerSvc.SetProjectService(services.Project)
+	schedulerSvc.SetAutomationService(automationSvc)
+	schedulerSvc.SetFeishuClient(feishuWorkflowClient)
+	handlers.Scheduler = handler.NewSchedulerHandler(schedulerSvc)
+
+	// 评审会议：飞书日历邀请参会人，结论待办转任务；关联评审未结束时任务不能完成
+	reviewMeetingSvc := service.NewReviewMeetingService(db)
+	reviewMeetingSvc.SetProjectService(services.Project)
+	reviewMeetingSvc.SetFeishuClient(feishuWorkflowClient)
+	services.Project.SetReviewMeetingService(reviewMeetingSvc)
+	workflowSvc.SetReviewMeetingService(reviewMeetingSvc)
+	approvalSvc.SetReviewMeetingService(reviewMeetingSvc)
+	handlers.Review = handler.NewReviewMeetingHandler(reviewMeetingSvc)
+
+	// 飞书任务双向同步：任务变化推送飞书，飞书端完成/转派回写 PLM
+	taskSyncSvc := service.NewFeishuTaskSyncService(db)
+	taskSyncSvc.SetFeishuClient(feishuWorkflowClient)
+	taskSyncSvc.SetWorkflowService(workflowSvc)
+	taskSyncSvc.SetTaskStateMirror(taskStates)
+	services.Project.SetFeishuTaskSyncService(taskSyncSvc)
+	workflowSvc.SetFeishuTaskSyncService(taskSyncSvc)
+	scheduleSvc.SetFeishuTaskSyncService(taskSyncSvc)
+	handlers.FeishuTaskSync = handler.NewFeishuTaskSyncHandler(taskSyncSvc)
+
+	// 阶段门禁：阶段完成前检查交付物/关键任务/ECN/BOM 发布，任务完成时自动登记交付物
+	phaseGateSvc := service.NewPhaseGateService(db)
+	services.Project.SetPhaseGateService(phaseGateSvc)
+	services.Template.SetPhaseGateService(phaseGateSvc)
+	workflowSvc.SetPhaseGateService(phaseGateSvc)
+	handlers.PhaseGate = handler.NewPhaseGateHandler(phaseGateSvc, services.Project)
+
+	// 任务检查项：模板检查项实例化到任务，必检项未勾选时任务不能完成
+	checklistSvc := service.NewChecklistService(db)
+	services.Project.SetChecklistService(checklistSvc)
+	services.Template.SetChecklistService(checklistSvc)
+	workflowSvc.SetChecklistService(checklistSvc)
+	handlers.Checklist = handler.NewChecklistHandler(checklistSvc)
+
+	// 模板包：导出/导入自包含的模板 JSON/YAML，对比模板版本差异
+	handlers.TemplateBundle = handler.NewTemplateBundleHandler(service.NewTemplateBundleService(db))
+
 	// Backfill: 为已有BOM items自动创建缺失的物料
 	services.ProjectBOM.BackfillMaterials(context.Background())
 
@@ -878,10 +1304,11 @@ func main() {
 	// SRM仓库和服务
 	srmRepos := srmrepo.NewRepositories(db)
 	srmSupplierSvc := srmsvc.NewSupplierService(srmRepos.Supplier)
-	srmProcurementSvc := srmsvc.NewProcurementService(srmRepos.PR, srmRepos.PO, db)
+	srmProcurementSvc := srmsvc.NewProcurementService(srmRepos.PR, srmRepos.PO, db, stateEngine)
 	srmInspectionSvc := srmsvc.NewInspectionService(srmRepos.Inspection, srmRepos.PR)
 	srmInventorySvc := srmsvc.NewInventoryService(srmRepos.Inventory)
 	srmInspectionSvc.SetPORepo(srmRepos.PO)
+	srmInspectionSvc.SetProcurementService(srmProcurementSvc)
 	srmInspectionSvc.SetInventoryService(srmInventorySvc)
 	srmDashboardSvc := srmsvc.NewDashboardService(db)
 	srmProjectSvc := srmsvc.NewSRMProjectService(srmRepos.Project, srmRepos.PR, srmRepos.ActivityLog, srmRepos.DelayRequest, db)
@@ -891,19 +1318,33 @@ func main() {
 	srmEvaluationSvc.SetSupplierRepo(srmRepos.Supplier)
 	srmEquipmentSvc := srmsvc.NewEquipmentService(srmRepos.Equipment)
 	srmRFQSvc := srmsvc.NewRFQService(srmRepos.RFQ, srmRepos.PO, srmRepos.PR, srmRepos.ActivityLog, db)
-	srmPRItemSvc := srmsvc.NewPRItemService(srmRepos.PR, srmRepos.Project, srmRepos.ActivityLog, db)
-	srmSamplingSvc := srmsvc.NewSamplingService(srmRepos.Sampling, srmRepos.PR, srmRepos.Supplier, srmRepos.ActivityLog, db)
-	srmHandlers := srmhandler.NewHandlers(srmSupplierSvc, srmProcurementSvc, srmInspectionSvc, srmDashboardSvc, srmRepos.PO, srmProjectSvc, srmSettlementSvc, srmCorrectiveActionSvc, srmEvaluationSvc, srmEquipmentSvc, srmRFQSvc, srmPRItemSvc, srmSamplingSvc)
+	srmPRItemSvc := srmsvc.NewPRItemService(srmRepos.PR, srmRepos.Project, srmRepos.ActivityLog, db, stateEngine)
+	srmSamplingSvc := srmsvc.NewSamplingService(srmRepos.Sampling, srmRepos.PR, srmRepos.Supplier, srmRepos.ActivityLog, db, stateEngine)
+	srmHandlers := srmhandler.NewHandlers(srmSupplierSvc, srmProcurementSvc, srmInspectionSvc, srmDashboardSvc, srmProjectSvc, srmSettlementSvc, srmCorrectiveActionSvc, srmEvaluationSvc, srmEquipmentSvc, srmRFQSvc, srmPRItemSvc, srmSamplingSvc)
 	srmHandlers.Inventory = srmhandler.NewInventoryHandler(srmInventorySvc)
 
+	srmSupplierNotifier := srmsvc.NewSupplierNotifier(db)
+	actionExecutor.Register("notify_supplier", srmSupplierNotifier)
+	srmSupplierNotifier.SetNotifyConfig(cfg.Feishu.SRMNotifyOpenID, cfg.Feishu.WebBaseURL)
+
 	// SRM→飞书：注入飞书客户端到SRM各服务
 	if feishuWorkflowClient != nil {
 		srmProcurementSvc.SetFeishuClient(feishuWorkflowClient)
 		srmInspectionSvc.SetFeishuClient(feishuWorkflowClient)
 		srmCorrectiveActionSvc.SetFeishuClient(feishuWorkflowClient)
 		srmSamplingSvc.SetFeishuClient(feishuWorkflowClient)
+		srmSupplierNotifier.SetFeishuClient(feishuWorkflowClient)
 	}
 
+	// 状态机后台调度：动作发件箱（事务提交后执行动作）；执行器全部注册后再启动
+	dispatcherCtx, stopDispatcher := context.WithCancel(context.Background())
+	defer stopDispatcher()
+	go engine.NewOutboxDispatcher(stateEngine).Start(dispatcherCtx)
+	// 状态机后台调度：定时器（到期触发定时转换）
+	go engine.NewTimerScheduler(stateEngine).Start(dispatcherCtx)
+	// 后台定时任务
+	go schedulerSvc.Start(dispatcherCtx)
+
 	// 工作流→SRM集成：采购控件自动创建PR
 	workflowSvc.SetSRMProcurementService(srmProcurementSvc)
 
@@ -945,6 +1386,7 @@ func main() {
 	<-quit
 
 	zapLogger.Info("Shutting down server...")
+	stopDispatcher()
 
 	ctx, cancel := context.WithTimeout(context.Background(), cfg.Server.ShutdownTimeout)
 	defer cancel()
@@ -979,6 +1421,34 @@ func initLogger(cfg config.LogConfig) (*zap.Logger, error) {
 	return zapCfg.Build()
 }
 
+// registerStateMachines 注册状态机: 声明式状态机文件（与内置定义同名时以文件为准）+ 内置定义（PLM 任务、SRM 采购订单/行项/打样）
+func registerStateMachines(stateEngine *engine.Engine, zapLogger *zap.Logger) {
+	fileMachines, err := engine.LoadMachineDir(stateMachineDir)
+	if err != nil {
+		zapLogger.Warn("Failed to load state machine files", zap.String("dir", stateMachineDir), zap.Error(err))
+	}
+	fileDefined := make(map[string]bool)
+	for _, def := range fileMachines {
+		if err := stateEngine.RegisterMachine(def); err != nil {
+			zapLogger.Warn("Failed to register state machine", zap.String("name", def.Name), zap.Error(err))
+		}
+		fileDefined[def.Name] = true
+	}
+	names := make([]string, 0, len(builtinMachines))
+	for name := range builtinMachines {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if fileDefined[name] {
+			continue
+		}
+		if err := stateEngine.RegisterMachine(builtinMachines[name]()); err != nil {
+			zapLogger.Warn("Failed to register built-in state machine", zap.String("name", name), zap.Error(err))
+		}
+	}
+}
+
 func initDatabase(cfg config.DatabaseConfig) (*gorm.DB, error) {
 	dsn := fmt.Sprintf(
 		"host=%s port=%d user=%s password=%s dbname=%s sslmode=%s",
@@ -1080,7 +1550,7 @@ func registerRoutes(r *gin.Engine, h *handler.Handlers, svc *service.Services, c
 		webhooks := v1.Group("/webhooks")
 		{
 			webhooks.POST("/feishu/approval", handleFeishuApprovalWebhook)
-			webhooks.POST("/feishu/event", handleFeishuEventVerification)
+			webhooks.POST("/feishu/event", handleFeishuEvent(h.FeishuTaskSync, cfg.Feishu))
 		}
 
 		// SSE 实时推送（需要认证，支持 query param token）
@@ -1110,6 +1580,24 @@ func registerRoutes(r *gin.Engine, h *handler.Handlers, svc *service.Services, c
 			admin := authorized.Group("/admin")
 			{
 				admin.POST("/sync-contacts", h.Admin.SyncContacts)
+
+				// 状态机动作发件箱
+				admin.GET("/state-engine/outbox", h.StateEngine.ListOutbox)
+				admin.POST("/state-engine/outbox/:id/replay", h.StateEngine.ReplayOutbox)
+				admin.GET("/state-engine/machines/:name/versions", h.StateEngine.ListMachineVersions)
+				admin.POST("/state-engine/machines/:name/migrate", h.StateEngine.MigrateEntities)
+				admin.GET("/state-engine/machines/:name/diagram", h.StateEngine.GetMachineDiagram)
+				admin.POST("/state-engine/rebuild", h.StateEngine.RebuildEntityStates)
+			}
+
+			// 状态机: 可用事件查询 & 转换模拟 & 状态历史
+			stateEngine := authorized.Group("/state-engine")
+			{
+				stateEngine.GET("/entities/:entity_type/:entity_id/events", h.StateEngine.AvailableEvents)
+				stateEngine.POST("/entities/:entity_type/:entity_id/events", h.StateEngine.AvailableEvents)
+				stateEngine.GET("/entities/:entity_type/:entity_id/history", h.StateEngine.GetTimeline)
+				stateEngine.GET("/entities/:entity_type/:entity_id/state", h.StateEngine.GetStateAt)
+				stateEngine.POST("/simulate", h.StateEngine.Simulate)
 			}
 
 			// V4: 审批
@@ -1156,6 +1644,67 @@ func registerRoutes(r *gin.Engine, h *handler.Handlers, svc *service.Services, c
 				roles.DELETE("/:id/members", h.Role.RemoveMembers)
 			}
 
+			// 工作日历
+			calendars := authorized.Group("/calendars")
+			{
+				calendars.GET("", h.Calendar.ListCalendars)
+				calendars.POST("", h.Calendar.CreateCalendar)
+				calendars.GET("/:id", h.Calendar.GetCalendar)
+				calendars.PUT("/:id", h.Calendar.UpdateCalendar)
+				calendars.DELETE("/:id", h.Calendar.DeleteCalendar)
+				calendars.GET("/:id/days", h.Calendar.ListDays)
+				calendars.POST("/:id/days", h.Calendar.SetDays)
+				calendars.DELETE("/:id/days/:date", h.Calendar.DeleteDay)
+				calendars.POST("/:id/import", h.Calendar.ImportHolidays)
+				calendars.GET("/:id/workdays", h.Calendar.ResolveDays)
+			}
+			// 自动化规则
+			automationRules := authorized.Group("/automation-rules")
+			{
+				automationRules.GET("", h.Automation.ListRules)
+				automationRules.POST("", h.Automation.CreateRule)
+				automationRules.GET("/:id", h.Automation.GetRule)
+				automationRules.PUT("/:id", h.Automation.UpdateRule)
+				automationRules.DELETE("/:id", h.Automation.DeleteRule)
+			}
+			authorized.GET("/automation-logs", h.Automation.ListLogs)
+			authorized.POST("/automation/check-overdue", h.Automation.CheckOverdue)
+
+			// 后台定时任务
+			scheduler := authorized.Group("/scheduler")
+			{
+				scheduler.GET("/jobs", h.Scheduler.ListJobs)
+				scheduler.POST("/jobs/:name/run", h.Scheduler.RunJob)
+				scheduler.GET("/runs", h.Scheduler.ListRuns)
+			}
+
+			// 评审会议
+			reviewMeetings := authorized.Group("/review-meetings")
+			{
+				reviewMeetings.GET("", h.Review.ListMeetings)
+				reviewMeetings.POST("", h.Review.CreateMeeting)
+				reviewMeetings.GET("/:id", h.Review.GetMeeting)
+				reviewMeetings.PUT("/:id", h.Review.UpdateMeeting)
+				reviewMeetings.POST("/:id/start", h.Review.StartMeeting)
+				reviewMeetings.POST("/:id/cancel", h.Review.CancelMeeting)
+				reviewMeetings.POST("/:id/conclude", h.Review.ConcludeMeeting)
+				reviewMeetings.POST("/:id/convert-actions", h.Review.ConvertActionItems)
+			}
+
+			// 飞书任务同步
+			authorized.GET("/feishu-task-syncs", h.FeishuTaskSync.ListSyncs)
+			authorized.POST("/feishu-task-syncs/:id/retry", h.FeishuTaskSync.RetrySync)
+
+			// 资源负荷
+			authorized.GET("/workload", h.Workload.GetWorkload)
+
+			calendarExceptions := authorized.Group("/calendar-exceptions")
+			{
+				calendarExceptions.GET("", h.Calendar.ListUserExceptions)
+				calendarExceptions.POST("", h.Calendar.CreateUserException)
+				calendarExceptions.DELETE("/:id", h.Calendar.DeleteUserException)
+			}
+
 			// 部门树（角色成员选择用）
 			authorized.GET("/departments", h.Role.ListDepartments)
 
@@ -1275,11 +1824,27 @@ func registerRoutes(r *gin.Engine, h *handler.Handlers, svc *service.Services, c
 				projects.POST("/:id/tasks/:taskId/dependencies", h.Project.AddTaskDependency)
 				projects.DELETE("/:id/tasks/:taskId/dependencies/:depId", h.Project.RemoveTaskDependency)
 				projects.GET("/:id/overdue-tasks", h.Project.GetOverdueTasks)
+				projects.GET("/:id/schedule", h.Schedule.GetSchedule)
+				projects.POST("/:id/schedule/recalculate", h.Schedule.Recalculate)
+				projects.GET("/:id/baselines", h.Baseline.ListBaselines)
+				projects.POST("/:id/baselines", h.Baseline.CreateBaseline)
+				projects.GET("/:id/baselines/:baselineId", h.Baseline.GetBaseline)
+				projects.POST("/:id/baselines/:baselineId/activate", h.Baseline.ActivateBaseline)
+				projects.DELETE("/:id/baselines/:baselineId", h.Baseline.DeleteBaseline)
+				projects.GET("/:id/earned-value", h.Baseline.GetEarnedValue)
+				projects.GET("/:id/automation-rules", h.Automation.ListProjectRules)
+				projects.POST("/:id/automation-rules", h.Automation.CreateProjectRule)
+				projects.GET("/:id/automation-logs", h.Automation.ListProjectLogs)
+				projects.GET("/:id/review-meetings", h.Review.ListProjectMeetings)
+				projects.POST("/:id/review-meetings", h.Review.CreateProjectMeeting)
+				projects.GET("/:id/earned-value/export", h.Baseline.ExportEarnedValue)
 
 				// V6: 任务表单
 				projects.GET("/:id/tasks/:taskId/form", h.TaskForm.GetTaskForm)
 				projects.PUT("/:id/tasks/:taskId/form", h.TaskForm.UpsertTaskForm)
 				projects.GET("/:id/tasks/:taskId/form/submission", h.TaskForm.GetFormSubmission)
+				projects.GET("/:id/tasks/:taskId/form/submissions", h.TaskForm.ListFormSubmissions)
+				projects.GET("/:id/tasks/:taskId/form/submissions/diff", h.TaskForm.DiffFormSubmissions)
 
 				// V6: 任务确认/驳回
 				projects.POST("/:id/tasks/:taskId/confirm", h.Project.ConfirmTask)
@@ -1375,6 +1940,15 @@ func registerRoutes(r *gin.Engine, h *handler.Handlers, svc *service.Services, c
 				projects.GET("/:id/deliverables", h.Deliverable.ListByProject)
 				projects.GET("/:id/phases/:phaseId/deliverables", h.Deliverable.ListByPhase)
 				projects.PUT("/:id/deliverables/:deliverableId", h.Deliverable.Update)
+				projects.GET("/:id/phases/:phaseId/gate", h.PhaseGate.CheckGate)
+				projects.POST("/:id/phases/:phaseId/gate/override", h.PhaseGate.OverrideGate)
+
+				// 任务检查项
+				projects.GET("/:id/tasks/:taskId/checklist", h.Checklist.ListItems)
+				projects.POST("/:id/tasks/:taskId/checklist", h.Checklist.AddItem)
+				projects.PUT("/:id/tasks/:taskId/checklist/:itemId/check", h.Checklist.CheckItem)
+				projects.DELETE("/:id/tasks/:taskId/checklist/:itemId", h.Checklist.DeleteItem)
+				projects.GET("/:id/checklist-summary", h.Checklist.ProjectSummary)
 
 				// V3: 工作流操作
 				if h.Workflow != nil {
@@ -1395,6 +1969,7 @@ func registerRoutes(r *gin.Engine, h *handler.Handlers, svc *service.Services, c
 			authorized.POST("/my/tasks/:taskId/complete", h.Project.CompleteMyTask)
 			authorized.PUT("/my/tasks/:taskId/form-draft", h.TaskForm.SaveFormDraft)
 			authorized.GET("/my/tasks/:taskId/form-draft", h.TaskForm.GetFormDraft)
+			authorized.DELETE("/my/tasks/:taskId/form-draft", h.TaskForm.DeleteFormDraft)
 
 			// 文件上传
 			authorized.POST("/upload", h.Upload.Upload)
@@ -1475,10 +2050,23 @@ func registerRoutes(r *gin.Engine, h *handler.Handlers, svc *service.Services, c
 				templates.POST("/:id/upgrade", h.Template.UpgradeVersion)
 				templates.POST("/:id/revert", h.Template.Revert)
 				templates.GET("/:id/versions", h.Template.ListVersions)
+				templates.POST("/:id/preview", h.Template.PreviewProject)
+				templates.GET("/:id/diff", h.TemplateBundle.Diff)
+				templates.GET("/:id/export", h.TemplateBundle.Export)
+				templates.POST("/import", h.TemplateBundle.Import)
 
 				// V7: 模板任务表单
 				templates.GET("/:id/task-forms", h.TaskForm.GetTemplateTaskForms)
 				templates.POST("/:id/task-forms", h.TaskForm.SaveTemplateTaskForm)
+
+				// 模板级自动化规则
+				templates.GET("/:id/automation-rules", h.Automation.ListTemplateRules)
+				templates.POST("/:id/automation-rules", h.Automation.CreateTemplateRule)
+
+				// 阶段门禁
+				templates.GET("/:id/phase-gates", h.PhaseGate.ListTemplateGates)
+				templates.PUT("/:id/phase-gates/:phase", h.PhaseGate.SaveTemplateGate)
+				templates.DELETE("/:id/phase-gates/:phase", h.PhaseGate.DeleteTemplateGate)
 			}
 
 			// 从模板创建项目
@@ -1523,6 +2111,7 @@ func registerRoutes(r *gin.Engine, h *handler.Handlers, svc *service.Services, c
 					pos.GET("/:id", srmH.PO.GetPO)
 					pos.PUT("/:id", srmH.PO.UpdatePO)
 					pos.POST("/:id/submit", srmH.PO.SubmitPO)
+					pos.POST("/:id/send", srmH.PO.SendPO)
 					pos.POST("/:id/approve", srmH.PO.ApprovePO)
 					pos.POST("/:id/items/:itemId/receive", srmH.PO.ReceiveItem)
 					pos.DELETE("/:id", srmH.PO.DeletePO)
@@ -1658,7 +2247,7 @@ func registerRoutes(r *gin.Engine, h *handler.Handlers, svc *service.Services, c
 
 // =============================================================================
 // 飞书Webhook处理函数
-// 暂时只做日志记录，真正的业务处理在Phase 3实现
+// 审批事件暂时只做日志记录，任务变更事件交给飞书任务同步处理
 // =============================================================================
 
 // handleFeishuApprovalWebhook 处理飞书审批回调事件
@@ -1698,33 +2287,48 @@ func handleFeishuApprovalWebhook(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"code": 0})
 }
 
-// handleFeishuEventVerification 处理飞书事件订阅URL验证
-func handleFeishuEventVerification(c *gin.Context) {
-	body, err := io.ReadAll(c.Request.Body)
-	if err != nil {
-		log.Printf("[Feishu Webhook] 读取请求体失败: %v", err)
-		c.JSON(http.StatusBadRequest, gin.H{"code": -1, "msg": "读取请求体失败"})
-		return
-	}
+// handleFeishuEvent 处理飞书事件订阅（URL验证、任务变更事件）
+// 事件会改动任务状态和飞书关联，先按 verification_token / encrypt_key 校验来源
+func handleFeishuEvent(taskSync *handler.FeishuTaskSyncHandler, feishuCfg config.FeishuConfig) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		body, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			log.Printf("[Feishu Webhook] 读取请求体失败: %v", err)
+			c.JSON(http.StatusBadRequest, gin.H{"code": -1, "msg": "读取请求体失败"})
+			return
+		}
 
-	// 获取事件类型
-	eventType := feishu.GetEventType(body)
-	log.Printf("[Feishu Webhook] 收到事件: type=%s", eventType)
+		if err := feishu.VerifyEvent(c.Request.Header, body, feishuCfg.VerificationToken, feishuCfg.EncryptKey); err != nil {
+			log.Printf("[Feishu Webhook] 事件校验失败: %v", err)
+			c.JSON(http.StatusUnauthorized, gin.H{"code": -1, "msg": "事件校验失败"})
+			return
+		}
 
-	// 处理URL验证
-	if feishu.IsVerificationEvent(body) {
-		challenge, err := feishu.HandleVerification(body)
-		if err != nil {
-			log.Printf("[Feishu Webhook] URL验证失败: %v", err)
-			c.JSON(http.StatusBadRequest, gin.H{"code": -1, "msg": "URL验证失败"})
+		// 获取事件类型
+		eventType := feishu.GetEventType(body)
+		log.Printf("[Feishu Webhook] 收到事件: type=%s", eventType)
+
+		// 处理URL验证
+		if feishu.IsVerificationEvent(body) {
+			challenge, err := feishu.HandleVerification(body)
+			if err != nil {
+				log.Printf("[Feishu Webhook] URL验证失败: %v", err)
+				c.JSON(http.StatusBadRequest, gin.H{"code": -1, "msg": "URL验证失败"})
+				return
+			}
+			log.Printf("[Feishu Webhook] URL验证成功, challenge=%s", challenge)
+			c.JSON(http.StatusOK, gin.H{"challenge": challenge})
 			return
 		}
-		log.Printf("[Feishu Webhook] URL验证成功, challenge=%s", challenge)
-		c.JSON(http.StatusOK, gin.H{"challenge": challenge})
-		return
-	}
 
-	// 其他事件暂时只记录日志（Phase 3 将在此处扩展）
-	log.Printf("[Feishu Webhook] 收到未处理的事件: %s, body=%s", eventType, string(body))
-	c.JSON(http.StatusOK, gin.H{"code": 0})
+		// 任务变更事件：同步飞书任务的完成/转派
+		if eventType == feishu.EventTypeTaskUpdated && taskSync != nil {
+			taskSync.HandleTaskEvent(c, body)
+			return
+		}
+
+		// 其他事件暂时只记录日志（Phase 3 将在此处扩展）
+		log.Printf("[Feishu Webhook] 收到未处理的事件: %s, body=%s", eventType, string(body))
+		c.JSON(http.StatusOK, gin.H{"code": 0})
+	}
 }
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/bitfantasy/nimo/internal/config"
+	"github.com/bitfantasy/nimo/internal/shared/engine"
+	"github.com/joho/godotenv"
+	"go.uber.org/zap"
+	"gorm.io/gorm/logger"
+)
+
+// runStateCommand 实体状态运维工具（需要数据库连接）
+//
+//	plm state rebuild [-entity-type plm_task] [-apply] [-json]  按转换日志重建 entity_states，默认只报告差异
+func runStateCommand(args []string) int {
+	if len(args) == 0 || args[0] != "rebuild" {
+		fmt.Fprintln(os.Stderr, "用法: plm state rebuild [-entity-type <type>] [-apply] [-json]")
+		return 2
+	}
+
+	fs := flag.NewFlagSet("state rebuild", flag.ContinueOnError)
+	entityType := fs.String("entity-type", "", "只处理指定实体类型（默认全部）")
+	apply := fs.Bool("apply", false, "修复不一致的实体状态（默认只报告）")
+	asJSON := fs.Bool("json", false, "以 JSON 输出报告")
+	if err := fs.Parse(args[1:]); err != nil {
+		return 2
+	}
+
+	_ = godotenv.Load()
+	cfg, err := config.Load()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "加载配置失败: %v\n", err)
+		return 1
+	}
+	db, err := initDatabase(cfg.Database)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "连接数据库失败: %v\n", err)
+		return 1
+	}
+	db.Logger = db.Logger.LogMode(logger.Warn)
+
+	stateEngine := engine.NewEngine(db, nil)
+	zapLogger, _ := zap.NewDevelopment()
+	registerStateMachines(stateEngine, zapLogger)
+
+	report, err := stateEngine.RebuildEntityStates(*entityType, !*apply)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "重建失败: %v\n", err)
+		return 1
+	}
+
+	if *asJSON {
+		out, _ := json.MarshalIndent(report, "", "  ")
+		fmt.Println(string(out))
+	} else {
+		for _, d := range report.Discrepancies {
+			status := ""
+			if d.Fixed {
+				status = " [已修复]"
+			}
+			fmt.Printf("%-12s %s/%s stored=%s rebuilt=%s%s", d.Kind, d.EntityType, d.EntityID, d.StoredState, d.RebuiltState, status)
+			if d.Detail != "" {
+				fmt.Printf(" (%s)", d.Detail)
+			}
+			fmt.Println()
+		}
+		fmt.Printf("扫描 %d，一致 %d，差异 %d，修复 %d（dry_run=%v）\n",
+			report.Scanned, report.Consistent, len(report.Discrepancies), report.Fixed, report.DryRun)
+	}
+
+	if !*apply && len(report.Discrepancies) > 0 {
+		return 1
+	}
+	return 0
+}
@@ -10,14 +10,15 @@ import (
 )
 
 type Config struct {
-	Server   ServerConfig   `mapstructure:"server"`
-	Database DatabaseConfig `mapstructure:"database"`
-	Redis    RedisConfig    `mapstructure:"redis"`
-	RabbitMQ RabbitMQConfig `mapstructure:"rabbitmq"`
-	MinIO    MinIOConfig    `mapstructure:"minio"`
-	JWT      JWTConfig      `mapstructure:"jwt"`
-	Feishu   FeishuConfig   `mapstructure:"feishu"`
-	Log      LogConfig      `mapstructure:"log"`
+	Server    ServerConfig    `mapstructure:"server"`
+	Database  DatabaseConfig  `mapstructure:"database"`
+	Redis     RedisConfig     `mapstructure:"redis"`
+	RabbitMQ  RabbitMQConfig  `mapstructure:"rabbitmq"`
+	MinIO     MinIOConfig     `mapstructure:"minio"`
+	JWT       JWTConfig       `mapstructure:"jwt"`
+	Feishu    FeishuConfig    `mapstructure:"feishu"`
+	Log       LogConfig       `mapstructure:"log"`
+	Scheduler SchedulerConfig `mapstructure:"scheduler"`
 }
 
 type ServerConfig struct {
@@ -78,6 +79,10 @@ type FeishuConfig struct {
 	EncryptKey        string `mapstructure:"encrypt_key"`
 	VerificationToken string `mapstructure:"verification_token"`
 	RedirectURI       string `mapstructure:"redirect_uri"`
+	// WebBaseURL 前端访问地址，通知卡片中的详情链接以此拼接
+	WebBaseURL string `mapstructure:"web_base_url"`
+	// SRMNotifyOpenID 供应商联系人没有飞书账号时，接收 PO 通知并负责转达的采购人员 open_id
+	SRMNotifyOpenID string `mapstructure:"srm_notify_open_id"`
 }
 
 type LogConfig struct {
@@ -91,6 +96,12 @@ type LogConfig struct {
 	Compress   bool   `mapstructure:"compress"`
 }
 
+// SchedulerConfig 后台定时任务
+type SchedulerConfig struct {
+	// Lock 多实例互斥锁后端：db（默认，job_locks 表）/ redis
+	Lock string `mapstructure:"lock"`
+}
+
 func Load() (*Config, error) {
 	v := viper.New()
 
@@ -161,6 +172,11 @@ func bindEnvVariables(v *viper.Viper) {
 	v.BindEnv("feishu.encrypt_key", "FEISHU_ENCRYPT_KEY")
 	v.BindEnv("feishu.verification_token", "FEISHU_VERIFICATION_TOKEN")
 	v.BindEnv("feishu.redirect_uri", "FEISHU_REDIRECT_URI")
+	v.BindEnv("feishu.web_base_url", "FEISHU_WEB_BASE_URL")
+	v.BindEnv("feishu.srm_notify_open_id", "FEISHU_SRM_NOTIFY_OPEN_ID")
+
+	// Scheduler
+	v.BindEnv("scheduler.lock", "SCHEDULER_LOCK")
 }
 
 // GetEnvOrDefault 获取环境变量，如果不存在则返回默认值
@@ -30,11 +30,16 @@ type ApprovalRequest struct {
 	Code          string          `json:"code" gorm:"size:50"`
 	CurrentNode   int             `json:"current_node" gorm:"default:0"`
 	FlowSnapshot  json.RawMessage `json:"flow_snapshot" gorm:"type:jsonb"`
+	// 审批人解析记录 []ApproverResolution
+	ApproverResolutions json.RawMessage `json:"approver_resolutions,omitempty" gorm:"type:jsonb"`
+	// 发起人自选的审批人 node_index(string) -> user_ids，后续节点激活时按此解析
+	SelectedApprovers json.RawMessage `json:"selected_approvers,omitempty" gorm:"type:jsonb"`
 	CreatedAt     time.Time       `json:"created_at"`
 	UpdatedAt     time.Time       `json:"updated_at"`
 
 	// 关联
 	Reviewers []ApprovalReviewer `json:"reviewers,omitempty" gorm:"foreignKey:ApprovalID"`
+	Branches  []ApprovalBranch   `json:"branches,omitempty" gorm:"foreignKey:ApprovalID"`
 	Requester *User              `json:"requester,omitempty" gorm:"foreignKey:RequestedBy"`
 	Task      *Task              `json:"task,omitempty" gorm:"foreignKey:TaskID"`
 	Project   *Project           `json:"project,omitempty" gorm:"foreignKey:ProjectID"`
@@ -64,3 +69,32 @@ type ApprovalReviewer struct {
 func (ApprovalReviewer) TableName() string {
 	return "approval_reviewers"
 }
+
+// 审批分支状态
+const (
+	ApprovalBranchActive    = "active"    // 停在审批节点等待审批
+	ApprovalBranchSplit     = "split"     // 已拆分为并行子分支，等待子分支汇合
+	ApprovalBranchJoined    = "joined"    // 已到达汇合节点，等待其他分支
+	ApprovalBranchCompleted = "completed" // 已结束（到达结束节点或已汇合）
+	ApprovalBranchCanceled  = "canceled"  // 审批被驳回
+)
+
+// ApprovalBranch 审批实例的分支进度
+// 主干为根分支；并行节点的每条出线拆出一个子分支，全部子分支到达汇合节点后父分支继续
+type ApprovalBranch struct {
+	ID          string          `json:"id" gorm:"primaryKey;size:36"`
+	ApprovalID  string          `json:"approval_id" gorm:"size:36;not null;index"`
+	ParentID    string          `json:"parent_id" gorm:"size:36"`
+	SplitNodeID string          `json:"split_node_id" gorm:"size:64"` // 拆出该分支的并行节点
+	Name        string          `json:"name" gorm:"size:100"`
+	NodeID      string          `json:"node_id" gorm:"size:64"` // 当前所在节点
+	NodeIndex   int             `json:"node_index" gorm:"default:0"`
+	Status      string          `json:"status" gorm:"size:20;not null"`
+	Path        json.RawMessage `json:"path" gorm:"type:jsonb"` // 经过的节点ID
+	CreatedAt   time.Time       `json:"created_at"`
+	UpdatedAt   time.Time       `json:"updated_at"`
+}
+
+func (ApprovalBranch) TableName() string {
+	return "approval_branches"
+}
@@ -47,17 +47,29 @@ const (
 )
 
 // FlowSchema 流程定义
+// 未配置 edges 时节点按数组顺序串行；配置 edges 后按有向图流转，支持条件分支和并行分支
 type FlowSchema struct {
 	Nodes []FlowNode `json:"nodes"`
+	Edges []FlowEdge `json:"edges,omitempty"`
 }
 
 // FlowNode 流程节点
 type FlowNode struct {
-	Type   string         `json:"type"` // submit, approve, end
+	ID     string         `json:"id,omitempty"` // 配置 edges 时必填
+	Type   string         `json:"type"`         // submit, approve, condition, parallel, join, end
 	Name   string         `json:"name"`
 	Config FlowNodeConfig `json:"config"`
 }
 
+// FlowEdge 流程连线
+type FlowEdge struct {
+	From      string          `json:"from"`
+	To        string          `json:"to"`
+	Name      string          `json:"name,omitempty"`
+	Condition json.RawMessage `json:"condition,omitempty"` // 条件节点出线的条件，按表单值求值
+	Default   bool            `json:"default,omitempty"`   // 条件节点的默认出线
+}
+
 // FlowNodeConfig 节点配置
 type FlowNodeConfig struct {
 	// submit node
@@ -69,4 +81,29 @@ type FlowNodeConfig struct {
 	MultiApprove string   `json:"multi_approve,omitempty"` // all, any, sequential
 	WhenSelf     string   `json:"when_self,omitempty"`
 	SelectRange  string   `json:"select_range,omitempty"`
+	// approver resolution
+	SupervisorLevel     int      `json:"supervisor_level,omitempty"`      // supervisor: 第几级上级，默认 1（直属上级）
+	DeptLevel           int      `json:"dept_level,omitempty"`            // dept_leader: 第几级部门负责人，默认 1（所在部门）
+	RoleCode            string   `json:"role_code,omitempty"`             // role: 角色编码
+	RoleScope           string   `json:"role_scope,omitempty"`            // role: global（系统角色，默认）, project（项目角色分配）
+	FallbackApproverIDs []string `json:"fallback_approver_ids,omitempty"` // 解析不到审批人时的兜底审批人
+}
+
+// 审批人解析来源
+const (
+	ApproverSourceResolved = "resolved" // 按节点配置解析
+	ApproverSourceFallback = "fallback" // 解析为空，使用兜底审批人
+	ApproverSourceEmpty    = "empty"    // 兜底后仍无审批人
+	ApproverSourceAdmin    = "admin"    // 兜底后仍无审批人，流转中转交管理员处理
+)
+
+// ApproverResolution 审批人解析记录（记录在审批实例上用于审计）
+type ApproverResolution struct {
+	NodeIndex    int       `json:"node_index"`
+	NodeName     string    `json:"node_name"`
+	ApproverType string    `json:"approver_type"`
+	Source       string    `json:"source"`
+	UserIDs      []string  `json:"user_ids"`
+	Detail       string    `json:"detail"`
+	ResolvedAt   time.Time `json:"resolved_at"`
 }
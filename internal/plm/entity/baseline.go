@@ -0,0 +1,50 @@
+package entity
+
+import "time"
+
+// 基线来源
+const (
+	BaselineSourceManual    = "manual"     // 手动保存
+	BaselineSourcePhaseGate = "phase_gate" // 阶段门通过时自动保存
+)
+
+// ProjectBaseline 项目进度基线：某一时点的计划快照
+// 任务计划日期后续可调整，基线保留原始计划用于偏差和挣值分析
+type ProjectBaseline struct {
+	ID           string     `json:"id" gorm:"primaryKey;size:32"`
+	ProjectID    string     `json:"project_id" gorm:"size:32;not null;index"`
+	Name         string     `json:"name" gorm:"size:128;not null"`
+	Description  string     `json:"description" gorm:"type:text"`
+	Source       string     `json:"source" gorm:"size:16;not null;default:manual"`
+	PhaseID      *string    `json:"phase_id" gorm:"size:32"`
+	Phase        string     `json:"phase" gorm:"size:16"` // 保存时项目所处阶段
+	IsActive     bool       `json:"is_active" gorm:"default:false"`
+	PlannedStart *time.Time `json:"planned_start" gorm:"type:date"`
+	PlannedEnd   *time.Time `json:"planned_end" gorm:"type:date"`
+	TotalHours   float64    `json:"total_hours" gorm:"type:decimal(10,2)"` // 完工预算（预估工时合计）
+	TaskCount    int        `json:"task_count"`
+	CreatedBy    string     `json:"created_by" gorm:"size:32"`
+	CreatedAt    time.Time  `json:"created_at"`
+
+	Tasks []TaskBaseline `json:"tasks,omitempty" gorm:"foreignKey:BaselineID"`
+}
+
+func (ProjectBaseline) TableName() string {
+	return "project_baselines"
+}
+
+// TaskBaseline 基线中的任务计划
+type TaskBaseline struct {
+	ID             string     `json:"id" gorm:"primaryKey;size:32"`
+	BaselineID     string     `json:"baseline_id" gorm:"size:32;not null;index"`
+	TaskID         string     `json:"task_id" gorm:"size:32;not null"`
+	Code           string     `json:"code" gorm:"size:64"`
+	Title          string     `json:"title" gorm:"size:256"`
+	PlannedStart   *time.Time `json:"planned_start" gorm:"type:date"`
+	PlannedEnd     *time.Time `json:"planned_end" gorm:"type:date"`
+	EstimatedHours float64    `json:"estimated_hours" gorm:"type:decimal(8,2)"`
+}
+
+func (TaskBaseline) TableName() string {
+	return "task_baselines"
+}
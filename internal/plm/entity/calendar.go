@@ -0,0 +1,69 @@
+package entity
+
+import "time"
+
+// 日历日期来源
+const (
+	CalendarDaySourceManual = "manual"
+	CalendarDaySourceICS    = "ics"
+	CalendarDaySourceJSON   = "json"
+)
+
+// 个人日历例外类型
+const (
+	CalendarExceptionLeave    = "leave"    // 请假（不工作）
+	CalendarExceptionTrip     = "trip"     // 出差（不参与项目工作）
+	CalendarExceptionOvertime = "overtime" // 加班（休息日工作）
+)
+
+// WorkCalendar 公司工作日历
+// 工作周定义常规工作日，CalendarDay 覆盖法定节假日和调休上班
+type WorkCalendar struct {
+	ID          string    `json:"id" gorm:"primaryKey;size:32"`
+	Code        string    `json:"code" gorm:"size:64;not null;uniqueIndex"`
+	Name        string    `json:"name" gorm:"size:128;not null"`
+	Description string    `json:"description" gorm:"type:text"`
+	WorkWeek    string    `json:"work_week" gorm:"size:16;not null;default:12345"` // 0-6 数字，0 为周日
+	IsDefault   bool      `json:"is_default" gorm:"default:false"`
+	CreatedBy   string    `json:"created_by" gorm:"size:32"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+func (WorkCalendar) TableName() string {
+	return "work_calendars"
+}
+
+// CalendarDay 日历例外日期：节假日放假或调休上班
+type CalendarDay struct {
+	ID         string    `json:"id" gorm:"primaryKey;size:32"`
+	CalendarID string    `json:"calendar_id" gorm:"size:32;not null;uniqueIndex:idx_calendar_day"`
+	Date       time.Time `json:"date" gorm:"type:date;not null;uniqueIndex:idx_calendar_day"`
+	IsWorkday  bool      `json:"is_workday"`
+	Name       string    `json:"name" gorm:"size:64"`
+	Source     string    `json:"source" gorm:"size:16;default:manual"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+func (CalendarDay) TableName() string {
+	return "work_calendar_days"
+}
+
+// UserCalendarException 个人日历例外：请假、出差、加班
+type UserCalendarException struct {
+	ID        string    `json:"id" gorm:"primaryKey;size:32"`
+	UserID    string    `json:"user_id" gorm:"size:32;not null;index"`
+	StartDate time.Time `json:"start_date" gorm:"type:date;not null"`
+	EndDate   time.Time `json:"end_date" gorm:"type:date;not null"`
+	Type      string    `json:"type" gorm:"size:16;not null"`
+	IsWorkday bool      `json:"is_workday"` // 加班为 true，请假/出差为 false
+	Reason    string    `json:"reason" gorm:"size:256"`
+	CreatedBy string    `json:"created_by" gorm:"size:32"`
+	CreatedAt time.Time `json:"created_at"`
+
+	User *User `json:"user,omitempty" gorm:"foreignKey:UserID"`
+}
+
+func (UserCalendarException) TableName() string {
+	return "user_calendar_exceptions"
+}
@@ -0,0 +1,40 @@
+package entity
+
+import "time"
+
+// ChecklistItemDef 模板任务检查项定义（TemplateTask.Checklist 的元素）
+// 兼容纯字符串写法 ["检查项A", "检查项B"]，此时均为必检项
+type ChecklistItemDef struct {
+	Title       string `json:"title"`
+	Description string `json:"description"`
+	IsMandatory bool   `json:"is_mandatory"`
+}
+
+// 检查项来源
+const (
+	ChecklistSourceTemplate = "template" // 从模板任务实例化
+	ChecklistSourceManual   = "manual"   // 任务上手动添加
+)
+
+// TaskChecklistItem 任务检查项（项目创建时从模板任务实例化，也可手动添加）
+type TaskChecklistItem struct {
+	ID          string     `json:"id" gorm:"primaryKey;size:32"`
+	ProjectID   string     `json:"project_id" gorm:"size:32;not null;index"`
+	TaskID      string     `json:"task_id" gorm:"size:32;not null;index"`
+	Title       string     `json:"title" gorm:"size:500;not null"`
+	Description string     `json:"description" gorm:"type:text"`
+	IsMandatory bool       `json:"is_mandatory" gorm:"not null"` // 不设 gorm 默认值，否则 false 会被默认值覆盖
+	SortOrder   int        `json:"sort_order" gorm:"default:0"`
+	Source      string     `json:"source" gorm:"size:16;default:template"` // template/manual
+	IsChecked   bool       `json:"is_checked" gorm:"default:false"`
+	CheckedBy   *string    `json:"checked_by" gorm:"size:32"`
+	CheckedAt   *time.Time `json:"checked_at"`
+	Note        string     `json:"note" gorm:"type:text"` // 勾选备注
+	CreatedBy   string     `json:"created_by" gorm:"size:32"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+
+	Checker *User `json:"checker,omitempty" gorm:"foreignKey:CheckedBy"`
+}
+
+func (TaskChecklistItem) TableName() string { return "task_checklist_items" }
@@ -13,6 +13,7 @@ type PhaseDeliverable struct {
 	Status          string     `json:"status" gorm:"size:16;not null;default:pending"` // pending/submitted/approved
 	DocumentID      *string    `json:"document_id,omitempty" gorm:"size:32"`
 	BOMID           *string    `json:"bom_id,omitempty" gorm:"size:32"`
+	TaskID          *string    `json:"task_id,omitempty" gorm:"size:32"` // 产出任务，完成时自动登记
 	SubmittedAt     *time.Time `json:"submitted_at,omitempty"`
 	SubmittedBy     *string    `json:"submitted_by,omitempty" gorm:"size:32"`
 	ApprovedAt      *time.Time `json:"approved_at,omitempty"`
@@ -0,0 +1,79 @@
+package entity
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// 交付物类型
+const (
+	DeliverableTypeDocument = "document"
+	DeliverableTypeBOM      = "bom"
+	DeliverableTypeReview   = "review"
+)
+
+// 交付物状态
+const (
+	DeliverableStatusPending   = "pending"
+	DeliverableStatusSubmitted = "submitted"
+	DeliverableStatusApproved  = "approved"
+)
+
+// PhaseGateCriteria 阶段门禁条件
+// 项目创建时从模板复制到 ProjectPhase.ExitCriteria，项目阶段未配置时只检查必需交付物
+type PhaseGateCriteria struct {
+	RequireDeliverables  bool `json:"require_deliverables"`   // 必需交付物均已批准
+	RequireCriticalTasks bool `json:"require_critical_tasks"` // 阶段内关键任务（模板 is_critical）均已完成
+	MaxOpenECNs          *int `json:"max_open_ecns"`          // 项目未关闭 ECN 数上限，为空不检查
+	RequireBOMReleased   bool `json:"require_bom_released"`   // 阶段 BOM 均已发布
+}
+
+// PhaseGateDeliverableDef 模板阶段交付物定义（项目创建时生成 PhaseDeliverable）
+type PhaseGateDeliverableDef struct {
+	Name            string `json:"name"`
+	DeliverableType string `json:"deliverable_type"` // document/bom/review
+	ResponsibleRole string `json:"responsible_role"`
+	IsRequired      bool   `json:"is_required"`
+	TaskCode        string `json:"task_code"` // 产出该交付物的模板任务，任务完成时自动登记
+}
+
+// TemplatePhaseGate 阶段门禁配置（模板级别）
+type TemplatePhaseGate struct {
+	ID                   string          `json:"id" gorm:"primaryKey;size:36"`
+	TemplateID           string          `json:"template_id" gorm:"size:36;not null;index"`
+	Phase                string          `json:"phase" gorm:"size:20;not null"` // concept/evt/dvt/pvt/mp
+	RequireDeliverables  bool            `json:"require_deliverables" gorm:"default:true"`
+	RequireCriticalTasks bool            `json:"require_critical_tasks" gorm:"default:false"`
+	MaxOpenECNs          *int            `json:"max_open_ecns" gorm:"column:max_open_ecns"`
+	RequireBOMReleased   bool            `json:"require_bom_released" gorm:"column:require_bom_released;default:false"`
+	Deliverables         json.RawMessage `json:"deliverables" gorm:"type:jsonb"` // []PhaseGateDeliverableDef
+	CreatedAt            time.Time       `json:"created_at"`
+	UpdatedAt            time.Time       `json:"updated_at"`
+}
+
+func (TemplatePhaseGate) TableName() string { return "template_phase_gates" }
+
+// Criteria 门禁条件
+func (g *TemplatePhaseGate) Criteria() PhaseGateCriteria {
+	return PhaseGateCriteria{
+		RequireDeliverables:  g.RequireDeliverables,
+		RequireCriticalTasks: g.RequireCriticalTasks,
+		MaxOpenECNs:          g.MaxOpenECNs,
+		RequireBOMReleased:   g.RequireBOMReleased,
+	}
+}
+
+// PhaseGateOverride 阶段门禁强制放行记录
+type PhaseGateOverride struct {
+	ID            string          `json:"id" gorm:"primaryKey;size:32"`
+	ProjectID     string          `json:"project_id" gorm:"size:32;not null;index"`
+	PhaseID       string          `json:"phase_id" gorm:"size:32;not null;index"`
+	Justification string          `json:"justification" gorm:"type:text;not null"`
+	FailedChecks  json.RawMessage `json:"failed_checks" gorm:"type:jsonb"` // 放行时未通过的检查项
+	OverriddenBy  string          `json:"overridden_by" gorm:"size:32;not null"`
+	CreatedAt     time.Time       `json:"created_at"`
+
+	Overrider *User `json:"overrider,omitempty" gorm:"foreignKey:OverriddenBy"`
+}
+
+func (PhaseGateOverride) TableName() string { return "phase_gate_overrides" }
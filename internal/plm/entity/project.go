@@ -25,6 +25,8 @@ type Project struct {
 	FeishuProjectKey string    `json:"feishu_project_key" gorm:"size:64"`
 	TemplateID      *string    `json:"template_id" gorm:"size:36"`
 	AutoStartTasks  bool       `json:"auto_start_tasks" gorm:"default:true"`
+	CalendarID      *string    `json:"calendar_id" gorm:"size:32"` // 工作日历，为空时使用默认日历
+	Attributes      JSONB      `json:"attributes" gorm:"type:jsonb"` // 项目属性（产品类型、目标市场等），决定模板任务是否包含
 	CreatedBy       string     `json:"created_by" gorm:"size:32;not null"`
 	CreatedAt       time.Time  `json:"created_at"`
 	UpdatedAt       time.Time  `json:"updated_at"`
@@ -123,6 +125,9 @@ type Task struct {
 	Creator      *User            `json:"creator,omitempty" gorm:"foreignKey:CreatedBy"`
 	SubTasks     []Task           `json:"sub_tasks,omitempty" gorm:"foreignKey:ParentTaskID"`
 	Dependencies []TaskDependency `json:"dependencies,omitempty" gorm:"-"` // 非数据库字段，手动加载
+	BaselineStart *time.Time     `json:"baseline_start,omitempty" gorm:"-"` // 当前基线的计划开始，非数据库字段
+	BaselineEnd   *time.Time     `json:"baseline_end,omitempty" gorm:"-"`   // 当前基线的计划完成，非数据库字段
+	OverdueDays   int            `json:"overdue_days,omitempty" gorm:"-"`   // 逾期工作日数，非数据库字段
 }
 
 func (Task) TableName() string {
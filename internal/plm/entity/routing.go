@@ -30,11 +30,12 @@ func (RoutingRule) TableName() string { return "routing_rules" }
 
 // RoutingDecision 路由决策结果
 type RoutingDecision struct {
-	Channel      string                 `json:"channel"`       // feishu | agent
-	RuleID       string                 `json:"rule_id"`       // 匹配的规则ID（空=默认规则）
-	RuleName     string                 `json:"rule_name"`     // 匹配的规则名称
-	ActionConfig map[string]interface{} `json:"action_config"` // 动作配置
-	Reason       string                 `json:"reason"`        // 决策原因
+	Channel      string                 `json:"channel"`          // feishu | agent
+	RuleID       string                 `json:"rule_id"`          // 匹配的规则ID（空=默认规则）
+	RuleName     string                 `json:"rule_name"`        // 匹配的规则名称
+	ActionConfig map[string]interface{} `json:"action_config"`    // 动作配置
+	Reason       string                 `json:"reason"`           // 决策原因
+	Errors       []string               `json:"errors,omitempty"` // 条件评估出错而被跳过的规则
 }
 
 // RoutingLog 路由日志
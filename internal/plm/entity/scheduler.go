@@ -0,0 +1,39 @@
+package entity
+
+import "time"
+
+// 定时任务执行状态
+const (
+	JobRunStatusSuccess = "success"
+	JobRunStatusFailed  = "failed"
+)
+
+// JobRun 定时任务执行记录
+type JobRun struct {
+	ID         string    `json:"id" gorm:"primaryKey;size:32"`
+	JobName    string    `json:"job_name" gorm:"size:64;not null;index"`
+	Trigger    string    `json:"trigger" gorm:"size:16;not null"` // schedule / manual
+	Instance   string    `json:"instance" gorm:"size:128"`        // 执行实例（主机名:PID）
+	ScheduleAt time.Time `json:"schedule_at"`
+	StartedAt  time.Time `json:"started_at"`
+	FinishedAt time.Time `json:"finished_at"`
+	DurationMs int64     `json:"duration_ms"`
+	Status     string    `json:"status" gorm:"size:16;not null"`
+	Output     string    `json:"output" gorm:"type:text"`
+	Error      string    `json:"error" gorm:"type:text"`
+}
+
+func (JobRun) TableName() string {
+	return "scheduler_job_runs"
+}
+
+// SchedulerLock 定时任务数据库锁（未配置 Redis 时使用），过期后可被清理
+type SchedulerLock struct {
+	LockKey   string    `json:"lock_key" gorm:"primaryKey;size:128"`
+	Owner     string    `json:"owner" gorm:"size:128"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+func (SchedulerLock) TableName() string {
+	return "scheduler_locks"
+}
@@ -21,22 +21,40 @@ func (TaskForm) TableName() string {
 	return "task_forms"
 }
 
-// TaskFormSubmission 表单提交记录
+// TaskFormSubmission 表单提交记录（每次提交一个版本，提交后不再修改；驳回时记录驳回意见）
 type TaskFormSubmission struct {
-	ID          string          `json:"id" gorm:"primaryKey;size:32"`
-	FormID      string          `json:"form_id" gorm:"size:32;not null"`
-	TaskID      string          `json:"task_id" gorm:"size:32;not null"`
-	Data        JSONB           `json:"data" gorm:"type:jsonb;not null;default:'{}'"`
-	Files       json.RawMessage `json:"files" gorm:"type:jsonb;default:'[]'"`
-	SubmittedBy string          `json:"submitted_by" gorm:"size:32;not null"`
-	SubmittedAt time.Time       `json:"submitted_at"`
-	Version     int             `json:"version" gorm:"not null;default:0"`
+	ID              string          `json:"id" gorm:"primaryKey;size:32"`
+	FormID          string          `json:"form_id" gorm:"size:32;not null"`
+	TaskID          string          `json:"task_id" gorm:"size:32;not null"`
+	Data            JSONB           `json:"data" gorm:"type:jsonb;not null;default:'{}'"`
+	Files           json.RawMessage `json:"files" gorm:"type:jsonb;default:'[]'"`
+	SubmittedBy     string          `json:"submitted_by" gorm:"size:32;not null"`
+	SubmittedAt     time.Time       `json:"submitted_at"`
+	Version         int             `json:"version" gorm:"not null;default:0"`
+	RejectionReason string          `json:"rejection_reason,omitempty" gorm:"type:text"`
+	RejectedBy      *string         `json:"rejected_by,omitempty" gorm:"size:32"`
+	RejectedAt      *time.Time      `json:"rejected_at,omitempty"`
 }
 
 func (TaskFormSubmission) TableName() string {
 	return "task_form_submissions"
 }
 
+// TaskFormDraft 表单草稿（每人每个任务一份，自动保存，提交后删除）
+type TaskFormDraft struct {
+	ID        string    `json:"id" gorm:"primaryKey;size:32"`
+	FormID    string    `json:"form_id" gorm:"size:32;not null"`
+	TaskID    string    `json:"task_id" gorm:"size:32;not null;uniqueIndex:idx_task_form_draft_user"`
+	UserID    string    `json:"user_id" gorm:"size:32;not null;uniqueIndex:idx_task_form_draft_user"`
+	Data      JSONB     `json:"data" gorm:"type:jsonb;not null;default:'{}'"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+func (TaskFormDraft) TableName() string {
+	return "task_form_drafts"
+}
+
 // TemplateTaskForm 模板任务表单
 type TemplateTaskForm struct {
 	ID         string          `json:"id" gorm:"primaryKey;size:32"`
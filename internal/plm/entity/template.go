@@ -55,6 +55,7 @@ type TemplateTask struct {
 	FeishuApprovalCode    string          `json:"feishu_approval_code" gorm:"size:100"`
 	SortOrder             int             `json:"sort_order" gorm:"default:0"`
 	IsLocked              bool            `json:"is_locked" gorm:"default:false"`
+	InclusionCondition    json.RawMessage `json:"inclusion_condition" gorm:"type:jsonb"` // 包含条件（按项目属性求值，为空总是包含）
 	CreatedAt             time.Time       `json:"created_at"`
 	UpdatedAt             time.Time       `json:"updated_at"`
 
@@ -81,17 +82,27 @@ func (TemplateTaskDependency) TableName() string {
 	return "template_task_dependencies"
 }
 
+// 飞书任务同步状态
+const (
+	FeishuSyncStatusSynced   = "SYNCED"   // 两端一致
+	FeishuSyncStatusFailed   = "FAILED"   // 同步失败，见 SyncError，可重试
+	FeishuSyncStatusConflict = "CONFLICT" // 上次同步后两端均有修改，需人工重试（以PLM为准）
+)
+
 // FeishuTaskSync 飞书任务同步记录
+// 飞书任务创建失败时 FeishuTaskID 为空，重试成功后回填
 type FeishuTaskSync struct {
 	ID            string     `json:"id" gorm:"primaryKey;size:36"`
 	TaskID        string     `json:"task_id" gorm:"size:32;uniqueIndex;not null"`
-	FeishuTaskID  string     `json:"feishu_task_id" gorm:"size:100;uniqueIndex;not null"`
+	FeishuTaskID  *string    `json:"feishu_task_id" gorm:"size:100;uniqueIndex"`
 	FeishuTaskGUID string    `json:"feishu_task_guid" gorm:"size:100"`
 	SyncStatus    string     `json:"sync_status" gorm:"size:20;default:'SYNCED'"`
 	LastSyncAt    *time.Time `json:"last_sync_at"`
 	SyncError     string     `json:"sync_error" gorm:"type:text"`
 	CreatedAt     time.Time  `json:"created_at"`
 	UpdatedAt     time.Time  `json:"updated_at"`
+
+	Task *Task `json:"task,omitempty" gorm:"foreignKey:TaskID"`
 }
 
 func (FeishuTaskSync) TableName() string {
@@ -121,13 +132,33 @@ func (ApprovalInstance) TableName() string {
 	return "approval_instances"
 }
 
+// 评审会议类型
+const (
+	ReviewMeetingTypeDesign = "DESIGN"
+	ReviewMeetingTypePhase  = "PHASE"
+	ReviewMeetingTypeBOM    = "BOM"
+	ReviewMeetingTypeECN    = "ECN"
+)
+
+// 评审会议状态
+const (
+	ReviewMeetingStatusScheduled  = "SCHEDULED"
+	ReviewMeetingStatusInProgress = "IN_PROGRESS"
+	ReviewMeetingStatusCompleted  = "COMPLETED"
+	ReviewMeetingStatusCancelled  = "CANCELLED"
+)
+
 // ReviewMeeting 评审会议
+// 关联任务的评审未结束（COMPLETED）或取消前，任务不能完成
 type ReviewMeeting struct {
 	ID                     string          `json:"id" gorm:"primaryKey;size:36"`
 	Title                  string          `json:"title" gorm:"size:200;not null"`
 	MeetingType            string          `json:"meeting_type" gorm:"size:20;not null"` // DESIGN/PHASE/BOM/ECN
 	ProjectID              string          `json:"project_id" gorm:"size:32;index"`
 	TaskID                 string          `json:"task_id" gorm:"size:32;index"`
+	PhaseID                string          `json:"phase_id" gorm:"size:32"`
+	BOMID                  string          `json:"bom_id" gorm:"column:bom_id;size:32"`
+	ECNID                  string          `json:"ecn_id" gorm:"column:ecn_id;size:32"`
 	FeishuCalendarEventID  string          `json:"feishu_calendar_event_id" gorm:"size:100"`
 	FeishuMeetingID        string          `json:"feishu_meeting_id" gorm:"size:100"`
 	ScheduledAt            time.Time       `json:"scheduled_at" gorm:"not null"`
@@ -141,6 +172,9 @@ type ReviewMeeting struct {
 	Conclusion             string          `json:"conclusion" gorm:"type:text"`
 	ActionItems            json.RawMessage `json:"action_items" gorm:"type:jsonb"`
 	MinutesDocID           *string         `json:"minutes_doc_id" gorm:"size:36"`
+	ConcludedBy            string          `json:"concluded_by" gorm:"size:32"`
+	ConcludedAt            *time.Time      `json:"concluded_at"`
+	CreatedBy              string          `json:"created_by" gorm:"size:32"`
 	CreatedAt              time.Time       `json:"created_at"`
 	UpdatedAt              time.Time       `json:"updated_at"`
 }
@@ -149,9 +183,25 @@ func (ReviewMeeting) TableName() string {
 	return "review_meetings"
 }
 
+// ReviewAttendee 评审会议参会人（ReviewMeeting.Attendees 元素）
+type ReviewAttendee struct {
+	UserID string `json:"user_id"`
+	Name   string `json:"name"`
+}
+
+// ReviewActionItem 评审待办（ReviewMeeting.ActionItems 元素），转为任务后记录 TaskID
+type ReviewActionItem struct {
+	Title       string     `json:"title"`
+	Description string     `json:"description,omitempty"`
+	AssigneeID  string     `json:"assignee_id"`
+	DueDate     *time.Time `json:"due_date"`
+	TaskID      string     `json:"task_id,omitempty"`
+}
+
 // AutomationRule 自动化规则
 type AutomationRule struct {
 	ID               string          `json:"id" gorm:"primaryKey;size:36"`
+	Name             string          `json:"name" gorm:"size:128"`
 	RuleType         string          `json:"rule_type" gorm:"size:30;not null;index"` // TASK_START/TASK_COMPLETE/OVERDUE/PHASE_COMPLETE
 	TriggerCondition json.RawMessage `json:"trigger_condition" gorm:"type:jsonb;not null"`
 	ActionType       string          `json:"action_type" gorm:"size:30;not null"` // UPDATE_STATUS/SEND_NOTIFICATION/CREATE_TASK/CREATE_APPROVAL
@@ -159,7 +209,8 @@ type AutomationRule struct {
 	IsActive         bool            `json:"is_active" gorm:"default:true;index"`
 	ProjectID        string          `json:"project_id" gorm:"size:32;index"`
 	TemplateID       *string         `json:"template_id" gorm:"size:36"`
-	Priority         int             `json:"priority" gorm:"default:0"`
+	Priority         int             `json:"priority" gorm:"default:0"` // 数值大的先执行
+	CreatedBy        string          `json:"created_by" gorm:"size:32"`
 	CreatedAt        time.Time       `json:"created_at"`
 	UpdatedAt        time.Time       `json:"updated_at"`
 }
@@ -172,6 +223,9 @@ func (AutomationRule) TableName() string {
 type AutomationLog struct {
 	ID           string          `json:"id" gorm:"primaryKey;size:36"`
 	RuleID       *string         `json:"rule_id" gorm:"size:36;index"`
+	RuleType     string          `json:"rule_type" gorm:"size:30"`
+	ProjectID    string          `json:"project_id" gorm:"size:32;index"`
+	TaskID       string          `json:"task_id" gorm:"size:32;index"`
 	TriggerEvent json.RawMessage `json:"trigger_event" gorm:"type:jsonb;not null"`
 	ActionResult json.RawMessage `json:"action_result" gorm:"type:jsonb"`
 	Status       string          `json:"status" gorm:"size:20;not null"` // SUCCESS/FAILED/SKIPPED
@@ -182,3 +236,26 @@ type AutomationLog struct {
 func (AutomationLog) TableName() string {
 	return "automation_logs"
 }
+
+// 自动化规则触发类型
+const (
+	AutomationTriggerTaskStart     = "TASK_START"
+	AutomationTriggerTaskComplete  = "TASK_COMPLETE"
+	AutomationTriggerOverdue       = "OVERDUE"
+	AutomationTriggerPhaseComplete = "PHASE_COMPLETE"
+)
+
+// 自动化规则动作类型
+const (
+	AutomationActionUpdateStatus     = "UPDATE_STATUS"
+	AutomationActionSendNotification = "SEND_NOTIFICATION"
+	AutomationActionCreateTask       = "CREATE_TASK"
+	AutomationActionCreateApproval   = "CREATE_APPROVAL"
+)
+
+// 自动化执行结果
+const (
+	AutomationLogSuccess = "SUCCESS"
+	AutomationLogFailed  = "FAILED"
+	AutomationLogSkipped = "SKIPPED"
+)
@@ -17,6 +17,7 @@ type User struct {
 	Mobile        string     `json:"mobile" gorm:"size:20"`
 	AvatarURL     string     `json:"avatar_url" gorm:"size:512"`
 	DepartmentID  string     `json:"department_id" gorm:"size:32"`
+	ManagerID     string     `json:"manager_id" gorm:"size:32;index"` // 直属上级（飞书 leader_user_id）
 	Status        string     `json:"status" gorm:"size:16;not null;default:active"`
 	LastLoginAt   *time.Time `json:"last_login_at"`
 	CreatedAt     time.Time  `json:"created_at"`
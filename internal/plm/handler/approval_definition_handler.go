@@ -2,6 +2,7 @@ package handler
 
 import (
 	"github.com/bitfantasy/nimo/internal/plm/service"
+	"github.com/bitfantasy/nimo/internal/shared/formschema"
 	"github.com/gin-gonic/gin"
 )
 
@@ -34,6 +35,10 @@ func (h *ApprovalDefinitionHandler) CreateDefinition(c *gin.Context) {
 		BadRequest(c, "参数错误: "+err.Error())
 		return
 	}
+	if _, err := formschema.ParseAndCheck(req.FormSchema); err != nil {
+		BadRequest(c, err.Error())
+		return
+	}
 
 	userID := GetUserID(c)
 	if userID == "" {
@@ -71,6 +76,10 @@ func (h *ApprovalDefinitionHandler) UpdateDefinition(c *gin.Context) {
 		BadRequest(c, "参数错误: "+err.Error())
 		return
 	}
+	if _, err := formschema.ParseAndCheck(req.FormSchema); err != nil {
+		BadRequest(c, err.Error())
+		return
+	}
 
 	def, err := h.svc.Update(c.Request.Context(), id, req)
 	if err != nil {
@@ -97,7 +106,7 @@ func (h *ApprovalDefinitionHandler) DeleteDefinition(c *gin.Context) {
 func (h *ApprovalDefinitionHandler) PublishDefinition(c *gin.Context) {
 	id := c.Param("id")
 	if err := h.svc.Publish(c.Request.Context(), id); err != nil {
-		InternalError(c, err.Error())
+		BadRequest(c, err.Error())
 		return
 	}
 	Success(c, gin.H{"message": "发布成功"})
@@ -178,6 +187,9 @@ func (h *ApprovalDefinitionHandler) SubmitInstance(c *gin.Context) {
 
 	approval, err := h.svc.CreateInstance(c.Request.Context(), definitionID, req, userID)
 	if err != nil {
+		if formValidationFailed(c, err) {
+			return
+		}
 		InternalError(c, "发起审批失败: "+err.Error())
 		return
 	}
@@ -0,0 +1,159 @@
+package handler
+
+import (
+	"github.com/bitfantasy/nimo/internal/plm/service"
+	"github.com/gin-gonic/gin"
+)
+
+// AutomationHandler 自动化规则处理器
+type AutomationHandler struct {
+	svc *service.AutomationService
+}
+
+// NewAutomationHandler 创建自动化规则处理器
+func NewAutomationHandler(svc *service.AutomationService) *AutomationHandler {
+	return &AutomationHandler{svc: svc}
+}
+
+// ListRules 全局规则列表
+// GET /api/v1/automation-rules?rule_type=
+func (h *AutomationHandler) ListRules(c *gin.Context) {
+	h.listRules(c, "", "")
+}
+
+// CreateRule 创建全局规则
+// POST /api/v1/automation-rules
+func (h *AutomationHandler) CreateRule(c *gin.Context) {
+	h.createRule(c, "", "")
+}
+
+// ListProjectRules 项目级规则列表
+// GET /api/v1/projects/:id/automation-rules
+func (h *AutomationHandler) ListProjectRules(c *gin.Context) {
+	h.listRules(c, c.Param("id"), "")
+}
+
+// CreateProjectRule 创建项目级规则
+// POST /api/v1/projects/:id/automation-rules
+func (h *AutomationHandler) CreateProjectRule(c *gin.Context) {
+	h.createRule(c, c.Param("id"), "")
+}
+
+// ListTemplateRules 模板级规则列表（由该模板创建的项目生效）
+// GET /api/v1/templates/:id/automation-rules
+func (h *AutomationHandler) ListTemplateRules(c *gin.Context) {
+	h.listRules(c, "", c.Param("id"))
+}
+
+// CreateTemplateRule 创建模板级规则
+// POST /api/v1/templates/:id/automation-rules
+func (h *AutomationHandler) CreateTemplateRule(c *gin.Context) {
+	h.createRule(c, "", c.Param("id"))
+}
+
+func (h *AutomationHandler) listRules(c *gin.Context, projectID, templateID string) {
+	rules, err := h.svc.ListRules(c.Request.Context(), projectID, templateID, c.Query("rule_type"))
+	if err != nil {
+		InternalError(c, err.Error())
+		return
+	}
+	Success(c, gin.H{"items": rules})
+}
+
+func (h *AutomationHandler) createRule(c *gin.Context, projectID, templateID string) {
+	var req service.SaveAutomationRuleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		BadRequest(c, "参数错误: "+err.Error())
+		return
+	}
+	rule, err := h.svc.CreateRule(c.Request.Context(), projectID, templateID, &req, GetUserID(c))
+	if err != nil {
+		BadRequest(c, err.Error())
+		return
+	}
+	Created(c, rule)
+}
+
+// GetRule 规则详情
+// GET /api/v1/automation-rules/:id
+func (h *AutomationHandler) GetRule(c *gin.Context) {
+	rule, err := h.svc.GetRule(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		NotFound(c, err.Error())
+		return
+	}
+	Success(c, rule)
+}
+
+// UpdateRule 更新规则
+// PUT /api/v1/automation-rules/:id
+func (h *AutomationHandler) UpdateRule(c *gin.Context) {
+	var req service.SaveAutomationRuleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		BadRequest(c, "参数错误: "+err.Error())
+		return
+	}
+	rule, err := h.svc.UpdateRule(c.Request.Context(), c.Param("id"), &req)
+	if err != nil {
+		BadRequest(c, err.Error())
+		return
+	}
+	Success(c, rule)
+}
+
+// DeleteRule 删除规则
+// DELETE /api/v1/automation-rules/:id
+func (h *AutomationHandler) DeleteRule(c *gin.Context) {
+	if err := h.svc.DeleteRule(c.Request.Context(), c.Param("id")); err != nil {
+		InternalError(c, err.Error())
+		return
+	}
+	Success(c, nil)
+}
+
+// ListLogs 执行日志
+// GET /api/v1/automation-logs?project_id=&rule_id=&status=
+func (h *AutomationHandler) ListLogs(c *gin.Context) {
+	h.listLogs(c, c.Query("project_id"))
+}
+
+// ListProjectLogs 项目执行日志
+// GET /api/v1/projects/:id/automation-logs
+func (h *AutomationHandler) ListProjectLogs(c *gin.Context) {
+	h.listLogs(c, c.Param("id"))
+}
+
+func (h *AutomationHandler) listLogs(c *gin.Context, projectID string) {
+	page, pageSize := GetPagination(c)
+	logs, total, err := h.svc.ListLogs(c.Request.Context(), projectID, c.Query("rule_id"), c.Query("status"), page, pageSize)
+	if err != nil {
+		InternalError(c, err.Error())
+		return
+	}
+
+	totalPages := int(total) / pageSize
+	if int(total)%pageSize > 0 {
+		totalPages++
+	}
+
+	Success(c, ListResponse{
+		Items: logs,
+		Pagination: &Pagination{
+			Page:       page,
+			PageSize:   pageSize,
+			Total:      int(total),
+			TotalPages: totalPages,
+		},
+	})
+}
+
+// CheckOverdue 立即扫描逾期任务并执行 OVERDUE 规则
+// POST /api/v1/automation/check-overdue
+func (h *AutomationHandler) CheckOverdue(c *gin.Context) {
+	executed, err := h.svc.CheckOverdue(c.Request.Context())
+	if err != nil {
+		InternalError(c, err.Error())
+		return
+	}
+	Success(c, gin.H{"executed": executed})
+}
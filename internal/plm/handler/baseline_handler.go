@@ -0,0 +1,139 @@
+package handler
+
+import (
+	"errors"
+	"time"
+
+	"github.com/bitfantasy/nimo/internal/plm/entity"
+	"github.com/bitfantasy/nimo/internal/plm/service"
+	"github.com/gin-gonic/gin"
+)
+
+// BaselineHandler 进度基线 & 挣值分析处理器
+type BaselineHandler struct {
+	svc *service.BaselineService
+}
+
+// NewBaselineHandler 创建进度基线处理器
+func NewBaselineHandler(svc *service.BaselineService) *BaselineHandler {
+	return &BaselineHandler{svc: svc}
+}
+
+// ListBaselines 项目基线列表
+// GET /api/v1/projects/:id/baselines
+func (h *BaselineHandler) ListBaselines(c *gin.Context) {
+	baselines, err := h.svc.ListBaselines(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		InternalError(c, err.Error())
+		return
+	}
+	Success(c, gin.H{"items": baselines})
+}
+
+// CreateBaseline 保存当前计划为基线
+// POST /api/v1/projects/:id/baselines
+func (h *BaselineHandler) CreateBaseline(c *gin.Context) {
+	var req service.CreateBaselineRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		BadRequest(c, "参数错误: "+err.Error())
+		return
+	}
+	baseline, err := h.svc.CreateBaseline(c.Request.Context(), c.Param("id"), &req, entity.BaselineSourceManual, GetUserID(c))
+	if err != nil {
+		baselineError(c, err)
+		return
+	}
+	Created(c, baseline)
+}
+
+// GetBaseline 基线详情
+// GET /api/v1/projects/:id/baselines/:baselineId
+func (h *BaselineHandler) GetBaseline(c *gin.Context) {
+	baseline, err := h.svc.GetBaseline(c.Request.Context(), c.Param("id"), c.Param("baselineId"))
+	if err != nil {
+		NotFound(c, err.Error())
+		return
+	}
+	Success(c, baseline)
+}
+
+// ActivateBaseline 设为当前基线
+// POST /api/v1/projects/:id/baselines/:baselineId/activate
+func (h *BaselineHandler) ActivateBaseline(c *gin.Context) {
+	if err := h.svc.ActivateBaseline(c.Request.Context(), c.Param("id"), c.Param("baselineId")); err != nil {
+		baselineError(c, err)
+		return
+	}
+	Success(c, nil)
+}
+
+// DeleteBaseline 删除基线
+// DELETE /api/v1/projects/:id/baselines/:baselineId
+func (h *BaselineHandler) DeleteBaseline(c *gin.Context) {
+	if err := h.svc.DeleteBaseline(c.Request.Context(), c.Param("id"), c.Param("baselineId")); err != nil {
+		baselineError(c, err)
+		return
+	}
+	Success(c, nil)
+}
+
+// GetEarnedValue 挣值分析 / 基线偏差报告
+// GET /api/v1/projects/:id/earned-value?baseline_id=&status_date=2006-01-02
+func (h *BaselineHandler) GetEarnedValue(c *gin.Context) {
+	statusDate, ok := parseStatusDate(c)
+	if !ok {
+		return
+	}
+	report, err := h.svc.GetEarnedValue(c.Request.Context(), c.Param("id"), c.Query("baseline_id"), statusDate)
+	if err != nil {
+		baselineError(c, err)
+		return
+	}
+	Success(c, report)
+}
+
+// ExportEarnedValue 导出挣值分析Excel
+// GET /api/v1/projects/:id/earned-value/export?baseline_id=&status_date=2006-01-02
+func (h *BaselineHandler) ExportEarnedValue(c *gin.Context) {
+	statusDate, ok := parseStatusDate(c)
+	if !ok {
+		return
+	}
+	f, filename, err := h.svc.ExportEarnedValue(c.Request.Context(), c.Param("id"), c.Query("baseline_id"), statusDate)
+	if err != nil {
+		baselineError(c, err)
+		return
+	}
+	defer f.Close()
+
+	c.Header("Content-Type", "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet")
+	c.Header("Content-Disposition", "attachment; filename=\""+filename+"\"")
+	c.Header("Content-Transfer-Encoding", "binary")
+
+	if err := f.Write(c.Writer); err != nil {
+		InternalError(c, "write excel: "+err.Error())
+	}
+}
+
+// baselineError 项目或基线不存在返回 404，其余为服务端错误
+func baselineError(c *gin.Context, err error) {
+	if errors.Is(err, service.ErrProjectNotFound) || errors.Is(err, service.ErrBaselineNotFound) {
+		NotFound(c, err.Error())
+		return
+	}
+	InternalError(c, err.Error())
+}
+
+// parseStatusDate 解析 status_date 查询参数，为空时返回零值（服务端取今天）
+func parseStatusDate(c *gin.Context) (time.Time, bool) {
+	raw := c.Query("status_date")
+	if raw == "" {
+		return time.Time{}, true
+	}
+	t, err := time.Parse("2006-01-02", raw)
+	if err != nil {
+		BadRequest(c, "参数错误: status_date 格式应为 YYYY-MM-DD")
+		return time.Time{}, false
+	}
+	return t, true
+}
@@ -0,0 +1,219 @@
+package handler
+
+import (
+	"io"
+	"strconv"
+	"time"
+
+	"github.com/bitfantasy/nimo/internal/plm/service"
+	"github.com/gin-gonic/gin"
+)
+
+// CalendarHandler 工作日历处理器
+type CalendarHandler struct {
+	svc *service.CalendarService
+}
+
+// NewCalendarHandler 创建工作日历处理器
+func NewCalendarHandler(svc *service.CalendarService) *CalendarHandler {
+	return &CalendarHandler{svc: svc}
+}
+
+// maxHolidayFileSize 节假日文件大小上限
+const maxHolidayFileSize = 2 << 20
+
+// ListCalendars 日历列表
+// GET /api/v1/calendars
+func (h *CalendarHandler) ListCalendars(c *gin.Context) {
+	calendars, err := h.svc.ListCalendars(c.Request.Context())
+	if err != nil {
+		InternalError(c, err.Error())
+		return
+	}
+	Success(c, gin.H{"items": calendars})
+}
+
+// CreateCalendar 创建日历
+// POST /api/v1/calendars
+func (h *CalendarHandler) CreateCalendar(c *gin.Context) {
+	var req service.SaveCalendarRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		BadRequest(c, "参数错误: "+err.Error())
+		return
+	}
+	cal, err := h.svc.CreateCalendar(c.Request.Context(), &req, GetUserID(c))
+	if err != nil {
+		InternalError(c, err.Error())
+		return
+	}
+	Created(c, cal)
+}
+
+// GetCalendar 日历详情
+// GET /api/v1/calendars/:id
+func (h *CalendarHandler) GetCalendar(c *gin.Context) {
+	cal, err := h.svc.GetCalendar(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		NotFound(c, err.Error())
+		return
+	}
+	Success(c, cal)
+}
+
+// UpdateCalendar 更新日历
+// PUT /api/v1/calendars/:id
+func (h *CalendarHandler) UpdateCalendar(c *gin.Context) {
+	var req service.SaveCalendarRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		BadRequest(c, "参数错误: "+err.Error())
+		return
+	}
+	cal, err := h.svc.UpdateCalendar(c.Request.Context(), c.Param("id"), &req)
+	if err != nil {
+		InternalError(c, err.Error())
+		return
+	}
+	Success(c, cal)
+}
+
+// DeleteCalendar 删除日历
+// DELETE /api/v1/calendars/:id
+func (h *CalendarHandler) DeleteCalendar(c *gin.Context) {
+	if err := h.svc.DeleteCalendar(c.Request.Context(), c.Param("id")); err != nil {
+		InternalError(c, err.Error())
+		return
+	}
+	Success(c, nil)
+}
+
+// ListDays 日历例外日期（节假日/调休）
+// GET /api/v1/calendars/:id/days?year=2026
+func (h *CalendarHandler) ListDays(c *gin.Context) {
+	year, _ := strconv.Atoi(c.Query("year"))
+	days, err := h.svc.ListDays(c.Request.Context(), c.Param("id"), year)
+	if err != nil {
+		InternalError(c, err.Error())
+		return
+	}
+	Success(c, gin.H{"items": days})
+}
+
+// SetDays 批量设置例外日期
+// POST /api/v1/calendars/:id/days
+func (h *CalendarHandler) SetDays(c *gin.Context) {
+	var req struct {
+		Days []service.CalendarDayInput `json:"days" binding:"required,dive"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		BadRequest(c, "参数错误: "+err.Error())
+		return
+	}
+	n, err := h.svc.SetDays(c.Request.Context(), c.Param("id"), req.Days)
+	if err != nil {
+		InternalError(c, err.Error())
+		return
+	}
+	Success(c, gin.H{"saved": n})
+}
+
+// DeleteDay 删除例外日期
+// DELETE /api/v1/calendars/:id/days/:date
+func (h *CalendarHandler) DeleteDay(c *gin.Context) {
+	if err := h.svc.DeleteDay(c.Request.Context(), c.Param("id"), c.Param("date")); err != nil {
+		InternalError(c, err.Error())
+		return
+	}
+	Success(c, nil)
+}
+
+// ImportHolidays 导入节假日文件（.ics / .json）
+// POST /api/v1/calendars/:id/import (multipart: file)
+func (h *CalendarHandler) ImportHolidays(c *gin.Context) {
+	file, header, err := c.Request.FormFile("file")
+	if err != nil {
+		BadRequest(c, "请上传节假日文件")
+		return
+	}
+	defer file.Close()
+	if header.Size > maxHolidayFileSize {
+		BadRequest(c, "文件过大")
+		return
+	}
+	data, err := io.ReadAll(file)
+	if err != nil {
+		BadRequest(c, "读取文件失败: "+err.Error())
+		return
+	}
+	result, err := h.svc.ImportHolidays(c.Request.Context(), c.Param("id"), header.Filename, data)
+	if err != nil {
+		BadRequest(c, err.Error())
+		return
+	}
+	Success(c, result)
+}
+
+// ResolveDays 区间内每天是否工作日，可叠加个人请假/加班
+// GET /api/v1/calendars/:id/workdays?from=2026-10-01&to=2026-10-31&user_id=
+// :id 为 default 时使用默认日历
+func (h *CalendarHandler) ResolveDays(c *gin.Context) {
+	from, err1 := time.Parse("2006-01-02", c.Query("from"))
+	to, err2 := time.Parse("2006-01-02", c.Query("to"))
+	if err1 != nil || err2 != nil {
+		BadRequest(c, "参数错误: from/to 格式应为 YYYY-MM-DD")
+		return
+	}
+	calendarID := c.Param("id")
+	if calendarID == "default" {
+		calendarID = ""
+	}
+	days, err := h.svc.ResolveDays(c.Request.Context(), calendarID, c.Query("user_id"), from, to)
+	if err != nil {
+		BadRequest(c, err.Error())
+		return
+	}
+	Success(c, gin.H{"items": days})
+}
+
+// ListUserExceptions 个人日历例外
+// GET /api/v1/calendar-exceptions?user_id=&from=&to=
+func (h *CalendarHandler) ListUserExceptions(c *gin.Context) {
+	var from, to time.Time
+	if v := c.Query("from"); v != "" {
+		from, _ = time.Parse("2006-01-02", v)
+	}
+	if v := c.Query("to"); v != "" {
+		to, _ = time.Parse("2006-01-02", v)
+	}
+	list, err := h.svc.ListUserExceptions(c.Request.Context(), c.Query("user_id"), from, to)
+	if err != nil {
+		InternalError(c, err.Error())
+		return
+	}
+	Success(c, gin.H{"items": list})
+}
+
+// CreateUserException 登记请假/出差/加班
+// POST /api/v1/calendar-exceptions
+func (h *CalendarHandler) CreateUserException(c *gin.Context) {
+	var req service.CreateUserExceptionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		BadRequest(c, "参数错误: "+err.Error())
+		return
+	}
+	exc, err := h.svc.CreateUserException(c.Request.Context(), &req, GetUserID(c))
+	if err != nil {
+		BadRequest(c, err.Error())
+		return
+	}
+	Created(c, exc)
+}
+
+// DeleteUserException 删除个人日历例外
+// DELETE /api/v1/calendar-exceptions/:id
+func (h *CalendarHandler) DeleteUserException(c *gin.Context) {
+	if err := h.svc.DeleteUserException(c.Request.Context(), c.Param("id")); err != nil {
+		InternalError(c, err.Error())
+		return
+	}
+	Success(c, nil)
+}
@@ -0,0 +1,105 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/bitfantasy/nimo/internal/plm/service"
+	"github.com/gin-gonic/gin"
+)
+
+// ChecklistHandler 任务检查项处理器
+type ChecklistHandler struct {
+	svc *service.ChecklistService
+}
+
+// NewChecklistHandler 创建任务检查项处理器
+func NewChecklistHandler(svc *service.ChecklistService) *ChecklistHandler {
+	return &ChecklistHandler{svc: svc}
+}
+
+// ListItems 任务检查项列表
+// GET /api/v1/projects/:id/tasks/:taskId/checklist
+func (h *ChecklistHandler) ListItems(c *gin.Context) {
+	items, err := h.svc.ListTaskItems(c.Request.Context(), c.Param("taskId"))
+	if err != nil {
+		InternalError(c, err.Error())
+		return
+	}
+	Success(c, items)
+}
+
+// AddItem 添加检查项
+// POST /api/v1/projects/:id/tasks/:taskId/checklist
+func (h *ChecklistHandler) AddItem(c *gin.Context) {
+	var req service.SaveChecklistItemRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		BadRequest(c, "参数错误: "+err.Error())
+		return
+	}
+	item, err := h.svc.AddItem(c.Request.Context(), c.Param("id"), c.Param("taskId"), &req, GetUserID(c))
+	if err != nil {
+		BadRequest(c, err.Error())
+		return
+	}
+	Created(c, item)
+}
+
+// CheckItem 勾选/取消勾选检查项
+// PUT /api/v1/projects/:id/tasks/:taskId/checklist/:itemId/check
+func (h *ChecklistHandler) CheckItem(c *gin.Context) {
+	var req struct {
+		Checked bool   `json:"checked"`
+		Note    string `json:"note"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		BadRequest(c, "参数错误: "+err.Error())
+		return
+	}
+	item, err := h.svc.CheckItem(c.Request.Context(), c.Param("id"), c.Param("taskId"), c.Param("itemId"), GetUserID(c), req.Checked, req.Note)
+	if err != nil {
+		BadRequest(c, err.Error())
+		return
+	}
+	Success(c, item)
+}
+
+// DeleteItem 删除检查项（模板检查项仅项目经理或管理员）
+// DELETE /api/v1/projects/:id/tasks/:taskId/checklist/:itemId
+func (h *ChecklistHandler) DeleteItem(c *gin.Context) {
+	err := h.svc.DeleteItem(c.Request.Context(), c.Param("id"), c.Param("taskId"), c.Param("itemId"), GetUserID(c))
+	if errors.Is(err, service.ErrChecklistDeleteForbidden) {
+		Forbidden(c, err.Error())
+		return
+	}
+	if err != nil {
+		BadRequest(c, err.Error())
+		return
+	}
+	Success(c, nil)
+}
+
+// ProjectSummary 项目检查项完成情况
+// GET /api/v1/projects/:id/checklist-summary
+func (h *ChecklistHandler) ProjectSummary(c *gin.Context) {
+	summary, err := h.svc.ProjectSummary(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		InternalError(c, err.Error())
+		return
+	}
+	Success(c, summary)
+}
+
+// checklistIncomplete 必检项未完成时返回未勾选的检查项，便于前端提示
+func checklistIncomplete(c *gin.Context, err error) bool {
+	var openErr *service.ChecklistIncompleteError
+	if !errors.As(err, &openErr) {
+		return false
+	}
+	c.JSON(http.StatusBadRequest, Response{
+		Code:    40000,
+		Message: openErr.Error(),
+		Data:    openErr,
+	})
+	return true
+}
@@ -1,6 +1,9 @@
 package handler
 
 import (
+	"time"
+
+	"github.com/bitfantasy/nimo/internal/plm/entity"
 	"github.com/bitfantasy/nimo/internal/plm/repository"
 
 	"github.com/gin-gonic/gin"
@@ -68,6 +71,11 @@ func (h *DeliverableHandler) Update(c *gin.Context) {
 	}
 
 	if input.Status != "" {
+		// 自动登记只到已提交，审批通过由评审人在此确认并留痕
+		if input.Status == entity.DeliverableStatusApproved && d.Status != entity.DeliverableStatusApproved {
+			now, userID := time.Now(), GetUserID(c)
+			d.ApprovedAt, d.ApprovedBy = &now, &userID
+		}
 		d.Status = input.Status
 	}
 	if input.DocumentID != nil {
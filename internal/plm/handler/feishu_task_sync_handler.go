@@ -0,0 +1,76 @@
+package handler
+
+import (
+	"log"
+	"net/http"
+
+	"github.com/bitfantasy/nimo/internal/plm/service"
+	"github.com/bitfantasy/nimo/internal/shared/feishu"
+	"github.com/gin-gonic/gin"
+)
+
+// FeishuTaskSyncHandler 飞书任务同步处理器
+type FeishuTaskSyncHandler struct {
+	svc *service.FeishuTaskSyncService
+}
+
+// NewFeishuTaskSyncHandler 创建飞书任务同步处理器
+func NewFeishuTaskSyncHandler(svc *service.FeishuTaskSyncService) *FeishuTaskSyncHandler {
+	return &FeishuTaskSyncHandler{svc: svc}
+}
+
+// ListSyncs 同步记录列表
+// GET /api/v1/feishu-task-syncs?project_id=&sync_status=
+func (h *FeishuTaskSyncHandler) ListSyncs(c *gin.Context) {
+	page, pageSize := GetPagination(c)
+	records, total, err := h.svc.ListSyncs(c.Request.Context(), service.FeishuTaskSyncQuery{
+		ProjectID:  c.Query("project_id"),
+		SyncStatus: c.Query("sync_status"),
+	}, page, pageSize)
+	if err != nil {
+		InternalError(c, err.Error())
+		return
+	}
+
+	totalPages := int(total) / pageSize
+	if int(total)%pageSize > 0 {
+		totalPages++
+	}
+
+	Success(c, ListResponse{
+		Items: records,
+		Pagination: &Pagination{
+			Page:       page,
+			PageSize:   pageSize,
+			Total:      int(total),
+			TotalPages: totalPages,
+		},
+	})
+}
+
+// RetrySync 重试同步（以 PLM 任务为准覆盖飞书任务）
+// POST /api/v1/feishu-task-syncs/:id/retry
+func (h *FeishuTaskSyncHandler) RetrySync(c *gin.Context) {
+	record, err := h.svc.RetrySync(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		BadRequest(c, err.Error())
+		return
+	}
+	Success(c, record)
+}
+
+// HandleTaskEvent 处理飞书任务变更事件（由飞书事件订阅回调分发）
+// 处理失败记录在同步记录中，始终返回成功避免飞书重试
+func (h *FeishuTaskSyncHandler) HandleTaskEvent(c *gin.Context, body []byte) {
+	event, err := feishu.HandleTaskEvent(body)
+	if err != nil {
+		log.Printf("[Feishu Webhook] 解析任务事件失败: %v", err)
+		c.JSON(http.StatusOK, gin.H{"code": 0})
+		return
+	}
+
+	if err := h.svc.HandleTaskEvent(c.Request.Context(), event); err != nil {
+		log.Printf("[Feishu Webhook] 处理任务事件失败: task=%s, obj_type=%d, error=%v", event.TaskID, event.ObjType, err)
+	}
+	c.JSON(http.StatusOK, gin.H{"code": 0})
+}
@@ -53,6 +53,25 @@ type Handlers struct {
 	LangVariant *LangVariantHandler
 	// V18 BOM ECN
 	BOMECN      *BOMECNHandler
+	// 状态机引擎管理
+	StateEngine *StateEngineHandler
+	// 进度计划
+	Schedule    *ScheduleHandler
+	Baseline    *BaselineHandler
+	Calendar    *CalendarHandler
+	Workload    *WorkloadHandler
+	Automation  *AutomationHandler
+	Scheduler   *SchedulerHandler
+	// 评审会议
+	Review      *ReviewMeetingHandler
+	// 飞书任务同步
+	FeishuTaskSync *FeishuTaskSyncHandler
+	// 阶段门禁
+	PhaseGate   *PhaseGateHandler
+	// 模板包导入导出
+	TemplateBundle *TemplateBundleHandler
+	// 任务检查项
+	Checklist *ChecklistHandler
 }
 
 // NewHandlers 创建处理器集合
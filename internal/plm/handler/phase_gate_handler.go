@@ -0,0 +1,104 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/bitfantasy/nimo/internal/plm/service"
+	"github.com/gin-gonic/gin"
+)
+
+// PhaseGateHandler 阶段门禁处理器
+type PhaseGateHandler struct {
+	svc        *service.PhaseGateService
+	projectSvc *service.ProjectService
+}
+
+// NewPhaseGateHandler 创建阶段门禁处理器
+func NewPhaseGateHandler(svc *service.PhaseGateService, projectSvc *service.ProjectService) *PhaseGateHandler {
+	return &PhaseGateHandler{svc: svc, projectSvc: projectSvc}
+}
+
+// CheckGate 检查阶段门禁，逐项说明缺失内容
+// GET /api/v1/projects/:id/phases/:phaseId/gate
+func (h *PhaseGateHandler) CheckGate(c *gin.Context) {
+	result, err := h.svc.CheckGate(c.Request.Context(), c.Param("phaseId"))
+	if err != nil {
+		NotFound(c, err.Error())
+		return
+	}
+	Success(c, result)
+}
+
+// OverrideGate 填写理由强制放行并完成阶段（仅项目经理或管理员）
+// POST /api/v1/projects/:id/phases/:phaseId/gate/override
+func (h *PhaseGateHandler) OverrideGate(c *gin.Context) {
+	var req struct {
+		Justification string `json:"justification" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		BadRequest(c, "参数错误: "+err.Error())
+		return
+	}
+	phase, err := h.projectSvc.OverridePhaseGate(c.Request.Context(), c.Param("phaseId"), GetUserID(c), req.Justification)
+	if errors.Is(err, service.ErrOverrideForbidden) {
+		Forbidden(c, err.Error())
+		return
+	}
+	if err != nil {
+		BadRequest(c, err.Error())
+		return
+	}
+	Success(c, phase)
+}
+
+// ListTemplateGates 模板阶段门禁配置
+// GET /api/v1/templates/:id/phase-gates
+func (h *PhaseGateHandler) ListTemplateGates(c *gin.Context) {
+	gates, err := h.svc.ListTemplateGates(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		InternalError(c, err.Error())
+		return
+	}
+	Success(c, gates)
+}
+
+// SaveTemplateGate 保存模板阶段门禁
+// PUT /api/v1/templates/:id/phase-gates/:phase
+func (h *PhaseGateHandler) SaveTemplateGate(c *gin.Context) {
+	var req service.SaveTemplatePhaseGateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		BadRequest(c, "参数错误: "+err.Error())
+		return
+	}
+	gate, err := h.svc.SaveTemplateGate(c.Request.Context(), c.Param("id"), c.Param("phase"), &req)
+	if err != nil {
+		BadRequest(c, err.Error())
+		return
+	}
+	Success(c, gate)
+}
+
+// DeleteTemplateGate 删除模板阶段门禁
+// DELETE /api/v1/templates/:id/phase-gates/:phase
+func (h *PhaseGateHandler) DeleteTemplateGate(c *gin.Context) {
+	if err := h.svc.DeleteTemplateGate(c.Request.Context(), c.Param("id"), c.Param("phase")); err != nil {
+		BadRequest(c, err.Error())
+		return
+	}
+	Success(c, nil)
+}
+
+// phaseGateFailed 门禁未通过时返回检查结果，便于前端展示缺失项
+func phaseGateFailed(c *gin.Context, err error) bool {
+	var gateErr *service.PhaseGateError
+	if !errors.As(err, &gateErr) {
+		return false
+	}
+	c.JSON(http.StatusBadRequest, Response{
+		Code:    40000,
+		Message: gateErr.Error(),
+		Data:    gateErr.Result,
+	})
+	return true
+}
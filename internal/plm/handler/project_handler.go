@@ -183,6 +183,9 @@ func (h *ProjectHandler) UpdatePhaseStatus(c *gin.Context) {
 
 	phase, err := h.svc.UpdatePhaseStatus(c.Request.Context(), phaseID, req.Status)
 	if err != nil {
+		if phaseGateFailed(c, err) {
+			return
+		}
 		InternalError(c, err.Error())
 		return
 	}
@@ -326,6 +329,9 @@ func (h *ProjectHandler) UpdateTaskStatus(c *gin.Context) {
 
 	task, err := h.svc.UpdateTaskStatus(c.Request.Context(), id, req.Status)
 	if err != nil {
+		if checklistIncomplete(c, err) {
+			return
+		}
 		InternalError(c, err.Error())
 		return
 	}
@@ -429,7 +435,7 @@ func (h *ProjectHandler) AddTaskDependency(c *gin.Context) {
 	}
 
 	if req.DependencyType == "" {
-		req.DependencyType = "finish_to_start"
+		req.DependencyType = "FS"
 	}
 
 	dep, err := h.svc.AddTaskDependency(c.Request.Context(), taskID, req.DependsOnID, req.DependencyType, req.LagDays)
@@ -502,6 +508,9 @@ func (h *ProjectHandler) CompleteMyTask(c *gin.Context) {
 	c.ShouldBindJSON(&req)
 
 	if err := h.svc.CompleteMyTask(c.Request.Context(), taskID, userID, req.FormData); err != nil {
+		if formValidationFailed(c, err) || checklistIncomplete(c, err) {
+			return
+		}
 		InternalError(c, err.Error())
 		return
 	}
@@ -522,6 +531,9 @@ func (h *ProjectHandler) ConfirmTask(c *gin.Context) {
 	userID := GetUserID(c)
 
 	if err := h.svc.ConfirmTask(c.Request.Context(), projectID, taskID, userID); err != nil {
+		if checklistIncomplete(c, err) {
+			return
+		}
 		InternalError(c, err.Error())
 		return
 	}
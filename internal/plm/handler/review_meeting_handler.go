@@ -0,0 +1,163 @@
+package handler
+
+import (
+	"github.com/bitfantasy/nimo/internal/plm/entity"
+	"github.com/bitfantasy/nimo/internal/plm/service"
+	"github.com/gin-gonic/gin"
+)
+
+// ReviewMeetingHandler 评审会议处理器
+type ReviewMeetingHandler struct {
+	svc *service.ReviewMeetingService
+}
+
+// NewReviewMeetingHandler 创建评审会议处理器
+func NewReviewMeetingHandler(svc *service.ReviewMeetingService) *ReviewMeetingHandler {
+	return &ReviewMeetingHandler{svc: svc}
+}
+
+// ListMeetings 评审会议列表
+// GET /api/v1/review-meetings?project_id=&task_id=&phase_id=&bom_id=&ecn_id=&meeting_type=&status=
+func (h *ReviewMeetingHandler) ListMeetings(c *gin.Context) {
+	h.listMeetings(c, c.Query("project_id"))
+}
+
+// ListProjectMeetings 项目评审会议列表
+// GET /api/v1/projects/:id/review-meetings
+func (h *ReviewMeetingHandler) ListProjectMeetings(c *gin.Context) {
+	h.listMeetings(c, c.Param("id"))
+}
+
+func (h *ReviewMeetingHandler) listMeetings(c *gin.Context, projectID string) {
+	page, pageSize := GetPagination(c)
+	meetings, total, err := h.svc.ListMeetings(c.Request.Context(), service.ReviewMeetingQuery{
+		ProjectID:   projectID,
+		TaskID:      c.Query("task_id"),
+		PhaseID:     c.Query("phase_id"),
+		BOMID:       c.Query("bom_id"),
+		ECNID:       c.Query("ecn_id"),
+		MeetingType: c.Query("meeting_type"),
+		Status:      c.Query("status"),
+	}, page, pageSize)
+	if err != nil {
+		InternalError(c, err.Error())
+		return
+	}
+
+	totalPages := int(total) / pageSize
+	if int(total)%pageSize > 0 {
+		totalPages++
+	}
+
+	Success(c, ListResponse{
+		Items: meetings,
+		Pagination: &Pagination{
+			Page:       page,
+			PageSize:   pageSize,
+			Total:      int(total),
+			TotalPages: totalPages,
+		},
+	})
+}
+
+// CreateMeeting 创建评审会议（ECN 评审可不关联项目）
+// POST /api/v1/review-meetings
+func (h *ReviewMeetingHandler) CreateMeeting(c *gin.Context) {
+	h.createMeeting(c, "")
+}
+
+// CreateProjectMeeting 创建项目评审会议
+// POST /api/v1/projects/:id/review-meetings
+func (h *ReviewMeetingHandler) CreateProjectMeeting(c *gin.Context) {
+	h.createMeeting(c, c.Param("id"))
+}
+
+func (h *ReviewMeetingHandler) createMeeting(c *gin.Context, projectID string) {
+	var req service.SaveReviewMeetingRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		BadRequest(c, "参数错误: "+err.Error())
+		return
+	}
+	if projectID != "" {
+		req.ProjectID = projectID
+	}
+	meeting, err := h.svc.CreateMeeting(c.Request.Context(), &req, GetUserID(c))
+	if err != nil {
+		BadRequest(c, err.Error())
+		return
+	}
+	Created(c, meeting)
+}
+
+// GetMeeting 评审会议详情
+// GET /api/v1/review-meetings/:id
+func (h *ReviewMeetingHandler) GetMeeting(c *gin.Context) {
+	meeting, err := h.svc.GetMeeting(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		NotFound(c, err.Error())
+		return
+	}
+	Success(c, meeting)
+}
+
+// UpdateMeeting 修改评审会议（改期会同步飞书日历）
+// PUT /api/v1/review-meetings/:id
+func (h *ReviewMeetingHandler) UpdateMeeting(c *gin.Context) {
+	var req service.SaveReviewMeetingRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		BadRequest(c, "参数错误: "+err.Error())
+		return
+	}
+	meeting, err := h.svc.UpdateMeeting(c.Request.Context(), c.Param("id"), &req)
+	if err != nil {
+		BadRequest(c, err.Error())
+		return
+	}
+	Success(c, meeting)
+}
+
+// StartMeeting 开始评审会议
+// POST /api/v1/review-meetings/:id/start
+func (h *ReviewMeetingHandler) StartMeeting(c *gin.Context) {
+	h.respond(c, func() (*entity.ReviewMeeting, error) {
+		return h.svc.StartMeeting(c.Request.Context(), c.Param("id"))
+	})
+}
+
+// CancelMeeting 取消评审会议
+// POST /api/v1/review-meetings/:id/cancel
+func (h *ReviewMeetingHandler) CancelMeeting(c *gin.Context) {
+	h.respond(c, func() (*entity.ReviewMeeting, error) {
+		return h.svc.CancelMeeting(c.Request.Context(), c.Param("id"))
+	})
+}
+
+// ConcludeMeeting 记录评审结论，待办转为任务
+// POST /api/v1/review-meetings/:id/conclude
+func (h *ReviewMeetingHandler) ConcludeMeeting(c *gin.Context) {
+	var req service.ConcludeReviewMeetingRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		BadRequest(c, "参数错误: "+err.Error())
+		return
+	}
+	h.respond(c, func() (*entity.ReviewMeeting, error) {
+		return h.svc.ConcludeMeeting(c.Request.Context(), c.Param("id"), &req, GetUserID(c))
+	})
+}
+
+// ConvertActionItems 将评审待办转为任务
+// POST /api/v1/review-meetings/:id/convert-actions
+func (h *ReviewMeetingHandler) ConvertActionItems(c *gin.Context) {
+	h.respond(c, func() (*entity.ReviewMeeting, error) {
+		return h.svc.ConvertActionItems(c.Request.Context(), c.Param("id"), GetUserID(c))
+	})
+}
+
+func (h *ReviewMeetingHandler) respond(c *gin.Context, fn func() (*entity.ReviewMeeting, error)) {
+	meeting, err := fn()
+	if err != nil {
+		BadRequest(c, err.Error())
+		return
+	}
+	Success(c, meeting)
+}
@@ -1,8 +1,11 @@
 package handler
 
 import (
+	"errors"
+
 	"github.com/bitfantasy/nimo/internal/plm/entity"
 	"github.com/bitfantasy/nimo/internal/plm/service"
+	"github.com/bitfantasy/nimo/internal/shared/expr"
 	"github.com/gin-gonic/gin"
 )
 
@@ -112,6 +115,11 @@ func (h *RoutingHandler) CreateRule(c *gin.Context) {
 	}
 
 	if err := h.svc.CreateRule(c.Request.Context(), rule); err != nil {
+		var exprErr *expr.Error
+		if errors.As(err, &exprErr) {
+			BadRequest(c, err.Error())
+			return
+		}
 		InternalError(c, err.Error())
 		return
 	}
@@ -183,6 +191,11 @@ func (h *RoutingHandler) UpdateRule(c *gin.Context) {
 	}
 
 	if err := h.svc.UpdateRule(c.Request.Context(), id, updates); err != nil {
+		var exprErr *expr.Error
+		if errors.As(err, &exprErr) {
+			BadRequest(c, err.Error())
+			return
+		}
 		InternalError(c, err.Error())
 		return
 	}
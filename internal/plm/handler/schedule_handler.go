@@ -0,0 +1,41 @@
+package handler
+
+import (
+	"strconv"
+
+	"github.com/bitfantasy/nimo/internal/plm/service"
+	"github.com/gin-gonic/gin"
+)
+
+// ScheduleHandler 项目进度计划处理器
+type ScheduleHandler struct {
+	svc *service.ScheduleService
+}
+
+// NewScheduleHandler 创建进度计划处理器
+func NewScheduleHandler(svc *service.ScheduleService) *ScheduleHandler {
+	return &ScheduleHandler{svc: svc}
+}
+
+// GetSchedule 获取项目排程（甘特图：最早/最晚时间、时差、关键路径）
+// GET /api/v1/projects/:id/schedule
+func (h *ScheduleHandler) GetSchedule(c *gin.Context) {
+	result, err := h.svc.GetSchedule(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		InternalError(c, "计算项目排程失败: "+err.Error())
+		return
+	}
+	Success(c, result)
+}
+
+// Recalculate 重新排程，顺延/提前未开始任务的计划日期
+// POST /api/v1/projects/:id/schedule/recalculate?dry_run=true
+func (h *ScheduleHandler) Recalculate(c *gin.Context) {
+	dryRun, _ := strconv.ParseBool(c.DefaultQuery("dry_run", "false"))
+	result, err := h.svc.Reschedule(c.Request.Context(), c.Param("id"), dryRun)
+	if err != nil {
+		InternalError(c, "重新排程失败: "+err.Error())
+		return
+	}
+	Success(c, result)
+}
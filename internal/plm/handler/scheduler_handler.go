@@ -0,0 +1,70 @@
+package handler
+
+import (
+	"errors"
+
+	"github.com/bitfantasy/nimo/internal/plm/service"
+	"github.com/bitfantasy/nimo/internal/shared/cron"
+	"github.com/gin-gonic/gin"
+)
+
+// SchedulerHandler 定时任务处理器
+type SchedulerHandler struct {
+	svc *service.SchedulerService
+}
+
+// NewSchedulerHandler 创建定时任务处理器
+func NewSchedulerHandler(svc *service.SchedulerService) *SchedulerHandler {
+	return &SchedulerHandler{svc: svc}
+}
+
+// ListJobs 定时任务列表（含下次执行时间）
+// GET /api/v1/scheduler/jobs
+func (h *SchedulerHandler) ListJobs(c *gin.Context) {
+	Success(c, h.svc.ListJobs())
+}
+
+// RunJob 立即执行定时任务
+// POST /api/v1/scheduler/jobs/:name/run
+func (h *SchedulerHandler) RunJob(c *gin.Context) {
+	run, err := h.svc.RunJob(c.Request.Context(), c.Param("name"))
+	if err != nil {
+		if errors.Is(err, cron.ErrJobRunning) {
+			BadRequest(c, err.Error())
+			return
+		}
+		if errors.Is(err, cron.ErrJobNotFound) {
+			NotFound(c, err.Error())
+			return
+		}
+		InternalError(c, err.Error())
+		return
+	}
+	Success(c, run)
+}
+
+// ListRuns 执行记录
+// GET /api/v1/scheduler/runs?job=&status=
+func (h *SchedulerHandler) ListRuns(c *gin.Context) {
+	page, pageSize := GetPagination(c)
+	runs, total, err := h.svc.ListRuns(c.Request.Context(), c.Query("job"), c.Query("status"), page, pageSize)
+	if err != nil {
+		InternalError(c, err.Error())
+		return
+	}
+
+	totalPages := int(total) / pageSize
+	if int(total)%pageSize > 0 {
+		totalPages++
+	}
+
+	Success(c, ListResponse{
+		Items: runs,
+		Pagination: &Pagination{
+			Page:       page,
+			PageSize:   pageSize,
+			Total:      int(total),
+			TotalPages: totalPages,
+		},
+	})
+}
@@ -0,0 +1,258 @@
+package handler
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/bitfantasy/nimo/internal/shared/engine"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// StateEngineHandler 状态机引擎管理处理器
+type StateEngineHandler struct {
+	engine *engine.Engine
+}
+
+// NewStateEngineHandler 创建状态机引擎管理处理器
+func NewStateEngineHandler(eng *engine.Engine) *StateEngineHandler {
+	return &StateEngineHandler{engine: eng}
+}
+
+// ListOutbox 查询动作发件箱
+// GET /api/v1/admin/state-engine/outbox?status=dead&entity_type=plm_task
+func (h *StateEngineHandler) ListOutbox(c *gin.Context) {
+	page, pageSize := GetPagination(c)
+	status := c.Query("status")
+	entityType := c.Query("entity_type")
+
+	entries, total, err := h.engine.ListOutbox(status, entityType, page, pageSize)
+	if err != nil {
+		InternalError(c, err.Error())
+		return
+	}
+
+	totalPages := int(total) / pageSize
+	if int(total)%pageSize > 0 {
+		totalPages++
+	}
+
+	Success(c, ListResponse{
+		Items: entries,
+		Pagination: &Pagination{
+			Page:       page,
+			PageSize:   pageSize,
+			Total:      int(total),
+			TotalPages: totalPages,
+		},
+	})
+}
+
+// ReplayOutbox 重放死信动作
+// POST /api/v1/admin/state-engine/outbox/:id/replay
+func (h *StateEngineHandler) ReplayOutbox(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		BadRequest(c, "无效的动作ID")
+		return
+	}
+
+	if err := h.engine.ReplayOutbox(id); err != nil {
+		BadRequest(c, err.Error())
+		return
+	}
+
+	Success(c, gin.H{"message": "动作已重新排队"})
+}
+
+// ListMachineVersions 查询状态机的所有版本
+// GET /api/v1/admin/state-engine/machines/:name/versions
+func (h *StateEngineHandler) ListMachineVersions(c *gin.Context) {
+	versions, err := h.engine.ListMachineVersions(c.Param("name"))
+	if err != nil {
+		InternalError(c, err.Error())
+		return
+	}
+
+	Success(c, versions)
+}
+
+// MigrateEntitiesRequest 实体迁移请求
+type MigrateEntitiesRequest struct {
+	FromVersion int               `json:"from_version" binding:"required"`
+	ToVersion   int               `json:"to_version"` // 0 或不传表示最新版本
+	StateMap    map[string]string `json:"state_map"`
+	DryRun      bool              `json:"dry_run"`
+}
+
+// MigrateEntities 将旧版本上的实体迁移到新版本（dry_run 时只返回迁移报告）
+// POST /api/v1/admin/state-engine/machines/:name/migrate
+func (h *StateEngineHandler) MigrateEntities(c *gin.Context) {
+	var req MigrateEntitiesRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		BadRequest(c, "参数错误: "+err.Error())
+		return
+	}
+
+	report, err := h.engine.MigrateEntities(engine.MigrationPlan{
+		MachineName: c.Param("name"),
+		FromVersion: req.FromVersion,
+		ToVersion:   req.ToVersion,
+		StateMap:    req.StateMap,
+	}, req.DryRun)
+	if err != nil {
+		BadRequest(c, err.Error())
+		return
+	}
+
+	Success(c, report)
+}
+
+// GetMachineDiagram 导出状态机流程图
+// GET /api/v1/admin/state-engine/machines/:name/diagram?format=mermaid|dot&version=2
+func (h *StateEngineHandler) GetMachineDiagram(c *gin.Context) {
+	version, _ := strconv.Atoi(c.Query("version"))
+	def, err := h.engine.GetMachineVersion(c.Param("name"), version)
+	if err != nil {
+		NotFound(c, "状态机不存在")
+		return
+	}
+
+	format := c.DefaultQuery("format", engine.DiagramMermaid)
+	diagram, err := engine.RenderDiagram(def, format)
+	if err != nil {
+		BadRequest(c, err.Error())
+		return
+	}
+
+	Success(c, gin.H{
+		"name":    def.Name,
+		"version": def.Version,
+		"format":  format,
+		"diagram": diagram,
+	})
+}
+
+// AvailableEventsRequest 可用事件查询请求（POST 时可携带条件评估上下文）
+type AvailableEventsRequest struct {
+	EventData map[string]interface{} `json:"event_data"`
+}
+
+// AvailableEvents 查询实体当前状态下可触发的事件及每条规则的条件评估结果
+// GET  /api/v1/state-engine/entities/:entity_type/:entity_id/events
+// POST /api/v1/state-engine/entities/:entity_type/:entity_id/events  {"event_data": {...}}
+func (h *StateEngineHandler) AvailableEvents(c *gin.Context) {
+	entityID := engine.EntityUUID(c.Param("entity_id"))
+
+	var req AvailableEventsRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			BadRequest(c, "参数错误: "+err.Error())
+			return
+		}
+	}
+
+	result, err := h.engine.AvailableEvents(c.Param("entity_type"), entityID, req.EventData)
+	if err != nil {
+		BadRequest(c, err.Error())
+		return
+	}
+
+	Success(c, result)
+}
+
+// SimulateRequest 转换模拟请求
+type SimulateRequest struct {
+	EntityType string                   `json:"entity_type" binding:"required"`
+	EntityID   string                   `json:"entity_id"`  // 可选，从该实体当前状态开始
+	FromState  string                   `json:"from_state"` // 可选，指定起始状态
+	Events     []engine.SimulationEvent `json:"events" binding:"required,min=1"`
+}
+
+// Simulate 模拟执行一串事件（不落库），返回状态路径和将执行的动作
+// POST /api/v1/state-engine/simulate
+func (h *StateEngineHandler) Simulate(c *gin.Context) {
+	var req SimulateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		BadRequest(c, "参数错误: "+err.Error())
+		return
+	}
+
+	entityID := uuid.Nil
+	if req.EntityID != "" {
+		entityID = engine.EntityUUID(req.EntityID)
+	}
+
+	result, err := h.engine.Simulate(req.EntityType, entityID, req.FromState, req.Events)
+	if err != nil {
+		BadRequest(c, err.Error())
+		return
+	}
+
+	Success(c, result)
+}
+
+// GetTimeline 查询实体状态历史时间线（含各状态停留时长）
+// GET /api/v1/state-engine/entities/:entity_type/:entity_id/history
+func (h *StateEngineHandler) GetTimeline(c *gin.Context) {
+	entityID := engine.EntityUUID(c.Param("entity_id"))
+
+	timeline, err := h.engine.Timeline(c.Param("entity_type"), entityID, time.Now())
+	if err != nil {
+		InternalError(c, err.Error())
+		return
+	}
+
+	Success(c, timeline)
+}
+
+// GetStateAt 查询实体在指定时刻的状态
+// GET /api/v1/state-engine/entities/:entity_type/:entity_id/state?at=2026-03-01T12:00:00+08:00
+func (h *StateEngineHandler) GetStateAt(c *gin.Context) {
+	entityID := engine.EntityUUID(c.Param("entity_id"))
+
+	at := time.Now()
+	if s := c.Query("at"); s != "" {
+		var err error
+		if at, err = time.Parse(time.RFC3339, s); err != nil {
+			if at, err = time.ParseInLocation("2006-01-02", s, time.Local); err != nil {
+				BadRequest(c, "无效的时间，格式为 RFC3339 或 YYYY-MM-DD")
+				return
+			}
+			// 仅日期时取当天结束时的状态
+			at = at.Add(24*time.Hour - time.Nanosecond)
+		}
+	}
+
+	snapshot, err := h.engine.StateAt(c.Param("entity_type"), entityID, at)
+	if err != nil {
+		InternalError(c, err.Error())
+		return
+	}
+
+	Success(c, snapshot)
+}
+
+// RebuildEntityStatesRequest 状态重建请求
+type RebuildEntityStatesRequest struct {
+	EntityType string `json:"entity_type"` // 为空时处理全部实体类型
+	DryRun     bool   `json:"dry_run"`
+}
+
+// RebuildEntityStates 按转换日志重建实体状态并报告差异
+// POST /api/v1/admin/state-engine/rebuild
+func (h *StateEngineHandler) RebuildEntityStates(c *gin.Context) {
+	var req RebuildEntityStatesRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		BadRequest(c, "参数错误: "+err.Error())
+		return
+	}
+
+	report, err := h.engine.RebuildEntityStates(req.EntityType, req.DryRun)
+	if err != nil {
+		InternalError(c, err.Error())
+		return
+	}
+
+	Success(c, report)
+}
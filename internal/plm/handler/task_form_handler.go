@@ -3,11 +3,15 @@ package handler
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"log"
+	"net/http"
+	"strconv"
 	"time"
 
 	"github.com/bitfantasy/nimo/internal/plm/entity"
 	"github.com/bitfantasy/nimo/internal/plm/repository"
+	"github.com/bitfantasy/nimo/internal/shared/formschema"
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 )
@@ -91,6 +95,10 @@ func (h *TaskFormHandler) UpsertTaskForm(c *gin.Context) {
 		BadRequest(c, "Invalid request body: "+err.Error())
 		return
 	}
+	if _, err := formschema.ParseAndCheck(req.Fields); err != nil {
+		BadRequest(c, err.Error())
+		return
+	}
 
 	ctx := c.Request.Context()
 	now := time.Now()
@@ -190,6 +198,10 @@ func (h *TaskFormHandler) SaveTemplateTaskForm(c *gin.Context) {
 		BadRequest(c, "task_code is required")
 		return
 	}
+	if _, err := formschema.ParseAndCheck(req.Fields); err != nil {
+		BadRequest(c, err.Error())
+		return
+	}
 
 	now := time.Now()
 	name := req.Name
@@ -247,21 +259,24 @@ func (h *TaskFormHandler) SaveFormDraft(c *gin.Context) {
 		return
 	}
 
-	submission := &entity.TaskFormSubmission{
-		ID:          uuid.New().String()[:32],
-		FormID:      form.ID,
-		TaskID:      taskID,
-		Data:        entity.JSONB(req.FormData),
-		SubmittedBy: userID,
-		SubmittedAt: time.Now(),
+	// 草稿只保存不校验，提交时再按表单定义校验
+	now := time.Now()
+	draft := &entity.TaskFormDraft{
+		ID:        uuid.New().String()[:32],
+		FormID:    form.ID,
+		TaskID:    taskID,
+		UserID:    userID,
+		Data:      entity.JSONB(req.FormData),
+		CreatedAt: now,
+		UpdatedAt: now,
 	}
 
-	if err := h.formRepo.UpsertDraftSubmission(ctx, submission); err != nil {
+	if err := h.formRepo.UpsertDraft(ctx, draft); err != nil {
 		InternalError(c, "保存草稿失败: "+err.Error())
 		return
 	}
 
-	Success(c, gin.H{"message": "草稿已保存"})
+	Success(c, gin.H{"message": "草稿已保存", "updated_at": draft.UpdatedAt})
 }
 
 // GetFormDraft 获取表单草稿
@@ -273,13 +288,107 @@ func (h *TaskFormHandler) GetFormDraft(c *gin.Context) {
 		return
 	}
 
-	submission, err := h.formRepo.FindDraftSubmission(c.Request.Context(), taskID)
+	draft, err := h.formRepo.FindDraft(c.Request.Context(), taskID, GetUserID(c))
 	if err != nil {
 		InternalError(c, "查询草稿失败: "+err.Error())
 		return
 	}
 
-	Success(c, submission)
+	Success(c, draft)
+}
+
+// DeleteFormDraft 丢弃表单草稿
+// DELETE /my/tasks/:taskId/form-draft
+func (h *TaskFormHandler) DeleteFormDraft(c *gin.Context) {
+	taskID := c.Param("taskId")
+	if taskID == "" {
+		BadRequest(c, "Task ID is required")
+		return
+	}
+
+	if err := h.formRepo.DeleteDraft(c.Request.Context(), taskID, GetUserID(c)); err != nil {
+		InternalError(c, "删除草稿失败: "+err.Error())
+		return
+	}
+
+	Success(c, gin.H{"message": "草稿已删除"})
+}
+
+// ListFormSubmissions 表单提交历史（含驳回意见）
+// GET /projects/:id/tasks/:taskId/form/submissions
+func (h *TaskFormHandler) ListFormSubmissions(c *gin.Context) {
+	submissions, err := h.formRepo.ListSubmissions(c.Request.Context(), c.Param("taskId"))
+	if err != nil {
+		InternalError(c, "查询表单提交失败: "+err.Error())
+		return
+	}
+	Success(c, submissions)
+}
+
+// DiffFormSubmissions 对比两个提交版本的字段差异
+// GET /projects/:id/tasks/:taskId/form/submissions/diff?from=1&to=2
+// 省略 to 时取最新版本，省略 from 时取 to 的上一版本
+func (h *TaskFormHandler) DiffFormSubmissions(c *gin.Context) {
+	ctx := c.Request.Context()
+	taskID := c.Param("taskId")
+
+	toVersion, err := strconv.Atoi(c.DefaultQuery("to", "0"))
+	if err != nil {
+		BadRequest(c, "to 必须是版本号")
+		return
+	}
+	if toVersion == 0 {
+		latest, err := h.formRepo.FindLatestSubmission(ctx, taskID)
+		if err != nil {
+			InternalError(c, "查询表单提交失败: "+err.Error())
+			return
+		}
+		if latest == nil || latest.Version == 0 {
+			NotFound(c, "该任务还没有表单提交")
+			return
+		}
+		toVersion = latest.Version
+	}
+	fromVersion, err := strconv.Atoi(c.DefaultQuery("from", strconv.Itoa(toVersion-1)))
+	if err != nil {
+		BadRequest(c, "from 必须是版本号")
+		return
+	}
+	if fromVersion < 1 {
+		BadRequest(c, "没有可对比的上一版本")
+		return
+	}
+	if fromVersion == toVersion {
+		BadRequest(c, "请指定两个不同的提交版本")
+		return
+	}
+
+	from, err := h.formRepo.FindSubmissionByVersion(ctx, taskID, fromVersion)
+	if err != nil {
+		InternalError(c, "查询表单提交失败: "+err.Error())
+		return
+	}
+	to, err := h.formRepo.FindSubmissionByVersion(ctx, taskID, toVersion)
+	if err != nil {
+		InternalError(c, "查询表单提交失败: "+err.Error())
+		return
+	}
+	if from == nil || to == nil {
+		NotFound(c, "提交版本不存在")
+		return
+	}
+
+	// 表单定义仅用于字段名称和附件识别，解析失败时按键名对比
+	var schema formschema.Schema
+	if form, _ := h.formRepo.FindByTaskID(ctx, taskID); form != nil {
+		schema, _ = formschema.Parse(form.Fields)
+	}
+
+	Success(c, gin.H{
+		"from":    from,
+		"to":      to,
+		"changes": schema.Diff(from.Data, to.Data),
+	})
 }
 
 // GetFormSubmission 获取最新表单提交
@@ -313,6 +422,25 @@ func (h *TaskFormHandler) GetFormSubmission(c *gin.Context) {
 		return
 	}
 
+	// 系统控件数据单独存放在 version=0 记录中，叠加到用户最新提交上返回（不回写）
+	if submission.Version > 0 {
+		system, err := h.formRepo.FindSystemSubmission(c.Request.Context(), taskID)
+		if err != nil {
+			InternalError(c, "查询表单提交失败: "+err.Error())
+			return
+		}
+		if system != nil && len(system.Data) > 0 {
+			merged := make(entity.JSONB, len(submission.Data)+len(system.Data))
+			for k, v := range submission.Data {
+				merged[k] = v
+			}
+			for k, v := range system.Data {
+				merged[k] = v
+			}
+			submission.Data = merged
+		}
+	}
+
 	Success(c, submission)
 }
 
@@ -332,3 +460,17 @@ func (h *TaskFormHandler) hasProcurementControlField(formDef *entity.TaskForm) b
 	}
 	return false
 }
+
+// formValidationFailed 表单校验未通过时返回字段级错误，便于前端逐项提示
+func formValidationFailed(c *gin.Context, err error) bool {
+	var verr *formschema.ValidationError
+	if !errors.As(err, &verr) {
+		return false
+	}
+	c.JSON(http.StatusBadRequest, Response{
+		Code:    40000,
+		Message: verr.Error(),
+		Data:    verr,
+	})
+	return true
+}
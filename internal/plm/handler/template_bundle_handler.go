@@ -0,0 +1,116 @@
+package handler
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/bitfantasy/nimo/internal/plm/service"
+	"github.com/gin-gonic/gin"
+)
+
+// maxTemplateBundleSize 模板包大小上限
+const maxTemplateBundleSize = 10 << 20
+
+// TemplateBundleHandler 模板包导入导出处理器
+type TemplateBundleHandler struct {
+	svc *service.TemplateBundleService
+}
+
+// NewTemplateBundleHandler 创建模板包处理器
+func NewTemplateBundleHandler(svc *service.TemplateBundleService) *TemplateBundleHandler {
+	return &TemplateBundleHandler{svc: svc}
+}
+
+// Export 导出模板包
+// GET /api/v1/templates/:id/export?format=json|yaml
+func (h *TemplateBundleHandler) Export(c *gin.Context) {
+	format := strings.ToLower(c.DefaultQuery("format", "json"))
+	if format == "yml" {
+		format = "yaml"
+	}
+	if format != "json" && format != "yaml" {
+		BadRequest(c, "不支持的导出格式: "+format)
+		return
+	}
+
+	bundle, err := h.svc.ExportBundle(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		NotFound(c, err.Error())
+		return
+	}
+	data, err := service.MarshalBundle(bundle, format)
+	if err != nil {
+		InternalError(c, err.Error())
+		return
+	}
+
+	contentType := "application/json"
+	if format == "yaml" {
+		contentType = "application/yaml"
+	}
+	filename := fmt.Sprintf("%s-v%s.%s", bundle.Template.Code, bundle.Template.Version, format)
+	c.Header("Content-Disposition", "attachment; filename=\""+filename+"\"")
+	c.Data(200, contentType, data)
+}
+
+// Import 导入模板包（JSON/YAML，请求体或 multipart 文件字段 file）
+// POST /api/v1/templates/import?on_conflict=rename|version|fail&code=&create_missing_roles=true
+func (h *TemplateBundleHandler) Import(c *gin.Context) {
+	var reader io.Reader = c.Request.Body
+	if strings.HasPrefix(c.ContentType(), "multipart/") {
+		file, err := c.FormFile("file")
+		if err != nil {
+			BadRequest(c, "请上传模板包文件")
+			return
+		}
+		f, err := file.Open()
+		if err != nil {
+			BadRequest(c, "读取文件失败: "+err.Error())
+			return
+		}
+		defer f.Close()
+		reader = f
+	}
+	data, err := io.ReadAll(io.LimitReader(reader, maxTemplateBundleSize+1))
+	if err != nil {
+		BadRequest(c, "读取模板包失败: "+err.Error())
+		return
+	}
+	if len(data) > maxTemplateBundleSize {
+		BadRequest(c, "模板包超过 10MB")
+		return
+	}
+
+	bundle, err := service.ParseBundle(data)
+	if err != nil {
+		BadRequest(c, err.Error())
+		return
+	}
+	result, err := h.svc.ImportBundle(c.Request.Context(), bundle, service.ImportBundleOptions{
+		OnConflict:         c.Query("on_conflict"),
+		Code:               c.Query("code"),
+		CreateMissingRoles: c.Query("create_missing_roles") == "true",
+	}, GetUserID(c))
+	if err != nil {
+		BadRequest(c, err.Error())
+		return
+	}
+	Created(c, result)
+}
+
+// Diff 对比两个模板版本
+// GET /api/v1/templates/:id/diff?to=<模板ID>（:id 为旧版本）
+func (h *TemplateBundleHandler) Diff(c *gin.Context) {
+	to := c.Query("to")
+	if to == "" {
+		BadRequest(c, "请指定对比的模板版本 to")
+		return
+	}
+	diff, err := h.svc.DiffTemplates(c.Request.Context(), c.Param("id"), to)
+	if err != nil {
+		NotFound(c, err.Error())
+		return
+	}
+	Success(c, diff)
+}
@@ -1,56 +1,17 @@
 package handler
 
 import (
+	"encoding/json"
 	"fmt"
-	"strconv"
-	"strings"
 	"time"
 
 	"github.com/bitfantasy/nimo/internal/plm/entity"
 	"github.com/bitfantasy/nimo/internal/plm/service"
+	"github.com/bitfantasy/nimo/internal/shared/expr"
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 )
 
-// isVersionGreater 比较版本号 a > b（格式: "1.0", "2.1" 等）
-func isVersionGreater(a, b string) bool {
-	parseVer := func(v string) (int, int) {
-		v = strings.TrimPrefix(v, "v")
-		v = strings.TrimPrefix(v, "V")
-		parts := strings.SplitN(v, ".", 2)
-		major, _ := strconv.Atoi(parts[0])
-		minor := 0
-		if len(parts) > 1 {
-			minor, _ = strconv.Atoi(parts[1])
-		}
-		return major, minor
-	}
-	aMajor, aMinor := parseVer(a)
-	bMajor, bMinor := parseVer(b)
-	if aMajor != bMajor {
-		return aMajor > bMajor
-	}
-	return aMinor > bMinor
-}
-
-// nextVersion 自动递增版本号
-func nextVersion(current string) string {
-	current = strings.TrimPrefix(current, "v")
-	current = strings.TrimPrefix(current, "V")
-	parts := strings.SplitN(current, ".", 2)
-	major, _ := strconv.Atoi(parts[0])
-	minor := 0
-	if len(parts) > 1 {
-		minor, _ = strconv.Atoi(parts[1])
-	}
-	minor++
-	if minor >= 10 {
-		major++
-		minor = 0
-	}
-	return fmt.Sprintf("%d.%d", major, minor)
-}
-
 // TemplateHandler 模板处理器
 type TemplateHandler struct {
 	svc *service.TemplateService
@@ -177,7 +138,7 @@ func (h *TemplateHandler) Update(c *gin.Context) {
 	}
 	if req.Version != "" {
 		// 版本号必须比当前版本大
-		if !isVersionGreater(req.Version, template.Version) {
+		if !service.IsTemplateVersionGreater(req.Version, template.Version) {
 			BadRequest(c, "新版本号必须大于当前版本 "+template.Version)
 			return
 		}
@@ -243,21 +204,23 @@ func (h *TemplateHandler) Duplicate(c *gin.Context) {
 
 // CreateTemplateTaskRequest 创建模板任务请求
 type CreateTemplateTaskRequest struct {
-	TaskCode             string   `json:"task_code" binding:"required"`
-	Name                 string   `json:"name" binding:"required"`
-	Description          string   `json:"description"`
-	Phase                string   `json:"phase" binding:"required"`
-	ParentTaskCode       string   `json:"parent_task_code"`
-	TaskType             string   `json:"task_type"`
-	DefaultAssigneeRole  string   `json:"default_assignee_role"`
-	EstimatedDays        int      `json:"estimated_days"`
-	IsCritical           bool     `json:"is_critical"`
-	RequiresApproval     bool     `json:"requires_approval"`
-	ApprovalType         string   `json:"approval_type"`
-	AutoCreateFeishuTask bool     `json:"auto_create_feishu_task"`
-	FeishuApprovalCode   string   `json:"feishu_approval_code"`
-	SortOrder            int      `json:"sort_order"`
-	DependsOn            []string `json:"depends_on"` // 前置任务 task_code 列表
+	TaskCode             string          `json:"task_code" binding:"required"`
+	Name                 string          `json:"name" binding:"required"`
+	Description          string          `json:"description"`
+	Phase                string          `json:"phase" binding:"required"`
+	ParentTaskCode       string          `json:"parent_task_code"`
+	TaskType             string          `json:"task_type"`
+	DefaultAssigneeRole  string          `json:"default_assignee_role"`
+	EstimatedDays        int             `json:"estimated_days"`
+	IsCritical           bool            `json:"is_critical"`
+	RequiresApproval     bool            `json:"requires_approval"`
+	ApprovalType         string          `json:"approval_type"`
+	AutoCreateFeishuTask bool            `json:"auto_create_feishu_task"`
+	FeishuApprovalCode   string          `json:"feishu_approval_code"`
+	SortOrder            int             `json:"sort_order"`
+	DependsOn            []string        `json:"depends_on"`          // 前置任务 task_code 列表
+	InclusionCondition   json.RawMessage `json:"inclusion_condition"` // 纳入条件（按项目属性求值），为空则始终纳入
+	Checklist            json.RawMessage `json:"checklist"`           // 检查项，见 service.ParseChecklistDefs
 }
 
 // CreateTask 创建模板任务
@@ -269,6 +232,14 @@ func (h *TemplateHandler) CreateTask(c *gin.Context) {
 		BadRequest(c, "Invalid request body")
 		return
 	}
+	if err := expr.ValidateCondition(req.InclusionCondition); err != nil {
+		BadRequest(c, "纳入条件无效: "+err.Error())
+		return
+	}
+	if _, err := service.ParseChecklistDefs(req.Checklist); err != nil {
+		BadRequest(c, "检查项无效: "+err.Error())
+		return
+	}
 
 	task := &entity.TemplateTask{
 		TemplateID:           templateID,
@@ -285,6 +256,8 @@ func (h *TemplateHandler) CreateTask(c *gin.Context) {
 		ApprovalType:         req.ApprovalType,
 		AutoCreateFeishuTask: req.AutoCreateFeishuTask,
 		FeishuApprovalCode:   req.FeishuApprovalCode,
+		InclusionCondition:   req.InclusionCondition,
+		Checklist:            req.Checklist,
 		SortOrder:            req.SortOrder,
 	}
 
@@ -313,6 +286,14 @@ func (h *TemplateHandler) UpdateTask(c *gin.Context) {
 		BadRequest(c, "Invalid request body")
 		return
 	}
+	if err := expr.ValidateCondition(req.InclusionCondition); err != nil {
+		BadRequest(c, "纳入条件无效: "+err.Error())
+		return
+	}
+	if _, err := service.ParseChecklistDefs(req.Checklist); err != nil {
+		BadRequest(c, "检查项无效: "+err.Error())
+		return
+	}
 
 	task := &entity.TemplateTask{
 		TemplateID:           templateID,
@@ -329,6 +310,8 @@ func (h *TemplateHandler) UpdateTask(c *gin.Context) {
 		ApprovalType:         req.ApprovalType,
 		AutoCreateFeishuTask: req.AutoCreateFeishuTask,
 		FeishuApprovalCode:   req.FeishuApprovalCode,
+		InclusionCondition:   req.InclusionCondition,
+		Checklist:            req.Checklist,
 		SortOrder:            req.SortOrder,
 	}
 
@@ -362,7 +345,10 @@ type CreateProjectFromTemplateRequest struct {
 	StartDate       string            `json:"start_date" binding:"required"`
 	PMID            string            `json:"pm_user_id" binding:"required"`
 	SkipWeekends    bool              `json:"skip_weekends"`
+	CalendarID      string            `json:"calendar_id"`
 	RoleAssignments map[string]string `json:"role_assignments"`
+	// Attributes 项目属性（product_type/target_markets/has_battery/new_tooling 等），用于求值任务纳入条件
+	Attributes map[string]interface{} `json:"attributes"`
 }
 
 // CreateProjectFromTemplate 从模板创建项目
@@ -389,7 +375,9 @@ func (h *TemplateHandler) CreateProjectFromTemplate(c *gin.Context) {
 		StartDate:       startDate,
 		PMID:            req.PMID,
 		SkipWeekends:    req.SkipWeekends,
+		CalendarID:      req.CalendarID,
 		RoleAssignments: req.RoleAssignments,
+		Attributes:      req.Attributes,
 	}
 
 	project, err := h.svc.CreateProjectFromTemplate(c.Request.Context(), input, userID)
@@ -401,6 +389,45 @@ func (h *TemplateHandler) CreateProjectFromTemplate(c *gin.Context) {
 	Created(c, project)
 }
 
+// PreviewProjectRequest 预览从模板创建项目请求
+type PreviewProjectRequest struct {
+	StartDate    string                 `json:"start_date"`
+	SkipWeekends bool                   `json:"skip_weekends"`
+	CalendarID   string                 `json:"calendar_id"`
+	Attributes   map[string]interface{} `json:"attributes"`
+}
+
+// PreviewProject 按项目属性预览模板将生成的任务
+// POST /api/v1/templates/:id/preview
+func (h *TemplateHandler) PreviewProject(c *gin.Context) {
+	var req PreviewProjectRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		BadRequest(c, "参数错误: "+err.Error())
+		return
+	}
+
+	input := &service.PreviewProjectInput{
+		SkipWeekends: req.SkipWeekends,
+		CalendarID:   req.CalendarID,
+		Attributes:   req.Attributes,
+	}
+	if req.StartDate != "" {
+		startDate, err := time.Parse("2006-01-02", req.StartDate)
+		if err != nil {
+			BadRequest(c, "Invalid start_date format, use YYYY-MM-DD")
+			return
+		}
+		input.StartDate = startDate
+	}
+
+	preview, err := h.svc.PreviewProjectFromTemplate(c.Request.Context(), c.Param("id"), input)
+	if err != nil {
+		BadRequest(c, err.Error())
+		return
+	}
+	Success(c, preview)
+}
+
 // BatchSaveTasksRequest 批量保存任务请求
 type BatchSaveTasksRequest struct {
 	Tasks   []CreateTemplateTaskRequest `json:"tasks" binding:"required"`
@@ -432,7 +459,7 @@ func (h *TemplateHandler) BatchSaveTasks(c *gin.Context) {
 
 	// 如果指定了版本号，验证
 	if req.Version != "" {
-		if !isVersionGreater(req.Version, template.Version) {
+		if !service.IsTemplateVersionGreater(req.Version, template.Version) {
 			BadRequest(c, fmt.Sprintf("版本号 %s 必须大于当前版本 %s", req.Version, template.Version))
 			return
 		}
@@ -441,6 +468,14 @@ func (h *TemplateHandler) BatchSaveTasks(c *gin.Context) {
 	// 转换为entity
 	var tasks []entity.TemplateTask
 	for i, t := range req.Tasks {
+		if err := expr.ValidateCondition(t.InclusionCondition); err != nil {
+			BadRequest(c, fmt.Sprintf("任务 %s 的纳入条件无效: %s", t.TaskCode, err.Error()))
+			return
+		}
+		if _, err := service.ParseChecklistDefs(t.Checklist); err != nil {
+			BadRequest(c, fmt.Sprintf("任务 %s 的检查项无效: %s", t.TaskCode, err.Error()))
+			return
+		}
 		task := entity.TemplateTask{
 			ID:                   uuid.New().String(),
 			TemplateID:           templateID,
@@ -457,6 +492,8 @@ func (h *TemplateHandler) BatchSaveTasks(c *gin.Context) {
 			ApprovalType:         t.ApprovalType,
 			AutoCreateFeishuTask: t.AutoCreateFeishuTask,
 			FeishuApprovalCode:   t.FeishuApprovalCode,
+			InclusionCondition:   t.InclusionCondition,
+			Checklist:            t.Checklist,
 			SortOrder:            i,
 		}
 		if task.TaskType == "" {
@@ -494,7 +531,7 @@ func (h *TemplateHandler) BatchSaveTasks(c *gin.Context) {
 		template.Version = req.Version
 	} else if template.Status != "draft" {
 		// 非草稿状态自动递增版本
-		template.Version = nextVersion(template.Version)
+		template.Version = service.NextTemplateVersion(template.Version)
 	}
 	// 草稿状态且未指定版本号时，仅更新预估工期，不变更版本
 	template.EstimatedDays = calcEstimatedDays(tasks)
@@ -587,11 +624,11 @@ func (h *TemplateHandler) UpgradeVersion(c *gin.Context) {
 	userID := GetUserID(c)
 	newVersion := req.Version
 	if newVersion == "" {
-		newVersion = nextVersion(template.Version)
+		newVersion = service.NextTemplateVersion(template.Version)
 	}
 
 	// 版本号校验
-	if !isVersionGreater(newVersion, template.Version) {
+	if !service.IsTemplateVersionGreater(newVersion, template.Version) {
 		BadRequest(c, fmt.Sprintf("新版本号 %s 必须大于当前版本 %s", newVersion, template.Version))
 		return
 	}
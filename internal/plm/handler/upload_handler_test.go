@@ -16,6 +16,8 @@ import (
 
 func setupUploadTest(t *testing.T) *gin.Engine {
 	t.Helper()
+	// 上传写入相对路径 ./uploads，切到临时目录避免在源码目录留下测试文件
+	t.Chdir(t.TempDir())
 	router := testutil.SetupRouter()
 	handler := NewUploadHandler()
 	api := testutil.AuthGroup(router, "/api/v1")
@@ -76,6 +76,9 @@ func (h *WorkflowHandler) CompleteTask(c *gin.Context) {
 	operatorID := GetUserID(c)
 
 	if err := h.svc.CompleteTask(c.Request.Context(), projectID, taskID, operatorID); err != nil {
+		if checklistIncomplete(c, err) {
+			return
+		}
 		InternalError(c, err.Error())
 		return
 	}
@@ -0,0 +1,64 @@
+package handler
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/bitfantasy/nimo/internal/plm/service"
+	"github.com/gin-gonic/gin"
+)
+
+// WorkloadHandler 资源负荷处理器
+type WorkloadHandler struct {
+	svc *service.WorkloadService
+}
+
+// NewWorkloadHandler 创建资源负荷处理器
+func NewWorkloadHandler(svc *service.WorkloadService) *WorkloadHandler {
+	return &WorkloadHandler{svc: svc}
+}
+
+// defaultWorkloadWeeks 未指定结束日期时统计的周数
+const defaultWorkloadWeeks = 8
+
+// GetWorkload 跨项目资源负荷
+// GET /api/v1/workload?from=2026-10-12&to=2026-12-06&user_id=&role=&weekly_capacity=40
+func (h *WorkloadHandler) GetWorkload(c *gin.Context) {
+	q := service.WorkloadQuery{
+		From:     time.Now(),
+		UserID:   c.Query("user_id"),
+		RoleCode: c.Query("role"),
+	}
+	if v := c.Query("from"); v != "" {
+		t, err := time.Parse("2006-01-02", v)
+		if err != nil {
+			BadRequest(c, "参数错误: from 格式应为 YYYY-MM-DD")
+			return
+		}
+		q.From = t
+	}
+	q.To = q.From.AddDate(0, 0, defaultWorkloadWeeks*7-1)
+	if v := c.Query("to"); v != "" {
+		t, err := time.Parse("2006-01-02", v)
+		if err != nil {
+			BadRequest(c, "参数错误: to 格式应为 YYYY-MM-DD")
+			return
+		}
+		q.To = t
+	}
+	if v := c.Query("weekly_capacity"); v != "" {
+		capacity, err := strconv.ParseFloat(v, 64)
+		if err != nil || capacity <= 0 {
+			BadRequest(c, "参数错误: weekly_capacity 应为正数")
+			return
+		}
+		q.WeeklyCapacity = capacity
+	}
+
+	report, err := h.svc.GetWorkload(c.Request.Context(), q)
+	if err != nil {
+		BadRequest(c, err.Error())
+		return
+	}
+	Success(c, report)
+}
@@ -3,6 +3,7 @@ package repository
 import (
 	"context"
 	"errors"
+	"time"
 
 	"github.com/bitfantasy/nimo/internal/plm/entity"
 	"gorm.io/gorm"
@@ -67,37 +68,86 @@ func (r *TaskFormRepository) FindLatestSubmission(ctx context.Context, taskID st
 	return &submission, nil
 }
 
-// UpsertDraftSubmission 保存草稿（version=0）
-func (r *TaskFormRepository) UpsertDraftSubmission(ctx context.Context, submission *entity.TaskFormSubmission) error {
-	var existing entity.TaskFormSubmission
+// FindSystemSubmission 获取系统控件（如采购控件）写入的 version=0 记录
+func (r *TaskFormRepository) FindSystemSubmission(ctx context.Context, taskID string) (*entity.TaskFormSubmission, error) {
+	return r.FindSubmissionByVersion(ctx, taskID, 0)
+}
+
+// ListSubmissions 获取任务的全部提交版本（不含系统控件写入的 version=0 记录），新版本在前
+func (r *TaskFormRepository) ListSubmissions(ctx context.Context, taskID string) ([]entity.TaskFormSubmission, error) {
+	var submissions []entity.TaskFormSubmission
+	err := r.db.WithContext(ctx).
+		Where("task_id = ? AND version > 0", taskID).
+		Order("version DESC").
+		Find(&submissions).Error
+	return submissions, err
+}
+
+// FindSubmissionByVersion 获取指定版本的提交
+func (r *TaskFormRepository) FindSubmissionByVersion(ctx context.Context, taskID string, version int) (*entity.TaskFormSubmission, error) {
+	var submission entity.TaskFormSubmission
+	err := r.db.WithContext(ctx).
+		Where("task_id = ? AND version = ?", taskID, version).
+		First(&submission).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &submission, nil
+}
+
+// MarkSubmissionRejected 在被驳回的提交版本上记录驳回意见
+func (r *TaskFormRepository) MarkSubmissionRejected(ctx context.Context, id, userID, reason string, at time.Time) error {
+	return r.db.WithContext(ctx).Model(&entity.TaskFormSubmission{}).
+		Where("id = ?", id).
+		Updates(map[string]interface{}{
+			"rejection_reason": reason,
+			"rejected_by":      userID,
+			"rejected_at":      at,
+		}).Error
+}
+
+// UpsertDraft 保存草稿（每人每个任务一份）
+func (r *TaskFormRepository) UpsertDraft(ctx context.Context, draft *entity.TaskFormDraft) error {
+	var existing entity.TaskFormDraft
 	err := r.db.WithContext(ctx).
-		Where("task_id = ? AND version = 0", submission.TaskID).
+		Where("task_id = ? AND user_id = ?", draft.TaskID, draft.UserID).
 		First(&existing).Error
 	if err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
-			submission.Version = 0
-			return r.db.WithContext(ctx).Create(submission).Error
+			return r.db.WithContext(ctx).Create(draft).Error
 		}
 		return err
 	}
-	existing.Data = submission.Data
-	existing.SubmittedAt = submission.SubmittedAt
+	existing.FormID = draft.FormID
+	existing.Data = draft.Data
+	existing.UpdatedAt = draft.UpdatedAt
+	*draft = existing
 	return r.db.WithContext(ctx).Save(&existing).Error
 }
 
-// FindDraftSubmission 获取草稿（version=0）
-func (r *TaskFormRepository) FindDraftSubmission(ctx context.Context, taskID string) (*entity.TaskFormSubmission, error) {
-	var submission entity.TaskFormSubmission
+// FindDraft 获取当前用户的草稿
+func (r *TaskFormRepository) FindDraft(ctx context.Context, taskID, userID string) (*entity.TaskFormDraft, error) {
+	var draft entity.TaskFormDraft
 	err := r.db.WithContext(ctx).
-		Where("task_id = ? AND version = 0", taskID).
-		First(&submission).Error
+		Where("task_id = ? AND user_id = ?", taskID, userID).
+		First(&draft).Error
 	if err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			return nil, nil
 		}
 		return nil, err
 	}
-	return &submission, nil
+	return &draft, nil
+}
+
+// DeleteDraft 删除当前用户的草稿
+func (r *TaskFormRepository) DeleteDraft(ctx context.Context, taskID, userID string) error {
+	return r.db.WithContext(ctx).
+		Where("task_id = ? AND user_id = ?", taskID, userID).
+		Delete(&entity.TaskFormDraft{}).Error
 }
 
 // FindTemplateFormsByTemplateID 根据模板ID查找模板表单
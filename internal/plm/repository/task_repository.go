@@ -260,6 +260,16 @@ func (r *TaskRepository) RemoveDependency(ctx context.Context, id string) error
 	return r.db.WithContext(ctx).Delete(&entity.TaskDependency{}, "id = ?", id).Error
 }
 
+// FindDependencyByID 按ID查找依赖
+func (r *TaskRepository) FindDependencyByID(ctx context.Context, id string) (*entity.TaskDependency, error) {
+	var dep entity.TaskDependency
+	err := r.db.WithContext(ctx).Where("id = ?", id).First(&dep).Error
+	if err != nil {
+		return nil, err
+	}
+	return &dep, nil
+}
+
 // ListDependencies 获取依赖列表
 func (r *TaskRepository) ListDependencies(ctx context.Context, taskID string) ([]entity.TaskDependency, error) {
 	var deps []entity.TaskDependency
@@ -342,8 +352,9 @@ func (r *TaskRepository) ListByAssigneeWithPaging(ctx context.Context, userID st
 // ListOverdue 获取逾期任务
 func (r *TaskRepository) ListOverdue(ctx context.Context, projectID string) ([]entity.Task, error) {
 	var tasks []entity.Task
+	// 截止日期映射在 planned_end 列（due_date 为旧列，未再写入）
 	query := r.db.WithContext(ctx).
-		Where("due_date < ? AND status NOT IN ?", time.Now(), []string{entity.TaskStatusCompleted, entity.TaskStatusCancelled})
+		Where("planned_end < ? AND status NOT IN ?", time.Now(), []string{entity.TaskStatusCompleted, entity.TaskStatusCancelled})
 	
 	if projectID != "" {
 		query = query.Where("project_id = ?", projectID)
@@ -352,7 +363,7 @@ func (r *TaskRepository) ListOverdue(ctx context.Context, projectID string) ([]e
 	err := query.
 		Preload("Assignee").
 		Preload("Project").
-		Order("due_date ASC").
+		Order("planned_end ASC").
 		Find(&tasks).Error
 	return tasks, err
 }
@@ -9,6 +9,7 @@ import (
 
 	"github.com/bitfantasy/nimo/internal/plm/entity"
 	"github.com/bitfantasy/nimo/internal/shared/feishu"
+	"github.com/bitfantasy/nimo/internal/shared/formschema"
 	"github.com/google/uuid"
 	"gorm.io/gorm"
 )
@@ -220,6 +221,14 @@ func (s *ApprovalDefinitionService) Delete(ctx context.Context, id string) error
 
 // Publish 发布审批定义
 func (s *ApprovalDefinitionService) Publish(ctx context.Context, id string) error {
+	var def entity.ApprovalDefinition
+	if err := s.db.WithContext(ctx).Where("id = ?", id).First(&def).Error; err != nil {
+		return fmt.Errorf("审批定义不存在")
+	}
+	if err := validateFlowSchema(def.FlowSchema); err != nil {
+		return err
+	}
+
 	result := s.db.WithContext(ctx).Model(&entity.ApprovalDefinition{}).
 		Where("id = ? AND status = ?", id, entity.ApprovalDefStatusDraft).
 		Update("status", entity.ApprovalDefStatusPublished)
@@ -287,35 +296,16 @@ func (s *ApprovalDefinitionService) CreateInstance(ctx context.Context, definiti
 		return nil, fmt.Errorf("审批定义未发布，无法发起")
 	}
 
-	// 2. 解析 flow_schema
-	var flowSchema entity.FlowSchema
-	if err := json.Unmarshal(def.FlowSchema, &flowSchema); err != nil {
-		return nil, fmt.Errorf("解析流程定义失败: %w", err)
-	}
-
-	// 3. 找到第一个 approve 节点
-	firstApproveIndex := -1
-	for i, node := range flowSchema.Nodes {
-		if node.Type == "approve" {
-			firstApproveIndex = i
-			break
-		}
-	}
-	if firstApproveIndex == -1 {
-		return nil, fmt.Errorf("流程定义中没有审批节点")
-	}
-
-	// 4. 确定第一个审批节点的审批人
-	firstNode := flowSchema.Nodes[firstApproveIndex]
-	approverIDs, err := s.resolveApprovers(firstNode, submitterID, req.SelectedApprovers, firstApproveIndex)
+	// 按表单定义校验提交数据
+	formSchema, err := formschema.Parse(def.FormSchema)
 	if err != nil {
-		return nil, fmt.Errorf("确定审批人失败: %w", err)
+		return nil, fmt.Errorf("解析表单定义失败: %w", err)
 	}
-	if len(approverIDs) == 0 {
-		return nil, fmt.Errorf("第一个审批节点没有审批人")
+	if err := formSchema.Validate(req.FormData); err != nil {
+		return nil, err
 	}
 
-	// 5. 构建 form_data
+	// 2. 构建 form_data
 	formDataJSON, _ := json.Marshal(req.FormData)
 	var formData entity.JSONB
 	json.Unmarshal(formDataJSON, &formData)
@@ -324,48 +314,74 @@ func (s *ApprovalDefinitionService) CreateInstance(ctx context.Context, definiti
 	if title == "" {
 		title = def.Name
 	}
+	var selected json.RawMessage
+	if len(req.SelectedApprovers) > 0 {
+		if selected, err = json.Marshal(req.SelectedApprovers); err != nil {
+			return nil, fmt.Errorf("序列化自选审批人失败: %w", err)
+		}
+	}
 
 	now := time.Now()
 	approval := &entity.ApprovalRequest{
-		ID:           uuid.New().String(),
-		ProjectID:    req.ProjectID,
-		TaskID:       req.TaskID,
-		Title:        title,
-		Description:  req.Description,
-		Type:         "definition",
-		Status:       entity.PLMApprovalStatusPending,
-		FormData:     formData,
-		RequestedBy:  submitterID,
-		DefinitionID: definitionID,
-		Code:         def.Code,
-		CurrentNode:  firstApproveIndex,
-		FlowSnapshot: def.FlowSchema,
-		CreatedAt:    now,
-		UpdatedAt:    now,
-	}
-
-	// 6. 创建审批人记录
+		ID:                uuid.New().String(),
+		ProjectID:         req.ProjectID,
+		TaskID:            req.TaskID,
+		Title:             title,
+		Description:       req.Description,
+		Type:              "definition",
+		Status:            entity.PLMApprovalStatusPending,
+		FormData:          formData,
+		RequestedBy:       submitterID,
+		DefinitionID:      definitionID,
+		Code:              def.Code,
+		FlowSnapshot:      def.FlowSchema,
+		SelectedApprovers: selected,
+		CreatedAt:         now,
+		UpdatedAt:         now,
+	}
+
+	// 3. 保存实例并按流程图推进到第一批审批节点（条件分支按表单取值选择，并行分支同时激活）
 	var reviewers []entity.ApprovalReviewer
-	for i, uid := range approverIDs {
-		reviewers = append(reviewers, entity.ApprovalReviewer{
-			ID:         uuid.New().String(),
-			ApprovalID: approval.ID,
-			UserID:     uid,
-			Status:     entity.PLMApprovalStatusPending,
-			Sequence:   i,
-			NodeIndex:  firstApproveIndex,
-			NodeName:   firstNode.Name,
-			ReviewType: "approve",
-		})
-	}
-	approval.Reviewers = reviewers
+	var completedTask *entity.Task // 流程无需审批时随之完成的任务，事务提交后启动后续任务
+	err = s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(approval).Error; err != nil {
+			return fmt.Errorf("创建审批实例失败: %w", err)
+		}
+		flow, err := newApprovalFlow(tx, approval, instanceScope(approval), true)
+		if err != nil {
+			return err
+		}
+		if err := flow.start(ctx); err != nil {
+			return err
+		}
+		reviewers = flow.reviewers
 
-	// 7. 保存到数据库
-	if err := s.db.WithContext(ctx).Create(approval).Error; err != nil {
-		return nil, fmt.Errorf("创建审批实例失败: %w", err)
+		updates := map[string]interface{}{
+			"approver_resolutions": appendApproverResolutions(nil, flow.resolutions...),
+		}
+		if node, ok := flow.currentNode(); ok {
+			updates["current_node"] = node
+		}
+		if flow.completed {
+			updates["result_comment"] = "流程无需审批，自动通过"
+		}
+		if err := tx.Model(approval).Updates(updates).Error; err != nil {
+			return fmt.Errorf("更新审批进度失败: %w", err)
+		}
+		if !flow.completed {
+			return nil
+		}
+		// 条件分支直接走到结束节点，无需审批：与审批通过走同一完成流程，关联任务随之完成
+		task, err := s.approvalSvc.completeApproval(ctx, tx, approval, submitterID, "流程无需审批，自动通过")
+		completedTask = task
+		return err
+	})
+	if err != nil {
+		return nil, err
 	}
+	s.approvalSvc.afterApprovalCompleted(ctx, completedTask, submitterID)
 
-	// 8. 发通知给第一批审批人
+	// 4. 发通知给第一批审批人
 	if s.feishuClient != nil {
 		go func() {
 			bgCtx := context.Background()
@@ -375,38 +391,17 @@ func (s *ApprovalDefinitionService) CreateInstance(ctx context.Context, definiti
 		}()
 	}
 
-	// 9. 加载关联
+	// 5. 加载关联
 	s.db.WithContext(ctx).
 		Preload("Reviewers").
 		Preload("Reviewers.User").
+		Preload("Branches").
 		Preload("Requester").
 		First(approval, "id = ?", approval.ID)
 
 	return approval, nil
 }
 
-// resolveApprovers 根据节点配置确定审批人
-func (s *ApprovalDefinitionService) resolveApprovers(node entity.FlowNode, submitterID string, selectedApprovers map[string][]string, nodeIndex int) ([]string, error) {
-	switch node.Config.ApproverType {
-	case "designated":
-		return node.Config.ApproverIDs, nil
-	case "self_select":
-		key := fmt.Sprintf("%d", nodeIndex)
-		if approvers, ok := selectedApprovers[key]; ok && len(approvers) > 0 {
-			return approvers, nil
-		}
-		return nil, fmt.Errorf("自选审批人节点[%s]未指定审批人", node.Name)
-	case "submitter":
-		return []string{submitterID}, nil
-	default:
-		// For supervisor, dept_leader, role — fallback to designated if approver_ids provided
-		if len(node.Config.ApproverIDs) > 0 {
-			return node.Config.ApproverIDs, nil
-		}
-		return nil, fmt.Errorf("审批人类型[%s]暂不支持自动解析，请指定审批人", node.Config.ApproverType)
-	}
-}
-
 // notifyReviewer 通知审批人
 func (s *ApprovalDefinitionService) notifyReviewer(ctx context.Context, approval *entity.ApprovalRequest, reviewerUserID string) {
 	var user entity.User
@@ -0,0 +1,376 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/bitfantasy/nimo/internal/plm/entity"
+	"github.com/bitfantasy/nimo/internal/shared/flowgraph"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// =============================================================================
+// 审批实例流转 — 按流程图推进各分支
+// =============================================================================
+
+// approvalFlow 推进一个审批实例：审批节点解析审批人后停下等待审批，条件节点按表单取值选择出线，
+// 并行节点为每条出线拆出子分支，同一并行节点的子分支全部到达汇合节点后父分支继续，
+// 根分支到达结束节点即整体通过
+type approvalFlow struct {
+	tx       *gorm.DB
+	approval *entity.ApprovalRequest
+	schema   entity.FlowSchema
+	graph    *flowgraph.Graph
+	resolver approverResolver
+	scope    approverScope
+	strict   bool // 发起时审批节点解析不到审批人直接报错；流转中转交管理员
+
+	reviewers   []entity.ApprovalReviewer   // 本次激活的审批人
+	resolutions []entity.ApproverResolution // 本次的审批人解析记录
+	completed   bool                        // 根分支已到达结束节点
+}
+
+func newApprovalFlow(tx *gorm.DB, approval *entity.ApprovalRequest, scope approverScope, strict bool) (*approvalFlow, error) {
+	var schema entity.FlowSchema
+	if err := json.Unmarshal(approval.FlowSnapshot, &schema); err != nil {
+		return nil, fmt.Errorf("解析流程定义失败: %w", err)
+	}
+	graph, err := buildFlowGraph(schema)
+	if err != nil {
+		return nil, err
+	}
+	return &approvalFlow{
+		tx:       tx,
+		approval: approval,
+		schema:   schema,
+		graph:    graph,
+		resolver: approverResolver{db: tx},
+		scope:    scope,
+		strict:   strict,
+	}, nil
+}
+
+// buildFlowGraph 流程定义 → 流程图
+func buildFlowGraph(schema entity.FlowSchema) (*flowgraph.Graph, error) {
+	nodes := make([]flowgraph.Node, len(schema.Nodes))
+	for i, n := range schema.Nodes {
+		nodes[i] = flowgraph.Node{ID: n.ID, Type: n.Type, Name: n.Name}
+	}
+	edges := make([]flowgraph.Edge, len(schema.Edges))
+	for i, e := range schema.Edges {
+		edges[i] = flowgraph.Edge{From: e.From, To: e.To, Name: e.Name, Condition: e.Condition, Default: e.Default}
+	}
+	graph, err := flowgraph.New(nodes, edges)
+	if err != nil {
+		return nil, fmt.Errorf("流程定义无效: %w", err)
+	}
+	return graph, nil
+}
+
+// validateFlowSchema 发布前校验流程定义：流程图结构（条件分支、并行拆分/汇合）和审批节点的审批人配置
+func validateFlowSchema(raw json.RawMessage) error {
+	var flow entity.FlowSchema
+	if err := json.Unmarshal(raw, &flow); err != nil {
+		return fmt.Errorf("流程定义格式错误: %w", err)
+	}
+	graph, err := buildFlowGraph(flow)
+	if err != nil {
+		return err
+	}
+
+	var problems []string
+	var verr *flowgraph.ValidationError
+	if err := graph.Validate(); errors.As(err, &verr) {
+		problems = append(problems, verr.Problems...)
+	}
+	for i, node := range flow.Nodes {
+		if node.Type != flowgraph.TypeApprove {
+			continue
+		}
+		name := node.Name
+		if name == "" {
+			name = fmt.Sprintf("#%d", i)
+		}
+		cfg := node.Config
+		switch cfg.ApproverType {
+		case ApproverTypeDesignated, "":
+			if len(cfg.ApproverIDs) == 0 {
+				problems = append(problems, fmt.Sprintf("节点[%s]未指定审批人", name))
+			}
+		case ApproverTypeSelfSelect, ApproverTypeSubmitter, ApproverTypeSupervisor, ApproverTypeDeptLeader:
+		case ApproverTypeRole:
+			if cfg.RoleCode == "" {
+				problems = append(problems, fmt.Sprintf("节点[%s]未配置角色", name))
+			}
+			if cfg.RoleScope != "" && cfg.RoleScope != RoleScopeGlobal && cfg.RoleScope != RoleScopeProject {
+				problems = append(problems, fmt.Sprintf("节点[%s]角色范围无效: %s", name, cfg.RoleScope))
+			}
+		default:
+			problems = append(problems, fmt.Sprintf("节点[%s]审批人类型无效: %s", name, cfg.ApproverType))
+		}
+		if cfg.SupervisorLevel < 0 || cfg.DeptLevel < 0 || cfg.SupervisorLevel > maxOrgDepth || cfg.DeptLevel > maxOrgDepth {
+			problems = append(problems, fmt.Sprintf("节点[%s]上级/部门层级超出范围", name))
+		}
+	}
+	if len(problems) > 0 {
+		return &flowgraph.ValidationError{Problems: problems}
+	}
+	return nil
+}
+
+// start 发起审批：从起始节点创建根分支并推进
+func (f *approvalFlow) start(ctx context.Context) error {
+	startID := f.graph.Start()
+	if startID == "" {
+		return fmt.Errorf("流程定义中没有节点")
+	}
+	root := f.newBranch("", "", "主流程")
+	if err := f.tx.WithContext(ctx).Create(root).Error; err != nil {
+		return fmt.Errorf("创建审批分支失败: %w", err)
+	}
+	return f.run(ctx, root, startID)
+}
+
+// advance 审批节点全部通过后，推进停在该节点的分支
+func (f *approvalFlow) advance(ctx context.Context, nodeIndex int) error {
+	node, ok := f.graph.NodeAt(nodeIndex)
+	if !ok {
+		return fmt.Errorf("审批节点不存在: %d", nodeIndex)
+	}
+
+	var branch entity.ApprovalBranch
+	err := f.tx.WithContext(ctx).
+		Where("approval_id = ? AND node_id = ? AND status = ?", f.approval.ID, node.ID, entity.ApprovalBranchActive).
+		First(&branch).Error
+	if err == gorm.ErrRecordNotFound {
+		// 分支记录上线前发起的实例没有分支，按当前节点补建根分支
+		var count int64
+		f.tx.WithContext(ctx).Model(&entity.ApprovalBranch{}).Where("approval_id = ?", f.approval.ID).Count(&count)
+		if count > 0 {
+			return fmt.Errorf("审批节点[%s]没有进行中的分支", node.Name)
+		}
+		branch = *f.newBranch("", "", "主流程")
+		f.visit(&branch, node.ID, nodeIndex)
+		if err := f.tx.WithContext(ctx).Create(&branch).Error; err != nil {
+			return fmt.Errorf("创建审批分支失败: %w", err)
+		}
+	} else if err != nil {
+		return fmt.Errorf("查询审批分支失败: %w", err)
+	}
+
+	next, err := f.graph.Next(node.ID)
+	if err != nil {
+		return err
+	}
+	return f.run(ctx, &branch, next)
+}
+
+// run 从 nodeID 开始推进分支，直到停在审批节点、等待汇合或结束
+func (f *approvalFlow) run(ctx context.Context, branch *entity.ApprovalBranch, nodeID string) error {
+	for {
+		if nodeID == "" {
+			// 线性流程的最后一个节点之后视同结束
+			return f.finish(ctx, branch)
+		}
+		node, index, ok := f.graph.Node(nodeID)
+		if !ok {
+			return fmt.Errorf("流程节点不存在: %s", nodeID)
+		}
+		f.visit(branch, node.ID, index)
+
+		switch node.Type {
+		case flowgraph.TypeApprove:
+			activated, err := f.activate(ctx, branch, index)
+			if err != nil || activated {
+				return err
+			}
+		case flowgraph.TypeCondition:
+			edge, err := f.graph.Choose(node.ID, map[string]interface{}(f.approval.FormData))
+			if err != nil {
+				return err
+			}
+			nodeID = edge.To
+			continue
+		case flowgraph.TypeParallel:
+			return f.split(ctx, branch, node)
+		case flowgraph.TypeJoin:
+			parent, err := f.join(ctx, branch, node)
+			if err != nil || parent == nil {
+				return err
+			}
+			branch = parent
+			f.visit(branch, node.ID, index)
+		case flowgraph.TypeEnd:
+			return f.finish(ctx, branch)
+		}
+
+		next, err := f.graph.Next(node.ID)
+		if err != nil {
+			return err
+		}
+		nodeID = next
+	}
+}
+
+// activate 解析审批节点的审批人并创建待审批记录，返回分支是否停在该节点
+// 解析和兜底都为空时：发起阶段报错；流转中停在该节点转交管理员处理，没有管理员则报错，不会跳过节点
+func (f *approvalFlow) activate(ctx context.Context, branch *entity.ApprovalBranch, index int) (bool, error) {
+	node := f.schema.Nodes[index]
+	ids, resolution, err := f.resolver.resolve(ctx, node, index, f.scope)
+	if err != nil {
+		return false, fmt.Errorf("确定审批节点[%s]审批人失败: %w", node.Name, err)
+	}
+	if len(ids) == 0 {
+		if f.strict {
+			return false, fmt.Errorf("审批节点[%s]没有审批人: %s", node.Name, resolution.Detail)
+		}
+		if ids, err = f.resolver.admins(ctx); err != nil {
+			return false, err
+		}
+		if len(ids) == 0 {
+			return false, fmt.Errorf("审批节点[%s]没有审批人且没有可转交的管理员: %s", node.Name, resolution.Detail)
+		}
+		resolution.Source = entity.ApproverSourceAdmin
+		resolution.UserIDs = ids
+		resolution.Detail += "，转交管理员处理"
+	}
+	f.resolutions = append(f.resolutions, resolution)
+
+	for i, uid := range ids {
+		reviewer := entity.ApprovalReviewer{
+			ID:         uuid.New().String(),
+			ApprovalID: f.approval.ID,
+			UserID:     uid,
+			Status:     entity.PLMApprovalStatusPending,
+			Sequence:   i,
+			NodeIndex:  index,
+			NodeName:   node.Name,
+			ReviewType: "approve",
+		}
+		if err := f.tx.WithContext(ctx).Create(&reviewer).Error; err != nil {
+			return false, fmt.Errorf("创建审批人记录失败: %w", err)
+		}
+		f.reviewers = append(f.reviewers, reviewer)
+	}
+
+	branch.Status = entity.ApprovalBranchActive
+	return true, f.save(ctx, branch)
+}
+
+// split 并行节点：为每条出线拆出子分支；先全部建好再逐个推进，避免先到汇合节点的分支误判其他分支已到达
+func (f *approvalFlow) split(ctx context.Context, branch *entity.ApprovalBranch, node flowgraph.Node) error {
+	branch.Status = entity.ApprovalBranchSplit
+	if err := f.save(ctx, branch); err != nil {
+		return err
+	}
+
+	edges := f.graph.Outgoing(node.ID)
+	children := make([]*entity.ApprovalBranch, 0, len(edges))
+	for _, e := range edges {
+		name := e.Name
+		if name == "" {
+			if target, _, ok := f.graph.Node(e.To); ok {
+				name = target.Name
+			}
+		}
+		child := f.newBranch(branch.ID, node.ID, name)
+		if err := f.tx.WithContext(ctx).Create(child).Error; err != nil {
+			return fmt.Errorf("创建并行分支失败: %w", err)
+		}
+		children = append(children, child)
+	}
+	for i, child := range children {
+		if err := f.run(ctx, child, edges[i].To); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// join 分支到达汇合节点；同一并行节点拆出的分支全部到达后返回父分支继续，否则返回 nil 等待
+func (f *approvalFlow) join(ctx context.Context, branch *entity.ApprovalBranch, node flowgraph.Node) (*entity.ApprovalBranch, error) {
+	if branch.ParentID == "" {
+		return nil, fmt.Errorf("汇合节点[%s]没有对应的并行分支", node.Name)
+	}
+	branch.Status = entity.ApprovalBranchJoined
+	if err := f.save(ctx, branch); err != nil {
+		return nil, err
+	}
+
+	var pending int64
+	if err := f.tx.WithContext(ctx).Model(&entity.ApprovalBranch{}).
+		Where("parent_id = ? AND status IN ?", branch.ParentID, []string{entity.ApprovalBranchActive, entity.ApprovalBranchSplit}).
+		Count(&pending).Error; err != nil {
+		return nil, fmt.Errorf("查询并行分支失败: %w", err)
+	}
+	if pending > 0 {
+		return nil, nil
+	}
+
+	if err := f.tx.WithContext(ctx).Model(&entity.ApprovalBranch{}).
+		Where("parent_id = ? AND status = ?", branch.ParentID, entity.ApprovalBranchJoined).
+		Updates(map[string]interface{}{
+			"status":     entity.ApprovalBranchCompleted,
+			"updated_at": time.Now(),
+		}).Error; err != nil {
+		return nil, fmt.Errorf("更新并行分支失败: %w", err)
+	}
+	var parent entity.ApprovalBranch
+	if err := f.tx.WithContext(ctx).First(&parent, "id = ?", branch.ParentID).Error; err != nil {
+		return nil, fmt.Errorf("查询父分支失败: %w", err)
+	}
+	return &parent, nil
+}
+
+// finish 分支结束；根分支结束即审批通过
+func (f *approvalFlow) finish(ctx context.Context, branch *entity.ApprovalBranch) error {
+	branch.Status = entity.ApprovalBranchCompleted
+	if branch.ParentID == "" {
+		f.completed = true
+	}
+	return f.save(ctx, branch)
+}
+
+func (f *approvalFlow) newBranch(parentID, splitNodeID, name string) *entity.ApprovalBranch {
+	now := time.Now()
+	return &entity.ApprovalBranch{
+		ID:          uuid.New().String(),
+		ApprovalID:  f.approval.ID,
+		ParentID:    parentID,
+		SplitNodeID: splitNodeID,
+		Name:        name,
+		Status:      entity.ApprovalBranchActive,
+		Path:        json.RawMessage("[]"),
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+}
+
+// visit 记录分支到达的节点
+func (f *approvalFlow) visit(branch *entity.ApprovalBranch, nodeID string, index int) {
+	var path []string
+	json.Unmarshal(branch.Path, &path)
+	branch.Path, _ = json.Marshal(append(path, nodeID))
+	branch.NodeID = nodeID
+	branch.NodeIndex = index
+}
+
+func (f *approvalFlow) save(ctx context.Context, branch *entity.ApprovalBranch) error {
+	branch.UpdatedAt = time.Now()
+	if err := f.tx.WithContext(ctx).Save(branch).Error; err != nil {
+		return fmt.Errorf("保存审批分支失败: %w", err)
+	}
+	return nil
+}
+
+// currentNode 本次激活的最后一个审批节点（兼容只展示单个当前节点的页面）
+func (f *approvalFlow) currentNode() (int, bool) {
+	if len(f.reviewers) == 0 {
+		return 0, false
+	}
+	return f.reviewers[len(f.reviewers)-1].NodeIndex, true
+}
@@ -2,7 +2,6 @@ package service
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
 	"log"
 	"time"
@@ -12,6 +11,7 @@ import (
 	"github.com/bitfantasy/nimo/internal/shared/feishu"
 	"github.com/google/uuid"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 // ApprovalService 审批服务
@@ -19,6 +19,9 @@ type ApprovalService struct {
 	db           *gorm.DB
 	feishuClient *feishu.FeishuClient
 	projectSvc   *ProjectService
+	workflowSvc  *WorkflowService
+	taskStates   *TaskStateMirror
+	reviewSvc    *ReviewMeetingService
 }
 
 // NewApprovalService 创建审批服务
@@ -26,11 +29,26 @@ func NewApprovalService(db *gorm.DB, fc *feishu.FeishuClient) *ApprovalService {
 	return &ApprovalService{db: db, feishuClient: fc}
 }
 
-// SetProjectService 注入项目服务（用于下游任务激活通知）
+// SetProjectService 注入项目服务（审批通过后处理表单、触发自动化规则）
 func (s *ApprovalService) SetProjectService(svc *ProjectService) {
 	s.projectSvc = svc
 }
 
+// SetWorkflowService 注入工作流服务（审批通过后按工作流自动启动后续任务）
+func (s *ApprovalService) SetWorkflowService(svc *WorkflowService) {
+	s.workflowSvc = svc
+}
+
+// SetReviewMeetingService 注入评审会议服务（关联评审未结束时审批不能通过）
+func (s *ApprovalService) SetReviewMeetingService(svc *ReviewMeetingService) {
+	s.reviewSvc = svc
+}
+
+// SetTaskStateMirror 注入任务状态镜像（审批发起/通过/驳回时同步 plm_task 状态机）
+func (s *ApprovalService) SetTaskStateMirror(m *TaskStateMirror) {
+	s.taskStates = m
+}
+
 // CreateApprovalReq 创建审批请求参数
 type CreateApprovalReq struct {
 	ProjectID   string   `json:"project_id" binding:"required"`
@@ -86,9 +104,12 @@ func (s *ApprovalService) CreateApproval(ctx context.Context, req CreateApproval
 	}
 
 	// 更新关联任务状态为 reviewing
+	var fromStatus string
+	s.db.WithContext(ctx).Model(&entity.Task{}).Where("id = ?", req.TaskID).Select("status").Scan(&fromStatus)
 	s.db.WithContext(ctx).Model(&entity.Task{}).
 		Where("id = ?", req.TaskID).
 		Update("status", entity.TaskStatusReviewing)
+	s.taskStates.Sync(ctx, req.TaskID, fromStatus, entity.TaskStatusReviewing, requestedBy)
 
 	// 异步发飞书通知给审批人
 	if s.feishuClient != nil {
@@ -106,9 +127,20 @@ func (s *ApprovalService) CreateApproval(ctx context.Context, req CreateApproval
 	return approval, nil
 }
 
-// Approve 审批通过（支持多级审批）
+// Approve 审批通过（支持多级、条件分支和并行分支审批）
 func (s *ApprovalService) Approve(ctx context.Context, approvalID, reviewerUserID, comment string) error {
-	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+	var completedTask *entity.Task // 审批通过后完成的任务，事务提交后触发自动化规则
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		// 先锁住审批实例，同一实例的审批依次处理：并行分支的最后两人同时通过时，
+		// 节点计数和汇合判断都能看到对方已提交的结果，不会漏推进或重复推进
+		var approval entity.ApprovalRequest
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&approval, "id = ?", approvalID).Error; err != nil {
+			return fmt.Errorf("审批请求不存在: %w", err)
+		}
+		if approval.Status != entity.PLMApprovalStatusPending {
+			return fmt.Errorf("审批已结束（当前状态: %s）", approval.Status)
+		}
+
 		// 查找审批人记录
 		var reviewer entity.ApprovalReviewer
 		if err := tx.Where("approval_id = ? AND user_id = ? AND status = ?", approvalID, reviewerUserID, entity.PLMApprovalStatusPending).First(&reviewer).Error; err != nil {
@@ -124,155 +156,167 @@ func (s *ApprovalService) Approve(ctx context.Context, approvalID, reviewerUserI
 			return fmt.Errorf("更新审批人状态失败: %w", err)
 		}
 
-		// 获取审批请求
-		var approval entity.ApprovalRequest
-		if err := tx.Where("id = ?", approvalID).First(&approval).Error; err != nil {
-			return fmt.Errorf("审批请求不存在: %w", err)
-		}
-
-		// 检查当前节点的所有审批人是否都已通过
-		currentNode := approval.CurrentNode
+		// 检查该审批人所在节点的所有审批人是否都已通过（并行分支下各节点独立）
+		currentNode := reviewer.NodeIndex
 		var pendingCount int64
-		tx.Model(&entity.ApprovalReviewer{}).
+		if err := tx.Model(&entity.ApprovalReviewer{}).
 			Where("approval_id = ? AND node_index = ? AND status = ?", approvalID, currentNode, entity.PLMApprovalStatusPending).
-			Count(&pendingCount)
+			Count(&pendingCount).Error; err != nil {
+			return fmt.Errorf("查询节点审批进度失败: %w", err)
+		}
 
 		if pendingCount > 0 {
 			// 当前节点还有人未审批
 			return nil
 		}
 
-		// 当前节点所有人都已通过，检查是否有下一个审批节点
-		if approval.FlowSnapshot != nil && len(approval.FlowSnapshot) > 0 {
-			var flowSchema entity.FlowSchema
-			if err := json.Unmarshal(approval.FlowSnapshot, &flowSchema); err == nil {
-				// 找下一个 approve 节点
-				nextApproveIndex := -1
-				for i := currentNode + 1; i < len(flowSchema.Nodes); i++ {
-					if flowSchema.Nodes[i].Type == "approve" {
-						nextApproveIndex = i
-						break
-					}
-				}
+		// 当前节点所有人都已通过，按流程图推进该节点所在分支
+		if len(approval.FlowSnapshot) > 0 {
+			flow, err := newApprovalFlow(tx, &approval, instanceScope(&approval), false)
+			if err != nil {
+				return err
+			}
+			if err := flow.advance(ctx, currentNode); err != nil {
+				return fmt.Errorf("推进审批流程失败: %w", err)
+			}
 
-				if nextApproveIndex != -1 {
-					// 存在下一个审批节点，激活它
-					nextNode := flowSchema.Nodes[nextApproveIndex]
-					approverIDs := nextNode.Config.ApproverIDs
-					if len(approverIDs) == 0 && nextNode.Config.ApproverType == "submitter" {
-						approverIDs = []string{approval.RequestedBy}
-					}
+			updates := map[string]interface{}{"updated_at": now}
+			if len(flow.resolutions) > 0 {
+				updates["approver_resolutions"] = appendApproverResolutions(approval.ApproverResolutions, flow.resolutions...)
+			}
+			if node, ok := flow.currentNode(); ok {
+				updates["current_node"] = node
+			}
+			if err := tx.Model(&entity.ApprovalRequest{}).Where("id = ?", approvalID).Updates(updates).Error; err != nil {
+				return fmt.Errorf("更新审批进度失败: %w", err)
+			}
 
-					if len(approverIDs) > 0 {
-						// 创建下一节点的审批人记录
-						for i, uid := range approverIDs {
-							nextReviewer := entity.ApprovalReviewer{
-								ID:         uuid.New().String(),
-								ApprovalID: approvalID,
-								UserID:     uid,
-								Status:     entity.PLMApprovalStatusPending,
-								Sequence:   i,
-								NodeIndex:  nextApproveIndex,
-								NodeName:   nextNode.Name,
-								ReviewType: "approve",
-							}
-							if err := tx.Create(&nextReviewer).Error; err != nil {
-								return fmt.Errorf("创建下一节点审批人失败: %w", err)
-							}
-
-							// 异步通知
-							if s.feishuClient != nil {
-								go s.notifyReviewer(context.Background(), &approval, uid)
-							}
-						}
-
-						// 更新 current_node
-						if err := tx.Model(&entity.ApprovalRequest{}).
-							Where("id = ?", approvalID).
-							Updates(map[string]interface{}{
-								"current_node": nextApproveIndex,
-								"updated_at":   now,
-							}).Error; err != nil {
-							return fmt.Errorf("更新当前节点失败: %w", err)
-						}
-
-						return nil // 还没结束，等下一个节点
+			if !flow.completed {
+				// 异步通知新激活的审批人；其他并行分支仍在审批时等待
+				if s.feishuClient != nil {
+					for _, r := range flow.reviewers {
+						go s.notifyReviewer(context.Background(), &approval, r.UserID)
 					}
 				}
+				return nil
 			}
 		}
 
-		// 没有下一个审批节点，或者是旧的审批（无 flow_snapshot），整体通过
-		if err := tx.Model(&entity.ApprovalRequest{}).
-			Where("id = ?", approvalID).
-			Updates(map[string]interface{}{
-				"status":     entity.PLMApprovalStatusApproved,
-				"result":     entity.PLMApprovalStatusApproved,
-				"updated_at": now,
-			}).Error; err != nil {
-			return fmt.Errorf("更新审批状态失败: %w", err)
+		// 流程到达结束节点，或者是旧的审批（无 flow_snapshot），整体通过
+		task, err := s.completeApproval(ctx, tx, &approval, reviewerUserID, comment)
+		completedTask = task
+		return err
+	})
+	if err == nil {
+		s.afterApprovalCompleted(ctx, completedTask, reviewerUserID)
+	}
+	return err
+}
+
+// completeApproval 在事务中将审批实例整体通过：关联任务 reviewing → completed，评审须已结束；
+// 逐级审批走完和发起时流程无需审批（CreateInstance）共用。返回被完成的任务，事务提交后交给 afterApprovalCompleted
+func (s *ApprovalService) completeApproval(ctx context.Context, tx *gorm.DB, approval *entity.ApprovalRequest, operatorID, comment string) (*entity.Task, error) {
+	var completedTask *entity.Task
+	if approval.TaskID != "" && s.reviewSvc != nil {
+		if err := checkTaskReviews(tx, approval.TaskID); err != nil {
+			return nil, err
 		}
+	}
 
-		// 更新关联任务状态: reviewing → completed（审批通过直接完成）
-		if approval.TaskID != "" {
-			completedAt := time.Now()
-			result := tx.Model(&entity.Task{}).
-				Where("id = ? AND status = ?", approval.TaskID, entity.TaskStatusReviewing).
-				Updates(map[string]interface{}{
-					"status":     entity.TaskStatusCompleted,
-					"actual_end": completedAt,
-					"progress":   100,
-					"updated_at": completedAt,
-				})
-			// 审批通过且任务变为completed时，自动启动依赖此任务的后续任务
-			if result.RowsAffected > 0 {
-				var task entity.Task
-				if err := tx.Where("id = ?", approval.TaskID).First(&task).Error; err == nil {
-					s.autoStartDependentTasks(ctx, tx, task.ProjectID, task.ID)
-				}
+	if err := tx.Model(&entity.ApprovalRequest{}).
+		Where("id = ?", approval.ID).
+		Updates(map[string]interface{}{
+			"status":     entity.PLMApprovalStatusApproved,
+			"result":     entity.PLMApprovalStatusApproved,
+			"updated_at": time.Now(),
+		}).Error; err != nil {
+		return nil, fmt.Errorf("更新审批状态失败: %w", err)
+	}
+
+	// 更新关联任务状态: reviewing → completed（审批通过直接完成）
+	if approval.TaskID != "" {
+		completedAt := time.Now()
+		result := tx.Model(&entity.Task{}).
+			Where("id = ? AND status = ?", approval.TaskID, entity.TaskStatusReviewing).
+			Updates(map[string]interface{}{
+				"status":     entity.TaskStatusCompleted,
+				"actual_end": completedAt,
+				"progress":   100,
+				"updated_at": completedAt,
+			})
+		// 任务变为completed时返回给调用方，事务提交后由 afterApprovalCompleted 启动后续任务
+		if result.RowsAffected > 0 {
+			var task entity.Task
+			if err := tx.Where("id = ?", approval.TaskID).First(&task).Error; err == nil {
+				completedTask = &task
 			}
 		}
+	}
 
-		// 审批通过后，处理表单副作用（BOM创建 + 角色绑定等）
-		if approval.TaskID != "" && s.projectSvc != nil {
-			go func() {
-				bgCtx := context.Background()
-				// 获取任务用于角色绑定
-				var taskEntity entity.Task
-				if err := s.db.Where("id = ?", approval.TaskID).First(&taskEntity).Error; err == nil {
-					s.projectSvc.processRoleAssignment(bgCtx, &taskEntity)
-				}
-				// BOM创建
-				if s.projectSvc.bomSvc != nil && s.projectSvc.taskFormRepo != nil {
-					form, ferr := s.projectSvc.taskFormRepo.FindByTaskID(bgCtx, approval.TaskID)
-					if ferr != nil || form == nil {
-						return
-					}
-					submission, serr := s.projectSvc.taskFormRepo.FindLatestSubmission(bgCtx, approval.TaskID)
-					if serr != nil || submission == nil {
-						return
-					}
+	// 审批通过后，处理表单副作用（BOM创建 + 角色绑定等）
+	if approval.TaskID != "" && s.projectSvc != nil {
+		go func() {
+			bgCtx := context.Background()
+			// 获取任务用于角色绑定
+			var taskEntity entity.Task
+			if err := s.db.Where("id = ?", approval.TaskID).First(&taskEntity).Error; err == nil {
+				s.projectSvc.processRoleAssignment(bgCtx, &taskEntity)
+			}
+			// BOM创建
+			var bomIDs []string
+			if s.projectSvc.bomSvc != nil && s.projectSvc.taskFormRepo != nil {
+				form, ferr := s.projectSvc.taskFormRepo.FindByTaskID(bgCtx, approval.TaskID)
+				submission, serr := s.projectSvc.taskFormRepo.FindLatestSubmission(bgCtx, approval.TaskID)
+				if ferr == nil && form != nil && serr == nil && submission != nil {
 					formData := map[string]interface{}(submission.Data)
-					s.projectSvc.ProcessBOMUploadFields(bgCtx, form, formData, approval.ProjectID, approval.RequestedBy)
+					bomIDs = s.projectSvc.ProcessBOMUploadFields(bgCtx, form, formData, approval.ProjectID, approval.RequestedBy)
 				}
-			}()
-		}
+			}
+			// 上传的BOM/文档自动登记为阶段交付物
+			if completedTask != nil {
+				s.projectSvc.phaseGateSvc.FulfillTaskDeliverables(bgCtx, approval.TaskID, operatorID, bomIDs)
+			}
+		}()
+	}
 
-		// 发通知给发起人
-		if s.feishuClient != nil {
-			go s.notifyRequester(context.Background(), &approval, "approved", comment)
-		}
+	// 发通知给发起人
+	if s.feishuClient != nil {
+		go s.notifyRequester(context.Background(), approval, "approved", comment)
+	}
 
-		// SSE: 通知前端审批通过
-		sse.PublishTaskUpdate(approval.ProjectID, approval.TaskID, "approval_approved")
+	// SSE: 通知前端审批通过
+	sse.PublishTaskUpdate(approval.ProjectID, approval.TaskID, "approval_approved")
 
-		return nil
-	})
+	return completedTask, nil
+}
+
+// afterApprovalCompleted 审批通过的事务提交后：同步任务状态镜像、按依赖类型自动启动后续任务、触发自动化规则和飞书同步
+func (s *ApprovalService) afterApprovalCompleted(ctx context.Context, completedTask *entity.Task, operatorID string) {
+	if completedTask == nil {
+		return
+	}
+	s.taskStates.Sync(ctx, completedTask.ID, entity.TaskStatusReviewing, entity.TaskStatusCompleted, operatorID)
+	// 与直接完成任务走同一套自动启动逻辑，按依赖类型判断后续任务能否开始
+	s.workflowSvc.checkAndStartDependentTasks(ctx, completedTask.ProjectID, completedTask.ID)
+	if s.projectSvc != nil {
+		s.projectSvc.automationSvc.FireAsync(ctx, AutomationEvent{
+			Type: entity.AutomationTriggerTaskComplete, ProjectID: completedTask.ProjectID, TaskID: completedTask.ID,
+			FromStatus: entity.TaskStatusReviewing, ToStatus: entity.TaskStatusCompleted, OperatorID: operatorID,
+		})
+		s.projectSvc.taskSync.SyncAsync(completedTask.ID)
+	}
 }
 
 // Reject 审批驳回
 func (s *ApprovalService) Reject(ctx context.Context, approvalID, reviewerUserID, comment string) error {
-	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+	var reopenedTaskID string // 打回修改的任务，事务提交后同步状态机
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		// 与 Approve 一样先锁住审批实例，避免驳回与并行分支的推进交错
+		var locked entity.ApprovalRequest
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&locked, "id = ?", approvalID).Error; err != nil {
+			return fmt.Errorf("审批请求不存在: %w", err)
+		}
+
 		// 查找审批人记录
 		var reviewer entity.ApprovalReviewer
 		if err := tx.Where("approval_id = ? AND user_id = ?", approvalID, reviewerUserID).First(&reviewer).Error; err != nil {
@@ -303,16 +347,31 @@ func (s *ApprovalService) Reject(ctx context.Context, approvalID, reviewerUserID
 			return fmt.Errorf("更新审批状态失败: %w", err)
 		}
 
+		// 未结束的分支一并取消
+		if err := tx.Model(&entity.ApprovalBranch{}).
+			Where("approval_id = ? AND status IN ?", approvalID, []string{
+				entity.ApprovalBranchActive, entity.ApprovalBranchSplit, entity.ApprovalBranchJoined,
+			}).
+			Updates(map[string]interface{}{
+				"status":     entity.ApprovalBranchCanceled,
+				"updated_at": now,
+			}).Error; err != nil {
+			return fmt.Errorf("更新审批分支失败: %w", err)
+		}
+
 		// 获取审批请求
 		var approval entity.ApprovalRequest
 		if err := tx.Where("id = ?", approvalID).First(&approval).Error; err == nil {
 			// 更新关联任务状态: reviewing → in_progress（打回修改）
-			tx.Model(&entity.Task{}).
+			result := tx.Model(&entity.Task{}).
 				Where("id = ? AND status = ?", approval.TaskID, entity.TaskStatusReviewing).
 				Updates(map[string]interface{}{
 					"status":     entity.TaskStatusInProgress,
 					"updated_at": now,
 				})
+			if result.Error == nil && result.RowsAffected > 0 {
+				reopenedTaskID = approval.TaskID
+			}
 
 			// 发通知给发起人
 			if s.feishuClient != nil {
@@ -325,6 +384,10 @@ func (s *ApprovalService) Reject(ctx context.Context, approvalID, reviewerUserID
 
 		return nil
 	})
+	if err == nil && reopenedTaskID != "" {
+		s.taskStates.Sync(ctx, reopenedTaskID, entity.TaskStatusReviewing, entity.TaskStatusInProgress, reviewerUserID)
+	}
+	return err
 }
 
 // ListMyPending 获取我的待审批列表
@@ -393,6 +456,7 @@ func (s *ApprovalService) GetApproval(ctx context.Context, approvalID string) (*
 		Where("id = ?", approvalID).
 		Preload("Reviewers").
 		Preload("Reviewers.User").
+		Preload("Branches").
 		Preload("Requester").
 		Preload("Task").
 		Preload("Project").
@@ -407,6 +471,7 @@ func (s *ApprovalService) loadApprovalRelations(ctx context.Context, approval *e
 	s.db.WithContext(ctx).
 		Preload("Reviewers").
 		Preload("Reviewers.User").
+		Preload("Branches").
 		Preload("Requester").
 		Preload("Task").
 		Preload("Project").
@@ -506,67 +571,3 @@ func NewApprovalRequestCard(title, requesterName, description string) feishu.Int
 		Elements: elements,
 	}
 }
-
-// autoStartDependentTasks 审批通过后自动启动依赖任务
-func (s *ApprovalService) autoStartDependentTasks(ctx context.Context, db *gorm.DB, projectID, completedTaskID string) {
-	var deps []entity.TaskDependency
-	if err := db.Where("depends_on_task_id = ?", completedTaskID).Find(&deps).Error; err != nil {
-		log.Printf("[ApprovalService] 查找依赖任务失败: %v", err)
-		return
-	}
-
-	for _, dep := range deps {
-		var task entity.Task
-		if err := db.Where("id = ?", dep.TaskID).First(&task).Error; err != nil {
-			continue
-		}
-		if task.Status != entity.TaskStatusPending {
-			continue
-		}
-
-		// 检查该任务的所有前置依赖是否都已完成
-		allCompleted := true
-		var allDeps []entity.TaskDependency
-		if err := db.Where("task_id = ?", task.ID).Find(&allDeps).Error; err != nil {
-			continue
-		}
-		for _, d := range allDeps {
-			var depTask entity.Task
-			if err := db.Where("id = ?", d.DependsOnID).First(&depTask).Error; err != nil {
-				allCompleted = false
-				break
-			}
-			if depTask.Status != entity.TaskStatusCompleted {
-				allCompleted = false
-				break
-			}
-		}
-
-		if allCompleted {
-			now := time.Now()
-			task.Status = entity.TaskStatusInProgress
-			task.ActualStart = &now
-			if err := db.Save(&task).Error; err != nil {
-				log.Printf("[ApprovalService] 自动启动任务失败 (task=%s): %v", task.ID, err)
-				continue
-			}
-			log.Printf("[ApprovalService] 审批通过后自动启动任务 task=%s (依赖任务 %s 完成)", task.ID, completedTaskID)
-
-			// SSE: 通知前端任务被激活
-			sse.PublishTaskUpdate(projectID, task.ID, "task_activated")
-			// SSE: 如果任务有 assignee，给特定用户发个人事件
-			if task.AssigneeID != nil && *task.AssigneeID != "" {
-				sse.PublishUserTaskUpdate(*task.AssigneeID, projectID, task.ID, "task_activated")
-			}
-
-			// 发送飞书通知（延迟2秒，确保审批通过通知先到达）
-			if s.projectSvc != nil {
-				taskCopy := task
-				go func() {
-					time.Sleep(2 * time.Second)
-					s.projectSvc.notifyTaskActivation(context.Background(), &taskCopy)
-				}()
-			}
-		}
-	}
-}
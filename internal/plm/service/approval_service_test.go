@@ -0,0 +1,212 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/bitfantasy/nimo/internal/plm/entity"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+func TestApproveWaitsForOpenReviewMeetings(t *testing.T) {
+	db := setupServiceTestDB(t, &entity.Task{}, &entity.TaskDependency{}, &entity.ApprovalRequest{},
+		&entity.ApprovalReviewer{}, &entity.ReviewMeeting{})
+	ctx := context.Background()
+	require.NoError(t, db.Create(&entity.Task{ID: "t1", ProjectID: "p1", Title: "结构设计", Status: entity.TaskStatusReviewing, CreatedBy: "u1"}).Error)
+	require.NoError(t, db.Create(&entity.ApprovalRequest{ID: "a1", ProjectID: "p1", TaskID: "t1", Title: "结构设计审批", RequestedBy: "u1", Status: entity.PLMApprovalStatusPending}).Error)
+	require.NoError(t, db.Create(&entity.ApprovalReviewer{ID: "r1", ApprovalID: "a1", UserID: "u2", Status: entity.PLMApprovalStatusPending}).Error)
+	require.NoError(t, db.Create(&entity.ReviewMeeting{ID: "m1", Title: "结构评审会", MeetingType: "DESIGN", ProjectID: "p1", TaskID: "t1",
+		ScheduledAt: time.Now(), OrganizerID: "u1", Status: entity.ReviewMeetingStatusScheduled}).Error)
+
+	svc := NewApprovalService(db, nil)
+	svc.SetReviewMeetingService(NewReviewMeetingService(db))
+
+	err := svc.Approve(ctx, "a1", "u2", "同意")
+	assert.ErrorContains(t, err, "评审会议[结构评审会]尚未结束")
+	var task entity.Task
+	require.NoError(t, db.First(&task, "id = ?", "t1").Error)
+	assert.Equal(t, entity.TaskStatusReviewing, task.Status)
+	var reviewer entity.ApprovalReviewer
+	require.NoError(t, db.First(&reviewer, "id = ?", "r1").Error)
+	assert.Equal(t, entity.PLMApprovalStatusPending, reviewer.Status, "审批被拦截时审批人的决定不应落库")
+
+	require.NoError(t, db.Model(&entity.ReviewMeeting{}).Where("id = ?", "m1").Update("status", entity.ReviewMeetingStatusCompleted).Error)
+	require.NoError(t, svc.Approve(ctx, "a1", "u2", "同意"))
+	require.NoError(t, db.First(&task, "id = ?", "t1").Error)
+	assert.Equal(t, entity.TaskStatusCompleted, task.Status)
+}
+
+// 发起 → 金额条件（>5万加财务总监）→ 并行（法务、质量）→ 汇合 → 结束
+func setupFlowApprovalTest(t *testing.T) (*ApprovalDefinitionService, *ApprovalService, context.Context) {
+	t.Helper()
+	db := setupServiceTestDB(t, &entity.User{}, &entity.ApprovalDefinition{}, &entity.ApprovalRequest{},
+		&entity.ApprovalReviewer{}, &entity.ApprovalBranch{})
+	for _, id := range []string{"u1", "cfo", "lg", "qa"} {
+		require.NoError(t, db.Create(&entity.User{ID: id, Username: id, Name: id, FeishuUserID: "fs_" + id, Email: id + "@example.com", Status: "active"}).Error)
+	}
+
+	designated := func(ids ...string) entity.FlowNodeConfig {
+		return entity.FlowNodeConfig{ApproverType: ApproverTypeDesignated, ApproverIDs: ids}
+	}
+	flow, err := json.Marshal(entity.FlowSchema{
+		Nodes: []entity.FlowNode{
+			{ID: "start", Type: "submit", Name: "发起"},
+			{ID: "amount", Type: "condition", Name: "金额判断"},
+			{ID: "cfo", Type: "approve", Name: "财务总监", Config: designated("cfo")},
+			{ID: "split", Type: "parallel", Name: "会签"},
+			{ID: "legal", Type: "approve", Name: "法务", Config: designated("lg")},
+			{ID: "quality", Type: "approve", Name: "质量", Config: designated("qa")},
+			{ID: "join", Type: "join", Name: "汇合"},
+			{ID: "end", Type: "end", Name: "结束"},
+		},
+		Edges: []entity.FlowEdge{
+			{From: "start", To: "amount"},
+			{From: "amount", To: "cfo", Name: "大额", Condition: json.RawMessage(`{"field": "amount", "op": "gt", "value": 50000}`)},
+			{From: "amount", To: "split", Name: "其他", Default: true},
+			{From: "cfo", To: "split"},
+			{From: "split", To: "legal"},
+			{From: "split", To: "quality"},
+			{From: "legal", To: "join"},
+			{From: "quality", To: "join"},
+			{From: "join", To: "end"},
+		},
+	})
+	require.NoError(t, err)
+	require.NoError(t, validateFlowSchema(flow))
+	require.NoError(t, db.Create(&entity.ApprovalDefinition{
+		ID: "def1", Code: "PURCHASE", Name: "采购审批", FormSchema: json.RawMessage(`[]`), FlowSchema: flow,
+		Status: entity.ApprovalDefStatusPublished, CreatedBy: "u1",
+	}).Error)
+
+	approvalSvc := NewApprovalService(db, nil)
+	return NewApprovalDefinitionService(db, nil, approvalSvc), approvalSvc, context.Background()
+}
+
+func pendingApprovers(t *testing.T, db *gorm.DB, approvalID string) []string {
+	t.Helper()
+	var ids []string
+	require.NoError(t, db.Model(&entity.ApprovalReviewer{}).
+		Where("approval_id = ? AND status = ?", approvalID, entity.PLMApprovalStatusPending).
+		Order("user_id").Pluck("user_id", &ids).Error)
+	return ids
+}
+
+func approvalStatus(t *testing.T, db *gorm.DB, approvalID string) string {
+	t.Helper()
+	var approval entity.ApprovalRequest
+	require.NoError(t, db.First(&approval, "id = ?", approvalID).Error)
+	return approval.Status
+}
+
+func TestFlowApprovalConditionSplitJoin(t *testing.T) {
+	tests := []struct {
+		name   string
+		amount float64
+		steps  [][]string // 每一步：当前待审批人（按 user_id 排序），依次全部通过后进入下一步
+	}{
+		{name: "小额直接进入会签", amount: 100, steps: [][]string{{"lg", "qa"}}},
+		{name: "大额先经财务总监", amount: 80000, steps: [][]string{{"cfo"}, {"lg", "qa"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defSvc, svc, ctx := setupFlowApprovalTest(t)
+			approval, err := defSvc.CreateInstance(ctx, "def1", CreateInstanceReq{
+				FormData: map[string]interface{}{"amount": tt.amount},
+			}, "u1")
+			require.NoError(t, err)
+
+			for i, step := range tt.steps {
+				assert.Equal(t, step, pendingApprovers(t, svc.db, approval.ID), "第 %d 步", i+1)
+				for j, uid := range step {
+					require.NoError(t, svc.Approve(ctx, approval.ID, uid, "同意"))
+					last := i == len(tt.steps)-1 && j == len(step)-1
+					if !last {
+						// 并行分支未全部到达汇合节点前，实例保持审批中
+						assert.Equal(t, entity.PLMApprovalStatusPending, approvalStatus(t, svc.db, approval.ID))
+					}
+				}
+			}
+			assert.Equal(t, entity.PLMApprovalStatusApproved, approvalStatus(t, svc.db, approval.ID))
+			assert.Empty(t, pendingApprovers(t, svc.db, approval.ID))
+
+			// 会签的两个分支都已汇合，根分支结束
+			var branches []entity.ApprovalBranch
+			require.NoError(t, svc.db.Where("approval_id = ?", approval.ID).Find(&branches).Error)
+			require.Len(t, branches, 3)
+			for _, b := range branches {
+				assert.Equal(t, entity.ApprovalBranchCompleted, b.Status, b.Name)
+			}
+
+			// 审批结束后不能再处理
+			assert.Error(t, svc.Approve(ctx, approval.ID, "qa", "同意"))
+		})
+	}
+}
+
+func TestFlowApprovalRejectCancelsParallelBranches(t *testing.T) {
+	defSvc, svc, ctx := setupFlowApprovalTest(t)
+	approval, err := defSvc.CreateInstance(ctx, "def1", CreateInstanceReq{FormData: map[string]interface{}{"amount": 100}}, "u1")
+	require.NoError(t, err)
+
+	require.NoError(t, svc.Approve(ctx, approval.ID, "lg", "同意"))
+	require.NoError(t, svc.Reject(ctx, approval.ID, "qa", "资料不全"))
+	assert.Equal(t, entity.PLMApprovalStatusRejected, approvalStatus(t, svc.db, approval.ID))
+
+	var open int64
+	require.NoError(t, svc.db.Model(&entity.ApprovalBranch{}).
+		Where("approval_id = ? AND status IN ?", approval.ID, []string{entity.ApprovalBranchActive, entity.ApprovalBranchSplit, entity.ApprovalBranchJoined}).
+		Count(&open).Error)
+	assert.Zero(t, open)
+	assert.ErrorContains(t, svc.Approve(ctx, approval.ID, "lg", "同意"), "审批已结束")
+}
+
+func TestCreateInstanceWithoutApproversCompletesTask(t *testing.T) {
+	defSvc, svc, ctx := setupFlowApprovalTest(t)
+	db := svc.db
+	require.NoError(t, db.AutoMigrate(&entity.Task{}, &entity.TaskDependency{}, &entity.TaskActionLog{}))
+	svc.SetWorkflowService(NewWorkflowService(db, nil, nil, nil, nil))
+
+	// 小额采购不经过任何审批节点
+	flow, err := json.Marshal(entity.FlowSchema{
+		Nodes: []entity.FlowNode{
+			{ID: "start", Type: "submit", Name: "发起"},
+			{ID: "amount", Type: "condition", Name: "金额判断"},
+			{ID: "cfo", Type: "approve", Name: "财务总监", Config: entity.FlowNodeConfig{ApproverType: ApproverTypeDesignated, ApproverIDs: []string{"cfo"}}},
+			{ID: "end", Type: "end", Name: "结束"},
+		},
+		Edges: []entity.FlowEdge{
+			{From: "start", To: "amount"},
+			{From: "amount", To: "cfo", Name: "大额", Condition: json.RawMessage(`{"field": "amount", "op": "gt", "value": 50000}`)},
+			{From: "amount", To: "end", Name: "其他", Default: true},
+			{From: "cfo", To: "end"},
+		},
+	})
+	require.NoError(t, err)
+	require.NoError(t, db.Create(&entity.ApprovalDefinition{
+		ID: "def2", Code: "PURCHASE_SMALL", Name: "小额采购", FormSchema: json.RawMessage(`[]`), FlowSchema: flow,
+		Status: entity.ApprovalDefStatusPublished, CreatedBy: "u1",
+	}).Error)
+	require.NoError(t, db.Create([]entity.Task{
+		{ID: "t1", ProjectID: "p1", Code: "T1", Title: "采购申请", Status: entity.TaskStatusReviewing, CreatedBy: "u1"},
+		{ID: "t2", ProjectID: "p1", Code: "T2", Title: "来料检验", Status: entity.TaskStatusPending, CreatedBy: "u1"},
+	}).Error)
+	require.NoError(t, db.Create(&entity.TaskDependency{ID: "d1", TaskID: "t2", DependsOnID: "t1", DependencyType: "FS"}).Error)
+
+	approval, err := defSvc.CreateInstance(ctx, "def2", CreateInstanceReq{
+		ProjectID: "p1", TaskID: "t1", FormData: map[string]interface{}{"amount": 100},
+	}, "u1")
+	require.NoError(t, err)
+	assert.Equal(t, entity.PLMApprovalStatusApproved, approval.Status)
+	assert.Empty(t, pendingApprovers(t, db, approval.ID))
+
+	// 与审批通过相同：关联任务完成，依赖它的任务自动开始
+	var tasks []entity.Task
+	require.NoError(t, db.Order("id").Find(&tasks).Error)
+	require.Len(t, tasks, 2)
+	assert.Equal(t, entity.TaskStatusCompleted, tasks[0].Status)
+	assert.Equal(t, entity.TaskStatusInProgress, tasks[1].Status)
+}
@@ -0,0 +1,373 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/bitfantasy/nimo/internal/plm/entity"
+	"gorm.io/gorm"
+)
+
+// =============================================================================
+// 审批人解析
+// =============================================================================
+
+// 审批人类型
+const (
+	ApproverTypeDesignated = "designated"
+	ApproverTypeSelfSelect = "self_select"
+	ApproverTypeSubmitter  = "submitter"
+	ApproverTypeSupervisor = "supervisor"
+	ApproverTypeDeptLeader = "dept_leader"
+	ApproverTypeRole       = "role"
+)
+
+// 角色审批人范围
+const (
+	RoleScopeGlobal  = "global"  // 系统角色（user_roles）
+	RoleScopeProject = "project" // 项目角色分配（project_role_assignments）
+)
+
+// maxOrgDepth 沿汇报关系/部门树向上查找的最大层数，防止数据成环
+const maxOrgDepth = 20
+
+// approverScope 解析审批人所需的实例信息
+type approverScope struct {
+	SubmitterID string
+	ProjectID   string
+	Selected    map[string][]string // node_index(string) -> user_ids（自选审批人）
+}
+
+// instanceScope 审批实例的解析范围（发起人、项目、实例上保存的自选审批人）
+func instanceScope(approval *entity.ApprovalRequest) approverScope {
+	scope := approverScope{SubmitterID: approval.RequestedBy, ProjectID: approval.ProjectID}
+	if len(approval.SelectedApprovers) > 0 {
+		json.Unmarshal(approval.SelectedApprovers, &scope.Selected)
+	}
+	return scope
+}
+
+// approverResolver 按流程节点配置解析审批人
+// 上级取用户的 manager_id（通讯录同步自飞书 leader_user_id），缺失时取所在部门负责人；
+// 部门负责人沿 departments.parent_id 逐级向上；角色取系统角色成员或项目内的角色分配。
+type approverResolver struct {
+	db *gorm.DB
+}
+
+// resolve 解析节点审批人，返回有效（在职、去重）的用户ID及解析记录
+// 解析为空时使用兜底审批人（fallback_approver_ids，兼容旧配置中的 approver_ids）；
+// 仍为空时返回空列表，由调用方决定报错还是跳过节点
+func (r approverResolver) resolve(ctx context.Context, node entity.FlowNode, nodeIndex int, scope approverScope) ([]string, entity.ApproverResolution, error) {
+	cfg := node.Config
+	resolution := entity.ApproverResolution{
+		NodeIndex:    nodeIndex,
+		NodeName:     node.Name,
+		ApproverType: cfg.ApproverType,
+		Source:       entity.ApproverSourceResolved,
+		ResolvedAt:   time.Now(),
+	}
+
+	var candidates []string
+	var detail string
+	switch cfg.ApproverType {
+	case ApproverTypeDesignated, "":
+		candidates, detail = cfg.ApproverIDs, "指定审批人"
+	case ApproverTypeSelfSelect:
+		candidates = scope.Selected[fmt.Sprintf("%d", nodeIndex)]
+		detail = "发起人自选"
+		if len(candidates) == 0 {
+			detail = "发起人未选择审批人"
+		}
+	case ApproverTypeSubmitter:
+		candidates, detail = []string{scope.SubmitterID}, "发起人本人"
+	case ApproverTypeSupervisor:
+		level := cfg.SupervisorLevel
+		if level < 1 {
+			level = 1
+		}
+		id, d, err := r.supervisor(ctx, scope.SubmitterID, level)
+		if err != nil {
+			return nil, resolution, err
+		}
+		if id != "" {
+			candidates = []string{id}
+		}
+		detail = d
+	case ApproverTypeDeptLeader:
+		level := cfg.DeptLevel
+		if level < 1 {
+			level = 1
+		}
+		id, d, err := r.deptLeader(ctx, scope.SubmitterID, level)
+		if err != nil {
+			return nil, resolution, err
+		}
+		if id != "" {
+			candidates = []string{id}
+		}
+		detail = d
+	case ApproverTypeRole:
+		ids, d, err := r.roleMembers(ctx, cfg.RoleCode, cfg.RoleScope, scope.ProjectID)
+		if err != nil {
+			return nil, resolution, err
+		}
+		candidates, detail = ids, d
+	default:
+		return nil, resolution, fmt.Errorf("不支持的审批人类型: %s", cfg.ApproverType)
+	}
+
+	ids, err := r.activeUsers(ctx, candidates)
+	if err != nil {
+		return nil, resolution, err
+	}
+	if len(candidates) > 0 && len(ids) < len(dedupe(candidates)) {
+		detail += "（已排除停用用户）"
+	}
+
+	if len(ids) == 0 {
+		fallback := cfg.FallbackApproverIDs
+		if len(fallback) == 0 && cfg.ApproverType != ApproverTypeDesignated {
+			// 旧配置在 supervisor/dept_leader/role 节点上填写的 approver_ids 作为兜底
+			fallback = cfg.ApproverIDs
+		}
+		if ids, err = r.activeUsers(ctx, fallback); err != nil {
+			return nil, resolution, err
+		}
+		if len(ids) > 0 {
+			resolution.Source = entity.ApproverSourceFallback
+			detail += "，使用兜底审批人"
+		} else {
+			resolution.Source = entity.ApproverSourceEmpty
+			detail += "，且未配置可用的兜底审批人"
+		}
+	}
+
+	resolution.UserIDs = ids
+	resolution.Detail = detail
+	return ids, resolution, nil
+}
+
+// orgUser 解析所需的用户字段
+type orgUser struct {
+	ID           string
+	Name         string
+	ManagerID    string
+	DepartmentID string
+}
+
+// orgDept 解析所需的部门字段
+type orgDept struct {
+	ID       string
+	Name     string
+	ParentID string
+	LeaderID string
+}
+
+func (r approverResolver) findUser(ctx context.Context, id string) (*orgUser, error) {
+	var u orgUser
+	err := r.db.WithContext(ctx).Model(&entity.User{}).
+		Select("id, name, manager_id, department_id").
+		Where("id = ?", id).Take(&u).Error
+	if err == gorm.ErrRecordNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("查询用户失败: %w", err)
+	}
+	return &u, nil
+}
+
+func (r approverResolver) findDept(ctx context.Context, id string) (*orgDept, error) {
+	if id == "" {
+		return nil, nil
+	}
+	var d orgDept
+	err := r.db.WithContext(ctx).Model(&entity.Department{}).
+		Select("id, name, parent_id, leader_id").
+		Where("id = ?", id).Take(&d).Error
+	if err == gorm.ErrRecordNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("查询部门失败: %w", err)
+	}
+	return &d, nil
+}
+
+// managerOf 用户的直属上级：优先 manager_id，其次所在部门（或上级部门）中不是本人的负责人
+func (r approverResolver) managerOf(ctx context.Context, userID string) (string, error) {
+	user, err := r.findUser(ctx, userID)
+	if err != nil || user == nil {
+		return "", err
+	}
+	if user.ManagerID != "" && user.ManagerID != userID {
+		return user.ManagerID, nil
+	}
+	deptID := user.DepartmentID
+	for i := 0; i < maxOrgDepth && deptID != ""; i++ {
+		dept, err := r.findDept(ctx, deptID)
+		if err != nil || dept == nil {
+			return "", err
+		}
+		if dept.LeaderID != "" && dept.LeaderID != userID {
+			return dept.LeaderID, nil
+		}
+		deptID = dept.ParentID
+	}
+	return "", nil
+}
+
+// supervisor 发起人的第 level 级上级
+func (r approverResolver) supervisor(ctx context.Context, submitterID string, level int) (string, string, error) {
+	visited := map[string]bool{submitterID: true}
+	current := submitterID
+	for i := 1; i <= level; i++ {
+		next, err := r.managerOf(ctx, current)
+		if err != nil {
+			return "", "", err
+		}
+		if next == "" {
+			return "", fmt.Sprintf("未找到发起人的第 %d 级上级", i), nil
+		}
+		if visited[next] {
+			return "", fmt.Sprintf("第 %d 级上级的汇报关系存在循环", i), nil
+		}
+		visited[next] = true
+		current = next
+	}
+	if level == 1 {
+		return current, "发起人的直属上级", nil
+	}
+	return current, fmt.Sprintf("发起人的第 %d 级上级", level), nil
+}
+
+// deptLeader 发起人所在部门向上第 level 级部门的负责人
+// 部门未设负责人或负责人是发起人本人时，继续向上取上级部门负责人
+func (r approverResolver) deptLeader(ctx context.Context, submitterID string, level int) (string, string, error) {
+	user, err := r.findUser(ctx, submitterID)
+	if err != nil {
+		return "", "", err
+	}
+	if user == nil || user.DepartmentID == "" {
+		return "", "发起人未归属任何部门", nil
+	}
+
+	dept, err := r.findDept(ctx, user.DepartmentID)
+	if err != nil {
+		return "", "", err
+	}
+	for i := 1; i < level && dept != nil; i++ {
+		if dept, err = r.findDept(ctx, dept.ParentID); err != nil {
+			return "", "", err
+		}
+	}
+	if dept == nil {
+		return "", fmt.Sprintf("未找到发起人的第 %d 级部门", level), nil
+	}
+
+	for i := 0; i < maxOrgDepth && dept != nil; i++ {
+		if dept.LeaderID != "" && dept.LeaderID != submitterID {
+			return dept.LeaderID, fmt.Sprintf("部门[%s]负责人", dept.Name), nil
+		}
+		if dept, err = r.findDept(ctx, dept.ParentID); err != nil {
+			return "", "", err
+		}
+	}
+	return "", "未找到部门负责人", nil
+}
+
+// roleMembers 角色成员：global 取系统角色成员，project 取项目内该角色的分配人
+func (r approverResolver) roleMembers(ctx context.Context, roleCode, roleScope, projectID string) ([]string, string, error) {
+	if roleCode == "" {
+		return nil, "", fmt.Errorf("角色审批节点未配置角色")
+	}
+
+	var ids []string
+	if roleScope == RoleScopeProject {
+		if projectID == "" {
+			return nil, fmt.Sprintf("审批未关联项目，无法按项目角色[%s]解析", roleCode), nil
+		}
+		if err := r.db.WithContext(ctx).Model(&entity.ProjectRoleAssignment{}).
+			Where("project_id = ? AND role_code = ?", projectID, roleCode).
+			Order("assigned_at ASC").
+			Pluck("user_id", &ids).Error; err != nil {
+			return nil, "", fmt.Errorf("查询项目角色成员失败: %w", err)
+		}
+		return ids, fmt.Sprintf("项目角色[%s]成员", roleCode), nil
+	}
+
+	if err := r.db.WithContext(ctx).Table("user_roles AS ur").
+		Joins("JOIN roles r ON r.id = ur.role_id").
+		Where("r.code = ? AND r.status = ?", roleCode, "active").
+		Order("ur.created_at ASC").
+		Pluck("ur.user_id", &ids).Error; err != nil {
+		return nil, "", fmt.Errorf("查询角色成员失败: %w", err)
+	}
+	return ids, fmt.Sprintf("角色[%s]成员", roleCode), nil
+}
+
+// admins 在职的管理员，节点解析和兜底都为空时转交处理
+func (r approverResolver) admins(ctx context.Context) ([]string, error) {
+	var ids []string
+	if err := r.db.WithContext(ctx).Table("user_roles AS ur").
+		Joins("JOIN roles r ON r.id = ur.role_id").
+		Where("r.code IN ?", adminRoleCodes).
+		Order("ur.user_id ASC").
+		Pluck("ur.user_id", &ids).Error; err != nil {
+		return nil, fmt.Errorf("查询管理员失败: %w", err)
+	}
+	return r.activeUsers(ctx, ids)
+}
+
+// activeUsers 去重并过滤掉不存在或已停用的用户，保持原顺序
+func (r approverResolver) activeUsers(ctx context.Context, ids []string) ([]string, error) {
+	ids = dedupe(ids)
+	if len(ids) == 0 {
+		return nil, nil
+	}
+	var active []string
+	if err := r.db.WithContext(ctx).Model(&entity.User{}).
+		Where("id IN ? AND status = ? AND deleted_at IS NULL", ids, "active").
+		Pluck("id", &active).Error; err != nil {
+		return nil, fmt.Errorf("查询审批人失败: %w", err)
+	}
+	ok := make(map[string]bool, len(active))
+	for _, id := range active {
+		ok[id] = true
+	}
+	result := make([]string, 0, len(ids))
+	for _, id := range ids {
+		if ok[id] {
+			result = append(result, id)
+		}
+	}
+	return result, nil
+}
+
+func dedupe(ids []string) []string {
+	seen := make(map[string]bool, len(ids))
+	result := make([]string, 0, len(ids))
+	for _, id := range ids {
+		id = strings.TrimSpace(id)
+		if id == "" || seen[id] {
+			continue
+		}
+		seen[id] = true
+		result = append(result, id)
+	}
+	return result
+}
+
+// appendApproverResolutions 在实例已有的解析记录后追加
+func appendApproverResolutions(raw json.RawMessage, items ...entity.ApproverResolution) json.RawMessage {
+	var all []entity.ApproverResolution
+	if len(raw) > 0 {
+		json.Unmarshal(raw, &all)
+	}
+	all = append(all, items...)
+	data, _ := json.Marshal(all)
+	return data
+}
@@ -0,0 +1,289 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/bitfantasy/nimo/internal/plm/entity"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+// 组织架构：
+//
+//	研发中心 d1（负责人 m3）
+//	└─ 结构部 d2（负责人 l2）
+//	   └─ 结构一组 d3（未设负责人）
+//
+// 汇报关系 u1 → m1 → m2 → m3；c1 ↔ c2 互为上级（数据成环）
+func setupResolverTest(t *testing.T) (*gorm.DB, approverResolver, context.Context) {
+	t.Helper()
+	db := setupServiceTestDB(t, &entity.User{}, &entity.Department{}, &entity.Role{}, &entity.ProjectRoleAssignment{},
+		&entity.ApprovalRequest{}, &entity.ApprovalReviewer{}, &entity.ApprovalBranch{})
+
+	require.NoError(t, db.Create([]entity.Department{
+		{ID: "d1", FeishuDeptID: "fd1", Name: "研发中心", LeaderID: "m3"},
+		{ID: "d2", FeishuDeptID: "fd2", Name: "结构部", ParentID: "d1", LeaderID: "l2"},
+		{ID: "d3", FeishuDeptID: "fd3", Name: "结构一组", ParentID: "d2"},
+	}).Error)
+	users := []entity.User{
+		{ID: "u1", Name: "张工", ManagerID: "m1", DepartmentID: "d3"},
+		{ID: "m1", Name: "组长", ManagerID: "m2", DepartmentID: "d3"},
+		{ID: "m2", Name: "部长", ManagerID: "m3", DepartmentID: "d2"},
+		{ID: "m3", Name: "总监", DepartmentID: "d1"},
+		{ID: "l2", Name: "结构部负责人", DepartmentID: "d2"},
+		{ID: "c1", Name: "甲", ManagerID: "c2"},
+		{ID: "c2", Name: "乙", ManagerID: "c1"},
+		{ID: "q1", Name: "质量甲"},
+		{ID: "q2", Name: "质量乙（停用）", Status: "disabled"},
+		{ID: "g1", Name: "全局质量"},
+		{ID: "f1", Name: "兜底审批人"},
+		{ID: "f2", Name: "停用兜底", Status: "disabled"},
+	}
+	for i := range users {
+		users[i].Username = users[i].ID
+		users[i].FeishuUserID = "fs_" + users[i].ID
+		users[i].Email = users[i].ID + "@example.com"
+		if users[i].Status == "" {
+			users[i].Status = "active"
+		}
+	}
+	require.NoError(t, db.Create(users).Error)
+
+	// users 的 many2many 建出的 user_roles 没有 created_at，补上与线上表一致
+	require.NoError(t, db.Exec("ALTER TABLE user_roles ADD COLUMN created_at DATETIME").Error)
+	require.NoError(t, db.Create(&entity.Role{ID: "r_qa", Code: "qa", Name: "质量", Status: "active"}).Error)
+	require.NoError(t, db.Exec("INSERT INTO user_roles (user_id, role_id) VALUES (?, ?)", "g1", "r_qa").Error)
+	require.NoError(t, db.Create([]entity.ProjectRoleAssignment{
+		{ID: "pa1", ProjectID: "p1", Phase: "evt", RoleCode: "qa", UserID: "q1", AssignedBy: "u1"},
+		{ID: "pa2", ProjectID: "p1", Phase: "evt", RoleCode: "qa", UserID: "q2", AssignedBy: "u1"},
+	}).Error)
+
+	return db, approverResolver{db: db}, context.Background()
+}
+
+func TestApproverResolver(t *testing.T) {
+	_, r, ctx := setupResolverTest(t)
+
+	approve := func(cfg entity.FlowNodeConfig) entity.FlowNode {
+		return entity.FlowNode{Type: "approve", Name: "审批", Config: cfg}
+	}
+	tests := []struct {
+		name       string
+		node       entity.FlowNode
+		scope      approverScope
+		want       []string
+		wantSource string
+		wantDetail string
+	}{
+		{
+			name:       "直属上级",
+			node:       approve(entity.FlowNodeConfig{ApproverType: ApproverTypeSupervisor}),
+			scope:      approverScope{SubmitterID: "u1"},
+			want:       []string{"m1"},
+			wantSource: entity.ApproverSourceResolved,
+			wantDetail: "发起人的直属上级",
+		},
+		{
+			name:       "第 3 级上级",
+			node:       approve(entity.FlowNodeConfig{ApproverType: ApproverTypeSupervisor, SupervisorLevel: 3}),
+			scope:      approverScope{SubmitterID: "u1"},
+			want:       []string{"m3"},
+			wantSource: entity.ApproverSourceResolved,
+			wantDetail: "发起人的第 3 级上级",
+		},
+		{
+			name:       "上级不足 N 级",
+			node:       approve(entity.FlowNodeConfig{ApproverType: ApproverTypeSupervisor, SupervisorLevel: 4}),
+			scope:      approverScope{SubmitterID: "u1"},
+			wantSource: entity.ApproverSourceEmpty,
+			wantDetail: "未找到发起人的第 4 级上级",
+		},
+		{
+			name:       "未设上级时取部门负责人",
+			node:       approve(entity.FlowNodeConfig{ApproverType: ApproverTypeSupervisor}),
+			scope:      approverScope{SubmitterID: "l2"},
+			want:       []string{"m3"},
+			wantSource: entity.ApproverSourceResolved,
+		},
+		{
+			name:       "汇报关系成环",
+			node:       approve(entity.FlowNodeConfig{ApproverType: ApproverTypeSupervisor, SupervisorLevel: 2}),
+			scope:      approverScope{SubmitterID: "c1"},
+			wantSource: entity.ApproverSourceEmpty,
+			wantDetail: "第 2 级上级的汇报关系存在循环",
+		},
+		{
+			name:       "部门未设负责人时向上取",
+			node:       approve(entity.FlowNodeConfig{ApproverType: ApproverTypeDeptLeader}),
+			scope:      approverScope{SubmitterID: "u1"},
+			want:       []string{"l2"},
+			wantSource: entity.ApproverSourceResolved,
+			wantDetail: "部门[结构部]负责人",
+		},
+		{
+			name:       "负责人是发起人本人时向上取",
+			node:       approve(entity.FlowNodeConfig{ApproverType: ApproverTypeDeptLeader}),
+			scope:      approverScope{SubmitterID: "l2"},
+			want:       []string{"m3"},
+			wantSource: entity.ApproverSourceResolved,
+			wantDetail: "部门[研发中心]负责人",
+		},
+		{
+			name:       "第 3 级部门负责人",
+			node:       approve(entity.FlowNodeConfig{ApproverType: ApproverTypeDeptLeader, DeptLevel: 3}),
+			scope:      approverScope{SubmitterID: "u1"},
+			want:       []string{"m3"},
+			wantSource: entity.ApproverSourceResolved,
+		},
+		{
+			name:       "部门层级超出",
+			node:       approve(entity.FlowNodeConfig{ApproverType: ApproverTypeDeptLeader, DeptLevel: 5}),
+			scope:      approverScope{SubmitterID: "u1"},
+			wantSource: entity.ApproverSourceEmpty,
+			wantDetail: "未找到发起人的第 5 级部门",
+		},
+		{
+			name:       "项目角色只取在职成员",
+			node:       approve(entity.FlowNodeConfig{ApproverType: ApproverTypeRole, RoleCode: "qa", RoleScope: RoleScopeProject}),
+			scope:      approverScope{SubmitterID: "u1", ProjectID: "p1"},
+			want:       []string{"q1"},
+			wantSource: entity.ApproverSourceResolved,
+			wantDetail: "项目角色[qa]成员（已排除停用用户）",
+		},
+		{
+			name:       "审批未关联项目时项目角色为空",
+			node:       approve(entity.FlowNodeConfig{ApproverType: ApproverTypeRole, RoleCode: "qa", RoleScope: RoleScopeProject}),
+			scope:      approverScope{SubmitterID: "u1"},
+			wantSource: entity.ApproverSourceEmpty,
+			wantDetail: "审批未关联项目",
+		},
+		{
+			name:       "系统角色",
+			node:       approve(entity.FlowNodeConfig{ApproverType: ApproverTypeRole, RoleCode: "qa"}),
+			scope:      approverScope{SubmitterID: "u1", ProjectID: "p1"},
+			want:       []string{"g1"},
+			wantSource: entity.ApproverSourceResolved,
+			wantDetail: "角色[qa]成员",
+		},
+		{
+			name:       "发起人自选",
+			node:       approve(entity.FlowNodeConfig{ApproverType: ApproverTypeSelfSelect}),
+			scope:      approverScope{SubmitterID: "u1", Selected: map[string][]string{"1": {"q1", "q1", "m2"}}},
+			want:       []string{"q1", "m2"},
+			wantSource: entity.ApproverSourceResolved,
+		},
+		{
+			name:       "解析为空时使用兜底审批人",
+			node:       approve(entity.FlowNodeConfig{ApproverType: ApproverTypeSupervisor, FallbackApproverIDs: []string{"f2", "f1"}}),
+			scope:      approverScope{SubmitterID: "m3"},
+			want:       []string{"f1"},
+			wantSource: entity.ApproverSourceFallback,
+			wantDetail: "，使用兜底审批人",
+		},
+		{
+			name:       "旧配置的 approver_ids 作为兜底",
+			node:       approve(entity.FlowNodeConfig{ApproverType: ApproverTypeDeptLeader, ApproverIDs: []string{"f1"}}),
+			scope:      approverScope{SubmitterID: "c1"},
+			want:       []string{"f1"},
+			wantSource: entity.ApproverSourceFallback,
+		},
+		{
+			name:       "兜底审批人均已停用",
+			node:       approve(entity.FlowNodeConfig{ApproverType: ApproverTypeSupervisor, FallbackApproverIDs: []string{"f2"}}),
+			scope:      approverScope{SubmitterID: "m3"},
+			wantSource: entity.ApproverSourceEmpty,
+			wantDetail: "且未配置可用的兜底审批人",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ids, resolution, err := r.resolve(ctx, tt.node, 1, tt.scope)
+			require.NoError(t, err)
+			if len(tt.want) == 0 {
+				assert.Empty(t, ids)
+			} else {
+				assert.Equal(t, tt.want, ids)
+			}
+			assert.Equal(t, tt.wantSource, resolution.Source)
+			assert.Contains(t, resolution.Detail, tt.wantDetail)
+		})
+	}
+
+	_, _, err := r.resolve(ctx, approve(entity.FlowNodeConfig{ApproverType: ApproverTypeRole}), 1, approverScope{SubmitterID: "u1"})
+	assert.ErrorContains(t, err, "角色审批节点未配置角色")
+	_, _, err = r.resolve(ctx, approve(entity.FlowNodeConfig{ApproverType: "unknown"}), 1, approverScope{SubmitterID: "u1"})
+	assert.ErrorContains(t, err, "不支持的审批人类型")
+}
+
+// 发起 → 主管（上级）→ 自选审批 → 结束
+func resolverTestFlow(t *testing.T) json.RawMessage {
+	t.Helper()
+	flow, err := json.Marshal(entity.FlowSchema{Nodes: []entity.FlowNode{
+		{Type: "submit", Name: "发起"},
+		{Type: "approve", Name: "主管", Config: entity.FlowNodeConfig{ApproverType: ApproverTypeSupervisor}},
+		{Type: "approve", Name: "自选审批", Config: entity.FlowNodeConfig{ApproverType: ApproverTypeSelfSelect}},
+	}})
+	require.NoError(t, err)
+	return flow
+}
+
+func TestApprovalFlowUsesPersistedSelectedApprovers(t *testing.T) {
+	db, _, ctx := setupResolverTest(t)
+	approval := &entity.ApprovalRequest{
+		ID: "a1", ProjectID: "p1", TaskID: "t1", Title: "采购审批", RequestedBy: "u1", Status: entity.PLMApprovalStatusPending,
+		FlowSnapshot: resolverTestFlow(t), SelectedApprovers: json.RawMessage(`{"2": ["q1"]}`),
+	}
+	require.NoError(t, db.Create(approval).Error)
+
+	flow, err := newApprovalFlow(db, approval, instanceScope(approval), true)
+	require.NoError(t, err)
+	require.NoError(t, flow.start(ctx))
+	require.Len(t, flow.reviewers, 1)
+	assert.Equal(t, "m1", flow.reviewers[0].UserID)
+
+	// 流转到自选节点时从实例上恢复发起人的选择
+	flow, err = newApprovalFlow(db, approval, instanceScope(approval), false)
+	require.NoError(t, err)
+	require.NoError(t, flow.advance(ctx, 1))
+	require.Len(t, flow.reviewers, 1)
+	assert.Equal(t, "q1", flow.reviewers[0].UserID)
+	assert.Equal(t, 2, flow.reviewers[0].NodeIndex)
+}
+
+func TestApprovalFlowHoldsEmptyNodeForAdmin(t *testing.T) {
+	db, _, ctx := setupResolverTest(t)
+	approval := &entity.ApprovalRequest{
+		ID: "a1", ProjectID: "p1", TaskID: "t1", Title: "采购审批", RequestedBy: "u1", Status: entity.PLMApprovalStatusPending,
+		FlowSnapshot: resolverTestFlow(t),
+	}
+	require.NoError(t, db.Create(approval).Error)
+	flow, err := newApprovalFlow(db, approval, instanceScope(approval), true)
+	require.NoError(t, err)
+	require.NoError(t, flow.start(ctx))
+
+	// 自选节点没有选择审批人，也没有管理员：报错，不跳过节点
+	flow, err = newApprovalFlow(db, approval, instanceScope(approval), false)
+	require.NoError(t, err)
+	assert.ErrorContains(t, flow.advance(ctx, 1), "审批节点[自选审批]没有审批人且没有可转交的管理员")
+
+	// 有管理员时停在该节点，转交管理员审批
+	require.NoError(t, db.Create(&entity.Role{ID: "r_admin", Code: "plm_admin", Name: "PLM 管理员", Status: "active"}).Error)
+	require.NoError(t, db.Exec("INSERT INTO user_roles (user_id, role_id) VALUES (?, ?)", "m3", "r_admin").Error)
+	flow, err = newApprovalFlow(db, approval, instanceScope(approval), false)
+	require.NoError(t, err)
+	require.NoError(t, flow.advance(ctx, 1))
+	assert.False(t, flow.completed)
+	require.Len(t, flow.reviewers, 1)
+	assert.Equal(t, "m3", flow.reviewers[0].UserID)
+	require.Len(t, flow.resolutions, 1)
+	assert.Equal(t, entity.ApproverSourceAdmin, flow.resolutions[0].Source)
+	assert.Contains(t, flow.resolutions[0].Detail, "转交管理员处理")
+
+	// 发起时同样解析为空则直接报错
+	strict, err := newApprovalFlow(db, &entity.ApprovalRequest{ID: "a2", RequestedBy: "m3", FlowSnapshot: resolverTestFlow(t)}, approverScope{SubmitterID: "m3"}, true)
+	require.NoError(t, err)
+	assert.ErrorContains(t, strict.start(ctx), "审批节点[主管]没有审批人")
+}
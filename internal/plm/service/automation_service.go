@@ -3,336 +3,826 @@ package service
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"log"
+	"regexp"
+	"strings"
 	"time"
 
 	"github.com/bitfantasy/nimo/internal/plm/entity"
-	"github.com/bitfantasy/nimo/internal/plm/repository"
+	"github.com/bitfantasy/nimo/internal/shared/expr"
+	"github.com/bitfantasy/nimo/internal/shared/feishu"
+	"github.com/bitfantasy/nimo/internal/shared/schedule"
 	"github.com/google/uuid"
-	"github.com/redis/go-redis/v9"
-	"go.uber.org/zap"
+	"gorm.io/gorm"
 )
 
-// AutomationService 自动化服务
+// =============================================================================
+// 自动化规则引擎
+//
+// 规则（automation_rules）按作用域匹配项目事件：
+//   - 项目级：project_id = 事件所在项目
+//   - 模板级：template_id = 项目来源模板，project_id 为空
+//   - 全局：project_id、template_id 均为空
+//
+// 同一事件命中的规则按 priority 降序执行；trigger_condition 为共享表达式引擎的
+// JSON 条件（与状态机、智能路由同一语法），可引用 event/task/project/phase 变量。
+// 每次执行（成功/失败/跳过）写入 automation_logs。
+//
+// 规则动作可能再次触发事件（如 UPDATE_STATUS 完成任务），
+// 通过 context 记录嵌套深度，超过 maxAutomationDepth 不再执行，防止规则互相触发死循环。
+// =============================================================================
+
+// maxAutomationDepth 规则动作引发的连锁事件最大深度
+const maxAutomationDepth = 3
+
+type automationDepthKey struct{}
+
+// AutomationService 自动化规则服务
 type AutomationService struct {
-	projectRepo *repository.ProjectRepository
-	rdb         *redis.Client
-	feishuSvc   *FeishuIntegrationService
-	logger      *zap.Logger
+	db           *gorm.DB
+	projectSvc   *ProjectService
+	approvalSvc  *ApprovalService
+	feishuClient *feishu.FeishuClient
 }
 
-// NewAutomationService 创建自动化服务
-func NewAutomationService(projectRepo *repository.ProjectRepository, rdb *redis.Client, feishuSvc *FeishuIntegrationService, logger *zap.Logger) *AutomationService {
-	return &AutomationService{
-		projectRepo: projectRepo,
-		rdb:         rdb,
-		feishuSvc:   feishuSvc,
-		logger:      logger,
-	}
+// NewAutomationService 创建自动化规则服务
+func NewAutomationService(db *gorm.DB) *AutomationService {
+	return &AutomationService{db: db}
 }
 
-// OnTaskCompleted 任务完成时触发
-func (s *AutomationService) OnTaskCompleted(ctx context.Context, taskID string) error {
-	s.logger.Info("Task completed, triggering automation", zap.String("task_id", taskID))
+// SetProjectService 注入项目服务（UPDATE_STATUS / CREATE_TASK 动作）
+func (s *AutomationService) SetProjectService(svc *ProjectService) {
+	s.projectSvc = svc
+}
 
-	// 获取任务信息
-	task, err := s.projectRepo.GetTask(ctx, taskID)
-	if err != nil {
-		return fmt.Errorf("get task: %w", err)
-	}
+// SetApprovalService 注入审批服务（CREATE_APPROVAL 动作）
+func (s *AutomationService) SetApprovalService(svc *ApprovalService) {
+	s.approvalSvc = svc
+}
 
-	// 1. 检查依赖此任务的后续任务
-	dependentTasks, err := s.projectRepo.GetDependentTasks(ctx, taskID)
-	if err != nil {
-		s.logger.Error("Failed to get dependent tasks", zap.Error(err))
-	} else {
-		for _, depTask := range dependentTasks {
-			// 检查该任务的所有前置依赖是否满足
-			allMet, err := s.checkAllDependenciesMet(ctx, depTask.ID)
-			if err != nil {
-				s.logger.Error("Failed to check dependencies", zap.String("task_id", depTask.ID), zap.Error(err))
-				continue
-			}
+// SetFeishuClient 注入飞书客户端（SEND_NOTIFICATION 动作）
+func (s *AutomationService) SetFeishuClient(fc *feishu.FeishuClient) {
+	s.feishuClient = fc
+}
 
-			if allMet {
-				// 更新状态为就绪或进行中
-				newStatus := "ready"
-				if depTask.AutoStart {
-					newStatus = "in_progress"
-				}
+// AutomationEvent 触发自动化的项目事件
+type AutomationEvent struct {
+	Type       string                 `json:"type"` // entity.AutomationTrigger*
+	ProjectID  string                 `json:"project_id"`
+	TaskID     string                 `json:"task_id,omitempty"`
+	PhaseID    string                 `json:"phase_id,omitempty"`
+	FromStatus string                 `json:"from_status,omitempty"`
+	ToStatus   string                 `json:"to_status,omitempty"`
+	OperatorID string                 `json:"operator_id,omitempty"`
+	Data       map[string]interface{} `json:"data,omitempty"`
+}
 
-				if err := s.projectRepo.UpdateTaskStatus(ctx, depTask.ID, newStatus); err != nil {
-					s.logger.Error("Failed to update task status", zap.String("task_id", depTask.ID), zap.Error(err))
-					continue
-				}
+// SaveAutomationRuleRequest 创建/更新规则请求
+type SaveAutomationRuleRequest struct {
+	Name             string          `json:"name"`
+	RuleType         string          `json:"rule_type" binding:"required"`
+	TriggerCondition json.RawMessage `json:"trigger_condition"`
+	ActionType       string          `json:"action_type" binding:"required"`
+	ActionConfig     json.RawMessage `json:"action_config" binding:"required"`
+	IsActive         *bool           `json:"is_active"`
+	Priority         int             `json:"priority"`
+}
 
-				s.logger.Info("Task auto-started", zap.String("task_id", depTask.ID), zap.String("status", newStatus))
+// -----------------------------------------------------------------------------
+// 动作配置
+// -----------------------------------------------------------------------------
 
-				// 发送通知
-				if depTask.AssigneeID != nil && *depTask.AssigneeID != "" {
-					s.sendTaskNotification(ctx, &depTask, "TASK_READY")
-				}
-			}
-		}
-	}
+// updateStatusConfig UPDATE_STATUS 动作配置
+// target: task（触发任务，默认）/ parent（父任务）/ successors（后续任务）/ codes（按任务编码）
+type updateStatusConfig struct {
+	Target    string   `json:"target"`
+	TaskCodes []string `json:"task_codes"`
+	Status    string   `json:"status"`
+}
 
-	// 2. 检查是否所有子任务完成，自动完成父任务
-	if task.ParentTaskID != nil && *task.ParentTaskID != "" {
-		allSubTasksComplete, err := s.checkAllSubTasksComplete(ctx, *task.ParentTaskID)
-		if err != nil {
-			s.logger.Error("Failed to check subtasks", zap.Error(err))
-		} else if allSubTasksComplete {
-			if err := s.projectRepo.UpdateTaskStatus(ctx, *task.ParentTaskID, "completed"); err != nil {
-				s.logger.Error("Failed to complete parent task", zap.Error(err))
-			} else {
-				s.logger.Info("Parent task auto-completed", zap.String("parent_task_id", *task.ParentTaskID))
-				// 递归触发父任务完成
-				s.OnTaskCompleted(ctx, *task.ParentTaskID)
-			}
-		}
-	}
+// notificationConfig SEND_NOTIFICATION 动作配置
+// recipients: assignee / reviewer / manager / operator；title、content 支持 {{task.title}} 形式的变量
+type notificationConfig struct {
+	Recipients []string `json:"recipients"`
+	UserIDs    []string `json:"user_ids"`
+	Title      string   `json:"title"`
+	Content    string   `json:"content"`
+}
 
-	// 3. 检查阶段是否完成
-	if err := s.checkPhaseCompletion(ctx, task.ProjectID); err != nil {
-		s.logger.Error("Failed to check phase completion", zap.Error(err))
-	}
+// createTaskConfig CREATE_TASK 动作配置
+// assignee: assignee / reviewer / manager / 用户ID；depends_on_trigger 为 true 时新任务以触发任务为 FS 前置
+type createTaskConfig struct {
+	Name             string  `json:"name"`
+	Description      string  `json:"description"`
+	TaskType         string  `json:"task_type"`
+	Priority         string  `json:"priority"`
+	Assignee         string  `json:"assignee"`
+	DurationDays     int     `json:"duration_days"`
+	EstimatedHours   float64 `json:"estimated_hours"`
+	DependsOnTrigger bool    `json:"depends_on_trigger"`
+}
 
-	return nil
+// createApprovalConfig CREATE_APPROVAL 动作配置；reviewers 同 notificationConfig.recipients
+type createApprovalConfig struct {
+	Title       string   `json:"title"`
+	Description string   `json:"description"`
+	Type        string   `json:"type"`
+	Reviewers   []string `json:"reviewers"`
+	ReviewerIDs []string `json:"reviewer_ids"`
 }
 
-// checkAllDependenciesMet 检查所有依赖是否满足
-func (s *AutomationService) checkAllDependenciesMet(ctx context.Context, taskID string) (bool, error) {
-	dependencies, err := s.projectRepo.GetTaskDependencies(ctx, taskID)
-	if err != nil {
-		return false, err
-	}
+// errAutomationSkipped 动作条件不满足（如没有接收人），记为 SKIPPED
+type errAutomationSkipped struct{ reason string }
 
-	if len(dependencies) == 0 {
-		return true, nil
-	}
+func (e *errAutomationSkipped) Error() string { return e.reason }
 
-	for _, dep := range dependencies {
-		depTask, err := s.projectRepo.GetTask(ctx, dep.DependsOnID)
-		if err != nil {
-			return false, err
-		}
+func skipped(format string, args ...interface{}) error {
+	return &errAutomationSkipped{reason: fmt.Sprintf(format, args...)}
+}
 
-		switch dep.DependencyType {
-		case "FS": // 完成-开始
-			if depTask.Status != "completed" && depTask.Status != "approved" {
-				return false, nil
-			}
-		case "SS": // 开始-开始
-			if depTask.Status == "pending" || depTask.Status == "ready" {
-				return false, nil
-			}
-		case "FF": // 完成-完成
-			// 允许开始，但完成需要等依赖完成
-		case "SF": // 开始-完成
-			if depTask.Status == "pending" || depTask.Status == "ready" {
-				return false, nil
-			}
-		default:
-			// 默认 FS
-			if depTask.Status != "completed" && depTask.Status != "approved" {
-				return false, nil
-			}
-		}
+// =============================================================================
+// 规则管理
+// =============================================================================
+
+// ListRules 规则列表；projectID、templateID 同时为空时返回全局规则
+func (s *AutomationService) ListRules(ctx context.Context, projectID, templateID, ruleType string) ([]entity.AutomationRule, error) {
+	query := s.db.WithContext(ctx)
+	switch {
+	case projectID != "":
+		query = query.Where("project_id = ?", projectID)
+	case templateID != "":
+		query = query.Where("template_id = ? AND COALESCE(project_id, '') = ''", templateID)
+	default:
+		query = query.Where("COALESCE(project_id, '') = '' AND template_id IS NULL")
+	}
+	if ruleType != "" {
+		query = query.Where("rule_type = ?", ruleType)
 	}
+	var rules []entity.AutomationRule
+	if err := query.Order("priority DESC, created_at").Find(&rules).Error; err != nil {
+		return nil, fmt.Errorf("查询自动化规则失败: %w", err)
+	}
+	return rules, nil
+}
 
-	return true, nil
+// GetRule 规则详情
+func (s *AutomationService) GetRule(ctx context.Context, id string) (*entity.AutomationRule, error) {
+	var rule entity.AutomationRule
+	if err := s.db.WithContext(ctx).Where("id = ?", id).First(&rule).Error; err != nil {
+		return nil, fmt.Errorf("规则不存在")
+	}
+	return &rule, nil
+}
+
+// CreateRule 创建规则；projectID / templateID 决定作用域
+func (s *AutomationService) CreateRule(ctx context.Context, projectID, templateID string, req *SaveAutomationRuleRequest, userID string) (*entity.AutomationRule, error) {
+	if err := validateAutomationRule(req); err != nil {
+		return nil, err
+	}
+	rule := &entity.AutomationRule{
+		ID:               uuid.New().String(),
+		Name:             req.Name,
+		RuleType:         req.RuleType,
+		TriggerCondition: normalizeCondition(req.TriggerCondition),
+		ActionType:       req.ActionType,
+		ActionConfig:     req.ActionConfig,
+		IsActive:         req.IsActive == nil || *req.IsActive,
+		ProjectID:        projectID,
+		Priority:         req.Priority,
+		CreatedBy:        userID,
+	}
+	if templateID != "" {
+		rule.TemplateID = &templateID
+	}
+	if err := s.db.WithContext(ctx).Create(rule).Error; err != nil {
+		return nil, fmt.Errorf("创建自动化规则失败: %w", err)
+	}
+	return rule, nil
 }
 
-// checkAllSubTasksComplete 检查所有子任务是否完成
-func (s *AutomationService) checkAllSubTasksComplete(ctx context.Context, parentTaskID string) (bool, error) {
-	subTasks, err := s.projectRepo.GetSubTasks(ctx, parentTaskID)
+// UpdateRule 更新规则（作用域不变）
+func (s *AutomationService) UpdateRule(ctx context.Context, id string, req *SaveAutomationRuleRequest) (*entity.AutomationRule, error) {
+	rule, err := s.GetRule(ctx, id)
 	if err != nil {
-		return false, err
+		return nil, err
 	}
-
-	if len(subTasks) == 0 {
-		return false, nil // 没有子任务，不自动完成
+	if err := validateAutomationRule(req); err != nil {
+		return nil, err
+	}
+	rule.Name = req.Name
+	rule.RuleType = req.RuleType
+	rule.TriggerCondition = normalizeCondition(req.TriggerCondition)
+	rule.ActionType = req.ActionType
+	rule.ActionConfig = req.ActionConfig
+	rule.Priority = req.Priority
+	if req.IsActive != nil {
+		rule.IsActive = *req.IsActive
 	}
+	if err := s.db.WithContext(ctx).Save(rule).Error; err != nil {
+		return nil, fmt.Errorf("更新自动化规则失败: %w", err)
+	}
+	return rule, nil
+}
 
-	for _, st := range subTasks {
-		if st.Status != "completed" && st.Status != "approved" && st.Status != "cancelled" {
-			return false, nil
-		}
+// DeleteRule 删除规则（保留执行日志）
+func (s *AutomationService) DeleteRule(ctx context.Context, id string) error {
+	result := s.db.WithContext(ctx).Where("id = ?", id).Delete(&entity.AutomationRule{})
+	if result.Error != nil {
+		return fmt.Errorf("删除自动化规则失败: %w", result.Error)
 	}
+	if result.RowsAffected == 0 {
+		return fmt.Errorf("规则不存在")
+	}
+	return nil
+}
 
-	return true, nil
+// ListLogs 执行日志
+func (s *AutomationService) ListLogs(ctx context.Context, projectID, ruleID, status string, page, pageSize int) ([]entity.AutomationLog, int64, error) {
+	query := s.db.WithContext(ctx).Model(&entity.AutomationLog{})
+	if projectID != "" {
+		query = query.Where("project_id = ?", projectID)
+	}
+	if ruleID != "" {
+		query = query.Where("rule_id = ?", ruleID)
+	}
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, fmt.Errorf("查询执行日志失败: %w", err)
+	}
+	var logs []entity.AutomationLog
+	if err := query.Order("executed_at DESC").Offset((page - 1) * pageSize).Limit(pageSize).Find(&logs).Error; err != nil {
+		return nil, 0, fmt.Errorf("查询执行日志失败: %w", err)
+	}
+	return logs, total, nil
 }
 
-// checkPhaseCompletion 检查阶段是否完成
-func (s *AutomationService) checkPhaseCompletion(ctx context.Context, projectID string) error {
-	project, err := s.projectRepo.FindByID(ctx, projectID)
-	if err != nil {
-		return err
+// validTaskStatus 任务状态是否有效
+func validTaskStatus(status string) bool {
+	switch status {
+	case entity.TaskStatusUnassigned, entity.TaskStatusPending, entity.TaskStatusInProgress,
+		entity.TaskStatusSubmitted, entity.TaskStatusReviewing, entity.TaskStatusRejected,
+		entity.TaskStatusCompleted, entity.TaskStatusCancelled:
+		return true
 	}
+	return false
+}
 
-	// 获取当前阶段的所有里程碑任务
-	milestoneTasks, err := s.projectRepo.GetPhaseMilestoneTasks(ctx, projectID, project.Phase)
-	if err != nil {
-		return err
+// validateAutomationRule 校验触发类型、动作类型、条件和动作配置
+func validateAutomationRule(req *SaveAutomationRuleRequest) error {
+	switch req.RuleType {
+	case entity.AutomationTriggerTaskStart, entity.AutomationTriggerTaskComplete,
+		entity.AutomationTriggerOverdue, entity.AutomationTriggerPhaseComplete:
+	default:
+		return fmt.Errorf("不支持的触发类型: %s", req.RuleType)
+	}
+	if err := expr.ValidateCondition(req.TriggerCondition); err != nil {
+		return fmt.Errorf("触发条件无效: %w", err)
 	}
 
-	allMilestonesComplete := true
-	for _, mt := range milestoneTasks {
-		if mt.Status != "completed" && mt.Status != "approved" {
-			allMilestonesComplete = false
-			break
+	var err error
+	switch req.ActionType {
+	case entity.AutomationActionUpdateStatus:
+		var cfg updateStatusConfig
+		if err = json.Unmarshal(req.ActionConfig, &cfg); err == nil {
+			if cfg.Status == "" {
+				return fmt.Errorf("UPDATE_STATUS 需要配置 status")
+			}
+			if !validTaskStatus(cfg.Status) {
+				return fmt.Errorf("UPDATE_STATUS 的 status 不是有效的任务状态: %s", cfg.Status)
+			}
+			if cfg.Target == "codes" && len(cfg.TaskCodes) == 0 {
+				return fmt.Errorf("target 为 codes 时需要配置 task_codes")
+			}
 		}
+	case entity.AutomationActionSendNotification:
+		var cfg notificationConfig
+		if err = json.Unmarshal(req.ActionConfig, &cfg); err == nil && cfg.Content == "" {
+			return fmt.Errorf("SEND_NOTIFICATION 需要配置 content")
+		}
+	case entity.AutomationActionCreateTask:
+		var cfg createTaskConfig
+		if err = json.Unmarshal(req.ActionConfig, &cfg); err == nil && cfg.Name == "" {
+			return fmt.Errorf("CREATE_TASK 需要配置 name")
+		}
+	case entity.AutomationActionCreateApproval:
+		var cfg createApprovalConfig
+		if err = json.Unmarshal(req.ActionConfig, &cfg); err == nil && len(cfg.Reviewers)+len(cfg.ReviewerIDs) == 0 {
+			return fmt.Errorf("CREATE_APPROVAL 需要配置审批人")
+		}
+	default:
+		return fmt.Errorf("不支持的动作类型: %s", req.ActionType)
 	}
-
-	if allMilestonesComplete && len(milestoneTasks) > 0 {
-		s.logger.Info("Phase completed", zap.String("project_id", projectID), zap.String("phase", project.Phase))
-		// 可以触发阶段评审创建等后续动作
+	if err != nil {
+		return fmt.Errorf("动作配置格式错误: %w", err)
 	}
-
 	return nil
 }
 
-// sendTaskNotification 发送任务通知
-func (s *AutomationService) sendTaskNotification(ctx context.Context, task *entity.Task, notificationType string) {
-	if s.feishuSvc == nil || task.AssigneeID == nil || *task.AssigneeID == "" {
-		return
+// normalizeCondition 空条件统一保存为 {}（列非空）
+func normalizeCondition(raw json.RawMessage) json.RawMessage {
+	if len(strings.TrimSpace(string(raw))) == 0 || string(raw) == "null" {
+		return json.RawMessage("{}")
 	}
+	return raw
+}
 
-	var content string
-	switch notificationType {
-	case "TASK_READY":
-		content = fmt.Sprintf("📋 任务就绪通知\n\n任务【%s】的前置任务已完成，现在可以开始工作了。", task.Title)
-	case "TASK_OVERDUE":
-		content = fmt.Sprintf("⚠️ 任务逾期提醒\n\n任务【%s】已逾期，请尽快处理。", task.Title)
-	default:
-		content = fmt.Sprintf("📌 任务通知\n\n任务【%s】状态已更新。", task.Title)
-	}
+// =============================================================================
+// 事件处理
+// =============================================================================
 
-	// 发送飞书消息
-	if err := s.feishuSvc.SendMessage(ctx, *task.AssigneeID, content); err != nil {
-		s.logger.Error("Failed to send notification", zap.Error(err))
+// FireAsync 异步处理事件（不阻断主流程），沿用 ctx 中的嵌套深度
+func (s *AutomationService) FireAsync(ctx context.Context, ev AutomationEvent) {
+	if s == nil {
+		return
 	}
+	depth, _ := ctx.Value(automationDepthKey{}).(int)
+	go func() {
+		bgCtx := context.WithValue(context.Background(), automationDepthKey{}, depth)
+		if _, err := s.Fire(bgCtx, ev); err != nil {
+			log.Printf("[Automation] 处理事件失败 (type=%s project=%s task=%s): %v", ev.Type, ev.ProjectID, ev.TaskID, err)
+		}
+	}()
 }
 
-// StartTask 开始任务
-func (s *AutomationService) StartTask(ctx context.Context, taskID string) error {
-	// 检查依赖是否满足
-	allMet, err := s.checkAllDependenciesMet(ctx, taskID)
-	if err != nil {
-		return fmt.Errorf("check dependencies: %w", err)
+// Fire 按优先级执行命中的规则，返回本次写入的执行日志
+func (s *AutomationService) Fire(ctx context.Context, ev AutomationEvent) ([]entity.AutomationLog, error) {
+	depth, _ := ctx.Value(automationDepthKey{}).(int)
+	if depth >= maxAutomationDepth {
+		log.Printf("[Automation] 连锁触发超过 %d 层，忽略事件 (type=%s task=%s)", maxAutomationDepth, ev.Type, ev.TaskID)
+		return nil, nil
 	}
 
-	if !allMet {
-		return fmt.Errorf("task dependencies not met")
+	var project entity.Project
+	if err := s.db.WithContext(ctx).Where("id = ?", ev.ProjectID).First(&project).Error; err != nil {
+		return nil, fmt.Errorf("查询项目失败: %w", err)
+	}
+	rules, err := s.matchingRules(ctx, &project, ev.Type)
+	if err != nil || len(rules) == 0 {
+		return nil, err
 	}
 
-	// 更新状态
-	if err := s.projectRepo.UpdateTaskStatus(ctx, taskID, "in_progress"); err != nil {
-		return fmt.Errorf("update status: %w", err)
+	var task *entity.Task
+	if ev.TaskID != "" {
+		task = &entity.Task{}
+		if err := s.db.WithContext(ctx).Where("id = ?", ev.TaskID).First(task).Error; err != nil {
+			return nil, fmt.Errorf("查询任务失败: %w", err)
+		}
+		if ev.PhaseID == "" && task.PhaseID != nil {
+			ev.PhaseID = *task.PhaseID
+		}
+	}
+	var phase *entity.ProjectPhase
+	if ev.PhaseID != "" {
+		phase = &entity.ProjectPhase{}
+		if err := s.db.WithContext(ctx).Where("id = ?", ev.PhaseID).First(phase).Error; err != nil {
+			phase = nil
+		}
 	}
+	vars := automationVars(ev, &project, task, phase)
 
-	return nil
+	actionCtx := context.WithValue(ctx, automationDepthKey{}, depth+1)
+	var logs []entity.AutomationLog
+	for i := range rules {
+		rule := &rules[i]
+		if ev.Type == entity.AutomationTriggerOverdue && s.firedToday(ctx, rule.ID, ev.TaskID) {
+			continue
+		}
+		matched, err := expr.EvaluateCondition(rule.TriggerCondition, vars)
+		if err != nil {
+			logs = append(logs, s.writeLog(ctx, rule, ev, nil, fmt.Errorf("触发条件评估失败: %w", err)))
+			continue
+		}
+		if !matched {
+			continue
+		}
+		result, err := s.execute(actionCtx, rule, ev, &project, task, vars)
+		logs = append(logs, s.writeLog(ctx, rule, ev, result, err))
+	}
+	return logs, nil
 }
 
-// CompleteTask 完成任务
-func (s *AutomationService) CompleteTask(ctx context.Context, taskID string, userID string) error {
-	task, err := s.projectRepo.GetTask(ctx, taskID)
+// CheckOverdue 扫描逾期任务并触发 OVERDUE 规则（同一规则对同一任务每天最多执行一次）
+func (s *AutomationService) CheckOverdue(ctx context.Context) (int, error) {
+	if s.projectSvc == nil {
+		return 0, fmt.Errorf("项目服务未初始化")
+	}
+	tasks, err := s.projectSvc.GetOverdueTasks(ctx, "")
 	if err != nil {
-		return fmt.Errorf("get task: %w", err)
+		return 0, fmt.Errorf("查询逾期任务失败: %w", err)
 	}
-
-	// 检查是否需要审批
-	if task.RequiresApproval {
-		// 更新状态为待审批
-		if err := s.projectRepo.UpdateTaskStatus(ctx, taskID, "needs_review"); err != nil {
-			return fmt.Errorf("update status: %w", err)
+	executed := 0
+	for _, t := range tasks {
+		if t.Project == nil || t.Project.DeletedAt != nil ||
+			t.Project.Status == entity.ProjectStatusCompleted || t.Project.Status == entity.ProjectStatusCancelled {
+			continue
+		}
+		logs, err := s.Fire(ctx, AutomationEvent{
+			Type:      entity.AutomationTriggerOverdue,
+			ProjectID: t.ProjectID,
+			TaskID:    t.ID,
+			Data:      map[string]interface{}{"overdue_days": t.OverdueDays},
+		})
+		if err != nil {
+			log.Printf("[Automation] 逾期规则执行失败 (task=%s): %v", t.ID, err)
+			continue
 		}
-		return nil
+		executed += len(logs)
 	}
+	return executed, nil
+}
 
-	// 直接完成
-	now := time.Now()
-	task.Status = "completed"
-	task.CompletedAt = &now
-	task.Progress = 100
-
-	if err := s.projectRepo.UpdateTask(ctx, task); err != nil {
-		return fmt.Errorf("update task: %w", err)
+// matchingRules 项目级、模板级、全局的启用规则，按优先级降序
+func (s *AutomationService) matchingRules(ctx context.Context, project *entity.Project, ruleType string) ([]entity.AutomationRule, error) {
+	query := s.db.WithContext(ctx).Where("is_active = ? AND rule_type = ?", true, ruleType)
+	if project.TemplateID != nil && *project.TemplateID != "" {
+		query = query.Where("project_id = ? OR (COALESCE(project_id, '') = '' AND (template_id = ? OR template_id IS NULL))",
+			project.ID, *project.TemplateID)
+	} else {
+		query = query.Where("project_id = ? OR (COALESCE(project_id, '') = '' AND template_id IS NULL)", project.ID)
+	}
+	var rules []entity.AutomationRule
+	if err := query.Order("priority DESC, created_at").Find(&rules).Error; err != nil {
+		return nil, fmt.Errorf("查询自动化规则失败: %w", err)
 	}
+	return rules, nil
+}
 
-	// 触发自动化
-	go s.OnTaskCompleted(context.Background(), taskID)
+// firedToday 规则今天是否已对该任务执行过
+func (s *AutomationService) firedToday(ctx context.Context, ruleID, taskID string) bool {
+	var count int64
+	y, m, d := time.Now().Date()
+	s.db.WithContext(ctx).Model(&entity.AutomationLog{}).
+		Where("rule_id = ? AND task_id = ? AND executed_at >= ?", ruleID, taskID, time.Date(y, m, d, 0, 0, 0, 0, time.Local)).
+		Count(&count)
+	return count > 0
+}
 
-	return nil
+// writeLog 写执行日志
+func (s *AutomationService) writeLog(ctx context.Context, rule *entity.AutomationRule, ev AutomationEvent, result map[string]interface{}, err error) entity.AutomationLog {
+	ruleID := rule.ID
+	entry := entity.AutomationLog{
+		ID:         uuid.New().String(),
+		RuleID:     &ruleID,
+		RuleType:   rule.RuleType,
+		ProjectID:  ev.ProjectID,
+		TaskID:     ev.TaskID,
+		Status:     entity.AutomationLogSuccess,
+		ExecutedAt: time.Now(),
+	}
+	entry.TriggerEvent, _ = json.Marshal(ev)
+	if result != nil {
+		entry.ActionResult, _ = json.Marshal(result)
+	}
+	var skip *errAutomationSkipped
+	if errors.As(err, &skip) {
+		entry.Status = entity.AutomationLogSkipped
+		entry.ErrorMessage = skip.reason
+	} else if err != nil {
+		entry.Status = entity.AutomationLogFailed
+		entry.ErrorMessage = err.Error()
+	}
+	if dbErr := s.db.WithContext(ctx).Create(&entry).Error; dbErr != nil {
+		log.Printf("[Automation] 写执行日志失败 (rule=%s): %v", rule.ID, dbErr)
+	}
+	return entry
 }
 
-// ApproveTask 审批通过任务
-func (s *AutomationService) ApproveTask(ctx context.Context, taskID string, approverID string, comment string) error {
-	task, err := s.projectRepo.GetTask(ctx, taskID)
-	if err != nil {
-		return fmt.Errorf("get task: %w", err)
+// automationVars 条件与模板变量
+func automationVars(ev AutomationEvent, project *entity.Project, task *entity.Task, phase *entity.ProjectPhase) map[string]interface{} {
+	event := map[string]interface{}{
+		"type":        ev.Type,
+		"from_status": ev.FromStatus,
+		"to_status":   ev.ToStatus,
+		"operator_id": ev.OperatorID,
+	}
+	for k, v := range ev.Data {
+		event[k] = v
+	}
+	vars := map[string]interface{}{
+		"event": event,
+		"project": map[string]interface{}{
+			"id":         project.ID,
+			"code":       project.Code,
+			"name":       project.Name,
+			"status":     project.Status,
+			"phase":      project.Phase,
+			"manager_id": project.ManagerID,
+			"progress":   project.Progress,
+		},
+	}
+	if task != nil {
+		vars["task"] = map[string]interface{}{
+			"id":              task.ID,
+			"code":            task.Code,
+			"title":           task.Title,
+			"status":          task.Status,
+			"task_type":       task.TaskType,
+			"priority":        task.Priority,
+			"assignee_id":     stringOrEmpty(task.AssigneeID),
+			"reviewer_id":     stringOrEmpty(task.ReviewerID),
+			"parent_task_id":  stringOrEmpty(task.ParentTaskID),
+			"progress":        task.Progress,
+			"estimated_hours": task.EstimatedHours,
+			"role":            task.DefaultAssigneeRole,
+		}
+		if task.DueDate != nil {
+			vars["task"].(map[string]interface{})["due_date"] = *task.DueDate
+		}
 	}
-
-	if task.Status != "needs_review" {
-		return fmt.Errorf("task is not in review status")
+	if phase != nil {
+		vars["phase"] = map[string]interface{}{
+			"id":     phase.ID,
+			"phase":  phase.Phase,
+			"name":   phase.Name,
+			"status": phase.Status,
+		}
 	}
+	return vars
+}
 
-	now := time.Now()
-	task.Status = "completed"
-	task.CompletedAt = &now
-	task.Progress = 100
-	task.ApprovalStatus = "APPROVED"
-
-	if err := s.projectRepo.UpdateTask(ctx, task); err != nil {
-		return fmt.Errorf("update task: %w", err)
+func stringOrEmpty(s *string) string {
+	if s == nil {
+		return ""
 	}
+	return *s
+}
 
-	// 记录审批
-	s.logApproval(ctx, taskID, approverID, "APPROVED", comment)
-
-	// 触发自动化
-	go s.OnTaskCompleted(context.Background(), taskID)
-
-	return nil
+// =============================================================================
+// 动作执行
+// =============================================================================
+
+// execute 执行规则动作，返回动作结果
+func (s *AutomationService) execute(ctx context.Context, rule *entity.AutomationRule, ev AutomationEvent, project *entity.Project, task *entity.Task, vars map[string]interface{}) (map[string]interface{}, error) {
+	switch rule.ActionType {
+	case entity.AutomationActionUpdateStatus:
+		var cfg updateStatusConfig
+		if err := json.Unmarshal(rule.ActionConfig, &cfg); err != nil {
+			return nil, fmt.Errorf("动作配置格式错误: %w", err)
+		}
+		return s.updateStatus(ctx, &cfg, project, task)
+	case entity.AutomationActionSendNotification:
+		var cfg notificationConfig
+		if err := json.Unmarshal(rule.ActionConfig, &cfg); err != nil {
+			return nil, fmt.Errorf("动作配置格式错误: %w", err)
+		}
+		return s.sendNotification(ctx, &cfg, ev, project, task, vars)
+	case entity.AutomationActionCreateTask:
+		var cfg createTaskConfig
+		if err := json.Unmarshal(rule.ActionConfig, &cfg); err != nil {
+			return nil, fmt.Errorf("动作配置格式错误: %w", err)
+		}
+		return s.createTask(ctx, &cfg, rule, ev, project, task, vars)
+	case entity.AutomationActionCreateApproval:
+		var cfg createApprovalConfig
+		if err := json.Unmarshal(rule.ActionConfig, &cfg); err != nil {
+			return nil, fmt.Errorf("动作配置格式错误: %w", err)
+		}
+		return s.createApproval(ctx, &cfg, rule, ev, project, task, vars)
+	}
+	return nil, fmt.Errorf("不支持的动作类型: %s", rule.ActionType)
 }
 
-// RejectTask 审批驳回任务
-func (s *AutomationService) RejectTask(ctx context.Context, taskID string, approverID string, comment string) error {
-	task, err := s.projectRepo.GetTask(ctx, taskID)
-	if err != nil {
-		return fmt.Errorf("get task: %w", err)
+// updateStatus UPDATE_STATUS：经项目服务更新状态，下游激活、重排和连锁规则照常触发
+func (s *AutomationService) updateStatus(ctx context.Context, cfg *updateStatusConfig, project *entity.Project, task *entity.Task) (map[string]interface{}, error) {
+	if s.projectSvc == nil {
+		return nil, fmt.Errorf("项目服务未初始化")
+	}
+	var targets []entity.Task
+	query := s.db.WithContext(ctx).Where("project_id = ? AND status <> ?", project.ID, cfg.Status)
+	switch cfg.Target {
+	case "", "task":
+		if task == nil {
+			return nil, skipped("事件没有关联任务")
+		}
+		query = query.Where("id = ?", task.ID)
+	case "parent":
+		if task == nil || task.ParentTaskID == nil {
+			return nil, skipped("触发任务没有父任务")
+		}
+		query = query.Where("id = ?", *task.ParentTaskID)
+	case "successors":
+		if task == nil {
+			return nil, skipped("事件没有关联任务")
+		}
+		query = query.Where("id IN (SELECT task_id FROM task_dependencies WHERE depends_on_task_id = ?)", task.ID)
+	case "codes":
+		query = query.Where("code IN ?", cfg.TaskCodes)
+	default:
+		return nil, fmt.Errorf("不支持的 target: %s", cfg.Target)
+	}
+	if err := query.Find(&targets).Error; err != nil {
+		return nil, fmt.Errorf("查询目标任务失败: %w", err)
+	}
+	if len(targets) == 0 {
+		return nil, skipped("没有需要更新的任务")
 	}
 
-	if task.Status != "needs_review" {
-		return fmt.Errorf("task is not in review status")
+	updated := make([]string, 0, len(targets))
+	for _, t := range targets {
+		if _, err := s.projectSvc.UpdateTaskStatus(ctx, t.ID, cfg.Status); err != nil {
+			return map[string]interface{}{"updated_task_ids": updated}, fmt.Errorf("更新任务[%s]状态失败: %w", t.Code, err)
+		}
+		updated = append(updated, t.ID)
 	}
+	return map[string]interface{}{"status": cfg.Status, "updated_task_ids": updated}, nil
+}
 
-	task.Status = "in_progress"
-	task.ApprovalStatus = "REJECTED"
+// sendNotification SEND_NOTIFICATION：飞书卡片通知
+func (s *AutomationService) sendNotification(ctx context.Context, cfg *notificationConfig, ev AutomationEvent, project *entity.Project, task *entity.Task, vars map[string]interface{}) (map[string]interface{}, error) {
+	if s.feishuClient == nil {
+		return nil, skipped("未配置飞书")
+	}
+	userIDs := resolveAutomationUsers(cfg.Recipients, cfg.UserIDs, ev, project, task)
+	if len(userIDs) == 0 {
+		return nil, skipped("没有通知对象")
+	}
+	var users []entity.User
+	if err := s.db.WithContext(ctx).Where("id IN ?", userIDs).Find(&users).Error; err != nil {
+		return nil, fmt.Errorf("查询通知对象失败: %w", err)
+	}
+
+	title := renderAutomationTemplate(cfg.Title, vars)
+	if title == "" {
+		title = "🤖 项目自动化通知"
+	}
+	card := feishu.InteractiveCard{
+		Config: &feishu.CardConfig{WideScreenMode: true},
+		Header: &feishu.CardHeader{
+			Title:    feishu.CardText{Tag: "plain_text", Content: title},
+			Template: "blue",
+		},
+		Elements: []feishu.CardElement{
+			{Tag: "div", Text: &feishu.CardText{Tag: "lark_md", Content: renderAutomationTemplate(cfg.Content, vars)}},
+		},
+	}
+
+	sent := []string{}
+	var failed []string
+	for _, u := range users {
+		if u.FeishuOpenID == "" {
+			failed = append(failed, u.Name+"(无飞书账号)")
+			continue
+		}
+		if err := s.feishuClient.SendUserCard(ctx, u.FeishuOpenID, card); err != nil {
+			failed = append(failed, u.Name+": "+err.Error())
+			continue
+		}
+		sent = append(sent, u.ID)
+	}
+	result := map[string]interface{}{"sent_to": sent}
+	if len(sent) == 0 {
+		return result, fmt.Errorf("通知发送失败: %s", strings.Join(failed, "; "))
+	}
+	if len(failed) > 0 {
+		result["failed"] = failed
+	}
+	return result, nil
+}
 
-	if err := s.projectRepo.UpdateTask(ctx, task); err != nil {
-		return fmt.Errorf("update task: %w", err)
+// createTask CREATE_TASK：在触发任务/阶段所在阶段创建任务
+func (s *AutomationService) createTask(ctx context.Context, cfg *createTaskConfig, rule *entity.AutomationRule, ev AutomationEvent, project *entity.Project, task *entity.Task, vars map[string]interface{}) (map[string]interface{}, error) {
+	if s.projectSvc == nil {
+		return nil, fmt.Errorf("项目服务未初始化")
+	}
+	start := schedule.Day(time.Now())
+	req := &CreateTaskRequest{
+		Name:           renderAutomationTemplate(cfg.Name, vars),
+		Description:    renderAutomationTemplate(cfg.Description, vars),
+		PhaseID:        ev.PhaseID,
+		TaskType:       cfg.TaskType,
+		Priority:       cfg.Priority,
+		PlannedStart:   &start,
+		DurationDays:   cfg.DurationDays,
+		EstimatedHours: cfg.EstimatedHours,
+	}
+	if cfg.Assignee != "" {
+		if ids := resolveAutomationUsers([]string{cfg.Assignee}, nil, ev, project, task); len(ids) > 0 {
+			req.AssigneeID = ids[0]
+		} else if !isAutomationRecipient(cfg.Assignee) {
+			req.AssigneeID = cfg.Assignee
+		}
+	}
+	creator := rule.CreatedBy
+	if creator == "" {
+		creator = project.ManagerID
 	}
 
-	// 记录审批
-	s.logApproval(ctx, taskID, approverID, "REJECTED", comment)
+	created, err := s.projectSvc.CreateTask(ctx, project.ID, creator, req)
+	if err != nil {
+		return nil, fmt.Errorf("创建任务失败: %w", err)
+	}
+	result := map[string]interface{}{"task_id": created.ID, "task_code": created.Code}
+	if cfg.DependsOnTrigger && task != nil {
+		if _, err := s.projectSvc.AddTaskDependency(ctx, created.ID, task.ID, "FS", 0); err != nil {
+			return result, fmt.Errorf("添加前置依赖失败: %w", err)
+		}
+	}
+	return result, nil
+}
 
-	// 通知负责人
-	if task.AssigneeID != nil && *task.AssigneeID != "" {
-		s.sendTaskNotification(ctx, task, "TASK_REJECTED")
+// createApproval CREATE_APPROVAL：为触发任务发起审批
+func (s *AutomationService) createApproval(ctx context.Context, cfg *createApprovalConfig, rule *entity.AutomationRule, ev AutomationEvent, project *entity.Project, task *entity.Task, vars map[string]interface{}) (map[string]interface{}, error) {
+	if s.approvalSvc == nil {
+		return nil, fmt.Errorf("审批服务未初始化")
+	}
+	if task == nil {
+		return nil, skipped("事件没有关联任务，无法发起审批")
+	}
+	reviewers := resolveAutomationUsers(cfg.Reviewers, cfg.ReviewerIDs, ev, project, task)
+	if len(reviewers) == 0 {
+		return nil, skipped("没有审批人")
+	}
+	title := renderAutomationTemplate(cfg.Title, vars)
+	if title == "" {
+		title = task.Title + " 审批"
+	}
+	approvalType := cfg.Type
+	if approvalType == "" {
+		approvalType = "automation"
+	}
+	requester := rule.CreatedBy
+	if requester == "" {
+		requester = project.ManagerID
+	}
+	approval, err := s.approvalSvc.CreateApproval(ctx, CreateApprovalReq{
+		ProjectID:   project.ID,
+		TaskID:      task.ID,
+		Title:       title,
+		Description: renderAutomationTemplate(cfg.Description, vars),
+		Type:        approvalType,
+		ReviewerIDs: reviewers,
+	}, requester)
+	if err != nil {
+		return nil, fmt.Errorf("发起审批失败: %w", err)
 	}
+	return map[string]interface{}{"approval_id": approval.ID, "reviewer_ids": reviewers}, nil
+}
 
-	return nil
+// automationRecipients 角色型接收人关键字
+var automationRecipients = map[string]bool{"assignee": true, "reviewer": true, "manager": true, "operator": true}
+
+func isAutomationRecipient(key string) bool {
+	return automationRecipients[key]
 }
 
-func (s *AutomationService) logApproval(ctx context.Context, taskID, approverID, status, comment string) {
-	log := &entity.AutomationLog{
-		ID: uuid.New().String(),
-		TriggerEvent: json.RawMessage(fmt.Sprintf(`{"type":"APPROVAL","task_id":"%s","approver":"%s"}`, taskID, approverID)),
-		ActionResult: json.RawMessage(fmt.Sprintf(`{"status":"%s","comment":"%s"}`, status, comment)),
-		Status:       "SUCCESS",
-		ExecutedAt:   time.Now(),
+// resolveAutomationUsers 解析接收人关键字 + 指定用户，去重
+func resolveAutomationUsers(keys, userIDs []string, ev AutomationEvent, project *entity.Project, task *entity.Task) []string {
+	seen := make(map[string]bool)
+	var ids []string
+	add := func(id string) {
+		if id != "" && !seen[id] {
+			seen[id] = true
+			ids = append(ids, id)
+		}
 	}
-	// 存储日志（忽略错误）
-	_ = s.projectRepo.CreateAutomationLog(ctx, log)
+	for _, key := range keys {
+		switch key {
+		case "assignee":
+			if task != nil {
+				add(stringOrEmpty(task.AssigneeID))
+			}
+		case "reviewer":
+			if task != nil {
+				add(stringOrEmpty(task.ReviewerID))
+			}
+		case "manager":
+			add(project.ManagerID)
+		case "operator":
+			add(ev.OperatorID)
+		}
+	}
+	for _, id := range userIDs {
+		add(id)
+	}
+	return ids
+}
+
+var automationPlaceholder = regexp.MustCompile(`\{\{\s*([\w.]+)\s*\}\}`)
+
+// renderAutomationTemplate 替换 {{task.title}} 形式的变量，未知变量替换为空
+func renderAutomationTemplate(tpl string, vars map[string]interface{}) string {
+	return automationPlaceholder.ReplaceAllStringFunc(tpl, func(m string) string {
+		path := strings.Split(automationPlaceholder.FindStringSubmatch(m)[1], ".")
+		var cur interface{} = vars
+		for _, key := range path {
+			obj, ok := cur.(map[string]interface{})
+			if !ok {
+				return ""
+			}
+			cur = obj[key]
+		}
+		switch v := cur.(type) {
+		case nil:
+			return ""
+		case time.Time:
+			return v.Format("2006-01-02")
+		default:
+			return fmt.Sprint(v)
+		}
+	})
 }
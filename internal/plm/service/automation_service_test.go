@@ -0,0 +1,70 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/bitfantasy/nimo/internal/plm/entity"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidateUpdateStatusAction(t *testing.T) {
+	tests := []struct {
+		config string
+		err    string
+	}{
+		{`{"target": "successors", "status": "in_progress"}`, ""},
+		{`{"target": "codes", "task_codes": ["T1"], "status": "completed"}`, ""},
+		{`{"target": "successors"}`, "UPDATE_STATUS 需要配置 status"},
+		{`{"target": "successors", "status": "done"}`, "UPDATE_STATUS 的 status 不是有效的任务状态: done"},
+		{`{"target": "codes", "status": "pending"}`, "target 为 codes 时需要配置 task_codes"},
+	}
+	for _, tt := range tests {
+		err := validateAutomationRule(&SaveAutomationRuleRequest{
+			RuleType:     entity.AutomationTriggerTaskComplete,
+			ActionType:   entity.AutomationActionUpdateStatus,
+			ActionConfig: json.RawMessage(tt.config),
+		})
+		if tt.err == "" {
+			assert.NoError(t, err, tt.config)
+		} else {
+			assert.EqualError(t, err, tt.err, tt.config)
+		}
+	}
+}
+
+func TestMatchingRulesByScope(t *testing.T) {
+	db := setupServiceTestDB(t, &entity.AutomationRule{})
+	tplA, tplB := "tpl-a", "tpl-b"
+	rule := func(id, projectID string, templateID *string, priority int) entity.AutomationRule {
+		return entity.AutomationRule{
+			ID: id, RuleType: entity.AutomationTriggerTaskComplete, IsActive: true,
+			TriggerCondition: json.RawMessage(`{}`), ActionType: entity.AutomationActionSendNotification,
+			ActionConfig: json.RawMessage(`{"content": "x"}`), ProjectID: projectID, TemplateID: templateID, Priority: priority,
+		}
+	}
+	require.NoError(t, db.Create([]entity.AutomationRule{
+		rule("global", "", nil, 0),
+		rule("template-a", "", &tplA, 5),
+		rule("template-b", "", &tplB, 5),
+		rule("project-1", "p1", nil, 10),
+		rule("project-2", "p2", nil, 10),
+	}).Error)
+	svc := NewAutomationService(db)
+
+	ids := func(project *entity.Project) []string {
+		rules, err := svc.matchingRules(context.Background(), project, entity.AutomationTriggerTaskComplete)
+		require.NoError(t, err)
+		var result []string
+		for _, r := range rules {
+			result = append(result, r.ID)
+		}
+		return result
+	}
+
+	// 从模板创建的项目同时匹配来源模板的规则
+	assert.Equal(t, []string{"project-1", "template-a", "global"}, ids(&entity.Project{ID: "p1", TemplateID: &tplA}))
+	assert.Equal(t, []string{"project-1", "global"}, ids(&entity.Project{ID: "p1"}))
+}
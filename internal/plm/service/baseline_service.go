@@ -0,0 +1,462 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/bitfantasy/nimo/internal/plm/entity"
+	"github.com/bitfantasy/nimo/internal/shared/schedule"
+	"github.com/google/uuid"
+	"github.com/xuri/excelize/v2"
+	"gorm.io/gorm"
+)
+
+// =============================================================================
+// 进度基线 & 挣值分析
+//
+// 基线保存任务计划日期和预估工时的快照；项目同一时刻只有一个当前基线。
+// 挣值以工时为单位：BAC 为基线预估工时，PV 按基线计划区间的工作日线性分配，
+// EV = BAC × 进度，AC 为实际工时
+// =============================================================================
+
+// 基线相关的查找失败，处理器据此返回 404
+var (
+	ErrProjectNotFound  = errors.New("项目不存在")
+	ErrBaselineNotFound = errors.New("基线不存在")
+)
+
+// BaselineService 进度基线服务
+type BaselineService struct {
+	db        *gorm.DB
+	calendars *CalendarService
+}
+
+// NewBaselineService 创建进度基线服务
+func NewBaselineService(db *gorm.DB) *BaselineService {
+	return &BaselineService{db: db}
+}
+
+// SetCalendarService 注入工作日历服务（按项目日历计算工作日，未注入时按周一至周五）
+func (s *BaselineService) SetCalendarService(svc *CalendarService) {
+	s.calendars = svc
+}
+
+// CreateBaselineRequest 保存基线请求
+type CreateBaselineRequest struct {
+	Name        string  `json:"name"`
+	Description string  `json:"description"`
+	PhaseID     *string `json:"phase_id"`
+	SetActive   *bool   `json:"set_active"` // 默认设为当前基线
+}
+
+// CreateBaseline 保存项目当前计划为基线
+func (s *BaselineService) CreateBaseline(ctx context.Context, projectID string, req *CreateBaselineRequest, source, userID string) (*entity.ProjectBaseline, error) {
+	var project entity.Project
+	if err := s.db.WithContext(ctx).Where("id = ?", projectID).First(&project).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrProjectNotFound
+		}
+		return nil, fmt.Errorf("查询项目失败: %w", err)
+	}
+
+	var tasks []entity.Task
+	if err := s.db.WithContext(ctx).
+		Where("project_id = ? AND status <> ?", projectID, entity.TaskStatusCancelled).
+		Order("sequence ASC, created_at ASC").
+		Find(&tasks).Error; err != nil {
+		return nil, fmt.Errorf("查询项目任务失败: %w", err)
+	}
+
+	baseline := &entity.ProjectBaseline{
+		ID:           uuid.New().String()[:32],
+		ProjectID:    projectID,
+		Name:         req.Name,
+		Description:  req.Description,
+		Source:       source,
+		PhaseID:      req.PhaseID,
+		Phase:        project.Phase,
+		IsActive:     req.SetActive == nil || *req.SetActive,
+		PlannedStart: project.StartDate,
+		PlannedEnd:   project.PlannedEnd,
+		TaskCount:    len(tasks),
+		CreatedBy:    userID,
+		CreatedAt:    time.Now(),
+	}
+	for _, t := range tasks {
+		baseline.TotalHours += t.EstimatedHours
+		baseline.Tasks = append(baseline.Tasks, entity.TaskBaseline{
+			ID:             uuid.New().String()[:32],
+			BaselineID:     baseline.ID,
+			TaskID:         t.ID,
+			Code:           t.Code,
+			Title:          t.Title,
+			PlannedStart:   t.StartDate,
+			PlannedEnd:     t.DueDate,
+			EstimatedHours: t.EstimatedHours,
+		})
+	}
+
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var count int64
+		if err := tx.Model(&entity.ProjectBaseline{}).Where("project_id = ?", projectID).Count(&count).Error; err != nil {
+			return err
+		}
+		if baseline.Name == "" {
+			baseline.Name = fmt.Sprintf("基线%d", count+1)
+		}
+		if baseline.IsActive {
+			if err := tx.Model(&entity.ProjectBaseline{}).Where("project_id = ? AND is_active = ?", projectID, true).
+				Update("is_active", false).Error; err != nil {
+				return err
+			}
+		}
+		return tx.Create(baseline).Error
+	})
+	if err != nil {
+		return nil, fmt.Errorf("保存基线失败: %w", err)
+	}
+	return baseline, nil
+}
+
+// CaptureForPhaseGate 阶段通过时自动保存基线
+func (s *BaselineService) CaptureForPhaseGate(ctx context.Context, phase *entity.ProjectPhase, userID string) (*entity.ProjectBaseline, error) {
+	return s.CreateBaseline(ctx, phase.ProjectID, &CreateBaselineRequest{
+		Name:        fmt.Sprintf("%s 阶段门基线", phase.Name),
+		Description: fmt.Sprintf("%s 阶段完成时自动保存", phase.Name),
+		PhaseID:     &phase.ID,
+	}, entity.BaselineSourcePhaseGate, userID)
+}
+
+// ListBaselines 项目基线列表（不含任务明细）
+func (s *BaselineService) ListBaselines(ctx context.Context, projectID string) ([]entity.ProjectBaseline, error) {
+	var baselines []entity.ProjectBaseline
+	if err := s.db.WithContext(ctx).Where("project_id = ?", projectID).
+		Order("created_at DESC").Find(&baselines).Error; err != nil {
+		return nil, fmt.Errorf("查询基线失败: %w", err)
+	}
+	return baselines, nil
+}
+
+// GetBaseline 获取基线详情（含任务明细）
+func (s *BaselineService) GetBaseline(ctx context.Context, projectID, baselineID string) (*entity.ProjectBaseline, error) {
+	var baseline entity.ProjectBaseline
+	if err := s.db.WithContext(ctx).Preload("Tasks").
+		Where("id = ? AND project_id = ?", baselineID, projectID).First(&baseline).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrBaselineNotFound
+		}
+		return nil, fmt.Errorf("查询基线失败: %w", err)
+	}
+	return &baseline, nil
+}
+
+// ActivateBaseline 设为当前基线
+func (s *BaselineService) ActivateBaseline(ctx context.Context, projectID, baselineID string) error {
+	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var baseline entity.ProjectBaseline
+		if err := tx.Where("id = ? AND project_id = ?", baselineID, projectID).First(&baseline).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrBaselineNotFound
+			}
+			return fmt.Errorf("查询基线失败: %w", err)
+		}
+		if err := tx.Model(&entity.ProjectBaseline{}).Where("project_id = ?", projectID).
+			Update("is_active", false).Error; err != nil {
+			return fmt.Errorf("更新基线失败: %w", err)
+		}
+		return tx.Model(&baseline).Update("is_active", true).Error
+	})
+}
+
+// DeleteBaseline 删除基线
+func (s *BaselineService) DeleteBaseline(ctx context.Context, projectID, baselineID string) error {
+	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		result := tx.Where("id = ? AND project_id = ?", baselineID, projectID).Delete(&entity.ProjectBaseline{})
+		if result.Error != nil {
+			return fmt.Errorf("删除基线失败: %w", result.Error)
+		}
+		if result.RowsAffected == 0 {
+			return ErrBaselineNotFound
+		}
+		return tx.Where("baseline_id = ?", baselineID).Delete(&entity.TaskBaseline{}).Error
+	})
+}
+
+// activeBaseline 项目当前基线（含任务明细），没有时返回 nil
+func (s *BaselineService) activeBaseline(ctx context.Context, projectID string) (*entity.ProjectBaseline, error) {
+	var baseline entity.ProjectBaseline
+	err := s.db.WithContext(ctx).Preload("Tasks").
+		Where("project_id = ? AND is_active = ?", projectID, true).First(&baseline).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("查询当前基线失败: %w", err)
+	}
+	return &baseline, nil
+}
+
+// FillTaskBaselines 为任务填充当前基线的计划日期
+func (s *BaselineService) FillTaskBaselines(ctx context.Context, projectID string, tasks []entity.Task) error {
+	baseline, err := s.activeBaseline(ctx, projectID)
+	if err != nil || baseline == nil {
+		return err
+	}
+	byTask := make(map[string]*entity.TaskBaseline, len(baseline.Tasks))
+	for i := range baseline.Tasks {
+		byTask[baseline.Tasks[i].TaskID] = &baseline.Tasks[i]
+	}
+	for i := range tasks {
+		if tb, ok := byTask[tasks[i].ID]; ok {
+			tasks[i].BaselineStart = tb.PlannedStart
+			tasks[i].BaselineEnd = tb.PlannedEnd
+		}
+	}
+	return nil
+}
+
+// TaskVariance 任务基线偏差与挣值
+type TaskVariance struct {
+	TaskID         string     `json:"task_id"`
+	Code           string     `json:"code"`
+	Title          string     `json:"title"`
+	Status         string     `json:"status"`
+	Progress       int        `json:"progress"`
+	InBaseline     bool       `json:"in_baseline"` // false 表示基线之后新增的任务
+	BaselineStart  *time.Time `json:"baseline_start"`
+	BaselineEnd    *time.Time `json:"baseline_end"`
+	PlannedStart   *time.Time `json:"planned_start"` // 当前计划
+	PlannedEnd     *time.Time `json:"planned_end"`
+	ActualStart    *time.Time `json:"actual_start"`
+	ActualEnd      *time.Time `json:"actual_end"`
+	StartVariance  *int       `json:"start_variance_days"`  // 实际（未开始取当前计划）开始 - 基线开始，工作日
+	FinishVariance *int       `json:"finish_variance_days"` // 实际（未完成取当前计划）完成 - 基线完成，工作日
+	schedule.EarnedValue
+}
+
+// EarnedValueReport 项目挣值分析报告
+type EarnedValueReport struct {
+	ProjectID   string                  `json:"project_id"`
+	ProjectCode string                  `json:"project_code"`
+	ProjectName string                  `json:"project_name"`
+	Baseline    *entity.ProjectBaseline `json:"baseline"` // 为空表示项目尚无基线，以当前计划代替
+	StatusDate  time.Time               `json:"status_date"`
+	Summary     schedule.EarnedValue    `json:"summary"`
+	Tasks       []TaskVariance          `json:"tasks"`
+}
+
+// GetEarnedValue 计算项目挣值分析；baselineID 为空时取当前基线，statusDate 为零值时取今天
+func (s *BaselineService) GetEarnedValue(ctx context.Context, projectID, baselineID string, statusDate time.Time) (*EarnedValueReport, error) {
+	var project entity.Project
+	if err := s.db.WithContext(ctx).Where("id = ?", projectID).First(&project).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrProjectNotFound
+		}
+		return nil, fmt.Errorf("查询项目失败: %w", err)
+	}
+
+	var baseline *entity.ProjectBaseline
+	var err error
+	if baselineID != "" {
+		baseline, err = s.GetBaseline(ctx, projectID, baselineID)
+	} else {
+		baseline, err = s.activeBaseline(ctx, projectID)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var tasks []entity.Task
+	if err := s.db.WithContext(ctx).
+		Where("project_id = ? AND status <> ?", projectID, entity.TaskStatusCancelled).
+		Order("sequence ASC, created_at ASC").
+		Find(&tasks).Error; err != nil {
+		return nil, fmt.Errorf("查询项目任务失败: %w", err)
+	}
+
+	if statusDate.IsZero() {
+		statusDate = time.Now()
+	}
+	statusDate = schedule.Day(statusDate)
+	cal := s.calendars.ProjectCalendar(ctx, projectID)
+
+	byTask := make(map[string]*entity.TaskBaseline)
+	if baseline != nil {
+		for i := range baseline.Tasks {
+			byTask[baseline.Tasks[i].TaskID] = &baseline.Tasks[i]
+		}
+	}
+
+	report := &EarnedValueReport{
+		ProjectID:   project.ID,
+		ProjectCode: project.Code,
+		ProjectName: project.Name,
+		Baseline:    baseline,
+		StatusDate:  statusDate,
+		Tasks:       make([]TaskVariance, 0, len(tasks)),
+	}
+	for _, t := range tasks {
+		v := TaskVariance{
+			TaskID:       t.ID,
+			Code:         t.Code,
+			Title:        t.Title,
+			Status:       t.Status,
+			Progress:     t.Progress,
+			PlannedStart: t.StartDate,
+			PlannedEnd:   t.DueDate,
+			ActualStart:  t.ActualStart,
+			ActualEnd:    t.CompletedAt,
+		}
+		bac := t.EstimatedHours
+		if tb, ok := byTask[t.ID]; ok {
+			v.InBaseline = true
+			v.BaselineStart, v.BaselineEnd = tb.PlannedStart, tb.PlannedEnd
+			bac = tb.EstimatedHours
+		} else if baseline == nil {
+			v.BaselineStart, v.BaselineEnd = t.StartDate, t.DueDate
+		}
+		v.StartVariance = dateVariance(cal, v.BaselineStart, firstDate(t.ActualStart, t.StartDate))
+		v.FinishVariance = dateVariance(cal, v.BaselineEnd, firstDate(t.CompletedAt, t.DueDate))
+
+		var pv float64
+		if v.BaselineStart != nil && v.BaselineEnd != nil {
+			pv = bac * schedule.PlannedPercent(cal, *v.BaselineStart, *v.BaselineEnd, statusDate)
+		}
+		progress := t.Progress
+		if t.Status == entity.TaskStatusCompleted {
+			progress = 100
+		}
+		v.EarnedValue = schedule.NewEarnedValue(bac, pv, bac*float64(progress)/100, t.ActualHours)
+		report.Summary = report.Summary.Add(v.EarnedValue)
+		v.EarnedValue = roundEarnedValue(v.EarnedValue)
+		report.Tasks = append(report.Tasks, v)
+	}
+	report.Summary = roundEarnedValue(report.Summary)
+	return report, nil
+}
+
+// dateVariance 两个日期间的工作日偏差，任一为空时返回 nil
+func dateVariance(cal schedule.Calendar, baseline, current *time.Time) *int {
+	if baseline == nil || current == nil {
+		return nil
+	}
+	d := schedule.WorkdayDiff(cal, *baseline, *current)
+	return &d
+}
+
+// roundEarnedValue 工时保留两位小数，绩效指数保留三位
+func roundEarnedValue(e schedule.EarnedValue) schedule.EarnedValue {
+	r := func(v float64, places float64) float64 {
+		p := math.Pow(10, places)
+		return math.Round(v*p) / p
+	}
+	return schedule.EarnedValue{
+		BAC: r(e.BAC, 2), PV: r(e.PV, 2), EV: r(e.EV, 2), AC: r(e.AC, 2),
+		SV: r(e.SV, 2), CV: r(e.CV, 2), SPI: r(e.SPI, 3), CPI: r(e.CPI, 3),
+		EAC: r(e.EAC, 2), ETC: r(e.ETC, 2), VAC: r(e.VAC, 2),
+	}
+}
+
+var earnedValueExportHeaders = []string{
+	"任务编码", "任务名称", "状态", "进度(%)",
+	"基线开始", "基线完成", "计划开始", "计划完成", "实际开始", "实际完成",
+	"开始偏差(天)", "完成偏差(天)",
+	"BAC(h)", "PV(h)", "EV(h)", "AC(h)", "SV(h)", "CV(h)", "SPI", "CPI",
+}
+
+// ExportEarnedValue 导出挣值分析为xlsx（汇总 + 任务明细）
+func (s *BaselineService) ExportEarnedValue(ctx context.Context, projectID, baselineID string, statusDate time.Time) (*excelize.File, string, error) {
+	report, err := s.GetEarnedValue(ctx, projectID, baselineID, statusDate)
+	if err != nil {
+		return nil, "", err
+	}
+
+	f := excelize.NewFile()
+	boldStyle, _ := f.NewStyle(&excelize.Style{
+		Font:   &excelize.Font{Bold: true, Size: 11},
+		Fill:   excelize.Fill{Type: "pattern", Pattern: 1, Color: []string{"#D9E1F2"}},
+		Border: []excelize.Border{{Type: "bottom", Color: "000000", Style: 1}},
+	})
+
+	// 汇总
+	summary := "汇总"
+	f.SetSheetName("Sheet1", summary)
+	baselineName := "无（以当前计划代替）"
+	if report.Baseline != nil {
+		baselineName = report.Baseline.Name
+	}
+	sm := report.Summary
+	rows := [][]interface{}{
+		{"项目", fmt.Sprintf("%s %s", report.ProjectCode, report.ProjectName)},
+		{"基线", baselineName},
+		{"状态日", report.StatusDate.Format("2006-01-02")},
+		{"完工预算 BAC(h)", sm.BAC},
+		{"计划值 PV(h)", sm.PV},
+		{"挣值 EV(h)", sm.EV},
+		{"实际成本 AC(h)", sm.AC},
+		{"进度偏差 SV(h)", sm.SV},
+		{"成本偏差 CV(h)", sm.CV},
+		{"进度绩效指数 SPI", sm.SPI},
+		{"成本绩效指数 CPI", sm.CPI},
+		{"完工估算 EAC(h)", sm.EAC},
+		{"完工尚需 ETC(h)", sm.ETC},
+		{"完工偏差 VAC(h)", sm.VAC},
+	}
+	for i, row := range rows {
+		f.SetCellValue(summary, fmt.Sprintf("A%d", i+1), row[0])
+		f.SetCellValue(summary, fmt.Sprintf("B%d", i+1), row[1])
+		f.SetCellStyle(summary, fmt.Sprintf("A%d", i+1), fmt.Sprintf("A%d", i+1), boldStyle)
+	}
+	f.SetColWidth(summary, "A", "A", 20)
+	f.SetColWidth(summary, "B", "B", 36)
+
+	// 任务明细
+	detail := "任务明细"
+	f.NewSheet(detail)
+	for i, h := range earnedValueExportHeaders {
+		col, _ := excelize.ColumnNumberToName(i + 1)
+		f.SetCellValue(detail, col+"1", h)
+		f.SetCellStyle(detail, col+"1", col+"1", boldStyle)
+	}
+	for i, t := range report.Tasks {
+		values := []interface{}{
+			t.Code, t.Title, t.Status, t.Progress,
+			formatDate(t.BaselineStart), formatDate(t.BaselineEnd),
+			formatDate(t.PlannedStart), formatDate(t.PlannedEnd),
+			formatDate(t.ActualStart), formatDate(t.ActualEnd),
+			intOrBlank(t.StartVariance), intOrBlank(t.FinishVariance),
+			t.BAC, t.PV, t.EV, t.AC, t.SV, t.CV, t.SPI, t.CPI,
+		}
+		for j, v := range values {
+			col, _ := excelize.ColumnNumberToName(j + 1)
+			f.SetCellValue(detail, fmt.Sprintf("%s%d", col, i+2), v)
+		}
+	}
+	colWidths := []float64{14, 30, 12, 8, 12, 12, 12, 12, 12, 12, 12, 12, 10, 10, 10, 10, 10, 10, 8, 8}
+	for i, w := range colWidths {
+		col, _ := excelize.ColumnNumberToName(i + 1)
+		f.SetColWidth(detail, col, col, w)
+	}
+
+	filename := fmt.Sprintf("EVM_%s_%s.xlsx", report.ProjectCode, report.StatusDate.Format("20060102"))
+	return f, filename, nil
+}
+
+// formatDate 日期格式化，空值返回空串
+func formatDate(t *time.Time) string {
+	if t == nil {
+		return ""
+	}
+	return t.Format("2006-01-02")
+}
+
+// intOrBlank 可空整数，空值返回空串
+func intOrBlank(v *int) interface{} {
+	if v == nil {
+		return ""
+	}
+	return *v
+}
@@ -0,0 +1,123 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/bitfantasy/nimo/internal/plm/entity"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testDate(s string) *time.Time {
+	t, _ := time.Parse("2006-01-02", s)
+	return &t
+}
+
+func setupBaselineTest(t *testing.T) (*BaselineService, context.Context) {
+	t.Helper()
+	db := setupServiceTestDB(t, &entity.Project{}, &entity.Task{}, &entity.ProjectBaseline{}, &entity.TaskBaseline{})
+	require.NoError(t, db.Create(&entity.Project{
+		ID: "p1", Code: "PRJ-001", Name: "基线测试", Phase: "evt", ManagerID: "u1", CreatedBy: "u1",
+		StartDate: testDate("2026-03-02"), PlannedEnd: testDate("2026-03-13"),
+	}).Error)
+	// 2026-03-02 为周一
+	require.NoError(t, db.Create([]entity.Task{
+		{ID: "t1", ProjectID: "p1", Code: "T1", Title: "结构设计", Status: entity.TaskStatusInProgress, CreatedBy: "u1",
+			StartDate: testDate("2026-03-02"), DueDate: testDate("2026-03-06"), EstimatedHours: 40, ActualHours: 30, Progress: 50, Sequence: 1},
+		{ID: "t2", ProjectID: "p1", Code: "T2", Title: "结构评审", Status: entity.TaskStatusPending, CreatedBy: "u1",
+			StartDate: testDate("2026-03-09"), DueDate: testDate("2026-03-13"), EstimatedHours: 16, Sequence: 2},
+		{ID: "t3", ProjectID: "p1", Code: "T3", Title: "已取消", Status: entity.TaskStatusCancelled, CreatedBy: "u1",
+			EstimatedHours: 8, Sequence: 3},
+	}).Error)
+	return NewBaselineService(db), context.Background()
+}
+
+func TestCreateBaselineCapturesPlan(t *testing.T) {
+	svc, ctx := setupBaselineTest(t)
+
+	first, err := svc.CreateBaseline(ctx, "p1", &CreateBaselineRequest{}, entity.BaselineSourceManual, "u1")
+	require.NoError(t, err)
+	assert.Equal(t, "基线1", first.Name)
+	assert.True(t, first.IsActive)
+	assert.Equal(t, "evt", first.Phase)
+	// 已取消的任务不计入基线
+	assert.Equal(t, 2, first.TaskCount)
+	assert.Equal(t, 56.0, first.TotalHours)
+
+	saved, err := svc.GetBaseline(ctx, "p1", first.ID)
+	require.NoError(t, err)
+	require.Len(t, saved.Tasks, 2)
+	assert.Equal(t, "t1", saved.Tasks[0].TaskID)
+	assert.True(t, testDate("2026-03-06").Equal(*saved.Tasks[0].PlannedEnd))
+
+	// 新基线默认成为当前基线，旧基线取消
+	second, err := svc.CreateBaseline(ctx, "p1", &CreateBaselineRequest{Name: "DVT 基线"}, entity.BaselineSourceManual, "u1")
+	require.NoError(t, err)
+	assert.Equal(t, "DVT 基线", second.Name)
+	active, err := svc.activeBaseline(ctx, "p1")
+	require.NoError(t, err)
+	assert.Equal(t, second.ID, active.ID)
+
+	inactive := false
+	third, err := svc.CreateBaseline(ctx, "p1", &CreateBaselineRequest{SetActive: &inactive}, entity.BaselineSourceManual, "u1")
+	require.NoError(t, err)
+	assert.Equal(t, "基线3", third.Name)
+	active, err = svc.activeBaseline(ctx, "p1")
+	require.NoError(t, err)
+	assert.Equal(t, second.ID, active.ID)
+
+	_, err = svc.CreateBaseline(ctx, "missing", &CreateBaselineRequest{}, entity.BaselineSourceManual, "u1")
+	assert.ErrorIs(t, err, ErrProjectNotFound)
+}
+
+func TestEarnedValueComparesWithBaseline(t *testing.T) {
+	svc, ctx := setupBaselineTest(t)
+	baseline, err := svc.CreateBaseline(ctx, "p1", &CreateBaselineRequest{}, entity.BaselineSourceManual, "u1")
+	require.NoError(t, err)
+
+	// 基线之后：T2 完成日推迟两个工作日、预估工时调整，新增 T4
+	require.NoError(t, svc.db.Model(&entity.Task{}).Where("id = ?", "t2").
+		Updates(map[string]interface{}{"planned_end": testDate("2026-03-17"), "estimated_hours": 24}).Error)
+	require.NoError(t, svc.db.Create(&entity.Task{ID: "t4", ProjectID: "p1", Code: "T4", Title: "补充测试", Status: entity.TaskStatusPending,
+		CreatedBy: "u1", StartDate: testDate("2026-03-16"), DueDate: testDate("2026-03-17"), EstimatedHours: 4, Sequence: 4}).Error)
+
+	report, err := svc.GetEarnedValue(ctx, "p1", "", *testDate("2026-03-06"))
+	require.NoError(t, err)
+	require.NotNil(t, report.Baseline)
+	assert.Equal(t, baseline.ID, report.Baseline.ID)
+	require.Len(t, report.Tasks, 3)
+
+	byID := map[string]TaskVariance{}
+	for _, v := range report.Tasks {
+		byID[v.TaskID] = v
+	}
+
+	t1 := byID["t1"]
+	assert.True(t, t1.InBaseline)
+	assert.Equal(t, 0, *t1.FinishVariance)
+	assert.Equal(t, 40.0, t1.BAC)
+	assert.Equal(t, 40.0, t1.PV) // 状态日为基线完成日，计划工作量全部到期
+	assert.Equal(t, 20.0, t1.EV)
+	assert.Equal(t, 30.0, t1.AC)
+
+	t2 := byID["t2"]
+	assert.True(t, t2.InBaseline)
+	assert.Equal(t, 2, *t2.FinishVariance)
+	assert.Equal(t, 16.0, t2.BAC) // 按基线工时，不随当前计划变化
+	assert.Equal(t, 0.0, t2.PV)
+
+	t4 := byID["t4"]
+	assert.False(t, t4.InBaseline)
+	assert.Nil(t, t4.FinishVariance)
+	assert.Equal(t, 4.0, t4.BAC)
+
+	assert.Equal(t, 60.0, report.Summary.BAC)
+	assert.Equal(t, 20.0, report.Summary.EV)
+
+	_, err = svc.GetEarnedValue(ctx, "p1", "missing", time.Time{})
+	assert.ErrorIs(t, err, ErrBaselineNotFound)
+	_, err = svc.GetEarnedValue(ctx, "missing", "", time.Time{})
+	assert.ErrorIs(t, err, ErrProjectNotFound)
+}
@@ -0,0 +1,552 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bitfantasy/nimo/internal/plm/entity"
+	"github.com/bitfantasy/nimo/internal/shared/engine"
+	"github.com/bitfantasy/nimo/internal/shared/schedule"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// =============================================================================
+// 工作日历 — 公司日历（工作周 + 节假日/调休）与个人例外（请假/加班）
+//
+// 项目使用其指定日历，未指定时使用默认日历；没有任何日历配置时按周一至周五。
+// 方法对 nil 接收者安全，未注入日历服务的调用方按周一至周五计算
+// =============================================================================
+
+// CalendarService 工作日历服务
+type CalendarService struct {
+	db    *gorm.DB
+	cache sync.Map // calendarID → *schedule.OverrideCalendar
+}
+
+// NewCalendarService 创建工作日历服务
+func NewCalendarService(db *gorm.DB) *CalendarService {
+	return &CalendarService{db: db}
+}
+
+// SaveCalendarRequest 创建/更新日历请求
+type SaveCalendarRequest struct {
+	Code        string `json:"code"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	WorkWeek    string `json:"work_week"`
+	IsDefault   *bool  `json:"is_default"`
+}
+
+// CalendarDayInput 设置日历例外日期
+type CalendarDayInput struct {
+	Date      string `json:"date" binding:"required"` // 2006-01-02
+	IsWorkday bool   `json:"is_workday"`
+	Name      string `json:"name"`
+}
+
+// HolidayImportResult 节假日导入结果
+type HolidayImportResult struct {
+	Imported int `json:"imported"`
+	Holidays int `json:"holidays"` // 放假天数
+	Workdays int `json:"workdays"` // 调休上班天数
+}
+
+// CreateUserExceptionRequest 个人日历例外请求
+type CreateUserExceptionRequest struct {
+	UserID    string `json:"user_id" binding:"required"`
+	StartDate string `json:"start_date" binding:"required"`
+	EndDate   string `json:"end_date"`
+	Type      string `json:"type" binding:"required"`
+	Reason    string `json:"reason"`
+}
+
+// CalendarDayInfo 解析后的某天
+type CalendarDayInfo struct {
+	Date      string `json:"date"`
+	IsWorkday bool   `json:"is_workday"`
+	Name      string `json:"name,omitempty"` // 节假日/调休/请假说明
+}
+
+// SeedDefaultCalendar 没有日历时创建默认日历（周一至周五）
+func (s *CalendarService) SeedDefaultCalendar(ctx context.Context) error {
+	var count int64
+	if err := s.db.WithContext(ctx).Model(&entity.WorkCalendar{}).Count(&count).Error; err != nil {
+		return err
+	}
+	if count > 0 {
+		return nil
+	}
+	now := time.Now()
+	return s.db.WithContext(ctx).Create(&entity.WorkCalendar{
+		ID:        uuid.New().String()[:32],
+		Code:      "default",
+		Name:      "公司默认日历",
+		WorkWeek:  "12345",
+		IsDefault: true,
+		CreatedBy: "system",
+		CreatedAt: now,
+		UpdatedAt: now,
+	}).Error
+}
+
+// ListCalendars 日历列表
+func (s *CalendarService) ListCalendars(ctx context.Context) ([]entity.WorkCalendar, error) {
+	var calendars []entity.WorkCalendar
+	if err := s.db.WithContext(ctx).Order("is_default DESC, created_at ASC").Find(&calendars).Error; err != nil {
+		return nil, fmt.Errorf("查询日历失败: %w", err)
+	}
+	return calendars, nil
+}
+
+// GetCalendar 日历详情
+func (s *CalendarService) GetCalendar(ctx context.Context, id string) (*entity.WorkCalendar, error) {
+	var cal entity.WorkCalendar
+	if err := s.db.WithContext(ctx).Where("id = ?", id).First(&cal).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, fmt.Errorf("日历不存在")
+		}
+		return nil, fmt.Errorf("查询日历失败: %w", err)
+	}
+	return &cal, nil
+}
+
+// CreateCalendar 创建日历
+func (s *CalendarService) CreateCalendar(ctx context.Context, req *SaveCalendarRequest, userID string) (*entity.WorkCalendar, error) {
+	if req.Code == "" || req.Name == "" {
+		return nil, fmt.Errorf("日历编码和名称不能为空")
+	}
+	if req.WorkWeek == "" {
+		req.WorkWeek = "12345"
+	}
+	week, err := schedule.ParseWorkWeek(req.WorkWeek)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	cal := &entity.WorkCalendar{
+		ID:          uuid.New().String()[:32],
+		Code:        req.Code,
+		Name:        req.Name,
+		Description: req.Description,
+		WorkWeek:    week.String(),
+		IsDefault:   req.IsDefault != nil && *req.IsDefault,
+		CreatedBy:   userID,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+	err = s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if cal.IsDefault {
+			if err := tx.Model(&entity.WorkCalendar{}).Where("is_default = ?", true).Update("is_default", false).Error; err != nil {
+				return err
+			}
+		}
+		return tx.Create(cal).Error
+	})
+	if err != nil {
+		return nil, fmt.Errorf("创建日历失败: %w", err)
+	}
+	return cal, nil
+}
+
+// UpdateCalendar 更新日历
+func (s *CalendarService) UpdateCalendar(ctx context.Context, id string, req *SaveCalendarRequest) (*entity.WorkCalendar, error) {
+	cal, err := s.GetCalendar(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if req.Code != "" {
+		cal.Code = req.Code
+	}
+	if req.Name != "" {
+		cal.Name = req.Name
+	}
+	if req.Description != "" {
+		cal.Description = req.Description
+	}
+	if req.WorkWeek != "" {
+		week, err := schedule.ParseWorkWeek(req.WorkWeek)
+		if err != nil {
+			return nil, err
+		}
+		cal.WorkWeek = week.String()
+	}
+	if req.IsDefault != nil {
+		cal.IsDefault = *req.IsDefault
+	}
+	cal.UpdatedAt = time.Now()
+
+	err = s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if cal.IsDefault {
+			if err := tx.Model(&entity.WorkCalendar{}).Where("is_default = ? AND id <> ?", true, id).Update("is_default", false).Error; err != nil {
+				return err
+			}
+		}
+		return tx.Save(cal).Error
+	})
+	if err != nil {
+		return nil, fmt.Errorf("更新日历失败: %w", err)
+	}
+	s.cache.Delete(id)
+	return cal, nil
+}
+
+// DeleteCalendar 删除日历（默认日历和被项目使用的日历不可删除）
+func (s *CalendarService) DeleteCalendar(ctx context.Context, id string) error {
+	cal, err := s.GetCalendar(ctx, id)
+	if err != nil {
+		return err
+	}
+	if cal.IsDefault {
+		return fmt.Errorf("默认日历不可删除")
+	}
+	var used int64
+	if err := s.db.WithContext(ctx).Model(&entity.Project{}).Where("calendar_id = ?", id).Count(&used).Error; err != nil {
+		return fmt.Errorf("查询日历使用情况失败: %w", err)
+	}
+	if used > 0 {
+		return fmt.Errorf("日历正被 %d 个项目使用，不可删除", used)
+	}
+	err = s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("calendar_id = ?", id).Delete(&entity.CalendarDay{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&entity.WorkCalendar{}, "id = ?", id).Error
+	})
+	if err != nil {
+		return fmt.Errorf("删除日历失败: %w", err)
+	}
+	s.cache.Delete(id)
+	return nil
+}
+
+// ListDays 日历例外日期；year 为 0 时返回全部
+func (s *CalendarService) ListDays(ctx context.Context, calendarID string, year int) ([]entity.CalendarDay, error) {
+	query := s.db.WithContext(ctx).Where("calendar_id = ?", calendarID)
+	if year > 0 {
+		query = query.Where("date >= ? AND date < ?",
+			time.Date(year, 1, 1, 0, 0, 0, 0, time.UTC), time.Date(year+1, 1, 1, 0, 0, 0, 0, time.UTC))
+	}
+	var days []entity.CalendarDay
+	if err := query.Order("date ASC").Find(&days).Error; err != nil {
+		return nil, fmt.Errorf("查询日历日期失败: %w", err)
+	}
+	return days, nil
+}
+
+// SetDays 批量设置例外日期（同一天已存在时覆盖）
+func (s *CalendarService) SetDays(ctx context.Context, calendarID string, inputs []CalendarDayInput) (int, error) {
+	entries := make([]schedule.HolidayEntry, 0, len(inputs))
+	for _, in := range inputs {
+		d, err := time.Parse("2006-01-02", in.Date)
+		if err != nil {
+			return 0, fmt.Errorf("日期格式错误: %s", in.Date)
+		}
+		entries = append(entries, schedule.HolidayEntry{Date: d, Name: in.Name, IsWorkday: in.IsWorkday})
+	}
+	return s.saveDays(ctx, calendarID, entries, entity.CalendarDaySourceManual)
+}
+
+// DeleteDay 删除例外日期，恢复按工作周计算
+func (s *CalendarService) DeleteDay(ctx context.Context, calendarID, date string) error {
+	d, err := time.Parse("2006-01-02", date)
+	if err != nil {
+		return fmt.Errorf("日期格式错误: %s", date)
+	}
+	if err := s.db.WithContext(ctx).Where("calendar_id = ? AND date = ?", calendarID, d).
+		Delete(&entity.CalendarDay{}).Error; err != nil {
+		return fmt.Errorf("删除日历日期失败: %w", err)
+	}
+	s.cache.Delete(calendarID)
+	return nil
+}
+
+// ImportHolidays 导入节假日文件，按扩展名或内容识别 ICS / JSON
+func (s *CalendarService) ImportHolidays(ctx context.Context, calendarID, filename string, data []byte) (*HolidayImportResult, error) {
+	var entries []schedule.HolidayEntry
+	var source string
+	var err error
+	ext := strings.ToLower(filepath.Ext(filename))
+	if ext == ".ics" || (ext != ".json" && bytes.Contains(data, []byte("BEGIN:VCALENDAR"))) {
+		source = entity.CalendarDaySourceICS
+		entries, err = schedule.ParseICS(bytes.NewReader(data))
+	} else {
+		source = entity.CalendarDaySourceJSON
+		entries, err = schedule.ParseHolidayJSON(data)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(entries) == 0 {
+		return nil, fmt.Errorf("文件中没有节假日数据")
+	}
+
+	n, err := s.saveDays(ctx, calendarID, entries, source)
+	if err != nil {
+		return nil, err
+	}
+	result := &HolidayImportResult{Imported: n}
+	for _, e := range entries {
+		if e.IsWorkday {
+			result.Workdays++
+		} else {
+			result.Holidays++
+		}
+	}
+	return result, nil
+}
+
+// saveDays 写入例外日期
+func (s *CalendarService) saveDays(ctx context.Context, calendarID string, entries []schedule.HolidayEntry, source string) (int, error) {
+	if _, err := s.GetCalendar(ctx, calendarID); err != nil {
+		return 0, err
+	}
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		for _, e := range entries {
+			if err := tx.Where("calendar_id = ? AND date = ?", calendarID, e.Date).Delete(&entity.CalendarDay{}).Error; err != nil {
+				return err
+			}
+			if err := tx.Create(&entity.CalendarDay{
+				ID:         uuid.New().String()[:32],
+				CalendarID: calendarID,
+				Date:       e.Date,
+				IsWorkday:  e.IsWorkday,
+				Name:       e.Name,
+				Source:     source,
+				CreatedAt:  time.Now(),
+			}).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, fmt.Errorf("保存日历日期失败: %w", err)
+	}
+	s.cache.Delete(calendarID)
+	return len(entries), nil
+}
+
+// ListUserExceptions 个人日历例外；from/to 为零值时不限
+func (s *CalendarService) ListUserExceptions(ctx context.Context, userID string, from, to time.Time) ([]entity.UserCalendarException, error) {
+	query := s.db.WithContext(ctx).Preload("User")
+	if userID != "" {
+		query = query.Where("user_id = ?", userID)
+	}
+	if !from.IsZero() {
+		query = query.Where("end_date >= ?", schedule.Day(from))
+	}
+	if !to.IsZero() {
+		query = query.Where("start_date <= ?", schedule.Day(to))
+	}
+	var list []entity.UserCalendarException
+	if err := query.Order("start_date ASC").Find(&list).Error; err != nil {
+		return nil, fmt.Errorf("查询个人日历失败: %w", err)
+	}
+	return list, nil
+}
+
+// CreateUserException 登记请假/出差/加班
+func (s *CalendarService) CreateUserException(ctx context.Context, req *CreateUserExceptionRequest, operatorID string) (*entity.UserCalendarException, error) {
+	var workday bool
+	switch req.Type {
+	case entity.CalendarExceptionLeave, entity.CalendarExceptionTrip:
+	case entity.CalendarExceptionOvertime:
+		workday = true
+	default:
+		return nil, fmt.Errorf("不支持的例外类型: %s", req.Type)
+	}
+	start, err := time.Parse("2006-01-02", req.StartDate)
+	if err != nil {
+		return nil, fmt.Errorf("开始日期格式错误: %s", req.StartDate)
+	}
+	end := start
+	if req.EndDate != "" {
+		if end, err = time.Parse("2006-01-02", req.EndDate); err != nil {
+			return nil, fmt.Errorf("结束日期格式错误: %s", req.EndDate)
+		}
+	}
+	if end.Before(start) {
+		return nil, fmt.Errorf("结束日期不能早于开始日期")
+	}
+
+	exc := &entity.UserCalendarException{
+		ID:        uuid.New().String()[:32],
+		UserID:    req.UserID,
+		StartDate: start,
+		EndDate:   end,
+		Type:      req.Type,
+		IsWorkday: workday,
+		Reason:    req.Reason,
+		CreatedBy: operatorID,
+		CreatedAt: time.Now(),
+	}
+	if err := s.db.WithContext(ctx).Create(exc).Error; err != nil {
+		return nil, fmt.Errorf("保存个人日历失败: %w", err)
+	}
+	return exc, nil
+}
+
+// DeleteUserException 删除个人日历例外
+func (s *CalendarService) DeleteUserException(ctx context.Context, id string) error {
+	result := s.db.WithContext(ctx).Delete(&entity.UserCalendarException{}, "id = ?", id)
+	if result.Error != nil {
+		return fmt.Errorf("删除个人日历失败: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return fmt.Errorf("记录不存在")
+	}
+	return nil
+}
+
+// Calendar 加载日历（带缓存）；calendarID 为空时取默认日历
+func (s *CalendarService) Calendar(ctx context.Context, calendarID string) (schedule.Calendar, error) {
+	if s == nil {
+		return schedule.WeekdayCalendar{}, nil
+	}
+	var cal entity.WorkCalendar
+	query := s.db.WithContext(ctx)
+	if calendarID != "" {
+		if cached, ok := s.cache.Load(calendarID); ok {
+			return cached.(*schedule.OverrideCalendar), nil
+		}
+		query = query.Where("id = ?", calendarID)
+	} else {
+		query = query.Where("is_default = ?", true)
+	}
+	if err := query.First(&cal).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) && calendarID == "" {
+			return schedule.WeekdayCalendar{}, nil
+		}
+		return nil, fmt.Errorf("加载日历失败: %w", err)
+	}
+	if cached, ok := s.cache.Load(cal.ID); ok {
+		return cached.(*schedule.OverrideCalendar), nil
+	}
+
+	week, err := schedule.ParseWorkWeek(cal.WorkWeek)
+	if err != nil {
+		return nil, err
+	}
+	oc := schedule.NewOverrideCalendar(week)
+	days, err := s.ListDays(ctx, cal.ID, 0)
+	if err != nil {
+		return nil, err
+	}
+	for _, d := range days {
+		oc.Set(d.Date, d.IsWorkday)
+	}
+	s.cache.Store(cal.ID, oc)
+	return oc, nil
+}
+
+// EngineCalendar 状态机定时器使用的工作日历：工作日按默认日历（含节假日、调休），每天 09:00-18:00
+func (s *CalendarService) EngineCalendar() *engine.WeekdayCalendar {
+	cal := engine.NewWeekdayCalendar()
+	cal.IsWorkday = func(day time.Time) bool {
+		c, err := s.Calendar(context.Background(), "")
+		if err != nil {
+			return schedule.WeekdayCalendar{}.IsWorkday(day)
+		}
+		return c.IsWorkday(day)
+	}
+	return cal
+}
+
+// ProjectCalendar 项目使用的日历：项目指定日历，否则默认日历；加载失败时按周一至周五
+func (s *CalendarService) ProjectCalendar(ctx context.Context, projectID string) schedule.Calendar {
+	if s == nil {
+		return schedule.WeekdayCalendar{}
+	}
+	var calendarID string
+	s.db.WithContext(ctx).Model(&entity.Project{}).Where("id = ?", projectID).
+		Select("COALESCE(calendar_id, '')").Scan(&calendarID)
+	cal, err := s.Calendar(ctx, calendarID)
+	if err != nil {
+		return schedule.WeekdayCalendar{}
+	}
+	return cal
+}
+
+// UserCalendar 在基础日历上叠加个人请假/加班
+func (s *CalendarService) UserCalendar(ctx context.Context, base schedule.Calendar, userID string) schedule.Calendar {
+	if s == nil || userID == "" {
+		return base
+	}
+	exceptions, err := s.ListUserExceptions(ctx, userID, time.Time{}, time.Time{})
+	if err != nil || len(exceptions) == 0 {
+		return base
+	}
+	oc := schedule.NewOverrideCalendar(base)
+	for _, e := range exceptions {
+		for d := schedule.Day(e.StartDate); !d.After(schedule.Day(e.EndDate)); d = d.AddDate(0, 0, 1) {
+			oc.Set(d, e.IsWorkday)
+		}
+	}
+	return oc
+}
+
+// ResolveDays 解析日期区间内每天是否工作日（可叠加个人例外）
+func (s *CalendarService) ResolveDays(ctx context.Context, calendarID, userID string, from, to time.Time) ([]CalendarDayInfo, error) {
+	from, to = schedule.Day(from), schedule.Day(to)
+	if to.Before(from) || to.Sub(from) > 366*24*time.Hour {
+		return nil, fmt.Errorf("日期区间无效（最长一年）")
+	}
+	cal, err := s.Calendar(ctx, calendarID)
+	if err != nil {
+		return nil, err
+	}
+
+	names := make(map[string]string)
+	var days []entity.CalendarDay
+	query := s.db.WithContext(ctx).Where("date >= ? AND date <= ?", from, to)
+	if calendarID != "" {
+		query = query.Where("calendar_id = ?", calendarID)
+	} else {
+		query = query.Where("calendar_id IN (SELECT id FROM work_calendars WHERE is_default = ?)", true)
+	}
+	if err := query.Find(&days).Error; err != nil {
+		return nil, fmt.Errorf("查询日历日期失败: %w", err)
+	}
+	for _, d := range days {
+		names[schedule.Day(d.Date).Format("2006-01-02")] = d.Name
+	}
+	if userID != "" {
+		exceptions, err := s.ListUserExceptions(ctx, userID, from, to)
+		if err != nil {
+			return nil, err
+		}
+		for _, e := range exceptions {
+			for d := schedule.Day(e.StartDate); !d.After(schedule.Day(e.EndDate)); d = d.AddDate(0, 0, 1) {
+				names[d.Format("2006-01-02")] = userExceptionLabel(e)
+			}
+		}
+		cal = s.UserCalendar(ctx, cal, userID)
+	}
+
+	result := make([]CalendarDayInfo, 0, int(to.Sub(from).Hours()/24)+1)
+	for d := from; !d.After(to); d = d.AddDate(0, 0, 1) {
+		key := d.Format("2006-01-02")
+		result = append(result, CalendarDayInfo{Date: key, IsWorkday: cal.IsWorkday(d), Name: names[key]})
+	}
+	return result, nil
+}
+
+// userExceptionLabel 个人例外说明
+func userExceptionLabel(e entity.UserCalendarException) string {
+	label := map[string]string{
+		entity.CalendarExceptionLeave:    "请假",
+		entity.CalendarExceptionTrip:     "出差",
+		entity.CalendarExceptionOvertime: "加班",
+	}[e.Type]
+	if e.Reason != "" {
+		label += ": " + e.Reason
+	}
+	return label
+}
@@ -0,0 +1,330 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/bitfantasy/nimo/internal/plm/entity"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// ChecklistService 任务检查项服务
+// 项目创建时把模板任务的 Checklist 实例化为任务检查项；必检项未全部勾选时任务不能完成。
+type ChecklistService struct {
+	db *gorm.DB
+}
+
+// NewChecklistService 创建任务检查项服务
+func NewChecklistService(db *gorm.DB) *ChecklistService {
+	return &ChecklistService{db: db}
+}
+
+// ChecklistIncompleteError 必检项未完成，任务不能完成
+type ChecklistIncompleteError struct {
+	Open []entity.TaskChecklistItem `json:"open"`
+}
+
+func (e *ChecklistIncompleteError) Error() string {
+	titles := make([]string, 0, len(e.Open))
+	for _, item := range e.Open {
+		titles = append(titles, item.Title)
+	}
+	return fmt.Sprintf("还有 %d 项必检项未勾选: %s", len(e.Open), strings.Join(titles, "、"))
+}
+
+// ParseChecklistDefs 解析模板任务检查项定义
+// 元素可以是字符串（必检项），也可以是对象 {"title", "description", "is_mandatory"}；
+// 对象未指定 is_mandatory/required 时视为必检项
+func ParseChecklistDefs(raw json.RawMessage) ([]entity.ChecklistItemDef, error) {
+	trimmed := strings.TrimSpace(string(raw))
+	if trimmed == "" || trimmed == "null" {
+		return nil, nil
+	}
+	var items []interface{}
+	if err := json.Unmarshal(raw, &items); err != nil {
+		return nil, fmt.Errorf("检查项必须是数组: %w", err)
+	}
+
+	defs := make([]entity.ChecklistItemDef, 0, len(items))
+	for i, item := range items {
+		def := entity.ChecklistItemDef{IsMandatory: true}
+		switch x := item.(type) {
+		case string:
+			def.Title = x
+		case map[string]interface{}:
+			for _, k := range []string{"title", "name", "content"} {
+				if s, ok := x[k].(string); ok && s != "" {
+					def.Title = s
+					break
+				}
+			}
+			def.Description, _ = x["description"].(string)
+			for _, k := range []string{"is_mandatory", "required", "mandatory"} {
+				if b, ok := x[k].(bool); ok {
+					def.IsMandatory = b
+					break
+				}
+			}
+		default:
+			return nil, fmt.Errorf("第 %d 个检查项格式不正确", i+1)
+		}
+		def.Title = strings.TrimSpace(def.Title)
+		if def.Title == "" {
+			return nil, fmt.Errorf("第 %d 个检查项缺少标题", i+1)
+		}
+		defs = append(defs, def)
+	}
+	return defs, nil
+}
+
+// InstantiateTemplateChecklists 从模板创建项目时为每个任务生成检查项
+// taskMap: task_code -> 项目任务ID
+func (s *ChecklistService) InstantiateTemplateChecklists(ctx context.Context, projectID, createdBy string, tasks []entity.TemplateTask, taskMap map[string]string) error {
+	if s == nil {
+		return nil
+	}
+	now := time.Now()
+	var items []entity.TaskChecklistItem
+	for _, tt := range tasks {
+		taskID, ok := taskMap[tt.TaskCode]
+		if !ok {
+			continue
+		}
+		defs, err := ParseChecklistDefs(tt.Checklist)
+		if err != nil {
+			return fmt.Errorf("任务 %s 的检查项无效: %w", tt.TaskCode, err)
+		}
+		for i, def := range defs {
+			items = append(items, entity.TaskChecklistItem{
+				ID:          uuid.New().String()[:32],
+				ProjectID:   projectID,
+				TaskID:      taskID,
+				Title:       def.Title,
+				Description: def.Description,
+				IsMandatory: def.IsMandatory,
+				SortOrder:   i,
+				Source:      entity.ChecklistSourceTemplate,
+				CreatedBy:   createdBy,
+				CreatedAt:   now,
+				UpdatedAt:   now,
+			})
+		}
+	}
+	if len(items) == 0 {
+		return nil
+	}
+	if err := s.db.WithContext(ctx).CreateInBatches(items, 200).Error; err != nil {
+		return fmt.Errorf("创建任务检查项失败: %w", err)
+	}
+	return nil
+}
+
+// ListTaskItems 任务检查项列表
+func (s *ChecklistService) ListTaskItems(ctx context.Context, taskID string) ([]entity.TaskChecklistItem, error) {
+	var items []entity.TaskChecklistItem
+	if err := s.db.WithContext(ctx).Preload("Checker").
+		Where("task_id = ?", taskID).
+		Order("sort_order ASC, created_at ASC").
+		Find(&items).Error; err != nil {
+		return nil, fmt.Errorf("查询任务检查项失败: %w", err)
+	}
+	return items, nil
+}
+
+// SaveChecklistItemRequest 添加检查项请求
+type SaveChecklistItemRequest struct {
+	Title       string `json:"title" binding:"required"`
+	Description string `json:"description"`
+	IsMandatory *bool  `json:"is_mandatory"` // 默认必检
+}
+
+// AddItem 为任务手动添加检查项
+func (s *ChecklistService) AddItem(ctx context.Context, projectID, taskID string, req *SaveChecklistItemRequest, userID string) (*entity.TaskChecklistItem, error) {
+	if err := s.editableTask(ctx, projectID, taskID); err != nil {
+		return nil, err
+	}
+
+	var maxSort int
+	s.db.WithContext(ctx).Model(&entity.TaskChecklistItem{}).
+		Where("task_id = ?", taskID).
+		Select("COALESCE(MAX(sort_order), -1)").Scan(&maxSort)
+
+	now := time.Now()
+	item := &entity.TaskChecklistItem{
+		ID:          uuid.New().String()[:32],
+		ProjectID:   projectID,
+		TaskID:      taskID,
+		Title:       strings.TrimSpace(req.Title),
+		Description: req.Description,
+		IsMandatory: req.IsMandatory == nil || *req.IsMandatory,
+		SortOrder:   maxSort + 1,
+		Source:      entity.ChecklistSourceManual,
+		CreatedBy:   userID,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+	if item.Title == "" {
+		return nil, fmt.Errorf("检查项标题不能为空")
+	}
+	if err := s.db.WithContext(ctx).Create(item).Error; err != nil {
+		return nil, fmt.Errorf("创建检查项失败: %w", err)
+	}
+	return item, nil
+}
+
+// ErrChecklistDeleteForbidden 非项目经理或管理员删除模板检查项
+var ErrChecklistDeleteForbidden = errors.New("模板检查项只有项目经理或管理员可以删除")
+
+// DeleteItem 删除检查项：手动添加的检查项可直接删除，模板实例化的检查项需项目经理或管理员
+func (s *ChecklistService) DeleteItem(ctx context.Context, projectID, taskID, itemID, userID string) error {
+	if err := s.editableTask(ctx, projectID, taskID); err != nil {
+		return err
+	}
+
+	var item entity.TaskChecklistItem
+	if err := s.db.WithContext(ctx).Where("id = ? AND task_id = ?", itemID, taskID).First(&item).Error; err != nil {
+		return fmt.Errorf("检查项不存在")
+	}
+	if item.Source != entity.ChecklistSourceManual {
+		ok, err := isProjectManagerOrAdmin(ctx, s.db, projectID, userID)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return ErrChecklistDeleteForbidden
+		}
+	}
+
+	if err := s.db.WithContext(ctx).Delete(&item).Error; err != nil {
+		return fmt.Errorf("删除检查项失败: %w", err)
+	}
+	return nil
+}
+
+// CheckItem 勾选或取消勾选检查项，记录勾选人和时间
+func (s *ChecklistService) CheckItem(ctx context.Context, projectID, taskID, itemID, userID string, checked bool, note string) (*entity.TaskChecklistItem, error) {
+	if err := s.editableTask(ctx, projectID, taskID); err != nil {
+		return nil, err
+	}
+
+	var item entity.TaskChecklistItem
+	if err := s.db.WithContext(ctx).Where("id = ? AND task_id = ?", itemID, taskID).First(&item).Error; err != nil {
+		return nil, fmt.Errorf("检查项不存在")
+	}
+
+	now := time.Now()
+	updates := map[string]interface{}{
+		"is_checked": checked,
+		"note":       note,
+		"updated_at": now,
+	}
+	if checked {
+		updates["checked_by"] = userID
+		updates["checked_at"] = now
+	} else {
+		updates["checked_by"] = nil
+		updates["checked_at"] = nil
+	}
+	if err := s.db.WithContext(ctx).Model(&item).Updates(updates).Error; err != nil {
+		return nil, fmt.Errorf("更新检查项失败: %w", err)
+	}
+
+	if err := s.db.WithContext(ctx).Preload("Checker").First(&item, "id = ?", itemID).Error; err != nil {
+		return nil, fmt.Errorf("查询检查项失败: %w", err)
+	}
+	return &item, nil
+}
+
+// editableTask 检查项只能在任务提交前修改
+func (s *ChecklistService) editableTask(ctx context.Context, projectID, taskID string) error {
+	var task entity.Task
+	if err := s.db.WithContext(ctx).First(&task, "id = ?", taskID).Error; err != nil {
+		return fmt.Errorf("任务不存在")
+	}
+	if task.ProjectID != projectID {
+		return fmt.Errorf("任务不属于该项目")
+	}
+	if task.Status != entity.TaskStatusPending && task.Status != entity.TaskStatusInProgress {
+		return fmt.Errorf("任务当前状态[%s]不允许修改检查项", task.Status)
+	}
+	return nil
+}
+
+// EnsureComplete 必检项须全部勾选，否则返回 *ChecklistIncompleteError
+func (s *ChecklistService) EnsureComplete(ctx context.Context, taskID string) error {
+	if s == nil {
+		return nil
+	}
+	var open []entity.TaskChecklistItem
+	if err := s.db.WithContext(ctx).
+		Where("task_id = ? AND is_mandatory = ? AND is_checked = ?", taskID, true, false).
+		Order("sort_order ASC, created_at ASC").
+		Find(&open).Error; err != nil {
+		return fmt.Errorf("查询任务检查项失败: %w", err)
+	}
+	if len(open) > 0 {
+		return &ChecklistIncompleteError{Open: open}
+	}
+	return nil
+}
+
+// TaskChecklistSummary 单个任务的检查项完成情况
+type TaskChecklistSummary struct {
+	TaskID         string `json:"task_id"`
+	TaskCode       string `json:"task_code"`
+	TaskTitle      string `json:"task_title"`
+	TaskStatus     string `json:"task_status"`
+	Total          int    `json:"total"`
+	Checked        int    `json:"checked"`
+	MandatoryTotal int    `json:"mandatory_total"`
+	MandatoryOpen  int    `json:"mandatory_open"`
+}
+
+// ProjectChecklistSummary 项目检查项完成情况
+type ProjectChecklistSummary struct {
+	ProjectID      string                 `json:"project_id"`
+	Total          int                    `json:"total"`
+	Checked        int                    `json:"checked"`
+	MandatoryTotal int                    `json:"mandatory_total"`
+	MandatoryOpen  int                    `json:"mandatory_open"`
+	CompletionRate int                    `json:"completion_rate"` // 已勾选占比（百分比）
+	Tasks          []TaskChecklistSummary `json:"tasks"`
+}
+
+// ProjectSummary 统计项目检查项完成情况（按任务顺序列出有检查项的任务）
+func (s *ChecklistService) ProjectSummary(ctx context.Context, projectID string) (*ProjectChecklistSummary, error) {
+	var rows []TaskChecklistSummary
+	if err := s.db.WithContext(ctx).Table("task_checklist_items AS i").
+		Select(`i.task_id, t.code AS task_code, t.name AS task_title, t.status AS task_status,
+			COUNT(*) AS total,
+			SUM(CASE WHEN i.is_checked THEN 1 ELSE 0 END) AS checked,
+			SUM(CASE WHEN i.is_mandatory THEN 1 ELSE 0 END) AS mandatory_total,
+			SUM(CASE WHEN i.is_mandatory AND NOT i.is_checked THEN 1 ELSE 0 END) AS mandatory_open`).
+		Joins("JOIN tasks t ON t.id = i.task_id").
+		Where("i.project_id = ?", projectID).
+		Group("i.task_id, t.code, t.name, t.status, t.sequence").
+		Order("t.sequence ASC, t.code ASC").
+		Scan(&rows).Error; err != nil {
+		return nil, fmt.Errorf("统计项目检查项失败: %w", err)
+	}
+
+	summary := &ProjectChecklistSummary{ProjectID: projectID, Tasks: rows}
+	if summary.Tasks == nil {
+		summary.Tasks = []TaskChecklistSummary{}
+	}
+	for _, r := range rows {
+		summary.Total += r.Total
+		summary.Checked += r.Checked
+		summary.MandatoryTotal += r.MandatoryTotal
+		summary.MandatoryOpen += r.MandatoryOpen
+	}
+	if summary.Total > 0 {
+		summary.CompletionRate = summary.Checked * 100 / summary.Total
+	}
+	return summary, nil
+}
@@ -0,0 +1,137 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/bitfantasy/nimo/internal/plm/entity"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseChecklistDefs(t *testing.T) {
+	tests := []struct {
+		name    string
+		raw     string
+		want    []entity.ChecklistItemDef
+		wantErr string
+	}{
+		{name: "空", raw: ``},
+		{name: "null", raw: `null`},
+		{
+			name: "字符串均为必检项",
+			raw:  `["外观检查", " 跌落测试 "]`,
+			want: []entity.ChecklistItemDef{
+				{Title: "外观检查", IsMandatory: true},
+				{Title: "跌落测试", IsMandatory: true},
+			},
+		},
+		{
+			name: "对象按 title/name/content 取标题，可标记非必检",
+			raw: `[{"title": "图纸评审", "description": "结构组确认"},
+				{"name": "BOM 核对", "is_mandatory": false},
+				{"content": "样机拍照", "required": false},
+				{"title": "包装确认", "mandatory": true}]`,
+			want: []entity.ChecklistItemDef{
+				{Title: "图纸评审", Description: "结构组确认", IsMandatory: true},
+				{Title: "BOM 核对", IsMandatory: false},
+				{Title: "样机拍照", IsMandatory: false},
+				{Title: "包装确认", IsMandatory: true},
+			},
+		},
+		{name: "不是数组", raw: `{"title": "x"}`, wantErr: "检查项必须是数组"},
+		{name: "元素格式错误", raw: `["a", 1]`, wantErr: "第 2 个检查项格式不正确"},
+		{name: "缺少标题", raw: `[{"description": "x"}]`, wantErr: "第 1 个检查项缺少标题"},
+		{name: "标题为空白", raw: `["  "]`, wantErr: "第 1 个检查项缺少标题"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defs, err := ParseChecklistDefs(json.RawMessage(tt.raw))
+			if tt.wantErr != "" {
+				assert.ErrorContains(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, defs)
+		})
+	}
+}
+
+func setupChecklistTest(t *testing.T) (*ChecklistService, context.Context) {
+	t.Helper()
+	db := setupServiceTestDB(t, &entity.Project{}, &entity.Task{}, &entity.TaskChecklistItem{}, &entity.User{}, &entity.Role{})
+	require.NoError(t, db.Create(&entity.Project{
+		ID: "p1", Code: "PRJ-001", Name: "检查项测试", Phase: "evt", ManagerID: "pm", CreatedBy: "pm",
+	}).Error)
+	require.NoError(t, db.Create(&entity.Task{
+		ID: "t1", ProjectID: "p1", Code: "T1", Title: "结构设计", Status: entity.TaskStatusInProgress, CreatedBy: "pm",
+	}).Error)
+	svc := NewChecklistService(db)
+	require.NoError(t, svc.InstantiateTemplateChecklists(context.Background(), "p1", "pm",
+		[]entity.TemplateTask{{TaskCode: "T1", Checklist: json.RawMessage(`["图纸评审", {"title": "拍照存档", "is_mandatory": false}]`)}},
+		map[string]string{"T1": "t1"}))
+	return svc, context.Background()
+}
+
+func TestEnsureComplete(t *testing.T) {
+	svc, ctx := setupChecklistTest(t)
+	items, err := svc.ListTaskItems(ctx, "t1")
+	require.NoError(t, err)
+	require.Len(t, items, 2)
+
+	// 必检项未勾选
+	err = svc.EnsureComplete(ctx, "t1")
+	var openErr *ChecklistIncompleteError
+	require.True(t, errors.As(err, &openErr))
+	require.Len(t, openErr.Open, 1)
+	assert.Equal(t, "图纸评审", openErr.Open[0].Title)
+	assert.ErrorContains(t, err, "还有 1 项必检项未勾选: 图纸评审")
+
+	// 手动添加的必检项同样要求勾选
+	manual, err := svc.AddItem(ctx, "p1", "t1", &SaveChecklistItemRequest{Title: "补充测试"}, "u1")
+	require.NoError(t, err)
+	_, err = svc.CheckItem(ctx, "p1", "t1", items[0].ID, "u1", true, "")
+	require.NoError(t, err)
+	require.True(t, errors.As(svc.EnsureComplete(ctx, "t1"), &openErr))
+	assert.Equal(t, "补充测试", openErr.Open[0].Title)
+
+	// 必检项全部勾选即可完成，非必检项不影响
+	_, err = svc.CheckItem(ctx, "p1", "t1", manual.ID, "u1", true, "")
+	require.NoError(t, err)
+	assert.NoError(t, svc.EnsureComplete(ctx, "t1"))
+
+	// 取消勾选后重新阻止
+	_, err = svc.CheckItem(ctx, "p1", "t1", manual.ID, "u1", false, "")
+	require.NoError(t, err)
+	assert.Error(t, svc.EnsureComplete(ctx, "t1"))
+
+	// 没有检查项的任务、未注入服务时直接通过
+	assert.NoError(t, svc.EnsureComplete(ctx, "t2"))
+	var nilSvc *ChecklistService
+	assert.NoError(t, nilSvc.EnsureComplete(ctx, "t1"))
+}
+
+func TestDeleteChecklistItem(t *testing.T) {
+	svc, ctx := setupChecklistTest(t)
+	items, err := svc.ListTaskItems(ctx, "t1")
+	require.NoError(t, err)
+	templateItem := items[0]
+	assert.Equal(t, entity.ChecklistSourceTemplate, templateItem.Source)
+
+	// 手动添加的检查项可以删除
+	manual, err := svc.AddItem(ctx, "p1", "t1", &SaveChecklistItemRequest{Title: "补充测试"}, "u1")
+	require.NoError(t, err)
+	assert.Equal(t, entity.ChecklistSourceManual, manual.Source)
+	require.NoError(t, svc.DeleteItem(ctx, "p1", "t1", manual.ID, "u1"))
+
+	// 模板检查项只有项目经理或管理员可以删除
+	assert.ErrorIs(t, svc.DeleteItem(ctx, "p1", "t1", templateItem.ID, "u1"), ErrChecklistDeleteForbidden)
+	require.NoError(t, svc.DeleteItem(ctx, "p1", "t1", templateItem.ID, "pm"))
+
+	assert.ErrorContains(t, svc.DeleteItem(ctx, "p1", "t1", templateItem.ID, "pm"), "检查项不存在")
+	items, err = svc.ListTaskItems(ctx, "t1")
+	require.NoError(t, err)
+	assert.Len(t, items, 1)
+}
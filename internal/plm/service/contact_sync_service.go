@@ -78,6 +78,11 @@ func (s *ContactSyncService) SyncContacts(ctx context.Context) (*SyncResult, err
 		}
 	}
 
+	// 3. 回填部门上下级、部门负责人和用户直属上级（审批人解析依赖这些关系）
+	if err := s.syncOrgRelations(ctx, depts, users); err != nil {
+		result.Errors = append(result.Errors, err.Error())
+	}
+
 	log.Printf("[ContactSync] 同步完成: 部门新建=%d 更新=%d, 用户新建=%d 更新=%d, 错误=%d",
 		result.DepartmentsCreated, result.DepartmentsUpdated,
 		result.UsersCreated, result.UsersUpdated, len(result.Errors))
@@ -249,3 +254,53 @@ func (s *ContactSyncService) syncUser(ctx context.Context, user feishu.FeishuUse
 	}
 	return false, nil
 }
+
+// syncOrgRelations 部门和用户都落库后，把飞书 ID 映射为本地 ID，回填
+// departments.parent_id / leader_id 和 users.manager_id
+func (s *ContactSyncService) syncOrgRelations(ctx context.Context, depts []feishu.FeishuDepartment, users []feishu.FeishuUser) error {
+	var localDepts []entity.Department
+	if err := s.db.WithContext(ctx).Select("id, feishu_dept_id").Find(&localDepts).Error; err != nil {
+		return fmt.Errorf("查询部门失败: %w", err)
+	}
+	deptIDs := make(map[string]string, len(localDepts))
+	for _, d := range localDepts {
+		deptIDs[d.FeishuDeptID] = d.ID
+	}
+
+	var localUsers []entity.User
+	if err := s.db.WithContext(ctx).Select("id, feishu_open_id").Where("feishu_open_id <> ''").Find(&localUsers).Error; err != nil {
+		return fmt.Errorf("查询用户失败: %w", err)
+	}
+	userIDs := make(map[string]string, len(localUsers))
+	for _, u := range localUsers {
+		userIDs[u.FeishuOpenID] = u.ID
+	}
+
+	for _, dept := range depts {
+		id := deptIDs[dept.OpenDepartmentID]
+		if id == "" {
+			id = deptIDs[dept.DepartmentID]
+		}
+		if id == "" {
+			continue
+		}
+		if err := s.db.WithContext(ctx).Model(&entity.Department{}).Where("id = ?", id).
+			Updates(map[string]interface{}{
+				"parent_id": deptIDs[dept.ParentID], // 根部门 parent_department_id 为 "0"，映射为空
+				"leader_id": userIDs[dept.LeaderUserID],
+			}).Error; err != nil {
+			return fmt.Errorf("更新部门[%s]上下级关系失败: %w", dept.Name, err)
+		}
+	}
+
+	for _, user := range users {
+		if user.OpenID == "" {
+			continue
+		}
+		if err := s.db.WithContext(ctx).Model(&entity.User{}).Where("feishu_open_id = ?", user.OpenID).
+			Update("manager_id", userIDs[user.LeaderUserID]).Error; err != nil {
+			return fmt.Errorf("更新用户[%s]直属上级失败: %w", user.Name, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,543 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bitfantasy/nimo/internal/plm/entity"
+	"github.com/bitfantasy/nimo/internal/plm/sse"
+	"github.com/bitfantasy/nimo/internal/shared/feishu"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// =============================================================================
+// 飞书任务双向同步
+//
+// PLM → 飞书：任务指派、修改、完成后推送标题/描述/截止日期/执行人/完成状态，
+// 开启 auto_create_feishu_task 的任务在首次有飞书执行人时创建飞书任务。
+// 飞书 → PLM：消费任务变更事件，飞书端完成或转派时同步完成/转派 PLM 任务。
+//
+// 冲突判定以 LastSyncAt 为界：一端有待同步的修改、另一端在上次同步后也被修改，
+// 记为 CONFLICT 不自动覆盖；重试时以 PLM 为准强制推送
+// =============================================================================
+
+// ErrSyncConflict 两端在上次同步后均有修改
+var ErrSyncConflict = errors.New("同步冲突")
+
+// syncClockSkew 比较飞书更新时间与 LastSyncAt 时允许的时钟偏差
+const syncClockSkew = 5 * time.Second
+
+// FeishuTaskSyncService 飞书任务同步服务
+type FeishuTaskSyncService struct {
+	db           *gorm.DB
+	feishuClient *feishu.FeishuClient
+	workflowSvc  *WorkflowService
+	taskStates   *TaskStateMirror
+	locks        sync.Map // taskID → *sync.Mutex，同一任务的同步串行执行
+}
+
+// NewFeishuTaskSyncService 创建飞书任务同步服务
+func NewFeishuTaskSyncService(db *gorm.DB) *FeishuTaskSyncService {
+	return &FeishuTaskSyncService{db: db}
+}
+
+// SetFeishuClient 注入飞书客户端
+func (s *FeishuTaskSyncService) SetFeishuClient(fc *feishu.FeishuClient) {
+	s.feishuClient = fc
+}
+
+// SetWorkflowService 注入工作流服务（飞书端完成时按工作流完成 PLM 任务）
+func (s *FeishuTaskSyncService) SetWorkflowService(svc *WorkflowService) {
+	s.workflowSvc = svc
+}
+
+// SetTaskStateMirror 注入任务状态镜像（飞书转派使任务进入待开始时同步 plm_task 状态机）
+func (s *FeishuTaskSyncService) SetTaskStateMirror(m *TaskStateMirror) {
+	s.taskStates = m
+}
+
+// FeishuTaskSyncQuery 同步记录查询条件
+type FeishuTaskSyncQuery struct {
+	ProjectID  string
+	SyncStatus string
+}
+
+// SyncAsync 异步推送任务到飞书（不阻断主流程，服务或飞书未配置时忽略）
+func (s *FeishuTaskSyncService) SyncAsync(taskID string) {
+	s.SyncAssignedAsync(taskID, "")
+}
+
+// SyncAssignedAsync 指派后异步推送，assigneeOpenID 为指派时指定的飞书执行人（为空取执行人绑定的飞书账号）
+func (s *FeishuTaskSyncService) SyncAssignedAsync(taskID, assigneeOpenID string) {
+	if s == nil || s.feishuClient == nil || taskID == "" {
+		return
+	}
+	go func() {
+		if _, err := s.syncTask(context.Background(), taskID, assigneeOpenID, false); err != nil {
+			log.Printf("[FeishuTaskSync] 推送飞书任务失败 (task=%s): %v", taskID, err)
+		}
+	}()
+}
+
+// SyncRoleTasksAsync 按角色批量指派后，异步推送该角色的全部任务
+func (s *FeishuTaskSyncService) SyncRoleTasksAsync(projectID, roleCode string) {
+	if s == nil || s.feishuClient == nil {
+		return
+	}
+	go func() {
+		ctx := context.Background()
+		var taskIDs []string
+		if err := s.db.WithContext(ctx).Model(&entity.Task{}).
+			Where("project_id = ? AND LOWER(default_assignee_role) = LOWER(?)", projectID, roleCode).
+			Pluck("id", &taskIDs).Error; err != nil {
+			log.Printf("[FeishuTaskSync] 查询角色任务失败 (project=%s role=%s): %v", projectID, roleCode, err)
+			return
+		}
+		for _, id := range taskIDs {
+			if _, err := s.syncTask(ctx, id, "", false); err != nil {
+				log.Printf("[FeishuTaskSync] 推送飞书任务失败 (task=%s): %v", id, err)
+			}
+		}
+	}()
+}
+
+// ListSyncs 同步记录列表
+func (s *FeishuTaskSyncService) ListSyncs(ctx context.Context, q FeishuTaskSyncQuery, page, pageSize int) ([]entity.FeishuTaskSync, int64, error) {
+	query := s.db.WithContext(ctx).Model(&entity.FeishuTaskSync{})
+	if q.SyncStatus != "" {
+		query = query.Where("sync_status = ?", q.SyncStatus)
+	}
+	if q.ProjectID != "" {
+		query = query.Where("task_id IN (?)", s.db.Model(&entity.Task{}).Select("id").Where("project_id = ?", q.ProjectID))
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, fmt.Errorf("统计同步记录失败: %w", err)
+	}
+
+	var records []entity.FeishuTaskSync
+	if err := query.Preload("Task").Order("updated_at DESC").
+		Offset((page - 1) * pageSize).Limit(pageSize).Find(&records).Error; err != nil {
+		return nil, 0, fmt.Errorf("查询同步记录失败: %w", err)
+	}
+	return records, total, nil
+}
+
+// RetrySync 重试同步：以 PLM 任务为准强制推送，清除失败/冲突状态
+func (s *FeishuTaskSyncService) RetrySync(ctx context.Context, id string) (*entity.FeishuTaskSync, error) {
+	if s.feishuClient == nil {
+		return nil, fmt.Errorf("飞书未配置")
+	}
+	var rec entity.FeishuTaskSync
+	if err := s.db.WithContext(ctx).Where("id = ?", id).First(&rec).Error; err != nil {
+		return nil, fmt.Errorf("同步记录不存在")
+	}
+	return s.syncTask(ctx, rec.TaskID, "", true)
+}
+
+// =============================================================================
+// PLM → 飞书
+// =============================================================================
+
+// syncTask 推送 PLM 任务到飞书并记录同步结果；任务未开启同步或尚无飞书执行人时返回 nil
+func (s *FeishuTaskSyncService) syncTask(ctx context.Context, taskID, assigneeOpenID string, force bool) (*entity.FeishuTaskSync, error) {
+	unlock := s.lockTask(taskID)
+	defer unlock()
+
+	var task entity.Task
+	if err := s.db.WithContext(ctx).Preload("Assignee").Where("id = ?", taskID).First(&task).Error; err != nil {
+		return nil, fmt.Errorf("查找任务失败: %w", err)
+	}
+	if assigneeOpenID == "" && task.Assignee != nil {
+		assigneeOpenID = task.Assignee.FeishuOpenID
+	}
+
+	rec, err := s.findByTask(ctx, taskID)
+	if err != nil {
+		return nil, err
+	}
+	if rec == nil {
+		switch {
+		case task.FeishuTaskID != "":
+			// 历史数据：已有飞书任务但没有同步记录
+			rec = &entity.FeishuTaskSync{TaskID: taskID}
+			s.bind(rec, task.FeishuTaskID)
+		case task.AutoCreateFeishuTask && assigneeOpenID != "":
+			rec = &entity.FeishuTaskSync{TaskID: taskID}
+		default:
+			return nil, nil
+		}
+		rec.ID = uuid.New().String()
+	}
+
+	if rec.SyncStatus == entity.FeishuSyncStatusConflict && !force {
+		return rec, nil
+	}
+
+	err = s.push(ctx, &task, rec, assigneeOpenID, force)
+	s.saveResult(ctx, rec, err)
+	return rec, err
+}
+
+// push 创建或更新飞书任务
+func (s *FeishuTaskSyncService) push(ctx context.Context, task *entity.Task, rec *entity.FeishuTaskSync, assigneeOpenID string, force bool) error {
+	if rec.FeishuTaskID == nil {
+		if assigneeOpenID == "" {
+			return fmt.Errorf("执行人未绑定飞书账号")
+		}
+		guid, err := s.feishuClient.CreateTask(ctx, feishu.CreateTaskReq{
+			Summary:     task.Title,
+			Description: task.Description,
+			Members:     []feishu.TaskMember{{ID: assigneeOpenID, Role: "assignee"}},
+			Due:         feishuTaskDue(task.DueDate),
+		})
+		if err != nil {
+			return err
+		}
+		s.bind(rec, guid)
+		if err := s.db.WithContext(ctx).Model(&entity.Task{}).Where("id = ?", task.ID).
+			UpdateColumn("feishu_task_id", guid).Error; err != nil {
+			return fmt.Errorf("保存飞书任务ID失败: %w", err)
+		}
+		log.Printf("[FeishuTaskSync] 飞书任务创建成功 task=%s feishu_guid=%s", task.ID, guid)
+		if task.Status == entity.TaskStatusCompleted {
+			return s.feishuClient.CompleteTask(ctx, guid)
+		}
+		return nil
+	}
+
+	guid := *rec.FeishuTaskID
+	remote, err := s.feishuClient.GetTask(ctx, guid)
+	if err != nil {
+		return err
+	}
+
+	// 比对差异
+	var update feishu.UpdateTaskReq
+	var changed []string
+	if remote.Summary != task.Title {
+		update.Summary = &task.Title
+		changed = append(changed, "标题")
+	}
+	if remote.Description != task.Description {
+		update.Description = &task.Description
+		changed = append(changed, "描述")
+	}
+	if due := feishuTaskDue(task.DueDate); due != nil && !sameDueDate(remote.DueTime(), task.DueDate) {
+		update.Due = due
+		changed = append(changed, "截止日期")
+	}
+	remoteAssignees := remote.Assignees()
+	reassign := assigneeOpenID != "" && !(len(remoteAssignees) == 1 && remoteAssignees[0] == assigneeOpenID)
+	if reassign {
+		changed = append(changed, "执行人")
+	}
+	complete := task.Status == entity.TaskStatusCompleted && !remote.IsCompleted()
+	if complete {
+		changed = append(changed, "完成状态")
+	}
+	if len(changed) == 0 {
+		return nil
+	}
+
+	if !force && rec.LastSyncAt != nil {
+		if updated := remote.UpdatedTime(); updated != nil && updated.After(rec.LastSyncAt.Add(syncClockSkew)) {
+			return fmt.Errorf("%w: 飞书任务在上次同步后已被修改，PLM 的%s变更未推送", ErrSyncConflict, strings.Join(changed, "、"))
+		}
+	}
+
+	if update.Summary != nil || update.Description != nil || update.Due != nil {
+		if err := s.feishuClient.UpdateTask(ctx, guid, update); err != nil {
+			return err
+		}
+	}
+	if reassign {
+		var stale []feishu.TaskMember
+		for _, id := range remoteAssignees {
+			if id != assigneeOpenID {
+				stale = append(stale, feishu.TaskMember{ID: id, Role: "assignee"})
+			}
+		}
+		if len(stale) > 0 {
+			if err := s.feishuClient.RemoveTaskMembers(ctx, guid, stale); err != nil {
+				return err
+			}
+		}
+		if !slices.Contains(remoteAssignees, assigneeOpenID) {
+			if err := s.feishuClient.AddTaskMembers(ctx, guid, []feishu.TaskMember{{ID: assigneeOpenID, Role: "assignee"}}); err != nil {
+				return err
+			}
+		}
+	}
+	if complete {
+		if err := s.feishuClient.CompleteTask(ctx, guid); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// =============================================================================
+// 飞书 → PLM
+// =============================================================================
+
+// HandleTaskEvent 处理飞书任务变更事件：飞书端完成则完成 PLM 任务，转派则同步执行人
+// 与 PLM 无关联的飞书任务直接忽略
+func (s *FeishuTaskSyncService) HandleTaskEvent(ctx context.Context, event *feishu.TaskEvent) error {
+	if s.feishuClient == nil {
+		return nil
+	}
+
+	rec, err := s.findByFeishuTask(ctx, event.TaskID)
+	if err != nil || rec == nil {
+		return err
+	}
+
+	unlock := s.lockTask(rec.TaskID)
+	defer unlock()
+
+	// 加锁后重新读取，避免覆盖并发推送的结果
+	if err := s.db.WithContext(ctx).Where("id = ?", rec.ID).First(rec).Error; err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return fmt.Errorf("查询同步记录失败: %w", err)
+	}
+
+	if event.ObjType == feishu.TaskEventObjDeleted {
+		// 先向飞书确认任务确实已删除，再解除关联（重试时重新创建）；任务仍存在时按普通变更处理
+		deleted, err := s.remoteTaskDeleted(ctx, event.TaskID)
+		if err != nil {
+			s.saveResult(ctx, rec, err)
+			return err
+		}
+		if deleted {
+			rec.FeishuTaskID = nil
+			rec.FeishuTaskGUID = ""
+			if err := s.db.WithContext(ctx).Model(&entity.Task{}).Where("id = ?", rec.TaskID).
+				UpdateColumn("feishu_task_id", "").Error; err != nil {
+				return fmt.Errorf("解除飞书任务关联失败: %w", err)
+			}
+			err = fmt.Errorf("飞书任务已被删除")
+			s.saveResult(ctx, rec, err)
+			return err
+		}
+		log.Printf("[FeishuTaskSync] 飞书任务 %s 收到删除事件但仍存在，按变更同步", event.TaskID)
+	}
+
+	applied, err := s.pull(ctx, rec, event.TaskID)
+	if err == nil && !applied {
+		return nil
+	}
+	s.saveResult(ctx, rec, err)
+	return err
+}
+
+// remoteTaskDeleted 查询飞书任务是否已不存在
+func (s *FeishuTaskSyncService) remoteTaskDeleted(ctx context.Context, guid string) (bool, error) {
+	if _, err := s.feishuClient.GetTask(ctx, guid); err != nil {
+		if feishu.IsTaskNotFound(err) {
+			return true, nil
+		}
+		return false, fmt.Errorf("确认飞书任务是否删除失败: %w", err)
+	}
+	return false, nil
+}
+
+// pull 读取飞书任务并把完成/转派应用到 PLM 任务，无需变更时返回 false
+func (s *FeishuTaskSyncService) pull(ctx context.Context, rec *entity.FeishuTaskSync, guid string) (bool, error) {
+	remote, err := s.feishuClient.GetTask(ctx, guid)
+	if err != nil {
+		return false, err
+	}
+
+	var task entity.Task
+	if err := s.db.WithContext(ctx).Preload("Assignee").Where("id = ?", rec.TaskID).First(&task).Error; err != nil {
+		return false, fmt.Errorf("查找任务失败: %w", err)
+	}
+
+	// 飞书执行人与 PLM 不一致时按飞书转派（多执行人时取第一个）
+	var newAssignee *entity.User
+	if assignees := remote.Assignees(); len(assignees) > 0 &&
+		(task.Assignee == nil || !slices.Contains(assignees, task.Assignee.FeishuOpenID)) {
+		var user entity.User
+		if err := s.db.WithContext(ctx).Where("feishu_open_id = ?", assignees[0]).First(&user).Error; err != nil {
+			return false, fmt.Errorf("飞书执行人 %s 未绑定 PLM 用户", assignees[0])
+		}
+		newAssignee = &user
+	}
+	complete := remote.IsCompleted() && task.Status != entity.TaskStatusCompleted && task.Status != entity.TaskStatusReviewing
+	if newAssignee == nil && !complete {
+		return false, nil
+	}
+
+	if rec.SyncStatus == entity.FeishuSyncStatusConflict ||
+		(rec.LastSyncAt != nil && task.UpdatedAt.After(*rec.LastSyncAt)) {
+		var changed []string
+		if newAssignee != nil {
+			changed = append(changed, "转派")
+		}
+		if complete {
+			changed = append(changed, "完成")
+		}
+		return false, fmt.Errorf("%w: PLM 任务在上次同步后已被修改，飞书端的%s未应用", ErrSyncConflict, strings.Join(changed, "、"))
+	}
+
+	operatorID := "system"
+	if newAssignee != nil {
+		if err := s.reassign(ctx, &task, newAssignee.ID); err != nil {
+			return false, err
+		}
+		operatorID = newAssignee.ID
+	} else if task.AssigneeID != nil {
+		operatorID = *task.AssigneeID
+	}
+
+	if complete {
+		if s.workflowSvc == nil {
+			return true, fmt.Errorf("工作流服务未配置，无法完成任务")
+		}
+		if err := s.workflowSvc.CompleteTask(ctx, task.ProjectID, task.ID, operatorID); err != nil {
+			return true, fmt.Errorf("完成 PLM 任务失败: %w", err)
+		}
+		log.Printf("[FeishuTaskSync] 飞书端已完成，同步完成 PLM 任务 task=%s", task.ID)
+	}
+	return true, nil
+}
+
+// reassign 按飞书执行人转派 PLM 任务（未指派的任务同时进入待开始）
+func (s *FeishuTaskSyncService) reassign(ctx context.Context, task *entity.Task, assigneeID string) error {
+	fromStatus := task.Status
+	updates := map[string]interface{}{"assignee_id": assigneeID}
+	if task.Status == entity.TaskStatusUnassigned {
+		updates["status"] = entity.TaskStatusPending
+		task.Status = entity.TaskStatusPending
+	}
+	if err := s.db.WithContext(ctx).Model(&entity.Task{}).Where("id = ?", task.ID).Updates(updates).Error; err != nil {
+		return fmt.Errorf("转派任务失败: %w", err)
+	}
+	s.taskStates.Sync(ctx, task.ID, fromStatus, task.Status, "system")
+
+	actionLog := entity.TaskActionLog{
+		ID:           uuid.New().String(),
+		ProjectID:    task.ProjectID,
+		TaskID:       task.ID,
+		Action:       entity.TaskActionAssign,
+		FromStatus:   fromStatus,
+		ToStatus:     task.Status,
+		OperatorID:   "system",
+		OperatorType: "system",
+		EventData:    entity.JSONB{"assignee_id": assigneeID, "source": "feishu"},
+		Comment:      "飞书任务转派",
+	}
+	if err := s.db.WithContext(ctx).Create(&actionLog).Error; err != nil {
+		log.Printf("[FeishuTaskSync] 记录操作日志失败: %v", err)
+	}
+
+	task.AssigneeID = &assigneeID
+	sse.PublishTaskUpdate(task.ProjectID, task.ID, "task_updated")
+	log.Printf("[FeishuTaskSync] 飞书端转派，同步 PLM 任务执行人 task=%s assignee=%s", task.ID, assigneeID)
+	return nil
+}
+
+// =============================================================================
+// 记录与工具函数
+// =============================================================================
+
+// saveResult 保存同步结果：成功刷新 LastSyncAt，冲突/失败记录 SyncError
+func (s *FeishuTaskSyncService) saveResult(ctx context.Context, rec *entity.FeishuTaskSync, syncErr error) {
+	switch {
+	case syncErr == nil:
+		now := time.Now()
+		rec.SyncStatus = entity.FeishuSyncStatusSynced
+		rec.SyncError = ""
+		rec.LastSyncAt = &now
+	case errors.Is(syncErr, ErrSyncConflict):
+		rec.SyncStatus = entity.FeishuSyncStatusConflict
+		rec.SyncError = syncErr.Error()
+	default:
+		rec.SyncStatus = entity.FeishuSyncStatusFailed
+		rec.SyncError = syncErr.Error()
+	}
+	if err := s.db.WithContext(ctx).Omit("Task").Save(rec).Error; err != nil {
+		log.Printf("[FeishuTaskSync] 保存同步记录失败 (task=%s): %v", rec.TaskID, err)
+	}
+}
+
+// bind 关联飞书任务（任务API v2 的 guid 即任务ID）
+func (s *FeishuTaskSyncService) bind(rec *entity.FeishuTaskSync, guid string) {
+	rec.FeishuTaskID = &guid
+	rec.FeishuTaskGUID = guid
+}
+
+// findByTask 按 PLM 任务查找同步记录，不存在返回 nil
+func (s *FeishuTaskSyncService) findByTask(ctx context.Context, taskID string) (*entity.FeishuTaskSync, error) {
+	var rec entity.FeishuTaskSync
+	err := s.db.WithContext(ctx).Where("task_id = ?", taskID).First(&rec).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("查询同步记录失败: %w", err)
+	}
+	return &rec, nil
+}
+
+// findByFeishuTask 按飞书任务查找同步记录；只有 tasks.feishu_task_id 的历史数据补建记录
+func (s *FeishuTaskSyncService) findByFeishuTask(ctx context.Context, guid string) (*entity.FeishuTaskSync, error) {
+	var rec entity.FeishuTaskSync
+	err := s.db.WithContext(ctx).Where("feishu_task_id = ?", guid).First(&rec).Error
+	if err == nil {
+		return &rec, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, fmt.Errorf("查询同步记录失败: %w", err)
+	}
+
+	var task entity.Task
+	if err := s.db.WithContext(ctx).Select("id").Where("feishu_task_id = ?", guid).First(&task).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("查找任务失败: %w", err)
+	}
+	if existing, err := s.findByTask(ctx, task.ID); err != nil || existing != nil {
+		if existing != nil {
+			s.bind(existing, guid)
+		}
+		return existing, err
+	}
+	rec = entity.FeishuTaskSync{ID: uuid.New().String(), TaskID: task.ID}
+	s.bind(&rec, guid)
+	return &rec, nil
+}
+
+// lockTask 获取任务级互斥锁，返回解锁函数
+func (s *FeishuTaskSyncService) lockTask(taskID string) func() {
+	v, _ := s.locks.LoadOrStore(taskID, &sync.Mutex{})
+	mu := v.(*sync.Mutex)
+	mu.Lock()
+	return mu.Unlock
+}
+
+// feishuTaskDue PLM 截止日期转为飞书全天截止时间
+func feishuTaskDue(due *time.Time) *feishu.TaskDue {
+	if due == nil {
+		return nil
+	}
+	day := time.Date(due.Year(), due.Month(), due.Day(), 0, 0, 0, 0, time.UTC)
+	return &feishu.TaskDue{Time: day.UnixMilli(), IsAllDay: true}
+}
+
+// sameDueDate 飞书截止时间与 PLM 截止日期是否为同一天
+func sameDueDate(remote, local *time.Time) bool {
+	if remote == nil || local == nil {
+		return remote == nil && local == nil
+	}
+	return remote.UTC().Format("2006-01-02") == local.Format("2006-01-02")
+}
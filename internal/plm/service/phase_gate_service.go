@@ -0,0 +1,654 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/bitfantasy/nimo/internal/plm/entity"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// PhaseGateService 阶段门禁服务
+// 模板按阶段配置门禁条件和交付物，项目创建时复制到项目阶段；阶段完成前检查门禁，
+// 未通过时需填写理由强制放行。任务完成时自动登记其产出的 BOM/文档交付物。
+type PhaseGateService struct {
+	db *gorm.DB
+}
+
+// NewPhaseGateService 创建阶段门禁服务
+func NewPhaseGateService(db *gorm.DB) *PhaseGateService {
+	return &PhaseGateService{db: db}
+}
+
+// 门禁检查项
+const (
+	PhaseGateCheckDeliverables  = "deliverables"
+	PhaseGateCheckCriticalTasks = "critical_tasks"
+	PhaseGateCheckOpenECNs      = "open_ecns"
+	PhaseGateCheckBOMReleased   = "bom_released"
+)
+
+// 模板阶段（与 CreateProjectFromTemplate 创建的项目阶段一致）
+var gatePhases = []string{"concept", "evt", "dvt", "pvt", "mp"}
+
+// PhaseGateCheck 门禁检查项结果
+type PhaseGateCheck struct {
+	Key     string   `json:"key"`
+	Name    string   `json:"name"`
+	Passed  bool     `json:"passed"`
+	Detail  string   `json:"detail"`
+	Missing []string `json:"missing,omitempty"` // 未满足的具体项
+}
+
+// PhaseGateResult 门禁检查结果
+type PhaseGateResult struct {
+	PhaseID   string                     `json:"phase_id"`
+	Phase     string                     `json:"phase"`
+	Passed    bool                       `json:"passed"`
+	Criteria  entity.PhaseGateCriteria   `json:"criteria"`
+	Checks    []PhaseGateCheck           `json:"checks"`
+	Overrides []entity.PhaseGateOverride `json:"overrides"`
+}
+
+// Missing 未通过检查项的说明
+func (r *PhaseGateResult) Missing() []string {
+	var missing []string
+	for _, c := range r.Checks {
+		if !c.Passed {
+			missing = append(missing, c.Name+": "+c.Detail)
+		}
+	}
+	return missing
+}
+
+// PhaseGateError 阶段门禁未通过
+type PhaseGateError struct {
+	Result *PhaseGateResult
+}
+
+func (e *PhaseGateError) Error() string {
+	return "阶段门禁未通过（" + strings.Join(e.Result.Missing(), "；") + "），如需继续请填写理由强制放行"
+}
+
+// ============================================================
+// 模板门禁配置
+// ============================================================
+
+// SaveTemplatePhaseGateRequest 保存模板阶段门禁
+type SaveTemplatePhaseGateRequest struct {
+	RequireDeliverables  bool                             `json:"require_deliverables"`
+	RequireCriticalTasks bool                             `json:"require_critical_tasks"`
+	MaxOpenECNs          *int                             `json:"max_open_ecns"`
+	RequireBOMReleased   bool                             `json:"require_bom_released"`
+	Deliverables         []entity.PhaseGateDeliverableDef `json:"deliverables"`
+}
+
+// ListTemplateGates 模板各阶段门禁配置
+func (s *PhaseGateService) ListTemplateGates(ctx context.Context, templateID string) ([]entity.TemplatePhaseGate, error) {
+	var gates []entity.TemplatePhaseGate
+	if err := s.db.WithContext(ctx).Where("template_id = ?", templateID).Find(&gates).Error; err != nil {
+		return nil, fmt.Errorf("查询阶段门禁失败: %w", err)
+	}
+	slices.SortFunc(gates, func(a, b entity.TemplatePhaseGate) int {
+		return slices.Index(gatePhases, a.Phase) - slices.Index(gatePhases, b.Phase)
+	})
+	return gates, nil
+}
+
+// SaveTemplateGate 保存模板阶段门禁（整体覆盖）
+func (s *PhaseGateService) SaveTemplateGate(ctx context.Context, templateID, phase string, req *SaveTemplatePhaseGateRequest) (*entity.TemplatePhaseGate, error) {
+	phase = strings.ToLower(phase)
+	if !slices.Contains(gatePhases, phase) {
+		return nil, fmt.Errorf("不支持的阶段: %s", phase)
+	}
+	if req.MaxOpenECNs != nil && *req.MaxOpenECNs < 0 {
+		return nil, fmt.Errorf("未关闭 ECN 上限不能为负数")
+	}
+	for i, d := range req.Deliverables {
+		if strings.TrimSpace(d.Name) == "" {
+			return nil, fmt.Errorf("第 %d 个交付物名称不能为空", i+1)
+		}
+		switch d.DeliverableType {
+		case "":
+			req.Deliverables[i].DeliverableType = entity.DeliverableTypeDocument
+		case entity.DeliverableTypeDocument, entity.DeliverableTypeBOM, entity.DeliverableTypeReview:
+		default:
+			return nil, fmt.Errorf("不支持的交付物类型: %s", d.DeliverableType)
+		}
+	}
+
+	var template entity.ProjectTemplate
+	if err := s.db.WithContext(ctx).Select("id").First(&template, "id = ?", templateID).Error; err != nil {
+		return nil, fmt.Errorf("模板不存在: %w", err)
+	}
+
+	deliverables, err := json.Marshal(req.Deliverables)
+	if err != nil {
+		return nil, fmt.Errorf("序列化交付物失败: %w", err)
+	}
+
+	var gate entity.TemplatePhaseGate
+	err = s.db.WithContext(ctx).Where("template_id = ? AND phase = ?", templateID, phase).First(&gate).Error
+	if err != nil && err != gorm.ErrRecordNotFound {
+		return nil, fmt.Errorf("查询阶段门禁失败: %w", err)
+	}
+	if err == gorm.ErrRecordNotFound {
+		gate = entity.TemplatePhaseGate{ID: uuid.New().String(), TemplateID: templateID, Phase: phase}
+	}
+	gate.RequireDeliverables = req.RequireDeliverables
+	gate.RequireCriticalTasks = req.RequireCriticalTasks
+	gate.MaxOpenECNs = req.MaxOpenECNs
+	gate.RequireBOMReleased = req.RequireBOMReleased
+	gate.Deliverables = deliverables
+
+	if err := s.db.WithContext(ctx).Save(&gate).Error; err != nil {
+		return nil, fmt.Errorf("保存阶段门禁失败: %w", err)
+	}
+	return &gate, nil
+}
+
+// DeleteTemplateGate 删除模板阶段门禁
+func (s *PhaseGateService) DeleteTemplateGate(ctx context.Context, templateID, phase string) error {
+	result := s.db.WithContext(ctx).
+		Where("template_id = ? AND phase = ?", templateID, strings.ToLower(phase)).
+		Delete(&entity.TemplatePhaseGate{})
+	if result.Error != nil {
+		return fmt.Errorf("删除阶段门禁失败: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return fmt.Errorf("阶段门禁不存在")
+	}
+	return nil
+}
+
+// ApplyTemplateGates 从模板创建项目时复制门禁条件并生成阶段交付物
+// phaseIDMap: 阶段名(小写) -> 项目阶段ID；taskMap: task_code -> 项目任务ID
+func (s *PhaseGateService) ApplyTemplateGates(ctx context.Context, templateID string, phaseIDMap, taskMap map[string]string) error {
+	if s == nil {
+		return nil
+	}
+	gates, err := s.ListTemplateGates(ctx, templateID)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	for _, gate := range gates {
+		phaseID, ok := phaseIDMap[gate.Phase]
+		if !ok {
+			continue
+		}
+
+		criteria, err := criteriaToJSONB(gate.Criteria())
+		if err != nil {
+			return err
+		}
+		if err := s.db.WithContext(ctx).Model(&entity.ProjectPhase{}).Where("id = ?", phaseID).
+			Update("exit_criteria", criteria).Error; err != nil {
+			return fmt.Errorf("保存阶段门禁条件失败: %w", err)
+		}
+
+		var defs []entity.PhaseGateDeliverableDef
+		if len(gate.Deliverables) > 0 {
+			if err := json.Unmarshal(gate.Deliverables, &defs); err != nil {
+				return fmt.Errorf("解析阶段交付物失败: %w", err)
+			}
+		}
+		deliverables := make([]entity.PhaseDeliverable, 0, len(defs))
+		for i, def := range defs {
+			d := entity.PhaseDeliverable{
+				ID:              uuid.New().String()[:32],
+				PhaseID:         phaseID,
+				Name:            def.Name,
+				DeliverableType: def.DeliverableType,
+				ResponsibleRole: def.ResponsibleRole,
+				IsRequired:      def.IsRequired,
+				Status:          entity.DeliverableStatusPending,
+				SortOrder:       i + 1,
+				CreatedAt:       now,
+				UpdatedAt:       now,
+			}
+			if tid, ok := taskMap[def.TaskCode]; ok {
+				d.TaskID = &tid
+			}
+			deliverables = append(deliverables, d)
+		}
+		if len(deliverables) > 0 {
+			if err := s.db.WithContext(ctx).Create(&deliverables).Error; err != nil {
+				return fmt.Errorf("创建阶段交付物失败: %w", err)
+			}
+		}
+	}
+	return nil
+}
+
+// ============================================================
+// 门禁检查
+// ============================================================
+
+// CheckGate 检查项目阶段门禁，逐项说明缺失内容
+func (s *PhaseGateService) CheckGate(ctx context.Context, phaseID string) (*PhaseGateResult, error) {
+	var phase entity.ProjectPhase
+	if err := s.db.WithContext(ctx).First(&phase, "id = ?", phaseID).Error; err != nil {
+		return nil, fmt.Errorf("阶段不存在: %w", err)
+	}
+	return s.checkPhase(ctx, &phase)
+}
+
+func (s *PhaseGateService) checkPhase(ctx context.Context, phase *entity.ProjectPhase) (*PhaseGateResult, error) {
+	var project entity.Project
+	if err := s.db.WithContext(ctx).First(&project, "id = ?", phase.ProjectID).Error; err != nil {
+		return nil, fmt.Errorf("项目不存在: %w", err)
+	}
+
+	criteria := phaseCriteria(phase)
+	result := &PhaseGateResult{PhaseID: phase.ID, Phase: phase.Phase, Passed: true, Criteria: criteria}
+
+	var deliverables []entity.PhaseDeliverable
+	if err := s.db.WithContext(ctx).Where("phase_id = ?", phase.ID).Order("sort_order ASC").Find(&deliverables).Error; err != nil {
+		return nil, fmt.Errorf("查询阶段交付物失败: %w", err)
+	}
+
+	checks := []struct {
+		enabled bool
+		fn      func() (PhaseGateCheck, error)
+	}{
+		{criteria.RequireDeliverables, func() (PhaseGateCheck, error) { return checkDeliverables(deliverables), nil }},
+		{criteria.RequireCriticalTasks, func() (PhaseGateCheck, error) { return s.checkCriticalTasks(ctx, &project, phase) }},
+		{criteria.MaxOpenECNs != nil, func() (PhaseGateCheck, error) { return s.checkOpenECNs(ctx, &project, *criteria.MaxOpenECNs) }},
+		{criteria.RequireBOMReleased, func() (PhaseGateCheck, error) { return s.checkBOMReleased(ctx, phase, deliverables) }},
+	}
+	for _, c := range checks {
+		if !c.enabled {
+			continue
+		}
+		check, err := c.fn()
+		if err != nil {
+			return nil, err
+		}
+		result.Checks = append(result.Checks, check)
+		result.Passed = result.Passed && check.Passed
+	}
+
+	if err := s.db.WithContext(ctx).Where("phase_id = ?", phase.ID).Preload("Overrider").
+		Order("created_at DESC").Find(&result.Overrides).Error; err != nil {
+		return nil, fmt.Errorf("查询强制放行记录失败: %w", err)
+	}
+	return result, nil
+}
+
+// Enforce 阶段完成前检查门禁，未通过返回 *PhaseGateError
+func (s *PhaseGateService) Enforce(ctx context.Context, phase *entity.ProjectPhase) error {
+	if s == nil {
+		return nil
+	}
+	result, err := s.checkPhase(ctx, phase)
+	if err != nil {
+		return err
+	}
+	if !result.Passed {
+		return &PhaseGateError{Result: result}
+	}
+	return nil
+}
+
+// ErrOverrideForbidden 非项目经理或管理员强制放行阶段门禁
+var ErrOverrideForbidden = errors.New("只有项目经理或管理员可以强制放行阶段门禁")
+
+// adminRoleCodes 视为管理员的系统角色
+var adminRoleCodes = []string{"admin", "super_admin", "plm_admin"}
+
+// CheckOverridePermission 只有项目经理或管理员可以强制放行
+func (s *PhaseGateService) CheckOverridePermission(ctx context.Context, projectID, userID string) error {
+	if s == nil {
+		return nil
+	}
+	ok, err := isProjectManagerOrAdmin(ctx, s.db, projectID, userID)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrOverrideForbidden
+	}
+	return nil
+}
+
+// isProjectManagerOrAdmin 用户是项目经理或拥有管理员角色
+func isProjectManagerOrAdmin(ctx context.Context, db *gorm.DB, projectID, userID string) (bool, error) {
+	if userID == "" {
+		return false, nil
+	}
+	var managers int64
+	if err := db.WithContext(ctx).Model(&entity.Project{}).
+		Where("id = ? AND owner_id = ?", projectID, userID).Count(&managers).Error; err != nil {
+		return false, fmt.Errorf("查询项目经理失败: %w", err)
+	}
+	if managers > 0 {
+		return true, nil
+	}
+	var admins int64
+	if err := db.WithContext(ctx).Table("user_roles").
+		Joins("JOIN roles ON roles.id = user_roles.role_id").
+		Where("user_roles.user_id = ? AND roles.code IN ?", userID, adminRoleCodes).
+		Count(&admins).Error; err != nil {
+		return false, fmt.Errorf("查询用户角色失败: %w", err)
+	}
+	return admins > 0, nil
+}
+
+// RecordOverride 记录强制放行（门禁已通过时不记录）
+func (s *PhaseGateService) RecordOverride(ctx context.Context, phase *entity.ProjectPhase, userID, justification string) error {
+	if s == nil {
+		return nil
+	}
+	result, err := s.checkPhase(ctx, phase)
+	if err != nil {
+		return err
+	}
+	if result.Passed {
+		return nil
+	}
+
+	var failed []PhaseGateCheck
+	for _, c := range result.Checks {
+		if !c.Passed {
+			failed = append(failed, c)
+		}
+	}
+	failedJSON, err := json.Marshal(failed)
+	if err != nil {
+		return fmt.Errorf("序列化检查结果失败: %w", err)
+	}
+	override := &entity.PhaseGateOverride{
+		ID:            uuid.New().String()[:32],
+		ProjectID:     phase.ProjectID,
+		PhaseID:       phase.ID,
+		Justification: justification,
+		FailedChecks:  failedJSON,
+		OverriddenBy:  userID,
+		CreatedAt:     time.Now(),
+	}
+	if err := s.db.WithContext(ctx).Create(override).Error; err != nil {
+		return fmt.Errorf("保存强制放行记录失败: %w", err)
+	}
+	log.Printf("[PhaseGate] 阶段门禁强制放行: phase=%s, user=%s, failed=%d", phase.ID, userID, len(failed))
+	return nil
+}
+
+func checkDeliverables(deliverables []entity.PhaseDeliverable) PhaseGateCheck {
+	check := PhaseGateCheck{Key: PhaseGateCheckDeliverables, Name: "必需交付物"}
+	required := 0
+	for _, d := range deliverables {
+		if !d.IsRequired {
+			continue
+		}
+		required++
+		if d.Status != entity.DeliverableStatusApproved {
+			check.Missing = append(check.Missing, fmt.Sprintf("%s（%s）", d.Name, d.Status))
+		}
+	}
+	check.Passed = len(check.Missing) == 0
+	check.Detail = fmt.Sprintf("%d/%d 项已批准", required-len(check.Missing), required)
+	return check
+}
+
+func (s *PhaseGateService) checkCriticalTasks(ctx context.Context, project *entity.Project, phase *entity.ProjectPhase) (PhaseGateCheck, error) {
+	check := PhaseGateCheck{Key: PhaseGateCheckCriticalTasks, Name: "关键任务"}
+	if project.TemplateID == nil {
+		check.Passed = true
+		check.Detail = "项目未关联模板，无关键任务"
+		return check, nil
+	}
+
+	var codes []string
+	if err := s.db.WithContext(ctx).Model(&entity.TemplateTask{}).
+		Where("template_id = ? AND is_critical = true", *project.TemplateID).
+		Pluck("task_code", &codes).Error; err != nil {
+		return check, fmt.Errorf("查询关键任务失败: %w", err)
+	}
+	var tasks []entity.Task
+	if len(codes) > 0 {
+		if err := s.db.WithContext(ctx).Where("phase_id = ? AND code IN ?", phase.ID, codes).
+			Order("sequence ASC").Find(&tasks).Error; err != nil {
+			return check, fmt.Errorf("查询关键任务失败: %w", err)
+		}
+	}
+	for _, t := range tasks {
+		if t.Status != entity.TaskStatusCompleted && t.Status != entity.TaskStatusCancelled {
+			check.Missing = append(check.Missing, fmt.Sprintf("%s（%s）", t.Title, t.Status))
+		}
+	}
+	check.Passed = len(check.Missing) == 0
+	check.Detail = fmt.Sprintf("%d/%d 项已完成", len(tasks)-len(check.Missing), len(tasks))
+	return check, nil
+}
+
+// checkOpenECNs 统计产品未关闭的 ECN 和项目 BOM 待审批的变更
+func (s *PhaseGateService) checkOpenECNs(ctx context.Context, project *entity.Project, max int) (PhaseGateCheck, error) {
+	check := PhaseGateCheck{Key: PhaseGateCheckOpenECNs, Name: "未关闭 ECN"}
+
+	if project.ProductID != nil {
+		var ecns []entity.ECN
+		if err := s.db.WithContext(ctx).Select("code", "title", "status").
+			Where("product_id = ? AND status IN ?", *project.ProductID,
+				[]string{entity.ECNStatusPending, entity.ECNStatusApproved, entity.ECNStatusExecuting}).
+			Order("created_at ASC").Find(&ecns).Error; err != nil {
+			return check, fmt.Errorf("查询 ECN 失败: %w", err)
+		}
+		for _, e := range ecns {
+			check.Missing = append(check.Missing, fmt.Sprintf("%s %s（%s）", e.Code, e.Title, e.Status))
+		}
+	}
+
+	var bomECNs []entity.BOMECN
+	if err := s.db.WithContext(ctx).Select("ecn_number", "title", "status").
+		Where("status = ? AND bom_id IN (?)", entity.BOMECNStatusPending,
+			s.db.Model(&entity.ProjectBOM{}).Select("id").Where("project_id = ?", project.ID)).
+		Order("created_at ASC").Find(&bomECNs).Error; err != nil {
+		return check, fmt.Errorf("查询 BOM 变更失败: %w", err)
+	}
+	for _, e := range bomECNs {
+		check.Missing = append(check.Missing, fmt.Sprintf("%s %s（%s）", e.ECNNumber, e.Title, e.Status))
+	}
+
+	check.Passed = len(check.Missing) <= max
+	check.Detail = fmt.Sprintf("%d 个未关闭，上限 %d", len(check.Missing), max)
+	return check, nil
+}
+
+// checkBOMReleased 阶段 BOM（交付物关联的 BOM 及归属该阶段的 BOM）须全部发布
+func (s *PhaseGateService) checkBOMReleased(ctx context.Context, phase *entity.ProjectPhase, deliverables []entity.PhaseDeliverable) (PhaseGateCheck, error) {
+	check := PhaseGateCheck{Key: PhaseGateCheckBOMReleased, Name: "BOM 发布"}
+
+	var bomIDs []string
+	for _, d := range deliverables {
+		if d.BOMID != nil {
+			bomIDs = append(bomIDs, *d.BOMID)
+		}
+	}
+	query := s.db.WithContext(ctx).Where("phase_id = ?", phase.ID)
+	if len(bomIDs) > 0 {
+		query = query.Or("id IN ?", bomIDs)
+	}
+	var boms []entity.ProjectBOM
+	if err := query.Order("created_at ASC").Find(&boms).Error; err != nil {
+		return check, fmt.Errorf("查询阶段 BOM 失败: %w", err)
+	}
+	if len(boms) == 0 {
+		check.Detail = "阶段未关联 BOM"
+		return check, nil
+	}
+
+	for _, b := range boms {
+		if b.Status != entity.BOMStatusReleased {
+			check.Missing = append(check.Missing, fmt.Sprintf("%s %s（%s）", b.BOMType, b.Name, b.Status))
+		}
+	}
+	check.Passed = len(check.Missing) == 0
+	check.Detail = fmt.Sprintf("%d/%d 个已发布", len(boms)-len(check.Missing), len(boms))
+	return check, nil
+}
+
+// ============================================================
+// 交付物自动登记
+// ============================================================
+
+// FulfillTaskDeliverables 任务完成后把其产出的交付物登记为已提交，审批仍由评审人完成
+// 只登记绑定该任务的交付物，以及同阶段未绑定任务、名称出现在任务名称中的交付物，不按类型猜测：
+// bomIDs 为任务表单上传生成的 BOM；文档取关联该任务的文档或表单提交的附件；评审类交付物仅在绑定任务时登记。
+func (s *PhaseGateService) FulfillTaskDeliverables(ctx context.Context, taskID, userID string, bomIDs []string) {
+	if s == nil {
+		return
+	}
+	if err := s.fulfillTaskDeliverables(ctx, taskID, userID, bomIDs); err != nil {
+		log.Printf("[PhaseGate] 登记任务交付物失败: task=%s, error=%v", taskID, err)
+	}
+}
+
+func (s *PhaseGateService) fulfillTaskDeliverables(ctx context.Context, taskID, userID string, bomIDs []string) error {
+	var task entity.Task
+	if err := s.db.WithContext(ctx).First(&task, "id = ?", taskID).Error; err != nil {
+		return fmt.Errorf("任务不存在: %w", err)
+	}
+	if task.PhaseID == nil {
+		return nil
+	}
+
+	var deliverables []entity.PhaseDeliverable
+	if err := s.db.WithContext(ctx).
+		Where("phase_id = ? AND status <> ? AND (task_id = ? OR task_id IS NULL)", *task.PhaseID, entity.DeliverableStatusApproved, taskID).
+		Order("sort_order ASC").Find(&deliverables).Error; err != nil {
+		return fmt.Errorf("查询阶段交付物失败: %w", err)
+	}
+	deliverables = slices.DeleteFunc(deliverables, func(d entity.PhaseDeliverable) bool {
+		return d.TaskID == nil && !deliverableNamedIn(d.Name, task.Title)
+	})
+	if len(deliverables) == 0 {
+		return nil
+	}
+	// 绑定该任务的交付物优先
+	slices.SortStableFunc(deliverables, func(a, b entity.PhaseDeliverable) int {
+		return boolRank(a.TaskID == nil) - boolRank(b.TaskID == nil)
+	})
+
+	documentID, hasDocument, err := s.taskDocument(ctx, taskID)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	for i := range deliverables {
+		d := &deliverables[i]
+		bound := d.TaskID != nil
+		switch d.DeliverableType {
+		case entity.DeliverableTypeBOM:
+			if len(bomIDs) == 0 {
+				continue
+			}
+			d.BOMID = &bomIDs[0]
+			bomIDs = bomIDs[1:]
+		case entity.DeliverableTypeDocument:
+			if !hasDocument {
+				continue
+			}
+			if documentID != "" {
+				d.DocumentID = &documentID
+			}
+		case entity.DeliverableTypeReview:
+			if !bound {
+				continue
+			}
+		default:
+			continue
+		}
+
+		d.Status = entity.DeliverableStatusSubmitted
+		d.TaskID = &task.ID
+		d.SubmittedAt, d.SubmittedBy = &now, &userID
+		d.UpdatedAt = now
+		if err := s.db.WithContext(ctx).Save(d).Error; err != nil {
+			return fmt.Errorf("更新交付物失败: %w", err)
+		}
+		log.Printf("[PhaseGate] 交付物已提交待审: deliverable=%s, task=%s", d.ID, taskID)
+	}
+	return nil
+}
+
+// deliverableNamedIn 交付物名称是否出现在任务名称中（如任务“编写产品需求文档”产出交付物“产品需求文档”）
+func deliverableNamedIn(deliverableName, taskTitle string) bool {
+	name := strings.TrimSpace(deliverableName)
+	return name != "" && strings.Contains(taskTitle, name)
+}
+
+// taskDocument 任务产出的文档：优先取关联该任务的最新文档，否则看表单提交是否带附件
+func (s *PhaseGateService) taskDocument(ctx context.Context, taskID string) (string, bool, error) {
+	var doc entity.Document
+	err := s.db.WithContext(ctx).Select("id").
+		Where("related_type = ? AND related_id = ? AND deleted_at IS NULL", "task", taskID).
+		Order("created_at DESC").First(&doc).Error
+	if err == nil {
+		return doc.ID, true, nil
+	}
+	if err != gorm.ErrRecordNotFound {
+		return "", false, fmt.Errorf("查询任务文档失败: %w", err)
+	}
+
+	var submission entity.TaskFormSubmission
+	err = s.db.WithContext(ctx).Select("files").Where("task_id = ?", taskID).
+		Order("submitted_at DESC").First(&submission).Error
+	if err == gorm.ErrRecordNotFound {
+		return "", false, nil
+	}
+	if err != nil {
+		return "", false, fmt.Errorf("查询表单提交失败: %w", err)
+	}
+	var files []json.RawMessage
+	if len(submission.Files) > 0 {
+		_ = json.Unmarshal(submission.Files, &files)
+	}
+	return "", len(files) > 0, nil
+}
+
+// phaseCriteria 项目阶段门禁条件，未配置（或为旧版自由格式）时只要求必需交付物
+func phaseCriteria(phase *entity.ProjectPhase) entity.PhaseGateCriteria {
+	criteria := entity.PhaseGateCriteria{RequireDeliverables: true}
+	if _, ok := phase.ExitCriteria["require_deliverables"]; !ok {
+		return criteria
+	}
+	data, err := json.Marshal(phase.ExitCriteria)
+	if err != nil {
+		return criteria
+	}
+	var parsed entity.PhaseGateCriteria
+	if err := json.Unmarshal(data, &parsed); err != nil {
+		log.Printf("[PhaseGate] 解析阶段门禁条件失败: phase=%s, error=%v", phase.ID, err)
+		return criteria
+	}
+	return parsed
+}
+
+func criteriaToJSONB(criteria entity.PhaseGateCriteria) (entity.JSONB, error) {
+	data, err := json.Marshal(criteria)
+	if err != nil {
+		return nil, fmt.Errorf("序列化门禁条件失败: %w", err)
+	}
+	var m entity.JSONB
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, fmt.Errorf("序列化门禁条件失败: %w", err)
+	}
+	return m, nil
+}
+
+func boolRank(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
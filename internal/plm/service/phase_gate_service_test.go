@@ -0,0 +1,69 @@
+package service
+
+import (
+	"context"
+	"testing"
+
+	"github.com/bitfantasy/nimo/internal/plm/entity"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func setupPhaseGateTest(t *testing.T) (*PhaseGateService, context.Context) {
+	t.Helper()
+	db := setupServiceTestDB(t, &entity.Project{}, &entity.Task{}, &entity.PhaseDeliverable{},
+		&entity.Document{}, &entity.TaskFormSubmission{}, &entity.User{}, &entity.Role{})
+	phaseID := "ph1"
+	require.NoError(t, db.Create(&entity.Project{
+		ID: "p1", Code: "PRJ-001", Name: "门禁测试", Phase: "evt", ManagerID: "pm", CreatedBy: "pm",
+	}).Error)
+	require.NoError(t, db.Create(&entity.Task{
+		ID: "t1", ProjectID: "p1", PhaseID: &phaseID, Code: "T1", Title: "编写产品需求文档",
+		Status: entity.TaskStatusCompleted, CreatedBy: "pm",
+	}).Error)
+	require.NoError(t, db.Create(&entity.Document{
+		ID: "doc1", Code: "DOC-001", Title: "PRD", RelatedType: "task", RelatedID: "t1",
+		FileName: "prd.pdf", FilePath: "docs/prd.pdf", FileSize: 1024, UploadedBy: "u1",
+	}).Error)
+	return NewPhaseGateService(db), context.Background()
+}
+
+func TestFulfillTaskDeliverablesSubmitsForReview(t *testing.T) {
+	svc, ctx := setupPhaseGateTest(t)
+	other := "t2"
+	require.NoError(t, svc.db.Create([]entity.PhaseDeliverable{
+		{ID: "d1", PhaseID: "ph1", Name: "产品需求文档", DeliverableType: entity.DeliverableTypeDocument, Status: entity.DeliverableStatusPending, SortOrder: 1},
+		{ID: "d2", PhaseID: "ph1", Name: "测试报告", DeliverableType: entity.DeliverableTypeDocument, Status: entity.DeliverableStatusPending, SortOrder: 2},
+		{ID: "d3", PhaseID: "ph1", Name: "产品需求文档", DeliverableType: entity.DeliverableTypeDocument, Status: entity.DeliverableStatusPending, TaskID: &other, SortOrder: 3},
+	}).Error)
+
+	require.NoError(t, svc.fulfillTaskDeliverables(ctx, "t1", "u1", nil))
+
+	var got []entity.PhaseDeliverable
+	require.NoError(t, svc.db.Order("id").Find(&got).Error)
+	require.Len(t, got, 3)
+
+	// 名称匹配的交付物登记为已提交，审批留给评审人
+	assert.Equal(t, entity.DeliverableStatusSubmitted, got[0].Status)
+	require.NotNil(t, got[0].DocumentID)
+	assert.Equal(t, "doc1", *got[0].DocumentID)
+	assert.Equal(t, "u1", *got[0].SubmittedBy)
+	assert.Nil(t, got[0].ApprovedBy)
+	assert.Nil(t, got[0].ApprovedAt)
+
+	// 同类型但名称不匹配、或绑定其他任务的交付物不登记
+	assert.Equal(t, entity.DeliverableStatusPending, got[1].Status)
+	assert.Nil(t, got[1].DocumentID)
+	assert.Equal(t, entity.DeliverableStatusPending, got[2].Status)
+}
+
+func TestCheckOverridePermission(t *testing.T) {
+	svc, ctx := setupPhaseGateTest(t)
+	require.NoError(t, svc.db.Create(&entity.Role{ID: "r1", Code: "plm_admin", Name: "PLM 管理员", Status: "active"}).Error)
+	require.NoError(t, svc.db.Exec("INSERT INTO user_roles (user_id, role_id) VALUES (?, ?)", "admin", "r1").Error)
+
+	assert.NoError(t, svc.CheckOverridePermission(ctx, "p1", "pm"))
+	assert.NoError(t, svc.CheckOverridePermission(ctx, "p1", "admin"))
+	assert.ErrorIs(t, svc.CheckOverridePermission(ctx, "p1", "u1"), ErrOverrideForbidden)
+	assert.ErrorIs(t, svc.CheckOverridePermission(ctx, "p1", ""), ErrOverrideForbidden)
+}
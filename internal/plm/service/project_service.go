@@ -13,20 +13,31 @@ import (
 	"github.com/bitfantasy/nimo/internal/plm/sse"
 	"github.com/bitfantasy/nimo/internal/plm/repository"
 	"github.com/bitfantasy/nimo/internal/shared/feishu"
+	"github.com/bitfantasy/nimo/internal/shared/formschema"
+	"github.com/bitfantasy/nimo/internal/shared/schedule"
 	"github.com/google/uuid"
 )
 
 // ProjectService 项目服务
 type ProjectService struct {
-	projectRepo  *repository.ProjectRepository
-	taskRepo     *repository.TaskRepository
-	productRepo  *repository.ProductRepository
-	feishuSvc    *FeishuIntegrationService
-	taskFormRepo *repository.TaskFormRepository
-	approvalSvc  *ApprovalService
-	feishuClient *feishu.FeishuClient
-	userRepo     *repository.UserRepository
-	bomSvc       *ProjectBOMService
+	projectRepo   *repository.ProjectRepository
+	taskRepo      *repository.TaskRepository
+	productRepo   *repository.ProductRepository
+	feishuSvc     *FeishuIntegrationService
+	taskFormRepo  *repository.TaskFormRepository
+	approvalSvc   *ApprovalService
+	feishuClient  *feishu.FeishuClient
+	userRepo      *repository.UserRepository
+	bomSvc        *ProjectBOMService
+	scheduleSvc   *ScheduleService
+	baselineSvc   *BaselineService
+	calendarSvc   *CalendarService
+	automationSvc *AutomationService
+	reviewSvc     *ReviewMeetingService
+	taskSync      *FeishuTaskSyncService
+	phaseGateSvc  *PhaseGateService
+	checklistSvc  *ChecklistService
+	taskStates    *TaskStateMirror
 }
 
 // SetApprovalService 注入审批服务（避免循环依赖）
@@ -39,6 +50,51 @@ func (s *ProjectService) SetBOMService(svc *ProjectBOMService) {
 	s.bomSvc = svc
 }
 
+// SetAutomationService 注入自动化规则服务（任务开始/完成、阶段完成后按规则执行）
+func (s *ProjectService) SetAutomationService(svc *AutomationService) {
+	s.automationSvc = svc
+}
+
+// SetReviewMeetingService 注入评审会议服务（关联评审未结束时任务不能完成）
+func (s *ProjectService) SetReviewMeetingService(svc *ReviewMeetingService) {
+	s.reviewSvc = svc
+}
+
+// SetFeishuTaskSyncService 注入飞书任务同步服务（任务修改、状态变更后推送飞书）
+func (s *ProjectService) SetFeishuTaskSyncService(svc *FeishuTaskSyncService) {
+	s.taskSync = svc
+}
+
+// SetPhaseGateService 注入阶段门禁服务（阶段完成前检查门禁，任务完成时登记交付物）
+func (s *ProjectService) SetPhaseGateService(svc *PhaseGateService) {
+	s.phaseGateSvc = svc
+}
+
+// SetChecklistService 注入任务检查项服务（必检项未勾选时不能提交/确认任务）
+func (s *ProjectService) SetChecklistService(svc *ChecklistService) {
+	s.checklistSvc = svc
+}
+
+// SetTaskStateMirror 注入任务状态镜像（直接修改任务状态时同步 plm_task 状态机）
+func (s *ProjectService) SetTaskStateMirror(m *TaskStateMirror) {
+	s.taskStates = m
+}
+
+// SetScheduleService 注入进度计划服务（任务进度/日期/依赖变化后自动重排）
+func (s *ProjectService) SetScheduleService(svc *ScheduleService) {
+	s.scheduleSvc = svc
+}
+
+// SetBaselineService 注入进度基线服务（阶段完成时保存基线，任务列表附带基线日期）
+func (s *ProjectService) SetBaselineService(svc *BaselineService) {
+	s.baselineSvc = svc
+}
+
+// SetCalendarService 注入工作日历服务（任务截止日期、逾期按工作日计算）
+func (s *ProjectService) SetCalendarService(svc *CalendarService) {
+	s.calendarSvc = svc
+}
+
 // SetFeishuClient 注入飞书客户端（用于发送消息卡片）
 func (s *ProjectService) SetFeishuClient(fc *feishu.FeishuClient, userRepo *repository.UserRepository) {
 	s.feishuClient = fc
@@ -95,6 +151,7 @@ type CreateTaskRequest struct {
 	PlannedStart   *time.Time `json:"planned_start"`
 	PlannedEnd     *time.Time `json:"planned_end"`
 	DueDate        *time.Time `json:"due_date"`
+	DurationDays   int        `json:"duration_days"` // 工期（工作日），未给出截止日期时据此按日历推算
 	EstimatedHours float64    `json:"estimated_hours"`
 }
 
@@ -110,7 +167,7 @@ type UpdateTaskRequest struct {
 	DueDate        *time.Time `json:"due_date"`
 	EstimatedHours float64    `json:"estimated_hours"`
 	ActualHours    float64    `json:"actual_hours"`
-	Progress       int        `json:"progress"`
+	Progress       *int       `json:"progress"`
 }
 
 // ProjectListResult 项目列表结果
@@ -330,6 +387,40 @@ func (s *ProjectService) UpdatePhaseStatus(ctx context.Context, phaseID string,
 		return nil, fmt.Errorf("find phase: %w", err)
 	}
 
+	// 阶段门：门禁未通过不能完成，需强制放行
+	if status == "completed" && phase.Status != "completed" {
+		if err := s.phaseGateSvc.Enforce(ctx, phase); err != nil {
+			return nil, err
+		}
+	}
+
+	return s.applyPhaseStatus(ctx, phase, status)
+}
+
+// OverridePhaseGate 强制放行阶段门禁并完成阶段，必须填写理由
+func (s *ProjectService) OverridePhaseGate(ctx context.Context, phaseID, userID, justification string) (*entity.ProjectPhase, error) {
+	justification = strings.TrimSpace(justification)
+	if justification == "" {
+		return nil, fmt.Errorf("强制放行必须填写理由")
+	}
+	phase, err := s.projectRepo.FindPhaseByID(ctx, phaseID)
+	if err != nil {
+		return nil, fmt.Errorf("find phase: %w", err)
+	}
+	if phase.Status == "completed" {
+		return nil, fmt.Errorf("阶段已完成")
+	}
+	if err := s.phaseGateSvc.CheckOverridePermission(ctx, phase.ProjectID, userID); err != nil {
+		return nil, err
+	}
+
+	if err := s.phaseGateSvc.RecordOverride(ctx, phase, userID, justification); err != nil {
+		return nil, err
+	}
+	return s.applyPhaseStatus(ctx, phase, "completed")
+}
+
+func (s *ProjectService) applyPhaseStatus(ctx context.Context, phase *entity.ProjectPhase, status string) (*entity.ProjectPhase, error) {
 	phase.Status = status
 	if status == "in_progress" {
 		now := time.Now()
@@ -344,6 +435,18 @@ func (s *ProjectService) UpdatePhaseStatus(ctx context.Context, phaseID string,
 		return nil, fmt.Errorf("update phase: %w", err)
 	}
 
+	// 阶段门：阶段完成时保存进度基线
+	if status == "completed" && s.baselineSvc != nil {
+		if _, err := s.baselineSvc.CaptureForPhaseGate(ctx, phase, "system"); err != nil {
+			log.Printf("[ProjectService] 阶段门基线保存失败 (phase=%s): %v", phase.ID, err)
+		}
+	}
+	if status == "completed" {
+		s.automationSvc.FireAsync(ctx, AutomationEvent{
+			Type: entity.AutomationTriggerPhaseComplete, ProjectID: phase.ProjectID, PhaseID: phase.ID, ToStatus: status,
+		})
+	}
+
 	return phase, nil
 }
 
@@ -397,7 +500,14 @@ func (s *ProjectService) ListTasks(ctx context.Context, projectID string, page,
 			}
 		}
 
-		// 补偿逻辑：自动启动所有前置已完成的 pending 任务
+		// 附带当前基线的计划日期
+		if s.baselineSvc != nil {
+			if err := s.baselineSvc.FillTaskBaselines(ctx, projectID, tasks); err != nil {
+				log.Printf("[ProjectService] 加载任务基线失败 (project=%s): %v", projectID, err)
+			}
+		}
+
+		// 补偿逻辑：自动启动所有前置已满足启动条件的 pending 任务
 		for i := range tasks {
 			if tasks[i].Status != "pending" || len(tasks[i].Dependencies) == 0 {
 				continue
@@ -405,7 +515,7 @@ func (s *ProjectService) ListTasks(ctx context.Context, projectID string, page,
 
 			allCompleted := true
 			for _, dep := range tasks[i].Dependencies {
-				if dep.DependsOnStatus != "completed" {
+				if !dependencyAllowsStart(dep.DependencyType, dep.DependsOnStatus) {
 					allCompleted = false
 					break
 				}
@@ -415,7 +525,10 @@ func (s *ProjectService) ListTasks(ctx context.Context, projectID string, page,
 				now := time.Now()
 				tasks[i].Status = "in_progress"
 				tasks[i].ActualStart = &now
-				_ = s.taskRepo.Update(ctx, &tasks[i])
+				if err := s.taskRepo.Update(ctx, &tasks[i]); err != nil {
+					continue
+				}
+				s.taskStates.Sync(ctx, tasks[i].ID, entity.TaskStatusPending, entity.TaskStatusInProgress, "system")
 				go s.notifyTaskActivation(context.Background(), &tasks[i])
 			}
 		}
@@ -500,6 +613,12 @@ func (s *ProjectService) CreateTask(ctx context.Context, projectID string, userI
 	if dueDate == nil {
 		dueDate = req.PlannedEnd
 	}
+	// 只给出开始日期和工期时，按项目日历（叠加负责人请假）推算截止日期
+	if dueDate == nil && req.PlannedStart != nil && req.DurationDays > 0 {
+		cal := s.calendarSvc.UserCalendar(ctx, s.calendarSvc.ProjectCalendar(ctx, projectID), req.AssigneeID)
+		end := schedule.AddWorkdays(cal, schedule.NextWorkday(cal, *req.PlannedStart), req.DurationDays-1)
+		dueDate = &end
+	}
 
 	task := &entity.Task{
 		ID:             uuid.New().String()[:32],
@@ -568,8 +687,12 @@ func (s *ProjectService) UpdateTask(ctx context.Context, id string, req *UpdateT
 	if req.ActualHours > 0 {
 		task.ActualHours = req.ActualHours
 	}
-	if req.Progress >= 0 && req.Progress <= 100 {
-		task.Progress = req.Progress
+	progressChanged := req.Progress != nil && *req.Progress != task.Progress
+	if progressChanged {
+		if *req.Progress < 0 || *req.Progress > 100 {
+			return nil, fmt.Errorf("进度必须在 0-100 之间")
+		}
+		task.Progress = *req.Progress
 	}
 
 	task.UpdatedAt = time.Now()
@@ -581,6 +704,12 @@ func (s *ProjectService) UpdateTask(ctx context.Context, id string, req *UpdateT
 	// SSE: 通知前端任务已更新
 	sse.PublishTaskUpdate(task.ProjectID, task.ID, "task_updated")
 
+	// 日期/工时/进度变化影响排程
+	if req.PlannedStart != nil || req.PlannedEnd != nil || req.DueDate != nil || req.EstimatedHours > 0 || progressChanged {
+		s.scheduleSvc.RescheduleAsync(task.ProjectID, "task_updated")
+	}
+	s.taskSync.SyncAsync(task.ID)
+
 	return task, nil
 }
 
@@ -596,6 +725,16 @@ func (s *ProjectService) UpdateTaskStatus(ctx context.Context, id string, status
 		return nil, fmt.Errorf("find task: %w", err)
 	}
 
+	if status == entity.TaskStatusCompleted && task.Status != entity.TaskStatusCompleted {
+		if err := s.reviewSvc.CheckTaskReviews(ctx, task.ID); err != nil {
+			return nil, err
+		}
+		if err := s.checklistSvc.EnsureComplete(ctx, task.ID); err != nil {
+			return nil, err
+		}
+	}
+
+	fromStatus := task.Status
 	task.Status = status
 	now := time.Now()
 
@@ -620,6 +759,26 @@ func (s *ProjectService) UpdateTaskStatus(ctx context.Context, id string, status
 	// SSE: 通知前端任务状态变更
 	sse.PublishTaskUpdate(task.ProjectID, task.ID, "status_change")
 
+	// 开始/完成后检查下游任务（SS 前置开始即可、FS 前置完成）并重排
+	if status == entity.TaskStatusInProgress || status == entity.TaskStatusCompleted {
+		go s.activateDownstreamTasks(context.Background(), task.ID, task.ProjectID)
+	}
+	s.scheduleSvc.RescheduleAsync(task.ProjectID, "status_change")
+	s.taskSync.SyncAsync(task.ID)
+	s.taskStates.Sync(ctx, task.ID, fromStatus, status, "")
+
+	if fromStatus != status {
+		ev := AutomationEvent{ProjectID: task.ProjectID, TaskID: task.ID, FromStatus: fromStatus, ToStatus: status}
+		switch status {
+		case entity.TaskStatusInProgress:
+			ev.Type = entity.AutomationTriggerTaskStart
+			s.automationSvc.FireAsync(ctx, ev)
+		case entity.TaskStatusCompleted:
+			ev.Type = entity.AutomationTriggerTaskComplete
+			s.automationSvc.FireAsync(ctx, ev)
+		}
+	}
+
 	return task, nil
 }
 
@@ -662,12 +821,23 @@ func (s *ProjectService) ListTaskComments(ctx context.Context, taskID string) ([
 }
 
 // AddTaskDependency 添加任务依赖
+// 依赖类型统一保存为 FS/SS/FF/SF；不允许跨项目和循环依赖
 func (s *ProjectService) AddTaskDependency(ctx context.Context, taskID, dependsOnID, dependencyType string, lagDays int) (*entity.TaskDependency, error) {
+	linkType, err := schedule.ParseLinkType(dependencyType)
+	if err != nil {
+		return nil, err
+	}
+	if s.scheduleSvc != nil {
+		if err := s.scheduleSvc.ValidateDependency(ctx, taskID, dependsOnID); err != nil {
+			return nil, err
+		}
+	}
+
 	dep := &entity.TaskDependency{
 		ID:              uuid.New().String()[:32],
 		TaskID:          taskID,
 		DependsOnID: dependsOnID,
-		DependencyType:  dependencyType,
+		DependencyType:  string(linkType),
 		LagDays:         lagDays,
 		CreatedAt:       time.Now(),
 	}
@@ -676,12 +846,26 @@ func (s *ProjectService) AddTaskDependency(ctx context.Context, taskID, dependsO
 		return nil, fmt.Errorf("add dependency: %w", err)
 	}
 
+	if task, err := s.taskRepo.FindByID(ctx, taskID); err == nil {
+		s.scheduleSvc.RescheduleAsync(task.ProjectID, "dependency_added")
+	}
+
 	return dep, nil
 }
 
 // RemoveTaskDependency 移除任务依赖
 func (s *ProjectService) RemoveTaskDependency(ctx context.Context, id string) error {
-	return s.taskRepo.RemoveDependency(ctx, id)
+	dep, err := s.taskRepo.FindDependencyByID(ctx, id)
+	if err != nil {
+		return fmt.Errorf("find dependency: %w", err)
+	}
+	if err := s.taskRepo.RemoveDependency(ctx, id); err != nil {
+		return err
+	}
+	if task, err := s.taskRepo.FindByID(ctx, dep.TaskID); err == nil {
+		s.scheduleSvc.RescheduleAsync(task.ProjectID, "dependency_removed")
+	}
+	return nil
 }
 
 // ListTaskDependencies 获取任务依赖列表
@@ -711,8 +895,38 @@ func (s *ProjectService) GetMyTasks(ctx context.Context, userID string, page, pa
 }
 
 // GetOverdueTasks 获取逾期任务
+// 逾期天数按项目日历（叠加负责人请假）计：截止日之后到今天的工作日数，
+// 截止日后紧接节假日/周末、尚未经过工作日的任务不算逾期
 func (s *ProjectService) GetOverdueTasks(ctx context.Context, projectID string) ([]entity.Task, error) {
-	return s.taskRepo.ListOverdue(ctx, projectID)
+	tasks, err := s.taskRepo.ListOverdue(ctx, projectID)
+	if err != nil {
+		return nil, err
+	}
+
+	today := schedule.Day(time.Now())
+	cals := make(map[string]schedule.Calendar) // projectID / projectID+userID → 日历
+	overdue := make([]entity.Task, 0, len(tasks))
+	for _, t := range tasks {
+		cal, ok := cals[t.ProjectID]
+		if !ok {
+			cal = s.calendarSvc.ProjectCalendar(ctx, t.ProjectID)
+			cals[t.ProjectID] = cal
+		}
+		if t.AssigneeID != nil {
+			key := t.ProjectID + "/" + *t.AssigneeID
+			userCal, ok := cals[key]
+			if !ok {
+				userCal = s.calendarSvc.UserCalendar(ctx, cal, *t.AssigneeID)
+				cals[key] = userCal
+			}
+			cal = userCal
+		}
+		t.OverdueDays = schedule.WorkdaysBetween(cal, schedule.Day(*t.DueDate).AddDate(0, 0, 1), today)
+		if t.OverdueDays > 0 {
+			overdue = append(overdue, t)
+		}
+	}
+	return overdue, nil
 }
 
 // CompleteMyTask 工程师完成任务（含表单提交）
@@ -733,6 +947,19 @@ func (s *ProjectService) CompleteMyTask(ctx context.Context, taskID, userID stri
 		return fmt.Errorf("只有进行中的任务才能完成，当前状态: %s", task.Status)
 	}
 
+	// FF/SF 依赖约束完成时间
+	if err := checkFinishDependencies(ctx, s.taskRepo, taskID); err != nil {
+		return err
+	}
+	// 关联评审须已结束
+	if err := s.reviewSvc.CheckTaskReviews(ctx, taskID); err != nil {
+		return err
+	}
+	// 必检项须全部勾选
+	if err := s.checklistSvc.EnsureComplete(ctx, taskID); err != nil {
+		return err
+	}
+
 	// 4. 检查表单
 	if s.taskFormRepo != nil {
 		form, err := s.taskFormRepo.FindByTaskID(ctx, taskID)
@@ -746,21 +973,13 @@ func (s *ProjectService) CompleteMyTask(ctx context.Context, taskID, userID stri
 				return fmt.Errorf("此任务需要填写表单才能完成")
 			}
 
-			// 验证 required 字段
-			var fields []struct {
-				Key      string `json:"key"`
-				Label    string `json:"label"`
-				Required bool   `json:"required"`
+			// 按字段定义校验（必填、范围、正则、可选值、附件限制、显示条件）
+			schema, err := formschema.Parse(form.Fields)
+			if err != nil {
+				return fmt.Errorf("解析表单定义失败: %w", err)
 			}
-			if err := json.Unmarshal(form.Fields, &fields); err == nil {
-				for _, f := range fields {
-					if f.Required {
-						val, ok := formData[f.Key]
-						if !ok || val == nil || val == "" {
-							return fmt.Errorf("必填字段 [%s] 未填写", f.Label)
-						}
-					}
-				}
+			if err := schema.Validate(formData); err != nil {
+				return err
 			}
 
 			// 计算版本号
@@ -783,6 +1002,9 @@ func (s *ProjectService) CompleteMyTask(ctx context.Context, taskID, userID stri
 			if err := s.taskFormRepo.CreateSubmission(ctx, submission); err != nil {
 				return fmt.Errorf("保存表单提交失败: %w", err)
 			}
+			if err := s.taskFormRepo.DeleteDraft(ctx, taskID, userID); err != nil {
+				log.Printf("[CompleteMyTask] 删除表单草稿失败: task=%s, user=%s, error=%v", taskID, userID, err)
+			}
 
 			// 注意：bom_upload 字段的BOM创建在审批通过后执行，不在提交时
 		}
@@ -820,6 +1042,7 @@ func (s *ProjectService) CompleteMyTask(ctx context.Context, taskID, userID stri
 	if err := s.taskRepo.Update(ctx, task); err != nil {
 		return fmt.Errorf("更新任务状态失败: %w", err)
 	}
+	s.taskStates.Sync(ctx, task.ID, entity.TaskStatusInProgress, entity.TaskStatusSubmitted, userID)
 
 	// 8. 更新项目进度
 	go s.updateProjectProgress(context.Background(), task.ProjectID)
@@ -831,9 +1054,9 @@ func (s *ProjectService) CompleteMyTask(ctx context.Context, taskID, userID stri
 }
 
 // ProcessBOMUploadFields 检查表单字段定义，找出 bom_upload 类型的字段，
-// 然后从 formData 中提取已解析的BOM数据，自动创建项目BOM。
+// 然后从 formData 中提取已解析的BOM数据，自动创建项目BOM，返回创建（或合并到）的BOM ID。
 // 由审批通过时调用。
-func (s *ProjectService) ProcessBOMUploadFields(ctx context.Context, form *entity.TaskForm, formData map[string]interface{}, projectID, userID string) {
+func (s *ProjectService) ProcessBOMUploadFields(ctx context.Context, form *entity.TaskForm, formData map[string]interface{}, projectID, userID string) []string {
 	log.Printf("[ProcessBOMUploadFields] entry: formID=%s, taskID=%s, projectID=%s", form.ID, form.TaskID, projectID)
 
 	// 解析 form.Fields 找出 type=bom_upload 的字段key
@@ -845,9 +1068,10 @@ func (s *ProjectService) ProcessBOMUploadFields(ctx context.Context, form *entit
 	}
 	if err := json.Unmarshal(form.Fields, &fields); err != nil {
 		log.Printf("[WARN] processBOMUploadFields: parse form fields failed: %v", err)
-		return
+		return nil
 	}
 
+	var bomIDs []string
 	for _, f := range fields {
 		if f.Type != "bom_upload" && f.Type != "ebom_control" && f.Type != "pbom_control" && f.Type != "mbom_control" {
 			continue
@@ -961,7 +1185,9 @@ func (s *ProjectService) ProcessBOMUploadFields(ctx context.Context, form *entit
 			continue
 		}
 		log.Printf("[ProcessBOMUploadFields] BOM created successfully: field=%s, bomType=%s, bomID=%s, items=%d", f.Key, bomType, bomID, len(parsedItems))
+		bomIDs = append(bomIDs, bomID)
 	}
+	return bomIDs
 }
 
 // toStr 安全地将 interface{} 转为 string
@@ -1086,6 +1312,8 @@ func (s *ProjectService) processRoleAssignment(ctx context.Context, task *entity
 				s.taskRepo.UpsertRoleAssignment(ctx, assignment)
 				if err := s.taskRepo.UpdateAssigneeByRole(ctx, task.ProjectID, roleCode, assignedUserID); err != nil {
 					log.Printf("[processRoleAssignment] 更新任务assignee失败 (role=%s): %v", roleCode, err)
+				} else {
+					s.taskSync.SyncRoleTasksAsync(task.ProjectID, roleCode)
 				}
 				log.Printf("[processRoleAssignment] 角色绑定成功: project=%s, role=%s, user=%s", task.ProjectID, roleCode, assignedUserID)
 				// SSE: 通知前端角色已分配
@@ -1113,6 +1341,8 @@ func (s *ProjectService) processRoleAssignment(ctx context.Context, task *entity
 			s.taskRepo.UpsertRoleAssignment(ctx, assignment)
 			if err := s.taskRepo.UpdateAssigneeByRole(ctx, task.ProjectID, roleCode, assignedUserID); err != nil {
 				log.Printf("[processRoleAssignment] 更新任务assignee失败 (role=%s): %v", roleCode, err)
+			} else {
+				s.taskSync.SyncRoleTasksAsync(task.ProjectID, roleCode)
 			}
 			log.Printf("[processRoleAssignment] 角色绑定成功: project=%s, role=%s, user=%s", task.ProjectID, roleCode, assignedUserID)
 			// SSE: 通知前端角色已分配
@@ -1149,6 +1379,7 @@ func (s *ProjectService) AssignRoles(ctx context.Context, projectID, assignedBy
 		if err := s.taskRepo.UpdateAssigneeByRole(ctx, projectID, a.RoleCode, a.UserID); err != nil {
 			return fmt.Errorf("更新角色 %s 的任务失败: %w", a.RoleCode, err)
 		}
+		s.taskSync.SyncRoleTasksAsync(projectID, a.RoleCode)
 	}
 
 	// SSE: 通知前端角色已分配，任务可能更新了 assignee
@@ -1176,6 +1407,12 @@ func (s *ProjectService) ConfirmTask(ctx context.Context, projectID, taskID, use
 	if task.Status != entity.TaskStatusSubmitted {
 		return fmt.Errorf("只有已提交的任务才能确认，当前状态: %s", task.Status)
 	}
+	if err := s.reviewSvc.CheckTaskReviews(ctx, taskID); err != nil {
+		return err
+	}
+	if err := s.checklistSvc.EnsureComplete(ctx, taskID); err != nil {
+		return err
+	}
 
 	// 3. 更新状态为已完成
 	now := time.Now()
@@ -1187,6 +1424,7 @@ func (s *ProjectService) ConfirmTask(ctx context.Context, projectID, taskID, use
 	if err := s.taskRepo.Update(ctx, task); err != nil {
 		return fmt.Errorf("更新任务状态失败: %w", err)
 	}
+	s.taskStates.Sync(ctx, task.ID, entity.TaskStatusSubmitted, entity.TaskStatusCompleted, userID)
 
 	// 4. 确认时处理表单副作用（无审批的任务走这条路径）
 	//    - role_assignment / user → 角色绑定（同步，必须在激活下游任务之前完成）
@@ -1196,16 +1434,19 @@ func (s *ProjectService) ConfirmTask(ctx context.Context, projectID, taskID, use
 	// BOM processing - synchronous to prevent data loss on server restart
 	{
 		bgCtx := context.Background()
+		var bomIDs []string
 		if s.bomSvc != nil && s.taskFormRepo != nil {
 			form, ferr := s.taskFormRepo.FindByTaskID(bgCtx, taskID)
 			if ferr == nil && form != nil {
 				submission, serr := s.taskFormRepo.FindLatestSubmission(bgCtx, taskID)
 				if serr == nil && submission != nil {
 					formData := map[string]interface{}(submission.Data)
-					s.ProcessBOMUploadFields(bgCtx, form, formData, task.ProjectID, userID)
+					bomIDs = s.ProcessBOMUploadFields(bgCtx, form, formData, task.ProjectID, userID)
 				}
 			}
 		}
+		// 上传的BOM/文档自动登记为阶段交付物
+		s.phaseGateSvc.FulfillTaskDeliverables(bgCtx, taskID, userID, bomIDs)
 	}
 
 	// 5. 更新项目进度
@@ -1213,10 +1454,20 @@ func (s *ProjectService) ConfirmTask(ctx context.Context, projectID, taskID, use
 
 	// SSE: 通知前端任务确认
 	sse.PublishTaskUpdate(task.ProjectID, task.ID, "task_confirmed")
+	s.taskSync.SyncAsync(task.ID)
 
 	// 6. 检查并激活依赖此任务的下游任务
 	go s.activateDownstreamTasks(context.Background(), task.ID, task.ProjectID)
 
+	// 7. 实际完成日变化，重排后续任务
+	s.scheduleSvc.RescheduleAsync(task.ProjectID, "task_confirmed")
+
+	// 8. 自动化规则
+	s.automationSvc.FireAsync(ctx, AutomationEvent{
+		Type: entity.AutomationTriggerTaskComplete, ProjectID: task.ProjectID, TaskID: task.ID,
+		FromStatus: entity.TaskStatusSubmitted, ToStatus: entity.TaskStatusCompleted, OperatorID: userID,
+	})
+
 	return nil
 }
 
@@ -1276,7 +1527,8 @@ func (s *ProjectService) notifyTaskActivation(ctx context.Context, task *entity.
 	}
 }
 
-// activateDownstreamTasks 任务完成后检查并激活依赖此任务的下游任务
+// activateDownstreamTasks 任务开始或完成后检查并激活依赖此任务的下游任务
+// 按依赖类型判断（FS 需前置完成、SS 需前置开始）；被激活的任务继续检查其下游
 func (s *ProjectService) activateDownstreamTasks(ctx context.Context, completedTaskID, projectID string) {
 	// 查询所有依赖此任务的下游任务
 	downstreamTasks, err := s.taskRepo.ListDependentTasks(ctx, completedTaskID)
@@ -1307,11 +1559,10 @@ func (s *ProjectService) activateDownstreamTasks(ctx context.Context, completedT
 			continue
 		}
 
-		// 检查是否所有前置都已完成
+		// 检查是否所有前置都满足启动条件
 		allCompleted := true
-		for _, depID := range depIDs {
-			status := statusMap[depID]
-			if status != "completed" {
+		for _, d := range deps {
+			if !dependencyAllowsStart(d.DependencyType, statusMap[d.DependsOnID]) {
 				allCompleted = false
 				break
 			}
@@ -1323,12 +1574,15 @@ func (s *ProjectService) activateDownstreamTasks(ctx context.Context, completedT
 			downstreamTasks[i].ActualStart = &now
 			downstreamTasks[i].UpdatedAt = now
 			if err := s.taskRepo.Update(ctx, &downstreamTasks[i]); err == nil {
+				s.taskStates.Sync(ctx, downstreamTasks[i].ID, entity.TaskStatusPending, entity.TaskStatusInProgress, "system")
 				// SSE: 通知前端任务被激活
 				sse.PublishTaskUpdate(projectID, downstreamTasks[i].ID, "task_activated")
 				if downstreamTasks[i].AssigneeID != nil && *downstreamTasks[i].AssigneeID != "" {
 					sse.PublishUserTaskUpdate(*downstreamTasks[i].AssigneeID, projectID, downstreamTasks[i].ID, "task_activated")
 				}
 				go s.notifyTaskActivation(context.Background(), &downstreamTasks[i])
+				s.taskSync.SyncAsync(downstreamTasks[i].ID)
+				s.activateDownstreamTasks(ctx, downstreamTasks[i].ID, projectID)
 			}
 		}
 	}
@@ -1363,6 +1617,7 @@ func (s *ProjectService) RejectTask(ctx context.Context, projectID, taskID, user
 	if err := s.taskRepo.Update(ctx, task); err != nil {
 		return fmt.Errorf("更新任务状态失败: %w", err)
 	}
+	s.taskStates.Sync(ctx, task.ID, entity.TaskStatusSubmitted, entity.TaskStatusInProgress, userID)
 
 	// 4. 添加驳回评论
 	if reason != "" {
@@ -1377,6 +1632,15 @@ func (s *ProjectService) RejectTask(ctx context.Context, projectID, taskID, user
 		s.taskRepo.AddComment(ctx, comment)
 	}
 
+	// 驳回意见记录到被驳回的表单提交版本上
+	if s.taskFormRepo != nil {
+		if submission, _ := s.taskFormRepo.FindLatestSubmission(ctx, taskID); submission != nil && submission.Version > 0 {
+			if err := s.taskFormRepo.MarkSubmissionRejected(ctx, submission.ID, userID, reason, time.Now()); err != nil {
+				log.Printf("[RejectTask] 记录驳回意见失败: task=%s, version=%d, error=%v", taskID, submission.Version, err)
+			}
+		}
+	}
+
 	// 5. 更新项目进度
 	go s.updateProjectProgress(context.Background(), task.ProjectID)
 
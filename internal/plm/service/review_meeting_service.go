@@ -0,0 +1,611 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/bitfantasy/nimo/internal/plm/entity"
+	"github.com/bitfantasy/nimo/internal/shared/feishu"
+	"github.com/bitfantasy/nimo/internal/shared/schedule"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// =============================================================================
+// 评审会议
+//
+// 设计评审关联任务、阶段评审关联阶段、BOM/ECN 评审关联对应单据；
+// 创建时通过飞书日历邀请参会人，结束时记录结论，待办事项转为项目任务。
+// 关联任务的评审未结束前，该任务不能完成
+// =============================================================================
+
+// ReviewMeetingService 评审会议服务
+type ReviewMeetingService struct {
+	db           *gorm.DB
+	projectSvc   *ProjectService
+	feishuClient *feishu.FeishuClient
+}
+
+// NewReviewMeetingService 创建评审会议服务
+func NewReviewMeetingService(db *gorm.DB) *ReviewMeetingService {
+	return &ReviewMeetingService{db: db}
+}
+
+// SetProjectService 注入项目服务（待办转任务）
+func (s *ReviewMeetingService) SetProjectService(svc *ProjectService) {
+	s.projectSvc = svc
+}
+
+// SetFeishuClient 注入飞书客户端（日历邀请）
+func (s *ReviewMeetingService) SetFeishuClient(fc *feishu.FeishuClient) {
+	s.feishuClient = fc
+}
+
+// SaveReviewMeetingRequest 创建/修改评审会议请求
+type SaveReviewMeetingRequest struct {
+	Title           string          `json:"title"`
+	MeetingType     string          `json:"meeting_type"` // DESIGN/PHASE/BOM/ECN
+	ProjectID       string          `json:"project_id"`
+	TaskID          string          `json:"task_id"`
+	PhaseID         string          `json:"phase_id"`
+	BOMID           string          `json:"bom_id"`
+	ECNID           string          `json:"ecn_id"`
+	ScheduledAt     *time.Time      `json:"scheduled_at"`
+	DurationMinutes int             `json:"duration_minutes"`
+	Location        string          `json:"location"`
+	AttendeeIDs     []string        `json:"attendee_ids"`
+	Agenda          string          `json:"agenda"`
+	Documents       json.RawMessage `json:"documents"`
+	SendInvite      *bool           `json:"send_invite"` // 默认发送飞书日历邀请
+}
+
+// ConcludeReviewMeetingRequest 记录评审结论请求
+type ConcludeReviewMeetingRequest struct {
+	Conclusion  string                    `json:"conclusion"`
+	ActionItems []entity.ReviewActionItem `json:"action_items"`
+	CreateTasks *bool                     `json:"create_tasks"` // 默认将待办转为任务
+}
+
+// ReviewMeetingQuery 评审会议查询条件
+type ReviewMeetingQuery struct {
+	ProjectID   string
+	TaskID      string
+	PhaseID     string
+	BOMID       string
+	ECNID       string
+	MeetingType string
+	Status      string
+}
+
+// ListMeetings 评审会议列表
+func (s *ReviewMeetingService) ListMeetings(ctx context.Context, q ReviewMeetingQuery, page, pageSize int) ([]entity.ReviewMeeting, int64, error) {
+	query := s.db.WithContext(ctx).Model(&entity.ReviewMeeting{})
+	filters := []struct{ column, value string }{
+		{"project_id", q.ProjectID},
+		{"task_id", q.TaskID},
+		{"phase_id", q.PhaseID},
+		{"bom_id", q.BOMID},
+		{"ecn_id", q.ECNID},
+		{"meeting_type", q.MeetingType},
+		{"status", q.Status},
+	}
+	for _, f := range filters {
+		if f.value != "" {
+			query = query.Where(f.column+" = ?", f.value)
+		}
+	}
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, fmt.Errorf("查询评审会议失败: %w", err)
+	}
+	var meetings []entity.ReviewMeeting
+	if err := query.Order("scheduled_at DESC").Offset((page - 1) * pageSize).Limit(pageSize).Find(&meetings).Error; err != nil {
+		return nil, 0, fmt.Errorf("查询评审会议失败: %w", err)
+	}
+	return meetings, total, nil
+}
+
+// GetMeeting 获取评审会议
+func (s *ReviewMeetingService) GetMeeting(ctx context.Context, id string) (*entity.ReviewMeeting, error) {
+	var meeting entity.ReviewMeeting
+	if err := s.db.WithContext(ctx).Where("id = ?", id).First(&meeting).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, fmt.Errorf("评审会议不存在")
+		}
+		return nil, fmt.Errorf("查询评审会议失败: %w", err)
+	}
+	return &meeting, nil
+}
+
+// CreateMeeting 创建评审会议，并通过飞书日历邀请参会人
+func (s *ReviewMeetingService) CreateMeeting(ctx context.Context, req *SaveReviewMeetingRequest, userID string) (*entity.ReviewMeeting, error) {
+	if strings.TrimSpace(req.Title) == "" {
+		return nil, fmt.Errorf("会议主题不能为空")
+	}
+	if req.ScheduledAt == nil {
+		return nil, fmt.Errorf("会议时间不能为空")
+	}
+	if req.DurationMinutes <= 0 {
+		req.DurationMinutes = 60
+	}
+	meeting := &entity.ReviewMeeting{
+		ID:              uuid.New().String(),
+		Title:           strings.TrimSpace(req.Title),
+		MeetingType:     strings.ToUpper(req.MeetingType),
+		TaskID:          req.TaskID,
+		PhaseID:         req.PhaseID,
+		BOMID:           req.BOMID,
+		ECNID:           req.ECNID,
+		ScheduledAt:     *req.ScheduledAt,
+		DurationMinutes: req.DurationMinutes,
+		Location:        req.Location,
+		OrganizerID:     userID,
+		Agenda:          req.Agenda,
+		Documents:       req.Documents,
+		Status:          entity.ReviewMeetingStatusScheduled,
+		ActionItems:     json.RawMessage("[]"),
+		CreatedBy:       userID,
+		CreatedAt:       time.Now(),
+		UpdatedAt:       time.Now(),
+	}
+	projectID, err := s.resolveSubject(ctx, meeting, req.ProjectID)
+	if err != nil {
+		return nil, err
+	}
+	meeting.ProjectID = projectID
+
+	attendees, err := s.loadAttendees(ctx, req.AttendeeIDs)
+	if err != nil {
+		return nil, err
+	}
+	meeting.Attendees, _ = json.Marshal(toReviewAttendees(attendees))
+
+	if err := s.db.WithContext(ctx).Create(meeting).Error; err != nil {
+		return nil, fmt.Errorf("创建评审会议失败: %w", err)
+	}
+
+	if req.SendInvite == nil || *req.SendInvite {
+		s.sendInvite(ctx, meeting, attendees)
+	}
+	return meeting, nil
+}
+
+// UpdateMeeting 修改未结束的评审会议；改期时同步飞书日历
+func (s *ReviewMeetingService) UpdateMeeting(ctx context.Context, id string, req *SaveReviewMeetingRequest) (*entity.ReviewMeeting, error) {
+	meeting, err := s.GetMeeting(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if !reviewMeetingOpen(meeting.Status) {
+		return nil, fmt.Errorf("评审会议已%s，不能修改", reviewMeetingStatusText(meeting.Status))
+	}
+
+	timeChanged := false
+	if req.Title != "" {
+		meeting.Title = strings.TrimSpace(req.Title)
+	}
+	if req.ScheduledAt != nil && !req.ScheduledAt.Equal(meeting.ScheduledAt) {
+		meeting.ScheduledAt = *req.ScheduledAt
+		timeChanged = true
+	}
+	if req.DurationMinutes > 0 && req.DurationMinutes != meeting.DurationMinutes {
+		meeting.DurationMinutes = req.DurationMinutes
+		timeChanged = true
+	}
+	if req.Location != "" {
+		meeting.Location = req.Location
+	}
+	if req.Agenda != "" {
+		meeting.Agenda = req.Agenda
+	}
+	if len(req.Documents) > 0 {
+		meeting.Documents = req.Documents
+	}
+	if req.AttendeeIDs != nil {
+		attendees, err := s.loadAttendees(ctx, req.AttendeeIDs)
+		if err != nil {
+			return nil, err
+		}
+		meeting.Attendees, _ = json.Marshal(toReviewAttendees(attendees))
+	}
+	meeting.UpdatedAt = time.Now()
+	if err := s.db.WithContext(ctx).Save(meeting).Error; err != nil {
+		return nil, fmt.Errorf("更新评审会议失败: %w", err)
+	}
+
+	if timeChanged && meeting.FeishuCalendarEventID != "" && s.feishuClient != nil {
+		end := meeting.ScheduledAt.Add(time.Duration(meeting.DurationMinutes) * time.Minute)
+		if err := s.feishuClient.UpdateMeetingTime(ctx, meeting.FeishuCalendarEventID, meeting.ScheduledAt, end); err != nil {
+			log.Printf("[ReviewMeeting] 同步飞书日历改期失败 (meeting=%s): %v", meeting.ID, err)
+		}
+	}
+	return meeting, nil
+}
+
+// StartMeeting 标记会议进行中
+func (s *ReviewMeetingService) StartMeeting(ctx context.Context, id string) (*entity.ReviewMeeting, error) {
+	meeting, err := s.GetMeeting(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if meeting.Status != entity.ReviewMeetingStatusScheduled {
+		return nil, fmt.Errorf("只有待召开的评审会议才能开始，当前状态: %s", meeting.Status)
+	}
+	meeting.Status = entity.ReviewMeetingStatusInProgress
+	meeting.UpdatedAt = time.Now()
+	if err := s.db.WithContext(ctx).Model(meeting).
+		Updates(map[string]interface{}{"status": meeting.Status, "updated_at": meeting.UpdatedAt}).Error; err != nil {
+		return nil, fmt.Errorf("更新评审会议失败: %w", err)
+	}
+	return meeting, nil
+}
+
+// CancelMeeting 取消会议并删除飞书日历事件；取消后不再阻止关联任务完成
+func (s *ReviewMeetingService) CancelMeeting(ctx context.Context, id string) (*entity.ReviewMeeting, error) {
+	meeting, err := s.GetMeeting(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if !reviewMeetingOpen(meeting.Status) {
+		return nil, fmt.Errorf("评审会议已%s，不能取消", reviewMeetingStatusText(meeting.Status))
+	}
+	meeting.Status = entity.ReviewMeetingStatusCancelled
+	meeting.UpdatedAt = time.Now()
+	if err := s.db.WithContext(ctx).Model(meeting).
+		Updates(map[string]interface{}{"status": meeting.Status, "updated_at": meeting.UpdatedAt}).Error; err != nil {
+		return nil, fmt.Errorf("更新评审会议失败: %w", err)
+	}
+	if meeting.FeishuCalendarEventID != "" && s.feishuClient != nil {
+		if err := s.feishuClient.CancelMeeting(ctx, meeting.FeishuCalendarEventID); err != nil {
+			log.Printf("[ReviewMeeting] 删除飞书日历事件失败 (meeting=%s): %v", meeting.ID, err)
+		}
+	}
+	return meeting, nil
+}
+
+// ConcludeMeeting 记录评审结论；待办默认转为项目任务
+// 转任务失败时已创建的任务会保留在待办中，会议保持未结束，修正后可再次提交
+func (s *ReviewMeetingService) ConcludeMeeting(ctx context.Context, id string, req *ConcludeReviewMeetingRequest, userID string) (*entity.ReviewMeeting, error) {
+	meeting, err := s.GetMeeting(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if !reviewMeetingOpen(meeting.Status) {
+		return nil, fmt.Errorf("评审会议已%s，不能再次记录结论", reviewMeetingStatusText(meeting.Status))
+	}
+	if strings.TrimSpace(req.Conclusion) == "" {
+		return nil, fmt.Errorf("评审结论不能为空")
+	}
+	items := s.mergeActionItems(meeting, req.ActionItems)
+	if err := validateActionItems(items); err != nil {
+		return nil, err
+	}
+
+	meeting.Conclusion = strings.TrimSpace(req.Conclusion)
+	var convertErr error
+	if req.CreateTasks == nil || *req.CreateTasks {
+		convertErr = s.createActionItemTasks(ctx, meeting, items, userID)
+	}
+	meeting.ActionItems, _ = json.Marshal(items)
+	meeting.UpdatedAt = time.Now()
+	if convertErr == nil {
+		now := time.Now()
+		meeting.Status = entity.ReviewMeetingStatusCompleted
+		meeting.ConcludedBy = userID
+		meeting.ConcludedAt = &now
+	}
+	if err := s.db.WithContext(ctx).Save(meeting).Error; err != nil {
+		return nil, fmt.Errorf("保存评审结论失败: %w", err)
+	}
+	if convertErr != nil {
+		return meeting, convertErr
+	}
+	return meeting, nil
+}
+
+// ConvertActionItems 将已结束会议中尚未转任务的待办转为项目任务
+func (s *ReviewMeetingService) ConvertActionItems(ctx context.Context, id, userID string) (*entity.ReviewMeeting, error) {
+	meeting, err := s.GetMeeting(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if meeting.Status != entity.ReviewMeetingStatusCompleted {
+		return nil, fmt.Errorf("请先记录评审结论")
+	}
+	items := s.mergeActionItems(meeting, nil)
+	convertErr := s.createActionItemTasks(ctx, meeting, items, userID)
+	meeting.ActionItems, _ = json.Marshal(items)
+	meeting.UpdatedAt = time.Now()
+	if err := s.db.WithContext(ctx).Model(meeting).
+		Updates(map[string]interface{}{"action_items": meeting.ActionItems, "updated_at": meeting.UpdatedAt}).Error; err != nil {
+		return nil, fmt.Errorf("保存评审待办失败: %w", err)
+	}
+	return meeting, convertErr
+}
+
+// CheckTaskReviews 检查任务关联的评审是否都已结束（未注入服务时不检查）
+func (s *ReviewMeetingService) CheckTaskReviews(ctx context.Context, taskID string) error {
+	if s == nil {
+		return nil
+	}
+	return checkTaskReviews(s.db.WithContext(ctx), taskID)
+}
+
+// checkTaskReviews 在给定连接（可以是事务）上检查任务关联的评审是否都已结束
+func checkTaskReviews(db *gorm.DB, taskID string) error {
+	var open []entity.ReviewMeeting
+	if err := db.
+		Where("task_id = ? AND status IN ?", taskID,
+			[]string{entity.ReviewMeetingStatusScheduled, entity.ReviewMeetingStatusInProgress}).
+		Order("scheduled_at ASC").Find(&open).Error; err != nil {
+		return fmt.Errorf("查询评审会议失败: %w", err)
+	}
+	if len(open) > 0 {
+		return fmt.Errorf("评审会议[%s]尚未结束，请先记录评审结论再完成任务", open[0].Title)
+	}
+	return nil
+}
+
+// resolveSubject 按会议类型校验关联对象，返回所属项目
+func (s *ReviewMeetingService) resolveSubject(ctx context.Context, meeting *entity.ReviewMeeting, projectID string) (string, error) {
+	db := s.db.WithContext(ctx)
+	owner := ""
+	check := func(found string) error {
+		if projectID != "" && found != "" && found != projectID {
+			return fmt.Errorf("关联对象不属于该项目")
+		}
+		if owner == "" {
+			owner = found
+		}
+		return nil
+	}
+
+	switch meeting.MeetingType {
+	case entity.ReviewMeetingTypeDesign:
+		if meeting.TaskID == "" {
+			return "", fmt.Errorf("设计评审需关联任务")
+		}
+	case entity.ReviewMeetingTypePhase:
+		if meeting.PhaseID == "" {
+			return "", fmt.Errorf("阶段评审需关联阶段")
+		}
+	case entity.ReviewMeetingTypeBOM:
+		if meeting.BOMID == "" {
+			return "", fmt.Errorf("BOM评审需关联BOM")
+		}
+	case entity.ReviewMeetingTypeECN:
+		if meeting.ECNID == "" {
+			return "", fmt.Errorf("ECN评审需关联ECN")
+		}
+	default:
+		return "", fmt.Errorf("不支持的评审类型: %s", meeting.MeetingType)
+	}
+
+	if meeting.TaskID != "" {
+		var task entity.Task
+		if err := db.Select("id, project_id, phase_id").Where("id = ?", meeting.TaskID).First(&task).Error; err != nil {
+			return "", fmt.Errorf("关联任务不存在")
+		}
+		if err := check(task.ProjectID); err != nil {
+			return "", err
+		}
+		if meeting.PhaseID == "" && task.PhaseID != nil {
+			meeting.PhaseID = *task.PhaseID
+		}
+	}
+	if meeting.PhaseID != "" {
+		var phase entity.ProjectPhase
+		if err := db.Select("id, project_id").Where("id = ?", meeting.PhaseID).First(&phase).Error; err != nil {
+			return "", fmt.Errorf("关联阶段不存在")
+		}
+		if err := check(phase.ProjectID); err != nil {
+			return "", err
+		}
+	}
+	if meeting.BOMID != "" {
+		var bom entity.ProjectBOM
+		if err := db.Select("id, project_id").Where("id = ?", meeting.BOMID).First(&bom).Error; err != nil {
+			return "", fmt.Errorf("关联BOM不存在")
+		}
+		if err := check(bom.ProjectID); err != nil {
+			return "", err
+		}
+	}
+	if meeting.ECNID != "" {
+		var count int64
+		if err := db.Model(&entity.ECN{}).Where("id = ?", meeting.ECNID).Count(&count).Error; err != nil || count == 0 {
+			return "", fmt.Errorf("关联ECN不存在")
+		}
+	}
+	if owner == "" {
+		owner = projectID
+	}
+	return owner, nil
+}
+
+// loadAttendees 查询参会人，存在无效用户时报错
+func (s *ReviewMeetingService) loadAttendees(ctx context.Context, userIDs []string) ([]entity.User, error) {
+	if len(userIDs) == 0 {
+		return nil, nil
+	}
+	var users []entity.User
+	if err := s.db.WithContext(ctx).Where("id IN ?", userIDs).Find(&users).Error; err != nil {
+		return nil, fmt.Errorf("查询参会人失败: %w", err)
+	}
+	found := make(map[string]bool, len(users))
+	for _, u := range users {
+		found[u.ID] = true
+	}
+	for _, id := range userIDs {
+		if !found[id] {
+			return nil, fmt.Errorf("参会人不存在: %s", id)
+		}
+	}
+	return users, nil
+}
+
+func toReviewAttendees(users []entity.User) []entity.ReviewAttendee {
+	attendees := make([]entity.ReviewAttendee, 0, len(users))
+	for _, u := range users {
+		attendees = append(attendees, entity.ReviewAttendee{UserID: u.ID, Name: u.Name})
+	}
+	return attendees
+}
+
+// sendInvite 创建飞书日历事件邀请参会人（组织者一并邀请），失败只记录日志
+func (s *ReviewMeetingService) sendInvite(ctx context.Context, meeting *entity.ReviewMeeting, attendees []entity.User) {
+	if s.feishuClient == nil {
+		return
+	}
+	var organizer entity.User
+	if err := s.db.WithContext(ctx).Where("id = ?", meeting.OrganizerID).First(&organizer).Error; err == nil {
+		attendees = append(attendees, organizer)
+	}
+	seen := make(map[string]bool)
+	var openIDs []string
+	for _, u := range attendees {
+		if u.FeishuOpenID == "" || seen[u.FeishuOpenID] {
+			continue
+		}
+		seen[u.FeishuOpenID] = true
+		openIDs = append(openIDs, u.FeishuOpenID)
+	}
+	if len(openIDs) == 0 {
+		return
+	}
+
+	description := meeting.Agenda
+	if meeting.Location != "" {
+		description = fmt.Sprintf("地点: %s\n\n%s", meeting.Location, description)
+	}
+	eventID, err := s.feishuClient.CreateMeeting(ctx, feishu.CreateMeetingReq{
+		Summary:          fmt.Sprintf("[%s评审] %s", reviewMeetingTypeText(meeting.MeetingType), meeting.Title),
+		Description:      description,
+		StartTime:        meeting.ScheduledAt,
+		EndTime:          meeting.ScheduledAt.Add(time.Duration(meeting.DurationMinutes) * time.Minute),
+		AttendeeIDs:      openIDs,
+		NeedNotification: true,
+	})
+	if err != nil {
+		log.Printf("[ReviewMeeting] 创建飞书日历事件失败 (meeting=%s): %v", meeting.ID, err)
+		return
+	}
+	meeting.FeishuCalendarEventID = eventID
+	if err := s.db.WithContext(ctx).Model(meeting).Update("feishu_calendar_event_id", eventID).Error; err != nil {
+		log.Printf("[ReviewMeeting] 保存飞书日历事件ID失败 (meeting=%s): %v", meeting.ID, err)
+	}
+}
+
+// mergeActionItems 以请求中的待办为准（为空时沿用已保存的），保留已转任务的 TaskID
+func (s *ReviewMeetingService) mergeActionItems(meeting *entity.ReviewMeeting, incoming []entity.ReviewActionItem) []entity.ReviewActionItem {
+	var saved []entity.ReviewActionItem
+	if len(meeting.ActionItems) > 0 {
+		json.Unmarshal(meeting.ActionItems, &saved)
+	}
+	if incoming == nil {
+		return saved
+	}
+	converted := make(map[string]string)
+	for _, item := range saved {
+		if item.TaskID != "" {
+			converted[item.Title+"\x00"+item.AssigneeID] = item.TaskID
+		}
+	}
+	for i := range incoming {
+		if incoming[i].TaskID == "" {
+			incoming[i].TaskID = converted[incoming[i].Title+"\x00"+incoming[i].AssigneeID]
+		}
+	}
+	return incoming
+}
+
+// validateActionItems 待办须有标题和负责人
+func validateActionItems(items []entity.ReviewActionItem) error {
+	for i, item := range items {
+		if strings.TrimSpace(item.Title) == "" {
+			return fmt.Errorf("第%d项待办缺少标题", i+1)
+		}
+		if item.AssigneeID == "" {
+			return fmt.Errorf("待办[%s]缺少负责人", item.Title)
+		}
+	}
+	return nil
+}
+
+// createActionItemTasks 为尚未转任务的待办创建项目任务，结果写回 items
+func (s *ReviewMeetingService) createActionItemTasks(ctx context.Context, meeting *entity.ReviewMeeting, items []entity.ReviewActionItem, userID string) error {
+	pending := 0
+	for _, item := range items {
+		if item.TaskID == "" {
+			pending++
+		}
+	}
+	if pending == 0 {
+		return nil
+	}
+	if meeting.ProjectID == "" {
+		return fmt.Errorf("评审未关联项目，待办无法转为任务")
+	}
+	if s.projectSvc == nil {
+		return fmt.Errorf("项目服务未初始化")
+	}
+
+	start := schedule.Day(time.Now())
+	for i := range items {
+		if items[i].TaskID != "" {
+			continue
+		}
+		item := items[i]
+		description := fmt.Sprintf("来自评审会议「%s」的待办", meeting.Title)
+		if item.Description != "" {
+			description = item.Description + "\n\n" + description
+		}
+		req := &CreateTaskRequest{
+			Name:         item.Title,
+			Description:  description,
+			PhaseID:      meeting.PhaseID,
+			AssigneeID:   item.AssigneeID,
+			PlannedStart: &start,
+			DueDate:      item.DueDate,
+		}
+		if item.DueDate == nil {
+			req.DurationDays = 1
+		}
+		task, err := s.projectSvc.CreateTask(ctx, meeting.ProjectID, userID, req)
+		if err != nil {
+			return fmt.Errorf("待办[%s]转任务失败: %w", item.Title, err)
+		}
+		items[i].TaskID = task.ID
+	}
+	return nil
+}
+
+// reviewMeetingOpen 会议是否未结束
+func reviewMeetingOpen(status string) bool {
+	return status == entity.ReviewMeetingStatusScheduled || status == entity.ReviewMeetingStatusInProgress
+}
+
+func reviewMeetingStatusText(status string) string {
+	switch status {
+	case entity.ReviewMeetingStatusCompleted:
+		return "结束"
+	case entity.ReviewMeetingStatusCancelled:
+		return "取消"
+	}
+	return status
+}
+
+func reviewMeetingTypeText(meetingType string) string {
+	switch meetingType {
+	case entity.ReviewMeetingTypeDesign:
+		return "设计"
+	case entity.ReviewMeetingTypePhase:
+		return "阶段"
+	}
+	return meetingType
+}
@@ -2,11 +2,12 @@ package service
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"log"
-	"strings"
 
 	"github.com/bitfantasy/nimo/internal/plm/entity"
+	"github.com/bitfantasy/nimo/internal/shared/expr"
 	"github.com/google/uuid"
 	"gorm.io/gorm"
 )
@@ -40,10 +41,13 @@ func (s *RoutingService) EvaluateRoute(ctx context.Context, entityType, event st
 	}
 
 	// 逐条评估规则
+	var ruleErrors []string
 	for _, rule := range rules {
 		matched, err := evaluateConditions(rule.Conditions, routeCtx)
 		if err != nil {
+			// 条件出错的规则跳过，错误随决策一并返回
 			log.Printf("[RoutingService] 规则[%s]条件评估失败: %v", rule.Name, err)
+			ruleErrors = append(ruleErrors, fmt.Sprintf("规则[%s]条件评估失败: %v", rule.Name, err))
 			continue
 		}
 		if matched {
@@ -59,6 +63,7 @@ func (s *RoutingService) EvaluateRoute(ctx context.Context, entityType, event st
 				RuleName:     rule.Name,
 				ActionConfig: map[string]interface{}(rule.ActionConfig),
 				Reason:       fmt.Sprintf("匹配规则[%s]", rule.Name),
+				Errors:       ruleErrors,
 			}
 
 			// 记录路由日志
@@ -72,6 +77,7 @@ func (s *RoutingService) EvaluateRoute(ctx context.Context, entityType, event st
 	decision := &entity.RoutingDecision{
 		Channel: entity.RoutingChannelFeishu,
 		Reason:  "无匹配规则，默认走人工审批",
+		Errors:  ruleErrors,
 	}
 
 	s.logRouting(ctx, nil, entityType, "", event, entity.RoutingChannelFeishu, routeCtx, decision.Reason)
@@ -80,125 +86,40 @@ func (s *RoutingService) EvaluateRoute(ctx context.Context, entityType, event st
 }
 
 // =============================================================================
-// 条件评估引擎
+// 条件评估引擎 — 委托共享表达式引擎 expr（与状态机转换条件共用）
 // =============================================================================
 
 // evaluateConditions 评估条件组合
-// 支持格式:
+// 支持格式（可嵌套）:
 //
 //	{"operator": "and", "conditions": [...]}
 //	{"operator": "or", "conditions": [...]}
 //	{"field": "xxx", "op": "eq", "value": xxx}  （单条件也可以直接放在顶层）
+//	{"expr": "amount * qty > 10000 && due_date < now() + 3d"}
 func evaluateConditions(conditions entity.JSONB, routeCtx map[string]interface{}) (bool, error) {
-	if conditions == nil || len(conditions) == 0 {
+	if len(conditions) == 0 {
 		return true, nil // 空条件默认匹配
 	}
-
-	// 检查是否有 operator 字段（组合条件）
-	if op, ok := conditions["operator"]; ok {
-		operator, _ := op.(string)
-		subConds, ok := conditions["conditions"].([]interface{})
-		if !ok {
-			return false, fmt.Errorf("conditions 字段格式错误")
-		}
-		return evaluateGroup(operator, subConds, routeCtx)
-	}
-
-	// 单条件
-	return evaluateSingleCondition(conditions, routeCtx)
-}
-
-// evaluateGroup 评估 and/or 组合
-func evaluateGroup(operator string, conditions []interface{}, routeCtx map[string]interface{}) (bool, error) {
-	operator = strings.ToLower(operator)
-
-	for _, cond := range conditions {
-		condMap, ok := cond.(map[string]interface{})
-		if !ok {
-			continue
-		}
-
-		// 检查是否为嵌套组合
-		if _, hasOp := condMap["operator"]; hasOp {
-			matched, err := evaluateConditions(entity.JSONB(condMap), routeCtx)
-			if err != nil {
-				return false, err
-			}
-			if operator == "or" && matched {
-				return true, nil
-			}
-			if operator == "and" && !matched {
-				return false, nil
-			}
-		} else {
-			// 单条件
-			matched, err := evaluateSingleCondition(condMap, routeCtx)
-			if err != nil {
-				return false, err
-			}
-			if operator == "or" && matched {
-				return true, nil
-			}
-			if operator == "and" && !matched {
-				return false, nil
-			}
-		}
+	raw, err := json.Marshal(conditions)
+	if err != nil {
+		return false, fmt.Errorf("序列化条件失败: %w", err)
 	}
-
-	// and: 全部通过返回 true; or: 全部不通过返回 false
-	return operator == "and", nil
+	return expr.EvaluateCondition(raw, routeCtx)
 }
 
-// evaluateSingleCondition 评估单个条件 {"field": "xxx", "op": "eq", "value": xxx}
-func evaluateSingleCondition(cond map[string]interface{}, routeCtx map[string]interface{}) (bool, error) {
-	field, _ := cond["field"].(string)
-	op, _ := cond["op"].(string)
-	expected := cond["value"]
-
-	if field == "" || op == "" {
-		return false, fmt.Errorf("条件缺少 field 或 op")
+// validateConditions 校验条件（解析 + 类型检查），保存规则时调用
+func validateConditions(conditions entity.JSONB) error {
+	if len(conditions) == 0 {
+		return nil
 	}
-
-	actual, exists := routeCtx[field]
-	if !exists {
-		// 字段不存在，视为不匹配（除了 neq/not_in/not_contains）
-		switch op {
-		case "neq", "not_in", "not_contains":
-			return true, nil
-		default:
-			return false, nil
-		}
+	raw, err := json.Marshal(conditions)
+	if err != nil {
+		return fmt.Errorf("序列化条件失败: %w", err)
 	}
-
-	return compareValues(op, actual, expected)
-}
-
-// compareValues 比较值
-func compareValues(op string, actual, expected interface{}) (bool, error) {
-	switch op {
-	case "eq":
-		return fmt.Sprintf("%v", actual) == fmt.Sprintf("%v", expected), nil
-	case "neq":
-		return fmt.Sprintf("%v", actual) != fmt.Sprintf("%v", expected), nil
-	case "gt":
-		return toFloat64(actual) > toFloat64(expected), nil
-	case "gte":
-		return toFloat64(actual) >= toFloat64(expected), nil
-	case "lt":
-		return toFloat64(actual) < toFloat64(expected), nil
-	case "lte":
-		return toFloat64(actual) <= toFloat64(expected), nil
-	case "in":
-		return valueInSlice(actual, expected), nil
-	case "not_in":
-		return !valueInSlice(actual, expected), nil
-	case "contains":
-		return strings.Contains(fmt.Sprintf("%v", actual), fmt.Sprintf("%v", expected)), nil
-	case "not_contains":
-		return !strings.Contains(fmt.Sprintf("%v", actual), fmt.Sprintf("%v", expected)), nil
-	default:
-		return false, fmt.Errorf("不支持的操作符: %s", op)
+	if err := expr.ValidateCondition(raw); err != nil {
+		return fmt.Errorf("路由条件无效: %w", err)
 	}
+	return nil
 }
 
 // toFloat64 将 interface{} 转为 float64
@@ -223,32 +144,15 @@ func toFloat64(v interface{}) float64 {
 	}
 }
 
-// valueInSlice 检查值是否在切片中
-func valueInSlice(actual, expected interface{}) bool {
-	actualStr := fmt.Sprintf("%v", actual)
-	switch arr := expected.(type) {
-	case []interface{}:
-		for _, v := range arr {
-			if fmt.Sprintf("%v", v) == actualStr {
-				return true
-			}
-		}
-	case []string:
-		for _, v := range arr {
-			if v == actualStr {
-				return true
-			}
-		}
-	}
-	return false
-}
-
 // =============================================================================
 // CRUD 方法
 // =============================================================================
 
 // CreateRule 创建路由规则
 func (s *RoutingService) CreateRule(ctx context.Context, rule *entity.RoutingRule) error {
+	if err := validateConditions(rule.Conditions); err != nil {
+		return err
+	}
 	rule.ID = uuid.New().String()
 	if err := s.db.WithContext(ctx).Create(rule).Error; err != nil {
 		return fmt.Errorf("创建路由规则失败: %w", err)
@@ -258,6 +162,11 @@ func (s *RoutingService) CreateRule(ctx context.Context, rule *entity.RoutingRul
 
 // UpdateRule 更新路由规则
 func (s *RoutingService) UpdateRule(ctx context.Context, id string, updates map[string]interface{}) error {
+	if conditions, ok := updates["conditions"].(entity.JSONB); ok {
+		if err := validateConditions(conditions); err != nil {
+			return err
+		}
+	}
 	result := s.db.WithContext(ctx).Model(&entity.RoutingRule{}).Where("id = ?", id).Updates(updates)
 	if result.Error != nil {
 		return fmt.Errorf("更新路由规则失败: %w", result.Error)
@@ -0,0 +1,534 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/bitfantasy/nimo/internal/plm/entity"
+	"github.com/bitfantasy/nimo/internal/plm/repository"
+	"github.com/bitfantasy/nimo/internal/plm/sse"
+	"github.com/bitfantasy/nimo/internal/shared/schedule"
+	"gorm.io/gorm"
+)
+
+// =============================================================================
+// 项目进度计划 — 基于依赖类型（FS/SS/FF/SF）和延隔的关键路径排程
+//
+// 任务按状态映射为排程活动：
+//   - 已完成：开始/完成取实际日期，不再移动
+//   - 已开始（进行中/已提交/评审中/已驳回）：开始取实际日期，剩余工期按进度估算，
+//     预计完成不早于状态日（今天），因此拖期会顺延后续任务
+//   - 未开始：由前置依赖驱动，且不早于状态日；没有前置的任务保持原计划开始日
+//
+// 重排只改写未开始任务的计划起止日期
+// =============================================================================
+
+// ScheduleService 项目进度计划服务
+type ScheduleService struct {
+	db        *gorm.DB
+	calendars *CalendarService
+	taskSync  *FeishuTaskSyncService
+	locks     sync.Map // projectID → *sync.Mutex，同一项目的重排串行执行
+}
+
+// NewScheduleService 创建进度计划服务
+func NewScheduleService(db *gorm.DB) *ScheduleService {
+	return &ScheduleService{db: db}
+}
+
+// SetCalendarService 注入工作日历服务（按项目日历计算工作日，未注入时按周一至周五）
+func (s *ScheduleService) SetCalendarService(svc *CalendarService) {
+	s.calendars = svc
+}
+
+// SetFeishuTaskSyncService 注入飞书任务同步服务（重排后推送新的截止日期）
+func (s *ScheduleService) SetFeishuTaskSyncService(svc *FeishuTaskSyncService) {
+	s.taskSync = svc
+}
+
+// ScheduledTask 甘特图任务条
+type ScheduledTask struct {
+	TaskID       string     `json:"task_id"`
+	Code         string     `json:"code"`
+	Title        string     `json:"title"`
+	TaskType     string     `json:"task_type"`
+	Status       string     `json:"status"`
+	PhaseID      *string    `json:"phase_id"`
+	ParentTaskID *string    `json:"parent_task_id"`
+	AssigneeID   *string    `json:"assignee_id"`
+	Progress     int        `json:"progress"`
+	DurationDays int        `json:"duration_days"` // 工期（工作日）
+	PlannedStart *time.Time `json:"planned_start"` // 当前保存的计划开始
+	PlannedEnd   *time.Time `json:"planned_end"`   // 当前保存的计划完成
+	EarlyStart   time.Time  `json:"early_start"`
+	EarlyFinish  time.Time  `json:"early_finish"` // 含当天
+	LateStart    time.Time  `json:"late_start"`
+	LateFinish   time.Time  `json:"late_finish"` // 含当天
+	TotalFloat   int        `json:"total_float"` // 总时差（工作日），<0 表示已无法按期
+	FreeFloat    int        `json:"free_float"`  // 自由时差（工作日）
+	Critical     bool       `json:"critical"`
+}
+
+// ScheduleLink 甘特图依赖连线
+type ScheduleLink struct {
+	ID             string `json:"id"`
+	FromTaskID     string `json:"from_task_id"`
+	ToTaskID       string `json:"to_task_id"`
+	DependencyType string `json:"dependency_type"` // FS/SS/FF/SF
+	LagDays        int    `json:"lag_days"`
+}
+
+// ProjectSchedule 项目排程结果
+type ProjectSchedule struct {
+	ProjectID     string          `json:"project_id"`
+	StatusDate    time.Time       `json:"status_date"`
+	ProjectStart  time.Time       `json:"project_start"`
+	ProjectFinish time.Time       `json:"project_finish"` // 预测完工日（含当天）
+	PlannedEnd    *time.Time      `json:"planned_end"`
+	SlipDays      int             `json:"slip_days"` // 预测完工晚于计划完工的工作日数，提前为负
+	Tasks         []ScheduledTask `json:"tasks"`
+	Links         []ScheduleLink  `json:"links"`
+	CriticalPath  []string        `json:"critical_path"` // 关键任务ID，按最早开始排序
+}
+
+// TaskDateChange 重排引起的任务日期变化
+type TaskDateChange struct {
+	TaskID   string     `json:"task_id"`
+	Code     string     `json:"code"`
+	Title    string     `json:"title"`
+	OldStart *time.Time `json:"old_start"`
+	OldEnd   *time.Time `json:"old_end"`
+	NewStart time.Time  `json:"new_start"`
+	NewEnd   time.Time  `json:"new_end"`
+}
+
+// RescheduleResult 重排结果
+type RescheduleResult struct {
+	DryRun   bool             `json:"dry_run"`
+	Changes  []TaskDateChange `json:"changes"`
+	Schedule *ProjectSchedule `json:"schedule"`
+}
+
+// GetSchedule 计算项目当前排程（只读）
+func (s *ScheduleService) GetSchedule(ctx context.Context, projectID string) (*ProjectSchedule, error) {
+	plan, err := s.plan(ctx, projectID, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	return plan.schedule, nil
+}
+
+// Reschedule 按排程结果顺延/提前未开始任务的计划日期
+func (s *ScheduleService) Reschedule(ctx context.Context, projectID string, dryRun bool) (*RescheduleResult, error) {
+	mu, _ := s.locks.LoadOrStore(projectID, &sync.Mutex{})
+	mu.(*sync.Mutex).Lock()
+	defer mu.(*sync.Mutex).Unlock()
+
+	plan, err := s.plan(ctx, projectID, time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	result := &RescheduleResult{DryRun: dryRun, Changes: []TaskDateChange{}, Schedule: plan.schedule}
+	for i, st := range plan.schedule.Tasks {
+		if taskStarted(st.Status) || st.Status == entity.TaskStatusCancelled {
+			continue
+		}
+		if sameDay(st.PlannedStart, st.EarlyStart) && sameDay(st.PlannedEnd, st.EarlyFinish) {
+			continue
+		}
+		result.Changes = append(result.Changes, TaskDateChange{
+			TaskID:   st.TaskID,
+			Code:     st.Code,
+			Title:    st.Title,
+			OldStart: st.PlannedStart,
+			OldEnd:   st.PlannedEnd,
+			NewStart: st.EarlyStart,
+			NewEnd:   st.EarlyFinish,
+		})
+		if dryRun {
+			continue
+		}
+		start, end := st.EarlyStart, st.EarlyFinish
+		if err := s.db.WithContext(ctx).Model(&entity.Task{}).Where("id = ?", st.TaskID).
+			Updates(map[string]interface{}{"planned_start": start, "planned_end": end, "updated_at": time.Now()}).Error; err != nil {
+			return nil, fmt.Errorf("更新任务 %s 计划日期失败: %w", st.Code, err)
+		}
+		plan.schedule.Tasks[i].PlannedStart = &start
+		plan.schedule.Tasks[i].PlannedEnd = &end
+	}
+
+	if !dryRun && len(result.Changes) > 0 {
+		for _, c := range result.Changes {
+			sse.PublishTaskUpdate(projectID, c.TaskID, "task_rescheduled")
+			s.taskSync.SyncAsync(c.TaskID)
+		}
+		log.Printf("[ScheduleService] 项目 %s 重排 %d 个任务，预测完工 %s", projectID, len(result.Changes),
+			plan.schedule.ProjectFinish.Format("2006-01-02"))
+	}
+	return result, nil
+}
+
+// RescheduleAsync 任务进度/日期/依赖变化后异步重排（不阻断主流程）
+func (s *ScheduleService) RescheduleAsync(projectID, reason string) {
+	if s == nil || projectID == "" {
+		return
+	}
+	go func() {
+		if _, err := s.Reschedule(context.Background(), projectID, false); err != nil {
+			log.Printf("[ScheduleService] 自动重排失败 (project=%s reason=%s): %v", projectID, reason, err)
+		}
+	}()
+}
+
+// ValidateDependency 校验新增依赖：同一项目、非自依赖、不形成循环
+func (s *ScheduleService) ValidateDependency(ctx context.Context, taskID, dependsOnID string) error {
+	if taskID == dependsOnID {
+		return fmt.Errorf("任务不能依赖自身")
+	}
+	var tasks []entity.Task
+	if err := s.db.WithContext(ctx).Select("id", "project_id", "name").
+		Where("id IN ?", []string{taskID, dependsOnID}).Find(&tasks).Error; err != nil {
+		return fmt.Errorf("查询任务失败: %w", err)
+	}
+	if len(tasks) != 2 {
+		return fmt.Errorf("任务不存在")
+	}
+	if tasks[0].ProjectID != tasks[1].ProjectID {
+		return fmt.Errorf("只能依赖同一项目内的任务")
+	}
+
+	var deps []entity.TaskDependency
+	if err := s.db.WithContext(ctx).
+		Where("task_id IN (SELECT id FROM tasks WHERE project_id = ?)", tasks[0].ProjectID).
+		Find(&deps).Error; err != nil {
+		return fmt.Errorf("查询任务依赖失败: %w", err)
+	}
+	// dependsOnID 的前置链上若已有 taskID，则新增依赖会成环
+	preds := make(map[string][]string)
+	for _, d := range deps {
+		preds[d.TaskID] = append(preds[d.TaskID], d.DependsOnID)
+	}
+	visited := map[string]bool{}
+	stack := []string{dependsOnID}
+	for len(stack) > 0 {
+		id := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		if id == taskID {
+			return fmt.Errorf("添加该依赖会形成循环依赖")
+		}
+		if visited[id] {
+			continue
+		}
+		visited[id] = true
+		stack = append(stack, preds[id]...)
+	}
+	return nil
+}
+
+// schedulePlan 排程中间结果
+type schedulePlan struct {
+	schedule *ProjectSchedule
+	timeline *schedule.Timeline
+}
+
+// plan 加载项目任务和依赖并计算排程
+func (s *ScheduleService) plan(ctx context.Context, projectID string, now time.Time) (*schedulePlan, error) {
+	var project entity.Project
+	if err := s.db.WithContext(ctx).Where("id = ?", projectID).First(&project).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, fmt.Errorf("项目不存在")
+		}
+		return nil, fmt.Errorf("查询项目失败: %w", err)
+	}
+
+	var tasks []entity.Task
+	if err := s.db.WithContext(ctx).
+		Where("project_id = ? AND status <> ?", projectID, entity.TaskStatusCancelled).
+		Order("sequence ASC, created_at ASC").
+		Find(&tasks).Error; err != nil {
+		return nil, fmt.Errorf("查询项目任务失败: %w", err)
+	}
+	var deps []entity.TaskDependency
+	if err := s.db.WithContext(ctx).
+		Where("task_id IN (SELECT id FROM tasks WHERE project_id = ?)", projectID).
+		Order("created_at ASC").
+		Find(&deps).Error; err != nil {
+		return nil, fmt.Errorf("查询任务依赖失败: %w", err)
+	}
+
+	// 坐标原点：项目计划开始，否则最早的任务计划开始，否则今天
+	statusDate := schedule.Day(now)
+	origin := statusDate
+	if project.StartDate != nil {
+		origin = *project.StartDate
+	} else {
+		for _, t := range tasks {
+			if t.StartDate != nil && t.StartDate.Before(origin) {
+				origin = *t.StartDate
+			}
+		}
+	}
+	cal := s.calendars.ProjectCalendar(ctx, projectID)
+	tl := schedule.NewTimeline(cal, origin)
+	statusOffset := tl.Offset(statusDate)
+
+	taskIndex := make(map[string]int, len(tasks))
+	for i, t := range tasks {
+		taskIndex[t.ID] = i
+	}
+	links := make([]schedule.Link, 0, len(deps))
+	scheduleLinks := make([]ScheduleLink, 0, len(deps))
+	hasPred := make(map[string]bool)
+	for _, d := range deps {
+		if _, ok := taskIndex[d.DependsOnID]; !ok {
+			continue
+		}
+		linkType, err := schedule.ParseLinkType(d.DependencyType)
+		if err != nil {
+			linkType = schedule.FinishToStart
+		}
+		links = append(links, schedule.Link{From: d.DependsOnID, To: d.TaskID, Type: linkType, Lag: d.LagDays})
+		scheduleLinks = append(scheduleLinks, ScheduleLink{
+			ID:             d.ID,
+			FromTaskID:     d.DependsOnID,
+			ToTaskID:       d.TaskID,
+			DependencyType: string(linkType),
+			LagDays:        d.LagDays,
+		})
+		hasPred[d.TaskID] = true
+	}
+
+	activities := make([]schedule.Activity, len(tasks))
+	for i, t := range tasks {
+		activities[i] = s.taskActivity(&tasks[i], cal, tl, statusOffset, hasPred[t.ID])
+	}
+
+	res, err := schedule.Compute(activities, links)
+	if err != nil {
+		var cycle *schedule.CycleError
+		if errors.As(err, &cycle) {
+			return nil, fmt.Errorf("任务依赖存在循环，无法排程: %s", taskPath(cycle.Path, tasks, taskIndex))
+		}
+		return nil, fmt.Errorf("排程计算失败: %w", err)
+	}
+
+	ps := &ProjectSchedule{
+		ProjectID:    projectID,
+		StatusDate:   statusDate,
+		ProjectStart: tl.Origin(),
+		PlannedEnd:   project.PlannedEnd,
+		Tasks:        make([]ScheduledTask, 0, len(tasks)),
+		Links:        scheduleLinks,
+		CriticalPath: res.CriticalPath,
+	}
+	for _, t := range tasks {
+		r, _ := res.Get(t.ID)
+		ps.Tasks = append(ps.Tasks, ScheduledTask{
+			TaskID:       t.ID,
+			Code:         t.Code,
+			Title:        t.Title,
+			TaskType:     t.TaskType,
+			Status:       t.Status,
+			PhaseID:      t.PhaseID,
+			ParentTaskID: t.ParentTaskID,
+			AssigneeID:   t.AssigneeID,
+			Progress:     t.Progress,
+			DurationDays: r.Duration,
+			PlannedStart: t.StartDate,
+			PlannedEnd:   t.DueDate,
+			EarlyStart:   tl.Date(r.EarlyStart),
+			EarlyFinish:  finishDate(tl, r.EarlyStart, r.EarlyFinish),
+			LateStart:    tl.Date(r.LateStart),
+			LateFinish:   finishDate(tl, r.LateStart, r.LateFinish),
+			TotalFloat:   r.TotalFloat,
+			FreeFloat:    r.FreeFloat,
+			Critical:     r.Critical,
+		})
+	}
+	if len(tasks) > 0 {
+		ps.ProjectFinish = tl.Date(res.Finish - 1)
+	} else {
+		ps.ProjectFinish = tl.Origin()
+	}
+	if project.PlannedEnd != nil {
+		ps.SlipDays = tl.Offset(ps.ProjectFinish) - tl.Offset(*project.PlannedEnd)
+	}
+	return &schedulePlan{schedule: ps, timeline: tl}, nil
+}
+
+// taskActivity 把任务映射为排程活动
+func (s *ScheduleService) taskActivity(t *entity.Task, cal schedule.Calendar, tl *schedule.Timeline, statusOffset int, hasPred bool) schedule.Activity {
+	duration := plannedDuration(cal, t)
+	a := schedule.Activity{ID: t.ID, Duration: duration}
+
+	switch {
+	case t.Status == entity.TaskStatusCompleted:
+		start := firstDate(t.ActualStart, t.StartDate, t.CompletedAt, t.DueDate)
+		finish := firstDate(t.CompletedAt, t.DueDate, start)
+		a.Fixed, a.Completed = true, true
+		if start == nil {
+			a.NotBefore = statusOffset
+			return a
+		}
+		a.NotBefore = tl.Offset(*start)
+		a.Duration = 0
+		if finish != nil {
+			if d := tl.Offset(*finish) + 1 - a.NotBefore; d > 0 {
+				a.Duration = d
+			}
+		}
+	case taskStarted(t.Status):
+		a.Fixed = true
+		a.NotBefore = statusOffset
+		if start := firstDate(t.ActualStart, t.StartDate); start != nil {
+			a.NotBefore = tl.Offset(*start)
+		}
+		// 剩余工期按进度估算，未完成的任务最早今天完成
+		remaining := (duration*(100-t.Progress) + 99) / 100
+		if remaining < 1 {
+			remaining = 1
+		}
+		if d := statusOffset + remaining - a.NotBefore; d > a.Duration {
+			a.Duration = d
+		}
+	default:
+		a.NotBefore = statusOffset
+		if a.NotBefore < 0 {
+			a.NotBefore = 0
+		}
+		if !hasPred && t.StartDate != nil {
+			if planned := tl.Offset(*t.StartDate); planned > a.NotBefore {
+				a.NotBefore = planned
+			}
+		}
+	}
+	return a
+}
+
+// plannedDuration 任务计划工期：计划起止日期间的工作日数，否则按预估工时（8h/天），默认 1 天；里程碑为 0
+func plannedDuration(cal schedule.Calendar, t *entity.Task) int {
+	if t.TaskType == entity.TaskTypeMilestone {
+		return 0
+	}
+	if t.StartDate != nil && t.DueDate != nil {
+		if d := schedule.WorkdaysBetween(cal, *t.StartDate, *t.DueDate); d > 0 {
+			return d
+		}
+	}
+	if t.EstimatedHours > 0 {
+		return int((t.EstimatedHours + 7.999) / 8)
+	}
+	return 1
+}
+
+// finishDate 完成日（含当天）；零工期活动完成日即开始日
+func finishDate(tl *schedule.Timeline, start, finish int) time.Time {
+	if finish <= start {
+		return tl.Date(start)
+	}
+	return tl.Date(finish - 1)
+}
+
+// taskStarted 任务是否已开始（含已完成）
+func taskStarted(status string) bool {
+	switch status {
+	case entity.TaskStatusInProgress, entity.TaskStatusSubmitted, entity.TaskStatusReviewing,
+		entity.TaskStatusRejected, entity.TaskStatusCompleted:
+		return true
+	}
+	return false
+}
+
+// dependencyAllowsStart 按依赖类型判断前置任务状态是否允许后续任务开始
+// FS: 前置已完成；SS: 前置已开始；FF/SF 只约束完成时间，不阻止开始
+func dependencyAllowsStart(depType, predStatus string) bool {
+	linkType, _ := schedule.ParseLinkType(depType)
+	switch linkType {
+	case schedule.StartToStart:
+		return taskStarted(predStatus)
+	case schedule.FinishToFinish, schedule.StartToFinish:
+		return true
+	default:
+		return predStatus == entity.TaskStatusCompleted
+	}
+}
+
+// dependencyAllowsFinish 按依赖类型判断前置任务状态是否允许后续任务完成
+// FF: 前置已完成；SF: 前置已开始；FS/SS 在开始时已校验
+func dependencyAllowsFinish(depType, predStatus string) bool {
+	linkType, _ := schedule.ParseLinkType(depType)
+	switch linkType {
+	case schedule.FinishToFinish:
+		return predStatus == entity.TaskStatusCompleted
+	case schedule.StartToFinish:
+		return taskStarted(predStatus)
+	default:
+		return true
+	}
+}
+
+// dependencyRequirement 依赖类型对前置任务的要求（用于提示）
+func dependencyRequirement(depType string) string {
+	linkType, _ := schedule.ParseLinkType(depType)
+	switch linkType {
+	case schedule.StartToStart, schedule.StartToFinish:
+		return "开始"
+	default:
+		return "完成"
+	}
+}
+
+// checkFinishDependencies 按依赖类型检查任务能否完成（FF 需前置完成，SF 需前置开始）
+// 手动改状态和工作流完成任务共用
+func checkFinishDependencies(ctx context.Context, taskRepo *repository.TaskRepository, taskID string) error {
+	deps, err := taskRepo.ListDependencies(ctx, taskID)
+	if err != nil {
+		return fmt.Errorf("查询任务依赖失败: %w", err)
+	}
+	for _, d := range deps {
+		depTask, err := taskRepo.FindByID(ctx, d.DependsOnID)
+		if err != nil {
+			return fmt.Errorf("查找依赖任务失败 (id=%s): %w", d.DependsOnID, err)
+		}
+		if !dependencyAllowsFinish(d.DependencyType, depTask.Status) {
+			return fmt.Errorf("前置任务[%s]尚未%s（当前状态: %s），无法完成", depTask.Title, dependencyRequirement(d.DependencyType), depTask.Status)
+		}
+	}
+	return nil
+}
+
+// firstDate 返回第一个非空日期
+func firstDate(dates ...*time.Time) *time.Time {
+	for _, d := range dates {
+		if d != nil {
+			return d
+		}
+	}
+	return nil
+}
+
+// sameDay 比较保存的日期与排程日期是否为同一天
+func sameDay(stored *time.Time, day time.Time) bool {
+	return stored != nil && schedule.Day(*stored).Equal(schedule.Day(day))
+}
+
+// taskPath 把循环中的任务ID转换为任务编码路径
+func taskPath(ids []string, tasks []entity.Task, index map[string]int) string {
+	path := ""
+	for i, id := range ids {
+		if i > 0 {
+			path += " → "
+		}
+		if j, ok := index[id]; ok && tasks[j].Code != "" {
+			path += tasks[j].Code
+		} else {
+			path += id
+		}
+	}
+	return path
+}
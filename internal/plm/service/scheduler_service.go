@@ -0,0 +1,550 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bitfantasy/nimo/internal/plm/entity"
+	"github.com/bitfantasy/nimo/internal/plm/repository"
+	"github.com/bitfantasy/nimo/internal/shared/cron"
+	"github.com/bitfantasy/nimo/internal/shared/feishu"
+	"github.com/bitfantasy/nimo/internal/shared/schedule"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// =============================================================================
+// 后台定时通知
+//
+// 内置三个定时任务：即将到期提醒（发给负责人）、逾期升级（按项目汇总发给项目经理，
+// 同时执行 OVERDUE 自动化规则）、每日工作摘要（每人一张卡片）。
+// cron 表达式可通过系统配置 scheduler.cron.<任务名> 覆盖，修改后重启生效
+// =============================================================================
+
+// 定时任务名称
+const (
+	JobDueSoonReminder   = "due_soon_reminder"
+	JobOverdueEscalation = "overdue_escalation"
+	JobDailyDigest       = "daily_digest"
+)
+
+// 定时任务相关配置项
+const (
+	SchedulerCronConfigPrefix      = "scheduler.cron."
+	SchedulerDueSoonDaysConfigKey  = "scheduler.due_soon_days"         // 提前提醒天数，默认 2
+	SchedulerEscalateDaysConfigKey = "scheduler.overdue_escalate_days" // 逾期多少个工作日升级，默认 1
+	SchedulerPortalURLConfigKey    = "scheduler.portal_url"            // 卡片跳转的前端地址，为空时不显示按钮
+)
+
+var defaultJobSpecs = map[string]string{
+	JobDueSoonReminder:   "0 9 * * 1-5",
+	JobOverdueEscalation: "30 9 * * 1-5",
+	JobDailyDigest:       "0 8 * * 1-5",
+}
+
+// SchedulerService 定时任务服务
+type SchedulerService struct {
+	db            *gorm.DB
+	configRepo    *repository.SystemConfigRepository
+	cron          *cron.Scheduler
+	instance      string
+	projectSvc    *ProjectService
+	automationSvc *AutomationService
+	feishuClient  *feishu.FeishuClient
+}
+
+// NewSchedulerService 创建定时任务服务并注册内置任务；locker 为空时不加锁（单实例部署）
+func NewSchedulerService(db *gorm.DB, configRepo *repository.SystemConfigRepository, locker cron.Locker) *SchedulerService {
+	host, _ := os.Hostname()
+	s := &SchedulerService{
+		db:         db,
+		configRepo: configRepo,
+		cron:       cron.New(locker),
+		instance:   fmt.Sprintf("%s:%d", host, os.Getpid()),
+	}
+	s.cron.OnRun = func(r cron.Run) {
+		// 手动执行由 RunJob 记录
+		if r.Trigger == cron.TriggerSchedule {
+			s.recordRun(context.Background(), r)
+		}
+	}
+
+	jobs := []cron.Job{
+		{Name: JobDueSoonReminder, Description: "即将到期任务提醒负责人", Run: s.runDueSoonReminder},
+		{Name: JobOverdueEscalation, Description: "逾期任务升级给项目经理，并执行逾期自动化规则", Run: s.runOverdueEscalation},
+		{Name: JobDailyDigest, Description: "每日工作摘要", Run: s.runDailyDigest},
+	}
+	for _, job := range jobs {
+		job.Spec = s.jobSpec(context.Background(), job.Name)
+		if err := s.cron.Add(job); err != nil {
+			log.Printf("[Scheduler] 注册任务失败: %v", err)
+		}
+	}
+	return s
+}
+
+// SetProjectService 注入项目服务（逾期任务计算）
+func (s *SchedulerService) SetProjectService(svc *ProjectService) {
+	s.projectSvc = svc
+}
+
+// SetAutomationService 注入自动化服务（逾期规则）
+func (s *SchedulerService) SetAutomationService(svc *AutomationService) {
+	s.automationSvc = svc
+}
+
+// SetFeishuClient 注入飞书客户端
+func (s *SchedulerService) SetFeishuClient(fc *feishu.FeishuClient) {
+	s.feishuClient = fc
+}
+
+// Start 启动调度循环（阻塞直到 ctx 取消，调用方应使用 go 启动）
+func (s *SchedulerService) Start(ctx context.Context) {
+	s.cron.Start(ctx)
+}
+
+// ListJobs 已注册的定时任务
+func (s *SchedulerService) ListJobs() []cron.Entry {
+	return s.cron.Entries()
+}
+
+// RunJob 立即在本实例执行任务并记录
+func (s *SchedulerService) RunJob(ctx context.Context, name string) (*entity.JobRun, error) {
+	run, err := s.cron.RunNow(context.WithoutCancel(ctx), name)
+	if err != nil {
+		return nil, err
+	}
+	return s.recordRun(ctx, *run), nil
+}
+
+// ListRuns 执行记录
+func (s *SchedulerService) ListRuns(ctx context.Context, jobName, status string, page, pageSize int) ([]entity.JobRun, int64, error) {
+	query := s.db.WithContext(ctx).Model(&entity.JobRun{})
+	if jobName != "" {
+		query = query.Where("job_name = ?", jobName)
+	}
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, fmt.Errorf("查询执行记录失败: %w", err)
+	}
+	var runs []entity.JobRun
+	if err := query.Order("started_at DESC").Offset((page - 1) * pageSize).Limit(pageSize).Find(&runs).Error; err != nil {
+		return nil, 0, fmt.Errorf("查询执行记录失败: %w", err)
+	}
+	return runs, total, nil
+}
+
+// recordRun 保存执行记录
+func (s *SchedulerService) recordRun(ctx context.Context, r cron.Run) *entity.JobRun {
+	run := &entity.JobRun{
+		ID:         uuid.New().String()[:32],
+		JobName:    r.Job,
+		Trigger:    r.Trigger,
+		Instance:   s.instance,
+		ScheduleAt: r.ScheduleAt,
+		StartedAt:  r.StartedAt,
+		FinishedAt: r.FinishedAt,
+		DurationMs: r.FinishedAt.Sub(r.StartedAt).Milliseconds(),
+		Status:     r.Status,
+		Output:     r.Output,
+	}
+	if r.Err != nil {
+		run.Error = r.Err.Error()
+	}
+	if err := s.db.WithContext(ctx).Create(run).Error; err != nil {
+		log.Printf("[Scheduler] 保存执行记录失败: job=%s error=%v", r.Job, err)
+	}
+	return run
+}
+
+// jobSpec 任务的 cron 表达式：系统配置优先，无效时使用默认值
+func (s *SchedulerService) jobSpec(ctx context.Context, name string) string {
+	spec := defaultJobSpecs[name]
+	if v := s.configValue(ctx, SchedulerCronConfigPrefix+name); v != "" {
+		if err := cron.Validate(v); err != nil {
+			log.Printf("[Scheduler] 配置的 cron 表达式无效，使用默认值 %q: job=%s error=%v", spec, name, err)
+		} else {
+			spec = v
+		}
+	}
+	return spec
+}
+
+// configValue 读取系统配置，不存在时返回空串
+func (s *SchedulerService) configValue(ctx context.Context, key string) string {
+	if s.configRepo == nil {
+		return ""
+	}
+	cfg, err := s.configRepo.FindByKey(ctx, key)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(cfg.Value)
+}
+
+// configInt 读取正整数配置
+func (s *SchedulerService) configInt(ctx context.Context, key string, def int) int {
+	v, err := strconv.Atoi(s.configValue(ctx, key))
+	if err != nil || v <= 0 {
+		return def
+	}
+	return v
+}
+
+// portalLink 前端页面的飞书内打开链接，未配置前端地址时返回空串
+func (s *SchedulerService) portalLink(ctx context.Context, path string) string {
+	base := strings.TrimRight(s.configValue(ctx, SchedulerPortalURLConfigKey), "/")
+	if base == "" {
+		return ""
+	}
+	return fmt.Sprintf("https://applink.feishu.cn/client/web_url/open?url=%s&mode=window", url.QueryEscape(base+path))
+}
+
+// activeTaskQuery 进行中项目里未完成且有负责人的任务
+func (s *SchedulerService) activeTaskQuery(ctx context.Context) *gorm.DB {
+	return s.db.WithContext(ctx).
+		Joins("JOIN projects ON projects.id = tasks.project_id").
+		Where("projects.deleted_at IS NULL AND projects.status NOT IN ?",
+			[]string{entity.ProjectStatusCompleted, entity.ProjectStatusCancelled}).
+		Where("tasks.status NOT IN ?", []string{entity.TaskStatusCompleted, entity.TaskStatusCancelled}).
+		Where("tasks.assignee_id IS NOT NULL AND tasks.assignee_id <> ''").
+		Preload("Project").
+		Preload("Assignee")
+}
+
+// dueSoonTasks 今天起 days 天内到期的任务
+func (s *SchedulerService) dueSoonTasks(ctx context.Context, days int) ([]entity.Task, error) {
+	today := schedule.Day(time.Now())
+	var tasks []entity.Task
+	if err := s.activeTaskQuery(ctx).
+		Where("tasks.planned_end >= ? AND tasks.planned_end < ?", today, today.AddDate(0, 0, days+1)).
+		Order("tasks.planned_end ASC").
+		Find(&tasks).Error; err != nil {
+		return nil, fmt.Errorf("查询即将到期任务失败: %w", err)
+	}
+	return tasks, nil
+}
+
+// overdueTasks 进行中项目的逾期任务（逾期天数按工作日历计算）
+func (s *SchedulerService) overdueTasks(ctx context.Context) ([]entity.Task, error) {
+	if s.projectSvc == nil {
+		return nil, fmt.Errorf("项目服务未初始化")
+	}
+	tasks, err := s.projectSvc.GetOverdueTasks(ctx, "")
+	if err != nil {
+		return nil, fmt.Errorf("查询逾期任务失败: %w", err)
+	}
+	active := tasks[:0]
+	for _, t := range tasks {
+		if t.Project == nil || t.Project.DeletedAt != nil ||
+			t.Project.Status == entity.ProjectStatusCompleted || t.Project.Status == entity.ProjectStatusCancelled {
+			continue
+		}
+		active = append(active, t)
+	}
+	return active, nil
+}
+
+// cardTaskItem 任务转卡片条目
+func cardTaskItem(t entity.Task, note string) feishu.CardTaskItem {
+	item := feishu.CardTaskItem{Title: t.Title, DueDate: formatDate(t.DueDate), Note: note}
+	if t.Project != nil {
+		item.Project = t.Project.Name
+	}
+	if t.Assignee != nil {
+		item.Assignee = t.Assignee.Name
+	}
+	return item
+}
+
+// sendCards 按用户发送卡片，返回成功人数和失败说明
+func (s *SchedulerService) sendCards(ctx context.Context, cards map[string]feishu.InteractiveCard) (int, []string, error) {
+	if len(cards) == 0 {
+		return 0, nil, nil
+	}
+	userIDs := make([]string, 0, len(cards))
+	for id := range cards {
+		userIDs = append(userIDs, id)
+	}
+	var users []entity.User
+	if err := s.db.WithContext(ctx).Where("id IN ? AND deleted_at IS NULL", userIDs).Find(&users).Error; err != nil {
+		return 0, nil, fmt.Errorf("查询通知对象失败: %w", err)
+	}
+	sent := 0
+	var failed []string
+	for _, u := range users {
+		if u.FeishuOpenID == "" {
+			continue
+		}
+		if err := s.feishuClient.SendUserCard(ctx, u.FeishuOpenID, cards[u.ID]); err != nil {
+			failed = append(failed, u.Name+": "+err.Error())
+			continue
+		}
+		sent++
+	}
+	return sent, failed, nil
+}
+
+// sendSummary 发送结果摘要；全部失败时返回错误
+func sendSummary(prefix string, sent int, failed []string) (string, error) {
+	out := fmt.Sprintf("%s，已发送 %d 人", prefix, sent)
+	if len(failed) == 0 {
+		return out, nil
+	}
+	out += fmt.Sprintf("，失败 %d 人: %s", len(failed), strings.Join(failed, "; "))
+	if sent == 0 {
+		return out, fmt.Errorf("卡片发送失败")
+	}
+	return out, nil
+}
+
+// runDueSoonReminder 即将到期提醒：每个负责人一张卡片
+func (s *SchedulerService) runDueSoonReminder(ctx context.Context) (string, error) {
+	days := s.configInt(ctx, SchedulerDueSoonDaysConfigKey, 2)
+	tasks, err := s.dueSoonTasks(ctx, days)
+	if err != nil {
+		return "", err
+	}
+	byUser := make(map[string][]feishu.CardTaskItem)
+	today := schedule.Day(time.Now())
+	for _, t := range tasks {
+		note := "今天到期"
+		if left := int(schedule.Day(*t.DueDate).Sub(today).Hours() / 24); left > 0 {
+			note = fmt.Sprintf("剩余 %d 天", left)
+		}
+		byUser[*t.AssigneeID] = append(byUser[*t.AssigneeID], cardTaskItem(t, note))
+	}
+	prefix := fmt.Sprintf("%d 项任务将在 %d 天内到期，涉及 %d 人", len(tasks), days, len(byUser))
+	if s.feishuClient == nil {
+		return prefix + "，飞书未配置，未发送", nil
+	}
+
+	link := s.portalLink(ctx, "/my-tasks")
+	cards := make(map[string]feishu.InteractiveCard, len(byUser))
+	for userID, items := range byUser {
+		cards[userID] = feishu.NewDueSoonCard(days, items, link)
+	}
+	sent, failed, err := s.sendCards(ctx, cards)
+	if err != nil {
+		return "", err
+	}
+	return sendSummary(prefix, sent, failed)
+}
+
+// runOverdueEscalation 逾期升级：按项目汇总发给项目经理，并执行 OVERDUE 自动化规则
+func (s *SchedulerService) runOverdueEscalation(ctx context.Context) (string, error) {
+	tasks, err := s.overdueTasks(ctx)
+	if err != nil {
+		return "", err
+	}
+	threshold := s.configInt(ctx, SchedulerEscalateDaysConfigKey, 1)
+
+	type projectOverdue struct {
+		project *entity.Project
+		items   []feishu.CardTaskItem
+	}
+	byProject := make(map[string]*projectOverdue)
+	escalated := 0
+	for _, t := range tasks {
+		if t.OverdueDays < threshold {
+			continue
+		}
+		p, ok := byProject[t.ProjectID]
+		if !ok {
+			p = &projectOverdue{project: t.Project}
+			byProject[t.ProjectID] = p
+		}
+		p.items = append(p.items, cardTaskItem(t, fmt.Sprintf("逾期 %d 天", t.OverdueDays)))
+		escalated++
+	}
+
+	var parts []string
+	parts = append(parts, fmt.Sprintf("逾期任务 %d 项，达到升级阈值 %d 项，涉及 %d 个项目", len(tasks), escalated, len(byProject)))
+	var runErr error
+	if s.feishuClient == nil {
+		parts = append(parts, "飞书未配置，未发送")
+	} else {
+		// 同一经理负责多个项目时各发一张卡片
+		sent, noManager := 0, 0
+		var failed []string
+		for projectID, p := range byProject {
+			if p.project.ManagerID == "" {
+				noManager++
+				continue
+			}
+			card := feishu.NewOverdueEscalationCard(p.project.Name, p.items, s.portalLink(ctx, "/projects/"+projectID))
+			n, f, err := s.sendCards(ctx, map[string]feishu.InteractiveCard{p.project.ManagerID: card})
+			if err != nil {
+				return "", err
+			}
+			sent += n
+			failed = append(failed, f...)
+		}
+		summary, err := sendSummary("升级通知", sent, failed)
+		if noManager > 0 {
+			summary += fmt.Sprintf("，%d 个项目未设置项目经理", noManager)
+		}
+		parts = append(parts, summary)
+		runErr = err
+	}
+
+	if s.automationSvc != nil {
+		executed, err := s.automationSvc.CheckOverdue(ctx)
+		if err != nil {
+			parts = append(parts, "逾期规则执行失败: "+err.Error())
+			if runErr == nil {
+				runErr = err
+			}
+		} else {
+			parts = append(parts, fmt.Sprintf("逾期规则执行 %d 次", executed))
+		}
+	}
+	return strings.Join(parts, "；"), runErr
+}
+
+// runDailyDigest 每日工作摘要：进行中、即将到期、已逾期任务和待我审批
+func (s *SchedulerService) runDailyDigest(ctx context.Context) (string, error) {
+	days := s.configInt(ctx, SchedulerDueSoonDaysConfigKey, 2)
+	sections := make(map[string]*[4]feishu.DigestSection)
+	section := func(userID string) *[4]feishu.DigestSection {
+		sec, ok := sections[userID]
+		if !ok {
+			sec = &[4]feishu.DigestSection{
+				{Title: "⚠️ 已逾期"},
+				{Title: fmt.Sprintf("⏰ %d 天内到期", days)},
+				{Title: "🔨 进行中"},
+				{Title: "📝 待我审批"},
+			}
+			sections[userID] = sec
+		}
+		return sec
+	}
+
+	overdue, err := s.overdueTasks(ctx)
+	if err != nil {
+		return "", err
+	}
+	seen := make(map[string]bool)
+	for _, t := range overdue {
+		if t.AssigneeID == nil || *t.AssigneeID == "" {
+			continue
+		}
+		seen[t.ID] = true
+		sec := section(*t.AssigneeID)
+		sec[0].Items = append(sec[0].Items, cardTaskItem(t, fmt.Sprintf("逾期 %d 天", t.OverdueDays)))
+	}
+
+	dueSoon, err := s.dueSoonTasks(ctx, days)
+	if err != nil {
+		return "", err
+	}
+	for _, t := range dueSoon {
+		seen[t.ID] = true
+		sec := section(*t.AssigneeID)
+		sec[1].Items = append(sec[1].Items, cardTaskItem(t, ""))
+	}
+
+	var inProgress []entity.Task
+	if err := s.activeTaskQuery(ctx).
+		Where("tasks.status = ?", entity.TaskStatusInProgress).
+		Order("tasks.planned_end ASC NULLS LAST").
+		Find(&inProgress).Error; err != nil {
+		return "", fmt.Errorf("查询进行中任务失败: %w", err)
+	}
+	for _, t := range inProgress {
+		if seen[t.ID] {
+			continue
+		}
+		sec := section(*t.AssigneeID)
+		sec[2].Items = append(sec[2].Items, cardTaskItem(t, ""))
+	}
+
+	var approvals []struct {
+		UserID    string
+		Title     string
+		CreatedAt time.Time
+	}
+	if err := s.db.WithContext(ctx).Table("approval_reviewers").
+		Select("approval_reviewers.user_id, approval_requests.title, approval_requests.created_at").
+		Joins("JOIN approval_requests ON approval_requests.id = approval_reviewers.approval_id").
+		Where("approval_reviewers.status = ? AND approval_requests.status = ?",
+			entity.PLMApprovalStatusPending, entity.PLMApprovalStatusPending).
+		Order("approval_requests.created_at ASC").
+		Scan(&approvals).Error; err != nil {
+		return "", fmt.Errorf("查询待审批失败: %w", err)
+	}
+	for _, a := range approvals {
+		sec := section(a.UserID)
+		sec[3].Items = append(sec[3].Items, feishu.CardTaskItem{
+			Title: a.Title,
+			Note:  "提交于 " + a.CreatedAt.Format("2006-01-02"),
+		})
+	}
+
+	prefix := fmt.Sprintf("生成摘要 %d 人（逾期 %d、即将到期 %d、进行中 %d、待审批 %d）",
+		len(sections), len(overdue), len(dueSoon), len(inProgress), len(approvals))
+	if s.feishuClient == nil {
+		return prefix + "，飞书未配置，未发送", nil
+	}
+
+	date := time.Now().Format("2006-01-02")
+	link := s.portalLink(ctx, "/my-tasks")
+	userIDs := make([]string, 0, len(sections))
+	for id := range sections {
+		userIDs = append(userIDs, id)
+	}
+	sort.Strings(userIDs)
+	cards := make(map[string]feishu.InteractiveCard, len(userIDs))
+	for _, id := range userIDs {
+		cards[id] = feishu.NewDailyDigestCard(date, sections[id][:], link)
+	}
+	sent, failed, err := s.sendCards(ctx, cards)
+	if err != nil {
+		return "", err
+	}
+	return sendSummary(prefix, sent, failed)
+}
+
+// =============================================================================
+// DBJobLocker — 数据库租约锁（未配置 Redis 时使用）
+// =============================================================================
+
+// DBJobLocker 基于 scheduler_locks 表主键冲突的锁
+type DBJobLocker struct {
+	db    *gorm.DB
+	owner string
+}
+
+// NewDBJobLocker 创建数据库锁
+func NewDBJobLocker(db *gorm.DB) *DBJobLocker {
+	host, _ := os.Hostname()
+	return &DBJobLocker{db: db, owner: fmt.Sprintf("%s:%d", host, os.Getpid())}
+}
+
+// Acquire 插入锁记录，主键已存在且未过期时获取失败
+func (l *DBJobLocker) Acquire(ctx context.Context, key string, ttl time.Duration) (bool, error) {
+	now := time.Now()
+	if err := l.db.WithContext(ctx).Where("expires_at < ?", now).Delete(&entity.SchedulerLock{}).Error; err != nil {
+		return false, fmt.Errorf("清理过期锁失败: %w", err)
+	}
+	result := l.db.WithContext(ctx).Clauses(clause.OnConflict{DoNothing: true}).Create(&entity.SchedulerLock{
+		LockKey:   key,
+		Owner:     l.owner,
+		ExpiresAt: now.Add(ttl),
+	})
+	if result.Error != nil {
+		return false, fmt.Errorf("获取数据库锁失败: %w", result.Error)
+	}
+	return result.RowsAffected == 1, nil
+}
@@ -75,7 +75,7 @@ func NewServices(repos *repository.Repositories, rdb *redis.Client, cfg *config.
 		Document:   NewDocumentService(repos.Document, repos.DocumentCategory, minioClient, cfg.MinIO.Bucket),
 		Feishu:     feishuSvc,
 		Template:   templateSvc,
-		Automation: nil, // Will be initialized with logger later if needed
+		Automation: nil, // 依赖项目/审批服务，在 main 中初始化
 		// V2 新增
 		ProjectBOM: NewProjectBOMService(repos.ProjectBOM, repos.Project, repos.Deliverable, repos.Material, repos.PartDrawing),
 		// V13 CMF
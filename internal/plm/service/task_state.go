@@ -0,0 +1,61 @@
+package service
+
+import (
+	"context"
+	"log"
+
+	"github.com/bitfantasy/nimo/internal/plm/entity"
+	"github.com/bitfantasy/nimo/internal/shared/engine"
+	"gorm.io/gorm"
+)
+
+// =============================================================================
+// 任务状态镜像 — 任务状态变化同步到 plm_task 状态机，驱动审批超时升级等定时器
+// =============================================================================
+
+// EntityTypeTask PLM 任务在状态机引擎中的实体类型（与状态机名称一致）
+const EntityTypeTask = "plm_task"
+
+// TaskStateMirror 任务表仍是状态的权威来源，引擎中的 plm_task 状态只是镜像：
+// 任务首次接入引擎时以变更前的状态为起始状态；按目标状态反查事件触发转换，
+// 目标状态不在状态机中（submitted、rejected 等）或镜像已偏离时取消该任务的定时器，
+// 避免对已离开的状态继续计时。同步失败只记日志，不阻断业务流程
+type TaskStateMirror struct {
+	engine *engine.Engine
+}
+
+// NewTaskStateMirror 创建任务状态镜像
+func NewTaskStateMirror(eng *engine.Engine) *TaskStateMirror {
+	return &TaskStateMirror{engine: eng}
+}
+
+// Sync 任务状态 fromStatus → toStatus 后同步到状态机
+func (m *TaskStateMirror) Sync(ctx context.Context, taskID, fromStatus, toStatus, operatorID string) {
+	if m == nil || m.engine == nil || taskID == "" || fromStatus == toStatus {
+		return
+	}
+	entityID := engine.EntityUUID(taskID)
+	err := m.engine.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		_, err := m.engine.FireInTx(tx, engine.FireRequest{
+			EntityType:   EntityTypeTask,
+			EntityID:     entityID,
+			TargetState:  toStatus,
+			InitialState: fromStatus,
+			EventData: map[string]interface{}{
+				"id":           taskID,
+				"needs_review": toStatus == entity.TaskStatusReviewing,
+			},
+			TriggeredBy:     operatorID,
+			TriggeredByType: "user",
+		})
+		return err
+	})
+	if err != nil {
+		log.Printf("[TaskStateMirror] 同步任务状态失败，取消定时器 (task=%s %s→%s): %v", taskID, fromStatus, toStatus, err)
+		if err := m.engine.CancelTimers(EntityTypeTask, entityID); err != nil {
+			log.Printf("[TaskStateMirror] %v", err)
+		}
+		return
+	}
+	m.engine.NotifyOutbox()
+}
@@ -0,0 +1,96 @@
+package service
+
+import (
+	"context"
+	"testing"
+
+	"github.com/bitfantasy/nimo/internal/plm/entity"
+	"github.com/bitfantasy/nimo/internal/plm/repository"
+	"github.com/bitfantasy/nimo/internal/shared/engine"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+func setupTaskStateTest(t *testing.T) (*gorm.DB, *engine.Engine, *TaskStateMirror) {
+	t.Helper()
+	db := setupServiceTestDB(t, &entity.User{}, &entity.Project{}, &entity.ProjectPhase{}, &entity.Task{},
+		&entity.TaskDependency{}, &entity.TaskComment{}, &entity.TaskActionLog{},
+		&entity.ApprovalRequest{}, &entity.ApprovalReviewer{},
+		&engine.StateMachineDefinition{}, &engine.StateTransition{}, &engine.EntityState{},
+		&engine.TransitionLog{}, &engine.ActionOutbox{}, &engine.EntityTimer{})
+	eng := engine.NewEngine(db, nil)
+	require.NoError(t, eng.RegisterMachine(engine.NewPLMTaskMachine()))
+	require.NoError(t, db.Create(&entity.Project{
+		ID: "p1", Code: "PRJ-001", Name: "状态镜像测试", Phase: "evt", ManagerID: "pm", CreatedBy: "pm",
+	}).Error)
+	return db, eng, NewTaskStateMirror(eng)
+}
+
+func mirroredTaskState(t *testing.T, eng *engine.Engine, taskID string) string {
+	t.Helper()
+	state, err := eng.GetCurrentState(EntityTypeTask, engine.EntityUUID(taskID))
+	require.NoError(t, err)
+	return state
+}
+
+func TestTaskStateMirrorFollowsApprovalAutoStart(t *testing.T) {
+	db, eng, mirror := setupTaskStateTest(t)
+	ctx := context.Background()
+	require.NoError(t, db.Create([]entity.Task{
+		{ID: "t1", ProjectID: "p1", Code: "T1", Title: "方案设计", Status: entity.TaskStatusReviewing, CreatedBy: "pm"},
+		{ID: "t2", ProjectID: "p1", Code: "T2", Title: "结构设计", Status: entity.TaskStatusPending, CreatedBy: "pm"},
+	}).Error)
+	require.NoError(t, db.Create(&entity.TaskDependency{ID: "d1", TaskID: "t2", DependsOnID: "t1", DependencyType: "FS"}).Error)
+	require.NoError(t, db.Create(&entity.ApprovalRequest{
+		ID: "a1", ProjectID: "p1", TaskID: "t1", Title: "方案评审", Status: entity.PLMApprovalStatusPending, RequestedBy: "u1",
+	}).Error)
+	require.NoError(t, db.Create(&entity.ApprovalReviewer{
+		ID: "r1", ApprovalID: "a1", UserID: "pm", Status: entity.PLMApprovalStatusPending,
+	}).Error)
+	mirror.Sync(ctx, "t1", entity.TaskStatusInProgress, entity.TaskStatusReviewing, "u1")
+	mirror.Sync(ctx, "t2", entity.TaskStatusUnassigned, entity.TaskStatusPending, "pm")
+
+	workflowSvc := NewWorkflowService(db, eng, nil, repository.NewProjectRepository(db), repository.NewTaskRepository(db))
+	approvalSvc := NewApprovalService(db, nil)
+	approvalSvc.SetTaskStateMirror(mirror)
+	approvalSvc.SetWorkflowService(workflowSvc)
+	require.NoError(t, approvalSvc.Approve(ctx, "a1", "pm", "同意"))
+
+	var t2 entity.Task
+	require.NoError(t, db.First(&t2, "id = ?", "t2").Error)
+	assert.Equal(t, entity.TaskStatusInProgress, t2.Status)
+	assert.Equal(t, entity.TaskStatusCompleted, mirroredTaskState(t, eng, "t1"))
+	assert.Equal(t, entity.TaskStatusInProgress, mirroredTaskState(t, eng, "t2"))
+
+	// 离开 reviewing 后审批超时定时器随之取消
+	timers, err := eng.GetTimers(EntityTypeTask, engine.EntityUUID("t1"))
+	require.NoError(t, err)
+	for _, timer := range timers {
+		assert.NotEqual(t, engine.TimerStatusPending, timer.Status)
+	}
+}
+
+func TestTaskStateMirrorFollowsManagerReview(t *testing.T) {
+	db, eng, mirror := setupTaskStateTest(t)
+	ctx := context.Background()
+	assignee := "u1"
+	require.NoError(t, db.Create(&entity.Task{
+		ID: "t1", ProjectID: "p1", Code: "T1", Title: "方案设计", Status: entity.TaskStatusInProgress,
+		AssigneeID: &assignee, CreatedBy: "pm",
+	}).Error)
+	mirror.Sync(ctx, "t1", entity.TaskStatusPending, entity.TaskStatusInProgress, "u1")
+
+	svc := NewProjectService(repository.NewProjectRepository(db), repository.NewTaskRepository(db), nil, nil, nil)
+	svc.SetTaskStateMirror(mirror)
+
+	// submitted 不在状态机中，镜像停在 in_progress；驳回后与任务表一致
+	require.NoError(t, svc.CompleteMyTask(ctx, "t1", "u1", nil))
+	require.NoError(t, svc.RejectTask(ctx, "p1", "t1", "pm", "补充测试报告"))
+	assert.Equal(t, entity.TaskStatusInProgress, mirroredTaskState(t, eng, "t1"))
+
+	// 再次提交后项目经理确认，镜像进入 completed
+	require.NoError(t, svc.CompleteMyTask(ctx, "t1", "u1", nil))
+	require.NoError(t, svc.ConfirmTask(ctx, "p1", "t1", "pm"))
+	assert.Equal(t, entity.TaskStatusCompleted, mirroredTaskState(t, eng, "t1"))
+}
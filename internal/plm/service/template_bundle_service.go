@@ -0,0 +1,920 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/bitfantasy/nimo/internal/plm/entity"
+	"github.com/bitfantasy/nimo/internal/shared/expr"
+	"github.com/bitfantasy/nimo/internal/shared/formschema"
+	"github.com/google/uuid"
+	"gopkg.in/yaml.v3"
+	"gorm.io/gorm"
+)
+
+// TemplateBundleService 模板包导入导出服务
+// 模板包是与数据库 ID 无关的自包含 JSON/YAML 文件，任务之间及表单、角色、评审结果、阶段门禁均按 task_code 关联，
+// 用于在环境之间迁移调好的模板；同时提供两个模板版本的差异对比。
+type TemplateBundleService struct {
+	db *gorm.DB
+}
+
+// NewTemplateBundleService 创建模板包服务
+func NewTemplateBundleService(db *gorm.DB) *TemplateBundleService {
+	return &TemplateBundleService{db: db}
+}
+
+// 模板包格式
+const (
+	TemplateBundleFormat        = "nimo-plm-template"
+	TemplateBundleFormatVersion = 1
+)
+
+// 导入时模板编码冲突的处理方式
+const (
+	BundleConflictRename  = "rename"  // 追加后缀生成新编码
+	BundleConflictVersion = "version" // 作为同一流程的新草稿版本导入
+	BundleConflictFail    = "fail"    // 直接报错
+)
+
+// TemplateBundle 模板包
+type TemplateBundle struct {
+	Format        string                    `json:"format"`
+	FormatVersion int                       `json:"format_version"`
+	ExportedAt    time.Time                 `json:"exported_at"`
+	Template      TemplateBundleMeta        `json:"template"`
+	Tasks         []TemplateBundleTask      `json:"tasks"`
+	Dependencies  []TemplateBundleDep       `json:"dependencies"`
+	Forms         []TemplateBundleForm      `json:"forms"`
+	PhaseRoles    []TemplateBundlePhaseRole `json:"phase_roles"`
+	Outcomes      []TemplateBundleOutcome   `json:"outcomes"`
+	PhaseGates    []TemplateBundlePhaseGate `json:"phase_gates"`
+	TaskRoles     []TemplateBundleTaskRole  `json:"task_roles"` // 任务引用的角色，导入时校验目标环境是否存在
+}
+
+// TemplateBundleMeta 模板基本信息
+type TemplateBundleMeta struct {
+	Code          string          `json:"code"`
+	BaseCode      string          `json:"base_code"`
+	Name          string          `json:"name"`
+	Description   string          `json:"description"`
+	TemplateType  string          `json:"template_type"`
+	ProductType   string          `json:"product_type"`
+	Phases        json.RawMessage `json:"phases"`
+	EstimatedDays int             `json:"estimated_days"`
+	Version       string          `json:"version"`
+}
+
+// TemplateBundleTask 模板任务（含审批设置和检查项）
+type TemplateBundleTask struct {
+	TaskCode             string          `json:"task_code"`
+	Name                 string          `json:"name"`
+	Description          string          `json:"description"`
+	Phase                string          `json:"phase"`
+	ParentTaskCode       string          `json:"parent_task_code"`
+	TaskType             string          `json:"task_type"`
+	DefaultAssigneeRole  string          `json:"default_assignee_role"`
+	EstimatedDays        int             `json:"estimated_days"`
+	IsCritical           bool            `json:"is_critical"`
+	Deliverables         json.RawMessage `json:"deliverables"`
+	Checklist            json.RawMessage `json:"checklist"`
+	RequiresApproval     bool            `json:"requires_approval"`
+	ApprovalType         string          `json:"approval_type"`
+	AutoCreateFeishuTask bool            `json:"auto_create_feishu_task"`
+	FeishuApprovalCode   string          `json:"feishu_approval_code"`
+	SortOrder            int             `json:"sort_order"`
+	IsLocked             bool            `json:"is_locked"`
+	InclusionCondition   json.RawMessage `json:"inclusion_condition,omitempty"`
+}
+
+// TemplateBundleDep 任务依赖
+type TemplateBundleDep struct {
+	TaskCode          string `json:"task_code"`
+	DependsOnTaskCode string `json:"depends_on_task_code"`
+	DependencyType    string `json:"dependency_type"`
+	LagDays           int    `json:"lag_days"`
+}
+
+// TemplateBundleForm 任务表单
+type TemplateBundleForm struct {
+	TaskCode string          `json:"task_code"`
+	Name     string          `json:"name"`
+	Fields   json.RawMessage `json:"fields"`
+}
+
+// TemplateBundlePhaseRole 阶段角色
+type TemplateBundlePhaseRole struct {
+	Phase           string `json:"phase"`
+	RoleCode        string `json:"role_code"`
+	RoleName        string `json:"role_name"`
+	IsRequired      bool   `json:"is_required"`
+	TriggerTaskCode string `json:"trigger_task_code"`
+}
+
+// TemplateBundleOutcome 任务评审结果选项
+type TemplateBundleOutcome struct {
+	TaskCode           string `json:"task_code"`
+	OutcomeCode        string `json:"outcome_code"`
+	OutcomeName        string `json:"outcome_name"`
+	OutcomeType        string `json:"outcome_type"`
+	RollbackToTaskCode string `json:"rollback_to_task_code"`
+	RollbackCascade    bool   `json:"rollback_cascade"`
+	SortOrder          int    `json:"sort_order"`
+}
+
+// TemplateBundlePhaseGate 阶段门禁
+type TemplateBundlePhaseGate struct {
+	Phase                string          `json:"phase"`
+	RequireDeliverables  bool            `json:"require_deliverables"`
+	RequireCriticalTasks bool            `json:"require_critical_tasks"`
+	MaxOpenECNs          *int            `json:"max_open_ecns"`
+	RequireBOMReleased   bool            `json:"require_bom_released"`
+	Deliverables         json.RawMessage `json:"deliverables"`
+}
+
+// TemplateBundleTaskRole 任务角色
+type TemplateBundleTaskRole struct {
+	Code string `json:"code"`
+	Name string `json:"name"`
+}
+
+// ============================================================
+// 导出
+// ============================================================
+
+// ExportBundle 导出模板包
+func (s *TemplateBundleService) ExportBundle(ctx context.Context, templateID string) (*TemplateBundle, error) {
+	db := s.db.WithContext(ctx)
+
+	var tmpl entity.ProjectTemplate
+	if err := db.First(&tmpl, "id = ?", templateID).Error; err != nil {
+		return nil, fmt.Errorf("模板不存在: %w", err)
+	}
+
+	bundle := &TemplateBundle{
+		Format:        TemplateBundleFormat,
+		FormatVersion: TemplateBundleFormatVersion,
+		ExportedAt:    time.Now(),
+		Template: TemplateBundleMeta{
+			Code:          tmpl.Code,
+			BaseCode:      tmpl.BaseCode,
+			Name:          tmpl.Name,
+			Description:   tmpl.Description,
+			TemplateType:  tmpl.TemplateType,
+			ProductType:   tmpl.ProductType,
+			Phases:        tmpl.Phases,
+			EstimatedDays: tmpl.EstimatedDays,
+			Version:       tmpl.Version,
+		},
+		Tasks:        []TemplateBundleTask{},
+		Dependencies: []TemplateBundleDep{},
+		Forms:        []TemplateBundleForm{},
+		PhaseRoles:   []TemplateBundlePhaseRole{},
+		Outcomes:     []TemplateBundleOutcome{},
+		PhaseGates:   []TemplateBundlePhaseGate{},
+		TaskRoles:    []TemplateBundleTaskRole{},
+	}
+
+	var tasks []entity.TemplateTask
+	if err := db.Where("template_id = ?", templateID).Order("sort_order ASC, task_code ASC").Find(&tasks).Error; err != nil {
+		return nil, fmt.Errorf("查询模板任务失败: %w", err)
+	}
+	roleCodes := make(map[string]bool)
+	for _, t := range tasks {
+		bundle.Tasks = append(bundle.Tasks, TemplateBundleTask{
+			TaskCode:             t.TaskCode,
+			Name:                 t.Name,
+			Description:          t.Description,
+			Phase:                t.Phase,
+			ParentTaskCode:       t.ParentTaskCode,
+			TaskType:             t.TaskType,
+			DefaultAssigneeRole:  t.DefaultAssigneeRole,
+			EstimatedDays:        t.EstimatedDays,
+			IsCritical:           t.IsCritical,
+			Deliverables:         t.Deliverables,
+			Checklist:            t.Checklist,
+			RequiresApproval:     t.RequiresApproval,
+			ApprovalType:         t.ApprovalType,
+			AutoCreateFeishuTask: t.AutoCreateFeishuTask,
+			FeishuApprovalCode:   t.FeishuApprovalCode,
+			SortOrder:            t.SortOrder,
+			IsLocked:             t.IsLocked,
+			InclusionCondition:   t.InclusionCondition,
+		})
+		if t.DefaultAssigneeRole != "" {
+			roleCodes[t.DefaultAssigneeRole] = true
+		}
+	}
+
+	var deps []entity.TemplateTaskDependency
+	if err := db.Where("template_id = ?", templateID).Order("task_code ASC, depends_on_task_code ASC").Find(&deps).Error; err != nil {
+		return nil, fmt.Errorf("查询任务依赖失败: %w", err)
+	}
+	for _, d := range deps {
+		bundle.Dependencies = append(bundle.Dependencies, TemplateBundleDep{
+			TaskCode:          d.TaskCode,
+			DependsOnTaskCode: d.DependsOnTaskCode,
+			DependencyType:    d.DependencyType,
+			LagDays:           d.LagDays,
+		})
+	}
+
+	var forms []entity.TemplateTaskForm
+	if err := db.Where("template_id = ?", templateID).Order("task_code ASC").Find(&forms).Error; err != nil {
+		return nil, fmt.Errorf("查询任务表单失败: %w", err)
+	}
+	for _, f := range forms {
+		bundle.Forms = append(bundle.Forms, TemplateBundleForm{TaskCode: f.TaskCode, Name: f.Name, Fields: f.Fields})
+	}
+
+	var phaseRoles []entity.TemplatePhaseRole
+	if err := db.Where("template_id = ?", templateID).Order("phase ASC, role_code ASC").Find(&phaseRoles).Error; err != nil {
+		return nil, fmt.Errorf("查询阶段角色失败: %w", err)
+	}
+	for _, r := range phaseRoles {
+		bundle.PhaseRoles = append(bundle.PhaseRoles, TemplateBundlePhaseRole{
+			Phase:           r.Phase,
+			RoleCode:        r.RoleCode,
+			RoleName:        r.RoleName,
+			IsRequired:      r.IsRequired,
+			TriggerTaskCode: r.TriggerTaskCode,
+		})
+	}
+
+	var outcomes []entity.TemplateTaskOutcome
+	if err := db.Where("template_id = ?", templateID).Order("task_code ASC, sort_order ASC").Find(&outcomes).Error; err != nil {
+		return nil, fmt.Errorf("查询评审结果选项失败: %w", err)
+	}
+	for _, o := range outcomes {
+		bundle.Outcomes = append(bundle.Outcomes, TemplateBundleOutcome{
+			TaskCode:           o.TaskCode,
+			OutcomeCode:        o.OutcomeCode,
+			OutcomeName:        o.OutcomeName,
+			OutcomeType:        o.OutcomeType,
+			RollbackToTaskCode: o.RollbackToTaskCode,
+			RollbackCascade:    o.RollbackCascade,
+			SortOrder:          o.SortOrder,
+		})
+	}
+
+	var gates []entity.TemplatePhaseGate
+	if err := db.Where("template_id = ?", templateID).Order("phase ASC").Find(&gates).Error; err != nil {
+		return nil, fmt.Errorf("查询阶段门禁失败: %w", err)
+	}
+	for _, g := range gates {
+		bundle.PhaseGates = append(bundle.PhaseGates, TemplateBundlePhaseGate{
+			Phase:                g.Phase,
+			RequireDeliverables:  g.RequireDeliverables,
+			RequireCriticalTasks: g.RequireCriticalTasks,
+			MaxOpenECNs:          g.MaxOpenECNs,
+			RequireBOMReleased:   g.RequireBOMReleased,
+			Deliverables:         g.Deliverables,
+		})
+	}
+
+	if len(roleCodes) > 0 {
+		var roles []entity.TaskRole
+		if err := db.Where("code IN ?", mapKeys(roleCodes)).Order("sort_order ASC").Find(&roles).Error; err != nil {
+			return nil, fmt.Errorf("查询任务角色失败: %w", err)
+		}
+		for _, r := range roles {
+			bundle.TaskRoles = append(bundle.TaskRoles, TemplateBundleTaskRole{Code: r.Code, Name: r.Name})
+		}
+	}
+
+	return bundle, nil
+}
+
+// MarshalBundle 按格式序列化模板包（json/yaml）
+func MarshalBundle(bundle *TemplateBundle, format string) ([]byte, error) {
+	data, err := json.MarshalIndent(bundle, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("序列化模板包失败: %w", err)
+	}
+	if format != "yaml" {
+		return data, nil
+	}
+	// 经由通用结构转换，YAML 键名与 JSON 一致，表单字段等 JSON 内容展开为 YAML 结构
+	var generic interface{}
+	if err := json.Unmarshal(data, &generic); err != nil {
+		return nil, fmt.Errorf("序列化模板包失败: %w", err)
+	}
+	out, err := yaml.Marshal(generic)
+	if err != nil {
+		return nil, fmt.Errorf("序列化模板包失败: %w", err)
+	}
+	return out, nil
+}
+
+// ParseBundle 解析模板包，JSON 是 YAML 的子集，两种格式统一按 YAML 解析
+func ParseBundle(data []byte) (*TemplateBundle, error) {
+	var generic interface{}
+	if err := yaml.Unmarshal(data, &generic); err != nil {
+		return nil, fmt.Errorf("解析模板包失败: %w", err)
+	}
+	jsonData, err := json.Marshal(generic)
+	if err != nil {
+		return nil, fmt.Errorf("解析模板包失败: %w", err)
+	}
+	var bundle TemplateBundle
+	if err := json.Unmarshal(jsonData, &bundle); err != nil {
+		return nil, fmt.Errorf("解析模板包失败: %w", err)
+	}
+	if bundle.Format != TemplateBundleFormat {
+		return nil, fmt.Errorf("不是模板包文件（format=%q）", bundle.Format)
+	}
+	if bundle.FormatVersion > TemplateBundleFormatVersion {
+		return nil, fmt.Errorf("模板包格式版本 %d 高于当前支持的版本 %d", bundle.FormatVersion, TemplateBundleFormatVersion)
+	}
+	return &bundle, nil
+}
+
+// ============================================================
+// 导入
+// ============================================================
+
+// ImportBundleOptions 导入选项
+type ImportBundleOptions struct {
+	OnConflict         string // rename/version/fail，默认 rename
+	Code               string // 指定新模板编码（为空沿用模板包中的编码）
+	CreateMissingRoles bool   // 目标环境缺少的任务角色按模板包中的定义创建
+}
+
+// ImportBundleResult 导入结果
+type ImportBundleResult struct {
+	Template     *entity.ProjectTemplate `json:"template"`
+	CodeRenamed  bool                    `json:"code_renamed"`  // 编码冲突已改名
+	CreatedRoles []string                `json:"created_roles"` // 新建的任务角色
+}
+
+// ImportBundle 导入模板包，生成新的草稿模板（所有 ID 重新生成）
+func (s *TemplateBundleService) ImportBundle(ctx context.Context, bundle *TemplateBundle, opts ImportBundleOptions, createdBy string) (*ImportBundleResult, error) {
+	if err := validateBundle(bundle); err != nil {
+		return nil, err
+	}
+	if opts.OnConflict == "" {
+		opts.OnConflict = BundleConflictRename
+	}
+	if !slices.Contains([]string{BundleConflictRename, BundleConflictVersion, BundleConflictFail}, opts.OnConflict) {
+		return nil, fmt.Errorf("不支持的冲突处理方式: %s", opts.OnConflict)
+	}
+
+	result := &ImportBundleResult{CreatedRoles: []string{}}
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		created, err := s.ensureTaskRoles(tx, bundle, opts.CreateMissingRoles)
+		if err != nil {
+			return err
+		}
+		result.CreatedRoles = created
+
+		tmpl, renamed, err := s.newTemplate(tx, bundle, opts, createdBy)
+		if err != nil {
+			return err
+		}
+		result.Template, result.CodeRenamed = tmpl, renamed
+
+		return createBundleChildren(tx, tmpl.ID, bundle)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// validateBundle 校验模板包内部引用（依赖、父任务、表单、评审结果、阶段角色均须指向包内任务）
+func validateBundle(bundle *TemplateBundle) error {
+	if strings.TrimSpace(bundle.Template.Code) == "" || strings.TrimSpace(bundle.Template.Name) == "" {
+		return fmt.Errorf("模板包缺少模板编码或名称")
+	}
+	if len(bundle.Tasks) == 0 {
+		return fmt.Errorf("模板包不包含任务")
+	}
+
+	var problems []string
+	codes := make(map[string]bool, len(bundle.Tasks))
+	for _, t := range bundle.Tasks {
+		if t.TaskCode == "" {
+			problems = append(problems, fmt.Sprintf("任务「%s」缺少编码", t.Name))
+			continue
+		}
+		if codes[t.TaskCode] {
+			problems = append(problems, fmt.Sprintf("任务编码重复: %s", t.TaskCode))
+		}
+		codes[t.TaskCode] = true
+	}
+	ref := func(what, code string) {
+		if code != "" && !codes[code] {
+			problems = append(problems, fmt.Sprintf("%s引用了不存在的任务: %s", what, code))
+		}
+	}
+	for _, t := range bundle.Tasks {
+		ref("任务 "+t.TaskCode+" 的父任务", t.ParentTaskCode)
+		if err := expr.ValidateCondition(t.InclusionCondition); err != nil {
+			problems = append(problems, fmt.Sprintf("任务 %s 的纳入条件无效: %v", t.TaskCode, err))
+		}
+		if _, err := ParseChecklistDefs(t.Checklist); err != nil {
+			problems = append(problems, fmt.Sprintf("任务 %s 的检查项无效: %v", t.TaskCode, err))
+		}
+	}
+	for _, d := range bundle.Dependencies {
+		ref("依赖", d.TaskCode)
+		ref("依赖", d.DependsOnTaskCode)
+	}
+	for _, f := range bundle.Forms {
+		ref("表单", f.TaskCode)
+		if _, err := formschema.ParseAndCheck(f.Fields); err != nil {
+			problems = append(problems, fmt.Sprintf("任务 %s 的表单: %v", f.TaskCode, err))
+		}
+	}
+	for _, o := range bundle.Outcomes {
+		ref("评审结果", o.TaskCode)
+		ref("评审结果回退", o.RollbackToTaskCode)
+	}
+	for _, r := range bundle.PhaseRoles {
+		ref("阶段角色触发", r.TriggerTaskCode)
+	}
+	for _, g := range bundle.PhaseGates {
+		var defs []entity.PhaseGateDeliverableDef
+		if len(g.Deliverables) > 0 {
+			if err := json.Unmarshal(g.Deliverables, &defs); err != nil {
+				problems = append(problems, fmt.Sprintf("阶段门禁 %s 交付物格式错误", g.Phase))
+			}
+		}
+		for _, d := range defs {
+			ref("阶段交付物", d.TaskCode)
+		}
+	}
+
+	if len(problems) > 0 {
+		return fmt.Errorf("模板包校验失败: %s", strings.Join(problems, "；"))
+	}
+	return nil
+}
+
+// ensureTaskRoles 校验任务引用的角色在目标环境存在，按需创建
+func (s *TemplateBundleService) ensureTaskRoles(tx *gorm.DB, bundle *TemplateBundle, createMissing bool) ([]string, error) {
+	referenced := make(map[string]bool)
+	for _, t := range bundle.Tasks {
+		if t.DefaultAssigneeRole != "" {
+			referenced[t.DefaultAssigneeRole] = true
+		}
+	}
+	if len(referenced) == 0 {
+		return []string{}, nil
+	}
+
+	var existing []string
+	if err := tx.Model(&entity.TaskRole{}).Where("code IN ?", mapKeys(referenced)).Pluck("code", &existing).Error; err != nil {
+		return nil, fmt.Errorf("查询任务角色失败: %w", err)
+	}
+	var missing []string
+	for _, code := range mapKeys(referenced) {
+		if !slices.Contains(existing, code) {
+			missing = append(missing, code)
+		}
+	}
+	if len(missing) == 0 {
+		return []string{}, nil
+	}
+	if !createMissing {
+		return nil, fmt.Errorf("目标环境缺少任务角色: %s", strings.Join(missing, ", "))
+	}
+
+	names := make(map[string]string, len(bundle.TaskRoles))
+	for _, r := range bundle.TaskRoles {
+		names[r.Code] = r.Name
+	}
+	var maxSort int
+	tx.Model(&entity.TaskRole{}).Select("COALESCE(MAX(sort_order), 0)").Scan(&maxSort)
+	now := time.Now()
+	for i, code := range missing {
+		name := names[code]
+		if name == "" {
+			return nil, fmt.Errorf("模板包未定义任务角色 %s 的名称，无法创建", code)
+		}
+		role := &entity.TaskRole{
+			ID:        uuid.New().String(),
+			Code:      code,
+			Name:      name,
+			SortOrder: maxSort + i + 1,
+			CreatedAt: now,
+			UpdatedAt: now,
+		}
+		if err := tx.Create(role).Error; err != nil {
+			return nil, fmt.Errorf("创建任务角色失败: %w", err)
+		}
+	}
+	return missing, nil
+}
+
+// newTemplate 按冲突处理方式创建模板记录
+func (s *TemplateBundleService) newTemplate(tx *gorm.DB, bundle *TemplateBundle, opts ImportBundleOptions, createdBy string) (*entity.ProjectTemplate, bool, error) {
+	meta := bundle.Template
+	code := meta.Code
+	if opts.Code != "" {
+		code = opts.Code
+	}
+	baseCode := meta.BaseCode
+	if baseCode == "" {
+		baseCode = meta.Code
+	}
+	phases := meta.Phases
+	if len(phases) == 0 || string(phases) == "null" {
+		phases = json.RawMessage(`["CONCEPT","EVT","DVT","PVT","MP"]`)
+	}
+	version := meta.Version
+	if version == "" {
+		version = "1.0"
+	}
+
+	tmpl := &entity.ProjectTemplate{
+		ID:            uuid.New().String(),
+		Code:          code,
+		Name:          meta.Name,
+		Description:   meta.Description,
+		TemplateType:  meta.TemplateType,
+		ProductType:   meta.ProductType,
+		Phases:        phases,
+		EstimatedDays: meta.EstimatedDays,
+		IsActive:      true,
+		Version:       version,
+		Status:        "draft",
+		BaseCode:      code,
+		CreatedBy:     createdBy,
+		CreatedAt:     time.Now(),
+		UpdatedAt:     time.Now(),
+	}
+	if tmpl.TemplateType == "" {
+		tmpl.TemplateType = "CUSTOM"
+	}
+
+	renamed := false
+	switch {
+	case opts.OnConflict == BundleConflictVersion && opts.Code == "":
+		// 作为同一流程的新版本：沿用目标环境中的 base_code，版本号在已有最高版本上递增
+		var versions []entity.ProjectTemplate
+		if err := tx.Where("base_code = ? OR code = ?", baseCode, baseCode).Find(&versions).Error; err != nil {
+			return nil, false, fmt.Errorf("查询流程版本失败: %w", err)
+		}
+		if len(versions) > 0 {
+			latest := versions[0]
+			for _, v := range versions[1:] {
+				if IsTemplateVersionGreater(v.Version, latest.Version) {
+					latest = v
+				}
+			}
+			if !IsTemplateVersionGreater(tmpl.Version, latest.Version) {
+				tmpl.Version = NextTemplateVersion(latest.Version)
+			}
+			tmpl.BaseCode = baseCode
+			tmpl.Code = fmt.Sprintf("%s-v%s", baseCode, tmpl.Version)
+			tmpl.ParentTemplateID = &latest.ID
+			renamed = tmpl.Code != code
+		}
+	default:
+		taken, err := templateCodeTaken(tx, code)
+		if err != nil {
+			return nil, false, err
+		}
+		if taken {
+			if opts.OnConflict == BundleConflictFail || opts.Code != "" {
+				return nil, false, fmt.Errorf("模板编码 %s 已存在", code)
+			}
+			for i := 1; taken; i++ {
+				tmpl.Code = fmt.Sprintf("%s-import-%d", code, i)
+				if taken, err = templateCodeTaken(tx, tmpl.Code); err != nil {
+					return nil, false, err
+				}
+			}
+			tmpl.BaseCode = tmpl.Code
+			renamed = true
+		}
+		// 同名同类型模板不允许重复（与 CreateTemplate 一致）
+		var count int64
+		tx.Model(&entity.ProjectTemplate{}).Where("name = ? AND template_type = ?", tmpl.Name, tmpl.TemplateType).Count(&count)
+		if count > 0 {
+			tmpl.Name = fmt.Sprintf("%s（%s）", tmpl.Name, tmpl.Code)
+		}
+	}
+
+	if taken, err := templateCodeTaken(tx, tmpl.Code); err != nil {
+		return nil, false, err
+	} else if taken {
+		return nil, false, fmt.Errorf("模板编码 %s 已存在", tmpl.Code)
+	}
+	if err := tx.Create(tmpl).Error; err != nil {
+		return nil, false, fmt.Errorf("创建模板失败: %w", err)
+	}
+	return tmpl, renamed, nil
+}
+
+func templateCodeTaken(tx *gorm.DB, code string) (bool, error) {
+	var count int64
+	if err := tx.Model(&entity.ProjectTemplate{}).Where("code = ?", code).Count(&count).Error; err != nil {
+		return false, fmt.Errorf("查询模板编码失败: %w", err)
+	}
+	return count > 0, nil
+}
+
+// createBundleChildren 创建模板任务、依赖、表单、阶段角色、评审结果和阶段门禁
+func createBundleChildren(tx *gorm.DB, templateID string, bundle *TemplateBundle) error {
+	now := time.Now()
+
+	tasks := make([]entity.TemplateTask, 0, len(bundle.Tasks))
+	for _, t := range bundle.Tasks {
+		tasks = append(tasks, entity.TemplateTask{
+			ID:                   uuid.New().String(),
+			TemplateID:           templateID,
+			TaskCode:             t.TaskCode,
+			Name:                 t.Name,
+			Description:          t.Description,
+			Phase:                t.Phase,
+			ParentTaskCode:       t.ParentTaskCode,
+			TaskType:             t.TaskType,
+			DefaultAssigneeRole:  t.DefaultAssigneeRole,
+			EstimatedDays:        t.EstimatedDays,
+			IsCritical:           t.IsCritical,
+			Deliverables:         t.Deliverables,
+			Checklist:            t.Checklist,
+			RequiresApproval:     t.RequiresApproval,
+			ApprovalType:         t.ApprovalType,
+			AutoCreateFeishuTask: t.AutoCreateFeishuTask,
+			FeishuApprovalCode:   t.FeishuApprovalCode,
+			SortOrder:            t.SortOrder,
+			IsLocked:             t.IsLocked,
+			InclusionCondition:   t.InclusionCondition,
+			CreatedAt:            now,
+			UpdatedAt:            now,
+		})
+	}
+	if err := tx.Create(&tasks).Error; err != nil {
+		return fmt.Errorf("创建模板任务失败: %w", err)
+	}
+
+	if len(bundle.Dependencies) > 0 {
+		deps := make([]entity.TemplateTaskDependency, 0, len(bundle.Dependencies))
+		for _, d := range bundle.Dependencies {
+			deps = append(deps, entity.TemplateTaskDependency{
+				ID:                uuid.New().String(),
+				TemplateID:        templateID,
+				TaskCode:          d.TaskCode,
+				DependsOnTaskCode: d.DependsOnTaskCode,
+				DependencyType:    d.DependencyType,
+				LagDays:           d.LagDays,
+			})
+		}
+		if err := tx.Create(&deps).Error; err != nil {
+			return fmt.Errorf("创建任务依赖失败: %w", err)
+		}
+	}
+
+	if len(bundle.Forms) > 0 {
+		forms := make([]entity.TemplateTaskForm, 0, len(bundle.Forms))
+		for _, f := range bundle.Forms {
+			fields := f.Fields
+			if len(fields) == 0 || string(fields) == "null" {
+				fields = json.RawMessage(`[]`)
+			}
+			forms = append(forms, entity.TemplateTaskForm{
+				ID:         uuid.New().String()[:32],
+				TemplateID: templateID,
+				TaskCode:   f.TaskCode,
+				Name:       f.Name,
+				Fields:     fields,
+				CreatedAt:  now,
+				UpdatedAt:  now,
+			})
+		}
+		if err := tx.Create(&forms).Error; err != nil {
+			return fmt.Errorf("创建任务表单失败: %w", err)
+		}
+	}
+
+	if len(bundle.PhaseRoles) > 0 {
+		roles := make([]entity.TemplatePhaseRole, 0, len(bundle.PhaseRoles))
+		for _, r := range bundle.PhaseRoles {
+			roles = append(roles, entity.TemplatePhaseRole{
+				ID:              uuid.New().String(),
+				TemplateID:      templateID,
+				Phase:           r.Phase,
+				RoleCode:        r.RoleCode,
+				RoleName:        r.RoleName,
+				IsRequired:      r.IsRequired,
+				TriggerTaskCode: r.TriggerTaskCode,
+			})
+		}
+		if err := tx.Create(&roles).Error; err != nil {
+			return fmt.Errorf("创建阶段角色失败: %w", err)
+		}
+	}
+
+	if len(bundle.Outcomes) > 0 {
+		outcomes := make([]entity.TemplateTaskOutcome, 0, len(bundle.Outcomes))
+		for _, o := range bundle.Outcomes {
+			outcomes = append(outcomes, entity.TemplateTaskOutcome{
+				ID:                 uuid.New().String(),
+				TemplateID:         templateID,
+				TaskCode:           o.TaskCode,
+				OutcomeCode:        o.OutcomeCode,
+				OutcomeName:        o.OutcomeName,
+				OutcomeType:        o.OutcomeType,
+				RollbackToTaskCode: o.RollbackToTaskCode,
+				RollbackCascade:    o.RollbackCascade,
+				SortOrder:          o.SortOrder,
+			})
+		}
+		if err := tx.Create(&outcomes).Error; err != nil {
+			return fmt.Errorf("创建评审结果选项失败: %w", err)
+		}
+	}
+
+	if len(bundle.PhaseGates) > 0 {
+		gates := make([]entity.TemplatePhaseGate, 0, len(bundle.PhaseGates))
+		for _, g := range bundle.PhaseGates {
+			gates = append(gates, entity.TemplatePhaseGate{
+				ID:                   uuid.New().String(),
+				TemplateID:           templateID,
+				Phase:                strings.ToLower(g.Phase),
+				RequireDeliverables:  g.RequireDeliverables,
+				RequireCriticalTasks: g.RequireCriticalTasks,
+				MaxOpenECNs:          g.MaxOpenECNs,
+				RequireBOMReleased:   g.RequireBOMReleased,
+				Deliverables:         g.Deliverables,
+				CreatedAt:            now,
+				UpdatedAt:            now,
+			})
+		}
+		if err := tx.Create(&gates).Error; err != nil {
+			return fmt.Errorf("创建阶段门禁失败: %w", err)
+		}
+	}
+	return nil
+}
+
+// ============================================================
+// 版本对比
+// ============================================================
+
+// TemplateDiff 两个模板版本的差异
+type TemplateDiff struct {
+	From     TemplateDiffSide      `json:"from"`
+	To       TemplateDiffSide      `json:"to"`
+	Template []TemplateFieldChange `json:"template"` // 模板基本信息变化
+	Sections []TemplateSectionDiff `json:"sections"`
+	HasDiff  bool                  `json:"has_diff"`
+}
+
+// TemplateDiffSide 对比的模板版本
+type TemplateDiffSide struct {
+	ID      string `json:"id"`
+	Code    string `json:"code"`
+	Version string `json:"version"`
+	Status  string `json:"status"`
+}
+
+// TemplateSectionDiff 某一类配置（任务、依赖、表单……）的差异
+type TemplateSectionDiff struct {
+	Section string               `json:"section"`
+	Added   []string             `json:"added"`
+	Removed []string             `json:"removed"`
+	Changed []TemplateItemChange `json:"changed"`
+}
+
+// TemplateItemChange 条目字段变化
+type TemplateItemChange struct {
+	Key     string                `json:"key"`
+	Changes []TemplateFieldChange `json:"changes"`
+}
+
+// TemplateFieldChange 字段变化
+type TemplateFieldChange struct {
+	Field string      `json:"field"`
+	From  interface{} `json:"from"`
+	To    interface{} `json:"to"`
+}
+
+// DiffTemplates 对比两个模板版本（from 为旧版本，to 为新版本）
+func (s *TemplateBundleService) DiffTemplates(ctx context.Context, fromID, toID string) (*TemplateDiff, error) {
+	var from, to entity.ProjectTemplate
+	if err := s.db.WithContext(ctx).First(&from, "id = ?", fromID).Error; err != nil {
+		return nil, fmt.Errorf("模板不存在: %w", err)
+	}
+	if err := s.db.WithContext(ctx).First(&to, "id = ?", toID).Error; err != nil {
+		return nil, fmt.Errorf("模板不存在: %w", err)
+	}
+	fromBundle, err := s.ExportBundle(ctx, fromID)
+	if err != nil {
+		return nil, err
+	}
+	toBundle, err := s.ExportBundle(ctx, toID)
+	if err != nil {
+		return nil, err
+	}
+
+	diff := &TemplateDiff{
+		From: TemplateDiffSide{ID: from.ID, Code: from.Code, Version: from.Version, Status: from.Status},
+		To:   TemplateDiffSide{ID: to.ID, Code: to.Code, Version: to.Version, Status: to.Status},
+	}
+	// 编码/版本号是不同版本的固有差异，不计入
+	fromMeta, toMeta := fromBundle.Template, toBundle.Template
+	fromMeta.Code, fromMeta.Version, fromMeta.BaseCode = "", "", ""
+	toMeta.Code, toMeta.Version, toMeta.BaseCode = "", "", ""
+	diff.Template = compareFields(fromMeta, toMeta)
+
+	diff.Sections = []TemplateSectionDiff{
+		diffSection("tasks", fromBundle.Tasks, toBundle.Tasks, func(t TemplateBundleTask) string { return t.TaskCode }),
+		diffSection("dependencies", fromBundle.Dependencies, toBundle.Dependencies, func(d TemplateBundleDep) string {
+			return d.TaskCode + " <- " + d.DependsOnTaskCode
+		}),
+		diffSection("forms", fromBundle.Forms, toBundle.Forms, func(f TemplateBundleForm) string { return f.TaskCode }),
+		diffSection("phase_roles", fromBundle.PhaseRoles, toBundle.PhaseRoles, func(r TemplateBundlePhaseRole) string {
+			return r.Phase + "/" + r.RoleCode
+		}),
+		diffSection("outcomes", fromBundle.Outcomes, toBundle.Outcomes, func(o TemplateBundleOutcome) string {
+			return o.TaskCode + "/" + o.OutcomeCode
+		}),
+		diffSection("phase_gates", fromBundle.PhaseGates, toBundle.PhaseGates, func(g TemplateBundlePhaseGate) string { return g.Phase }),
+	}
+
+	diff.HasDiff = len(diff.Template) > 0
+	for _, sec := range diff.Sections {
+		if len(sec.Added)+len(sec.Removed)+len(sec.Changed) > 0 {
+			diff.HasDiff = true
+		}
+	}
+	return diff, nil
+}
+
+// diffSection 按 key 对比两组配置条目
+func diffSection[T any](section string, from, to []T, key func(T) string) TemplateSectionDiff {
+	sec := TemplateSectionDiff{Section: section, Added: []string{}, Removed: []string{}, Changed: []TemplateItemChange{}}
+	fromMap := make(map[string]T, len(from))
+	for _, item := range from {
+		fromMap[key(item)] = item
+	}
+	toMap := make(map[string]T, len(to))
+	for _, item := range to {
+		toMap[key(item)] = item
+	}
+
+	for _, item := range to {
+		k := key(item)
+		old, ok := fromMap[k]
+		if !ok {
+			sec.Added = append(sec.Added, k)
+			continue
+		}
+		if changes := compareFields(old, item); len(changes) > 0 {
+			sec.Changed = append(sec.Changed, TemplateItemChange{Key: k, Changes: changes})
+		}
+	}
+	for _, item := range from {
+		if _, ok := toMap[key(item)]; !ok {
+			sec.Removed = append(sec.Removed, key(item))
+		}
+	}
+	return sec
+}
+
+// compareFields 按 JSON 字段对比两个结构，JSON 内容按语义比较（忽略格式差异）
+func compareFields(from, to interface{}) []TemplateFieldChange {
+	fromMap, toMap := toGenericMap(from), toGenericMap(to)
+	fields := make(map[string]bool)
+	for k := range fromMap {
+		fields[k] = true
+	}
+	for k := range toMap {
+		fields[k] = true
+	}
+
+	changes := []TemplateFieldChange{}
+	for _, f := range mapKeys(fields) {
+		if !reflect.DeepEqual(fromMap[f], toMap[f]) {
+			changes = append(changes, TemplateFieldChange{Field: f, From: fromMap[f], To: toMap[f]})
+		}
+	}
+	return changes
+}
+
+func toGenericMap(v interface{}) map[string]interface{} {
+	data, _ := json.Marshal(v)
+	var m map[string]interface{}
+	_ = json.Unmarshal(data, &m)
+	return m
+}
+
+// mapKeys 排序后的 map 键
+func mapKeys(m map[string]bool) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
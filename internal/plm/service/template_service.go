@@ -5,12 +5,15 @@ import (
 	"encoding/json"
 	"fmt"
 	"log"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/bitfantasy/nimo/internal/plm/entity"
 	"github.com/bitfantasy/nimo/internal/plm/repository"
 	"github.com/bitfantasy/nimo/internal/plm/sse"
+	"github.com/bitfantasy/nimo/internal/shared/expr"
+	"github.com/bitfantasy/nimo/internal/shared/schedule"
 	"github.com/google/uuid"
 	"gorm.io/gorm"
 )
@@ -21,6 +24,9 @@ type TemplateService struct {
 	projectRepo  *repository.ProjectRepository
 	taskFormRepo *repository.TaskFormRepository
 	projectSvc   *ProjectService
+	calendarSvc  *CalendarService
+	phaseGateSvc *PhaseGateService
+	checklistSvc *ChecklistService
 }
 
 // SetProjectService 注入项目服务（用于任务激活通知）
@@ -28,6 +34,21 @@ func (s *TemplateService) SetProjectService(svc *ProjectService) {
 	s.projectSvc = svc
 }
 
+// SetPhaseGateService 注入阶段门禁服务（创建项目时复制模板门禁条件和交付物）
+func (s *TemplateService) SetPhaseGateService(svc *PhaseGateService) {
+	s.phaseGateSvc = svc
+}
+
+// SetChecklistService 注入任务检查项服务（创建项目时实例化模板任务检查项）
+func (s *TemplateService) SetChecklistService(svc *ChecklistService) {
+	s.checklistSvc = svc
+}
+
+// SetCalendarService 注入工作日历服务（按节假日/调休计算任务日期）
+func (s *TemplateService) SetCalendarService(svc *CalendarService) {
+	s.calendarSvc = svc
+}
+
 // NewTemplateService 创建模板服务
 func NewTemplateService(templateRepo *repository.TemplateRepository, projectRepo *repository.ProjectRepository, taskFormRepo *repository.TaskFormRepository) *TemplateService {
 	return &TemplateService{
@@ -142,6 +163,7 @@ func (s *TemplateService) DuplicateTemplate(ctx context.Context, id string, newC
 			ApprovalType:         task.ApprovalType,
 			AutoCreateFeishuTask: task.AutoCreateFeishuTask,
 			FeishuApprovalCode:   task.FeishuApprovalCode,
+			InclusionCondition:   task.InclusionCondition,
 			SortOrder:            task.SortOrder,
 			IsLocked:             task.IsLocked,
 			CreatedAt:            time.Now(),
@@ -378,6 +400,45 @@ func (s *TemplateService) RevertTemplate(ctx context.Context, id string) (*entit
 	return &prevPublished, nil
 }
 
+// IsTemplateVersionGreater 比较版本号 a > b（格式: "1.0", "2.1" 等）
+func IsTemplateVersionGreater(a, b string) bool {
+	parseVer := func(v string) (int, int) {
+		v = strings.TrimPrefix(v, "v")
+		v = strings.TrimPrefix(v, "V")
+		parts := strings.SplitN(v, ".", 2)
+		major, _ := strconv.Atoi(parts[0])
+		minor := 0
+		if len(parts) > 1 {
+			minor, _ = strconv.Atoi(parts[1])
+		}
+		return major, minor
+	}
+	aMajor, aMinor := parseVer(a)
+	bMajor, bMinor := parseVer(b)
+	if aMajor != bMajor {
+		return aMajor > bMajor
+	}
+	return aMinor > bMinor
+}
+
+// NextTemplateVersion 自动递增版本号
+func NextTemplateVersion(current string) string {
+	current = strings.TrimPrefix(current, "v")
+	current = strings.TrimPrefix(current, "V")
+	parts := strings.SplitN(current, ".", 2)
+	major, _ := strconv.Atoi(parts[0])
+	minor := 0
+	if len(parts) > 1 {
+		minor, _ = strconv.Atoi(parts[1])
+	}
+	minor++
+	if minor >= 10 {
+		major++
+		minor = 0
+	}
+	return fmt.Sprintf("%d.%d", major, minor)
+}
+
 // ListVersions 获取同一流程的所有版本
 func (s *TemplateService) ListVersions(ctx context.Context, baseCode string) ([]entity.ProjectTemplate, error) {
 	db := s.templateRepo.DB()
@@ -390,14 +451,16 @@ func (s *TemplateService) ListVersions(ctx context.Context, baseCode string) ([]
 
 // CreateProjectFromTemplateInput 从模板创建项目的输入
 type CreateProjectFromTemplateInput struct {
-	TemplateID      string            `json:"template_id"`
-	ProjectName     string            `json:"project_name"`
-	ProjectCode     string            `json:"project_code"`
-	ProductID       string            `json:"product_id"`
-	StartDate       time.Time         `json:"start_date"`
-	PMID            string            `json:"pm_user_id"`
-	SkipWeekends    bool              `json:"skip_weekends"`
-	RoleAssignments map[string]string `json:"role_assignments"` // role -> user_id
+	TemplateID      string                 `json:"template_id"`
+	ProjectName     string                 `json:"project_name"`
+	ProjectCode     string                 `json:"project_code"`
+	ProductID       string                 `json:"product_id"`
+	StartDate       time.Time              `json:"start_date"`
+	PMID            string                 `json:"pm_user_id"`
+	SkipWeekends    bool                   `json:"skip_weekends"`
+	CalendarID      string                 `json:"calendar_id"`      // 工作日历；为空且 SkipWeekends 时使用默认日历
+	RoleAssignments map[string]string      `json:"role_assignments"` // role -> user_id
+	Attributes      map[string]interface{} `json:"attributes"`       // 项目属性，见 ProjectAttr* 常量
 }
 
 // CreateProjectFromTemplate 从模板创建项目
@@ -408,6 +471,19 @@ func (s *TemplateService) CreateProjectFromTemplate(ctx context.Context, input *
 		return nil, fmt.Errorf("template not found: %w", err)
 	}
 
+	// 按项目属性筛选模板任务，被排除任务的前后依赖自动接上
+	attrs := projectAttributes(template, input.Attributes)
+	plan, err := planTemplateTasks(template.Tasks, template.Dependencies, attrs)
+	if err != nil {
+		return nil, err
+	}
+	template.Tasks, template.Dependencies = plan.Tasks, plan.Dependencies
+
+	cal, calendarID, err := s.projectCalendar(ctx, input.CalendarID, input.SkipWeekends)
+	if err != nil {
+		return nil, err
+	}
+
 	// 创建项目
 	var productID *string
 	if input.ProductID != "" {
@@ -419,9 +495,12 @@ func (s *TemplateService) CreateProjectFromTemplate(ctx context.Context, input *
 		Code:        input.ProjectCode,
 		Name:        input.ProjectName,
 		ProductID:   productID,
+		TemplateID:  &template.ID, // 模板级自动化规则按来源模板匹配
 		Phase:       "CONCEPT",
 		Status:      "planning",
 		StartDate:   &input.StartDate,
+		CalendarID:  calendarID,
+		Attributes:  attrs,
 		ManagerID:   input.PMID,
 		Progress:    0,
 		CreatedBy:   createdBy,
@@ -461,7 +540,7 @@ func (s *TemplateService) CreateProjectFromTemplate(ctx context.Context, input *
 
 	// 构建任务依赖图
 	depGraph := buildDependencyGraph(template.Dependencies)
-	taskDates := calculateTaskDates(template.Tasks, depGraph, input.StartDate, input.SkipWeekends)
+	taskDates := calculateTaskDates(template.Tasks, depGraph, input.StartDate, cal)
 
 	// 构建模板任务的 task_code -> TemplateTask 映射
 	ttMap := make(map[string]entity.TemplateTask)
@@ -663,6 +742,16 @@ func (s *TemplateService) CreateProjectFromTemplate(ctx context.Context, input *
 		}
 	}
 
+	// 阶段门禁条件和交付物
+	if err := s.phaseGateSvc.ApplyTemplateGates(ctx, template.ID, phaseIDMap, taskMap); err != nil {
+		log.Printf("[CreateProjectFromTemplate] 复制阶段门禁失败: %v", err) // 不阻塞项目创建
+	}
+
+	// 任务检查项
+	if err := s.checklistSvc.InstantiateTemplateChecklists(ctx, project.ID, createdBy, template.Tasks, taskMap); err != nil {
+		log.Printf("[CreateProjectFromTemplate] 创建任务检查项失败: %v", err) // 不阻塞项目创建
+	}
+
 	// 异步发送初始激活任务的飞书通知
 	log.Printf("[CreateProjectFromTemplate] projectSvc=%v, initialActiveTasks=%d", s.projectSvc != nil, len(initialActiveTasks))
 	if s.projectSvc != nil && len(initialActiveTasks) > 0 {
@@ -682,6 +771,263 @@ func (s *TemplateService) CreateProjectFromTemplate(ctx context.Context, input *
 	return project, nil
 }
 
+// PreviewProjectInput 预览从模板创建项目的输入
+type PreviewProjectInput struct {
+	StartDate    time.Time              `json:"start_date"`
+	SkipWeekends bool                   `json:"skip_weekends"`
+	CalendarID   string                 `json:"calendar_id"`
+	Attributes   map[string]interface{} `json:"attributes"`
+}
+
+// ProjectPreview 项目任务预览
+type ProjectPreview struct {
+	Attributes entity.JSONB           `json:"attributes"`
+	Tasks      []PreviewTask          `json:"tasks"`
+	Excluded   []ExcludedTemplateTask `json:"excluded"`
+	Rewired    []RewiredDependency    `json:"rewired"`
+}
+
+// PreviewTask 预览任务
+type PreviewTask struct {
+	TaskCode            string     `json:"task_code"`
+	Name                string     `json:"name"`
+	Phase               string     `json:"phase"`
+	ParentTaskCode      string     `json:"parent_task_code"`
+	TaskType            string     `json:"task_type"`
+	DefaultAssigneeRole string     `json:"default_assignee_role"`
+	StartDate           *time.Time `json:"start_date"`
+	DueDate             *time.Time `json:"due_date"`
+	DependsOn           []string   `json:"depends_on"`
+}
+
+// PreviewProjectFromTemplate 按项目属性预览将创建的任务（含被排除的任务和重接的依赖），不落库
+func (s *TemplateService) PreviewProjectFromTemplate(ctx context.Context, templateID string, input *PreviewProjectInput) (*ProjectPreview, error) {
+	template, err := s.templateRepo.GetWithTasks(ctx, templateID)
+	if err != nil {
+		return nil, fmt.Errorf("template not found: %w", err)
+	}
+
+	attrs := projectAttributes(template, input.Attributes)
+	plan, err := planTemplateTasks(template.Tasks, template.Dependencies, attrs)
+	if err != nil {
+		return nil, err
+	}
+	cal, _, err := s.projectCalendar(ctx, input.CalendarID, input.SkipWeekends)
+	if err != nil {
+		return nil, err
+	}
+	if input.StartDate.IsZero() {
+		input.StartDate = time.Now().Truncate(24 * time.Hour)
+	}
+
+	depGraph := buildDependencyGraph(plan.Dependencies)
+	taskDates := calculateTaskDates(plan.Tasks, depGraph, input.StartDate, cal)
+	preview := &ProjectPreview{
+		Attributes: attrs,
+		Tasks:      make([]PreviewTask, 0, len(plan.Tasks)),
+		Excluded:   plan.Excluded,
+		Rewired:    plan.Rewired,
+	}
+	for _, t := range plan.Tasks {
+		dependsOn := []string{}
+		for _, d := range depGraph[t.TaskCode] {
+			dependsOn = append(dependsOn, d.DependsOnTaskCode)
+		}
+		dates := taskDates[t.TaskCode]
+		preview.Tasks = append(preview.Tasks, PreviewTask{
+			TaskCode:            t.TaskCode,
+			Name:                t.Name,
+			Phase:               t.Phase,
+			ParentTaskCode:      t.ParentTaskCode,
+			TaskType:            t.TaskType,
+			DefaultAssigneeRole: t.DefaultAssigneeRole,
+			StartDate:           dates.Start,
+			DueDate:             dates.End,
+			DependsOn:           dependsOn,
+		})
+	}
+	if preview.Excluded == nil {
+		preview.Excluded = []ExcludedTemplateTask{}
+	}
+	if preview.Rewired == nil {
+		preview.Rewired = []RewiredDependency{}
+	}
+	return preview, nil
+}
+
+// projectCalendar 工作日历：指定日历或跳过周末时按日历排期（含节假日/调休），否则按自然日
+func (s *TemplateService) projectCalendar(ctx context.Context, calendarID string, skipWeekends bool) (schedule.Calendar, *string, error) {
+	if calendarID != "" {
+		c, err := s.calendarSvc.Calendar(ctx, calendarID)
+		if err != nil {
+			return nil, nil, err
+		}
+		return c, &calendarID, nil
+	}
+	if skipWeekends {
+		c, err := s.calendarSvc.Calendar(ctx, "")
+		if err != nil {
+			return nil, nil, err
+		}
+		return c, nil, nil
+	}
+	return nil, nil, nil
+}
+
+// ============================================================
+// 条件任务：按项目属性决定模板任务是否包含
+// ============================================================
+
+// 项目属性（模板任务包含条件中可引用的变量，未提供时取默认值）
+const (
+	ProjectAttrProductType   = "product_type"   // 产品类型，默认取模板的产品类型
+	ProjectAttrTargetMarkets = "target_markets" // 目标市场列表，如 ["CN","EU","US"]
+	ProjectAttrHasBattery    = "has_battery"    // 是否带电池
+	ProjectAttrNewTooling    = "new_tooling"    // 是否新开模具
+)
+
+// ExcludedTemplateTask 被排除的模板任务
+type ExcludedTemplateTask struct {
+	TaskCode string `json:"task_code"`
+	Name     string `json:"name"`
+	Phase    string `json:"phase"`
+	Reason   string `json:"reason"`
+}
+
+// RewiredDependency 绕过被排除任务后接上的依赖
+type RewiredDependency struct {
+	TaskCode          string   `json:"task_code"`
+	DependsOnTaskCode string   `json:"depends_on_task_code"`
+	Via               []string `json:"via"` // 被绕过的任务
+}
+
+// TemplateTaskPlan 筛选后的模板任务和依赖
+type TemplateTaskPlan struct {
+	Tasks        []entity.TemplateTask
+	Dependencies []entity.TemplateTaskDependency
+	Excluded     []ExcludedTemplateTask
+	Rewired      []RewiredDependency
+}
+
+// projectAttributes 项目属性补全默认值
+func projectAttributes(template *entity.ProjectTemplate, input map[string]interface{}) entity.JSONB {
+	attrs := entity.JSONB{
+		ProjectAttrProductType:   template.ProductType,
+		ProjectAttrTargetMarkets: []interface{}{},
+		ProjectAttrHasBattery:    false,
+		ProjectAttrNewTooling:    false,
+	}
+	for k, v := range input {
+		if v != nil {
+			attrs[k] = v
+		}
+	}
+	return attrs
+}
+
+// planTemplateTasks 求值包含条件筛选任务（父任务被排除时子任务一并排除），
+// 并把依赖被排除任务的边接到其前置任务上（依赖类型沿用原边，滞后天数累加，同一对任务取最大滞后）
+func planTemplateTasks(tasks []entity.TemplateTask, deps []entity.TemplateTaskDependency, attrs map[string]interface{}) (*TemplateTaskPlan, error) {
+	plan := &TemplateTaskPlan{}
+
+	byCode := make(map[string]entity.TemplateTask, len(tasks))
+	for _, t := range tasks {
+		byCode[t.TaskCode] = t
+	}
+	excluded := make(map[string]string) // task_code -> 原因
+	var isExcluded func(code string, seen map[string]bool) (string, error)
+	isExcluded = func(code string, seen map[string]bool) (string, error) {
+		if reason, ok := excluded[code]; ok {
+			return reason, nil
+		}
+		t := byCode[code]
+		reason := ""
+		if t.ParentTaskCode != "" && !seen[t.ParentTaskCode] {
+			if _, ok := byCode[t.ParentTaskCode]; ok {
+				seen[code] = true
+				parentReason, err := isExcluded(t.ParentTaskCode, seen)
+				if err != nil {
+					return "", err
+				}
+				if parentReason != "" {
+					reason = "父任务 " + t.ParentTaskCode + " 已排除"
+				}
+			}
+		}
+		if reason == "" {
+			ok, err := expr.EvaluateCondition(t.InclusionCondition, attrs)
+			if err != nil {
+				return "", fmt.Errorf("任务 %s 的包含条件无效: %w", code, err)
+			}
+			if !ok {
+				reason = "不满足条件: " + expr.DescribeCondition(t.InclusionCondition)
+			}
+		}
+		excluded[code] = reason
+		return reason, nil
+	}
+
+	for _, t := range tasks {
+		reason, err := isExcluded(t.TaskCode, map[string]bool{})
+		if err != nil {
+			return nil, err
+		}
+		if reason != "" {
+			plan.Excluded = append(plan.Excluded, ExcludedTemplateTask{TaskCode: t.TaskCode, Name: t.Name, Phase: t.Phase, Reason: reason})
+			continue
+		}
+		plan.Tasks = append(plan.Tasks, t)
+	}
+	if len(plan.Excluded) == 0 {
+		plan.Dependencies = deps
+		return plan, nil
+	}
+
+	depGraph := buildDependencyGraph(deps)
+	type edgeKey struct{ task, dependsOn string }
+	edges := make(map[edgeKey]int) // -> plan.Dependencies 下标
+	addEdge := func(dep entity.TemplateTaskDependency, via []string) {
+		key := edgeKey{dep.TaskCode, dep.DependsOnTaskCode}
+		if i, ok := edges[key]; ok {
+			if dep.LagDays > plan.Dependencies[i].LagDays {
+				plan.Dependencies[i].LagDays = dep.LagDays
+			}
+			return
+		}
+		edges[key] = len(plan.Dependencies)
+		plan.Dependencies = append(plan.Dependencies, dep)
+		if len(via) > 0 {
+			plan.Rewired = append(plan.Rewired, RewiredDependency{TaskCode: dep.TaskCode, DependsOnTaskCode: dep.DependsOnTaskCode, Via: via})
+		}
+	}
+
+	// bypass 沿被排除任务的前置依赖向上找到保留的任务
+	var bypass func(edge entity.TemplateTaskDependency, via []string, visited map[string]bool)
+	bypass = func(edge entity.TemplateTaskDependency, via []string, visited map[string]bool) {
+		upstream := edge.DependsOnTaskCode
+		if _, known := byCode[upstream]; !known || excluded[upstream] == "" {
+			addEdge(edge, via)
+			return
+		}
+		if visited[upstream] {
+			return
+		}
+		visited[upstream] = true
+		for _, up := range depGraph[upstream] {
+			next := edge
+			next.DependsOnTaskCode = up.DependsOnTaskCode
+			next.LagDays = edge.LagDays + up.LagDays
+			bypass(next, append(append([]string{}, via...), upstream), visited)
+		}
+	}
+	for _, t := range plan.Tasks {
+		for _, dep := range depGraph[t.TaskCode] {
+			bypass(dep, nil, map[string]bool{})
+		}
+	}
+	return plan, nil
+}
+
 // TaskDates 任务日期
 type TaskDates struct {
 	Start *time.Time
@@ -697,8 +1043,11 @@ func buildDependencyGraph(deps []entity.TemplateTaskDependency) map[string][]ent
 	return graph
 }
 
-// calculateTaskDates 计算任务日期
-func calculateTaskDates(tasks []entity.TemplateTask, depGraph map[string][]entity.TemplateTaskDependency, startDate time.Time, skipWeekends bool) map[string]TaskDates {
+// calculateTaskDates 计算任务日期，cal 为空时按自然日
+func calculateTaskDates(tasks []entity.TemplateTask, depGraph map[string][]entity.TemplateTaskDependency, startDate time.Time, cal schedule.Calendar) map[string]TaskDates {
+	if cal != nil {
+		startDate = schedule.NextWorkday(cal, startDate)
+	}
 	dates := make(map[string]TaskDates)
 	taskMap := make(map[string]entity.TemplateTask)
 	for _, t := range tasks {
@@ -727,22 +1076,22 @@ func calculateTaskDates(tasks []entity.TemplateTask, depGraph map[string][]entit
 			}
 
 			depStart := calculateStart(dep.DependsOnTaskCode)
-			depEnd := addWorkDays(depStart, depTask.EstimatedDays, skipWeekends)
+			depEnd := addWorkDays(depStart, depTask.EstimatedDays, cal)
 
 			switch dep.DependencyType {
 			case "FS": // 完成-开始
-				candidateStart := addWorkDays(depEnd, dep.LagDays, skipWeekends)
+				candidateStart := addWorkDays(depEnd, dep.LagDays, cal)
 				if candidateStart.After(maxDate) {
 					maxDate = candidateStart
 				}
 			case "SS": // 开始-开始
-				candidateStart := addWorkDays(depStart, dep.LagDays, skipWeekends)
+				candidateStart := addWorkDays(depStart, dep.LagDays, cal)
 				if candidateStart.After(maxDate) {
 					maxDate = candidateStart
 				}
 			default:
 				// 默认 FS
-				candidateStart := addWorkDays(depEnd, dep.LagDays, skipWeekends)
+				candidateStart := addWorkDays(depEnd, dep.LagDays, cal)
 				if candidateStart.After(maxDate) {
 					maxDate = candidateStart
 				}
@@ -755,27 +1104,17 @@ func calculateTaskDates(tasks []entity.TemplateTask, depGraph map[string][]entit
 	// 计算所有任务日期
 	for _, task := range tasks {
 		start := calculateStart(task.TaskCode)
-		end := addWorkDays(start, task.EstimatedDays, skipWeekends)
+		end := addWorkDays(start, task.EstimatedDays, cal)
 		dates[task.TaskCode] = TaskDates{Start: &start, End: &end}
 	}
 
 	return dates
 }
 
-// addWorkDays 添加工作日
-func addWorkDays(start time.Time, days int, skipWeekends bool) time.Time {
-	if days <= 0 {
-		return start
-	}
-
-	result := start
-	for i := 0; i < days; i++ {
-		result = result.AddDate(0, 0, 1)
-		if skipWeekends {
-			for result.Weekday() == time.Saturday || result.Weekday() == time.Sunday {
-				result = result.AddDate(0, 0, 1)
-			}
-		}
+// addWorkDays 添加工作日，cal 为空时按自然日
+func addWorkDays(start time.Time, days int, cal schedule.Calendar) time.Time {
+	if cal == nil {
+		cal = schedule.EveryDayCalendar{}
 	}
-	return result
+	return schedule.AddWorkdays(cal, start, days)
 }
@@ -0,0 +1,143 @@
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"github.com/bitfantasy/nimo/internal/plm/entity"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPlanTemplateTasks(t *testing.T) {
+	battery := json.RawMessage(`"has_battery == true"`)
+	task := func(code, parent string, cond json.RawMessage) entity.TemplateTask {
+		return entity.TemplateTask{TaskCode: code, Name: "任务" + code, ParentTaskCode: parent, InclusionCondition: cond}
+	}
+	dep := func(task, dependsOn, typ string, lag int) entity.TemplateTaskDependency {
+		return entity.TemplateTaskDependency{TaskCode: task, DependsOnTaskCode: dependsOn, DependencyType: typ, LagDays: lag}
+	}
+
+	tests := []struct {
+		name         string
+		tasks        []entity.TemplateTask
+		deps         []entity.TemplateTaskDependency
+		wantTasks    []string
+		wantExcluded map[string]string // task_code -> 原因
+		wantDeps     []string          // task->depends_on type+lag
+		wantRewired  map[string][]string
+	}{
+		{
+			name: "父任务排除时子孙任务一并排除",
+			tasks: []entity.TemplateTask{
+				task("A", "", nil),
+				task("G", "C", nil), // 子任务排在父任务之前
+				task("P", "", battery),
+				task("C", "P", nil),
+			},
+			wantTasks: []string{"A"},
+			wantExcluded: map[string]string{
+				"G": "父任务 C 已排除",
+				"P": "不满足条件: ",
+				"C": "父任务 P 已排除",
+			},
+		},
+		{
+			name:  "绕过连续排除的任务时滞后天数累加，依赖类型沿用原边",
+			tasks: []entity.TemplateTask{task("A", "", nil), task("B", "", battery), task("C", "", battery), task("D", "", nil)},
+			deps: []entity.TemplateTaskDependency{
+				dep("B", "A", "FS", 3),
+				dep("C", "B", "FS", 2),
+				dep("D", "C", "SS", 1),
+			},
+			wantTasks:    []string{"A", "D"},
+			wantExcluded: map[string]string{"B": "不满足条件: ", "C": "不满足条件: "},
+			wantDeps:     []string{"D->A SS+6"},
+			wantRewired:  map[string][]string{"D->A": {"C", "B"}},
+		},
+		{
+			name: "同一对任务经多条路径相连时取最大滞后",
+			tasks: []entity.TemplateTask{
+				task("A", "", nil), task("X", "", battery), task("Y", "", battery), task("D", "", nil),
+			},
+			deps: []entity.TemplateTaskDependency{
+				dep("X", "A", "FS", 1),
+				dep("Y", "A", "FS", 5),
+				dep("D", "A", "FS", 3),
+				dep("D", "X", "FS", 1),
+				dep("D", "Y", "FS", 0),
+			},
+			wantTasks:    []string{"A", "D"},
+			wantExcluded: map[string]string{"X": "不满足条件: ", "Y": "不满足条件: "},
+			wantDeps:     []string{"D->A FS+5"},
+		},
+		{
+			name: "被排除任务之间的依赖成环时不死循环",
+			tasks: []entity.TemplateTask{
+				task("S", "", nil), task("X", "", battery), task("Y", "", battery), task("T", "", nil),
+			},
+			deps: []entity.TemplateTaskDependency{
+				dep("X", "Y", "FS", 1),
+				dep("Y", "X", "FS", 1),
+				dep("Y", "S", "FS", 2),
+				dep("T", "X", "FS", 0),
+			},
+			wantTasks:    []string{"S", "T"},
+			wantExcluded: map[string]string{"X": "不满足条件: ", "Y": "不满足条件: "},
+			wantDeps:     []string{"T->S FS+3"},
+			wantRewired:  map[string][]string{"T->S": {"X", "Y"}},
+		},
+		{
+			name: "父子关系成环时不死循环",
+			tasks: []entity.TemplateTask{
+				task("P", "Q", nil), task("Q", "P", battery), task("Z", "", nil),
+			},
+			wantTasks:    []string{"Z"},
+			wantExcluded: map[string]string{"P": "父任务 Q 已排除", "Q": "不满足条件: "},
+		},
+		{
+			name:      "没有排除任务时依赖原样保留",
+			tasks:     []entity.TemplateTask{task("A", "", nil), task("B", "", nil)},
+			deps:      []entity.TemplateTaskDependency{dep("B", "A", "FF", 2)},
+			wantTasks: []string{"A", "B"},
+			wantDeps:  []string{"B->A FF+2"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			plan, err := planTemplateTasks(tt.tasks, tt.deps, map[string]interface{}{ProjectAttrHasBattery: false})
+			require.NoError(t, err)
+
+			var kept []string
+			for _, task := range plan.Tasks {
+				kept = append(kept, task.TaskCode)
+			}
+			assert.Equal(t, tt.wantTasks, kept)
+
+			require.Len(t, plan.Excluded, len(tt.wantExcluded))
+			for _, ex := range plan.Excluded {
+				assert.Contains(t, ex.Reason, tt.wantExcluded[ex.TaskCode], ex.TaskCode)
+			}
+
+			var deps []string
+			for _, d := range plan.Dependencies {
+				deps = append(deps, fmt.Sprintf("%s->%s %s+%d", d.TaskCode, d.DependsOnTaskCode, d.DependencyType, d.LagDays))
+			}
+			assert.Equal(t, tt.wantDeps, deps)
+
+			require.Len(t, plan.Rewired, len(tt.wantRewired))
+			for _, r := range plan.Rewired {
+				assert.Equal(t, tt.wantRewired[r.TaskCode+"->"+r.DependsOnTaskCode], r.Via)
+			}
+		})
+	}
+}
+
+func TestPlanTemplateTasksInvalidCondition(t *testing.T) {
+	_, err := planTemplateTasks([]entity.TemplateTask{
+		{TaskCode: "A", InclusionCondition: json.RawMessage(`"has_battery =="`)},
+	}, nil, map[string]interface{}{})
+	assert.ErrorContains(t, err, "任务 A 的包含条件无效")
+}
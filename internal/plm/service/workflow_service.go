@@ -9,6 +9,7 @@ import (
 
 	"github.com/bitfantasy/nimo/internal/plm/entity"
 	"github.com/bitfantasy/nimo/internal/plm/repository"
+	"github.com/bitfantasy/nimo/internal/plm/sse"
 	"github.com/bitfantasy/nimo/internal/shared/engine"
 	"github.com/bitfantasy/nimo/internal/shared/feishu"
 	srmsvc "github.com/bitfantasy/nimo/internal/srm/service"
@@ -34,6 +35,13 @@ type WorkflowService struct {
 	taskFormRepo        *repository.TaskFormRepository
 	srmProcurementSvc   *srmsvc.ProcurementService
 	bomRepo             *repository.ProjectBOMRepository
+	scheduleService     *ScheduleService
+	automationSvc       *AutomationService
+	reviewSvc           *ReviewMeetingService
+	taskSync            *FeishuTaskSyncService
+	phaseGate           *PhaseGateService
+	checklist           *ChecklistService
+	taskStates          *TaskStateMirror
 }
 
 // NewWorkflowService 创建工作流服务
@@ -44,6 +52,7 @@ func NewWorkflowService(db *gorm.DB, eng *engine.Engine, fc *feishu.FeishuClient
 		feishuClient: fc,
 		projectRepo:  projectRepo,
 		taskRepo:     taskRepo,
+		taskStates:   NewTaskStateMirror(eng),
 	}
 }
 
@@ -67,8 +76,38 @@ func (s *WorkflowService) SetBOMRepo(repo *repository.ProjectBOMRepository) {
 	s.bomRepo = repo
 }
 
+// SetScheduleService 注入进度计划服务（任务开始/完成后自动重排）
+func (s *WorkflowService) SetScheduleService(svc *ScheduleService) {
+	s.scheduleService = svc
+}
+
+// SetAutomationService 注入自动化规则服务（任务开始/完成后按规则执行）
+func (s *WorkflowService) SetAutomationService(svc *AutomationService) {
+	s.automationSvc = svc
+}
+
+// SetReviewMeetingService 注入评审会议服务（关联评审未结束时任务不能完成）
+func (s *WorkflowService) SetReviewMeetingService(svc *ReviewMeetingService) {
+	s.reviewSvc = svc
+}
+
+// SetFeishuTaskSyncService 注入飞书任务同步服务（指派、开始、完成后推送飞书任务）
+func (s *WorkflowService) SetFeishuTaskSyncService(svc *FeishuTaskSyncService) {
+	s.taskSync = svc
+}
+
+// SetPhaseGateService 注入阶段门禁服务（任务完成后登记交付物）
+func (s *WorkflowService) SetPhaseGateService(svc *PhaseGateService) {
+	s.phaseGate = svc
+}
+
+// SetChecklistService 注入任务检查项服务（必检项未勾选时不能完成任务）
+func (s *WorkflowService) SetChecklistService(svc *ChecklistService) {
+	s.checklist = svc
+}
+
 // AssignTask 指派任务
-// 把任务状态从 unassigned → pending，记录操作日志，可选创建飞书任务
+// 把任务状态从 unassigned → pending，记录操作日志，开启 auto_create_feishu_task 时同步飞书任务
 func (s *WorkflowService) AssignTask(ctx context.Context, projectID, taskID, assigneeID, feishuUserID, operatorID string) error {
 	task, err := s.taskRepo.FindByID(ctx, taskID)
 	if err != nil {
@@ -93,31 +132,14 @@ func (s *WorkflowService) AssignTask(ctx context.Context, projectID, taskID, ass
 	}
 
 	// 记录操作日志
+	s.taskStates.Sync(ctx, taskID, fromStatus, entity.TaskStatusPending, operatorID)
 	s.logAction(ctx, projectID, taskID, entity.TaskActionAssign, fromStatus, entity.TaskStatusPending, operatorID, map[string]interface{}{
 		"assignee_id":   assigneeID,
 		"feishu_user_id": feishuUserID,
 	}, "")
 
-	// 异步创建飞书任务（不阻断主流程）
-	if s.feishuClient != nil && task.AutoCreateFeishuTask && feishuUserID != "" {
-		go func() {
-			bgCtx := context.Background()
-			taskGUID, err := s.feishuClient.CreateTask(bgCtx, feishu.CreateTaskReq{
-				Summary:     task.Title,
-				Description: task.Description,
-				Members: []feishu.TaskMember{
-					{ID: feishuUserID, Role: "assignee"},
-				},
-			})
-			if err != nil {
-				log.Printf("[WorkflowService] 飞书任务创建失败 (task=%s): %v", taskID, err)
-				return
-			}
-			// 保存飞书任务ID到task
-			s.db.WithContext(bgCtx).Model(&entity.Task{}).Where("id = ?", taskID).Update("feishu_task_id", taskGUID)
-			log.Printf("[WorkflowService] 飞书任务创建成功 task=%s feishu_guid=%s", taskID, taskGUID)
-		}()
-	}
+	// 异步创建/转派飞书任务（不阻断主流程）
+	s.taskSync.SyncAssignedAsync(taskID, feishuUserID)
 
 	// 异步发飞书卡片通知给被指派人
 	if s.feishuClient != nil {
@@ -128,7 +150,7 @@ func (s *WorkflowService) AssignTask(ctx context.Context, projectID, taskID, ass
 }
 
 // StartTask 开始任务
-// 检查前置依赖是否完成，状态 pending → in_progress
+// 按依赖类型检查前置任务（FS 需完成、SS 需开始），状态 pending → in_progress
 func (s *WorkflowService) StartTask(ctx context.Context, projectID, taskID, operatorID string) error {
 	task, err := s.taskRepo.FindByID(ctx, taskID)
 	if err != nil {
@@ -155,12 +177,28 @@ func (s *WorkflowService) StartTask(ctx context.Context, projectID, taskID, oper
 	}
 
 	// 记录操作日志
+	s.taskStates.Sync(ctx, taskID, entity.TaskStatusPending, entity.TaskStatusInProgress, operatorID)
 	s.logAction(ctx, projectID, taskID, entity.TaskActionStart, entity.TaskStatusPending, entity.TaskStatusInProgress, operatorID, nil, "")
 
+	s.afterTaskStarted(ctx, projectID, task, operatorID)
+
+	return nil
+}
+
+// afterTaskStarted 任务 pending → in_progress 后的处理，手动开始和依赖满足自动开始共用：
+// 采购控件、SS 后续任务、进度重排、TASK_START 自动化规则、飞书任务同步
+func (s *WorkflowService) afterTaskStarted(ctx context.Context, projectID string, task *entity.Task, operatorID string) {
 	// Hook: 检测 procurement_control 字段，自动创建采购需求
 	go s.handleProcurementControl(context.Background(), task, operatorID)
 
-	return nil
+	// SS 依赖的后续任务在前置开始后即可启动
+	s.checkAndStartDependentTasks(ctx, projectID, task.ID)
+	s.scheduleService.RescheduleAsync(projectID, "task_started")
+	s.automationSvc.FireAsync(ctx, AutomationEvent{
+		Type: entity.AutomationTriggerTaskStart, ProjectID: projectID, TaskID: task.ID,
+		FromStatus: entity.TaskStatusPending, ToStatus: entity.TaskStatusInProgress, OperatorID: operatorID,
+	})
+	s.taskSync.SyncAsync(task.ID)
 }
 
 // CompleteTask 完成任务
@@ -177,6 +215,19 @@ func (s *WorkflowService) CompleteTask(ctx context.Context, projectID, taskID, o
 		return fmt.Errorf("任务当前状态[%s]不允许完成，需要处于 in_progress 状态", task.Status)
 	}
 
+	// FF/SF 依赖约束完成时间
+	if err := checkFinishDependencies(ctx, s.taskRepo, taskID); err != nil {
+		return err
+	}
+	// 关联评审须已结束
+	if err := s.reviewSvc.CheckTaskReviews(ctx, taskID); err != nil {
+		return err
+	}
+	// 必检项须全部勾选
+	if err := s.checklist.EnsureComplete(ctx, taskID); err != nil {
+		return err
+	}
+
 	if task.RequiresApproval {
 		// 智能路由：判断走 agent 自动审批还是人工审批
 		if s.routingService != nil {
@@ -198,6 +249,7 @@ func (s *WorkflowService) CompleteTask(ctx context.Context, projectID, taskID, o
 				if err := s.taskRepo.Update(ctx, task); err != nil {
 					return fmt.Errorf("更新任务失败: %w", err)
 				}
+				s.taskStates.Sync(ctx, taskID, entity.TaskStatusInProgress, entity.TaskStatusCompleted, "agent")
 				s.logAction(ctx, projectID, taskID, entity.TaskActionApprove, entity.TaskStatusInProgress, entity.TaskStatusCompleted, "agent", map[string]interface{}{
 					"routing_rule_id":   decision.RuleID,
 					"routing_rule_name": decision.RuleName,
@@ -205,14 +257,13 @@ func (s *WorkflowService) CompleteTask(ctx context.Context, projectID, taskID, o
 				}, "智能路由: Agent自动审批通过")
 
 				s.checkAndStartDependentTasks(ctx, projectID, taskID)
-
-				if s.feishuClient != nil && task.FeishuTaskID != "" {
-					go func() {
-						if err := s.feishuClient.CompleteTask(context.Background(), task.FeishuTaskID); err != nil {
-							log.Printf("[WorkflowService] 飞书任务完成失败 (feishu_task=%s): %v", task.FeishuTaskID, err)
-						}
-					}()
-				}
+				s.scheduleService.RescheduleAsync(projectID, "task_completed")
+				s.automationSvc.FireAsync(ctx, AutomationEvent{
+					Type: entity.AutomationTriggerTaskComplete, ProjectID: projectID, TaskID: taskID,
+					FromStatus: entity.TaskStatusInProgress, ToStatus: entity.TaskStatusCompleted, OperatorID: "agent",
+				})
+				s.taskSync.SyncAsync(taskID)
+				s.phaseGate.FulfillTaskDeliverables(ctx, taskID, operatorID, nil)
 				return nil
 			}
 		}
@@ -222,6 +273,7 @@ func (s *WorkflowService) CompleteTask(ctx context.Context, projectID, taskID, o
 		if err := s.taskRepo.Update(ctx, task); err != nil {
 			return fmt.Errorf("更新任务失败: %w", err)
 		}
+		s.taskStates.Sync(ctx, taskID, entity.TaskStatusInProgress, entity.TaskStatusReviewing, operatorID)
 		s.logAction(ctx, projectID, taskID, entity.TaskActionSubmitReview, entity.TaskStatusInProgress, entity.TaskStatusReviewing, operatorID, nil, "")
 	} else {
 		// 不需审批 → completed
@@ -232,19 +284,21 @@ func (s *WorkflowService) CompleteTask(ctx context.Context, projectID, taskID, o
 		if err := s.taskRepo.Update(ctx, task); err != nil {
 			return fmt.Errorf("更新任务失败: %w", err)
 		}
+		s.taskStates.Sync(ctx, taskID, entity.TaskStatusInProgress, entity.TaskStatusCompleted, operatorID)
 		s.logAction(ctx, projectID, taskID, entity.TaskActionComplete, entity.TaskStatusInProgress, entity.TaskStatusCompleted, operatorID, nil, "")
 
 		// 检查并启动依赖任务
 		s.checkAndStartDependentTasks(ctx, projectID, taskID)
+		s.scheduleService.RescheduleAsync(projectID, "task_completed")
+		s.automationSvc.FireAsync(ctx, AutomationEvent{
+			Type: entity.AutomationTriggerTaskComplete, ProjectID: projectID, TaskID: taskID,
+			FromStatus: entity.TaskStatusInProgress, ToStatus: entity.TaskStatusCompleted, OperatorID: operatorID,
+		})
 
 		// 异步完成飞书任务
-		if s.feishuClient != nil && task.FeishuTaskID != "" {
-			go func() {
-				if err := s.feishuClient.CompleteTask(context.Background(), task.FeishuTaskID); err != nil {
-					log.Printf("[WorkflowService] 飞书任务完成失败 (feishu_task=%s): %v", task.FeishuTaskID, err)
-				}
-			}()
-		}
+		s.taskSync.SyncAsync(taskID)
+		// 登记任务产出的阶段交付物
+		s.phaseGate.FulfillTaskDeliverables(ctx, taskID, operatorID, nil)
 	}
 
 	return nil
@@ -284,6 +338,7 @@ func (s *WorkflowService) SubmitReview(ctx context.Context, projectID, taskID, o
 		if err := s.taskRepo.Update(ctx, task); err != nil {
 			return fmt.Errorf("更新任务失败: %w", err)
 		}
+		s.taskStates.Sync(ctx, taskID, entity.TaskStatusReviewing, entity.TaskStatusRejected, operatorID)
 		s.logAction(ctx, projectID, taskID, entity.TaskActionReject, entity.TaskStatusReviewing, entity.TaskStatusRejected, operatorID, map[string]interface{}{
 			"outcome_code": outcomeCode,
 		}, comment)
@@ -300,11 +355,15 @@ func (s *WorkflowService) SubmitReview(ctx context.Context, projectID, taskID, o
 		if err := s.taskRepo.Update(ctx, task); err != nil {
 			return fmt.Errorf("更新任务失败: %w", err)
 		}
+		s.taskStates.Sync(ctx, taskID, entity.TaskStatusReviewing, entity.TaskStatusInProgress, operatorID)
 		s.logAction(ctx, projectID, taskID, entity.TaskActionReject, entity.TaskStatusReviewing, entity.TaskStatusInProgress, operatorID, map[string]interface{}{
 			"outcome_code": outcomeCode,
 		}, comment)
 	} else {
-		// 审批通过 → completed
+		// 审批通过 → completed，关联评审须已结束
+		if err := s.reviewSvc.CheckTaskReviews(ctx, taskID); err != nil {
+			return err
+		}
 		now := time.Now()
 		task.Status = entity.TaskStatusCompleted
 		task.CompletedAt = &now
@@ -312,21 +371,23 @@ func (s *WorkflowService) SubmitReview(ctx context.Context, projectID, taskID, o
 		if err := s.taskRepo.Update(ctx, task); err != nil {
 			return fmt.Errorf("更新任务失败: %w", err)
 		}
+		s.taskStates.Sync(ctx, taskID, entity.TaskStatusReviewing, entity.TaskStatusCompleted, operatorID)
 		s.logAction(ctx, projectID, taskID, entity.TaskActionApprove, entity.TaskStatusReviewing, entity.TaskStatusCompleted, operatorID, map[string]interface{}{
 			"outcome_code": outcomeCode,
 		}, comment)
 
 		// 检查并启动依赖任务
 		s.checkAndStartDependentTasks(ctx, projectID, taskID)
+		s.scheduleService.RescheduleAsync(projectID, "task_completed")
+		s.automationSvc.FireAsync(ctx, AutomationEvent{
+			Type: entity.AutomationTriggerTaskComplete, ProjectID: projectID, TaskID: taskID,
+			FromStatus: entity.TaskStatusReviewing, ToStatus: entity.TaskStatusCompleted, OperatorID: operatorID,
+		})
 
 		// 异步完成飞书任务
-		if s.feishuClient != nil && task.FeishuTaskID != "" {
-			go func() {
-				if err := s.feishuClient.CompleteTask(context.Background(), task.FeishuTaskID); err != nil {
-					log.Printf("[WorkflowService] 飞书任务完成失败 (feishu_task=%s): %v", task.FeishuTaskID, err)
-				}
-			}()
-		}
+		s.taskSync.SyncAsync(taskID)
+		// 登记任务产出的阶段交付物
+		s.phaseGate.FulfillTaskDeliverables(ctx, taskID, operatorID, nil)
 	}
 
 	return nil
@@ -350,6 +411,7 @@ func (s *WorkflowService) RollbackTask(ctx context.Context, projectID, taskID, r
 	if err := s.db.WithContext(ctx).Save(&targetTask).Error; err != nil {
 		return fmt.Errorf("重置目标任务失败: %w", err)
 	}
+	s.taskStates.Sync(ctx, targetTask.ID, fromStatus, entity.TaskStatusInProgress, operatorID)
 	s.logAction(ctx, projectID, targetTask.ID, entity.TaskActionRollback, fromStatus, entity.TaskStatusInProgress, operatorID, map[string]interface{}{
 		"triggered_by_task": taskID,
 		"cascade":           cascade,
@@ -376,6 +438,7 @@ func (s *WorkflowService) RollbackTask(ctx context.Context, projectID, taskID, r
 					log.Printf("[WorkflowService] 重置后续任务失败 (task=%s): %v", t.ID, err)
 					continue
 				}
+				s.taskStates.Sync(ctx, t.ID, oldStatus, entity.TaskStatusPending, operatorID)
 				s.logAction(ctx, projectID, t.ID, entity.TaskActionRollback, oldStatus, entity.TaskStatusPending, operatorID, map[string]interface{}{
 					"triggered_by_task": taskID,
 					"cascade":           true,
@@ -456,7 +519,8 @@ func (s *WorkflowService) GetTaskHistory(ctx context.Context, projectID, taskID
 	return logs, nil
 }
 
-// checkDependenciesCompleted 检查任务的所有前置依赖是否已完成
+// checkDependenciesCompleted 按依赖类型检查任务能否启动
+// FS 要求前置已完成，SS 要求前置已开始，FF/SF 只约束完成
 func (s *WorkflowService) checkDependenciesCompleted(ctx context.Context, taskID string) error {
 	var deps []entity.TaskDependency
 	if err := s.db.WithContext(ctx).Where("task_id = ?", taskID).Find(&deps).Error; err != nil {
@@ -468,17 +532,21 @@ func (s *WorkflowService) checkDependenciesCompleted(ctx context.Context, taskID
 		if err := s.db.WithContext(ctx).Where("id = ?", dep.DependsOnID).First(&depTask).Error; err != nil {
 			return fmt.Errorf("查找依赖任务失败 (id=%s): %w", dep.DependsOnID, err)
 		}
-		if depTask.Status != entity.TaskStatusCompleted {
-			return fmt.Errorf("前置任务[%s]尚未完成（当前状态: %s），无法启动", depTask.Title, depTask.Status)
+		if !dependencyAllowsStart(dep.DependencyType, depTask.Status) {
+			return fmt.Errorf("前置任务[%s]尚未%s（当前状态: %s），无法启动", depTask.Title, dependencyRequirement(dep.DependencyType), depTask.Status)
 		}
 	}
 
 	return nil
 }
 
-// checkAndStartDependentTasks 检查并启动依赖当前任务的后续任务
+// checkAndStartDependentTasks 前置任务开始或完成后，检查并启动依赖它的后续任务
+// 自动启动的任务按手动开始同样处理，并可能满足其 SS 后续的条件，因此逐级向下检查
 func (s *WorkflowService) checkAndStartDependentTasks(ctx context.Context, projectID, completedTaskID string) {
-	// 查找依赖于已完成任务的任务
+	if s == nil {
+		return
+	}
+	// 查找依赖于该任务的任务
 	var deps []entity.TaskDependency
 	if err := s.db.WithContext(ctx).Where("depends_on_task_id = ?", completedTaskID).Find(&deps).Error; err != nil {
 		log.Printf("[WorkflowService] 查找依赖任务失败: %v", err)
@@ -498,44 +566,41 @@ func (s *WorkflowService) checkAndStartDependentTasks(ctx context.Context, proje
 			continue
 		}
 
-		// 检查该任务的所有依赖是否都已完成
-		allCompleted := true
-		var allDeps []entity.TaskDependency
-		if err := s.db.WithContext(ctx).Where("task_id = ?", task.ID).Find(&allDeps).Error; err != nil {
-			log.Printf("[WorkflowService] 查找任务所有依赖失败: %v", err)
+		// 检查该任务的所有依赖是否都满足启动条件
+		if err := s.checkDependenciesCompleted(ctx, task.ID); err != nil {
 			continue
 		}
 
-		for _, d := range allDeps {
-			var depTask entity.Task
-			if err := s.db.WithContext(ctx).Where("id = ?", d.DependsOnID).First(&depTask).Error; err != nil {
-				allCompleted = false
-				break
-			}
-			if depTask.Status != entity.TaskStatusCompleted {
-				allCompleted = false
-				break
-			}
+		// 自动启动任务（条件更新，避免并发重复启动）
+		now := time.Now()
+		result := s.db.WithContext(ctx).Model(&entity.Task{}).
+			Where("id = ? AND status = ?", task.ID, entity.TaskStatusPending).
+			Updates(map[string]interface{}{"status": entity.TaskStatusInProgress, "actual_start": now, "updated_at": now})
+		if result.Error != nil {
+			log.Printf("[WorkflowService] 自动启动任务失败 (task=%s): %v", task.ID, result.Error)
+			continue
 		}
+		if result.RowsAffected == 0 {
+			continue
+		}
+		task.Status = entity.TaskStatusInProgress
+		task.ActualStart = &now
+		s.taskStates.Sync(ctx, task.ID, entity.TaskStatusPending, entity.TaskStatusInProgress, "system")
+		s.logAction(ctx, projectID, task.ID, entity.TaskActionStart, entity.TaskStatusPending, entity.TaskStatusInProgress, "system", map[string]interface{}{
+			"auto_started":       true,
+			"completed_dep_task": completedTaskID,
+			"dependency_type":    dep.DependencyType,
+		}, "依赖任务满足条件，自动启动")
+		log.Printf("[WorkflowService] 自动启动任务 task=%s (依赖任务 %s, %s)", task.ID, completedTaskID, dep.DependencyType)
 
-		if allCompleted {
-			// 自动启动任务
-			now := time.Now()
-			task.Status = entity.TaskStatusInProgress
-			task.ActualStart = &now
-			if err := s.db.WithContext(ctx).Save(&task).Error; err != nil {
-				log.Printf("[WorkflowService] 自动启动任务失败 (task=%s): %v", task.ID, err)
-				continue
-			}
-			s.logAction(ctx, projectID, task.ID, entity.TaskActionStart, entity.TaskStatusPending, entity.TaskStatusInProgress, "system", map[string]interface{}{
-				"auto_started":       true,
-				"completed_dep_task": completedTaskID,
-			}, "依赖任务完成，自动启动")
-			log.Printf("[WorkflowService] 自动启动任务 task=%s (依赖任务 %s 完成)", task.ID, completedTaskID)
-
-			// Hook: 自动启动时也触发采购控件
-			go s.handleProcurementControl(context.Background(), &task, "system")
+		// SSE: 通知前端任务被激活
+		sse.PublishTaskUpdate(projectID, task.ID, "task_activated")
+		if task.AssigneeID != nil && *task.AssigneeID != "" {
+			sse.PublishUserTaskUpdate(*task.AssigneeID, projectID, task.ID, "task_activated")
 		}
+
+		// 与手动开始相同的后续处理（含逐级检查 SS 后续）
+		s.afterTaskStarted(ctx, projectID, &task, "system")
 	}
 }
 
@@ -805,28 +870,40 @@ func (s *WorkflowService) saveProcurementResult(ctx context.Context, task *entit
 	s.saveProcurementSubmission(ctx, task, formDef, fieldKey, resultData)
 }
 
-// saveProcurementSubmission 保存采购控件数据到表单提交
+// saveProcurementSubmission 保存采购控件数据到任务的系统记录（version=0）
+// 用户提交的各版本保持原样，不写入系统数据；读取提交时再叠加系统记录
 func (s *WorkflowService) saveProcurementSubmission(ctx context.Context, task *entity.Task, formDef *entity.TaskForm, fieldKey string, data map[string]interface{}) {
-	// 先查已有提交
-	existing, _ := s.taskFormRepo.FindLatestSubmission(ctx, task.ID)
+	existing, err := s.taskFormRepo.FindSystemSubmission(ctx, task.ID)
+	if err != nil {
+		log.Printf("[WorkflowService] 查询采购控件数据失败 (task=%s): %v", task.ID, err)
+		return
+	}
 	if existing != nil {
-		// 合并到已有提交的data中
 		existingData := map[string]interface{}(existing.Data)
+		if existingData == nil {
+			existingData = map[string]interface{}{}
+		}
 		existingData[fieldKey] = data
 		existing.Data = entity.JSONB(existingData)
-		s.db.WithContext(ctx).Save(existing)
-	} else {
-		// 创建新提交
-		submission := &entity.TaskFormSubmission{
-			ID:          uuid.New().String()[:32],
-			FormID:      formDef.ID,
-			TaskID:      task.ID,
-			Data:        entity.JSONB{fieldKey: data},
-			SubmittedBy: "system",
-			SubmittedAt: time.Now(),
-			Version:     0,
-		}
-		s.taskFormRepo.CreateSubmission(ctx, submission)
+		existing.SubmittedAt = time.Now()
+		if err := s.db.WithContext(ctx).Save(existing).Error; err != nil {
+			log.Printf("[WorkflowService] 保存采购控件数据失败 (task=%s): %v", task.ID, err)
+		}
+		return
+	}
+
+	submission := &entity.TaskFormSubmission{
+		ID:          uuid.New().String()[:32],
+		FormID:      formDef.ID,
+		TaskID:      task.ID,
+		Data:        entity.JSONB{fieldKey: data},
+		Files:       json.RawMessage("[]"),
+		SubmittedBy: "system",
+		SubmittedAt: time.Now(),
+		Version:     0,
+	}
+	if err := s.taskFormRepo.CreateSubmission(ctx, submission); err != nil {
+		log.Printf("[WorkflowService] 保存采购控件数据失败 (task=%s): %v", task.ID, err)
 	}
 }
 
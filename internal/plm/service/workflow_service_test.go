@@ -0,0 +1,52 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/bitfantasy/nimo/internal/plm/entity"
+	"github.com/bitfantasy/nimo/internal/plm/repository"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSaveProcurementSubmissionKeepsUserVersions(t *testing.T) {
+	db := setupServiceTestDB(t, &entity.TaskFormSubmission{})
+	formRepo := repository.NewTaskFormRepository(db)
+	svc := &WorkflowService{db: db, taskFormRepo: formRepo}
+	ctx := context.Background()
+	task := &entity.Task{ID: "t1"}
+	form := &entity.TaskForm{ID: "f1"}
+
+	require.NoError(t, formRepo.CreateSubmission(ctx, &entity.TaskFormSubmission{
+		ID: "s1", FormID: "f1", TaskID: "t1", Data: entity.JSONB{"remark": "用户填写"}, Files: json.RawMessage("[]"),
+		SubmittedBy: "u1", SubmittedAt: time.Now(), Version: 1,
+	}))
+
+	svc.saveProcurementSubmission(ctx, task, form, "purchase", map[string]interface{}{"pr_code": "PR-001"})
+	svc.saveProcurementSubmission(ctx, task, form, "tooling", map[string]interface{}{"error": "无可采购物料"})
+
+	// 用户提交的版本不被改写
+	user, err := formRepo.FindSubmissionByVersion(ctx, "t1", 1)
+	require.NoError(t, err)
+	assert.Equal(t, entity.JSONB{"remark": "用户填写"}, user.Data)
+
+	// 系统数据合并在同一条 version=0 记录中
+	system, err := formRepo.FindSystemSubmission(ctx, "t1")
+	require.NoError(t, err)
+	require.NotNil(t, system)
+	assert.Equal(t, "system", system.SubmittedBy)
+	assert.Contains(t, system.Data, "purchase")
+	assert.Contains(t, system.Data, "tooling")
+
+	var count int64
+	require.NoError(t, db.Model(&entity.TaskFormSubmission{}).Where("task_id = ?", "t1").Count(&count).Error)
+	assert.Equal(t, int64(2), count)
+
+	submissions, err := formRepo.ListSubmissions(ctx, "t1")
+	require.NoError(t, err)
+	require.Len(t, submissions, 1)
+	assert.Equal(t, 1, submissions[0].Version)
+}
@@ -0,0 +1,645 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/bitfantasy/nimo/internal/plm/entity"
+	"github.com/bitfantasy/nimo/internal/plm/repository"
+	"github.com/bitfantasy/nimo/internal/shared/schedule"
+	"gorm.io/gorm"
+)
+
+// =============================================================================
+// 跨项目资源负荷
+//
+// 统计所有进行中项目里未完成、已指派且有预估工时的任务：
+//   - 工时按任务计划起止区间内负责人的工作日（项目日历叠加个人请假）平均分摊
+//   - 按周汇总到负责人和任务角色（模板任务的 default_assignee_role），与周产能比较
+//   - 周产能按默认日历叠加个人请假折算，节假日/请假所在周产能相应减少
+//   - 超负荷周给出削峰建议：把有自由时差（不影响后续任务）的未开始任务向后挪
+// =============================================================================
+
+const (
+	// WorkloadCapacityConfigKey 周产能配置项（system_configs，单位小时）
+	WorkloadCapacityConfigKey = "workload.weekly_capacity_hours"
+	// DefaultWeeklyCapacity 未配置时的周产能
+	DefaultWeeklyCapacity = 40.0
+	// standardWorkdaysPerWeek 周产能折算日产能的标准工作日数
+	standardWorkdaysPerWeek = 5
+	// maxWorkloadWeeks 单次查询最多统计的周数
+	maxWorkloadWeeks = 26
+)
+
+// WorkloadService 资源负荷服务
+type WorkloadService struct {
+	db          *gorm.DB
+	configRepo  *repository.SystemConfigRepository
+	calendars   *CalendarService
+	scheduleSvc *ScheduleService
+}
+
+// NewWorkloadService 创建资源负荷服务
+func NewWorkloadService(db *gorm.DB, configRepo *repository.SystemConfigRepository) *WorkloadService {
+	return &WorkloadService{db: db, configRepo: configRepo}
+}
+
+// SetCalendarService 注入工作日历服务（未注入时按周一至周五）
+func (s *WorkloadService) SetCalendarService(svc *CalendarService) {
+	s.calendars = svc
+}
+
+// SetScheduleService 注入进度计划服务（削峰建议依赖任务自由时差，未注入时不给建议）
+func (s *WorkloadService) SetScheduleService(svc *ScheduleService) {
+	s.scheduleSvc = svc
+}
+
+// WorkloadQuery 负荷查询条件
+type WorkloadQuery struct {
+	From           time.Time
+	To             time.Time
+	UserID         string  // 只看某人
+	RoleCode       string  // 只看在进行中任务上担任该角色的人
+	WeeklyCapacity float64 // 覆盖配置的周产能，<=0 时使用配置
+}
+
+// WorkloadBucket 一周的负荷
+type WorkloadBucket struct {
+	WeekStart     time.Time `json:"week_start"`
+	Hours         float64   `json:"hours"`
+	Capacity      float64   `json:"capacity"`
+	Utilization   float64   `json:"utilization"` // 负荷率，1 表示 100%
+	OverAllocated bool      `json:"over_allocated"`
+}
+
+// WorkloadTask 计入负荷的任务
+type WorkloadTask struct {
+	TaskID         string    `json:"task_id"`
+	Code           string    `json:"code"`
+	Title          string    `json:"title"`
+	Status         string    `json:"status"`
+	ProjectID      string    `json:"project_id"`
+	ProjectName    string    `json:"project_name"`
+	RoleCode       string    `json:"role_code"`
+	StartDate      time.Time `json:"start_date"`
+	DueDate        time.Time `json:"due_date"`
+	EstimatedHours float64   `json:"estimated_hours"`
+	HoursInRange   float64   `json:"hours_in_range"` // 落在查询区间内的工时
+}
+
+// UserWorkload 个人负荷
+type UserWorkload struct {
+	UserID        string           `json:"user_id"`
+	UserName      string           `json:"user_name"`
+	Roles         []string         `json:"roles"`
+	TotalHours    float64          `json:"total_hours"`
+	TotalCapacity float64          `json:"total_capacity"`
+	Utilization   float64          `json:"utilization"`
+	PeakWeek      *time.Time       `json:"peak_week"`
+	PeakUtil      float64          `json:"peak_utilization"`
+	OverAllocated bool             `json:"over_allocated"`
+	Weeks         []WorkloadBucket `json:"weeks"`
+	Projects      []ProjectLoad    `json:"projects"`
+	Tasks         []WorkloadTask   `json:"tasks"`
+}
+
+// ProjectLoad 个人在某项目上的工时
+type ProjectLoad struct {
+	ProjectID   string  `json:"project_id"`
+	ProjectName string  `json:"project_name"`
+	Hours       float64 `json:"hours"`
+}
+
+// RoleWorkload 任务角色负荷（容量为该角色下各人的周产能之和）
+type RoleWorkload struct {
+	RoleCode      string           `json:"role_code"`
+	RoleName      string           `json:"role_name"`
+	Headcount     int              `json:"headcount"`
+	TotalHours    float64          `json:"total_hours"`
+	TotalCapacity float64          `json:"total_capacity"`
+	Utilization   float64          `json:"utilization"`
+	Weeks         []WorkloadBucket `json:"weeks"`
+}
+
+// OverAllocation 超负荷记录
+type OverAllocation struct {
+	UserID      string    `json:"user_id"`
+	UserName    string    `json:"user_name"`
+	WeekStart   time.Time `json:"week_start"`
+	Hours       float64   `json:"hours"`
+	Capacity    float64   `json:"capacity"`
+	Utilization float64   `json:"utilization"`
+	Resolved    bool      `json:"resolved"` // 按削峰建议调整后是否降到产能以内
+}
+
+// LevelingSuggestion 削峰建议：在自由时差内后移任务
+type LevelingSuggestion struct {
+	TaskID         string    `json:"task_id"`
+	Code           string    `json:"code"`
+	Title          string    `json:"title"`
+	ProjectID      string    `json:"project_id"`
+	ProjectName    string    `json:"project_name"`
+	UserID         string    `json:"user_id"`
+	UserName       string    `json:"user_name"`
+	WeekStart      time.Time `json:"week_start"` // 要缓解的超负荷周
+	FreeFloat      int       `json:"free_float"` // 可用自由时差（工作日）
+	ShiftDays      int       `json:"shift_days"` // 建议后移工作日数
+	CurrentStart   time.Time `json:"current_start"`
+	CurrentEnd     time.Time `json:"current_end"`
+	SuggestedStart time.Time `json:"suggested_start"`
+	SuggestedEnd   time.Time `json:"suggested_end"`
+	RelievedHours  float64   `json:"relieved_hours"` // 该周减少的工时
+}
+
+// WorkloadReport 负荷报告
+type WorkloadReport struct {
+	From            time.Time            `json:"from"`
+	To              time.Time            `json:"to"`
+	WeeklyCapacity  float64              `json:"weekly_capacity"`
+	Weeks           []time.Time          `json:"weeks"`
+	Users           []UserWorkload       `json:"users"`
+	Roles           []RoleWorkload       `json:"roles"`
+	OverAllocations []OverAllocation     `json:"over_allocations"`
+	Suggestions     []LevelingSuggestion `json:"suggestions"`
+}
+
+// workloadItem 计入负荷的任务及其分摊
+type workloadItem struct {
+	task    entity.Task
+	projCal schedule.Calendar // 项目日历，用于挪动日期
+	userCal schedule.Calendar // 项目日历叠加负责人请假，用于分摊工时
+	start   time.Time
+	end     time.Time
+	spread  map[time.Time]float64
+}
+
+// userLoad 个人负荷计算中间态
+type userLoad struct {
+	id       string
+	name     string
+	items    []*workloadItem
+	daily    map[time.Time]float64 // 日期 → 工时
+	capacity map[time.Time]float64 // 周一 → 周产能
+}
+
+// GetWorkload 计算跨项目资源负荷
+func (s *WorkloadService) GetWorkload(ctx context.Context, q WorkloadQuery) (*WorkloadReport, error) {
+	from, to := schedule.WeekStart(q.From), schedule.Day(q.To)
+	if to.Before(from) {
+		return nil, fmt.Errorf("日期区间无效")
+	}
+	if to.Sub(from) > maxWorkloadWeeks*7*24*time.Hour {
+		return nil, fmt.Errorf("查询区间最长 %d 周", maxWorkloadWeeks)
+	}
+	weekly := q.WeeklyCapacity
+	if weekly <= 0 {
+		weekly = s.weeklyCapacity(ctx)
+	}
+
+	tasks, err := s.loadTasks(ctx, from, to, q)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &WorkloadReport{
+		From:            from,
+		To:              to,
+		WeeklyCapacity:  weekly,
+		Users:           []UserWorkload{},
+		Roles:           []RoleWorkload{},
+		OverAllocations: []OverAllocation{},
+		Suggestions:     []LevelingSuggestion{},
+	}
+	for w := from; !w.After(to); w = w.AddDate(0, 0, 7) {
+		report.Weeks = append(report.Weeks, w)
+	}
+
+	defaultCal, err := s.calendars.Calendar(ctx, "")
+	if err != nil {
+		return nil, err
+	}
+	projCals := make(map[string]schedule.Calendar)
+	userCals := make(map[string]schedule.Calendar) // projectID/userID → 日历
+	users := make(map[string]*userLoad)
+	var order []string
+	for _, t := range tasks {
+		userID := *t.AssigneeID
+		u, ok := users[userID]
+		if !ok {
+			u = &userLoad{id: userID, daily: make(map[time.Time]float64), capacity: make(map[time.Time]float64)}
+			if t.Assignee != nil {
+				u.name = t.Assignee.Name
+			}
+			capCal := s.calendars.UserCalendar(ctx, defaultCal, userID)
+			for _, w := range report.Weeks {
+				u.capacity[w] = weekly / standardWorkdaysPerWeek * float64(schedule.WorkdaysBetween(capCal, maxTime(w, from), minTime(w.AddDate(0, 0, 6), to)))
+			}
+			users[userID] = u
+			order = append(order, userID)
+		}
+
+		projCal, ok := projCals[t.ProjectID]
+		if !ok {
+			projCal = s.calendars.ProjectCalendar(ctx, t.ProjectID)
+			projCals[t.ProjectID] = projCal
+		}
+		key := t.ProjectID + "/" + userID
+		userCal, ok := userCals[key]
+		if !ok {
+			userCal = s.calendars.UserCalendar(ctx, projCal, userID)
+			userCals[key] = userCal
+		}
+
+		item := &workloadItem{task: t, projCal: projCal, userCal: userCal}
+		item.start = schedule.Day(*firstDate(t.StartDate, t.DueDate))
+		item.end = schedule.Day(*firstDate(t.DueDate, t.StartDate))
+		item.spread = schedule.SpreadHours(userCal, item.start, item.end, t.EstimatedHours)
+		for d, h := range item.spread {
+			u.daily[d] += h
+		}
+		u.items = append(u.items, item)
+	}
+	sort.SliceStable(order, func(i, j int) bool { return users[order[i]].name < users[order[j]].name })
+
+	// 先按原计划统计，再在副本上模拟削峰
+	for _, id := range order {
+		u := users[id]
+		report.Users = append(report.Users, s.userWorkload(u, report.Weeks, from, to))
+		for _, b := range u.buckets(report.Weeks, from, to) {
+			if b.OverAllocated {
+				report.OverAllocations = append(report.OverAllocations, OverAllocation{
+					UserID: u.id, UserName: u.name, WeekStart: b.WeekStart,
+					Hours: b.Hours, Capacity: b.Capacity, Utilization: b.Utilization,
+				})
+			}
+		}
+	}
+	report.Roles = s.roleWorkload(ctx, users, order, report.Weeks, from, to)
+
+	if len(report.OverAllocations) > 0 && s.scheduleSvc != nil {
+		report.Suggestions = s.level(ctx, users, report.OverAllocations, report.Weeks, from, to)
+		for i := range report.OverAllocations {
+			o := &report.OverAllocations[i]
+			o.Resolved = users[o.UserID].weekHours(o.WeekStart, from, to) <= o.Capacity+0.01
+		}
+	}
+	return report, nil
+}
+
+// weeklyCapacity 读取配置的周产能
+func (s *WorkloadService) weeklyCapacity(ctx context.Context) float64 {
+	if s.configRepo == nil {
+		return DefaultWeeklyCapacity
+	}
+	cfg, err := s.configRepo.FindByKey(ctx, WorkloadCapacityConfigKey)
+	if err != nil {
+		return DefaultWeeklyCapacity
+	}
+	v, err := strconv.ParseFloat(cfg.Value, 64)
+	if err != nil || v <= 0 {
+		return DefaultWeeklyCapacity
+	}
+	return v
+}
+
+// loadTasks 查询区间内计入负荷的任务
+func (s *WorkloadService) loadTasks(ctx context.Context, from, to time.Time, q WorkloadQuery) ([]entity.Task, error) {
+	query := s.db.WithContext(ctx).
+		Joins("JOIN projects ON projects.id = tasks.project_id").
+		Where("projects.deleted_at IS NULL AND projects.status NOT IN ?",
+			[]string{entity.ProjectStatusCompleted, entity.ProjectStatusCancelled}).
+		Where("tasks.status NOT IN ?", []string{entity.TaskStatusCompleted, entity.TaskStatusCancelled}).
+		Where("tasks.assignee_id IS NOT NULL AND tasks.assignee_id <> ''").
+		Where("tasks.estimated_hours > 0").
+		Where("COALESCE(tasks.planned_start, tasks.planned_end) <= ? AND COALESCE(tasks.planned_end, tasks.planned_start) >= ?", to, from)
+	if q.UserID != "" {
+		query = query.Where("tasks.assignee_id = ?", q.UserID)
+	}
+	if q.RoleCode != "" {
+		query = query.Where(`tasks.assignee_id IN (SELECT t2.assignee_id FROM tasks t2 JOIN projects p2 ON p2.id = t2.project_id
+			WHERE t2.default_assignee_role = ? AND t2.status NOT IN ? AND p2.deleted_at IS NULL AND p2.status NOT IN ?)`,
+			q.RoleCode,
+			[]string{entity.TaskStatusCompleted, entity.TaskStatusCancelled},
+			[]string{entity.ProjectStatusCompleted, entity.ProjectStatusCancelled})
+	}
+
+	var tasks []entity.Task
+	if err := query.Preload("Project").Preload("Assignee").
+		Order("tasks.planned_start, tasks.sequence").Find(&tasks).Error; err != nil {
+		return nil, fmt.Errorf("查询任务失败: %w", err)
+	}
+	return tasks, nil
+}
+
+// weekHours 某周（限查询区间内）的工时
+func (u *userLoad) weekHours(week, from, to time.Time) float64 {
+	h := 0.0
+	for d := maxTime(week, from); !d.After(minTime(week.AddDate(0, 0, 6), to)); d = d.AddDate(0, 0, 1) {
+		h += u.daily[d]
+	}
+	return h
+}
+
+// buckets 按周汇总
+func (u *userLoad) buckets(weeks []time.Time, from, to time.Time) []WorkloadBucket {
+	buckets := make([]WorkloadBucket, 0, len(weeks))
+	for _, w := range weeks {
+		buckets = append(buckets, newWorkloadBucket(w, u.weekHours(w, from, to), u.capacity[w]))
+	}
+	return buckets
+}
+
+func newWorkloadBucket(week time.Time, hours, capacity float64) WorkloadBucket {
+	b := WorkloadBucket{WeekStart: week, Hours: roundHours(hours), Capacity: roundHours(capacity)}
+	b.Utilization = utilization(hours, capacity)
+	b.OverAllocated = hours > capacity+0.01
+	return b
+}
+
+// userWorkload 个人负荷明细
+func (s *WorkloadService) userWorkload(u *userLoad, weeks []time.Time, from, to time.Time) UserWorkload {
+	uw := UserWorkload{UserID: u.id, UserName: u.name, Roles: []string{}, Weeks: u.buckets(weeks, from, to)}
+	for _, b := range uw.Weeks {
+		uw.TotalHours += b.Hours
+		uw.TotalCapacity += b.Capacity
+		if b.OverAllocated {
+			uw.OverAllocated = true
+		}
+		if uw.PeakWeek == nil || b.Utilization > uw.PeakUtil {
+			week := b.WeekStart
+			uw.PeakWeek, uw.PeakUtil = &week, b.Utilization
+		}
+	}
+	uw.TotalHours = roundHours(uw.TotalHours)
+	uw.Utilization = utilization(uw.TotalHours, uw.TotalCapacity)
+
+	roles := make(map[string]bool)
+	projects := make(map[string]int)
+	for _, item := range u.items {
+		t := item.task
+		inRange := 0.0
+		for d, h := range item.spread {
+			if !d.Before(from) && !d.After(to) {
+				inRange += h
+			}
+		}
+		wt := WorkloadTask{
+			TaskID: t.ID, Code: t.Code, Title: t.Title, Status: t.Status,
+			ProjectID: t.ProjectID, RoleCode: t.DefaultAssigneeRole,
+			StartDate: item.start, DueDate: item.end,
+			EstimatedHours: t.EstimatedHours, HoursInRange: roundHours(inRange),
+		}
+		if t.Project != nil {
+			wt.ProjectName = t.Project.Name
+		}
+		uw.Tasks = append(uw.Tasks, wt)
+
+		if t.DefaultAssigneeRole != "" && !roles[t.DefaultAssigneeRole] {
+			roles[t.DefaultAssigneeRole] = true
+			uw.Roles = append(uw.Roles, t.DefaultAssigneeRole)
+		}
+		idx, ok := projects[t.ProjectID]
+		if !ok {
+			idx = len(uw.Projects)
+			projects[t.ProjectID] = idx
+			uw.Projects = append(uw.Projects, ProjectLoad{ProjectID: t.ProjectID, ProjectName: wt.ProjectName})
+		}
+		uw.Projects[idx].Hours = roundHours(uw.Projects[idx].Hours + inRange)
+	}
+	return uw
+}
+
+// roleWorkload 按任务角色汇总；同一人担任多个角色时其产能计入每个角色
+func (s *WorkloadService) roleWorkload(ctx context.Context, users map[string]*userLoad, order []string, weeks []time.Time, from, to time.Time) []RoleWorkload {
+	names := make(map[string]string)
+	var taskRoles []entity.TaskRole
+	if err := s.db.WithContext(ctx).Find(&taskRoles).Error; err == nil {
+		for _, r := range taskRoles {
+			names[r.Code] = r.Name
+		}
+	}
+
+	type roleAgg struct {
+		hours    map[time.Time]float64
+		capacity map[time.Time]float64
+		members  map[string]bool
+	}
+	aggs := make(map[string]*roleAgg)
+	var codes []string
+	for _, id := range order {
+		u := users[id]
+		for _, item := range u.items {
+			code := item.task.DefaultAssigneeRole
+			agg, ok := aggs[code]
+			if !ok {
+				agg = &roleAgg{hours: make(map[time.Time]float64), capacity: make(map[time.Time]float64), members: make(map[string]bool)}
+				aggs[code] = agg
+				codes = append(codes, code)
+			}
+			if !agg.members[u.id] {
+				agg.members[u.id] = true
+				for w, c := range u.capacity {
+					agg.capacity[w] += c
+				}
+			}
+			for d, h := range item.spread {
+				if !d.Before(from) && !d.After(to) {
+					agg.hours[schedule.WeekStart(d)] += h
+				}
+			}
+		}
+	}
+	sort.Strings(codes)
+
+	roles := make([]RoleWorkload, 0, len(codes))
+	for _, code := range codes {
+		agg := aggs[code]
+		rw := RoleWorkload{RoleCode: code, RoleName: names[code], Headcount: len(agg.members)}
+		if code == "" {
+			rw.RoleName = "未指定角色"
+		} else if rw.RoleName == "" {
+			rw.RoleName = code
+		}
+		for _, w := range weeks {
+			b := newWorkloadBucket(w, agg.hours[w], agg.capacity[w])
+			rw.Weeks = append(rw.Weeks, b)
+			rw.TotalHours += agg.hours[w]
+			rw.TotalCapacity += agg.capacity[w]
+		}
+		rw.Utilization = utilization(rw.TotalHours, rw.TotalCapacity)
+		rw.TotalHours = roundHours(rw.TotalHours)
+		rw.TotalCapacity = roundHours(rw.TotalCapacity)
+		roles = append(roles, rw)
+	}
+	return roles
+}
+
+// level 削峰：对每个超负荷周，挑负责人在该周有工时、尚未开始且有自由时差的任务，
+// 在时差内试探后移，取能消除超负荷的最小后移量（否则取缓解最多的），且不得造成其他周超负荷。
+// 自由时差内后移不影响后续任务，因此各建议可同时采纳
+func (s *WorkloadService) level(ctx context.Context, users map[string]*userLoad, overs []OverAllocation, weeks []time.Time, from, to time.Time) []LevelingSuggestion {
+	floats := make(map[string]int) // taskID → 剩余可用自由时差
+	loaded := make(map[string]bool)
+	loadFloats := func(projectID string) {
+		if loaded[projectID] {
+			return
+		}
+		loaded[projectID] = true
+		sched, err := s.scheduleSvc.GetSchedule(ctx, projectID)
+		if err != nil {
+			return
+		}
+		cal := s.calendars.ProjectCalendar(ctx, projectID)
+		for _, st := range sched.Tasks {
+			ff := st.FreeFloat
+			// 当前计划晚于最早开始时，已占用了部分时差
+			if st.PlannedStart != nil {
+				if used := schedule.WorkdayDiff(cal, st.EarlyStart, *st.PlannedStart); used > 0 {
+					ff -= used
+				}
+			}
+			if ff > 0 {
+				floats[st.TaskID] = ff
+			}
+		}
+	}
+
+	suggestions := []LevelingSuggestion{}
+	for _, o := range overs {
+		u := users[o.UserID]
+		capacity := u.capacity[o.WeekStart]
+		weekEnd := o.WeekStart.AddDate(0, 0, 6)
+
+		var candidates []*workloadItem
+		for _, item := range u.items {
+			if item.task.Status != entity.TaskStatusPending {
+				continue
+			}
+			loadFloats(item.task.ProjectID)
+			if floats[item.task.ID] > 0 && hoursBetween(item.spread, o.WeekStart, weekEnd) > 0 {
+				candidates = append(candidates, item)
+			}
+		}
+		sort.SliceStable(candidates, func(i, j int) bool {
+			return floats[candidates[i].task.ID] > floats[candidates[j].task.ID]
+		})
+
+		for _, item := range candidates {
+			before := u.weekHours(o.WeekStart, from, to)
+			if before <= capacity+0.01 {
+				break
+			}
+			shift, spread := s.bestShift(u, item, floats[item.task.ID], o.WeekStart, capacity, weeks, from, to)
+			if shift == 0 {
+				continue
+			}
+			newStart := schedule.AddWorkdays(item.projCal, item.start, shift)
+			newEnd := schedule.AddWorkdays(item.projCal, item.end, shift)
+			u.apply(item, spread)
+
+			sg := LevelingSuggestion{
+				TaskID: item.task.ID, Code: item.task.Code, Title: item.task.Title,
+				ProjectID: item.task.ProjectID, UserID: u.id, UserName: u.name,
+				WeekStart: o.WeekStart, FreeFloat: floats[item.task.ID], ShiftDays: shift,
+				CurrentStart: item.start, CurrentEnd: item.end,
+				SuggestedStart: newStart, SuggestedEnd: newEnd,
+				RelievedHours: roundHours(before - u.weekHours(o.WeekStart, from, to)),
+			}
+			if item.task.Project != nil {
+				sg.ProjectName = item.task.Project.Name
+			}
+			suggestions = append(suggestions, sg)
+
+			item.start, item.end = newStart, newEnd
+			floats[item.task.ID] -= shift
+		}
+	}
+	return suggestions
+}
+
+// bestShift 在 [1, maxShift] 内选择后移量，返回 0 表示没有可行的后移
+func (s *WorkloadService) bestShift(u *userLoad, item *workloadItem, maxShift int, week time.Time, capacity float64, weeks []time.Time, from, to time.Time) (int, map[time.Time]float64) {
+	base := u.weekHours(week, from, to)
+	bestShift, bestHours := 0, base
+	var bestSpread map[time.Time]float64
+	for shift := 1; shift <= maxShift; shift++ {
+		start := schedule.AddWorkdays(item.projCal, item.start, shift)
+		end := schedule.AddWorkdays(item.projCal, item.end, shift)
+		spread := schedule.SpreadHours(item.userCal, start, end, item.task.EstimatedHours)
+
+		old := item.spread
+		u.apply(item, spread)
+		hours := u.weekHours(week, from, to)
+		feasible := true
+		for _, w := range weeks {
+			if w.Equal(week) || hoursBetween(spread, w, w.AddDate(0, 0, 6)) == 0 {
+				continue
+			}
+			if u.weekHours(w, from, to) > u.capacity[w]+0.01 && hoursBetween(old, w, w.AddDate(0, 0, 6)) < hoursBetween(spread, w, w.AddDate(0, 0, 6)) {
+				feasible = false
+				break
+			}
+		}
+		u.apply(item, old)
+
+		if !feasible || hours >= bestHours-0.01 {
+			continue
+		}
+		bestShift, bestHours, bestSpread = shift, hours, spread
+		if hours <= capacity+0.01 {
+			break
+		}
+	}
+	return bestShift, bestSpread
+}
+
+// apply 用新的分摊替换任务原分摊
+func (u *userLoad) apply(item *workloadItem, spread map[time.Time]float64) {
+	for d, h := range item.spread {
+		u.daily[d] -= h
+	}
+	for d, h := range spread {
+		u.daily[d] += h
+	}
+	item.spread = spread
+}
+
+func hoursBetween(spread map[time.Time]float64, from, to time.Time) float64 {
+	h := 0.0
+	for d, v := range spread {
+		if !d.Before(from) && !d.After(to) {
+			h += v
+		}
+	}
+	return h
+}
+
+// utilization 负荷率；产能为 0（整周休假）时记为 0，是否超负荷以 over_allocated 为准
+func utilization(hours, capacity float64) float64 {
+	if capacity <= 0 {
+		return 0
+	}
+	return math.Round(hours/capacity*1000) / 1000
+}
+
+func roundHours(h float64) float64 {
+	return math.Round(h*100) / 100
+}
+
+func maxTime(a, b time.Time) time.Time {
+	if a.After(b) {
+		return a
+	}
+	return b
+}
+
+func minTime(a, b time.Time) time.Time {
+	if a.Before(b) {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,168 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/bitfantasy/nimo/internal/plm/entity"
+	"github.com/bitfantasy/nimo/internal/shared/schedule"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+// workloadTask 测试任务；assignee 为空时不计入负荷，只参与排程
+func workloadTask(id, status, assignee string, start, end time.Time, hours float64) entity.Task {
+	t := entity.Task{
+		ID: id, ProjectID: "p1", Code: id, Title: id, Status: status, CreatedBy: "u1",
+		StartDate: &start, DueDate: &end, EstimatedHours: hours,
+	}
+	if assignee != "" {
+		t.AssigneeID = &assignee
+	}
+	return t
+}
+
+func setupWorkloadTest(t *testing.T, tasks []entity.Task, deps []entity.TaskDependency) *gorm.DB {
+	t.Helper()
+	db := setupServiceTestDB(t, &entity.User{}, &entity.Project{}, &entity.Task{}, &entity.TaskDependency{})
+	require.NoError(t, db.Create(&entity.User{ID: "u1", Username: "zhangsan", Name: "张三", Email: "u1@example.com", FeishuUserID: "fu1"}).Error)
+	require.NoError(t, db.Create(&entity.Project{ID: "p1", Code: "PRJ-001", Name: "负荷测试", Status: "active", ManagerID: "u1", CreatedBy: "u1"}).Error)
+	require.NoError(t, db.Create(tasks).Error)
+	if len(deps) > 0 {
+		require.NoError(t, db.Create(deps).Error)
+	}
+	return db
+}
+
+func TestWorkloadOverAllocation(t *testing.T) {
+	// 排程以今天为状态日，测试周取两周后的周一
+	week := schedule.WeekStart(time.Now()).AddDate(0, 0, 14)
+	fri := week.AddDate(0, 0, 4)
+	db := setupWorkloadTest(t, []entity.Task{
+		workloadTask("A", entity.TaskStatusInProgress, "u1", week, fri, 30),
+		workloadTask("B", entity.TaskStatusInProgress, "u1", week, fri, 20),
+		workloadTask("C", entity.TaskStatusPending, "u1", week.AddDate(0, 0, 7), week.AddDate(0, 0, 8), 8),
+	}, nil)
+
+	svc := NewWorkloadService(db, nil)
+	report, err := svc.GetWorkload(context.Background(), WorkloadQuery{From: week, To: week.AddDate(0, 0, 13)})
+	require.NoError(t, err)
+
+	require.Len(t, report.Users, 1)
+	u := report.Users[0]
+	assert.Equal(t, "张三", u.UserName)
+	assert.True(t, u.OverAllocated)
+	require.Len(t, u.Weeks, 2)
+	assert.Equal(t, 50.0, u.Weeks[0].Hours)
+	assert.Equal(t, 40.0, u.Weeks[0].Capacity)
+	assert.Equal(t, 1.25, u.Weeks[0].Utilization)
+	assert.False(t, u.Weeks[1].OverAllocated)
+
+	require.Len(t, report.OverAllocations, 1)
+	assert.True(t, week.Equal(report.OverAllocations[0].WeekStart))
+	assert.False(t, report.OverAllocations[0].Resolved)
+	// 未注入进度计划服务时不给削峰建议
+	assert.Empty(t, report.Suggestions)
+}
+
+func TestWorkloadLeveling(t *testing.T) {
+	week := schedule.WeekStart(time.Now()).AddDate(0, 0, 14)
+	fri := week.AddDate(0, 0, 4)
+	next := week.AddDate(0, 0, 7)
+
+	tests := []struct {
+		name      string
+		tasks     []entity.Task
+		deps      []entity.TaskDependency
+		shift     int
+		relieved  float64
+		resolved  bool
+		suggested bool
+	}{
+		{
+			// B 没有后续任务，时差到项目结束（长任务 Z）为止；后移 3 天本周剩 30+8
+			name: "shift within free float resolves the peak",
+			tasks: []entity.Task{
+				workloadTask("A", entity.TaskStatusInProgress, "u1", week, fri, 30),
+				workloadTask("B", entity.TaskStatusPending, "u1", week, fri, 20),
+				workloadTask("Z", entity.TaskStatusPending, "", week, week.AddDate(0, 0, 32), 0),
+			},
+			shift: 3, relieved: 12, resolved: true, suggested: true,
+		},
+		{
+			// B 的后续 D 受 E 约束最早下周三开始，B 只有 2 天自由时差，只能缓解 8 小时
+			name: "slack limits the shift",
+			tasks: []entity.Task{
+				workloadTask("A", entity.TaskStatusInProgress, "u1", week, fri, 30),
+				workloadTask("B", entity.TaskStatusPending, "u1", week, fri, 20),
+				workloadTask("E", entity.TaskStatusPending, "", next, next.AddDate(0, 0, 1), 0),
+				workloadTask("D", entity.TaskStatusPending, "", next.AddDate(0, 0, 2), next.AddDate(0, 0, 4), 0),
+			},
+			deps: []entity.TaskDependency{
+				{ID: "d1", TaskID: "D", DependsOnID: "B", DependencyType: "FS"},
+				{ID: "d2", TaskID: "D", DependsOnID: "E", DependencyType: "FS"},
+			},
+			shift: 2, relieved: 8, resolved: false, suggested: true,
+		},
+		{
+			// 下周已满负荷，B 的时差（到 F 完成为止）内任何后移都会造成新的超负荷
+			name: "shift that overloads another week is rejected",
+			tasks: []entity.Task{
+				workloadTask("A", entity.TaskStatusInProgress, "u1", week, fri, 30),
+				workloadTask("B", entity.TaskStatusPending, "u1", week, fri, 20),
+				workloadTask("F", entity.TaskStatusInProgress, "u1", next, next.AddDate(0, 0, 4), 40),
+			},
+			resolved: false, suggested: false,
+		},
+		{
+			// 时差足够时越过满负荷的下周，整体后移两周
+			name: "shift skips a fully loaded week",
+			tasks: []entity.Task{
+				workloadTask("A", entity.TaskStatusInProgress, "u1", week, fri, 30),
+				workloadTask("B", entity.TaskStatusPending, "u1", week, fri, 20),
+				workloadTask("F", entity.TaskStatusInProgress, "u1", next, next.AddDate(0, 0, 4), 40),
+				workloadTask("Z", entity.TaskStatusPending, "", week, week.AddDate(0, 0, 32), 0),
+			},
+			shift: 10, relieved: 20, resolved: true, suggested: true,
+		},
+		{
+			// 进行中的任务不参与削峰
+			name: "started tasks are not moved",
+			tasks: []entity.Task{
+				workloadTask("A", entity.TaskStatusInProgress, "u1", week, fri, 30),
+				workloadTask("B", entity.TaskStatusInProgress, "u1", week, fri, 20),
+				workloadTask("Z", entity.TaskStatusPending, "", week, week.AddDate(0, 0, 32), 0),
+			},
+			resolved: false, suggested: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := setupWorkloadTest(t, tt.tasks, tt.deps)
+			svc := NewWorkloadService(db, nil)
+			svc.SetScheduleService(NewScheduleService(db))
+
+			report, err := svc.GetWorkload(context.Background(), WorkloadQuery{From: week, To: week.AddDate(0, 0, 13)})
+			require.NoError(t, err)
+			require.NotEmpty(t, report.OverAllocations)
+			assert.True(t, week.Equal(report.OverAllocations[0].WeekStart))
+			assert.Equal(t, tt.resolved, report.OverAllocations[0].Resolved)
+
+			if !tt.suggested {
+				assert.Empty(t, report.Suggestions)
+				return
+			}
+			require.Len(t, report.Suggestions, 1)
+			sg := report.Suggestions[0]
+			assert.Equal(t, "B", sg.TaskID)
+			assert.Equal(t, tt.shift, sg.ShiftDays)
+			assert.Equal(t, tt.relieved, sg.RelievedHours)
+			assert.True(t, schedule.AddWorkdays(schedule.WeekdayCalendar{}, week, tt.shift).Equal(sg.SuggestedStart))
+			// 原计划统计不受模拟削峰影响
+			assert.Equal(t, 50.0, report.Users[0].Weeks[0].Hours)
+		})
+	}
+}
@@ -0,0 +1,130 @@
+package cron
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func at(s string) time.Time {
+	t, err := time.ParseInLocation("2006-01-02 15:04", s, time.UTC)
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
+
+func TestParseInvalid(t *testing.T) {
+	for _, spec := range []string{"", "* * * *", "60 * * * *", "* 24 * * *", "* * 0 * *", "*/0 * * * *", "5-1 * * * *", "@often", "@every 10s"} {
+		_, err := Parse(spec)
+		assert.Error(t, err, spec)
+	}
+}
+
+func TestNext(t *testing.T) {
+	cases := []struct {
+		spec, from, want string
+	}{
+		{"0 9 * * *", "2026-03-02 08:30", "2026-03-02 09:00"},
+		{"0 9 * * *", "2026-03-02 09:00", "2026-03-03 09:00"},
+		{"*/15 * * * *", "2026-03-02 10:07", "2026-03-02 10:15"},
+		{"30 8-18/2 * * 1-5", "2026-03-06 18:31", "2026-03-09 08:30"}, // 周五晚 → 下周一
+		{"0 0 1 * *", "2026-01-31 12:00", "2026-02-01 00:00"},
+		{"0 0 29 2 *", "2026-03-01 00:00", "2028-02-29 00:00"},
+		{"0 0 * * 7", "2026-03-02 00:00", "2026-03-08 00:00"}, // 7 = 周日
+		{"@hourly", "2026-03-02 10:59", "2026-03-02 11:00"},
+		{"@daily", "2026-12-31 23:59", "2027-01-01 00:00"},
+	}
+	for _, c := range cases {
+		s, err := Parse(c.spec)
+		if assert.NoError(t, err, c.spec) {
+			assert.Equal(t, at(c.want), s.Next(at(c.from)), c.spec)
+		}
+	}
+}
+
+func TestNextDayOfMonthOrWeekday(t *testing.T) {
+	// 日、周同时限定时任一满足即触发：每月 15 日或每周一
+	s, err := Parse("0 0 15 * 1")
+	assert.NoError(t, err)
+	assert.Equal(t, at("2026-03-09 00:00"), s.Next(at("2026-03-02 00:00"))) // 周一
+	assert.Equal(t, at("2026-03-15 00:00"), s.Next(at("2026-03-09 00:00"))) // 15 日（周日）
+}
+
+func TestEveryIsAligned(t *testing.T) {
+	s, err := Parse("@every 30m")
+	assert.NoError(t, err)
+	assert.Equal(t, at("2026-03-02 10:30"), s.Next(at("2026-03-02 10:07")))
+	assert.Equal(t, at("2026-03-02 11:00"), s.Next(at("2026-03-02 10:30")))
+}
+
+type memLocker struct {
+	mu   sync.Mutex
+	keys map[string]bool
+}
+
+func (l *memLocker) Acquire(_ context.Context, key string, _ time.Duration) (bool, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.keys[key] {
+		return false, nil
+	}
+	l.keys[key] = true
+	return true, nil
+}
+
+func TestScheduledRunTakesLockOnce(t *testing.T) {
+	locker := &memLocker{keys: map[string]bool{}}
+	var runs []Run
+	newScheduler := func() *Scheduler {
+		s := New(locker)
+		s.OnRun = func(r Run) { runs = append(runs, r) }
+		assert.NoError(t, s.Add(Job{Name: "digest", Spec: "@daily", Run: func(context.Context) (string, error) {
+			return "sent 3", nil
+		}}))
+		return s
+	}
+	// 两个实例在同一计划时刻触发，只有一个执行
+	slot := at("2026-03-02 00:00")
+	for _, s := range []*Scheduler{newScheduler(), newScheduler()} {
+		s.runScheduled(context.Background(), s.entries[0], slot)
+	}
+	if assert.Len(t, runs, 1) {
+		assert.Equal(t, RunStatusSuccess, runs[0].Status)
+		assert.Equal(t, TriggerSchedule, runs[0].Trigger)
+		assert.Equal(t, "sent 3", runs[0].Output)
+	}
+}
+
+func TestRunNowRecordsFailureAndPanic(t *testing.T) {
+	s := New(nil)
+	assert.NoError(t, s.Add(Job{Name: "fail", Spec: "@hourly", Run: func(context.Context) (string, error) {
+		return "", errors.New("boom")
+	}}))
+	assert.NoError(t, s.Add(Job{Name: "panic", Spec: "@hourly", Run: func(context.Context) (string, error) {
+		panic("oops")
+	}}))
+	assert.Error(t, s.Add(Job{Name: "fail", Spec: "@hourly", Run: func(context.Context) (string, error) { return "", nil }}))
+
+	run, err := s.RunNow(context.Background(), "fail")
+	assert.NoError(t, err)
+	assert.Equal(t, RunStatusFailed, run.Status)
+	assert.EqualError(t, run.Err, "boom")
+
+	run, err = s.RunNow(context.Background(), "panic")
+	assert.NoError(t, err)
+	assert.Equal(t, RunStatusFailed, run.Status)
+	assert.Contains(t, run.Err.Error(), "oops")
+
+	_, err = s.RunNow(context.Background(), "missing")
+	assert.Error(t, err)
+
+	entries := s.Entries()
+	assert.Len(t, entries, 2)
+	assert.NotNil(t, entries[0].Next)
+	assert.NotNil(t, entries[0].Prev)
+}
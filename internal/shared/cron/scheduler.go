@@ -0,0 +1,322 @@
+package cron
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// =============================================================================
+// Scheduler — 进程内定时任务调度
+//
+// 多实例部署时每个实例都运行调度器，到点后以「任务名 + 计划触发时刻」争抢分布式锁，
+// 抢到的实例执行，锁在 TTL 到期后自然释放（不提前释放，避免时钟略有偏差的实例重复执行）
+// =============================================================================
+
+// 执行结果状态
+const (
+	RunStatusSuccess = "success"
+	RunStatusFailed  = "failed"
+)
+
+// 触发方式
+const (
+	TriggerSchedule = "schedule"
+	TriggerManual   = "manual"
+)
+
+var (
+	// ErrJobNotFound 任务未注册
+	ErrJobNotFound = errors.New("任务不存在")
+	// ErrJobRunning 任务正在本实例执行
+	ErrJobRunning = errors.New("任务正在执行中")
+)
+
+// Job 定时任务
+type Job struct {
+	Name        string                                    // 唯一名称，同时作为锁的键
+	Spec        string                                    // cron 表达式
+	Description string                                    // 说明
+	Timeout     time.Duration                             // 单次执行超时，0 表示使用调度器默认值
+	Run         func(ctx context.Context) (string, error) // 返回执行摘要
+}
+
+// Run 一次任务执行记录
+type Run struct {
+	Job        string
+	Trigger    string    // schedule / manual
+	ScheduleAt time.Time // 计划触发时刻（手动执行为发起时刻）
+	StartedAt  time.Time
+	FinishedAt time.Time
+	Status     string
+	Output     string
+	Err        error
+}
+
+// Entry 已注册任务及其调度状态
+type Entry struct {
+	Name        string     `json:"name"`
+	Spec        string     `json:"spec"`
+	Description string     `json:"description"`
+	Running     bool       `json:"running"`
+	Next        *time.Time `json:"next_run_at"`
+	Prev        *time.Time `json:"prev_run_at"` // 本实例最近一次执行
+}
+
+// Locker 分布式锁：Acquire 成功返回 true，锁在 ttl 后自动失效
+type Locker interface {
+	Acquire(ctx context.Context, key string, ttl time.Duration) (bool, error)
+}
+
+type entry struct {
+	job      Job
+	schedule Schedule
+	next     time.Time
+	prev     time.Time
+	running  bool
+}
+
+// Scheduler 定时任务调度器
+type Scheduler struct {
+	Locker   Locker         // 为空时不加锁（单实例部署）
+	Location *time.Location // cron 表达式所在时区，默认本地时区
+	LockTTL  time.Duration  // 锁有效期
+	Timeout  time.Duration  // 默认单次执行超时
+	OnRun    func(Run)      // 每次执行结束后回调（记录执行日志）
+
+	mu      sync.Mutex
+	entries []*entry
+	wake    chan struct{}
+	wg      sync.WaitGroup
+}
+
+// New 创建调度器（使用默认参数）
+func New(locker Locker) *Scheduler {
+	return &Scheduler{
+		Locker:   locker,
+		Location: time.Local,
+		LockTTL:  10 * time.Minute,
+		Timeout:  30 * time.Minute,
+		wake:     make(chan struct{}, 1),
+	}
+}
+
+// Add 注册任务，名称重复或表达式无效时返回错误
+func (s *Scheduler) Add(job Job) error {
+	if job.Name == "" || job.Run == nil {
+		return fmt.Errorf("任务名称和执行函数不能为空")
+	}
+	sched, err := Parse(job.Spec)
+	if err != nil {
+		return fmt.Errorf("任务 %s: %w", job.Name, err)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, e := range s.entries {
+		if e.job.Name == job.Name {
+			return fmt.Errorf("任务 %s 已注册", job.Name)
+		}
+	}
+	s.entries = append(s.entries, &entry{
+		job:      job,
+		schedule: sched,
+		next:     sched.Next(time.Now().In(s.Location)),
+	})
+	s.signal()
+	return nil
+}
+
+// Entries 已注册任务列表（按名称排序）
+func (s *Scheduler) Entries() []Entry {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	list := make([]Entry, 0, len(s.entries))
+	for _, e := range s.entries {
+		item := Entry{Name: e.job.Name, Spec: e.job.Spec, Description: e.job.Description, Running: e.running}
+		if !e.next.IsZero() {
+			next := e.next
+			item.Next = &next
+		}
+		if !e.prev.IsZero() {
+			prev := e.prev
+			item.Prev = &prev
+		}
+		list = append(list, item)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	return list
+}
+
+// Start 启动调度循环（阻塞直到 ctx 取消并等待执行中的任务结束，调用方应使用 go 启动）
+func (s *Scheduler) Start(ctx context.Context) {
+	log.Printf("[Cron] 启动: jobs=%d", len(s.Entries()))
+	for {
+		wait := time.Hour
+		now := time.Now().In(s.Location)
+		s.mu.Lock()
+		for _, e := range s.entries {
+			if !e.next.IsZero() && e.next.Sub(now) < wait {
+				wait = e.next.Sub(now)
+			}
+		}
+		s.mu.Unlock()
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			s.wg.Wait()
+			log.Printf("[Cron] 已停止")
+			return
+		case <-s.wake:
+			timer.Stop()
+			continue
+		case <-timer.C:
+		}
+
+		now = time.Now().In(s.Location)
+		s.mu.Lock()
+		for _, e := range s.entries {
+			if e.next.IsZero() || e.next.After(now) {
+				continue
+			}
+			slot := e.next
+			e.next = e.schedule.Next(now)
+			s.wg.Add(1)
+			go func(e *entry) {
+				defer s.wg.Done()
+				s.runScheduled(ctx, e, slot)
+			}(e)
+		}
+		s.mu.Unlock()
+	}
+}
+
+// RunNow 立即在本实例执行任务（不加分布式锁），同步返回执行记录
+func (s *Scheduler) RunNow(ctx context.Context, name string) (*Run, error) {
+	s.mu.Lock()
+	var target *entry
+	for _, e := range s.entries {
+		if e.job.Name == name {
+			target = e
+			break
+		}
+	}
+	s.mu.Unlock()
+	if target == nil {
+		return nil, fmt.Errorf("%w: %s", ErrJobNotFound, name)
+	}
+	run, ok := s.execute(ctx, target, TriggerManual, time.Now().In(s.Location))
+	if !ok {
+		return nil, ErrJobRunning
+	}
+	return run, nil
+}
+
+// runScheduled 到点执行：先抢锁，未抢到说明其他实例已执行
+func (s *Scheduler) runScheduled(ctx context.Context, e *entry, slot time.Time) {
+	if s.Locker != nil {
+		key := fmt.Sprintf("cron:%s:%d", e.job.Name, slot.Unix())
+		acquired, err := s.Locker.Acquire(ctx, key, s.LockTTL)
+		if err != nil {
+			log.Printf("[Cron] 获取锁失败，跳过本次执行: job=%s error=%v", e.job.Name, err)
+			return
+		}
+		if !acquired {
+			return
+		}
+	}
+	if _, ok := s.execute(ctx, e, TriggerSchedule, slot); !ok {
+		log.Printf("[Cron] 上一次执行尚未结束，跳过: job=%s", e.job.Name)
+	}
+}
+
+// execute 执行任务并回调 OnRun；任务已在本实例执行时返回 false
+func (s *Scheduler) execute(ctx context.Context, e *entry, trigger string, slot time.Time) (*Run, bool) {
+	s.mu.Lock()
+	if e.running {
+		s.mu.Unlock()
+		return nil, false
+	}
+	e.running = true
+	s.mu.Unlock()
+
+	timeout := e.job.Timeout
+	if timeout <= 0 {
+		timeout = s.Timeout
+	}
+	runCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	run := &Run{Job: e.job.Name, Trigger: trigger, ScheduleAt: slot, StartedAt: time.Now()}
+	run.Output, run.Err = safeRun(runCtx, e.job.Run)
+	run.FinishedAt = time.Now()
+	run.Status = RunStatusSuccess
+	if run.Err != nil {
+		run.Status = RunStatusFailed
+		log.Printf("[Cron] 任务执行失败: job=%s trigger=%s error=%v", e.job.Name, trigger, run.Err)
+	}
+
+	s.mu.Lock()
+	e.running = false
+	e.prev = run.StartedAt
+	s.mu.Unlock()
+
+	if s.OnRun != nil {
+		s.OnRun(*run)
+	}
+	return run, true
+}
+
+// safeRun 执行任务函数，panic 按失败处理
+func safeRun(ctx context.Context, fn func(context.Context) (string, error)) (output string, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+	return fn(ctx)
+}
+
+// signal 唤醒调度循环重新计算等待时间
+func (s *Scheduler) signal() {
+	if s.wake == nil {
+		return
+	}
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+// =============================================================================
+// RedisLocker — 基于 SET NX 的分布式锁
+// =============================================================================
+
+// RedisLocker Redis 锁
+type RedisLocker struct {
+	client *redis.Client
+	owner  string
+}
+
+// NewRedisLocker 创建 Redis 锁，锁值记录持有实例（主机名:PID）
+func NewRedisLocker(client *redis.Client) *RedisLocker {
+	host, _ := os.Hostname()
+	return &RedisLocker{client: client, owner: fmt.Sprintf("%s:%d", host, os.Getpid())}
+}
+
+// Acquire 获取锁
+func (l *RedisLocker) Acquire(ctx context.Context, key string, ttl time.Duration) (bool, error) {
+	ok, err := l.client.SetNX(ctx, "nimo:"+key, l.owner, ttl).Result()
+	if err != nil {
+		return false, fmt.Errorf("获取Redis锁失败: %w", err)
+	}
+	return ok, nil
+}
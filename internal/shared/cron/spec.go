@@ -0,0 +1,201 @@
+package cron
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// =============================================================================
+// Cron 表达式
+//
+// 标准 5 段格式：分 时 日 月 周，支持 *、列表(1,15)、范围(1-5)、步长(*/10、8-18/2)；
+// 周取值 0-7（0 和 7 都表示周日）。日与周同时限定时任一满足即触发（与 crontab 一致）。
+// 另支持描述符 @yearly @monthly @weekly @daily @midnight @hourly 和 @every <时长>
+// =============================================================================
+
+// Schedule 调度计划
+type Schedule interface {
+	// Next 返回 t 之后的下一次触发时间，找不到时返回零值
+	Next(t time.Time) time.Time
+}
+
+// specSchedule 5 段 cron 表达式，各字段以位图表示允许的取值
+type specSchedule struct {
+	minute, hour, dom, month, dow uint64
+	domStar, dowStar              bool
+}
+
+// everySchedule 固定间隔
+type everySchedule struct {
+	interval time.Duration
+}
+
+type fieldBounds struct {
+	name     string
+	min, max int
+}
+
+var (
+	minuteBounds = fieldBounds{"分", 0, 59}
+	hourBounds   = fieldBounds{"时", 0, 23}
+	domBounds    = fieldBounds{"日", 1, 31}
+	monthBounds  = fieldBounds{"月", 1, 12}
+	dowBounds    = fieldBounds{"周", 0, 7}
+)
+
+var descriptors = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+// Parse 解析 cron 表达式
+func Parse(spec string) (Schedule, error) {
+	spec = strings.TrimSpace(spec)
+	if spec == "" {
+		return nil, fmt.Errorf("cron 表达式不能为空")
+	}
+	if strings.HasPrefix(spec, "@every") {
+		d, err := time.ParseDuration(strings.TrimSpace(strings.TrimPrefix(spec, "@every")))
+		if err != nil {
+			return nil, fmt.Errorf("无效的间隔: %s", spec)
+		}
+		if d < time.Minute {
+			return nil, fmt.Errorf("间隔不能小于1分钟: %s", spec)
+		}
+		return everySchedule{interval: d}, nil
+	}
+	if strings.HasPrefix(spec, "@") {
+		expanded, ok := descriptors[spec]
+		if !ok {
+			return nil, fmt.Errorf("不支持的描述符: %s", spec)
+		}
+		spec = expanded
+	}
+
+	fields := strings.Fields(spec)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("cron 表达式应为5段（分 时 日 月 周），实际 %d 段: %s", len(fields), spec)
+	}
+	s := &specSchedule{}
+	var err error
+	if s.minute, err = parseField(fields[0], minuteBounds); err != nil {
+		return nil, err
+	}
+	if s.hour, err = parseField(fields[1], hourBounds); err != nil {
+		return nil, err
+	}
+	if s.dom, err = parseField(fields[2], domBounds); err != nil {
+		return nil, err
+	}
+	if s.month, err = parseField(fields[3], monthBounds); err != nil {
+		return nil, err
+	}
+	if s.dow, err = parseField(fields[4], dowBounds); err != nil {
+		return nil, err
+	}
+	if s.dow&(1<<7) != 0 {
+		s.dow |= 1
+	}
+	s.domStar = fields[2] == "*" || fields[2] == "?"
+	s.dowStar = fields[4] == "*" || fields[4] == "?"
+	return s, nil
+}
+
+// Validate 校验 cron 表达式
+func Validate(spec string) error {
+	_, err := Parse(spec)
+	return err
+}
+
+// parseField 解析单个字段为位图
+func parseField(field string, b fieldBounds) (uint64, error) {
+	var bits uint64
+	for _, part := range strings.Split(field, ",") {
+		lo, hi, step := b.min, b.max, 1
+		rangePart := part
+		if i := strings.Index(part, "/"); i >= 0 {
+			n, err := strconv.Atoi(part[i+1:])
+			if err != nil || n <= 0 {
+				return 0, fmt.Errorf("%s字段步长无效: %s", b.name, part)
+			}
+			step = n
+			rangePart = part[:i]
+		}
+		switch {
+		case rangePart == "*" || rangePart == "?":
+		case strings.Contains(rangePart, "-"):
+			bounds := strings.SplitN(rangePart, "-", 2)
+			var err1, err2 error
+			lo, err1 = strconv.Atoi(bounds[0])
+			hi, err2 = strconv.Atoi(bounds[1])
+			if err1 != nil || err2 != nil {
+				return 0, fmt.Errorf("%s字段范围无效: %s", b.name, part)
+			}
+		default:
+			n, err := strconv.Atoi(rangePart)
+			if err != nil {
+				return 0, fmt.Errorf("%s字段取值无效: %s", b.name, part)
+			}
+			lo = n
+			if step == 1 {
+				hi = n
+			}
+		}
+		if lo < b.min || hi > b.max || lo > hi {
+			return 0, fmt.Errorf("%s字段超出范围 %d-%d: %s", b.name, b.min, b.max, part)
+		}
+		for v := lo; v <= hi; v += step {
+			bits |= 1 << uint(v)
+		}
+	}
+	return bits, nil
+}
+
+// Next 下一次触发时间（按 t 所在时区计算）
+func (s *specSchedule) Next(t time.Time) time.Time {
+	loc := t.Location()
+	t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), 0, 0, loc).Add(time.Minute)
+	limit := t.AddDate(5, 0, 0)
+	for t.Before(limit) {
+		if s.month&(1<<uint(t.Month())) == 0 {
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, loc)
+			continue
+		}
+		if !s.dayMatches(t) {
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, loc)
+			continue
+		}
+		if s.hour&(1<<uint(t.Hour())) == 0 {
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, loc)
+			continue
+		}
+		if s.minute&(1<<uint(t.Minute())) == 0 {
+			t = t.Add(time.Minute)
+			continue
+		}
+		return t
+	}
+	return time.Time{}
+}
+
+// dayMatches 日、周匹配：两者都限定时满足其一即可，否则需同时满足
+func (s *specSchedule) dayMatches(t time.Time) bool {
+	domOK := s.dom&(1<<uint(t.Day())) != 0
+	dowOK := s.dow&(1<<uint(t.Weekday())) != 0
+	if s.domStar || s.dowStar {
+		return domOK && dowOK
+	}
+	return domOK || dowOK
+}
+
+// Next 下一次触发时间：对齐到间隔的整数倍，保证多实例算出相同的触发时刻
+func (e everySchedule) Next(t time.Time) time.Time {
+	return t.Truncate(e.interval).Add(e.interval)
+}
@@ -20,10 +20,12 @@ type ActionContext struct {
 	ToState    string                 `json:"to_state"`    // 转换后状态
 	Event      string                 `json:"event"`       // 触发事件
 	EventData  map[string]interface{} `json:"event_data"`  // 事件数据
+	OutboxID   uuid.UUID              `json:"outbox_id"`   // 发件箱记录ID（重试时不变，可用于幂等）
 }
 
 // ActionExecutor 动作执行器接口
 // Phase 2 将实现飞书相关的执行器（创建任务、发起审批、发送通知等）
+// 动作在状态转换事务提交后由 OutboxDispatcher 调用，失败会重试，实现需保证幂等
 type ActionExecutor interface {
 	// Execute 执行单个动作
 	// action: 动作定义（类型+配置）
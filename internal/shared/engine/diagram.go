@@ -0,0 +1,110 @@
+package engine
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/bitfantasy/nimo/internal/shared/expr"
+)
+
+// =============================================================================
+// 状态机流程图导出 — Mermaid / Graphviz
+// =============================================================================
+
+// 支持的流程图格式
+const (
+	DiagramMermaid  = "mermaid"
+	DiagramGraphviz = "dot"
+)
+
+// RenderDiagram 按格式导出状态机流程图
+func RenderDiagram(def *StateMachineDefinition, format string) (string, error) {
+	switch strings.ToLower(format) {
+	case "", DiagramMermaid:
+		return RenderMermaid(def)
+	case DiagramGraphviz, "graphviz":
+		return RenderGraphviz(def)
+	default:
+		return "", fmt.Errorf("不支持的流程图格式: %s（可选 mermaid / dot）", format)
+	}
+}
+
+// RenderMermaid 导出 Mermaid stateDiagram-v2
+func RenderMermaid(def *StateMachineDefinition) (string, error) {
+	states, err := def.StateDefinitions()
+	if err != nil {
+		return "", err
+	}
+
+	var b strings.Builder
+	b.WriteString("stateDiagram-v2\n")
+	for _, st := range states {
+		label := st.Name
+		if st.Label != "" {
+			label = st.Label + " (" + st.Name + ")"
+		}
+		fmt.Fprintf(&b, "    state \"%s\" as %s\n", escapeDiagramLabel(label), st.Name)
+	}
+	fmt.Fprintf(&b, "    [*] --> %s\n", def.InitialState)
+	for _, t := range def.Transitions {
+		fmt.Fprintf(&b, "    %s --> %s: %s\n", t.FromState, t.ToState, escapeDiagramLabel(transitionLabel(t)))
+	}
+	for _, st := range states {
+		if st.IsFinal {
+			fmt.Fprintf(&b, "    %s --> [*]\n", st.Name)
+		}
+		for _, timer := range st.Timers {
+			fmt.Fprintf(&b, "    note right of %s: 定时 %s 后触发 %s\n", st.Name, timer.After, timer.Event)
+		}
+	}
+	return b.String(), nil
+}
+
+// RenderGraphviz 导出 Graphviz dot
+func RenderGraphviz(def *StateMachineDefinition) (string, error) {
+	states, err := def.StateDefinitions()
+	if err != nil {
+		return "", err
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "digraph %q {\n", def.Name)
+	b.WriteString("    rankdir=LR;\n")
+	b.WriteString("    node [shape=box, style=rounded];\n")
+	b.WriteString("    __start [shape=point];\n")
+	for _, st := range states {
+		label := st.Name
+		if st.Label != "" {
+			label = st.Label + "\\n" + st.Name
+		}
+		shape := ""
+		if st.IsFinal {
+			shape = ", peripheries=2"
+		}
+		fmt.Fprintf(&b, "    %q [label=\"%s\"%s];\n", st.Name, escapeDiagramLabel(label), shape)
+	}
+	fmt.Fprintf(&b, "    __start -> %q;\n", def.InitialState)
+	for _, t := range def.Transitions {
+		fmt.Fprintf(&b, "    %q -> %q [label=\"%s\"];\n", t.FromState, t.ToState, escapeDiagramLabel(transitionLabel(t)))
+	}
+	b.WriteString("}\n")
+	return b.String(), nil
+}
+
+// transitionLabel 转换的显示标签: 事件 [条件] (优先级)
+func transitionLabel(t StateTransition) string {
+	label := t.Event
+	if cond := expr.DescribeCondition(t.Condition); cond != "" {
+		label += " [" + cond + "]"
+	}
+	if t.Priority != 0 {
+		label += fmt.Sprintf(" (p=%d)", t.Priority)
+	}
+	return label
+}
+
+// escapeDiagramLabel 转义标签中的引号和冒号，避免破坏图语法
+func escapeDiagramLabel(s string) string {
+	s = strings.ReplaceAll(s, `"`, `'`)
+	return strings.ReplaceAll(s, ":", "：")
+}
@@ -2,6 +2,7 @@ package engine
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"sync"
@@ -15,14 +16,21 @@ import (
 // Engine — 状态机核心引擎
 // =============================================================================
 
+// ErrConcurrentTransition 并发状态转换冲突
+// 实体状态在读取后已被其他操作修改（版本号不一致），或与调用方预期的状态不符
+var ErrConcurrentTransition = errors.New("state transition conflict: entity state has changed")
+
 // Engine 状态机引擎
 // 管理状态机定义、执行状态转换、记录审计日志
 type Engine struct {
 	DB             *gorm.DB
 	repo           *Repository
 	actionExecutor ActionExecutor
-	machines       map[string]*StateMachineDefinition // name -> definition 内存缓存
-	mu             sync.RWMutex                       // 保护 machines map
+	machines       map[string]*StateMachineDefinition    // name -> 最新版本定义 内存缓存
+	versions       map[uuid.UUID]*StateMachineDefinition // id -> 各版本定义 内存缓存
+	mu             sync.RWMutex                          // 保护 machines/versions map
+	outboxSignal   chan struct{}                         // 转换提交后唤醒发件箱调度器
+	calendar       WorkCalendar                          // 工作日历（定时器按工作时间计时）
 }
 
 // NewEngine 创建状态机引擎实例
@@ -38,6 +46,9 @@ func NewEngine(db *gorm.DB, executor ActionExecutor) *Engine {
 		repo:           NewRepository(db),
 		actionExecutor: executor,
 		machines:       make(map[string]*StateMachineDefinition),
+		versions:       make(map[uuid.UUID]*StateMachineDefinition),
+		outboxSignal:   make(chan struct{}, 1),
+		calendar:       NewWeekdayCalendar(),
 	}
 }
 
@@ -46,7 +57,8 @@ func NewEngine(db *gorm.DB, executor ActionExecutor) *Engine {
 // =============================================================================
 
 // RegisterMachine 注册状态机定义
-// 将定义存入数据库并缓存到内存
+// 定义内容与最新版本一致时复用该版本；否则保存为新版本（版本号 +1），
+// 已有实体仍按其固定的旧版本流转，新实体使用最新版本
 func (e *Engine) RegisterMachine(def *StateMachineDefinition) error {
 	if def == nil {
 		return fmt.Errorf("状态机定义不能为空")
@@ -57,46 +69,49 @@ func (e *Engine) RegisterMachine(def *StateMachineDefinition) error {
 	if def.InitialState == "" {
 		return fmt.Errorf("初始状态不能为空")
 	}
-
-	// 生成 ID（如果没有）
-	if def.ID == uuid.Nil {
-		def.ID = uuid.New()
-	}
-
-	// 保存到数据库
-	if err := e.repo.SaveMachineDefinition(def); err != nil {
-		return fmt.Errorf("注册状态机失败: %w", err)
+	if err := ValidateMachine(def); err != nil {
+		return err
 	}
 
-	// 从数据库重新读取（确保 ID 一致）
-	saved, err := e.repo.GetMachineDefinition(def.Name)
+	checksum, err := MachineChecksum(def)
 	if err != nil {
-		return fmt.Errorf("读取已保存的状态机失败: %w", err)
+		return fmt.Errorf("计算状态机摘要失败: %w", err)
 	}
 
-	// 保存转换规则
-	if len(def.Transitions) > 0 {
-		for i := range def.Transitions {
-			def.Transitions[i].MachineID = saved.ID
-		}
-		if err := e.repo.SaveTransitions(def.Transitions); err != nil {
-			return fmt.Errorf("保存转换规则失败: %w", err)
+	// 与最新版本比较
+	latest, err := e.repo.GetMachineDefinition(def.Name)
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return fmt.Errorf("读取状态机失败: %w", err)
+	}
+	if latest != nil && latest.Checksum == checksum {
+		transitions, err := e.repo.GetTransitions(latest.ID)
+		if err != nil {
+			return err
 		}
+		latest.Transitions = transitions
+		e.cacheMachine(latest, true)
+		log.Printf("[StateEngine] 注册状态机: name=%s version=%d（定义未变化）", latest.Name, latest.Version)
+		return nil
 	}
 
-	// 更新内存缓存
-	e.mu.Lock()
-	saved.Transitions = def.Transitions
-	e.machines[def.Name] = saved
-	e.mu.Unlock()
+	def.ID = uuid.New()
+	def.Version = 1
+	if latest != nil {
+		def.Version = latest.Version + 1
+	}
+	def.Checksum = checksum
+	if err := e.repo.CreateMachineVersion(def); err != nil {
+		return fmt.Errorf("注册状态机失败: %w", err)
+	}
+	e.cacheMachine(def, true)
 
-	log.Printf("[StateEngine] 注册状态机: name=%s initial_state=%s transitions=%d",
-		def.Name, def.InitialState, len(def.Transitions))
+	log.Printf("[StateEngine] 注册状态机: name=%s version=%d initial_state=%s transitions=%d",
+		def.Name, def.Version, def.InitialState, len(def.Transitions))
 
 	return nil
 }
 
-// GetMachine 获取已注册的状态机定义
+// GetMachine 获取已注册的状态机定义（最新版本）
 func (e *Engine) GetMachine(name string) (*StateMachineDefinition, error) {
 	// 先从内存缓存查找
 	e.mu.RLock()
@@ -120,13 +135,44 @@ func (e *Engine) GetMachine(name string) (*StateMachineDefinition, error) {
 	def.Transitions = transitions
 
 	// 放入缓存
-	e.mu.Lock()
-	e.machines[name] = def
-	e.mu.Unlock()
+	e.cacheMachine(def, true)
+
+	return def, nil
+}
+
+// getMachineByID 按 ID 获取状态机的某个版本（实体固定版本时使用）
+func (e *Engine) getMachineByID(repo *Repository, id uuid.UUID) (*StateMachineDefinition, error) {
+	e.mu.RLock()
+	if m, ok := e.versions[id]; ok {
+		e.mu.RUnlock()
+		return m, nil
+	}
+	e.mu.RUnlock()
+
+	def, err := repo.GetMachineDefinitionByID(id)
+	if err != nil {
+		return nil, err
+	}
+	transitions, err := repo.GetTransitions(def.ID)
+	if err != nil {
+		return nil, err
+	}
+	def.Transitions = transitions
 
+	e.cacheMachine(def, false)
 	return def, nil
 }
 
+// cacheMachine 放入内存缓存；latest 为 true 时同时作为该名称的最新版本
+func (e *Engine) cacheMachine(def *StateMachineDefinition, latest bool) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.versions[def.ID] = def
+	if latest {
+		e.machines[def.Name] = def
+	}
+}
+
 // =============================================================================
 // 状态转换 — 核心方法
 // =============================================================================
@@ -139,79 +185,184 @@ func (e *Engine) GetMachine(name string) (*StateMachineDefinition, error) {
 // triggeredBy: 操作人ID
 // triggeredByType: 操作人类型（user/agent/system）
 func (e *Engine) Fire(entityType string, entityID uuid.UUID, event string, eventData map[string]interface{}, triggeredBy string, triggeredByType string) (*TransitionLog, error) {
+	return e.FireWithExpectedState(entityType, entityID, "", event, eventData, triggeredBy, triggeredByType)
+}
+
+// FireWithExpectedState 触发状态转换，并校验实体当前状态
+// expectedState: 调用方认为实体所处的状态（如前端页面加载时的状态），
+// 与实际状态不一致时返回 ErrConcurrentTransition，用于拒绝过期的界面操作；传空字符串则不校验
+//
+// 当前状态在事务内读取，并以版本号做 compare-and-swap 写回，
+// 两个并发转换中只有一个会成功，另一个返回 ErrConcurrentTransition
+func (e *Engine) FireWithExpectedState(entityType string, entityID uuid.UUID, expectedState string, event string, eventData map[string]interface{}, triggeredBy string, triggeredByType string) (*TransitionLog, error) {
+	var transitionLog *TransitionLog
+	err := e.DB.Transaction(func(tx *gorm.DB) error {
+		var err error
+		transitionLog, err = e.FireInTx(tx, FireRequest{
+			EntityType:      entityType,
+			EntityID:        entityID,
+			Event:           event,
+			ExpectedState:   expectedState,
+			EventData:       eventData,
+			TriggeredBy:     triggeredBy,
+			TriggeredByType: triggeredByType,
+		})
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	e.notifyOutbox()
+
+	return transitionLog, nil
+}
+
+// FireRequest 状态转换请求（FireInTx 使用）
+type FireRequest struct {
+	EntityType string
+	EntityID   uuid.UUID
+	Event      string // 触发事件；为空时按 TargetState 反查当前状态下通往该状态的事件
+	// TargetState 期望进入的状态，供"直接修改状态"类接口使用；
+	// 与 Event 同时给出时，实际进入的状态不一致视为条件不满足
+	TargetState   string
+	ExpectedState string // 调用方认为实体所处的状态，不一致时返回 ErrConcurrentTransition
+	// InitialState 实体尚无状态记录时的起始状态（存量业务数据首次接入引擎时传业务表中的状态），
+	// 为空则使用状态机初始状态
+	InitialState    string
+	EventData       map[string]interface{}
+	TriggeredBy     string
+	TriggeredByType string
+}
+
+// FireInTx 在调用方事务中触发状态转换
+// 用于业务表状态字段需要与引擎状态同一事务提交的场景；动作随事务写入发件箱，
+// 提交后由 OutboxDispatcher 轮询执行（可调用 NotifyOutbox 立即唤醒）
+func (e *Engine) FireInTx(tx *gorm.DB, req FireRequest) (*TransitionLog, error) {
+	entityType, entityID := req.EntityType, req.EntityID
+
 	// 1. 获取状态机定义
 	machine, err := e.findMachineForEntity(entityType)
 	if err != nil {
 		return nil, fmt.Errorf("未找到实体类型 [%s] 对应的状态机: %w", entityType, err)
 	}
 
-	// 2. 获取当前状态
-	currentState, err := e.getCurrentStateOrInitial(entityType, entityID, machine)
+	// 2. 在事务中执行状态转换
+	txRepo := NewRepository(tx)
+
+	// 读取当前状态（含版本号）
+	existing, err := txRepo.GetEntityState(entityType, entityID)
 	if err != nil {
 		return nil, fmt.Errorf("获取当前状态失败: %w", err)
 	}
-
-	// 3. 查找匹配的转换规则
-	transition, err := e.findMatchingTransition(machine.ID, currentState, event, eventData)
+	// 已有实体按其固定的状态机版本流转
+	machine, err = e.pinnedMachine(txRepo, machine, existing)
 	if err != nil {
 		return nil, err
 	}
-
-	// 4. 在事务中执行状态转换
-	var transitionLog *TransitionLog
-	err = e.DB.Transaction(func(tx *gorm.DB) error {
-		txRepo := NewRepository(tx)
-
-		// 更新实体状态
-		entityState := &EntityState{
-			EntityType:   entityType,
-			EntityID:     entityID,
-			CurrentState: transition.ToState,
-			MachineID:    machine.ID,
-			UpdatedAt:    time.Now(),
+	currentState := machine.InitialState
+	if existing != nil {
+		currentState = existing.CurrentState
+	} else if req.InitialState != "" {
+		if !machine.HasState(req.InitialState) {
+			return nil, fmt.Errorf("状态机 [%s] 中不存在状态: %s", machine.Name, req.InitialState)
 		}
-		if err := txRepo.SaveEntityState(entityState); err != nil {
-			return fmt.Errorf("更新实体状态失败: %w", err)
+		currentState = req.InitialState
+	}
+
+	if req.ExpectedState != "" && req.ExpectedState != currentState {
+		return nil, fmt.Errorf("%w: entity=%s/%s expected=%s actual=%s",
+			ErrConcurrentTransition, entityType, entityID.String(), req.ExpectedState, currentState)
+	}
+
+	event := req.Event
+	if event == "" {
+		if event = machine.eventTo(currentState, req.TargetState); event == "" {
+			return nil, fmt.Errorf("无效的状态转换: %s → %s（没有匹配的转换规则）", currentState, req.TargetState)
 		}
+	}
 
-		// 解析并执行动作
-		actions, actionsExecuted := e.executeActions(transition, entityType, entityID, currentState, event, eventData)
+	// 查找匹配的转换规则
+	transition, err := e.findMatchingTransition(txRepo, machine.ID, currentState, event, req.EventData)
+	if err != nil {
+		return nil, err
+	}
+	if req.TargetState != "" && transition.ToState != req.TargetState {
+		return nil, fmt.Errorf("无效的状态转换: %s → %s（条件不满足，事件 %s 将进入 %s）",
+			currentState, req.TargetState, event, transition.ToState)
+	}
 
-		// 序列化事件数据和执行的动作
-		eventDataJSON, _ := json.Marshal(eventData)
-		actionsJSON, _ := json.Marshal(actionsExecuted)
+	// 按版本号更新实体状态
+	entityState := &EntityState{
+		EntityType:     entityType,
+		EntityID:       entityID,
+		CurrentState:   transition.ToState,
+		MachineID:      machine.ID,
+		MachineVersion: machine.Version,
+		UpdatedAt:      time.Now(),
+	}
+	var swapped bool
+	if existing == nil {
+		swapped, err = txRepo.CreateEntityState(entityState)
+	} else {
+		swapped, err = txRepo.CompareAndSwapEntityState(entityState, existing.Version)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("更新实体状态失败: %w", err)
+	}
+	if !swapped {
+		return nil, fmt.Errorf("%w: entity=%s/%s state=%s event=%s",
+			ErrConcurrentTransition, entityType, entityID.String(), currentState, event)
+	}
 
-		// 记录转换日志
-		transitionLog = &TransitionLog{
-			ID:              uuid.New(),
-			EntityType:      entityType,
-			EntityID:        entityID,
-			FromState:       currentState,
-			ToState:         transition.ToState,
-			Event:           event,
-			EventData:       eventDataJSON,
-			TriggeredBy:     triggeredBy,
-			TriggeredByType: triggeredByType,
-			ActionsExecuted: actionsJSON,
-			CreatedAt:       time.Now(),
-		}
-		if err := txRepo.SaveTransitionLog(transitionLog); err != nil {
-			return fmt.Errorf("保存转换日志失败: %w", err)
+	// 离开旧状态：取消定时器；进入新状态：创建定时器
+	// 自环转换（如 reviewing 上的 escalate）没有离开状态，保留其余定时器且不重新计时，避免定时器无限自我续期
+	if transition.FromState != transition.ToState {
+		if err := e.rescheduleTimers(txRepo, machine, entityType, entityID, transition.ToState, entityState.UpdatedAt); err != nil {
+			return nil, fmt.Errorf("更新定时器失败: %w", err)
 		}
+	}
 
-		log.Printf("[StateEngine] 状态转换: entity=%s/%s %s→%s event=%s actions=%d",
-			entityType, entityID.String(), currentState, transition.ToState, event, len(actions))
-
-		return nil
-	})
+	// 序列化事件数据
+	eventDataJSON, _ := json.Marshal(req.EventData)
+	logID := uuid.New()
 
+	// 动作写入发件箱（同一事务），提交后由 OutboxDispatcher 执行
+	actions, actionsQueued, err := e.enqueueActions(txRepo, transition, logID, entityType, entityID, currentState, event, eventDataJSON)
 	if err != nil {
 		return nil, err
 	}
+	actionsJSON, _ := json.Marshal(actionsQueued)
+
+	// 记录转换日志
+	transitionLog := &TransitionLog{
+		ID:              logID,
+		EntityType:      entityType,
+		EntityID:        entityID,
+		FromState:       currentState,
+		ToState:         transition.ToState,
+		Event:           event,
+		EventData:       eventDataJSON,
+		TriggeredBy:     req.TriggeredBy,
+		TriggeredByType: req.TriggeredByType,
+		ActionsExecuted: actionsJSON,
+		CreatedAt:       time.Now(),
+	}
+	if err := txRepo.SaveTransitionLog(transitionLog); err != nil {
+		return nil, fmt.Errorf("保存转换日志失败: %w", err)
+	}
+
+	log.Printf("[StateEngine] 状态转换: entity=%s/%s %s→%s event=%s version=%d actions=%d",
+		entityType, entityID.String(), currentState, transition.ToState, event, entityState.Version, len(actions))
 
 	return transitionLog, nil
 }
 
+// NotifyOutbox 唤醒发件箱派发（FireInTx 的事务提交后调用）
+func (e *Engine) NotifyOutbox() {
+	e.notifyOutbox()
+}
+
 // =============================================================================
 // 查询方法
 // =============================================================================
@@ -248,22 +399,22 @@ func (e *Engine) findMachineForEntity(entityType string) (*StateMachineDefinitio
 	return e.GetMachine(entityType)
 }
 
-// getCurrentStateOrInitial 获取实体当前状态，如果是新实体则返回初始状态
-func (e *Engine) getCurrentStateOrInitial(entityType string, entityID uuid.UUID, machine *StateMachineDefinition) (string, error) {
-	state, err := e.repo.GetEntityState(entityType, entityID)
-	if err != nil {
-		return "", err
+// pinnedMachine 返回实体固定的状态机版本；新实体（existing 为 nil）使用 latest
+func (e *Engine) pinnedMachine(repo *Repository, latest *StateMachineDefinition, existing *EntityState) (*StateMachineDefinition, error) {
+	if existing == nil || existing.MachineID == uuid.Nil || existing.MachineID == latest.ID {
+		return latest, nil
 	}
-	if state == nil {
-		return machine.InitialState, nil
+	machine, err := e.getMachineByID(repo, existing.MachineID)
+	if err != nil {
+		return nil, fmt.Errorf("获取实体所属状态机版本失败: %w", err)
 	}
-	return state.CurrentState, nil
+	return machine, nil
 }
 
 // findMatchingTransition 查找匹配的转换规则
 // 按优先级排序，找到第一个条件满足的规则
-func (e *Engine) findMatchingTransition(machineID uuid.UUID, fromState string, event string, eventData map[string]interface{}) (*StateTransition, error) {
-	transitions, err := e.repo.GetMatchingTransitions(machineID, fromState, event)
+func (e *Engine) findMatchingTransition(repo *Repository, machineID uuid.UUID, fromState string, event string, eventData map[string]interface{}) (*StateTransition, error) {
+	transitions, err := repo.GetMatchingTransitions(machineID, fromState, event)
 	if err != nil {
 		return nil, fmt.Errorf("查找转换规则失败: %w", err)
 	}
@@ -274,7 +425,11 @@ func (e *Engine) findMatchingTransition(machineID uuid.UUID, fromState string, e
 
 	// 按优先级排序（已在 SQL 中排序），找第一个条件满足的
 	for _, t := range transitions {
-		if EvaluateCondition(t.Condition, eventData) {
+		ok, err := EvaluateCondition(t.Condition, eventData)
+		if err != nil {
+			return nil, fmt.Errorf("评估转换条件失败: state=%s event=%s to=%s: %w", fromState, event, t.ToState, err)
+		}
+		if ok {
 			return &t, nil
 		}
 	}
@@ -282,45 +437,72 @@ func (e *Engine) findMatchingTransition(machineID uuid.UUID, fromState string, e
 	return nil, fmt.Errorf("无效的状态转换: state=%s event=%s（条件不满足）", fromState, event)
 }
 
-// executeActions 解析并执行转换动作
-// 返回解析出的动作列表和执行结果
-func (e *Engine) executeActions(transition *StateTransition, entityType string, entityID uuid.UUID, fromState string, event string, eventData map[string]interface{}) ([]TransitionAction, []map[string]interface{}) {
+// enqueueActions 解析转换动作并写入发件箱
+// 返回解析出的动作列表和入队记录（写入 TransitionLog.ActionsExecuted）
+func (e *Engine) enqueueActions(repo *Repository, transition *StateTransition, logID uuid.UUID, entityType string, entityID uuid.UUID, fromState string, event string, eventDataJSON json.RawMessage) ([]TransitionAction, []map[string]interface{}, error) {
 	var actions []TransitionAction
-	var actionsExecuted []map[string]interface{}
+	var actionsQueued []map[string]interface{}
 
 	// 解析动作列表
 	if len(transition.Actions) > 0 && string(transition.Actions) != "null" {
 		if err := json.Unmarshal(transition.Actions, &actions); err != nil {
 			log.Printf("[StateEngine] 解析动作失败: %v", err)
-			return actions, actionsExecuted
+			return actions, actionsQueued, nil
 		}
 	}
-
-	// 构建动作上下文
-	ctx := ActionContext{
-		EntityType: entityType,
-		EntityID:   entityID,
-		FromState:  fromState,
-		ToState:    transition.ToState,
-		Event:      event,
-		EventData:  eventData,
+	if len(actions) == 0 {
+		return actions, actionsQueued, nil
 	}
 
-	// 执行每个动作
+	now := time.Now()
+	entries := make([]ActionOutbox, 0, len(actions))
 	for _, action := range actions {
-		result := map[string]interface{}{
-			"type":   action.Type,
-			"status": "success",
+		configJSON, _ := json.Marshal(action.Config)
+		entry := ActionOutbox{
+			ID:              uuid.New(),
+			TransitionLogID: logID,
+			EntityType:      entityType,
+			EntityID:        entityID,
+			FromState:       fromState,
+			ToState:         transition.ToState,
+			Event:           event,
+			EventData:       eventDataJSON,
+			ActionType:      action.Type,
+			ActionConfig:    configJSON,
+			Status:          OutboxStatusPending,
+			NextAttemptAt:   now,
 		}
+		entries = append(entries, entry)
+		actionsQueued = append(actionsQueued, map[string]interface{}{
+			"type":      action.Type,
+			"status":    "queued",
+			"outbox_id": entry.ID.String(),
+		})
+	}
 
-		if err := e.actionExecutor.Execute(action, ctx); err != nil {
-			log.Printf("[StateEngine] 动作执行失败: type=%s error=%v", action.Type, err)
-			result["status"] = "error"
-			result["error"] = err.Error()
-		}
+	if err := repo.CreateOutboxEntries(entries); err != nil {
+		return nil, nil, err
+	}
+
+	return actions, actionsQueued, nil
+}
 
-		actionsExecuted = append(actionsExecuted, result)
+// notifyOutbox 唤醒发件箱调度器（非阻塞）
+func (e *Engine) notifyOutbox() {
+	select {
+	case e.outboxSignal <- struct{}{}:
+	default:
 	}
+}
+
+// entityNamespace 非 UUID 业务主键映射为实体ID时使用的命名空间
+var entityNamespace = uuid.MustParse("6f1c2a4e-3b7d-5e90-8a1f-2c4d6e8f0a1b")
 
-	return actions, actionsExecuted
+// EntityUUID 将业务主键转换为引擎实体ID
+// 本身是 UUID 的直接使用；其他字符串（如 SRM 的 32 位截断ID）按 UUIDv5 稳定映射，同一主键总是得到同一实体ID
+func EntityUUID(id string) uuid.UUID {
+	if parsed, err := uuid.Parse(id); err == nil {
+		return parsed
+	}
+	return uuid.NewSHA1(entityNamespace, []byte(id))
 }
@@ -0,0 +1,410 @@
+package engine
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func setupEngineTestDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		t.Fatalf("open sqlite: %v", err)
+	}
+	// :memory: 库每个连接独立，固定单连接保证所有查询落在同一个库
+	sqlDB, _ := db.DB()
+	sqlDB.SetMaxOpenConns(1)
+	if err := db.AutoMigrate(&StateMachineDefinition{}, &StateTransition{}, &TransitionLog{}, &EntityState{}, &ActionOutbox{}, &EntityTimer{}); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	t.Cleanup(func() { sqlDB.Close() })
+	return db
+}
+
+func setupPLMTaskEngine(t *testing.T) *Engine {
+	t.Helper()
+	eng := NewEngine(setupEngineTestDB(t), nil)
+	if err := eng.RegisterMachine(NewPLMTaskMachine()); err != nil {
+		t.Fatalf("register machine: %v", err)
+	}
+	return eng
+}
+
+func TestFireIncrementsVersion(t *testing.T) {
+	eng := setupPLMTaskEngine(t)
+	taskID := uuid.New()
+
+	_, err := eng.Fire("plm_task", taskID, "assign", nil, "u1", "user")
+	assert.NoError(t, err)
+	_, err = eng.Fire("plm_task", taskID, "start", nil, "u1", "user")
+	assert.NoError(t, err)
+
+	state, err := eng.repo.GetEntityState("plm_task", taskID)
+	assert.NoError(t, err)
+	assert.Equal(t, "in_progress", state.CurrentState)
+	assert.Equal(t, int64(2), state.Version)
+}
+
+func TestFireWithExpectedStateRejectsStaleAction(t *testing.T) {
+	eng := setupPLMTaskEngine(t)
+	taskID := uuid.New()
+
+	_, err := eng.Fire("plm_task", taskID, "assign", nil, "u1", "user")
+	assert.NoError(t, err)
+
+	// 界面仍停留在 unassigned，再次指派应被拒绝
+	_, err = eng.FireWithExpectedState("plm_task", taskID, "unassigned", "assign", nil, "u2", "user")
+	assert.True(t, errors.Is(err, ErrConcurrentTransition))
+
+	_, err = eng.FireWithExpectedState("plm_task", taskID, "pending", "start", nil, "u1", "user")
+	assert.NoError(t, err)
+
+	logs, err := eng.GetHistory("plm_task", taskID)
+	assert.NoError(t, err)
+	assert.Len(t, logs, 2)
+}
+
+func TestCompareAndSwapEntityStateStaleVersion(t *testing.T) {
+	eng := setupPLMTaskEngine(t)
+	taskID := uuid.New()
+
+	_, err := eng.Fire("plm_task", taskID, "assign", nil, "u1", "user")
+	assert.NoError(t, err)
+
+	// 模拟两个请求读取到同一版本后先后写入
+	first := &EntityState{EntityType: "plm_task", EntityID: taskID, CurrentState: "in_progress"}
+	swapped, err := eng.repo.CompareAndSwapEntityState(first, 1)
+	assert.NoError(t, err)
+	assert.True(t, swapped)
+
+	second := &EntityState{EntityType: "plm_task", EntityID: taskID, CurrentState: "unassigned"}
+	swapped, err = eng.repo.CompareAndSwapEntityState(second, 1)
+	assert.NoError(t, err)
+	assert.False(t, swapped)
+
+	created, err := eng.repo.CreateEntityState(&EntityState{EntityType: "plm_task", EntityID: taskID, CurrentState: "pending"})
+	assert.NoError(t, err)
+	assert.False(t, created)
+}
+
+// flakyExecutor 对指定类型的动作始终返回错误，直到 failType 被清空
+type flakyExecutor struct {
+	failType string
+	calls    int
+}
+
+func (f *flakyExecutor) Execute(action TransitionAction, ctx ActionContext) error {
+	f.calls++
+	if action.Type == f.failType {
+		return errors.New("feishu unavailable")
+	}
+	return nil
+}
+
+func TestFireEnqueuesActionsInOutbox(t *testing.T) {
+	executor := &flakyExecutor{}
+	eng := NewEngine(setupEngineTestDB(t), executor)
+	if err := eng.RegisterMachine(NewPLMTaskMachine()); err != nil {
+		t.Fatalf("register machine: %v", err)
+	}
+
+	_, err := eng.Fire("plm_task", uuid.New(), "assign", nil, "u1", "user")
+	assert.NoError(t, err)
+	assert.Equal(t, 0, executor.calls, "动作不应在事务内执行")
+
+	entries, total, err := eng.ListOutbox(OutboxStatusPending, "", 1, 20)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), total)
+	assert.Len(t, entries, 2)
+
+	d := NewOutboxDispatcher(eng)
+	assert.Equal(t, 2, d.DispatchOnce())
+	assert.Equal(t, 2, executor.calls)
+
+	_, total, _ = eng.ListOutbox(OutboxStatusSucceeded, "", 1, 20)
+	assert.Equal(t, int64(2), total)
+}
+
+func TestOutboxDeadLetterAndReplay(t *testing.T) {
+	executor := &flakyExecutor{failType: "feishu_update_task"}
+	eng := NewEngine(setupEngineTestDB(t), executor)
+	if err := eng.RegisterMachine(NewPLMTaskMachine()); err != nil {
+		t.Fatalf("register machine: %v", err)
+	}
+	taskID := uuid.New()
+	_, err := eng.Fire("plm_task", taskID, "assign", nil, "u1", "user")
+	assert.NoError(t, err)
+	_, err = eng.Fire("plm_task", taskID, "start", nil, "u1", "user")
+	assert.NoError(t, err)
+
+	d := NewOutboxDispatcher(eng)
+	d.MaxAttempts = 2
+	d.BaseBackoff = 0
+
+	for i := 0; i < 3; i++ {
+		d.DispatchOnce()
+	}
+
+	dead, total, err := eng.ListOutbox(OutboxStatusDead, "", 1, 20)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), total)
+	assert.Equal(t, 2, dead[0].Attempts)
+	assert.Equal(t, "feishu unavailable", dead[0].LastError)
+
+	executor.failType = ""
+	assert.NoError(t, eng.ReplayOutbox(dead[0].ID))
+	assert.Error(t, eng.ReplayOutbox(dead[0].ID), "已重放的动作不应再次重放")
+	assert.Equal(t, 1, d.DispatchOnce())
+
+	_, total, _ = eng.ListOutbox(OutboxStatusDead, "", 1, 20)
+	assert.Equal(t, int64(0), total)
+}
+
+func TestClaimExpiredOutboxOnlyOnce(t *testing.T) {
+	repo := NewRepository(setupEngineTestDB(t))
+	now := time.Now()
+	expired := now.Add(-time.Minute)
+	entry := ActionOutbox{
+		EntityType:    "plm_task",
+		EntityID:      uuid.New(),
+		ActionType:    "notify",
+		Status:        OutboxStatusProcessing,
+		Attempts:      1,
+		NextAttemptAt: now.Add(-time.Hour),
+		LockedUntil:   &expired,
+	}
+	assert.NoError(t, repo.CreateOutboxEntries([]ActionOutbox{entry}))
+
+	// 两个实例在领取前都查到了这条租约过期的记录
+	var stale ActionOutbox
+	assert.NoError(t, repo.DB.First(&stale).Error)
+	ok, err := repo.claimOutboxEntry(stale, now, now.Add(time.Minute))
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	ok, err = repo.claimOutboxEntry(stale, now, now.Add(time.Minute))
+	assert.NoError(t, err)
+	assert.False(t, ok, "租约已被其他实例续上，不应再次领取")
+
+	claimed, err := repo.ClaimDueOutbox(now, time.Minute, 10)
+	assert.NoError(t, err)
+	assert.Empty(t, claimed)
+}
+
+func TestOutboxBackoff(t *testing.T) {
+	d := &OutboxDispatcher{BaseBackoff: 10 * time.Second, MaxBackoff: time.Minute}
+	assert.Equal(t, 10*time.Second, d.backoff(1))
+	assert.Equal(t, 20*time.Second, d.backoff(2))
+	assert.Equal(t, 40*time.Second, d.backoff(3))
+	assert.Equal(t, time.Minute, d.backoff(4))
+}
+
+func TestTimerEscalatesOnceAndCancelsOnLeave(t *testing.T) {
+	eng := setupPLMTaskEngine(t)
+	taskID := uuid.New()
+	for _, ev := range []string{"assign", "start", "submit_review"} {
+		_, err := eng.Fire("plm_task", taskID, ev, nil, "u1", "user")
+		assert.NoError(t, err)
+	}
+
+	timers, err := eng.GetTimers("plm_task", taskID)
+	assert.NoError(t, err)
+	assert.Len(t, timers, 1)
+	assert.Equal(t, "escalate", timers[0].Event)
+
+	scheduler := NewTimerScheduler(eng)
+	assert.Equal(t, 0, scheduler.FireDueOnce(time.Now()), "未到期不应触发")
+	assert.Equal(t, 1, scheduler.FireDueOnce(timers[0].FireAt.Add(time.Second)))
+
+	logs, _ := eng.GetHistory("plm_task", taskID)
+	assert.Equal(t, "escalate", logs[0].Event)
+	assert.Equal(t, "system", logs[0].TriggeredByType)
+
+	// escalate 自环不重新计时，只升级一次
+	timers, _ = eng.GetTimers("plm_task", taskID)
+	assert.Len(t, timers, 1)
+	assert.Equal(t, 0, scheduler.FireDueOnce(time.Now().Add(30*24*time.Hour)))
+
+	_, err = eng.Fire("plm_task", taskID, "approve", nil, "u2", "user")
+	assert.NoError(t, err)
+	timers, _ = eng.GetTimers("plm_task", taskID)
+	assert.Len(t, timers, 1)
+	assert.Equal(t, TimerStatusFired, timers[0].Status)
+
+	// 到期前离开 reviewing，定时器被取消
+	otherID := uuid.New()
+	for _, ev := range []string{"assign", "start", "submit_review", "reject"} {
+		_, err := eng.Fire("plm_task", otherID, ev, nil, "u1", "user")
+		assert.NoError(t, err)
+	}
+	timers, _ = eng.GetTimers("plm_task", otherID)
+	assert.Len(t, timers, 1)
+	assert.Equal(t, TimerStatusCancelled, timers[0].Status)
+}
+
+func TestParseTimerDuration(t *testing.T) {
+	days, rest, err := ParseTimerDuration("1d12h")
+	assert.NoError(t, err)
+	assert.Equal(t, 1, days)
+	assert.Equal(t, 12*time.Hour, rest)
+
+	days, rest, err = ParseTimerDuration("30d")
+	assert.NoError(t, err)
+	assert.Equal(t, 30, days)
+	assert.Equal(t, time.Duration(0), rest)
+
+	_, _, err = ParseTimerDuration("soon")
+	assert.Error(t, err)
+}
+
+func TestWeekdayCalendarSkipsWeekend(t *testing.T) {
+	cal := NewWeekdayCalendar()
+	// 2026-10-16 是周五，17:00 起 2 个工作小时 → 下周一 10:00
+	friday := time.Date(2026, 10, 16, 17, 0, 0, 0, time.UTC)
+	assert.Equal(t, time.Date(2026, 10, 19, 10, 0, 0, 0, time.UTC), cal.AddWorkingHours(friday, 2*time.Hour))
+	assert.Equal(t, time.Date(2026, 10, 19, 17, 0, 0, 0, time.UTC), cal.AddWorkdays(friday, 1))
+
+	// 注入节假日：下周一放假，顺延到周二
+	holiday := time.Date(2026, 10, 19, 0, 0, 0, 0, time.UTC)
+	cal.IsWorkday = func(day time.Time) bool {
+		return isWeekday(day) && !(day.Year() == holiday.Year() && day.YearDay() == holiday.YearDay())
+	}
+	assert.Equal(t, time.Date(2026, 10, 20, 10, 0, 0, 0, time.UTC), cal.AddWorkingHours(friday, 2*time.Hour))
+	assert.Equal(t, time.Date(2026, 10, 20, 17, 0, 0, 0, time.UTC), cal.AddWorkdays(friday, 1))
+}
+
+// plmTaskMachineV2 在 PLM 任务状态机基础上增加 blocked 状态
+func plmTaskMachineV2() *StateMachineDefinition {
+	def := NewPLMTaskMachine()
+	states, _ := def.StateDefinitions()
+	states = append(states, StateDefinition{Name: "blocked", Label: "已阻塞"})
+	def.States, _ = json.Marshal(states)
+	def.Transitions = append(def.Transitions,
+		StateTransition{ID: uuid.New(), FromState: "in_progress", ToState: "blocked", Event: "block"},
+		StateTransition{ID: uuid.New(), FromState: "blocked", ToState: "in_progress", Event: "unblock"},
+	)
+	return def
+}
+
+func TestRegisterMachineVersions(t *testing.T) {
+	eng := setupPLMTaskEngine(t)
+	assert.NoError(t, eng.RegisterMachine(NewPLMTaskMachine()))
+
+	versions, err := eng.ListMachineVersions("plm_task")
+	assert.NoError(t, err)
+	assert.Len(t, versions, 1, "定义未变化不应产生新版本")
+
+	oldTask := uuid.New()
+	for _, ev := range []string{"assign", "start"} {
+		_, err := eng.Fire("plm_task", oldTask, ev, nil, "u1", "user")
+		assert.NoError(t, err)
+	}
+
+	assert.NoError(t, eng.RegisterMachine(plmTaskMachineV2()))
+	versions, _ = eng.ListMachineVersions("plm_task")
+	assert.Len(t, versions, 2)
+	assert.Equal(t, 2, versions[0].Version)
+
+	// 旧实体仍按 v1 流转，新实体使用 v2
+	_, err = eng.Fire("plm_task", oldTask, "block", nil, "u1", "user")
+	assert.Error(t, err)
+
+	newTask := uuid.New()
+	for _, ev := range []string{"assign", "start", "block"} {
+		_, err := eng.Fire("plm_task", newTask, ev, nil, "u1", "user")
+		assert.NoError(t, err)
+	}
+	state, _ := eng.repo.GetEntityState("plm_task", newTask)
+	assert.Equal(t, 2, state.MachineVersion)
+}
+
+func TestMigrateEntities(t *testing.T) {
+	eng := setupPLMTaskEngine(t)
+	pending, working := uuid.New(), uuid.New()
+	_, err := eng.Fire("plm_task", pending, "assign", nil, "u1", "user")
+	assert.NoError(t, err)
+	for _, ev := range []string{"assign", "start"} {
+		_, err := eng.Fire("plm_task", working, ev, nil, "u1", "user")
+		assert.NoError(t, err)
+	}
+	assert.NoError(t, eng.RegisterMachine(plmTaskMachineV2()))
+
+	_, err = eng.MigrateEntities(MigrationPlan{MachineName: "plm_task", FromVersion: 1, StateMap: map[string]string{"pending": "nowhere"}}, true)
+	assert.Error(t, err, "映射到不存在的状态应报错")
+
+	plan := MigrationPlan{MachineName: "plm_task", FromVersion: 1, StateMap: map[string]string{"in_progress": "blocked"}}
+	report, err := eng.MigrateEntities(plan, true)
+	assert.NoError(t, err)
+	assert.True(t, report.DryRun)
+	assert.Equal(t, 2, report.ToVersion)
+	assert.Equal(t, 2, report.Total)
+	counts := map[string]string{}
+	for _, st := range report.States {
+		assert.Equal(t, 1, st.Count)
+		counts[st.FromState] = st.ToState
+	}
+	assert.Equal(t, map[string]string{"pending": "pending", "in_progress": "blocked"}, counts)
+
+	state, _ := eng.repo.GetEntityState("plm_task", working)
+	assert.Equal(t, "in_progress", state.CurrentState, "dry-run 不应修改实体")
+
+	_, err = eng.MigrateEntities(plan, false)
+	assert.NoError(t, err)
+
+	state, _ = eng.repo.GetEntityState("plm_task", working)
+	assert.Equal(t, "blocked", state.CurrentState)
+	assert.Equal(t, 2, state.MachineVersion)
+	logs, _ := eng.GetHistory("plm_task", working)
+	assert.Equal(t, "migrate_version", logs[0].Event)
+
+	// 迁移后的实体按 v2 流转
+	_, err = eng.Fire("plm_task", pending, "start", nil, "u1", "user")
+	assert.NoError(t, err)
+	_, err = eng.Fire("plm_task", pending, "block", nil, "u1", "user")
+	assert.NoError(t, err)
+}
+
+func TestFireInTxByTargetStateAdoptsInitialState(t *testing.T) {
+	eng := NewEngine(setupEngineTestDB(t), nil)
+	assert.NoError(t, eng.RegisterMachine(NewSRMPurchaseOrderMachine()))
+	poID := EntityUUID("po-legacy-0001")
+	assert.Equal(t, poID, EntityUUID("po-legacy-0001"))
+
+	fire := func(req FireRequest) (*TransitionLog, error) {
+		var log *TransitionLog
+		err := eng.DB.Transaction(func(tx *gorm.DB) error {
+			var err error
+			log, err = eng.FireInTx(tx, req)
+			return err
+		})
+		return log, err
+	}
+
+	// 存量数据没有引擎记录：以业务状态作为起始状态，按目标状态反查事件
+	log, err := fire(FireRequest{EntityType: "srm_purchase_order", EntityID: poID, TargetState: "sent", ExpectedState: "approved", InitialState: "approved"})
+	assert.NoError(t, err)
+	assert.Equal(t, "send", log.Event)
+	assert.Equal(t, "approved", log.FromState)
+
+	_, err = fire(FireRequest{EntityType: "srm_purchase_order", EntityID: poID, TargetState: "completed"})
+	assert.Error(t, err)
+
+	// 目标状态与条件选中的规则不一致时拒绝
+	_, err = fire(FireRequest{EntityType: "srm_purchase_order", EntityID: poID, TargetState: "received",
+		EventData: map[string]interface{}{"fully_received": false}})
+	assert.Error(t, err)
+	log, err = fire(FireRequest{EntityType: "srm_purchase_order", EntityID: poID, Event: "receive",
+		EventData: map[string]interface{}{"fully_received": true}})
+	assert.NoError(t, err)
+	assert.Equal(t, "received", log.ToState)
+
+	_, err = fire(FireRequest{EntityType: "srm_purchase_order", EntityID: uuid.New(), Event: "submit", InitialState: "unknown"})
+	assert.Error(t, err)
+}
@@ -0,0 +1,317 @@
+package engine
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// =============================================================================
+// 基于转换日志的状态回溯 — 时间点查询 / 历史时间线 / 状态重建
+// =============================================================================
+
+// StateSnapshot 实体在某一时刻的状态
+type StateSnapshot struct {
+	EntityType  string     `json:"entity_type"`
+	EntityID    uuid.UUID  `json:"entity_id"`
+	At          time.Time  `json:"at"`
+	Exists      bool       `json:"exists"`                 // 该时刻实体是否已有状态记录
+	State       string     `json:"state,omitempty"`        // 该时刻所处状态
+	Since       *time.Time `json:"since,omitempty"`        // 进入该状态的时间
+	Event       string     `json:"event,omitempty"`        // 进入该状态的事件
+	TriggeredBy string     `json:"triggered_by,omitempty"` // 进入该状态的操作人
+}
+
+// StateAt 查询实体在指定时刻所处的状态（按转换日志回放）
+// 该时刻之前没有任何日志时 Exists=false
+func (e *Engine) StateAt(entityType string, entityID uuid.UUID, at time.Time) (*StateSnapshot, error) {
+	logs, err := e.repo.ListTransitionLogsChronological(entityType, entityID)
+	if err != nil {
+		return nil, err
+	}
+
+	snapshot := &StateSnapshot{EntityType: entityType, EntityID: entityID, At: at}
+	for i := range logs {
+		if logs[i].CreatedAt.After(at) {
+			break
+		}
+		// 自转换（如定时提醒）不改变状态，Since 保留最早进入该状态的时间
+		if snapshot.Exists && logs[i].ToState == snapshot.State {
+			continue
+		}
+		entered := logs[i].CreatedAt
+		snapshot.Exists = true
+		snapshot.State = logs[i].ToState
+		snapshot.Since = &entered
+		snapshot.Event = logs[i].Event
+		snapshot.TriggeredBy = logs[i].TriggeredBy
+	}
+	return snapshot, nil
+}
+
+// StateSegment 时间线中的一段状态停留
+type StateSegment struct {
+	State           string     `json:"state"`
+	Event           string     `json:"event"` // 进入事件
+	TriggeredBy     string     `json:"triggered_by,omitempty"`
+	EnteredAt       time.Time  `json:"entered_at"`
+	ExitedAt        *time.Time `json:"exited_at,omitempty"` // 为空表示仍处于该状态
+	ExitEvent       string     `json:"exit_event,omitempty"`
+	DurationSeconds int64      `json:"duration_seconds"`
+	Duration        string     `json:"duration"`
+}
+
+// StateDuration 某状态的累计停留
+type StateDuration struct {
+	State           string `json:"state"`
+	Visits          int    `json:"visits"` // 进入次数
+	DurationSeconds int64  `json:"duration_seconds"`
+	Duration        string `json:"duration"`
+}
+
+// EntityTimeline 实体状态历史时间线
+type EntityTimeline struct {
+	EntityType   string          `json:"entity_type"`
+	EntityID     uuid.UUID       `json:"entity_id"`
+	CurrentState string          `json:"current_state"`
+	Segments     []StateSegment  `json:"segments"`
+	Totals       []StateDuration `json:"totals"` // 按首次进入顺序
+}
+
+// Timeline 生成实体状态历史时间线及各状态停留时长
+// 连续的自转换合并为一段；当前所处状态的停留时长计算到 now
+func (e *Engine) Timeline(entityType string, entityID uuid.UUID, now time.Time) (*EntityTimeline, error) {
+	logs, err := e.repo.ListTransitionLogsChronological(entityType, entityID)
+	if err != nil {
+		return nil, err
+	}
+
+	timeline := &EntityTimeline{
+		EntityType: entityType,
+		EntityID:   entityID,
+		Segments:   []StateSegment{},
+		Totals:     []StateDuration{},
+	}
+	for _, l := range logs {
+		n := len(timeline.Segments)
+		if n > 0 && timeline.Segments[n-1].State == l.ToState {
+			continue
+		}
+		if n > 0 {
+			exited := l.CreatedAt
+			timeline.Segments[n-1].ExitedAt = &exited
+			timeline.Segments[n-1].ExitEvent = l.Event
+		}
+		timeline.Segments = append(timeline.Segments, StateSegment{
+			State:       l.ToState,
+			Event:       l.Event,
+			TriggeredBy: l.TriggeredBy,
+			EnteredAt:   l.CreatedAt,
+		})
+	}
+
+	totals := make(map[string]int)
+	for i := range timeline.Segments {
+		seg := &timeline.Segments[i]
+		end := now
+		if seg.ExitedAt != nil {
+			end = *seg.ExitedAt
+		}
+		d := end.Sub(seg.EnteredAt)
+		if d < 0 {
+			d = 0
+		}
+		seg.DurationSeconds = int64(d / time.Second)
+		seg.Duration = d.Round(time.Second).String()
+
+		idx, ok := totals[seg.State]
+		if !ok {
+			idx = len(timeline.Totals)
+			totals[seg.State] = idx
+			timeline.Totals = append(timeline.Totals, StateDuration{State: seg.State})
+		}
+		timeline.Totals[idx].Visits++
+		timeline.Totals[idx].DurationSeconds += seg.DurationSeconds
+	}
+	for i := range timeline.Totals {
+		timeline.Totals[i].Duration = (time.Duration(timeline.Totals[i].DurationSeconds) * time.Second).String()
+	}
+	if n := len(timeline.Segments); n > 0 {
+		timeline.CurrentState = timeline.Segments[n-1].State
+	}
+	return timeline, nil
+}
+
+// =============================================================================
+// 状态重建
+// =============================================================================
+
+// 重建差异类型
+const (
+	RebuildMismatch    = "mismatch"     // 记录状态与日志回放结果不一致
+	RebuildMissing     = "missing"      // 有日志但没有状态记录
+	RebuildOrphan      = "orphan"       // 有状态记录但没有任何日志（仅报告）
+	RebuildBrokenChain = "broken_chain" // 日志前后衔接不上（仅报告）
+)
+
+// RebuildDiscrepancy 重建时发现的差异
+type RebuildDiscrepancy struct {
+	EntityType   string    `json:"entity_type"`
+	EntityID     uuid.UUID `json:"entity_id"`
+	Kind         string    `json:"kind"`
+	StoredState  string    `json:"stored_state,omitempty"`
+	RebuiltState string    `json:"rebuilt_state,omitempty"`
+	Detail       string    `json:"detail,omitempty"`
+	Fixed        bool      `json:"fixed"`
+}
+
+// RebuildReport 状态重建报告
+type RebuildReport struct {
+	EntityType    string               `json:"entity_type,omitempty"`
+	DryRun        bool                 `json:"dry_run"`
+	Scanned       int                  `json:"scanned"`
+	Consistent    int                  `json:"consistent"`
+	Fixed         int                  `json:"fixed"`
+	Discrepancies []RebuildDiscrepancy `json:"discrepancies"`
+}
+
+// RebuildEntityStates 按转换日志回放重建 entity_states，并报告与现有记录不一致的实体
+// entityType 为空时处理全部类型；dryRun=true 时只报告不修改。
+// 修复以实体为单位在各自事务中进行（CAS 更新，期间被修改的实体跳过），并按新状态重排定时器；
+// 没有日志的状态记录和日志断链只报告，不做修改
+func (e *Engine) RebuildEntityStates(entityType string, dryRun bool) (*RebuildReport, error) {
+	entities, err := e.repo.ListLoggedEntities(entityType)
+	if err != nil {
+		return nil, err
+	}
+	states, err := e.repo.ListEntityStates(entityType)
+	if err != nil {
+		return nil, err
+	}
+	stored := make(map[LoggedEntity]EntityState, len(states))
+	for _, st := range states {
+		stored[LoggedEntity{EntityType: st.EntityType, EntityID: st.EntityID}] = st
+	}
+
+	report := &RebuildReport{EntityType: entityType, DryRun: dryRun, Discrepancies: []RebuildDiscrepancy{}}
+	for _, ent := range entities {
+		report.Scanned++
+		logs, err := e.repo.ListTransitionLogsChronological(ent.EntityType, ent.EntityID)
+		if err != nil {
+			return nil, err
+		}
+		if len(logs) == 0 {
+			continue
+		}
+		rebuilt := logs[len(logs)-1].ToState
+
+		for i := 1; i < len(logs); i++ {
+			if logs[i].FromState != "" && logs[i].FromState != logs[i-1].ToState {
+				report.Discrepancies = append(report.Discrepancies, RebuildDiscrepancy{
+					EntityType:   ent.EntityType,
+					EntityID:     ent.EntityID,
+					Kind:         RebuildBrokenChain,
+					RebuiltState: rebuilt,
+					Detail: fmt.Sprintf("日志 %s 的起始状态 %s 与上一条的目标状态 %s 不一致",
+						logs[i].ID, logs[i].FromState, logs[i-1].ToState),
+				})
+				break
+			}
+		}
+
+		current, exists := stored[ent]
+		delete(stored, ent)
+		if exists && current.CurrentState == rebuilt {
+			report.Consistent++
+			continue
+		}
+
+		d := RebuildDiscrepancy{EntityType: ent.EntityType, EntityID: ent.EntityID, RebuiltState: rebuilt}
+		if exists {
+			d.Kind = RebuildMismatch
+			d.StoredState = current.CurrentState
+		} else {
+			d.Kind = RebuildMissing
+		}
+		if !dryRun {
+			var existing *EntityState
+			if exists {
+				existing = &current
+			}
+			if err := e.repairEntityState(ent, existing, rebuilt); err != nil {
+				d.Detail = err.Error()
+			} else {
+				d.Fixed = true
+				report.Fixed++
+			}
+		}
+		report.Discrepancies = append(report.Discrepancies, d)
+	}
+
+	for _, st := range states {
+		key := LoggedEntity{EntityType: st.EntityType, EntityID: st.EntityID}
+		if _, ok := stored[key]; !ok {
+			continue
+		}
+		report.Scanned++
+		report.Discrepancies = append(report.Discrepancies, RebuildDiscrepancy{
+			EntityType:  st.EntityType,
+			EntityID:    st.EntityID,
+			Kind:        RebuildOrphan,
+			StoredState: st.CurrentState,
+			Detail:      "没有转换日志，无法重建",
+		})
+	}
+
+	log.Printf("[StateEngine] 状态重建: entity_type=%s dry_run=%v scanned=%d discrepancies=%d fixed=%d",
+		entityType, dryRun, report.Scanned, len(report.Discrepancies), report.Fixed)
+	return report, nil
+}
+
+// repairEntityState 将实体状态修正为日志回放结果
+// 已有记录沿用其固定的状态机版本；缺失记录按最新版本创建
+func (e *Engine) repairEntityState(ent LoggedEntity, existing *EntityState, rebuilt string) error {
+	latest, err := e.findMachineForEntity(ent.EntityType)
+	if err != nil {
+		return fmt.Errorf("未找到实体类型 [%s] 对应的状态机: %w", ent.EntityType, err)
+	}
+
+	return e.DB.Transaction(func(tx *gorm.DB) error {
+		txRepo := NewRepository(tx)
+		machine, err := e.pinnedMachine(txRepo, latest, existing)
+		if err != nil {
+			return err
+		}
+
+		now := time.Now()
+		state := &EntityState{
+			EntityType:     ent.EntityType,
+			EntityID:       ent.EntityID,
+			CurrentState:   rebuilt,
+			MachineID:      machine.ID,
+			MachineVersion: machine.Version,
+			UpdatedAt:      now,
+		}
+		if existing == nil {
+			created, err := txRepo.CreateEntityState(state)
+			if err != nil {
+				return err
+			}
+			if !created {
+				return fmt.Errorf("%w: entity=%s/%s 重建期间已被创建", ErrConcurrentTransition, ent.EntityType, ent.EntityID.String())
+			}
+		} else {
+			swapped, err := txRepo.CompareAndSwapEntityState(state, existing.Version)
+			if err != nil {
+				return err
+			}
+			if !swapped {
+				return fmt.Errorf("%w: entity=%s/%s 重建期间发生变化", ErrConcurrentTransition, ent.EntityType, ent.EntityID.String())
+			}
+		}
+		return e.rescheduleTimers(txRepo, machine, ent.EntityType, ent.EntityID, rebuilt, now)
+	})
+}
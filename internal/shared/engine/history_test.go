@@ -0,0 +1,116 @@
+package engine
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func seedTransitionLogs(t *testing.T, eng *Engine, entityID uuid.UUID, base time.Time) {
+	t.Helper()
+	steps := []struct {
+		from, to, event string
+		offset          time.Duration
+	}{
+		{"", "unassigned", "init", 0},
+		{"unassigned", "pending", "assign", time.Hour},
+		{"pending", "in_progress", "start", 3 * time.Hour},
+		{"in_progress", "in_progress", "remind", 4 * time.Hour},
+		{"in_progress", "reviewing", "submit_review", 10 * time.Hour},
+	}
+	for _, s := range steps {
+		assert.NoError(t, eng.repo.SaveTransitionLog(&TransitionLog{
+			EntityType: "plm_task",
+			EntityID:   entityID,
+			FromState:  s.from,
+			ToState:    s.to,
+			Event:      s.event,
+			CreatedAt:  base.Add(s.offset),
+		}))
+	}
+}
+
+func TestStateAtAndTimeline(t *testing.T) {
+	eng := setupPLMTaskEngine(t)
+	taskID := uuid.New()
+	base := time.Date(2026, 3, 2, 9, 0, 0, 0, time.UTC)
+	seedTransitionLogs(t, eng, taskID, base)
+
+	snap, err := eng.StateAt("plm_task", taskID, base.Add(-time.Minute))
+	assert.NoError(t, err)
+	assert.False(t, snap.Exists)
+
+	snap, err = eng.StateAt("plm_task", taskID, base.Add(5*time.Hour))
+	assert.NoError(t, err)
+	assert.True(t, snap.Exists)
+	assert.Equal(t, "in_progress", snap.State)
+	assert.Equal(t, "start", snap.Event, "自转换不改变进入时间")
+	assert.True(t, snap.Since.Equal(base.Add(3*time.Hour)))
+
+	timeline, err := eng.Timeline("plm_task", taskID, base.Add(12*time.Hour))
+	assert.NoError(t, err)
+	assert.Equal(t, "reviewing", timeline.CurrentState)
+	if assert.Len(t, timeline.Segments, 4) {
+		assert.Equal(t, int64(7*3600), timeline.Segments[2].DurationSeconds)
+		assert.Equal(t, "submit_review", timeline.Segments[2].ExitEvent)
+		assert.Nil(t, timeline.Segments[3].ExitedAt)
+		assert.Equal(t, "2h0m0s", timeline.Segments[3].Duration)
+	}
+	assert.Equal(t, "pending", timeline.Totals[1].State)
+	assert.Equal(t, int64(2*3600), timeline.Totals[1].DurationSeconds)
+}
+
+func TestRebuildEntityStates(t *testing.T) {
+	eng := setupPLMTaskEngine(t)
+
+	// 正常流转后被篡改的实体
+	tampered := uuid.New()
+	for _, ev := range []string{"assign", "start"} {
+		_, err := eng.Fire("plm_task", tampered, ev, nil, "u1", "user")
+		assert.NoError(t, err)
+	}
+	assert.NoError(t, eng.DB.Model(&EntityState{}).
+		Where("entity_id = ?", tampered).Update("current_state", "pending").Error)
+
+	// 只有日志、没有状态记录的实体
+	missing := uuid.New()
+	seedTransitionLogs(t, eng, missing, time.Now().Add(-24*time.Hour))
+
+	// 只有状态记录的实体
+	orphan := uuid.New()
+	assert.NoError(t, eng.repo.SaveEntityState(&EntityState{EntityType: "plm_task", EntityID: orphan, CurrentState: "pending"}))
+
+	report, err := eng.RebuildEntityStates("plm_task", true)
+	assert.NoError(t, err)
+	assert.Equal(t, 3, report.Scanned)
+	assert.Equal(t, 0, report.Fixed)
+	kinds := map[uuid.UUID]string{}
+	for _, d := range report.Discrepancies {
+		kinds[d.EntityID] = d.Kind
+	}
+	assert.Equal(t, RebuildMismatch, kinds[tampered])
+	assert.Equal(t, RebuildMissing, kinds[missing])
+	assert.Equal(t, RebuildOrphan, kinds[orphan])
+
+	state, _ := eng.repo.GetEntityState("plm_task", tampered)
+	assert.Equal(t, "pending", state.CurrentState, "dry run 不修改")
+
+	report, err = eng.RebuildEntityStates("plm_task", false)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, report.Fixed)
+
+	state, _ = eng.repo.GetEntityState("plm_task", tampered)
+	assert.Equal(t, "in_progress", state.CurrentState)
+	assert.Equal(t, int64(3), state.Version)
+	state, _ = eng.repo.GetEntityState("plm_task", missing)
+	if assert.NotNil(t, state) {
+		assert.Equal(t, "reviewing", state.CurrentState)
+	}
+
+	report, err = eng.RebuildEntityStates("plm_task", true)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, report.Consistent)
+	assert.Len(t, report.Discrepancies, 1)
+}
@@ -0,0 +1,311 @@
+package engine
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/bitfantasy/nimo/internal/shared/expr"
+	"github.com/google/uuid"
+	"gopkg.in/yaml.v3"
+)
+
+// =============================================================================
+// 声明式状态机文件 — 从 configs/state_machines 加载 YAML/JSON 定义
+// =============================================================================
+
+// MachineFile 状态机文件格式（YAML 与 JSON 字段一致）
+//
+//	name: plm_task
+//	initial_state: unassigned
+//	states:
+//	  - {name: unassigned, label: 待指派}
+//	  - {name: completed, label: 已完成, is_final: true}
+//	transitions:
+//	  - from_state: unassigned
+//	    to_state: pending
+//	    event: assign
+//	    actions:
+//	      - {type: notify_users, config: {message: 您有新的任务待处理}}
+type MachineFile struct {
+	Name         string            `json:"name"`
+	Description  string            `json:"description"`
+	InitialState string            `json:"initial_state"`
+	States       []StateDefinition `json:"states"`
+	Transitions  []struct {
+		FromState   string             `json:"from_state"`
+		ToState     string             `json:"to_state"`
+		Event       string             `json:"event"`
+		Condition   json.RawMessage    `json:"condition"`
+		Actions     []TransitionAction `json:"actions"`
+		Priority    int                `json:"priority"`
+		Description string             `json:"description"`
+	} `json:"transitions"`
+}
+
+// ParseMachine 解析状态机定义并做静态校验
+// format: yaml / json（yml 视为 yaml）
+func ParseMachine(data []byte, format string) (*StateMachineDefinition, error) {
+	switch strings.ToLower(format) {
+	case "yaml", "yml":
+		// YAML 先转为通用结构再走 JSON 解码，两种格式共用一套字段名
+		var raw interface{}
+		if err := yaml.Unmarshal(data, &raw); err != nil {
+			return nil, fmt.Errorf("解析 YAML 失败: %w", err)
+		}
+		converted, err := json.Marshal(raw)
+		if err != nil {
+			return nil, fmt.Errorf("解析 YAML 失败: %w", err)
+		}
+		data = converted
+	case "json":
+	default:
+		return nil, fmt.Errorf("不支持的状态机文件格式: %s", format)
+	}
+
+	var file MachineFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("解析状态机定义失败: %w", err)
+	}
+
+	statesJSON, err := json.Marshal(file.States)
+	if err != nil {
+		return nil, err
+	}
+	def := &StateMachineDefinition{
+		ID:           uuid.New(),
+		Name:         file.Name,
+		Description:  file.Description,
+		InitialState: file.InitialState,
+		States:       statesJSON,
+	}
+	for _, t := range file.Transitions {
+		st := StateTransition{
+			ID:          uuid.New(),
+			FromState:   t.FromState,
+			ToState:     t.ToState,
+			Event:       t.Event,
+			Condition:   compactJSON(t.Condition),
+			Priority:    t.Priority,
+			Description: t.Description,
+		}
+		if len(t.Actions) > 0 {
+			if st.Actions, err = json.Marshal(t.Actions); err != nil {
+				return nil, err
+			}
+		}
+		def.Transitions = append(def.Transitions, st)
+	}
+
+	if err := ValidateMachine(def); err != nil {
+		return nil, err
+	}
+	return def, nil
+}
+
+// LoadMachineFile 从文件加载状态机定义（按扩展名识别格式）
+func LoadMachineFile(path string) (*StateMachineDefinition, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("读取状态机文件失败: %w", err)
+	}
+	def, err := ParseMachine(data, strings.TrimPrefix(filepath.Ext(path), "."))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return def, nil
+}
+
+// LoadMachineDir 加载目录下所有 .yaml/.yml/.json 状态机文件（按文件名排序）
+// 目录不存在时返回空列表；同名状态机重复定义视为错误
+func LoadMachineDir(dir string) ([]*StateMachineDefinition, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("读取状态机目录失败: %w", err)
+	}
+
+	var names []string
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		switch strings.ToLower(filepath.Ext(entry.Name())) {
+		case ".yaml", ".yml", ".json":
+			names = append(names, entry.Name())
+		}
+	}
+	sort.Strings(names)
+
+	var defs []*StateMachineDefinition
+	seen := make(map[string]string)
+	for _, name := range names {
+		path := filepath.Join(dir, name)
+		def, err := LoadMachineFile(path)
+		if err != nil {
+			return nil, err
+		}
+		if prev, ok := seen[def.Name]; ok {
+			return nil, fmt.Errorf("状态机 [%s] 重复定义: %s, %s", def.Name, prev, path)
+		}
+		seen[def.Name] = path
+		defs = append(defs, def)
+	}
+	return defs, nil
+}
+
+// =============================================================================
+// 静态校验
+// =============================================================================
+
+// knownActionTypes 已知动作类型 → 说明
+var knownActionTypes = map[string]string{
+	"feishu_create_task":     "为执行人创建飞书任务",
+	"feishu_update_task":     "更新飞书任务状态",
+	"feishu_create_approval": "发起飞书审批",
+	"notify_users":           "发送通知",
+	"notify_supplier":        "通知供应商",
+	"start_dependent_tasks":  "启动依赖任务",
+}
+
+// RegisterActionType 登记动作类型，供状态机校验识别（应在启动阶段调用）
+func RegisterActionType(actionType, description string) {
+	knownActionTypes[actionType] = description
+}
+
+// ValidationError 状态机静态校验错误
+type ValidationError struct {
+	Machine string   `json:"machine"`
+	Issues  []string `json:"issues"`
+}
+
+func (e *ValidationError) Error() string {
+	return fmt.Sprintf("状态机 [%s] 校验失败: %s", e.Machine, strings.Join(e.Issues, "; "))
+}
+
+// ValidateMachine 静态校验状态机定义
+// 检查: 状态引用、条件表达式类型、不可达状态、非终态死胡同、同一 (from_state, event) 优先级相同的重复规则、
+// 未知动作类型、定时器配置；发现问题时返回 *ValidationError
+func ValidateMachine(def *StateMachineDefinition) error {
+	var issues []string
+	addf := func(format string, args ...interface{}) {
+		issues = append(issues, fmt.Sprintf(format, args...))
+	}
+
+	if def.Name == "" {
+		addf("状态机名称不能为空")
+	}
+	states, err := def.StateDefinitions()
+	if err != nil {
+		return &ValidationError{Machine: def.Name, Issues: []string{err.Error()}}
+	}
+
+	stateByName := make(map[string]StateDefinition, len(states))
+	for _, st := range states {
+		if st.Name == "" {
+			addf("存在未命名的状态")
+			continue
+		}
+		if _, dup := stateByName[st.Name]; dup {
+			addf("状态 %s 重复定义", st.Name)
+		}
+		stateByName[st.Name] = st
+	}
+	if def.InitialState == "" {
+		addf("初始状态不能为空")
+	} else if _, ok := stateByName[def.InitialState]; !ok {
+		addf("初始状态 %s 未在状态列表中定义", def.InitialState)
+	}
+
+	outgoing := make(map[string][]string) // from → 可到达的其他状态
+	type ruleKey struct {
+		from, event string
+		priority    int
+	}
+	rules := make(map[ruleKey]int)
+	events := make(map[string]map[string]bool) // from → 可处理的事件
+	for _, t := range def.Transitions {
+		if _, ok := stateByName[t.FromState]; !ok {
+			addf("转换 %s --%s--> %s 的起始状态未定义", t.FromState, t.Event, t.ToState)
+		}
+		if _, ok := stateByName[t.ToState]; !ok {
+			addf("转换 %s --%s--> %s 的目标状态未定义", t.FromState, t.Event, t.ToState)
+		}
+		if t.Event == "" {
+			addf("转换 %s --> %s 缺少事件名", t.FromState, t.ToState)
+		}
+		if t.ToState != t.FromState {
+			outgoing[t.FromState] = append(outgoing[t.FromState], t.ToState)
+		}
+		if events[t.FromState] == nil {
+			events[t.FromState] = make(map[string]bool)
+		}
+		events[t.FromState][t.Event] = true
+
+		key := ruleKey{t.FromState, t.Event, t.Priority}
+		rules[key]++
+		if rules[key] == 2 {
+			addf("状态 %s 的事件 %s 存在多条优先级相同（%d）的规则，匹配顺序不确定", t.FromState, t.Event, t.Priority)
+		}
+
+		if err := expr.ValidateCondition(t.Condition); err != nil {
+			addf("转换 %s --%s--> %s 的条件无效: %v", t.FromState, t.Event, t.ToState, err)
+		}
+
+		if len(t.Actions) > 0 && string(t.Actions) != "null" {
+			var actions []TransitionAction
+			if err := json.Unmarshal(t.Actions, &actions); err != nil {
+				addf("转换 %s --%s--> %s 的动作列表格式错误", t.FromState, t.Event, t.ToState)
+			}
+			for _, a := range actions {
+				if _, ok := knownActionTypes[a.Type]; !ok {
+					addf("转换 %s --%s--> %s 使用了未知动作类型 %s", t.FromState, t.Event, t.ToState, a.Type)
+				}
+			}
+		}
+	}
+
+	// 从初始状态出发的可达性
+	reachable := map[string]bool{def.InitialState: true}
+	queue := []string{def.InitialState}
+	for len(queue) > 0 {
+		cur := queue[0]
+		queue = queue[1:]
+		for _, next := range outgoing[cur] {
+			if !reachable[next] {
+				reachable[next] = true
+				queue = append(queue, next)
+			}
+		}
+	}
+
+	for _, st := range states {
+		if st.Name == "" {
+			continue
+		}
+		if !reachable[st.Name] {
+			addf("状态 %s 从初始状态不可达", st.Name)
+		}
+		if !st.IsFinal && len(outgoing[st.Name]) == 0 {
+			addf("非终态 %s 没有离开的转换（死胡同）", st.Name)
+		}
+		for _, timer := range st.Timers {
+			if _, _, err := ParseTimerDuration(timer.After); err != nil {
+				addf("状态 %s 的定时器 %s 时长无效: %v", st.Name, timer.Event, err)
+			}
+			if !events[st.Name][timer.Event] {
+				addf("状态 %s 的定时器事件 %s 没有对应的转换规则", st.Name, timer.Event)
+			}
+		}
+	}
+
+	if len(issues) > 0 {
+		return &ValidationError{Machine: def.Name, Issues: issues}
+	}
+	return nil
+}
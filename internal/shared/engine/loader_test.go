@@ -0,0 +1,148 @@
+package engine
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+const reviewMachineYAML = `
+name: doc_review
+description: 文档评审
+initial_state: draft
+states:
+  - {name: draft, label: 草稿}
+  - {name: reviewing, label: 评审中, timers: [{event: remind, after: 1d}]}
+  - {name: approved, label: 已通过, is_final: true}
+transitions:
+  - {from_state: draft, to_state: reviewing, event: submit}
+  - from_state: reviewing
+    to_state: approved
+    event: approve
+    condition: {field: score, op: gte, value: 60}
+    priority: 10
+    actions:
+      - {type: notify_users, config: {message: 评审通过}}
+  - {from_state: reviewing, to_state: draft, event: approve}
+  - {from_state: reviewing, to_state: reviewing, event: remind}
+`
+
+func TestParseMachineYAML(t *testing.T) {
+	def, err := ParseMachine([]byte(reviewMachineYAML), "yaml")
+	assert.NoError(t, err)
+	assert.Equal(t, "doc_review", def.Name)
+	assert.Len(t, def.Transitions, 4)
+
+	states, _ := def.StateDefinitions()
+	assert.True(t, states[2].IsFinal)
+	assert.Equal(t, "1d", states[1].Timers[0].After)
+
+	eng := NewEngine(setupEngineTestDB(t), nil)
+	assert.NoError(t, eng.RegisterMachine(def))
+
+	// 条件求值出错应返回错误，而不是回落到低优先级规则
+	docID := uuid.New()
+	_, err = eng.Fire("doc_review", docID, "submit", nil, "u1", "user")
+	assert.NoError(t, err)
+	_, err = eng.Fire("doc_review", docID, "approve", map[string]interface{}{"score": true}, "u1", "user")
+	assert.Error(t, err)
+	log, err := eng.Fire("doc_review", docID, "approve", map[string]interface{}{"score": 75}, "u1", "user")
+	assert.NoError(t, err)
+	assert.Equal(t, "approved", log.ToState)
+}
+
+func TestValidateMachineRejectsBadCondition(t *testing.T) {
+	def, err := ParseMachine([]byte(reviewMachineYAML), "yaml")
+	assert.NoError(t, err)
+	def.Transitions[1].Condition = []byte(`"score >= 'high' + 1"`)
+	assert.Error(t, ValidateMachine(def))
+}
+
+func TestValidateMachineReportsIssues(t *testing.T) {
+	src := `{
+		"name": "broken",
+		"initial_state": "a",
+		"states": [{"name": "a"}, {"name": "b"}, {"name": "orphan", "is_final": true}],
+		"transitions": [
+			{"from_state": "a", "to_state": "b", "event": "go"},
+			{"from_state": "a", "to_state": "a", "event": "go", "actions": [{"type": "launch_rocket"}]}
+		]
+	}`
+	_, err := ParseMachine([]byte(src), "json")
+
+	var verr *ValidationError
+	assert.True(t, errors.As(err, &verr))
+	joined := strings.Join(verr.Issues, "\n")
+	assert.Contains(t, joined, "orphan 从初始状态不可达")
+	assert.Contains(t, joined, "非终态 b 没有离开的转换")
+	assert.Contains(t, joined, "事件 go 存在多条优先级相同")
+	assert.Contains(t, joined, "未知动作类型 launch_rocket")
+}
+
+func TestBuiltinMachineIsValid(t *testing.T) {
+	assert.NoError(t, ValidateMachine(NewPLMTaskMachine()))
+}
+
+func TestSampleMachineMatchesBuiltin(t *testing.T) {
+	data, err := os.ReadFile("../../../configs/state_machines/plm_task.yaml.example")
+	assert.NoError(t, err)
+	def, err := ParseMachine(data, "yaml")
+	assert.NoError(t, err)
+
+	builtin := NewPLMTaskMachine()
+	assert.Equal(t, builtin.Name, def.Name)
+	assert.Equal(t, builtin.InitialState, def.InitialState)
+	assert.JSONEq(t, string(builtin.States), string(def.States))
+	assert.Len(t, def.Transitions, len(builtin.Transitions))
+	for i, tr := range builtin.Transitions {
+		assert.Equal(t, tr.FromState+"-"+tr.Event+"->"+tr.ToState, def.Transitions[i].FromState+"-"+def.Transitions[i].Event+"->"+def.Transitions[i].ToState)
+		assert.Equal(t, tr.Priority, def.Transitions[i].Priority)
+	}
+}
+
+func TestLoadMachineDirRejectsDuplicates(t *testing.T) {
+	dir := t.TempDir()
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "a.yaml"), []byte(reviewMachineYAML), 0o644))
+
+	defs, err := LoadMachineDir(dir)
+	assert.NoError(t, err)
+	assert.Len(t, defs, 1)
+
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "b.yml"), []byte(reviewMachineYAML), 0o644))
+	_, err = LoadMachineDir(dir)
+	assert.Error(t, err)
+
+	defs, err = LoadMachineDir(filepath.Join(dir, "missing"))
+	assert.NoError(t, err)
+	assert.Empty(t, defs)
+}
+
+func TestRenderDiagram(t *testing.T) {
+	def, err := ParseMachine([]byte(reviewMachineYAML), "yaml")
+	assert.NoError(t, err)
+
+	mermaid, err := RenderDiagram(def, DiagramMermaid)
+	assert.NoError(t, err)
+	assert.Contains(t, mermaid, "stateDiagram-v2")
+	assert.Contains(t, mermaid, "[*] --> draft")
+	assert.Contains(t, mermaid, "reviewing --> approved: approve [score >= 60] (p=10)")
+	assert.Contains(t, mermaid, "approved --> [*]")
+
+	dot, err := RenderDiagram(def, DiagramGraphviz)
+	assert.NoError(t, err)
+	assert.Contains(t, dot, `"draft" -> "reviewing" [label="submit"];`)
+
+	_, err = RenderDiagram(def, "png")
+	assert.Error(t, err)
+}
+
+func TestSRMMachinesAreValid(t *testing.T) {
+	for _, def := range []*StateMachineDefinition{NewSRMPurchaseOrderMachine(), NewSRMPRItemMachine(), NewSRMSamplingMachine()} {
+		assert.NoError(t, ValidateMachine(def), def.Name)
+	}
+}
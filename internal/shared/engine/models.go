@@ -3,6 +3,7 @@ package engine
 import (
 	"database/sql/driver"
 	"encoding/json"
+	"fmt"
 	"time"
 
 	"github.com/google/uuid"
@@ -41,14 +42,18 @@ func (j *JSONB) Scan(value interface{}) error {
 // =============================================================================
 
 // StateMachineDefinition 状态机定义，可复用于 PLM/ERP/WMS
+// 同名定义按版本存储，已注册的版本不可修改；定义变化时注册为新版本，
+// 已有实体固定在其开始时的版本上，需通过 MigrateEntities 显式迁移
 type StateMachineDefinition struct {
-	ID           uuid.UUID        `json:"id" gorm:"type:uuid;primaryKey"`
-	Name         string           `json:"name" gorm:"size:100;not null;uniqueIndex"`          // 如: plm_task, purchase_order
-	Description  string           `json:"description" gorm:"type:text"`                       // 描述
-	InitialState string           `json:"initial_state" gorm:"size:50;not null"`              // 初始状态
-	States       json.RawMessage  `json:"states" gorm:"type:jsonb"`                           // 状态列表及属性
-	CreatedAt    time.Time        `json:"created_at"`
-	UpdatedAt    time.Time        `json:"updated_at"`
+	ID           uuid.UUID       `json:"id" gorm:"type:uuid;primaryKey"`
+	Name         string          `json:"name" gorm:"size:100;not null;uniqueIndex:idx_machine_name_version"`     // 如: plm_task, purchase_order
+	Version      int             `json:"version" gorm:"not null;default:1;uniqueIndex:idx_machine_name_version"` // 版本号，从 1 递增
+	Checksum     string          `json:"checksum" gorm:"size:64"`                                                // 定义内容摘要，用于判断是否需要新版本
+	Description  string          `json:"description" gorm:"type:text"`                                           // 描述
+	InitialState string          `json:"initial_state" gorm:"size:50;not null"`                                  // 初始状态
+	States       json.RawMessage `json:"states" gorm:"type:jsonb"`                                               // 状态列表及属性
+	CreatedAt    time.Time       `json:"created_at"`
+	UpdatedAt    time.Time       `json:"updated_at"`
 
 	// 关联
 	Transitions []StateTransition `json:"transitions,omitempty" gorm:"foreignKey:MachineID"`
@@ -59,12 +64,70 @@ func (StateMachineDefinition) TableName() string {
 	return "state_machine_definitions"
 }
 
+// StateDefinitions 解析 States 字段中的状态列表
+func (d *StateMachineDefinition) StateDefinitions() ([]StateDefinition, error) {
+	var states []StateDefinition
+	if len(d.States) == 0 || string(d.States) == "null" {
+		return states, nil
+	}
+	if err := json.Unmarshal(d.States, &states); err != nil {
+		return nil, fmt.Errorf("解析状态列表失败 [machine=%s]: %w", d.Name, err)
+	}
+	return states, nil
+}
+
+// HasState 状态是否在定义中
+func (d *StateMachineDefinition) HasState(name string) bool {
+	states, err := d.StateDefinitions()
+	if err != nil {
+		return false
+	}
+	for _, st := range states {
+		if st.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// HasEvent from 状态下是否有 event 的转换规则（不评估条件）
+func (d *StateMachineDefinition) HasEvent(from, event string) bool {
+	for _, t := range d.Transitions {
+		if t.FromState == from && t.Event == event {
+			return true
+		}
+	}
+	return false
+}
+
+// eventTo 查找从 from 状态进入 to 状态的事件（多条时取优先级最高者），没有则返回空
+func (d *StateMachineDefinition) eventTo(from, to string) string {
+	event, best := "", 0
+	for _, t := range d.Transitions {
+		if t.FromState == from && t.ToState == to && (event == "" || t.Priority > best) {
+			event, best = t.Event, t.Priority
+		}
+	}
+	return event
+}
+
 // StateDefinition 单个状态的定义（用于 States JSONB 字段解析）
 type StateDefinition struct {
-	Name        string `json:"name"`         // 状态标识: unassigned, pending, in_progress...
-	Label       string `json:"label"`        // 显示名称: 待指派, 待处理, 进行中...
-	Description string `json:"description"`  // 描述
-	IsFinal     bool   `json:"is_final"`     // 是否终态
+	Name        string            `json:"name"`             // 状态标识: unassigned, pending, in_progress...
+	Label       string            `json:"label"`            // 显示名称: 待指派, 待处理, 进行中...
+	Description string            `json:"description"`      // 描述
+	IsFinal     bool              `json:"is_final"`         // 是否终态
+	Timers      []TimerDefinition `json:"timers,omitempty"` // 停留超时定时器
+}
+
+// TimerDefinition 状态定时器定义
+// 实体进入该状态时开始计时，到期仍停留在该状态则以 system 身份触发 Event；
+// 离开该状态时定时器自动取消
+type TimerDefinition struct {
+	Event       string `json:"event"`                  // 到期触发的事件，需有对应的转换规则
+	After       string `json:"after"`                  // 时长: 30d, 48h, 1d12h, 90m
+	WorkingTime bool   `json:"working_time,omitempty"` // 按工作时间计时（跳过非工作时段）
+	Description string `json:"description,omitempty"`  // 描述
 }
 
 // =============================================================================
@@ -129,15 +192,93 @@ func (TransitionLog) TableName() string {
 
 // EntityState 追踪实体当前所处状态
 type EntityState struct {
-	ID           uuid.UUID `json:"id" gorm:"type:uuid;primaryKey"`
-	EntityType   string    `json:"entity_type" gorm:"size:50;not null;uniqueIndex:idx_entity_type_id"` // 实体类型
-	EntityID     uuid.UUID `json:"entity_id" gorm:"type:uuid;not null;uniqueIndex:idx_entity_type_id"` // 实体ID
-	CurrentState string    `json:"current_state" gorm:"size:50;not null"`                               // 当前状态
-	MachineID    uuid.UUID `json:"machine_id" gorm:"type:uuid"`                                         // 所属状态机
-	UpdatedAt    time.Time `json:"updated_at"`
+	ID             uuid.UUID `json:"id" gorm:"type:uuid;primaryKey"`
+	EntityType     string    `json:"entity_type" gorm:"size:50;not null;uniqueIndex:idx_entity_type_id"` // 实体类型
+	EntityID       uuid.UUID `json:"entity_id" gorm:"type:uuid;not null;uniqueIndex:idx_entity_type_id"` // 实体ID
+	CurrentState   string    `json:"current_state" gorm:"size:50;not null"`                               // 当前状态
+	MachineID      uuid.UUID `json:"machine_id" gorm:"type:uuid"`                                         // 所属状态机（具体版本）
+	MachineVersion int       `json:"machine_version" gorm:"default:0"`                                    // 所属状态机版本号
+	Version        int64     `json:"version" gorm:"not null;default:0"`                                   // 乐观锁版本号，每次转换 +1
+	UpdatedAt      time.Time `json:"updated_at"`
 }
 
 // TableName 指定表名
 func (EntityState) TableName() string {
 	return "entity_states"
 }
+
+// =============================================================================
+// ActionOutbox — 动作发件箱（事务内写入，提交后由 OutboxDispatcher 异步执行）
+// =============================================================================
+
+// 发件箱动作状态
+const (
+	OutboxStatusPending    = "pending"    // 待执行（含等待重试）
+	OutboxStatusProcessing = "processing" // 已被调度器领取，执行中
+	OutboxStatusSucceeded  = "succeeded"  // 执行成功
+	OutboxStatusDead       = "dead"       // 多次失败，进入死信，需人工重放
+)
+
+// ActionOutbox 待执行的转换动作
+// 与 EntityState/TransitionLog 在同一事务中写入，保证转换回滚时动作不会执行，
+// 转换提交后动作也不会丢失
+type ActionOutbox struct {
+	ID              uuid.UUID       `json:"id" gorm:"type:uuid;primaryKey"`
+	TransitionLogID uuid.UUID       `json:"transition_log_id" gorm:"type:uuid;index"`            // 来源转换日志
+	EntityType      string          `json:"entity_type" gorm:"size:50;not null;index"`           // 实体类型
+	EntityID        uuid.UUID       `json:"entity_id" gorm:"type:uuid;not null"`                 // 实体ID
+	FromState       string          `json:"from_state" gorm:"size:50"`                           // 转换前状态
+	ToState         string          `json:"to_state" gorm:"size:50;not null"`                    // 转换后状态
+	Event           string          `json:"event" gorm:"size:100;not null"`                      // 触发事件
+	EventData       json.RawMessage `json:"event_data" gorm:"type:jsonb"`                        // 事件数据
+	ActionType      string          `json:"action_type" gorm:"size:100;not null"`                // 动作类型
+	ActionConfig    json.RawMessage `json:"action_config" gorm:"type:jsonb"`                     // 动作配置
+	Status          string          `json:"status" gorm:"size:20;not null;default:pending;index"` // pending | processing | succeeded | dead
+	Attempts        int             `json:"attempts" gorm:"not null;default:0"`                  // 已尝试次数
+	NextAttemptAt   time.Time       `json:"next_attempt_at" gorm:"index"`                        // 下次可执行时间
+	LockedUntil     *time.Time      `json:"locked_until"`                                        // 领取租约到期时间（进程崩溃后可被重新领取）
+	LastError       string          `json:"last_error" gorm:"type:text"`                         // 最近一次错误
+	ProcessedAt     *time.Time      `json:"processed_at"`                                        // 成功执行时间
+	CreatedAt       time.Time       `json:"created_at"`
+	UpdatedAt       time.Time       `json:"updated_at"`
+}
+
+// TableName 指定表名
+func (ActionOutbox) TableName() string {
+	return "state_action_outbox"
+}
+
+// =============================================================================
+// EntityTimer — 实体定时器（持久化，重启后继续生效）
+// =============================================================================
+
+// 定时器状态
+const (
+	TimerStatusPending   = "pending"   // 等待到期
+	TimerStatusFiring    = "firing"    // 已被调度器领取，触发中
+	TimerStatusFired     = "fired"     // 已触发转换
+	TimerStatusCancelled = "cancelled" // 实体已离开该状态，定时器取消
+	TimerStatusFailed    = "failed"    // 触发失败（无匹配转换或条件不满足）
+)
+
+// EntityTimer 实体在某状态上的定时器实例
+type EntityTimer struct {
+	ID          uuid.UUID  `json:"id" gorm:"type:uuid;primaryKey"`
+	EntityType  string     `json:"entity_type" gorm:"size:50;not null;index:idx_state_timers_entity"` // 实体类型
+	EntityID    uuid.UUID  `json:"entity_id" gorm:"type:uuid;not null;index:idx_state_timers_entity"` // 实体ID
+	MachineID   uuid.UUID  `json:"machine_id" gorm:"type:uuid"`                                       // 所属状态机
+	State       string     `json:"state" gorm:"size:50;not null"`                                     // 计时所在状态
+	Event       string     `json:"event" gorm:"size:100;not null"`                                    // 到期触发的事件
+	FireAt      time.Time  `json:"fire_at" gorm:"not null;index"`                                     // 到期时间
+	Status      string     `json:"status" gorm:"size:20;not null;default:pending;index"`              // pending | firing | fired | cancelled | failed
+	LockedUntil *time.Time `json:"locked_until"`                                                      // 领取租约到期时间
+	LastError   string     `json:"last_error" gorm:"type:text"`                                       // 触发失败原因
+	FiredAt     *time.Time `json:"fired_at"`                                                          // 实际触发时间
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+}
+
+// TableName 指定表名
+func (EntityTimer) TableName() string {
+	return "state_timers"
+}
@@ -0,0 +1,163 @@
+package engine
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// =============================================================================
+// OutboxDispatcher — 发件箱调度器
+// 在事务提交后执行转换动作，失败按指数退避重试，多次失败进入死信
+// =============================================================================
+
+// OutboxDispatcher 发件箱调度器
+type OutboxDispatcher struct {
+	engine       *Engine
+	PollInterval time.Duration // 轮询间隔（转换提交时也会被立即唤醒）
+	BatchSize    int           // 每轮最多领取的动作数
+	MaxAttempts  int           // 最大尝试次数，超过进入死信
+	BaseBackoff  time.Duration // 首次重试等待时间，之后每次翻倍
+	MaxBackoff   time.Duration // 重试等待上限
+	Lease        time.Duration // 领取租约，超时未完成的动作可被重新领取
+}
+
+// NewOutboxDispatcher 创建发件箱调度器（使用默认参数）
+func NewOutboxDispatcher(e *Engine) *OutboxDispatcher {
+	return &OutboxDispatcher{
+		engine:       e,
+		PollInterval: 5 * time.Second,
+		BatchSize:    50,
+		MaxAttempts:  5,
+		BaseBackoff:  10 * time.Second,
+		MaxBackoff:   30 * time.Minute,
+		Lease:        5 * time.Minute,
+	}
+}
+
+// Start 启动调度循环（阻塞直到 ctx 取消，调用方应使用 go 启动）
+func (d *OutboxDispatcher) Start(ctx context.Context) {
+	log.Printf("[OutboxDispatcher] 启动: poll=%s max_attempts=%d", d.PollInterval, d.MaxAttempts)
+	ticker := time.NewTicker(d.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		d.DispatchOnce()
+
+		select {
+		case <-ctx.Done():
+			log.Printf("[OutboxDispatcher] 已停止")
+			return
+		case <-ticker.C:
+		case <-d.engine.outboxSignal:
+		}
+	}
+}
+
+// DispatchOnce 领取并执行一批到期动作，返回处理的动作数
+func (d *OutboxDispatcher) DispatchOnce() int {
+	entries, err := d.engine.repo.ClaimDueOutbox(time.Now(), d.Lease, d.BatchSize)
+	if err != nil {
+		log.Printf("[OutboxDispatcher] %v", err)
+	}
+
+	for _, entry := range entries {
+		d.dispatch(entry)
+	}
+	return len(entries)
+}
+
+// dispatch 执行单个动作并记录结果
+func (d *OutboxDispatcher) dispatch(entry ActionOutbox) {
+	attempts := entry.Attempts + 1
+
+	var config map[string]interface{}
+	if len(entry.ActionConfig) > 0 {
+		json.Unmarshal(entry.ActionConfig, &config)
+	}
+	var eventData map[string]interface{}
+	if len(entry.EventData) > 0 {
+		json.Unmarshal(entry.EventData, &eventData)
+	}
+
+	action := TransitionAction{Type: entry.ActionType, Config: config}
+	ctx := ActionContext{
+		EntityType: entry.EntityType,
+		EntityID:   entry.EntityID,
+		FromState:  entry.FromState,
+		ToState:    entry.ToState,
+		Event:      entry.Event,
+		EventData:  eventData,
+		OutboxID:   entry.ID,
+	}
+
+	execErr := d.execute(action, ctx)
+	if execErr == nil {
+		if err := d.engine.repo.MarkOutboxSucceeded(entry.ID, attempts); err != nil {
+			log.Printf("[OutboxDispatcher] %v", err)
+		}
+		return
+	}
+
+	dead := attempts >= d.MaxAttempts
+	nextAttemptAt := time.Now().Add(d.backoff(attempts))
+	if dead {
+		log.Printf("[OutboxDispatcher] 动作进入死信: id=%s type=%s entity=%s/%s attempts=%d error=%v",
+			entry.ID, entry.ActionType, entry.EntityType, entry.EntityID, attempts, execErr)
+	} else {
+		log.Printf("[OutboxDispatcher] 动作执行失败，%s 后重试: id=%s type=%s attempts=%d error=%v",
+			nextAttemptAt.Sub(time.Now()).Round(time.Second), entry.ID, entry.ActionType, attempts, execErr)
+	}
+	if err := d.engine.repo.MarkOutboxFailed(entry.ID, attempts, execErr.Error(), nextAttemptAt, dead); err != nil {
+		log.Printf("[OutboxDispatcher] %v", err)
+	}
+}
+
+// execute 调用动作执行器，执行器 panic 按失败处理
+func (d *OutboxDispatcher) execute(action TransitionAction, ctx ActionContext) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("动作执行 panic: %v", r)
+		}
+	}()
+	return d.engine.actionExecutor.Execute(action, ctx)
+}
+
+// backoff 计算第 attempts 次失败后的重试等待时间
+func (d *OutboxDispatcher) backoff(attempts int) time.Duration {
+	wait := d.BaseBackoff
+	for i := 1; i < attempts && wait < d.MaxBackoff; i++ {
+		wait *= 2
+	}
+	if wait > d.MaxBackoff {
+		wait = d.MaxBackoff
+	}
+	return wait
+}
+
+// =============================================================================
+// 发件箱管理（供管理员接口使用）
+// =============================================================================
+
+// ListOutbox 分页查询发件箱动作
+// status 为空时返回全部状态；查询失败动作传 OutboxStatusDead
+func (e *Engine) ListOutbox(status, entityType string, page, pageSize int) ([]ActionOutbox, int64, error) {
+	return e.repo.ListOutbox(status, entityType, page, pageSize)
+}
+
+// ReplayOutbox 重放死信动作：重置尝试次数并立即唤醒调度器
+func (e *Engine) ReplayOutbox(id uuid.UUID) error {
+	reset, err := e.repo.ResetOutboxEntry(id)
+	if err != nil {
+		return err
+	}
+	if !reset {
+		return fmt.Errorf("动作不存在或不处于死信状态: id=%s", id.String())
+	}
+	e.notifyOutbox()
+	return nil
+}
@@ -39,15 +39,18 @@ import (
 //	                    │completed│  │(回退到进行中)
 //	                    │(已完成) │  └─────────────────
 //	                    └────────┘
+//
+// 定时器: reviewing 停留超过 48 个工作小时自动触发 escalate（自环，通知项目经理）
 func NewPLMTaskMachine() *StateMachineDefinition {
 	// 状态定义
 	states := []StateDefinition{
 		{Name: "unassigned", Label: "待指派", Description: "任务已创建，尚未指派执行人"},
 		{Name: "pending", Label: "待处理", Description: "已指派执行人，等待开始"},
 		{Name: "in_progress", Label: "进行中", Description: "执行人正在处理任务"},
-		{Name: "reviewing", Label: "待审批", Description: "任务已提交，等待审批"},
-		{Name: "completed", Label: "已完成", Description: "任务已完成"},
-		{Name: "rejected", Label: "已驳回", Description: "任务被驳回，需要修改"},
+		{Name: "reviewing", Label: "待审批", Description: "任务已提交，等待审批", Timers: []TimerDefinition{
+			{Event: "escalate", After: "48h", WorkingTime: true, Description: "审批超过 48 个工作小时未处理，升级提醒"},
+		}},
+		{Name: "completed", Label: "已完成", Description: "任务已完成", IsFinal: true},
 	}
 	statesJSON, _ := json.Marshal(states)
 
@@ -145,6 +148,19 @@ func NewPLMTaskMachine() *StateMachineDefinition {
 			Description: "审批通过",
 		},
 
+		// reviewing + escalate → reviewing（审批超时升级，定时器触发）
+		{
+			ID:        uuid.New(),
+			FromState: "reviewing",
+			ToState:   "reviewing",
+			Event:     "escalate",
+			Actions: mustMarshalJSON([]TransitionAction{
+				{Type: "notify_users", Config: map[string]interface{}{"message": "任务审批已超时，请尽快处理", "target": "project_manager"}},
+			}),
+			Priority:    0,
+			Description: "审批超时升级（48 工作小时）",
+		},
+
 		// reviewing + reject → in_progress（回退到进行中）
 		{
 			ID:        uuid.New(),
@@ -0,0 +1,254 @@
+package engine
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"github.com/bitfantasy/nimo/internal/shared/expr"
+	"github.com/google/uuid"
+)
+
+// =============================================================================
+// 可用事件查询 & 转换模拟（只读，不落库）
+// =============================================================================
+
+// GuardResult 单条转换规则的条件评估结果
+type GuardResult struct {
+	ToState     string `json:"to_state"`
+	Priority    int    `json:"priority"`
+	Condition   string `json:"condition,omitempty"` // 条件表达式文本（无条件时为空）
+	Passed      bool   `json:"passed"`
+	Reason      string `json:"reason"`
+	Description string `json:"description,omitempty"`
+}
+
+// EventOption 当前状态下的一个事件
+// Available 为 true 时 ToState 是实际会进入的状态（按优先级第一条条件通过的规则）
+type EventOption struct {
+	Event     string        `json:"event"`
+	Available bool          `json:"available"`
+	ToState   string        `json:"to_state,omitempty"`
+	Guards    []GuardResult `json:"guards"`
+}
+
+// AvailableEventsResult 可用事件查询结果
+type AvailableEventsResult struct {
+	EntityType     string        `json:"entity_type"`
+	EntityID       uuid.UUID     `json:"entity_id"`
+	CurrentState   string        `json:"current_state"`
+	MachineVersion int           `json:"machine_version"`
+	Events         []EventOption `json:"events"`
+}
+
+// AvailableEvents 查询实体当前状态下的所有事件及其条件评估结果
+// eventData 作为条件评估上下文（与 Fire 的 eventData 一致）；实体不存在时按初始状态计算
+func (e *Engine) AvailableEvents(entityType string, entityID uuid.UUID, eventData map[string]interface{}) (*AvailableEventsResult, error) {
+	machine, currentState, err := e.entityMachineAndState(entityType, entityID)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &AvailableEventsResult{
+		EntityType:     entityType,
+		EntityID:       entityID,
+		CurrentState:   currentState,
+		MachineVersion: machine.Version,
+		Events:         []EventOption{},
+	}
+
+	// 按事件分组，保持定义顺序
+	byEvent := make(map[string][]StateTransition)
+	var events []string
+	for _, t := range machine.Transitions {
+		if t.FromState != currentState {
+			continue
+		}
+		if _, ok := byEvent[t.Event]; !ok {
+			events = append(events, t.Event)
+		}
+		byEvent[t.Event] = append(byEvent[t.Event], t)
+	}
+
+	for _, event := range events {
+		transition, guards := evaluateGuards(byEvent[event], eventData)
+		option := EventOption{Event: event, Guards: guards}
+		if transition != nil {
+			option.Available = true
+			option.ToState = transition.ToState
+		}
+		result.Events = append(result.Events, option)
+	}
+	return result, nil
+}
+
+// SimulationEvent 模拟中的一个事件
+type SimulationEvent struct {
+	Event     string                 `json:"event"`
+	EventData map[string]interface{} `json:"event_data,omitempty"`
+}
+
+// SimulationStep 模拟的单步结果
+type SimulationStep struct {
+	Event     string             `json:"event"`
+	FromState string             `json:"from_state"`
+	ToState   string             `json:"to_state,omitempty"`
+	Actions   []TransitionAction `json:"actions"`          // 将执行的动作
+	Timers    []TimerDefinition  `json:"timers,omitempty"` // 进入新状态后将启动的定时器
+	Guards    []GuardResult      `json:"guards"`
+	Error     string             `json:"error,omitempty"`
+}
+
+// SimulationResult 模拟结果
+type SimulationResult struct {
+	EntityType     string           `json:"entity_type"`
+	MachineVersion int              `json:"machine_version"`
+	StartState     string           `json:"start_state"`
+	FinalState     string           `json:"final_state"`
+	Path           []string         `json:"path"`      // 依次经过的状态（含起始状态）
+	Completed      bool             `json:"completed"` // 所有事件均成功
+	Steps          []SimulationStep `json:"steps"`
+}
+
+// Simulate 在内存中依次执行事件，返回状态路径和将执行的动作，不写入任何数据
+// entityID 非空时从实体当前状态（及其固定的状态机版本）开始；fromState 非空时覆盖起始状态；
+// 否则从初始状态开始。遇到无法转换的事件即停止，错误记录在该步的 Error 中
+func (e *Engine) Simulate(entityType string, entityID uuid.UUID, fromState string, events []SimulationEvent) (*SimulationResult, error) {
+	var machine *StateMachineDefinition
+	var state string
+	var err error
+	if entityID != uuid.Nil {
+		machine, state, err = e.entityMachineAndState(entityType, entityID)
+	} else {
+		machine, err = e.findMachineForEntity(entityType)
+		if machine != nil {
+			state = machine.InitialState
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	stateDefs, err := machine.StateDefinitions()
+	if err != nil {
+		return nil, err
+	}
+	timersByState := make(map[string][]TimerDefinition)
+	known := make(map[string]bool)
+	for _, st := range stateDefs {
+		known[st.Name] = true
+		timersByState[st.Name] = st.Timers
+	}
+	if fromState != "" {
+		if !known[fromState] {
+			return nil, fmt.Errorf("状态机 [%s] 中不存在状态: %s", machine.Name, fromState)
+		}
+		state = fromState
+	}
+
+	result := &SimulationResult{
+		EntityType:     entityType,
+		MachineVersion: machine.Version,
+		StartState:     state,
+		Path:           []string{state},
+		Completed:      true,
+		Steps:          []SimulationStep{},
+	}
+
+	for _, ev := range events {
+		var candidates []StateTransition
+		for _, t := range machine.Transitions {
+			if t.FromState == state && t.Event == ev.Event {
+				candidates = append(candidates, t)
+			}
+		}
+
+		step := SimulationStep{Event: ev.Event, FromState: state, Actions: []TransitionAction{}}
+		transition, guards := evaluateGuards(candidates, ev.EventData)
+		step.Guards = guards
+		if transition == nil {
+			if len(candidates) == 0 {
+				step.Error = fmt.Sprintf("无效的状态转换: state=%s event=%s（没有匹配的转换规则）", state, ev.Event)
+			} else {
+				step.Error = fmt.Sprintf("无效的状态转换: state=%s event=%s（条件不满足）", state, ev.Event)
+			}
+			result.Steps = append(result.Steps, step)
+			result.Completed = false
+			break
+		}
+
+		if len(transition.Actions) > 0 && string(transition.Actions) != "null" {
+			if err := json.Unmarshal(transition.Actions, &step.Actions); err != nil {
+				return nil, fmt.Errorf("解析动作失败: %w", err)
+			}
+		}
+		step.ToState = transition.ToState
+		step.Timers = timersByState[transition.ToState]
+		result.Steps = append(result.Steps, step)
+
+		state = transition.ToState
+		result.Path = append(result.Path, state)
+	}
+	result.FinalState = state
+	return result, nil
+}
+
+// entityMachineAndState 获取实体固定的状态机版本和当前状态（实体不存在时为最新版本和初始状态）
+func (e *Engine) entityMachineAndState(entityType string, entityID uuid.UUID) (*StateMachineDefinition, string, error) {
+	latest, err := e.findMachineForEntity(entityType)
+	if err != nil {
+		return nil, "", fmt.Errorf("未找到实体类型 [%s] 对应的状态机: %w", entityType, err)
+	}
+	existing, err := e.repo.GetEntityState(entityType, entityID)
+	if err != nil {
+		return nil, "", err
+	}
+	machine, err := e.pinnedMachine(e.repo, latest, existing)
+	if err != nil {
+		return nil, "", err
+	}
+	if existing == nil {
+		return machine, machine.InitialState, nil
+	}
+	return machine, existing.CurrentState, nil
+}
+
+// evaluateGuards 按优先级评估同一 (from_state, event) 的规则，与 Fire 的匹配顺序一致
+// 返回第一条条件通过的规则（无则为 nil），以及每条规则的评估结果；
+// 已选中规则之后的低优先级规则仍会评估，便于展示
+func evaluateGuards(transitions []StateTransition, eventData map[string]interface{}) (*StateTransition, []GuardResult) {
+	sorted := make([]StateTransition, len(transitions))
+	copy(sorted, transitions)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].Priority > sorted[j].Priority })
+
+	var matched *StateTransition
+	failed := false // 求值出错时 Fire 会直接报错，之后的规则不再可能被选中
+	guards := make([]GuardResult, 0, len(sorted))
+	for i, t := range sorted {
+		g := GuardResult{
+			ToState:     t.ToState,
+			Priority:    t.Priority,
+			Condition:   expr.DescribeCondition(t.Condition),
+			Description: t.Description,
+		}
+		ok, err := EvaluateCondition(t.Condition, eventData)
+		switch {
+		case err != nil:
+			g.Reason = "条件求值出错: " + err.Error()
+			failed = failed || matched == nil
+		case ok && g.Condition == "":
+			g.Passed = true
+			g.Reason = "无条件"
+		case ok:
+			g.Passed = true
+			g.Reason = "条件满足"
+		default:
+			g.Reason = "条件不满足"
+		}
+		if g.Passed && matched == nil && !failed {
+			matched = &sorted[i]
+		}
+		guards = append(guards, g)
+	}
+	return matched, guards
+}
@@ -0,0 +1,108 @@
+package engine
+
+import (
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func findEventOption(result *AvailableEventsResult, event string) *EventOption {
+	for i := range result.Events {
+		if result.Events[i].Event == event {
+			return &result.Events[i]
+		}
+	}
+	return nil
+}
+
+func TestAvailableEventsEvaluatesGuards(t *testing.T) {
+	eng := setupPLMTaskEngine(t)
+	taskID := uuid.New()
+
+	// 实体不存在时按初始状态计算
+	result, err := eng.AvailableEvents("plm_task", taskID, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, "unassigned", result.CurrentState)
+	assert.NotNil(t, findEventOption(result, "assign"))
+
+	for _, ev := range []string{"assign", "start"} {
+		_, err := eng.Fire("plm_task", taskID, ev, nil, "u1", "user")
+		assert.NoError(t, err)
+	}
+
+	result, err = eng.AvailableEvents("plm_task", taskID, map[string]interface{}{"needs_review": true})
+	assert.NoError(t, err)
+	assert.Equal(t, "in_progress", result.CurrentState)
+	complete := findEventOption(result, "complete")
+	if assert.NotNil(t, complete) {
+		assert.True(t, complete.Available)
+		assert.Equal(t, "reviewing", complete.ToState)
+		assert.Len(t, complete.Guards, 2)
+		assert.Equal(t, "completed", complete.Guards[0].ToState, "按优先级从高到低")
+		assert.False(t, complete.Guards[0].Passed)
+		assert.Equal(t, "条件不满足", complete.Guards[0].Reason)
+		assert.Equal(t, "needs_review == false", complete.Guards[0].Condition)
+		assert.True(t, complete.Guards[1].Passed)
+	}
+
+	result, err = eng.AvailableEvents("plm_task", taskID, map[string]interface{}{"needs_review": false})
+	assert.NoError(t, err)
+	complete = findEventOption(result, "complete")
+	if assert.NotNil(t, complete) {
+		assert.Equal(t, "completed", complete.ToState)
+	}
+
+	// 缺少上下文时两条条件都不满足
+	result, err = eng.AvailableEvents("plm_task", taskID, nil)
+	assert.NoError(t, err)
+	complete = findEventOption(result, "complete")
+	if assert.NotNil(t, complete) {
+		assert.False(t, complete.Available)
+	}
+	submit := findEventOption(result, "submit_review")
+	if assert.NotNil(t, submit) {
+		assert.True(t, submit.Available)
+		assert.Equal(t, "无条件", submit.Guards[0].Reason)
+	}
+}
+
+func TestSimulateDoesNotPersist(t *testing.T) {
+	eng := setupPLMTaskEngine(t)
+	taskID := uuid.New()
+
+	result, err := eng.Simulate("plm_task", taskID, "", []SimulationEvent{
+		{Event: "assign"},
+		{Event: "start"},
+		{Event: "complete", EventData: map[string]interface{}{"needs_review": true}},
+	})
+	assert.NoError(t, err)
+	assert.True(t, result.Completed)
+	assert.Equal(t, []string{"unassigned", "pending", "in_progress", "reviewing"}, result.Path)
+	assert.Equal(t, "reviewing", result.FinalState)
+	if assert.Len(t, result.Steps, 3) {
+		assert.Equal(t, "feishu_create_approval", result.Steps[2].Actions[0].Type)
+		assert.Equal(t, "escalate", result.Steps[2].Timers[0].Event)
+	}
+
+	state, err := eng.repo.GetEntityState("plm_task", taskID)
+	assert.NoError(t, err)
+	assert.Nil(t, state, "模拟不应写入实体状态")
+}
+
+func TestSimulateStopsAtInvalidEvent(t *testing.T) {
+	eng := setupPLMTaskEngine(t)
+
+	result, err := eng.Simulate("plm_task", uuid.Nil, "in_progress", []SimulationEvent{
+		{Event: "complete"},
+		{Event: "approve"},
+	})
+	assert.NoError(t, err)
+	assert.False(t, result.Completed)
+	assert.Equal(t, "in_progress", result.FinalState)
+	assert.Len(t, result.Steps, 1)
+	assert.Contains(t, result.Steps[0].Error, "条件不满足")
+
+	_, err = eng.Simulate("plm_task", uuid.Nil, "nowhere", nil)
+	assert.Error(t, err)
+}
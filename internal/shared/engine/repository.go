@@ -2,9 +2,11 @@ package engine
 
 import (
 	"fmt"
+	"time"
 
 	"github.com/google/uuid"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 // =============================================================================
@@ -25,43 +27,60 @@ func NewRepository(db *gorm.DB) *Repository {
 // 状态机定义 CRUD
 // =============================================================================
 
-// SaveMachineDefinition 保存状态机定义（不存在则创建，已存在则更新）
-func (r *Repository) SaveMachineDefinition(def *StateMachineDefinition) error {
+// CreateMachineVersion 创建新的状态机版本（定义 + 转换规则）
+// 已有版本不会被修改；(name, version) 冲突时返回错误
+func (r *Repository) CreateMachineVersion(def *StateMachineDefinition) error {
 	if def.ID == uuid.Nil {
 		def.ID = uuid.New()
 	}
 
-	// 使用 Upsert：按 name 唯一键冲突时更新
-	result := r.DB.Where("name = ?", def.Name).FirstOrCreate(def)
-	if result.Error != nil {
-		return fmt.Errorf("保存状态机定义失败: %w", result.Error)
-	}
-
-	// 如果已存在，更新字段
-	if result.RowsAffected == 0 {
-		result = r.DB.Model(def).Where("name = ?", def.Name).Updates(map[string]interface{}{
-			"description":   def.Description,
-			"initial_state": def.InitialState,
-			"states":        def.States,
-		})
-		if result.Error != nil {
-			return fmt.Errorf("更新状态机定义失败: %w", result.Error)
+	return r.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Omit("Transitions").Create(def).Error; err != nil {
+			return fmt.Errorf("保存状态机定义失败: %w", err)
 		}
-	}
-
-	return nil
+		for i := range def.Transitions {
+			def.Transitions[i].ID = uuid.New()
+			def.Transitions[i].MachineID = def.ID
+		}
+		if len(def.Transitions) > 0 {
+			if err := tx.Create(&def.Transitions).Error; err != nil {
+				return fmt.Errorf("保存转换规则失败: %w", err)
+			}
+		}
+		return nil
+	})
 }
 
-// GetMachineDefinition 按名称获取状态机定义
+// GetMachineDefinition 按名称获取状态机定义（最新版本）
 func (r *Repository) GetMachineDefinition(name string) (*StateMachineDefinition, error) {
 	var def StateMachineDefinition
-	result := r.DB.Where("name = ?", name).First(&def)
+	result := r.DB.Where("name = ?", name).Order("version DESC").First(&def)
 	if result.Error != nil {
 		return nil, fmt.Errorf("获取状态机定义失败 [name=%s]: %w", name, result.Error)
 	}
 	return &def, nil
 }
 
+// GetMachineDefinitionVersion 按名称和版本号获取状态机定义
+func (r *Repository) GetMachineDefinitionVersion(name string, version int) (*StateMachineDefinition, error) {
+	var def StateMachineDefinition
+	result := r.DB.Where("name = ? AND version = ?", name, version).First(&def)
+	if result.Error != nil {
+		return nil, fmt.Errorf("获取状态机定义失败 [name=%s version=%d]: %w", name, version, result.Error)
+	}
+	return &def, nil
+}
+
+// ListMachineVersions 获取状态机的所有版本（按版本号倒序，不含转换规则）
+func (r *Repository) ListMachineVersions(name string) ([]StateMachineDefinition, error) {
+	var defs []StateMachineDefinition
+	result := r.DB.Where("name = ?", name).Order("version DESC").Find(&defs)
+	if result.Error != nil {
+		return nil, fmt.Errorf("获取状态机版本失败 [name=%s]: %w", name, result.Error)
+	}
+	return defs, nil
+}
+
 // GetMachineDefinitionByID 按ID获取状态机定义
 func (r *Repository) GetMachineDefinitionByID(id uuid.UUID) (*StateMachineDefinition, error) {
 	var def StateMachineDefinition
@@ -91,19 +110,15 @@ func (r *Repository) SaveTransitions(transitions []StateTransition) error {
 
 	// 先删除该状态机的旧规则，再批量插入新规则
 	machineID := transitions[0].MachineID
-	tx := r.DB.Begin()
-
-	if err := tx.Where("machine_id = ?", machineID).Delete(&StateTransition{}).Error; err != nil {
-		tx.Rollback()
-		return fmt.Errorf("删除旧转换规则失败: %w", err)
-	}
-
-	if err := tx.Create(&transitions).Error; err != nil {
-		tx.Rollback()
-		return fmt.Errorf("保存转换规则失败: %w", err)
-	}
-
-	return tx.Commit().Error
+	return r.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("machine_id = ?", machineID).Delete(&StateTransition{}).Error; err != nil {
+			return fmt.Errorf("删除旧转换规则失败: %w", err)
+		}
+		if err := tx.Create(&transitions).Error; err != nil {
+			return fmt.Errorf("保存转换规则失败: %w", err)
+		}
+		return nil
+	})
 }
 
 // GetTransitions 获取状态机的所有转换规则
@@ -145,7 +160,56 @@ func (r *Repository) GetEntityState(entityType string, entityID uuid.UUID) (*Ent
 	return &state, nil
 }
 
+// CreateEntityState 首次写入实体状态
+// 若其他事务已抢先写入同一实体（唯一索引冲突），返回 created=false
+func (r *Repository) CreateEntityState(state *EntityState) (bool, error) {
+	if state.ID == uuid.Nil {
+		state.ID = uuid.New()
+	}
+	state.Version = 1
+
+	result := r.DB.Clauses(clause.OnConflict{DoNothing: true}).Create(state)
+	if result.Error != nil {
+		return false, fmt.Errorf("创建实体状态失败: %w", result.Error)
+	}
+	return result.RowsAffected > 0, nil
+}
+
+// CompareAndSwapEntityState 按版本号条件更新实体状态（乐观锁）
+// 仅当库中版本仍为 expectedVersion 时才更新，成功后版本号 +1；
+// 返回 swapped=false 表示期间已被其他事务修改
+func (r *Repository) CompareAndSwapEntityState(state *EntityState, expectedVersion int64) (bool, error) {
+	result := r.DB.Model(&EntityState{}).
+		Where("entity_type = ? AND entity_id = ? AND version = ?", state.EntityType, state.EntityID, expectedVersion).
+		Updates(map[string]interface{}{
+			"current_state":   state.CurrentState,
+			"machine_id":      state.MachineID,
+			"machine_version": state.MachineVersion,
+			"version":         expectedVersion + 1,
+			"updated_at":      state.UpdatedAt,
+		})
+	if result.Error != nil {
+		return false, fmt.Errorf("更新实体状态失败: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return false, nil
+	}
+	state.Version = expectedVersion + 1
+	return true, nil
+}
+
+// ListEntityStatesByMachine 获取固定在指定状态机版本上的所有实体
+func (r *Repository) ListEntityStatesByMachine(machineID uuid.UUID) ([]EntityState, error) {
+	var states []EntityState
+	result := r.DB.Where("machine_id = ?", machineID).Order("current_state, entity_id").Find(&states)
+	if result.Error != nil {
+		return nil, fmt.Errorf("获取实体状态失败: %w", result.Error)
+	}
+	return states, nil
+}
+
 // SaveEntityState 保存/更新实体状态
+// 无条件覆盖，不做版本校验；状态转换请使用 CompareAndSwapEntityState
 func (r *Repository) SaveEntityState(state *EntityState) error {
 	if state.ID == uuid.Nil {
 		state.ID = uuid.New()
@@ -154,8 +218,9 @@ func (r *Repository) SaveEntityState(state *EntityState) error {
 	// Upsert: 按 entity_type + entity_id 唯一索引
 	result := r.DB.Where("entity_type = ? AND entity_id = ?", state.EntityType, state.EntityID).
 		Assign(map[string]interface{}{
-			"current_state": state.CurrentState,
-			"machine_id":    state.MachineID,
+			"current_state":   state.CurrentState,
+			"machine_id":      state.MachineID,
+			"machine_version": state.MachineVersion,
 		}).
 		FirstOrCreate(state)
 
@@ -192,3 +257,301 @@ func (r *Repository) GetTransitionLogs(entityType string, entityID uuid.UUID) ([
 	}
 	return logs, nil
 }
+
+// ListTransitionLogsChronological 获取实体的转换日志（按时间正序，用于回放）
+func (r *Repository) ListTransitionLogsChronological(entityType string, entityID uuid.UUID) ([]TransitionLog, error) {
+	var logs []TransitionLog
+	result := r.DB.Where("entity_type = ? AND entity_id = ?", entityType, entityID).
+		Order("created_at ASC").
+		Find(&logs)
+	if result.Error != nil {
+		return nil, fmt.Errorf("获取转换日志失败: %w", result.Error)
+	}
+	return logs, nil
+}
+
+// LoggedEntity 有转换日志的实体
+type LoggedEntity struct {
+	EntityType string
+	EntityID   uuid.UUID
+}
+
+// ListLoggedEntities 获取有转换日志的所有实体（entityType 为空时不过滤）
+func (r *Repository) ListLoggedEntities(entityType string) ([]LoggedEntity, error) {
+	var entities []LoggedEntity
+	query := r.DB.Model(&TransitionLog{}).Distinct("entity_type", "entity_id")
+	if entityType != "" {
+		query = query.Where("entity_type = ?", entityType)
+	}
+	if err := query.Order("entity_type, entity_id").Scan(&entities).Error; err != nil {
+		return nil, fmt.Errorf("获取实体列表失败: %w", err)
+	}
+	return entities, nil
+}
+
+// ListEntityStates 获取实体状态（entityType 为空时不过滤）
+func (r *Repository) ListEntityStates(entityType string) ([]EntityState, error) {
+	var states []EntityState
+	query := r.DB.Model(&EntityState{})
+	if entityType != "" {
+		query = query.Where("entity_type = ?", entityType)
+	}
+	if err := query.Order("entity_type, entity_id").Find(&states).Error; err != nil {
+		return nil, fmt.Errorf("获取实体状态失败: %w", err)
+	}
+	return states, nil
+}
+
+// =============================================================================
+// 动作发件箱 CRUD
+// =============================================================================
+
+// CreateOutboxEntries 批量写入待执行动作
+func (r *Repository) CreateOutboxEntries(entries []ActionOutbox) error {
+	if len(entries) == 0 {
+		return nil
+	}
+	for i := range entries {
+		if entries[i].ID == uuid.Nil {
+			entries[i].ID = uuid.New()
+		}
+	}
+	if err := r.DB.Create(&entries).Error; err != nil {
+		return fmt.Errorf("写入动作发件箱失败: %w", err)
+	}
+	return nil
+}
+
+// ClaimDueOutbox 领取到期的待执行动作
+// 包括到期的 pending 记录，以及租约已过期的 processing 记录（执行中进程崩溃）；
+// 领取通过条件更新完成，多实例并发领取时同一条记录只会被一个实例拿到
+func (r *Repository) ClaimDueOutbox(now time.Time, lease time.Duration, limit int) ([]ActionOutbox, error) {
+	var candidates []ActionOutbox
+	result := r.DB.Where("(status = ? AND next_attempt_at <= ?) OR (status = ? AND locked_until < ?)",
+		OutboxStatusPending, now, OutboxStatusProcessing, now).
+		Order("next_attempt_at ASC").
+		Limit(limit).
+		Find(&candidates)
+	if result.Error != nil {
+		return nil, fmt.Errorf("查询待执行动作失败: %w", result.Error)
+	}
+
+	lockedUntil := now.Add(lease)
+	claimed := make([]ActionOutbox, 0, len(candidates))
+	for _, c := range candidates {
+		ok, err := r.claimOutboxEntry(c, now, lockedUntil)
+		if err != nil {
+			return claimed, err
+		}
+		if !ok {
+			continue // 已被其他实例领取
+		}
+		c.Status = OutboxStatusProcessing
+		c.LockedUntil = &lockedUntil
+		claimed = append(claimed, c)
+	}
+	return claimed, nil
+}
+
+// claimOutboxEntry 条件更新领取一条记录：状态、尝试次数和租约到期时间都须与查询时一致，
+// 租约过期的 processing 记录被多个实例同时查到时，先更新的实例已改写 locked_until，其余实例更新不到
+func (r *Repository) claimOutboxEntry(c ActionOutbox, now, lockedUntil time.Time) (bool, error) {
+	query := r.DB.Model(&ActionOutbox{}).Where("id = ? AND status = ? AND attempts = ?", c.ID, c.Status, c.Attempts)
+	if c.LockedUntil == nil {
+		query = query.Where("locked_until IS NULL")
+	} else {
+		query = query.Where("locked_until = ?", *c.LockedUntil)
+	}
+	res := query.Updates(map[string]interface{}{
+		"status":       OutboxStatusProcessing,
+		"locked_until": lockedUntil,
+		"updated_at":   now,
+	})
+	if res.Error != nil {
+		return false, fmt.Errorf("领取待执行动作失败: %w", res.Error)
+	}
+	return res.RowsAffected > 0, nil
+}
+
+// MarkOutboxSucceeded 标记动作执行成功
+func (r *Repository) MarkOutboxSucceeded(id uuid.UUID, attempts int) error {
+	now := time.Now()
+	result := r.DB.Model(&ActionOutbox{}).Where("id = ?", id).Updates(map[string]interface{}{
+		"status":       OutboxStatusSucceeded,
+		"attempts":     attempts,
+		"locked_until": nil,
+		"last_error":   "",
+		"processed_at": now,
+		"updated_at":   now,
+	})
+	if result.Error != nil {
+		return fmt.Errorf("更新动作状态失败: %w", result.Error)
+	}
+	return nil
+}
+
+// MarkOutboxFailed 记录动作执行失败
+// dead=true 时进入死信状态，否则在 nextAttemptAt 之后重试
+func (r *Repository) MarkOutboxFailed(id uuid.UUID, attempts int, errMsg string, nextAttemptAt time.Time, dead bool) error {
+	status := OutboxStatusPending
+	if dead {
+		status = OutboxStatusDead
+	}
+	result := r.DB.Model(&ActionOutbox{}).Where("id = ?", id).Updates(map[string]interface{}{
+		"status":          status,
+		"attempts":        attempts,
+		"locked_until":    nil,
+		"last_error":      errMsg,
+		"next_attempt_at": nextAttemptAt,
+		"updated_at":      time.Now(),
+	})
+	if result.Error != nil {
+		return fmt.Errorf("更新动作状态失败: %w", result.Error)
+	}
+	return nil
+}
+
+// ListOutbox 分页查询发件箱动作（按创建时间倒序）
+func (r *Repository) ListOutbox(status, entityType string, page, pageSize int) ([]ActionOutbox, int64, error) {
+	query := r.DB.Model(&ActionOutbox{})
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+	if entityType != "" {
+		query = query.Where("entity_type = ?", entityType)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, fmt.Errorf("统计发件箱动作失败: %w", err)
+	}
+
+	var entries []ActionOutbox
+	if err := query.Order("created_at DESC").Offset((page - 1) * pageSize).Limit(pageSize).Find(&entries).Error; err != nil {
+		return nil, 0, fmt.Errorf("查询发件箱动作失败: %w", err)
+	}
+	return entries, total, nil
+}
+
+// ResetOutboxEntry 将死信动作重置为待执行（重放）
+// 仅 dead 状态的记录可重放，返回 reset=false 表示记录不存在或状态不符
+func (r *Repository) ResetOutboxEntry(id uuid.UUID) (bool, error) {
+	now := time.Now()
+	result := r.DB.Model(&ActionOutbox{}).
+		Where("id = ? AND status = ?", id, OutboxStatusDead).
+		Updates(map[string]interface{}{
+			"status":          OutboxStatusPending,
+			"attempts":        0,
+			"next_attempt_at": now,
+			"locked_until":    nil,
+			"updated_at":      now,
+		})
+	if result.Error != nil {
+		return false, fmt.Errorf("重放动作失败: %w", result.Error)
+	}
+	return result.RowsAffected > 0, nil
+}
+
+// =============================================================================
+// 实体定时器 CRUD
+// =============================================================================
+
+// CreateTimers 批量创建定时器
+func (r *Repository) CreateTimers(timers []EntityTimer) error {
+	if len(timers) == 0 {
+		return nil
+	}
+	for i := range timers {
+		if timers[i].ID == uuid.Nil {
+			timers[i].ID = uuid.New()
+		}
+	}
+	if err := r.DB.Create(&timers).Error; err != nil {
+		return fmt.Errorf("创建定时器失败: %w", err)
+	}
+	return nil
+}
+
+// CancelPendingTimers 取消实体所有未触发的定时器（实体离开状态时调用）
+func (r *Repository) CancelPendingTimers(entityType string, entityID uuid.UUID) error {
+	result := r.DB.Model(&EntityTimer{}).
+		Where("entity_type = ? AND entity_id = ? AND status = ?", entityType, entityID, TimerStatusPending).
+		Updates(map[string]interface{}{
+			"status":     TimerStatusCancelled,
+			"updated_at": time.Now(),
+		})
+	if result.Error != nil {
+		return fmt.Errorf("取消定时器失败: %w", result.Error)
+	}
+	return nil
+}
+
+// ClaimDueTimers 领取到期的定时器
+// 包括到期的 pending 定时器，以及租约已过期的 firing 定时器
+func (r *Repository) ClaimDueTimers(now time.Time, lease time.Duration, limit int) ([]EntityTimer, error) {
+	var candidates []EntityTimer
+	result := r.DB.Where("(status = ? AND fire_at <= ?) OR (status = ? AND locked_until < ?)",
+		TimerStatusPending, now, TimerStatusFiring, now).
+		Order("fire_at ASC").
+		Limit(limit).
+		Find(&candidates)
+	if result.Error != nil {
+		return nil, fmt.Errorf("查询到期定时器失败: %w", result.Error)
+	}
+
+	lockedUntil := now.Add(lease)
+	claimed := make([]EntityTimer, 0, len(candidates))
+	for _, c := range candidates {
+		query := r.DB.Model(&EntityTimer{}).Where("id = ? AND status = ?", c.ID, c.Status)
+		if c.LockedUntil == nil {
+			query = query.Where("locked_until IS NULL")
+		} else {
+			query = query.Where("locked_until = ?", *c.LockedUntil) // 租约过期的记录只能被一个实例续上
+		}
+		res := query.Updates(map[string]interface{}{
+			"status":       TimerStatusFiring,
+			"locked_until": lockedUntil,
+			"updated_at":   now,
+		})
+		if res.Error != nil {
+			return claimed, fmt.Errorf("领取定时器失败: %w", res.Error)
+		}
+		if res.RowsAffected == 0 {
+			continue
+		}
+		c.Status = TimerStatusFiring
+		c.LockedUntil = &lockedUntil
+		claimed = append(claimed, c)
+	}
+	return claimed, nil
+}
+
+// FinishTimer 记录定时器处理结果
+func (r *Repository) FinishTimer(id uuid.UUID, status string, errMsg string) error {
+	now := time.Now()
+	updates := map[string]interface{}{
+		"status":       status,
+		"locked_until": nil,
+		"last_error":   errMsg,
+		"updated_at":   now,
+	}
+	if status == TimerStatusFired {
+		updates["fired_at"] = now
+	}
+	if err := r.DB.Model(&EntityTimer{}).Where("id = ?", id).Updates(updates).Error; err != nil {
+		return fmt.Errorf("更新定时器状态失败: %w", err)
+	}
+	return nil
+}
+
+// GetTimers 获取实体的定时器（按到期时间升序）
+func (r *Repository) GetTimers(entityType string, entityID uuid.UUID) ([]EntityTimer, error) {
+	var timers []EntityTimer
+	result := r.DB.Where("entity_type = ? AND entity_id = ?", entityType, entityID).
+		Order("fire_at ASC").
+		Find(&timers)
+	if result.Error != nil {
+		return nil, fmt.Errorf("获取定时器失败: %w", result.Error)
+	}
+	return timers, nil
+}
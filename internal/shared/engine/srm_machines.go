@@ -0,0 +1,207 @@
+package engine
+
+import (
+	"encoding/json"
+
+	"github.com/google/uuid"
+)
+
+// =============================================================================
+// SRM 预设状态机定义 — 采购订单 / PR 行项 / 打样
+// 实体ID 由业务主键经 EntityUUID 映射，业务主键通过事件数据中的 id 字段传递
+// =============================================================================
+
+// NewSRMPurchaseOrderMachine 创建 SRM 采购订单状态机定义
+//
+// 状态流转:
+//
+//	draft ──submit──▶ submitted ──approve──▶ approved ──send──▶ sent
+//	draft ──approve──▶ approved（直接审批）
+//	approved / sent / partial ──receive──▶ received（fully_received=true，优先）/ partial（未收齐）
+//	received ──complete──▶ completed
+//	draft / submitted / approved ──cancel──▶ cancelled
+//
+// 动作: send 时通知供应商（notify_supplier）
+func NewSRMPurchaseOrderMachine() *StateMachineDefinition {
+	states := []StateDefinition{
+		{Name: "draft", Label: "草稿", Description: "采购订单已创建，可编辑"},
+		{Name: "submitted", Label: "已提交", Description: "已提交审批"},
+		{Name: "approved", Label: "已审批", Description: "审批通过，待发送供应商"},
+		{Name: "sent", Label: "已发送", Description: "已发送供应商，等待到货"},
+		{Name: "partial", Label: "部分收货", Description: "部分行项已收货"},
+		{Name: "received", Label: "已收货", Description: "全部行项已收货"},
+		{Name: "completed", Label: "已完成", Description: "订单已完成", IsFinal: true},
+		{Name: "cancelled", Label: "已取消", Description: "订单已取消", IsFinal: true},
+	}
+	statesJSON, _ := json.Marshal(states)
+
+	fullyReceived := mustMarshalJSON(map[string]interface{}{"field": "fully_received", "op": "eq", "value": true})
+	transitions := []StateTransition{
+		srmTransition("draft", "submitted", "submit", "提交审批"),
+		srmTransition("draft", "approved", "approve", "直接审批"),
+		srmTransition("submitted", "approved", "approve", "审批通过"),
+		{
+			ID:        uuid.New(),
+			FromState: "approved",
+			ToState:   "sent",
+			Event:     "send",
+			Actions: mustMarshalJSON([]TransitionAction{
+				{Type: "notify_supplier", Config: map[string]interface{}{"message": "您有新的采购订单，请确认交期"}},
+			}),
+			Description: "发送供应商",
+		},
+		{
+			ID:          uuid.New(),
+			FromState:   "approved",
+			ToState:     "received",
+			Event:       "receive",
+			Condition:   fullyReceived,
+			Priority:    10,
+			Description: "收货（全部收齐，未经发送直接到货）",
+		},
+		srmTransition("approved", "partial", "receive", "收货（部分，未经发送直接到货）"),
+		{
+			ID:          uuid.New(),
+			FromState:   "sent",
+			ToState:     "received",
+			Event:       "receive",
+			Condition:   fullyReceived,
+			Priority:    10,
+			Description: "收货（全部收齐）",
+		},
+		srmTransition("sent", "partial", "receive", "收货（部分）"),
+		{
+			ID:          uuid.New(),
+			FromState:   "partial",
+			ToState:     "received",
+			Event:       "receive",
+			Condition:   fullyReceived,
+			Priority:    10,
+			Description: "收货（全部收齐）",
+		},
+		srmTransition("partial", "partial", "receive", "收货（仍未收齐）"),
+		srmTransition("received", "completed", "complete", "订单完成"),
+		srmTransition("draft", "cancelled", "cancel", "取消订单"),
+		srmTransition("submitted", "cancelled", "cancel", "取消订单"),
+		srmTransition("approved", "cancelled", "cancel", "取消订单"),
+	}
+
+	return &StateMachineDefinition{
+		ID:           uuid.New(),
+		Name:         "srm_purchase_order",
+		Description:  "SRM 采购订单状态机 — 从草稿、审批、发送到收货完成",
+		InitialState: "draft",
+		States:       statesJSON,
+		Transitions:  transitions,
+	}
+}
+
+// NewSRMPRItemMachine 创建 SRM 采购需求行项状态机定义
+//
+// 状态流转:
+//
+//	pending ──start_sampling──▶ sampling ──sampling_passed──▶ quoting（打样流程驱动）
+//	pending ──quote──▶ quoting ──source──▶ sourcing ──order──▶ ordered
+//	pending ──source──▶ sourcing
+//	pending / quoting ──order──▶ ordered（仅生成采购订单时触发）
+//	ordered ──ship──▶ shipped ──receive──▶ received ──inspect──▶ inspecting ──pass/fail──▶ passed / failed
+//	ordered ──receive──▶ received（PO行项收齐时触发，未登记发货）
+//
+// 手动修改状态时事件数据带 manual=true，标注"流程驱动"的转换条件不满足
+func NewSRMPRItemMachine() *StateMachineDefinition {
+	states := []StateDefinition{
+		{Name: "pending", Label: "待处理"},
+		{Name: "sampling", Label: "打样中"},
+		{Name: "quoting", Label: "报价中"},
+		{Name: "sourcing", Label: "寻源中"},
+		{Name: "ordered", Label: "已下单"},
+		{Name: "shipped", Label: "已发货"},
+		{Name: "received", Label: "已收货"},
+		{Name: "inspecting", Label: "检验中"},
+		{Name: "passed", Label: "检验合格", IsFinal: true},
+		{Name: "failed", Label: "检验不合格", IsFinal: true},
+	}
+	statesJSON, _ := json.Marshal(states)
+
+	transitions := []StateTransition{
+		srmProcessTransition("pending", "sampling", "start_sampling", "发起打样"),
+		srmProcessTransition("sampling", "quoting", "sampling_passed", "打样验证通过，进入报价"),
+		srmTransition("pending", "quoting", "quote", "发起询价"),
+		srmTransition("pending", "sourcing", "source", "分配供应商"),
+		srmTransition("quoting", "sourcing", "source", "报价完成，确定供应商"),
+		srmProcessTransition("pending", "ordered", "order", "生成采购订单"),
+		srmProcessTransition("quoting", "ordered", "order", "生成采购订单"),
+		srmTransition("sourcing", "ordered", "order", "生成采购订单"),
+		srmTransition("ordered", "shipped", "ship", "供应商发货"),
+		srmProcessTransition("ordered", "received", "receive", "PO行项收齐（未登记发货）"),
+		srmTransition("shipped", "received", "receive", "到货"),
+		srmTransition("received", "inspecting", "inspect", "来料检验"),
+		srmTransition("inspecting", "passed", "pass", "检验合格"),
+		srmTransition("inspecting", "failed", "fail", "检验不合格"),
+	}
+
+	return &StateMachineDefinition{
+		ID:           uuid.New(),
+		Name:         "srm_pr_item",
+		Description:  "SRM 采购需求行项状态机 — 从打样/询价到下单、收货、检验",
+		InitialState: "pending",
+		States:       statesJSON,
+		Transitions:  transitions,
+	}
+}
+
+// NewSRMSamplingMachine 创建 SRM 打样请求状态机定义
+//
+// 状态流转:
+//
+//	preparing ──ship──▶ shipping ──arrive──▶ arrived ──request_verify──▶ verifying ──pass/fail──▶ passed / failed
+func NewSRMSamplingMachine() *StateMachineDefinition {
+	states := []StateDefinition{
+		{Name: "preparing", Label: "供应商制样"},
+		{Name: "shipping", Label: "运输中"},
+		{Name: "arrived", Label: "已到货"},
+		{Name: "verifying", Label: "验证中", Description: "已发飞书审批，等待研发验证"},
+		{Name: "passed", Label: "验证通过", IsFinal: true},
+		{Name: "failed", Label: "验证不通过", IsFinal: true},
+	}
+	statesJSON, _ := json.Marshal(states)
+
+	transitions := []StateTransition{
+		srmTransition("preparing", "shipping", "ship", "样品发出"),
+		srmTransition("shipping", "arrived", "arrive", "样品到货"),
+		srmTransition("arrived", "verifying", "request_verify", "发起研发验证"),
+		srmTransition("verifying", "passed", "pass", "验证通过"),
+		srmTransition("verifying", "failed", "fail", "验证不通过"),
+	}
+
+	return &StateMachineDefinition{
+		ID:           uuid.New(),
+		Name:         "srm_sampling_request",
+		Description:  "SRM 打样状态机 — 制样、运输、到货、研发验证",
+		InitialState: "preparing",
+		States:       statesJSON,
+		Transitions:  transitions,
+	}
+}
+
+// srmTransition 无条件、无动作的转换规则
+func srmTransition(from, to, event, description string) StateTransition {
+	return StateTransition{
+		ID:          uuid.New(),
+		FromState:   from,
+		ToState:     to,
+		Event:       event,
+		Description: description,
+	}
+}
+
+// srmNotManual 事件数据中 manual 不为 true（未传时满足）
+var srmNotManual = mustMarshalJSON(map[string]interface{}{"field": "manual", "op": "ne", "value": true})
+
+// srmProcessTransition 只能由业务流程触发的转换，手动修改状态时不可用
+func srmProcessTransition(from, to, event, description string) StateTransition {
+	t := srmTransition(from, to, event, description)
+	t.Condition = srmNotManual
+	t.Description += "（流程驱动）"
+	return t
+}
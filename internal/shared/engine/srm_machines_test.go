@@ -0,0 +1,69 @@
+package engine
+
+import (
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSRMPRItemManualTransitions(t *testing.T) {
+	eng := NewEngine(setupEngineTestDB(t), nil)
+	if err := eng.RegisterMachine(NewSRMPRItemMachine()); err != nil {
+		t.Fatalf("register machine: %v", err)
+	}
+
+	tests := []struct {
+		from, to string
+		manual   bool
+		wantErr  bool
+	}{
+		{from: "pending", to: "quoting", manual: true},
+		{from: "pending", to: "sourcing", manual: true},
+		{from: "quoting", to: "sourcing", manual: true},
+		{from: "sourcing", to: "ordered", manual: true},
+		{from: "ordered", to: "shipped", manual: true},
+		{from: "inspecting", to: "failed", manual: true},
+		// 打样由打样流程驱动
+		{from: "pending", to: "sampling", manual: true, wantErr: true},
+		{from: "sampling", to: "quoting", manual: true, wantErr: true},
+		{from: "pending", to: "sampling"},
+		{from: "sampling", to: "quoting"},
+		// pending/quoting 直接下单只能通过生成采购订单
+		{from: "pending", to: "ordered", manual: true, wantErr: true},
+		{from: "quoting", to: "ordered", manual: true, wantErr: true},
+		{from: "pending", to: "ordered"},
+		// 未登记发货直接到货只能由PO收货触发
+		{from: "ordered", to: "received", manual: true, wantErr: true},
+		{from: "ordered", to: "received"},
+		// 状态机中不存在的流转
+		{from: "ordered", to: "passed", manual: true, wantErr: true},
+	}
+	for _, tt := range tests {
+		name := tt.from + "→" + tt.to
+		if tt.manual {
+			name += "(手动)"
+		}
+		t.Run(name, func(t *testing.T) {
+			eventData := map[string]interface{}{}
+			if tt.manual {
+				eventData["manual"] = true
+			}
+			log, err := eng.FireInTx(eng.DB, FireRequest{
+				EntityType:   "srm_pr_item",
+				EntityID:     uuid.New(),
+				TargetState:  tt.to,
+				InitialState: tt.from,
+				EventData:    eventData,
+				TriggeredBy:  "u1",
+			})
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			if assert.NoError(t, err) {
+				assert.Equal(t, tt.to, log.ToState)
+			}
+		})
+	}
+}
@@ -0,0 +1,316 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// =============================================================================
+// 定时转换 — 状态停留超时后自动触发事件（SLA 升级、草稿自动作废等）
+// =============================================================================
+
+// WorkCalendar 工作日历，用于按工作时间计算定时器到期时间
+type WorkCalendar interface {
+	// AddWorkdays 从 start 起顺延 days 个工作日（保留时刻）
+	AddWorkdays(start time.Time, days int) time.Time
+	// AddWorkingHours 从 start 起累加 d 的工作时长，跳过非工作时段
+	AddWorkingHours(start time.Time, d time.Duration) time.Time
+}
+
+// WeekdayCalendar 默认工作日历：每天 StartHour~EndHour；
+// IsWorkday 为空时按周一至周五，业务侧可注入节假日/调休日历
+type WeekdayCalendar struct {
+	StartHour int
+	EndHour   int
+	IsWorkday func(day time.Time) bool
+}
+
+// NewWeekdayCalendar 创建默认工作日历（09:00-18:00）
+func NewWeekdayCalendar() *WeekdayCalendar {
+	return &WeekdayCalendar{StartHour: 9, EndHour: 18}
+}
+
+// AddWorkdays 顺延工作日
+func (c *WeekdayCalendar) AddWorkdays(start time.Time, days int) time.Time {
+	t := start
+	for i := 0; i < days; i++ {
+		t = t.AddDate(0, 0, 1)
+		for !c.workday(t) {
+			t = t.AddDate(0, 0, 1)
+		}
+	}
+	return t
+}
+
+// AddWorkingHours 累加工作时长
+func (c *WeekdayCalendar) AddWorkingHours(start time.Time, d time.Duration) time.Time {
+	t := start
+	remaining := d
+	for remaining > 0 {
+		dayStart := time.Date(t.Year(), t.Month(), t.Day(), c.StartHour, 0, 0, 0, t.Location())
+		dayEnd := time.Date(t.Year(), t.Month(), t.Day(), c.EndHour, 0, 0, 0, t.Location())
+		if !c.workday(t) || !t.Before(dayEnd) {
+			t = dayStart.AddDate(0, 0, 1)
+			continue
+		}
+		if t.Before(dayStart) {
+			t = dayStart
+		}
+		available := dayEnd.Sub(t)
+		if remaining <= available {
+			return t.Add(remaining)
+		}
+		remaining -= available
+		t = dayStart.AddDate(0, 0, 1)
+	}
+	return t
+}
+
+func (c *WeekdayCalendar) workday(t time.Time) bool {
+	if c.IsWorkday != nil {
+		return c.IsWorkday(t)
+	}
+	return isWeekday(t)
+}
+
+func isWeekday(t time.Time) bool {
+	return t.Weekday() != time.Saturday && t.Weekday() != time.Sunday
+}
+
+// ParseTimerDuration 解析定时器时长
+// 支持 Go duration 语法，并扩展 d 表示天: "30d", "48h", "1d12h", "90m"
+func ParseTimerDuration(s string) (days int, rest time.Duration, err error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return 0, 0, fmt.Errorf("时长不能为空")
+	}
+	if i := strings.Index(s, "d"); i >= 0 {
+		days, err = strconv.Atoi(s[:i])
+		if err != nil || days < 0 {
+			return 0, 0, fmt.Errorf("无效的天数: %s", s)
+		}
+		s = s[i+1:]
+	}
+	if s != "" {
+		rest, err = time.ParseDuration(s)
+		if err != nil {
+			return 0, 0, fmt.Errorf("无效的时长: %w", err)
+		}
+		if rest < 0 {
+			return 0, 0, fmt.Errorf("时长不能为负: %s", s)
+		}
+	}
+	return days, rest, nil
+}
+
+// SetWorkCalendar 设置工作日历（working_time 定时器使用）
+func (e *Engine) SetWorkCalendar(cal WorkCalendar) {
+	e.calendar = cal
+}
+
+// timerFireAt 计算定时器到期时间
+func (e *Engine) timerFireAt(def TimerDefinition, from time.Time) (time.Time, error) {
+	days, rest, err := ParseTimerDuration(def.After)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if !def.WorkingTime {
+		return from.AddDate(0, 0, days).Add(rest), nil
+	}
+	return e.calendar.AddWorkingHours(e.calendar.AddWorkdays(from, days), rest), nil
+}
+
+// rescheduleTimers 取消实体已有的定时器，并为新状态创建定时器（在转换事务内调用）
+func (e *Engine) rescheduleTimers(repo *Repository, machine *StateMachineDefinition, entityType string, entityID uuid.UUID, state string, now time.Time) error {
+	if err := repo.CancelPendingTimers(entityType, entityID); err != nil {
+		return err
+	}
+
+	states, err := machine.StateDefinitions()
+	if err != nil {
+		return err
+	}
+
+	var timers []EntityTimer
+	for _, st := range states {
+		if st.Name != state {
+			continue
+		}
+		for _, def := range st.Timers {
+			fireAt, err := e.timerFireAt(def, now)
+			if err != nil {
+				log.Printf("[StateEngine] 定时器配置错误，已跳过: machine=%s state=%s event=%s error=%v", machine.Name, state, def.Event, err)
+				continue
+			}
+			timers = append(timers, EntityTimer{
+				ID:         uuid.New(),
+				EntityType: entityType,
+				EntityID:   entityID,
+				MachineID:  machine.ID,
+				State:      state,
+				Event:      def.Event,
+				FireAt:     fireAt,
+				Status:     TimerStatusPending,
+			})
+		}
+	}
+	return repo.CreateTimers(timers)
+}
+
+// InitEntity 以初始状态登记新实体，并启动初始状态上的定时器
+// 实体已有状态记录时直接返回现有记录
+func (e *Engine) InitEntity(entityType string, entityID uuid.UUID, triggeredBy string, triggeredByType string) (*EntityState, error) {
+	machine, err := e.findMachineForEntity(entityType)
+	if err != nil {
+		return nil, fmt.Errorf("未找到实体类型 [%s] 对应的状态机: %w", entityType, err)
+	}
+
+	var state *EntityState
+	err = e.DB.Transaction(func(tx *gorm.DB) error {
+		txRepo := NewRepository(tx)
+
+		existing, err := txRepo.GetEntityState(entityType, entityID)
+		if err != nil {
+			return err
+		}
+		if existing != nil {
+			state = existing
+			return nil
+		}
+
+		now := time.Now()
+		state = &EntityState{
+			EntityType:     entityType,
+			EntityID:       entityID,
+			CurrentState:   machine.InitialState,
+			MachineID:      machine.ID,
+			MachineVersion: machine.Version,
+			UpdatedAt:      now,
+		}
+		created, err := txRepo.CreateEntityState(state)
+		if err != nil {
+			return err
+		}
+		if !created {
+			return fmt.Errorf("%w: entity=%s/%s 已被初始化", ErrConcurrentTransition, entityType, entityID.String())
+		}
+
+		if err := txRepo.SaveTransitionLog(&TransitionLog{
+			ID:              uuid.New(),
+			EntityType:      entityType,
+			EntityID:        entityID,
+			ToState:         machine.InitialState,
+			Event:           "init",
+			TriggeredBy:     triggeredBy,
+			TriggeredByType: triggeredByType,
+			CreatedAt:       now,
+		}); err != nil {
+			return err
+		}
+
+		return e.rescheduleTimers(txRepo, machine, entityType, entityID, machine.InitialState, now)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return state, nil
+}
+
+// GetTimers 获取实体的定时器
+func (e *Engine) GetTimers(entityType string, entityID uuid.UUID) ([]EntityTimer, error) {
+	return e.repo.GetTimers(entityType, entityID)
+}
+
+// CancelTimers 取消实体所有待触发的定时器（业务状态已在引擎之外离开计时状态时使用）
+func (e *Engine) CancelTimers(entityType string, entityID uuid.UUID) error {
+	return e.repo.CancelPendingTimers(entityType, entityID)
+}
+
+// =============================================================================
+// TimerScheduler — 定时器调度循环
+// =============================================================================
+
+// TimerScheduler 定时器调度器
+// 轮询到期定时器，以 triggeredByType=system 触发对应事件
+type TimerScheduler struct {
+	engine       *Engine
+	PollInterval time.Duration // 轮询间隔
+	BatchSize    int           // 每轮最多处理的定时器数
+	Lease        time.Duration // 领取租约
+}
+
+// NewTimerScheduler 创建定时器调度器（使用默认参数）
+func NewTimerScheduler(e *Engine) *TimerScheduler {
+	return &TimerScheduler{
+		engine:       e,
+		PollInterval: 30 * time.Second,
+		BatchSize:    50,
+		Lease:        5 * time.Minute,
+	}
+}
+
+// Start 启动调度循环（阻塞直到 ctx 取消，调用方应使用 go 启动）
+func (s *TimerScheduler) Start(ctx context.Context) {
+	log.Printf("[TimerScheduler] 启动: poll=%s", s.PollInterval)
+	ticker := time.NewTicker(s.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		s.FireDueOnce(time.Now())
+
+		select {
+		case <-ctx.Done():
+			log.Printf("[TimerScheduler] 已停止")
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// FireDueOnce 触发一批在 now 之前到期的定时器，返回处理的定时器数
+func (s *TimerScheduler) FireDueOnce(now time.Time) int {
+	timers, err := s.engine.repo.ClaimDueTimers(now, s.Lease, s.BatchSize)
+	if err != nil {
+		log.Printf("[TimerScheduler] %v", err)
+	}
+
+	for _, t := range timers {
+		s.fire(t)
+	}
+	return len(timers)
+}
+
+// fire 触发单个定时器
+// 以定时器所在状态作为 expectedState，实体已离开该状态时定时器作废
+func (s *TimerScheduler) fire(t EntityTimer) {
+	eventData := map[string]interface{}{
+		"timer_id":      t.ID.String(),
+		"timer_state":   t.State,
+		"timer_fire_at": t.FireAt.Format(time.RFC3339),
+	}
+
+	_, err := s.engine.FireWithExpectedState(t.EntityType, t.EntityID, t.State, t.Event, eventData, "timer:"+t.ID.String(), "system")
+
+	status, errMsg := TimerStatusFired, ""
+	switch {
+	case err == nil:
+		log.Printf("[TimerScheduler] 定时器触发: entity=%s/%s state=%s event=%s", t.EntityType, t.EntityID, t.State, t.Event)
+	case errors.Is(err, ErrConcurrentTransition):
+		status, errMsg = TimerStatusCancelled, err.Error()
+	default:
+		status, errMsg = TimerStatusFailed, err.Error()
+		log.Printf("[TimerScheduler] 定时器触发失败: entity=%s/%s event=%s error=%v", t.EntityType, t.EntityID, t.Event, err)
+	}
+
+	if err := s.engine.repo.FinishTimer(t.ID, status, errMsg); err != nil {
+		log.Printf("[TimerScheduler] %v", err)
+	}
+}
@@ -0,0 +1,256 @@
+package engine
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// =============================================================================
+// 状态机版本 & 实体迁移
+// =============================================================================
+
+// MachineChecksum 计算状态机定义的内容摘要
+// 只包含影响流转的字段（初始状态、状态列表、转换规则），不含 ID 与描述
+func MachineChecksum(def *StateMachineDefinition) (string, error) {
+	type transitionKey struct {
+		FromState string          `json:"from_state"`
+		ToState   string          `json:"to_state"`
+		Event     string          `json:"event"`
+		Condition json.RawMessage `json:"condition,omitempty"`
+		Actions   json.RawMessage `json:"actions,omitempty"`
+		Priority  int             `json:"priority"`
+	}
+
+	transitions := make([]transitionKey, 0, len(def.Transitions))
+	for _, t := range def.Transitions {
+		transitions = append(transitions, transitionKey{
+			FromState: t.FromState,
+			ToState:   t.ToState,
+			Event:     t.Event,
+			Condition: compactJSON(t.Condition),
+			Actions:   compactJSON(t.Actions),
+			Priority:  t.Priority,
+		})
+	}
+	sort.SliceStable(transitions, func(i, j int) bool {
+		a, b := transitions[i], transitions[j]
+		if a.FromState != b.FromState {
+			return a.FromState < b.FromState
+		}
+		if a.Event != b.Event {
+			return a.Event < b.Event
+		}
+		return a.Priority > b.Priority
+	})
+
+	payload, err := json.Marshal(map[string]interface{}{
+		"initial_state": def.InitialState,
+		"states":        compactJSON(def.States),
+		"transitions":   transitions,
+	})
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(payload)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// compactJSON 去除 JSON 中的空白，"null" 视为空
+func compactJSON(raw json.RawMessage) json.RawMessage {
+	if len(raw) == 0 || string(raw) == "null" {
+		return nil
+	}
+	var buf bytes.Buffer
+	if err := json.Compact(&buf, raw); err != nil {
+		return raw
+	}
+	return buf.Bytes()
+}
+
+// ListMachineVersions 获取状态机的所有版本（按版本号倒序）
+func (e *Engine) ListMachineVersions(name string) ([]StateMachineDefinition, error) {
+	return e.repo.ListMachineVersions(name)
+}
+
+// GetMachineVersion 获取状态机的指定版本（version 为 0 时返回最新版本）
+func (e *Engine) GetMachineVersion(name string, version int) (*StateMachineDefinition, error) {
+	if version == 0 {
+		return e.GetMachine(name)
+	}
+	def, err := e.repo.GetMachineDefinitionVersion(name, version)
+	if err != nil {
+		return nil, err
+	}
+	return e.getMachineByID(e.repo, def.ID)
+}
+
+// MigrationPlan 实体迁移计划
+// StateMap 指定旧状态 → 新状态的映射；未列出的状态若在新版本中同名存在则保持不变
+type MigrationPlan struct {
+	MachineName string            `json:"machine_name"`
+	FromVersion int               `json:"from_version"`
+	ToVersion   int               `json:"to_version"` // 0 表示最新版本
+	StateMap    map[string]string `json:"state_map"`
+}
+
+// StateMigrationReport 单个状态的迁移统计
+type StateMigrationReport struct {
+	FromState string      `json:"from_state"`
+	ToState   string      `json:"to_state"`
+	Count     int         `json:"count"`
+	EntityIDs []uuid.UUID `json:"entity_ids"`
+}
+
+// MigrationReport 迁移报告（dry-run 时只统计不写入）
+type MigrationReport struct {
+	MachineName string                 `json:"machine_name"`
+	FromVersion int                    `json:"from_version"`
+	ToVersion   int                    `json:"to_version"`
+	DryRun      bool                   `json:"dry_run"`
+	Total       int                    `json:"total"`
+	States      []StateMigrationReport `json:"states"`
+	Unmapped    []string               `json:"unmapped"` // 新版本中不存在且未映射的旧状态
+}
+
+// MigrateEntities 将固定在旧版本上的实体迁移到新版本
+// dryRun 为 true 时只返回每个状态将迁移的实体数，不做任何修改；
+// 实际迁移在同一事务内完成，每个实体记录一条 migrate_version 转换日志并按新状态重建定时器
+func (e *Engine) MigrateEntities(plan MigrationPlan, dryRun bool) (*MigrationReport, error) {
+	from, err := e.repo.GetMachineDefinitionVersion(plan.MachineName, plan.FromVersion)
+	if err != nil {
+		return nil, err
+	}
+	var to *StateMachineDefinition
+	if plan.ToVersion == 0 {
+		to, err = e.repo.GetMachineDefinition(plan.MachineName)
+	} else {
+		to, err = e.repo.GetMachineDefinitionVersion(plan.MachineName, plan.ToVersion)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if to.ID == from.ID {
+		return nil, fmt.Errorf("源版本与目标版本相同: version=%d", from.Version)
+	}
+	if to, err = e.getMachineByID(e.repo, to.ID); err != nil {
+		return nil, err
+	}
+
+	targetStates, err := to.StateDefinitions()
+	if err != nil {
+		return nil, err
+	}
+	known := make(map[string]bool, len(targetStates))
+	for _, st := range targetStates {
+		known[st.Name] = true
+	}
+	for oldState, newState := range plan.StateMap {
+		if !known[newState] {
+			return nil, fmt.Errorf("状态映射无效: %s → %s（目标版本 v%d 中不存在该状态）", oldState, newState, to.Version)
+		}
+	}
+
+	entities, err := e.repo.ListEntityStatesByMachine(from.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &MigrationReport{
+		MachineName: plan.MachineName,
+		FromVersion: from.Version,
+		ToVersion:   to.Version,
+		DryRun:      dryRun,
+		Total:       len(entities),
+	}
+	byState := make(map[string]*StateMigrationReport)
+	var order []string
+	for _, ent := range entities {
+		r, ok := byState[ent.CurrentState]
+		if !ok {
+			target, mapped := plan.StateMap[ent.CurrentState]
+			if !mapped {
+				if !known[ent.CurrentState] {
+					report.Unmapped = append(report.Unmapped, ent.CurrentState)
+				}
+				target = ent.CurrentState
+			}
+			r = &StateMigrationReport{FromState: ent.CurrentState, ToState: target}
+			byState[ent.CurrentState] = r
+			order = append(order, ent.CurrentState)
+		}
+		r.Count++
+		r.EntityIDs = append(r.EntityIDs, ent.EntityID)
+	}
+	for _, st := range order {
+		report.States = append(report.States, *byState[st])
+	}
+
+	if dryRun {
+		return report, nil
+	}
+	if len(report.Unmapped) > 0 {
+		return report, fmt.Errorf("存在未映射的状态: %v（目标版本 v%d 中不存在）", report.Unmapped, to.Version)
+	}
+
+	eventData, _ := json.Marshal(map[string]interface{}{
+		"from_version": from.Version,
+		"to_version":   to.Version,
+	})
+	err = e.DB.Transaction(func(tx *gorm.DB) error {
+		txRepo := NewRepository(tx)
+		now := time.Now()
+		for _, ent := range entities {
+			newState := byState[ent.CurrentState].ToState
+			updated := &EntityState{
+				EntityType:     ent.EntityType,
+				EntityID:       ent.EntityID,
+				CurrentState:   newState,
+				MachineID:      to.ID,
+				MachineVersion: to.Version,
+				UpdatedAt:      now,
+			}
+			swapped, err := txRepo.CompareAndSwapEntityState(updated, ent.Version)
+			if err != nil {
+				return fmt.Errorf("迁移实体状态失败: %w", err)
+			}
+			if !swapped {
+				return fmt.Errorf("%w: entity=%s/%s 迁移期间发生变化", ErrConcurrentTransition, ent.EntityType, ent.EntityID.String())
+			}
+			if err := txRepo.SaveTransitionLog(&TransitionLog{
+				ID:              uuid.New(),
+				EntityType:      ent.EntityType,
+				EntityID:        ent.EntityID,
+				FromState:       ent.CurrentState,
+				ToState:         newState,
+				Event:           "migrate_version",
+				EventData:       eventData,
+				TriggeredBy:     "system",
+				TriggeredByType: "system",
+				CreatedAt:       now,
+			}); err != nil {
+				return fmt.Errorf("保存转换日志失败: %w", err)
+			}
+			if err := e.rescheduleTimers(txRepo, to, ent.EntityType, ent.EntityID, newState, now); err != nil {
+				return fmt.Errorf("更新定时器失败: %w", err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	log.Printf("[StateEngine] 实体迁移: machine=%s v%d→v%d entities=%d",
+		plan.MachineName, from.Version, to.Version, len(entities))
+
+	return report, nil
+}
@@ -0,0 +1,102 @@
+package expr
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// =============================================================================
+// 语法树
+// =============================================================================
+
+type node interface {
+	String() string
+}
+
+// literalNode 字面量: float64 / string / bool / nil / time.Duration
+type literalNode struct {
+	value interface{}
+	text  string // 时长字面量的原文（如 3d），用于还原表达式
+}
+
+func (n *literalNode) String() string {
+	switch v := n.value.(type) {
+	case nil:
+		return "null"
+	case string:
+		return strconv.Quote(v)
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case time.Duration:
+		if n.text != "" {
+			return n.text
+		}
+		return v.String()
+	default:
+		return fmt.Sprintf("%v", v)
+	}
+}
+
+// identNode 字段引用，支持 a.b.c 嵌套路径
+type identNode struct {
+	path []string
+}
+
+func (n *identNode) String() string { return strings.Join(n.path, ".") }
+
+// listNode 列表字面量 [a, b, c]
+type listNode struct {
+	items []node
+}
+
+func (n *listNode) String() string { return "[" + joinNodes(n.items) + "]" }
+
+// unaryNode 一元运算: ! 或 -
+type unaryNode struct {
+	op string
+	x  node
+}
+
+func (n *unaryNode) String() string {
+	if n.op == "!" {
+		return "!" + wrap(n.x)
+	}
+	return n.op + wrap(n.x)
+}
+
+// binaryNode 二元运算
+type binaryNode struct {
+	op          string
+	left, right node
+}
+
+func (n *binaryNode) String() string {
+	return wrap(n.left) + " " + n.op + " " + wrap(n.right)
+}
+
+// callNode 函数调用
+type callNode struct {
+	name string
+	args []node
+	pos  int
+}
+
+func (n *callNode) String() string { return n.name + "(" + joinNodes(n.args) + ")" }
+
+func joinNodes(nodes []node) string {
+	parts := make([]string, len(nodes))
+	for i, n := range nodes {
+		parts[i] = n.String()
+	}
+	return strings.Join(parts, ", ")
+}
+
+// wrap 复合表达式加括号，保证还原后的优先级不变
+func wrap(n node) string {
+	if _, ok := n.(*binaryNode); ok {
+		return "(" + n.String() + ")"
+	}
+	return n.String()
+}
@@ -0,0 +1,246 @@
+package expr
+
+import (
+	"fmt"
+	"time"
+)
+
+// =============================================================================
+// 静态类型检查 — 保存规则/状态机时调用，字段类型未知时按 any 处理
+// =============================================================================
+
+type kind int
+
+const (
+	kindAny kind = iota
+	kindNull
+	kindBool
+	kindNumber
+	kindString
+	kindTime
+	kindDuration
+	kindList
+)
+
+func (k kind) String() string {
+	return [...]string{"any", "null", "bool", "number", "string", "time", "duration", "list"}[k]
+}
+
+// checkBool 检查表达式，并要求结果可作为布尔条件
+func checkBool(n node) error {
+	k, err := check(n)
+	if err != nil {
+		return err
+	}
+	if k != kindBool && k != kindAny && k != kindNull {
+		return errorf("条件表达式的结果应为布尔值，实际为 %s", k)
+	}
+	return nil
+}
+
+func check(n node) (kind, error) {
+	switch n := n.(type) {
+	case *literalNode:
+		switch n.value.(type) {
+		case nil:
+			return kindNull, nil
+		case bool:
+			return kindBool, nil
+		case float64:
+			return kindNumber, nil
+		case string:
+			return kindString, nil
+		case time.Duration:
+			return kindDuration, nil
+		}
+		return kindAny, nil
+	case *identNode:
+		return kindAny, nil
+	case *listNode:
+		for _, item := range n.items {
+			if _, err := check(item); err != nil {
+				return kindAny, err
+			}
+		}
+		return kindList, nil
+	case *unaryNode:
+		k, err := check(n.x)
+		if err != nil {
+			return kindAny, err
+		}
+		if n.op == "!" {
+			if !oneOf(k, kindAny, kindNull, kindBool) {
+				return kindAny, errorf("! 需要布尔值，实际为 %s", k)
+			}
+			return kindBool, nil
+		}
+		if !oneOf(k, kindAny, kindNull, kindNumber, kindDuration) {
+			return kindAny, errorf("- 需要数值或时长，实际为 %s", k)
+		}
+		return k, nil
+	case *binaryNode:
+		return checkBinary(n)
+	case *callNode:
+		return checkCall(n)
+	}
+	return kindAny, errorf("未知节点 %T", n)
+}
+
+func checkBinary(n *binaryNode) (kind, error) {
+	l, err := check(n.left)
+	if err != nil {
+		return kindAny, err
+	}
+	r, err := check(n.right)
+	if err != nil {
+		return kindAny, err
+	}
+
+	switch n.op {
+	case "&&", "||":
+		for _, k := range []kind{l, r} {
+			if !oneOf(k, kindAny, kindNull, kindBool) {
+				return kindAny, errorf("%s 需要布尔值，实际为 %s", n.op, k)
+			}
+		}
+		return kindBool, nil
+	case "==", "!=":
+		return kindBool, nil
+	case "<", "<=", ">", ">=":
+		if !comparable(l, r) {
+			return kindAny, errorf("无法比较 %s 与 %s", l, r)
+		}
+		return kindBool, nil
+	case "=~":
+		if lit, ok := n.right.(*literalNode); ok {
+			pattern, isStr := lit.value.(string)
+			if !isStr {
+				return kindAny, errorf("正则表达式应为字符串，实际为 %s", r)
+			}
+			if _, err := compileRegex(pattern); err != nil {
+				return kindAny, err
+			}
+		}
+		return kindBool, nil
+	case "in", "not in":
+		if !oneOf(r, kindAny, kindNull, kindList, kindString) {
+			return kindAny, errorf("%s 的右侧应为列表或字符串，实际为 %s", n.op, r)
+		}
+		return kindBool, nil
+	}
+	return checkArithmetic(n.op, l, r)
+}
+
+// comparable 两种类型能否比较大小（字符串可能是数值/日期文本，允许与数值、时间比较）
+func comparable(l, r kind) bool {
+	if l == kindAny || r == kindAny || l == kindNull || r == kindNull {
+		return true
+	}
+	if l == r {
+		return oneOf(l, kindNumber, kindString, kindTime, kindDuration)
+	}
+	pair := func(a, b kind) bool { return (l == a && r == b) || (l == b && r == a) }
+	return pair(kindString, kindNumber) || pair(kindString, kindTime)
+}
+
+func checkArithmetic(op string, l, r kind) (kind, error) {
+	if l == kindNull || r == kindNull {
+		return kindNull, nil
+	}
+	if l == kindAny || r == kindAny {
+		// 一侧类型已知时推断结果类型
+		switch {
+		case op == "+" && (l == kindTime || r == kindTime):
+			return kindTime, nil
+		case l == kindDuration && r == kindDuration:
+			return kindDuration, nil
+		}
+		return kindAny, nil
+	}
+
+	type sig struct {
+		op   string
+		l, r kind
+	}
+	results := map[sig]kind{
+		{"+", kindNumber, kindNumber}:     kindNumber,
+		{"-", kindNumber, kindNumber}:     kindNumber,
+		{"*", kindNumber, kindNumber}:     kindNumber,
+		{"/", kindNumber, kindNumber}:     kindNumber,
+		{"%", kindNumber, kindNumber}:     kindNumber,
+		{"+", kindString, kindString}:     kindString,
+		{"+", kindTime, kindDuration}:     kindTime,
+		{"-", kindTime, kindDuration}:     kindTime,
+		{"+", kindDuration, kindTime}:     kindTime,
+		{"-", kindTime, kindTime}:         kindDuration,
+		{"+", kindDuration, kindDuration}: kindDuration,
+		{"-", kindDuration, kindDuration}: kindDuration,
+		{"*", kindDuration, kindNumber}:   kindDuration,
+		{"*", kindNumber, kindDuration}:   kindDuration,
+		{"/", kindDuration, kindNumber}:   kindDuration,
+	}
+	if k, ok := results[sig{op, l, r}]; ok {
+		return k, nil
+	}
+	return kindAny, errorf("不支持 %s %s %s", l, op, r)
+}
+
+func checkCall(n *callNode) (kind, error) {
+	argKinds := make([]kind, len(n.args))
+	for i, a := range n.args {
+		k, err := check(a)
+		if err != nil {
+			return kindAny, err
+		}
+		argKinds[i] = k
+	}
+
+	if quantifiers[n.name] {
+		if len(n.args) != 1 && len(n.args) != 2 {
+			return kindAny, arityError(n, "1 或 2")
+		}
+		if !oneOf(argKinds[0], kindAny, kindNull, kindList) {
+			return kindAny, &Error{Pos: n.pos, Msg: fmt.Sprintf("%s 的第一个参数应为列表，实际为 %s", n.name, argKinds[0])}
+		}
+		if len(n.args) == 2 && !oneOf(argKinds[1], kindAny, kindNull, kindBool) {
+			return kindAny, &Error{Pos: n.pos, Msg: fmt.Sprintf("%s 的条件应为布尔值，实际为 %s", n.name, argKinds[1])}
+		}
+		return kindBool, nil
+	}
+
+	fn, ok := functions[n.name]
+	if !ok {
+		return kindAny, &Error{Pos: n.pos, Msg: "未知函数 " + n.name}
+	}
+	if fn.variadic {
+		if len(n.args) < len(fn.args) {
+			return kindAny, arityError(n, fmt.Sprintf("至少 %d", len(fn.args)))
+		}
+	} else if len(n.args) != len(fn.args) {
+		return kindAny, arityError(n, fmt.Sprintf("%d", len(fn.args)))
+	}
+	for i, k := range argKinds {
+		want := fn.args[len(fn.args)-1]
+		if i < len(fn.args) {
+			want = fn.args[i]
+		}
+		if want == kindAny || oneOf(k, kindAny, kindNull, want) {
+			continue
+		}
+		// 字符串参数允许传入日期文本
+		if want == kindTime && k == kindString {
+			continue
+		}
+		return kindAny, &Error{Pos: n.pos, Msg: fmt.Sprintf("%s 的第 %d 个参数应为 %s，实际为 %s", n.name, i+1, want, k)}
+	}
+	return fn.result, nil
+}
+
+func oneOf(k kind, kinds ...kind) bool {
+	for _, want := range kinds {
+		if k == want {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,234 @@
+package expr
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// =============================================================================
+// JSON 条件 — 状态机转换条件、路由规则共用
+// =============================================================================
+
+// jsonOps JSON 简单条件的操作符 → 表达式运算符
+var jsonOps = map[string]string{
+	"eq":  "==",
+	"ne":  "!=",
+	"neq": "!=",
+	"gt":  ">",
+	"gte": ">=",
+	"lt":  "<",
+	"lte": "<=",
+	"in":  "in",
+	// 以下以函数/组合形式构造，见 fromSimple
+	"not_in":       "",
+	"contains":     "",
+	"not_contains": "",
+	"regex":        "",
+	"matches":      "",
+	"starts_with":  "",
+	"ends_with":    "",
+	"is_null":      "",
+	"not_null":     "",
+}
+
+// CompileCondition 编译 JSON 条件，支持以下格式（可嵌套）:
+//
+//	"due_date < now() + 3d"                              表达式字符串
+//	{"expr": "amount * qty > 10000"}                     表达式对象
+//	{"field": "review_result", "op": "eq", "value": "pass"}
+//	{"and": [...]} / {"or": [...]} / {"not": {...}}
+//	{"operator": "and", "conditions": [...]}             路由规则格式
+//
+// 空条件（null、{}）返回 nil，表示无条件通过
+func CompileCondition(raw json.RawMessage) (*Program, error) {
+	trimmed := strings.TrimSpace(string(raw))
+	if trimmed == "" || trimmed == "null" || trimmed == "{}" || trimmed == `""` {
+		return nil, nil
+	}
+
+	var cond interface{}
+	if err := json.Unmarshal(raw, &cond); err != nil {
+		return nil, &Error{Pos: -1, Msg: "条件不是合法的 JSON: " + err.Error()}
+	}
+	root, err := fromJSON(cond)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkBool(root); err != nil {
+		return nil, withExpr(err, root.String())
+	}
+	return &Program{source: root.String(), root: root}, nil
+}
+
+// ValidateCondition 校验 JSON 条件（解析 + 类型检查），保存规则时调用
+func ValidateCondition(raw json.RawMessage) error {
+	_, err := CompileCondition(raw)
+	return err
+}
+
+var conditionCache sync.Map // 条件 JSON → *Program
+
+// EvaluateCondition 评估 JSON 条件；空条件返回 true
+// 条件无法解析或求值出错时返回错误（不再当作 false 处理）
+func EvaluateCondition(raw json.RawMessage, vars map[string]interface{}) (bool, error) {
+	key := string(raw)
+	var prog *Program
+	if cached, ok := conditionCache.Load(key); ok {
+		prog = cached.(*Program)
+	} else {
+		var err error
+		if prog, err = CompileCondition(raw); err != nil {
+			return false, err
+		}
+		conditionCache.Store(key, prog)
+	}
+	if prog == nil {
+		return true, nil
+	}
+	return prog.EvalBool(vars)
+}
+
+// DescribeCondition 将 JSON 条件转为表达式文本（用于流程图、日志）
+func DescribeCondition(raw json.RawMessage) string {
+	prog, err := CompileCondition(raw)
+	if err != nil {
+		return string(raw)
+	}
+	if prog == nil {
+		return ""
+	}
+	return prog.String()
+}
+
+func fromJSON(cond interface{}) (node, error) {
+	switch c := cond.(type) {
+	case string:
+		root, err := parse(c)
+		if err != nil {
+			return nil, withExpr(err, c)
+		}
+		return root, nil
+	case bool:
+		return &literalNode{value: c}, nil
+	case map[string]interface{}:
+		return fromObject(c)
+	}
+	return nil, errorf("条件格式错误: %v", cond)
+}
+
+func fromObject(c map[string]interface{}) (node, error) {
+	if len(c) == 0 {
+		return &literalNode{value: true}, nil
+	}
+	if src, ok := c["expr"]; ok {
+		s, isStr := src.(string)
+		if !isStr {
+			return nil, errorf("expr 应为字符串")
+		}
+		return fromJSON(s)
+	}
+	if sub, ok := c["and"]; ok {
+		return fromGroup("and", sub)
+	}
+	if sub, ok := c["or"]; ok {
+		return fromGroup("or", sub)
+	}
+	if sub, ok := c["not"]; ok {
+		x, err := fromJSON(sub)
+		if err != nil {
+			return nil, err
+		}
+		return &unaryNode{op: "!", x: x}, nil
+	}
+	if op, ok := c["operator"]; ok {
+		name, _ := op.(string)
+		name = strings.ToLower(name)
+		if name != "and" && name != "or" {
+			return nil, errorf("不支持的组合操作符: %v", op)
+		}
+		return fromGroup(name, c["conditions"])
+	}
+	return fromSimple(c)
+}
+
+// fromGroup and/or 组合；空 and 为 true，空 or 为 false
+func fromGroup(op string, sub interface{}) (node, error) {
+	list, ok := sub.([]interface{})
+	if !ok {
+		return nil, errorf("%s 条件应为数组", op)
+	}
+	if len(list) == 0 {
+		return &literalNode{value: op == "and"}, nil
+	}
+	binOp := "&&"
+	if op == "or" {
+		binOp = "||"
+	}
+	var root node
+	for _, item := range list {
+		n, err := fromJSON(item)
+		if err != nil {
+			return nil, err
+		}
+		if root == nil {
+			root = n
+		} else {
+			root = &binaryNode{op: binOp, left: root, right: n}
+		}
+	}
+	return root, nil
+}
+
+// fromSimple {"field": "xxx", "op": "eq", "value": yyy}
+func fromSimple(c map[string]interface{}) (node, error) {
+	fieldName, _ := c["field"].(string)
+	op, _ := c["op"].(string)
+	if fieldName == "" || op == "" {
+		return nil, errorf("条件缺少 field 或 op: %v", c)
+	}
+	if _, ok := jsonOps[op]; !ok {
+		return nil, errorf("不支持的操作符: %s", op)
+	}
+
+	fieldNode := &identNode{path: strings.Split(fieldName, ".")}
+	value := valueNode(c["value"])
+
+	switch op {
+	case "not_in":
+		return &binaryNode{op: "not in", left: fieldNode, right: value}, nil
+	case "contains":
+		return &callNode{name: "contains", args: []node{fieldNode, value}}, nil
+	case "not_contains":
+		return &unaryNode{op: "!", x: &callNode{name: "contains", args: []node{fieldNode, value}}}, nil
+	case "regex", "matches":
+		return &binaryNode{op: "=~", left: fieldNode, right: value}, nil
+	case "starts_with", "ends_with":
+		return &callNode{name: op, args: []node{fieldNode, value}}, nil
+	case "is_null":
+		return &binaryNode{op: "==", left: fieldNode, right: &literalNode{}}, nil
+	case "not_null":
+		return &binaryNode{op: "!=", left: fieldNode, right: &literalNode{}}, nil
+	}
+	return &binaryNode{op: jsonOps[op], left: fieldNode, right: value}, nil
+}
+
+// valueNode JSON 值 → 字面量节点
+func valueNode(v interface{}) node {
+	if list, ok := v.([]interface{}); ok {
+		items := make([]node, len(list))
+		for i, item := range list {
+			items[i] = valueNode(item)
+		}
+		return &listNode{items: items}
+	}
+	if v == nil {
+		return &literalNode{}
+	}
+	switch x := v.(type) {
+	case bool, float64, string:
+		return &literalNode{value: x}
+	}
+	return &literalNode{value: fmt.Sprintf("%v", v)}
+}
@@ -0,0 +1,641 @@
+package expr
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// =============================================================================
+// 求值
+// =============================================================================
+
+// Error 表达式解析/类型检查/求值错误
+type Error struct {
+	Expr string `json:"expr,omitempty"` // 出错的表达式
+	Pos  int    `json:"pos"`            // 出错位置（字符偏移，-1 表示未知）
+	Msg  string `json:"message"`
+}
+
+func (e *Error) Error() string {
+	if e.Expr == "" {
+		return "表达式错误: " + e.Msg
+	}
+	if e.Pos >= 0 {
+		return fmt.Sprintf("表达式错误 [%s] 位置 %d: %s", e.Expr, e.Pos, e.Msg)
+	}
+	return fmt.Sprintf("表达式错误 [%s]: %s", e.Expr, e.Msg)
+}
+
+// Program 已编译（解析 + 类型检查）的表达式
+type Program struct {
+	source string
+	root   node
+}
+
+// Compile 解析并类型检查表达式
+func Compile(source string) (*Program, error) {
+	root, err := parse(source)
+	if err != nil {
+		return nil, withExpr(err, source)
+	}
+	if err := checkBool(root); err != nil {
+		return nil, withExpr(err, source)
+	}
+	return &Program{source: source, root: root}, nil
+}
+
+// String 返回规范化后的表达式文本
+func (p *Program) String() string { return p.root.String() }
+
+// Eval 以 vars 为上下文求值，返回原始结果
+func (p *Program) Eval(vars map[string]interface{}) (interface{}, error) {
+	env := &evalEnv{vars: vars, now: time.Now()}
+	v, err := env.eval(p.root)
+	if err != nil {
+		return nil, withExpr(err, p.source)
+	}
+	return v, nil
+}
+
+// EvalBool 求值并要求结果为布尔值（null 视为 false）
+func (p *Program) EvalBool(vars map[string]interface{}) (bool, error) {
+	v, err := p.Eval(vars)
+	if err != nil {
+		return false, err
+	}
+	switch b := v.(type) {
+	case nil:
+		return false, nil
+	case bool:
+		return b, nil
+	default:
+		return false, &Error{Expr: p.source, Pos: -1, Msg: fmt.Sprintf("结果应为布尔值，实际为 %s", kindOf(v))}
+	}
+}
+
+func withExpr(err error, source string) error {
+	if e, ok := err.(*Error); ok && e.Expr == "" {
+		return &Error{Expr: source, Pos: e.Pos, Msg: e.Msg}
+	}
+	return err
+}
+
+func errorf(format string, args ...interface{}) error {
+	return &Error{Pos: -1, Msg: fmt.Sprintf(format, args...)}
+}
+
+// evalEnv 求值环境
+type evalEnv struct {
+	vars   map[string]interface{}
+	locals map[string]interface{} // any/all 中的 it
+	now    time.Time
+}
+
+func (env *evalEnv) eval(n node) (interface{}, error) {
+	switch n := n.(type) {
+	case *literalNode:
+		return n.value, nil
+	case *identNode:
+		return env.lookup(n.path), nil
+	case *listNode:
+		items := make([]interface{}, 0, len(n.items))
+		for _, item := range n.items {
+			v, err := env.eval(item)
+			if err != nil {
+				return nil, err
+			}
+			items = append(items, v)
+		}
+		return items, nil
+	case *unaryNode:
+		x, err := env.eval(n.x)
+		if err != nil {
+			return nil, err
+		}
+		return evalUnary(n.op, x)
+	case *binaryNode:
+		return env.evalBinary(n)
+	case *callNode:
+		return env.evalCall(n)
+	}
+	return nil, errorf("未知节点 %T", n)
+}
+
+// lookup 按路径取值，任一层缺失返回 nil
+func (env *evalEnv) lookup(path []string) interface{} {
+	var cur interface{}
+	if v, ok := env.locals[path[0]]; ok {
+		cur = v
+	} else {
+		cur = env.vars[path[0]]
+	}
+	for _, key := range path[1:] {
+		cur = field(cur, key)
+	}
+	return normalize(cur)
+}
+
+// field 取 map/结构体的字段
+func field(v interface{}, key string) interface{} {
+	if v == nil {
+		return nil
+	}
+	if m, ok := v.(map[string]interface{}); ok {
+		return m[key]
+	}
+	rv := reflect.ValueOf(v)
+	for rv.Kind() == reflect.Ptr {
+		if rv.IsNil() {
+			return nil
+		}
+		rv = rv.Elem()
+	}
+	switch rv.Kind() {
+	case reflect.Map:
+		if rv.Type().Key().Kind() != reflect.String {
+			return nil
+		}
+		val := rv.MapIndex(reflect.ValueOf(key).Convert(rv.Type().Key()))
+		if !val.IsValid() {
+			return nil
+		}
+		return val.Interface()
+	case reflect.Struct:
+		// 按 json tag 或字段名匹配
+		t := rv.Type()
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			name := strings.Split(f.Tag.Get("json"), ",")[0]
+			if name == key || (name == "" && strings.EqualFold(f.Name, key)) {
+				if f.IsExported() {
+					return rv.Field(i).Interface()
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// normalize 统一值的类型: 整数 → float64，切片 → []interface{}，指针解引用
+func normalize(v interface{}) interface{} {
+	switch x := v.(type) {
+	case nil, bool, string, float64, time.Time, time.Duration, []interface{}, map[string]interface{}:
+		return x
+	case int:
+		return float64(x)
+	case int8:
+		return float64(x)
+	case int16:
+		return float64(x)
+	case int32:
+		return float64(x)
+	case int64:
+		return float64(x)
+	case uint:
+		return float64(x)
+	case uint8:
+		return float64(x)
+	case uint16:
+		return float64(x)
+	case uint32:
+		return float64(x)
+	case uint64:
+		return float64(x)
+	case float32:
+		return float64(x)
+	case json.Number:
+		if f, err := x.Float64(); err == nil {
+			return f
+		}
+		return x.String()
+	case *time.Time:
+		if x == nil {
+			return nil
+		}
+		return *x
+	case fmt.Stringer:
+		if reflect.ValueOf(v).Kind() != reflect.Struct {
+			return x.String()
+		}
+	}
+
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Ptr:
+		if rv.IsNil() {
+			return nil
+		}
+		return normalize(rv.Elem().Interface())
+	case reflect.Slice, reflect.Array:
+		if rv.Kind() == reflect.Slice && rv.IsNil() {
+			return nil
+		}
+		items := make([]interface{}, rv.Len())
+		for i := range items {
+			items[i] = normalize(rv.Index(i).Interface())
+		}
+		return items
+	case reflect.String:
+		return rv.String()
+	case reflect.Bool:
+		return rv.Bool()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(rv.Int())
+	case reflect.Float32, reflect.Float64:
+		return rv.Float()
+	}
+	return v
+}
+
+func evalUnary(op string, x interface{}) (interface{}, error) {
+	switch op {
+	case "!":
+		switch b := x.(type) {
+		case nil:
+			return true, nil
+		case bool:
+			return !b, nil
+		}
+		return nil, errorf("! 需要布尔值，实际为 %s", kindOf(x))
+	case "-":
+		switch v := x.(type) {
+		case nil:
+			return nil, nil
+		case float64:
+			return -v, nil
+		case time.Duration:
+			return -v, nil
+		}
+		return nil, errorf("- 需要数值或时长，实际为 %s", kindOf(x))
+	}
+	return nil, errorf("未知运算符 %s", op)
+}
+
+func (env *evalEnv) evalBinary(n *binaryNode) (interface{}, error) {
+	left, err := env.eval(n.left)
+	if err != nil {
+		return nil, err
+	}
+
+	// 逻辑运算短路
+	if n.op == "&&" || n.op == "||" {
+		lb, err := truthy(n.op, left)
+		if err != nil {
+			return nil, err
+		}
+		if n.op == "&&" && !lb {
+			return false, nil
+		}
+		if n.op == "||" && lb {
+			return true, nil
+		}
+		right, err := env.eval(n.right)
+		if err != nil {
+			return nil, err
+		}
+		return truthy(n.op, right)
+	}
+
+	right, err := env.eval(n.right)
+	if err != nil {
+		return nil, err
+	}
+
+	switch n.op {
+	case "==":
+		return equal(left, right), nil
+	case "!=":
+		return !equal(left, right), nil
+	case "<", "<=", ">", ">=":
+		if left == nil || right == nil {
+			return false, nil // 与 null 比较大小恒为 false
+		}
+		c, err := compare(left, right)
+		if err != nil {
+			return nil, err
+		}
+		switch n.op {
+		case "<":
+			return c < 0, nil
+		case "<=":
+			return c <= 0, nil
+		case ">":
+			return c > 0, nil
+		default:
+			return c >= 0, nil
+		}
+	case "=~":
+		return matchRegex(left, right)
+	case "in":
+		return contains(right, left)
+	case "not in":
+		in, err := contains(right, left)
+		if err != nil {
+			return nil, err
+		}
+		return !in, nil
+	default:
+		return arithmetic(n.op, left, right)
+	}
+}
+
+func truthy(op string, v interface{}) (bool, error) {
+	switch b := v.(type) {
+	case nil:
+		return false, nil
+	case bool:
+		return b, nil
+	}
+	return false, errorf("%s 需要布尔值，实际为 %s", op, kindOf(v))
+}
+
+// equal 宽松相等: 数值按数值比较，字符串可与数值/布尔/时间互相比较
+func equal(a, b interface{}) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	switch x := a.(type) {
+	case float64:
+		if f, ok := asNumber(b); ok {
+			return x == f
+		}
+	case bool:
+		if y, ok := asBool(b); ok {
+			return x == y
+		}
+	case time.Time:
+		if t, ok := asTime(b); ok {
+			return x.Equal(t)
+		}
+	case string:
+		switch b.(type) {
+		case float64, bool, time.Time:
+			return equal(b, a)
+		}
+	}
+	return reflect.DeepEqual(a, b) || fmt.Sprintf("%v", a) == fmt.Sprintf("%v", b)
+}
+
+// compare 比较大小，返回 -1 / 0 / 1；类型无法比较时返回错误
+func compare(a, b interface{}) (int, error) {
+	switch x := a.(type) {
+	case float64:
+		if f, ok := asNumber(b); ok {
+			return cmpFloat(x, f), nil
+		}
+	case time.Time:
+		if t, ok := asTime(b); ok {
+			return cmpTime(x, t), nil
+		}
+	case time.Duration:
+		if d, ok := b.(time.Duration); ok {
+			return cmpFloat(float64(x), float64(d)), nil
+		}
+	case string:
+		switch y := b.(type) {
+		case string:
+			return strings.Compare(x, y), nil
+		case float64, time.Time:
+			c, err := compare(b, a)
+			return -c, err
+		}
+	}
+	return 0, errorf("无法比较 %s 与 %s", kindOf(a), kindOf(b))
+}
+
+func cmpFloat(a, b float64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+func cmpTime(a, b time.Time) int {
+	switch {
+	case a.Before(b):
+		return -1
+	case a.After(b):
+		return 1
+	}
+	return 0
+}
+
+func arithmetic(op string, a, b interface{}) (interface{}, error) {
+	if a == nil || b == nil {
+		return nil, nil // null 参与运算结果为 null
+	}
+	switch x := a.(type) {
+	case float64:
+		y, ok := asNumber(b)
+		if !ok {
+			if d, isDur := b.(time.Duration); isDur && op == "*" {
+				return time.Duration(x * float64(d)), nil
+			}
+			break
+		}
+		switch op {
+		case "+":
+			return x + y, nil
+		case "-":
+			return x - y, nil
+		case "*":
+			return x * y, nil
+		case "/":
+			if y == 0 {
+				return nil, errorf("除数为 0")
+			}
+			return x / y, nil
+		case "%":
+			if y == 0 {
+				return nil, errorf("除数为 0")
+			}
+			return math.Mod(x, y), nil
+		}
+	case string:
+		if y, ok := b.(string); ok && op == "+" {
+			return x + y, nil
+		}
+		if t, ok := asTime(x); ok {
+			return arithmetic(op, t, b)
+		}
+	case time.Time:
+		switch y := b.(type) {
+		case time.Duration:
+			switch op {
+			case "+":
+				return x.Add(y), nil
+			case "-":
+				return x.Add(-y), nil
+			}
+		default:
+			if t, ok := asTime(b); ok && op == "-" {
+				return x.Sub(t), nil
+			}
+		}
+	case time.Duration:
+		switch y := b.(type) {
+		case time.Duration:
+			switch op {
+			case "+":
+				return x + y, nil
+			case "-":
+				return x - y, nil
+			}
+		case time.Time:
+			if op == "+" {
+				return y.Add(x), nil
+			}
+		case float64:
+			switch op {
+			case "*":
+				return time.Duration(float64(x) * y), nil
+			case "/":
+				if y == 0 {
+					return nil, errorf("除数为 0")
+				}
+				return time.Duration(float64(x) / y), nil
+			}
+		}
+	}
+	return nil, errorf("不支持 %s %s %s", kindOf(a), op, kindOf(b))
+}
+
+// contains 判断 needle 是否在 haystack（列表或字符串）中
+func contains(haystack, needle interface{}) (bool, error) {
+	switch h := haystack.(type) {
+	case nil:
+		return false, nil
+	case []interface{}:
+		for _, item := range h {
+			if equal(item, needle) {
+				return true, nil
+			}
+		}
+		return false, nil
+	case string:
+		if needle == nil {
+			return false, nil
+		}
+		return strings.Contains(h, fmt.Sprintf("%v", needle)), nil
+	}
+	return false, errorf("in 的右侧应为列表或字符串，实际为 %s", kindOf(haystack))
+}
+
+var regexCache sync.Map // pattern → *regexp.Regexp
+
+func compileRegex(pattern string) (*regexp.Regexp, error) {
+	if re, ok := regexCache.Load(pattern); ok {
+		return re.(*regexp.Regexp), nil
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, errorf("无效的正则表达式 %q: %v", pattern, err)
+	}
+	regexCache.Store(pattern, re)
+	return re, nil
+}
+
+func matchRegex(s, pattern interface{}) (bool, error) {
+	if s == nil {
+		return false, nil
+	}
+	p, ok := pattern.(string)
+	if !ok {
+		return false, errorf("正则表达式应为字符串，实际为 %s", kindOf(pattern))
+	}
+	re, err := compileRegex(p)
+	if err != nil {
+		return false, err
+	}
+	str, ok := s.(string)
+	if !ok {
+		str = fmt.Sprintf("%v", s)
+	}
+	return re.MatchString(str), nil
+}
+
+// asNumber 数值或可解析为数值的字符串
+func asNumber(v interface{}) (float64, bool) {
+	switch x := v.(type) {
+	case float64:
+		return x, true
+	case string:
+		f, err := strconv.ParseFloat(strings.TrimSpace(x), 64)
+		return f, err == nil
+	}
+	return 0, false
+}
+
+func asBool(v interface{}) (bool, bool) {
+	switch x := v.(type) {
+	case bool:
+		return x, true
+	case string:
+		switch strings.ToLower(x) {
+		case "true", "1", "yes":
+			return true, true
+		case "false", "0", "no":
+			return false, true
+		}
+	case float64:
+		return x != 0, true
+	}
+	return false, false
+}
+
+// timeLayouts 字符串转时间时尝试的格式
+var timeLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04:05",
+	"2006-01-02 15:04",
+	"2006-01-02",
+}
+
+// asTime 时间或可解析为时间的字符串
+func asTime(v interface{}) (time.Time, bool) {
+	switch x := v.(type) {
+	case time.Time:
+		return x, true
+	case string:
+		for _, layout := range timeLayouts {
+			if t, err := time.ParseInLocation(layout, x, time.Local); err == nil {
+				return t, true
+			}
+		}
+	}
+	return time.Time{}, false
+}
+
+// kindOf 值的类型名（用于错误信息）
+func kindOf(v interface{}) string {
+	switch v.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "bool"
+	case float64:
+		return "number"
+	case string:
+		return "string"
+	case time.Time:
+		return "time"
+	case time.Duration:
+		return "duration"
+	case []interface{}:
+		return "list"
+	case map[string]interface{}:
+		return "object"
+	}
+	return fmt.Sprintf("%T", v)
+}
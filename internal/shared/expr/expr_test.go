@@ -0,0 +1,132 @@
+package expr
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func evalBool(t *testing.T, src string, vars map[string]interface{}) bool {
+	t.Helper()
+	prog, err := Compile(src)
+	if !assert.NoError(t, err, src) {
+		return false
+	}
+	ok, err := prog.EvalBool(vars)
+	assert.NoError(t, err, src)
+	return ok
+}
+
+func TestExpressionOperators(t *testing.T) {
+	vars := map[string]interface{}{
+		"amount":   1200,
+		"qty":      3,
+		"category": "Electronic",
+		"code":     "PO-2026-0012",
+		"tags":     []string{"urgent", "hw"},
+		"supplier": map[string]interface{}{"level": "A", "score": 92.5},
+	}
+
+	cases := map[string]bool{
+		"amount * qty > 3000":                                  true,
+		"(amount + 300) / 3 == 500":                            true,
+		"amount % 7 == 3":                                      true,
+		"lower(category) == 'electronic'":                      true,
+		"starts_with(code, 'PO-') && len(code) == 12":          true,
+		`code =~ "^PO-\\d{4}-\\d+$"`:                           true,
+		"'urgent' in tags and 'sw' not in tags":                true,
+		"supplier.level in ['A', 'B'] && supplier.score >= 90": true,
+		"not (supplier.level == 'C')":                          true,
+		"any(tags, it == 'hw')":                                true,
+		"all(tags, len(it) > 2)":                               false,
+		"coalesce(discount, 0) == 0":                           true,
+		"max(amount, qty, 5000) == 5000":                       true,
+	}
+	for src, want := range cases {
+		assert.Equal(t, want, evalBool(t, src, vars), src)
+	}
+}
+
+func TestExpressionDates(t *testing.T) {
+	vars := map[string]interface{}{
+		"due_date":   time.Now().Add(48 * time.Hour),
+		"created_at": "2026-01-05",
+		"started":    time.Now().Add(-36 * time.Hour),
+	}
+	assert.True(t, evalBool(t, "due_date < now() + 3d", vars))
+	assert.False(t, evalBool(t, "due_date < now() + 1d", vars))
+	assert.True(t, evalBool(t, "created_at < date('2026-02-01')", vars))
+	assert.True(t, evalBool(t, "now() - started > 1d12h - 1m", vars))
+	assert.True(t, evalBool(t, "days(now() - started) >= 1.5", vars))
+}
+
+func TestExpressionNullHandling(t *testing.T) {
+	vars := map[string]interface{}{"owner": nil}
+	assert.True(t, evalBool(t, "owner == null", vars))
+	assert.True(t, evalBool(t, "missing.deep.field == null", vars))
+	assert.False(t, evalBool(t, "missing > 3", vars), "与 null 比较大小为 false")
+	assert.True(t, evalBool(t, "is_null(missing + 1)", vars), "null 参与运算结果为 null")
+	assert.False(t, evalBool(t, "any(missing, it > 0)", vars))
+	assert.True(t, evalBool(t, "all(missing, it > 0)", vars))
+}
+
+func TestExpressionCompileErrors(t *testing.T) {
+	for _, src := range []string{
+		"amount >",
+		"unknown_fn(1)",
+		"len(1, 2)",
+		"'abc' > 3d",
+		"now() + 'x'",
+		"1 + 2",
+		`code =~ "("`,
+		"3days > 1",
+	} {
+		_, err := Compile(src)
+		assert.Error(t, err, src)
+	}
+}
+
+func TestExpressionRuntimeErrors(t *testing.T) {
+	prog, err := Compile("amount / qty > 1")
+	assert.NoError(t, err)
+	_, err = prog.EvalBool(map[string]interface{}{"amount": 1, "qty": 0})
+	assert.Error(t, err)
+
+	prog, err = Compile("owner > 3")
+	assert.NoError(t, err)
+	_, err = prog.EvalBool(map[string]interface{}{"owner": true})
+	assert.Error(t, err, "类型不匹配应报错而不是返回 false")
+
+	prog, err = Compile("flag")
+	assert.NoError(t, err)
+	_, err = prog.EvalBool(map[string]interface{}{"flag": "yes"})
+	assert.Error(t, err)
+}
+
+func TestConditionJSONFormats(t *testing.T) {
+	vars := map[string]interface{}{"needs_review": true, "priority": "high", "amount": 800.0}
+
+	cases := map[string]bool{
+		`null`: true,
+		`{}`:   true,
+		`{"field": "needs_review", "op": "eq", "value": true}`:                                                                                 true,
+		`{"and": [{"field": "priority", "op": "in", "value": ["high", "urgent"]}, {"field": "amount", "op": "lt", "value": 1000}]}`:            true,
+		`{"operator": "or", "conditions": [{"field": "priority", "op": "eq", "value": "low"}, {"field": "owner", "op": "neq", "value": "x"}]}`: true,
+		`{"field": "owner", "op": "not_contains", "value": "x"}`:                                                                               true,
+		`{"not": {"field": "amount", "op": "gte", "value": 500}}`:                                                                              false,
+		`"amount * 2 > 1500 && priority == 'high'"`:                                                                                            true,
+		`{"expr": "needs_review && amount > 1000"}`:                                                                                            false,
+	}
+	for src, want := range cases {
+		got, err := EvaluateCondition(json.RawMessage(src), vars)
+		assert.NoError(t, err, src)
+		assert.Equal(t, want, got, src)
+	}
+
+	_, err := EvaluateCondition(json.RawMessage(`{"field": "amount", "op": "between", "value": 1}`), vars)
+	assert.Error(t, err, "未知操作符应报错")
+
+	assert.Equal(t, "needs_review == true", DescribeCondition(json.RawMessage(`{"field": "needs_review", "op": "eq", "value": true}`)))
+}
@@ -0,0 +1,258 @@
+package expr
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"time"
+)
+
+// =============================================================================
+// 内置函数
+// =============================================================================
+
+// function 内置函数定义
+// args 为参数类型（kindAny 表示不限），variadic 时最后一个类型可重复
+type function struct {
+	args     []kind
+	variadic bool
+	result   kind
+	call     func(env *evalEnv, args []interface{}) (interface{}, error)
+}
+
+var functions map[string]function
+
+func init() {
+	functions = map[string]function{
+		// 时间
+		"now":   {result: kindTime, call: func(env *evalEnv, _ []interface{}) (interface{}, error) { return env.now, nil }},
+		"today": {result: kindTime, call: fnToday},
+		"date":  {args: []kind{kindString}, result: kindTime, call: fnDate},
+		"days":  {args: []kind{kindDuration}, result: kindNumber, call: durationIn(24 * time.Hour)},
+		"hours": {args: []kind{kindDuration}, result: kindNumber, call: durationIn(time.Hour)},
+
+		// 字符串
+		"lower":       {args: []kind{kindString}, result: kindString, call: stringFn(strings.ToLower)},
+		"upper":       {args: []kind{kindString}, result: kindString, call: stringFn(strings.ToUpper)},
+		"trim":        {args: []kind{kindString}, result: kindString, call: stringFn(strings.TrimSpace)},
+		"starts_with": {args: []kind{kindString, kindString}, result: kindBool, call: stringPredicate(strings.HasPrefix)},
+		"ends_with":   {args: []kind{kindString, kindString}, result: kindBool, call: stringPredicate(strings.HasSuffix)},
+		"matches":     {args: []kind{kindString, kindString}, result: kindBool, call: fnMatches},
+		"contains":    {args: []kind{kindAny, kindAny}, result: kindBool, call: fnContains},
+		"len":         {args: []kind{kindAny}, result: kindNumber, call: fnLen},
+
+		// 空值
+		"coalesce": {args: []kind{kindAny}, variadic: true, result: kindAny, call: fnCoalesce},
+		"is_null":  {args: []kind{kindAny}, result: kindBool, call: func(_ *evalEnv, a []interface{}) (interface{}, error) { return a[0] == nil, nil }},
+		"is_empty": {args: []kind{kindAny}, result: kindBool, call: fnIsEmpty},
+
+		// 数值
+		"abs":   {args: []kind{kindNumber}, result: kindNumber, call: mathFn(math.Abs)},
+		"round": {args: []kind{kindNumber}, result: kindNumber, call: mathFn(math.Round)},
+		"floor": {args: []kind{kindNumber}, result: kindNumber, call: mathFn(math.Floor)},
+		"ceil":  {args: []kind{kindNumber}, result: kindNumber, call: mathFn(math.Ceil)},
+		"min":   {args: []kind{kindNumber}, variadic: true, result: kindNumber, call: extremum(-1)},
+		"max":   {args: []kind{kindNumber}, variadic: true, result: kindNumber, call: extremum(1)},
+	}
+}
+
+// quantifiers 列表量词: any(list, 条件) / all(list, 条件)，条件中用 it 引用当前元素
+var quantifiers = map[string]bool{"any": true, "all": true}
+
+func (env *evalEnv) evalCall(n *callNode) (interface{}, error) {
+	if quantifiers[n.name] {
+		return env.evalQuantifier(n)
+	}
+	fn, ok := functions[n.name]
+	if !ok {
+		return nil, &Error{Pos: n.pos, Msg: "未知函数 " + n.name}
+	}
+	args := make([]interface{}, len(n.args))
+	for i, a := range n.args {
+		v, err := env.eval(a)
+		if err != nil {
+			return nil, err
+		}
+		args[i] = v
+	}
+	return fn.call(env, args)
+}
+
+// evalQuantifier any/all: 空列表时 any=false、all=true；null 视为空列表
+func (env *evalEnv) evalQuantifier(n *callNode) (interface{}, error) {
+	listVal, err := env.eval(n.args[0])
+	if err != nil {
+		return nil, err
+	}
+	var items []interface{}
+	switch l := listVal.(type) {
+	case nil:
+	case []interface{}:
+		items = l
+	default:
+		return nil, errorf("%s 的第一个参数应为列表，实际为 %s", n.name, kindOf(listVal))
+	}
+
+	isAll := n.name == "all"
+	for _, item := range items {
+		var v interface{} = item
+		if len(n.args) == 2 {
+			inner := &evalEnv{vars: env.vars, now: env.now, locals: map[string]interface{}{"it": item}}
+			for k, val := range env.locals {
+				if k != "it" {
+					inner.locals[k] = val
+				}
+			}
+			if v, err = inner.eval(n.args[1]); err != nil {
+				return nil, err
+			}
+		}
+		b, err := truthy(n.name, v)
+		if err != nil {
+			return nil, err
+		}
+		if isAll && !b {
+			return false, nil
+		}
+		if !isAll && b {
+			return true, nil
+		}
+	}
+	return isAll, nil
+}
+
+func fnToday(env *evalEnv, _ []interface{}) (interface{}, error) {
+	y, m, d := env.now.Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, env.now.Location()), nil
+}
+
+func fnDate(_ *evalEnv, args []interface{}) (interface{}, error) {
+	if args[0] == nil {
+		return nil, nil
+	}
+	t, ok := asTime(args[0])
+	if !ok {
+		return nil, errorf("无法解析日期 %v", args[0])
+	}
+	return t, nil
+}
+
+func durationIn(unit time.Duration) func(*evalEnv, []interface{}) (interface{}, error) {
+	return func(_ *evalEnv, args []interface{}) (interface{}, error) {
+		switch d := args[0].(type) {
+		case nil:
+			return nil, nil
+		case time.Duration:
+			return float64(d) / float64(unit), nil
+		}
+		return nil, errorf("需要时长，实际为 %s", kindOf(args[0]))
+	}
+}
+
+func stringFn(f func(string) string) func(*evalEnv, []interface{}) (interface{}, error) {
+	return func(_ *evalEnv, args []interface{}) (interface{}, error) {
+		switch s := args[0].(type) {
+		case nil:
+			return nil, nil
+		case string:
+			return f(s), nil
+		}
+		return nil, errorf("需要字符串，实际为 %s", kindOf(args[0]))
+	}
+}
+
+func stringPredicate(f func(string, string) bool) func(*evalEnv, []interface{}) (interface{}, error) {
+	return func(_ *evalEnv, args []interface{}) (interface{}, error) {
+		if args[0] == nil || args[1] == nil {
+			return false, nil
+		}
+		s, ok1 := args[0].(string)
+		sub, ok2 := args[1].(string)
+		if !ok1 || !ok2 {
+			return nil, errorf("需要字符串参数，实际为 %s, %s", kindOf(args[0]), kindOf(args[1]))
+		}
+		return f(s, sub), nil
+	}
+}
+
+func fnMatches(_ *evalEnv, args []interface{}) (interface{}, error) {
+	return matchRegex(args[0], args[1])
+}
+
+func fnContains(_ *evalEnv, args []interface{}) (interface{}, error) {
+	return contains(args[0], args[1])
+}
+
+func fnLen(_ *evalEnv, args []interface{}) (interface{}, error) {
+	switch v := args[0].(type) {
+	case nil:
+		return float64(0), nil
+	case string:
+		return float64(len([]rune(v))), nil
+	case []interface{}:
+		return float64(len(v)), nil
+	case map[string]interface{}:
+		return float64(len(v)), nil
+	}
+	return nil, errorf("len 不支持 %s", kindOf(args[0]))
+}
+
+func fnCoalesce(_ *evalEnv, args []interface{}) (interface{}, error) {
+	for _, a := range args {
+		if a != nil {
+			return a, nil
+		}
+	}
+	return nil, nil
+}
+
+func fnIsEmpty(_ *evalEnv, args []interface{}) (interface{}, error) {
+	switch v := args[0].(type) {
+	case nil:
+		return true, nil
+	case string:
+		return strings.TrimSpace(v) == "", nil
+	case []interface{}:
+		return len(v) == 0, nil
+	case map[string]interface{}:
+		return len(v) == 0, nil
+	}
+	return false, nil
+}
+
+func mathFn(f func(float64) float64) func(*evalEnv, []interface{}) (interface{}, error) {
+	return func(_ *evalEnv, args []interface{}) (interface{}, error) {
+		if args[0] == nil {
+			return nil, nil
+		}
+		x, ok := asNumber(args[0])
+		if !ok {
+			return nil, errorf("需要数值，实际为 %s", kindOf(args[0]))
+		}
+		return f(x), nil
+	}
+}
+
+func extremum(sign float64) func(*evalEnv, []interface{}) (interface{}, error) {
+	return func(_ *evalEnv, args []interface{}) (interface{}, error) {
+		var best interface{}
+		for _, a := range args {
+			if a == nil {
+				continue
+			}
+			x, ok := asNumber(a)
+			if !ok {
+				return nil, errorf("需要数值，实际为 %s", kindOf(a))
+			}
+			if best == nil || (x-best.(float64))*sign > 0 {
+				best = x
+			}
+		}
+		return best, nil
+	}
+}
+
+// arityError 参数个数错误
+func arityError(n *callNode, want string) error {
+	return &Error{Pos: n.pos, Msg: fmt.Sprintf("%s 需要 %s 个参数，实际 %d 个", n.name, want, len(n.args))}
+}
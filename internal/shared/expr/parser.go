@@ -0,0 +1,449 @@
+package expr
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// =============================================================================
+// 词法 & 语法分析
+// =============================================================================
+
+type tokenKind int
+
+const (
+	tokEOF tokenKind = iota
+	tokNumber
+	tokDuration
+	tokString
+	tokIdent
+	tokOp
+)
+
+type token struct {
+	kind tokenKind
+	text string
+	pos  int
+	num  float64
+	dur  time.Duration
+}
+
+// lex 将表达式拆分为 token 序列
+func lex(src string) ([]token, error) {
+	var tokens []token
+	runes := []rune(src)
+	i := 0
+	for i < len(runes) {
+		r := runes[i]
+		switch {
+		case unicode.IsSpace(r):
+			i++
+
+		case unicode.IsDigit(r) || (r == '.' && i+1 < len(runes) && unicode.IsDigit(runes[i+1])):
+			start := i
+			for i < len(runes) && (unicode.IsDigit(runes[i]) || runes[i] == '.') {
+				i++
+			}
+			// 数字后紧跟时间单位 → 时长字面量，如 3d、48h、1d12h
+			if i < len(runes) && strings.ContainsRune("wdhms", runes[i]) {
+				for i < len(runes) && (unicode.IsLetter(runes[i]) || unicode.IsDigit(runes[i]) || runes[i] == '.') {
+					i++
+				}
+				text := string(runes[start:i])
+				d, err := parseDuration(text)
+				if err != nil {
+					return nil, &Error{Pos: start, Msg: err.Error()}
+				}
+				tokens = append(tokens, token{kind: tokDuration, text: text, pos: start, dur: d})
+				continue
+			}
+			text := string(runes[start:i])
+			f, err := strconv.ParseFloat(text, 64)
+			if err != nil {
+				return nil, &Error{Pos: start, Msg: "无效的数字: " + text}
+			}
+			tokens = append(tokens, token{kind: tokNumber, text: text, pos: start, num: f})
+
+		case r == '"' || r == '\'':
+			start := i
+			quote := r
+			i++
+			var b strings.Builder
+			closed := false
+			for i < len(runes) {
+				if runes[i] == '\\' && i+1 < len(runes) {
+					switch runes[i+1] {
+					case 'n':
+						b.WriteRune('\n')
+					case 't':
+						b.WriteRune('\t')
+					default:
+						b.WriteRune(runes[i+1])
+					}
+					i += 2
+					continue
+				}
+				if runes[i] == quote {
+					closed = true
+					i++
+					break
+				}
+				b.WriteRune(runes[i])
+				i++
+			}
+			if !closed {
+				return nil, &Error{Pos: start, Msg: "字符串缺少结束引号"}
+			}
+			tokens = append(tokens, token{kind: tokString, text: b.String(), pos: start})
+
+		case unicode.IsLetter(r) || r == '_':
+			start := i
+			for i < len(runes) && (unicode.IsLetter(runes[i]) || unicode.IsDigit(runes[i]) || runes[i] == '_') {
+				i++
+			}
+			tokens = append(tokens, token{kind: tokIdent, text: string(runes[start:i]), pos: start})
+
+		default:
+			start := i
+			two := ""
+			if i+1 < len(runes) {
+				two = string(runes[i : i+2])
+			}
+			switch two {
+			case "==", "!=", "<=", ">=", "&&", "||", "=~":
+				tokens = append(tokens, token{kind: tokOp, text: two, pos: start})
+				i += 2
+				continue
+			}
+			if strings.ContainsRune("+-*/%<>!()[],.", r) {
+				tokens = append(tokens, token{kind: tokOp, text: string(r), pos: start})
+				i++
+				continue
+			}
+			return nil, &Error{Pos: start, Msg: fmt.Sprintf("无法识别的字符 %q", r)}
+		}
+	}
+	tokens = append(tokens, token{kind: tokEOF, pos: len(runes)})
+	return tokens, nil
+}
+
+// parseDuration 解析时长字面量: 1w, 3d, 48h, 30m, 10s, 1d12h
+func parseDuration(s string) (time.Duration, error) {
+	var total time.Duration
+	rest := s
+	for rest != "" {
+		i := 0
+		for i < len(rest) && (rest[i] >= '0' && rest[i] <= '9' || rest[i] == '.') {
+			i++
+		}
+		if i == 0 || i == len(rest) {
+			return 0, fmt.Errorf("无效的时长: %s", s)
+		}
+		n, err := strconv.ParseFloat(rest[:i], 64)
+		if err != nil {
+			return 0, fmt.Errorf("无效的时长: %s", s)
+		}
+		var unit time.Duration
+		switch rest[i] {
+		case 'w':
+			unit = 7 * 24 * time.Hour
+		case 'd':
+			unit = 24 * time.Hour
+		case 'h':
+			unit = time.Hour
+		case 'm':
+			unit = time.Minute
+		case 's':
+			unit = time.Second
+		default:
+			return 0, fmt.Errorf("无效的时长单位: %s", s)
+		}
+		total += time.Duration(n * float64(unit))
+		rest = rest[i+1:]
+	}
+	return total, nil
+}
+
+// parser 递归下降解析器
+//
+//	or      := and (("||" | "or") and)*
+//	and     := not (("&&" | "and") not)*
+//	not     := ("!" | "not") not | compare
+//	compare := additive (("==" | "!=" | "<" | "<=" | ">" | ">=" | "=~" | "in" | "not in") additive)?
+//	additive:= multiply (("+" | "-") multiply)*
+//	multiply:= unary (("*" | "/" | "%") unary)*
+//	unary   := "-" unary | primary
+//	primary := number | duration | string | true | false | null
+//	         | ident ("." ident)* | ident "(" args ")" | "(" or ")" | "[" args "]"
+type parser struct {
+	tokens []token
+	pos    int
+}
+
+func parse(src string) (node, error) {
+	tokens, err := lex(src)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{tokens: tokens}
+	n, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if tok := p.peek(); tok.kind != tokEOF {
+		return nil, p.errorf(tok, "多余的内容 %q", tok.text)
+	}
+	return n, nil
+}
+
+func (p *parser) peek() token { return p.tokens[p.pos] }
+
+func (p *parser) next() token {
+	tok := p.tokens[p.pos]
+	if tok.kind != tokEOF {
+		p.pos++
+	}
+	return tok
+}
+
+func (p *parser) errorf(tok token, format string, args ...interface{}) error {
+	return &Error{Pos: tok.pos, Msg: fmt.Sprintf(format, args...)}
+}
+
+// isOp 当前 token 是否为指定运算符或关键字
+func (p *parser) isOp(ops ...string) (string, bool) {
+	tok := p.peek()
+	if tok.kind != tokOp && tok.kind != tokIdent {
+		return "", false
+	}
+	for _, op := range ops {
+		if tok.text == op {
+			return op, true
+		}
+	}
+	return "", false
+}
+
+func (p *parser) expect(op string) error {
+	tok := p.next()
+	if tok.kind != tokOp || tok.text != op {
+		return p.errorf(tok, "缺少 %q", op)
+	}
+	return nil
+}
+
+func (p *parser) parseOr() (node, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		if _, ok := p.isOp("||", "or"); !ok {
+			return left, nil
+		}
+		p.next()
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = &binaryNode{op: "||", left: left, right: right}
+	}
+}
+
+func (p *parser) parseAnd() (node, error) {
+	left, err := p.parseNot()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		if _, ok := p.isOp("&&", "and"); !ok {
+			return left, nil
+		}
+		p.next()
+		right, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		left = &binaryNode{op: "&&", left: left, right: right}
+	}
+}
+
+func (p *parser) parseNot() (node, error) {
+	if _, ok := p.isOp("!", "not"); ok {
+		p.next()
+		x, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		return &unaryNode{op: "!", x: x}, nil
+	}
+	return p.parseCompare()
+}
+
+func (p *parser) parseCompare() (node, error) {
+	left, err := p.parseAdditive()
+	if err != nil {
+		return nil, err
+	}
+	op, ok := p.isOp("==", "!=", "<", "<=", ">", ">=", "=~", "in", "not")
+	if !ok {
+		return left, nil
+	}
+	tok := p.next()
+	if op == "not" {
+		if _, ok := p.isOp("in"); !ok {
+			return nil, p.errorf(tok, "not 之后应为 in")
+		}
+		p.next()
+		op = "not in"
+	}
+	right, err := p.parseAdditive()
+	if err != nil {
+		return nil, err
+	}
+	return &binaryNode{op: op, left: left, right: right}, nil
+}
+
+func (p *parser) parseAdditive() (node, error) {
+	left, err := p.parseMultiply()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		op, ok := p.isOp("+", "-")
+		if !ok || p.peek().kind != tokOp {
+			return left, nil
+		}
+		p.next()
+		right, err := p.parseMultiply()
+		if err != nil {
+			return nil, err
+		}
+		left = &binaryNode{op: op, left: left, right: right}
+	}
+}
+
+func (p *parser) parseMultiply() (node, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		op, ok := p.isOp("*", "/", "%")
+		if !ok || p.peek().kind != tokOp {
+			return left, nil
+		}
+		p.next()
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		left = &binaryNode{op: op, left: left, right: right}
+	}
+}
+
+func (p *parser) parseUnary() (node, error) {
+	if tok := p.peek(); tok.kind == tokOp && tok.text == "-" {
+		p.next()
+		x, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return &unaryNode{op: "-", x: x}, nil
+	}
+	return p.parsePrimary()
+}
+
+func (p *parser) parsePrimary() (node, error) {
+	tok := p.next()
+	switch tok.kind {
+	case tokNumber:
+		return &literalNode{value: tok.num}, nil
+	case tokDuration:
+		return &literalNode{value: tok.dur, text: tok.text}, nil
+	case tokString:
+		return &literalNode{value: tok.text}, nil
+	case tokIdent:
+		switch tok.text {
+		case "true":
+			return &literalNode{value: true}, nil
+		case "false":
+			return &literalNode{value: false}, nil
+		case "null", "nil":
+			return &literalNode{value: nil}, nil
+		case "and", "or", "not", "in":
+			return nil, p.errorf(tok, "意外的关键字 %q", tok.text)
+		}
+		// 函数调用
+		if next := p.peek(); next.kind == tokOp && next.text == "(" {
+			p.next()
+			args, err := p.parseArgs(")")
+			if err != nil {
+				return nil, err
+			}
+			return &callNode{name: tok.text, args: args, pos: tok.pos}, nil
+		}
+		// 字段路径 a.b.c
+		path := []string{tok.text}
+		for {
+			next := p.peek()
+			if next.kind != tokOp || next.text != "." {
+				break
+			}
+			p.next()
+			field := p.next()
+			if field.kind != tokIdent {
+				return nil, p.errorf(field, "字段名无效")
+			}
+			path = append(path, field.text)
+		}
+		return &identNode{path: path}, nil
+	case tokOp:
+		switch tok.text {
+		case "(":
+			n, err := p.parseOr()
+			if err != nil {
+				return nil, err
+			}
+			if err := p.expect(")"); err != nil {
+				return nil, err
+			}
+			return n, nil
+		case "[":
+			items, err := p.parseArgs("]")
+			if err != nil {
+				return nil, err
+			}
+			return &listNode{items: items}, nil
+		}
+	case tokEOF:
+		return nil, p.errorf(tok, "表达式不完整")
+	}
+	return nil, p.errorf(tok, "意外的 %q", tok.text)
+}
+
+// parseArgs 解析逗号分隔的参数列表，直到 closing
+func (p *parser) parseArgs(closing string) ([]node, error) {
+	var args []node
+	if tok := p.peek(); tok.kind == tokOp && tok.text == closing {
+		p.next()
+		return args, nil
+	}
+	for {
+		arg, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, arg)
+		tok := p.next()
+		if tok.kind == tokOp && tok.text == closing {
+			return args, nil
+		}
+		if tok.kind != tokOp || tok.text != "," {
+			return nil, p.errorf(tok, "缺少 %q", closing)
+		}
+	}
+}
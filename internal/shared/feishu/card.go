@@ -4,6 +4,7 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"strings"
 )
 
 // =============================================================================
@@ -250,3 +251,148 @@ func NewRollbackCard(taskName, reason, rolledBackBy string) InteractiveCard {
 		},
 	}
 }
+
+// CardTaskItem 卡片中的任务条目
+type CardTaskItem struct {
+	Title    string // 任务名称
+	Project  string // 所属项目
+	Assignee string // 负责人
+	DueDate  string // 截止日期
+	Note     string // 附加说明，如「逾期 3 天」
+}
+
+// line 任务条目的 Markdown 行
+func (t CardTaskItem) line() string {
+	parts := []string{fmt.Sprintf("• **%s**", t.Title)}
+	if t.Project != "" {
+		parts = append(parts, t.Project)
+	}
+	if t.Assignee != "" {
+		parts = append(parts, "负责人: "+t.Assignee)
+	}
+	if t.DueDate != "" {
+		parts = append(parts, "截止: "+t.DueDate)
+	}
+	if t.Note != "" {
+		parts = append(parts, t.Note)
+	}
+	return strings.Join(parts, " | ")
+}
+
+// taskListMarkdown 任务列表 Markdown，超过 limit 条时折叠
+func taskListMarkdown(items []CardTaskItem, limit int) string {
+	lines := make([]string, 0, len(items))
+	for i, item := range items {
+		if limit > 0 && i >= limit {
+			lines = append(lines, fmt.Sprintf("…等共 %d 项", len(items)))
+			break
+		}
+		lines = append(lines, item.line())
+	}
+	return strings.Join(lines, "\n")
+}
+
+// detailButton 查看详情按钮，detailURL 为空时不添加
+func detailButton(elements []CardElement, label, detailURL string) []CardElement {
+	if detailURL == "" {
+		return elements
+	}
+	return append(elements, CardElement{
+		Tag: "action",
+		Actions: []CardAction{
+			{Tag: "button", Text: CardText{Tag: "plain_text", Content: label}, Type: "primary", URL: detailURL},
+		},
+	})
+}
+
+// NewDueSoonCard 创建任务即将到期提醒卡片（黄色模板）
+// days: 提前提醒天数
+// tasks: 即将到期的任务
+// detailURL: 我的任务页面链接（可为空）
+func NewDueSoonCard(days int, tasks []CardTaskItem, detailURL string) InteractiveCard {
+	elements := []CardElement{
+		{
+			Tag:  "div",
+			Text: &CardText{Tag: "lark_md", Content: fmt.Sprintf("你有 **%d** 项任务将在 %d 天内到期：", len(tasks), days)},
+		},
+		{Tag: "markdown", Content: taskListMarkdown(tasks, 20)},
+		{Tag: "hr"},
+	}
+	elements = detailButton(elements, "查看我的任务", detailURL)
+	return InteractiveCard{
+		Config: &CardConfig{WideScreenMode: true},
+		Header: &CardHeader{
+			Title:    CardText{Tag: "plain_text", Content: "⏰ 任务即将到期"},
+			Template: "yellow",
+		},
+		Elements: elements,
+	}
+}
+
+// NewOverdueEscalationCard 创建逾期任务升级卡片（红色模板，发送给项目经理）
+// projectName: 项目名称
+// tasks: 逾期任务（Note 中注明逾期天数）
+// detailURL: 项目页面链接（可为空）
+func NewOverdueEscalationCard(projectName string, tasks []CardTaskItem, detailURL string) InteractiveCard {
+	elements := []CardElement{
+		{
+			Tag: "div",
+			Fields: []CardField{
+				{IsShort: true, Text: CardText{Tag: "lark_md", Content: fmt.Sprintf("**项目名称**\n%s", projectName)}},
+				{IsShort: true, Text: CardText{Tag: "lark_md", Content: fmt.Sprintf("**逾期任务**\n%d 项", len(tasks))}},
+			},
+		},
+		{Tag: "markdown", Content: taskListMarkdown(tasks, 30)},
+		{Tag: "hr"},
+	}
+	elements = detailButton(elements, "查看项目", detailURL)
+	elements = append(elements, CardElement{
+		Tag: "note",
+		Elements: []CardElement{
+			{Tag: "plain_text", Content: "请跟进逾期任务并调整计划"},
+		},
+	})
+	return InteractiveCard{
+		Config: &CardConfig{WideScreenMode: true},
+		Header: &CardHeader{
+			Title:    CardText{Tag: "plain_text", Content: "🚨 项目逾期任务升级"},
+			Template: "red",
+		},
+		Elements: elements,
+	}
+}
+
+// DigestSection 每日摘要中的一个分组
+type DigestSection struct {
+	Title string
+	Items []CardTaskItem
+}
+
+// NewDailyDigestCard 创建每日工作摘要卡片（蓝色模板），空分组不显示
+// date: 摘要日期（格式如 "2024-03-15"）
+// sections: 分组（进行中、即将到期、已逾期、待我审批等）
+// detailURL: 工作台链接（可为空）
+func NewDailyDigestCard(date string, sections []DigestSection, detailURL string) InteractiveCard {
+	elements := []CardElement{}
+	for _, sec := range sections {
+		if len(sec.Items) == 0 {
+			continue
+		}
+		elements = append(elements,
+			CardElement{Tag: "markdown", Content: fmt.Sprintf("**%s（%d）**\n%s", sec.Title, len(sec.Items), taskListMarkdown(sec.Items, 10))},
+		)
+	}
+	if len(elements) == 0 {
+		elements = append(elements, CardElement{Tag: "div", Text: &CardText{Tag: "lark_md", Content: "今天没有待处理事项 🎉"}})
+	}
+	elements = append(elements, CardElement{Tag: "hr"})
+	elements = detailButton(elements, "打开工作台", detailURL)
+	return InteractiveCard{
+		Config: &CardConfig{WideScreenMode: true},
+		Header: &CardHeader{
+			Title:    CardText{Tag: "plain_text", Content: fmt.Sprintf("📅 每日工作摘要 %s", date)},
+			Template: "blue",
+		},
+		Elements: elements,
+	}
+}
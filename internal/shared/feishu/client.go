@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
@@ -29,6 +30,26 @@ type FeishuClient struct {
 	httpClient  *http.Client // HTTP客户端
 }
 
+// APIError 飞书接口返回的业务错误（code 非 0）
+type APIError struct {
+	Code int
+	Msg  string
+	Path string
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("飞书API错误[%d]: %s (path=%s)", e.Code, e.Msg, e.Path)
+}
+
+// codeTaskNotFound 任务 v2 接口：任务不存在或已删除
+const codeTaskNotFound = 1470404
+
+// IsTaskNotFound 判断错误是否为飞书任务不存在（已删除）
+func IsTaskNotFound(err error) bool {
+	var apiErr *APIError
+	return errors.As(err, &apiErr) && apiErr.Code == codeTaskNotFound
+}
+
 // NewClient 创建飞书客户端实例
 func NewClient(appID, appSecret string) *FeishuClient {
 	return &FeishuClient{
@@ -156,7 +177,7 @@ func (c *FeishuClient) doRequest(ctx context.Context, method, path string, body
 		return fmt.Errorf("解析响应基础结构失败: %w", err)
 	}
 	if baseResp.Code != 0 {
-		return fmt.Errorf("飞书API错误[%d]: %s (path=%s)", baseResp.Code, baseResp.Msg, path)
+		return &APIError{Code: baseResp.Code, Msg: baseResp.Msg, Path: path}
 	}
 
 	// 解析完整响应
@@ -29,6 +29,7 @@ type FeishuUser struct {
 	} `json:"avatar"`
 	DepartmentIDs []string `json:"department_ids"`
 	EmployeeNo    string   `json:"employee_no"`
+	LeaderUserID  string   `json:"leader_user_id"` // 直属上级 open_id
 }
 
 // ListDepartments 获取所有部门列表（递归获取所有子部门）
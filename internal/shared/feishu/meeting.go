@@ -3,6 +3,7 @@ package feishu
 import (
 	"context"
 	"fmt"
+	"time"
 )
 
 // =============================================================================
@@ -46,3 +47,29 @@ func (c *FeishuClient) CreateMeeting(ctx context.Context, req CreateMeetingReq)
 
 	return resp.Data.Event.EventID, nil
 }
+
+// UpdateMeetingTime 修改日历事件时间（会议改期）
+func (c *FeishuClient) UpdateMeetingTime(ctx context.Context, eventID string, start, end time.Time) error {
+	reqBody := map[string]interface{}{
+		"start_time": map[string]interface{}{
+			"timestamp": fmt.Sprintf("%d", start.Unix()),
+		},
+		"end_time": map[string]interface{}{
+			"timestamp": fmt.Sprintf("%d", end.Unix()),
+		},
+	}
+	path := fmt.Sprintf("/open-apis/calendar/v4/calendars/primary/events/%s", eventID)
+	if err := c.doRequest(ctx, "PATCH", path, reqBody, nil); err != nil {
+		return fmt.Errorf("修改日历事件失败: %w", err)
+	}
+	return nil
+}
+
+// CancelMeeting 删除日历事件（取消会议），参会人会收到取消通知
+func (c *FeishuClient) CancelMeeting(ctx context.Context, eventID string) error {
+	path := fmt.Sprintf("/open-apis/calendar/v4/calendars/primary/events/%s?need_notification=true", eventID)
+	if err := c.doRequest(ctx, "DELETE", path, nil, nil); err != nil {
+		return fmt.Errorf("删除日历事件失败: %w", err)
+	}
+	return nil
+}
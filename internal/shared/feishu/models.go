@@ -181,6 +181,48 @@ type CompleteTaskResponse struct {
 	BaseResponse
 }
 
+// TaskDetailDue 任务详情中的截止时间
+type TaskDetailDue struct {
+	Timestamp string `json:"timestamp"`  // 截止时间（毫秒时间戳）
+	IsAllDay  bool   `json:"is_all_day"` // 是否全天任务
+}
+
+// TaskDetail 任务详情
+type TaskDetail struct {
+	Guid        string         `json:"guid"`         // 任务全局唯一ID
+	Summary     string         `json:"summary"`      // 任务标题
+	Description string         `json:"description"`  // 任务描述
+	Due         *TaskDetailDue `json:"due"`          // 截止时间
+	CompletedAt string         `json:"completed_at"` // 完成时间（毫秒时间戳），未完成为 "0"
+	UpdatedAt   string         `json:"updated_at"`   // 最近更新时间（毫秒时间戳）
+	Members     []TaskMember   `json:"members"`      // 任务成员
+}
+
+// GetTaskResponse 获取任务详情响应
+type GetTaskResponse struct {
+	BaseResponse
+	Data struct {
+		Task TaskDetail `json:"task"`
+	} `json:"data"`
+}
+
+// TaskEvent 任务变更事件（task.task.updated_v1）
+type TaskEvent struct {
+	EventID   string `json:"-"`        // 事件ID（取自事件头，用于去重）
+	EventType string `json:"-"`        // 事件类型
+	TaskID    string `json:"task_id"`  // 任务全局唯一ID
+	ObjType   int    `json:"obj_type"` // 变更类型：1详情 2执行人 3关注人 4提醒 5完成 6取消完成 7删除
+}
+
+// 任务变更事件类型（TaskEvent.ObjType）
+const (
+	TaskEventObjDetail    = 1 // 任务详情变更
+	TaskEventObjAssignee  = 2 // 执行人变更
+	TaskEventObjCompleted = 5 // 任务完成
+	TaskEventObjReopened  = 6 // 取消完成
+	TaskEventObjDeleted   = 7 // 任务删除
+)
+
 // =============================================================================
 // 会议（日历事件）相关模型
 // =============================================================================
@@ -300,8 +342,9 @@ const (
 
 // 事件类型常量
 const (
-	EventTypeApprovalInstance = "approval_instance"  // 审批实例事件
-	EventTypeURLVerification  = "url_verification"   // URL验证事件
+	EventTypeApprovalInstance = "approval_instance"    // 审批实例事件
+	EventTypeURLVerification  = "url_verification"     // URL验证事件
+	EventTypeTaskUpdated      = "task.task.updated_v1" // 任务变更事件
 )
 
 // WebhookEvent 飞书Webhook事件（通用信封）
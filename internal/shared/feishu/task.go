@@ -3,10 +3,12 @@ package feishu
 import (
 	"context"
 	"fmt"
+	"strconv"
+	"time"
 )
 
 // =============================================================================
-// 任务服务 — 管理飞书任务的创建、更新和完成
+// 任务服务 — 管理飞书任务的创建、更新、完成和成员变更
 // 使用飞书任务API v2版本
 // =============================================================================
 
@@ -118,3 +120,95 @@ func (c *FeishuClient) CompleteTask(ctx context.Context, taskID string) error {
 
 	return nil
 }
+
+// GetTask 获取飞书任务详情
+// 用于同步时比对执行人、完成状态和最近更新时间
+func (c *FeishuClient) GetTask(ctx context.Context, taskID string) (*TaskDetail, error) {
+	path := fmt.Sprintf("/open-apis/task/v2/tasks/%s?user_id_type=open_id", taskID)
+
+	var resp GetTaskResponse
+	err := c.doRequest(ctx, "GET", path, nil, &resp)
+	if err != nil {
+		return nil, fmt.Errorf("获取飞书任务失败: %w", err)
+	}
+
+	return &resp.Data.Task, nil
+}
+
+// AddTaskMembers 添加任务成员（执行人/关注人）
+func (c *FeishuClient) AddTaskMembers(ctx context.Context, taskID string, members []TaskMember) error {
+	path := fmt.Sprintf("/open-apis/task/v2/tasks/%s/add_members?user_id_type=open_id", taskID)
+
+	var resp UpdateTaskResponse
+	err := c.doRequest(ctx, "POST", path, map[string]interface{}{"members": taskMembersBody(members)}, &resp)
+	if err != nil {
+		return fmt.Errorf("添加飞书任务成员失败: %w", err)
+	}
+
+	return nil
+}
+
+// RemoveTaskMembers 移除任务成员
+func (c *FeishuClient) RemoveTaskMembers(ctx context.Context, taskID string, members []TaskMember) error {
+	path := fmt.Sprintf("/open-apis/task/v2/tasks/%s/remove_members?user_id_type=open_id", taskID)
+
+	var resp UpdateTaskResponse
+	err := c.doRequest(ctx, "POST", path, map[string]interface{}{"members": taskMembersBody(members)}, &resp)
+	if err != nil {
+		return fmt.Errorf("移除飞书任务成员失败: %w", err)
+	}
+
+	return nil
+}
+
+// taskMembersBody 构造任务成员请求体
+func taskMembersBody(members []TaskMember) []map[string]interface{} {
+	list := make([]map[string]interface{}, 0, len(members))
+	for _, m := range members {
+		list = append(list, map[string]interface{}{
+			"id":   m.ID,
+			"type": "user",
+			"role": m.Role,
+		})
+	}
+	return list
+}
+
+// IsCompleted 任务是否已完成
+func (t *TaskDetail) IsCompleted() bool {
+	return t.CompletedAt != "" && t.CompletedAt != "0"
+}
+
+// Assignees 执行人OpenID列表
+func (t *TaskDetail) Assignees() []string {
+	var ids []string
+	for _, m := range t.Members {
+		if m.Role == "assignee" {
+			ids = append(ids, m.ID)
+		}
+	}
+	return ids
+}
+
+// DueTime 截止时间，未设置时返回 nil
+func (t *TaskDetail) DueTime() *time.Time {
+	if t.Due == nil {
+		return nil
+	}
+	return parseMillis(t.Due.Timestamp)
+}
+
+// UpdatedTime 最近更新时间，无法解析时返回 nil
+func (t *TaskDetail) UpdatedTime() *time.Time {
+	return parseMillis(t.UpdatedAt)
+}
+
+// parseMillis 解析毫秒时间戳字符串，"0" 或空值返回 nil
+func parseMillis(v string) *time.Time {
+	ms, err := strconv.ParseInt(v, 10, 64)
+	if err != nil || ms <= 0 {
+		return nil
+	}
+	tm := time.UnixMilli(ms)
+	return &tm
+}
@@ -1,15 +1,67 @@
 package feishu
 
 import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
+	"net/http"
 )
 
 // =============================================================================
 // Webhook处理 — 解析飞书回调事件
-// 支持审批实例事件、URL验证事件
+// 支持审批实例事件、任务变更事件、URL验证事件
 // =============================================================================
 
+// VerifyEvent 校验事件确实来自飞书
+// 配置了 encrypt_key 时校验请求头签名（URL验证请求不带签名，跳过）；配置了 verification_token 时校验事件中的 token。
+// 两者都未配置时无法确认来源，直接拒绝
+func VerifyEvent(header http.Header, body []byte, verificationToken, encryptKey string) error {
+	if verificationToken == "" && encryptKey == "" {
+		return fmt.Errorf("未配置 verification_token 或 encrypt_key，拒绝处理事件")
+	}
+
+	if encryptKey != "" && !IsVerificationEvent(body) {
+		timestamp := header.Get("X-Lark-Request-Timestamp")
+		nonce := header.Get("X-Lark-Request-Nonce")
+		signature := header.Get("X-Lark-Signature")
+		if signature == "" {
+			return fmt.Errorf("缺少事件签名")
+		}
+		if !secureEqual(signature, EventSignature(timestamp, nonce, encryptKey, body)) {
+			return fmt.Errorf("事件签名不匹配")
+		}
+	}
+
+	if verificationToken != "" {
+		var envelope WebhookEvent
+		if err := json.Unmarshal(body, &envelope); err != nil {
+			return fmt.Errorf("解析事件失败: %w", err)
+		}
+		token := envelope.Token
+		if envelope.Header != nil {
+			token = envelope.Header.Token
+		}
+		if !secureEqual(token, verificationToken) {
+			return fmt.Errorf("事件 token 不匹配")
+		}
+	}
+	return nil
+}
+
+// EventSignature 计算事件签名：sha256(timestamp + nonce + encrypt_key + body) 的十六进制
+func EventSignature(timestamp, nonce, encryptKey string, body []byte) string {
+	h := sha256.New()
+	h.Write([]byte(timestamp + nonce + encryptKey))
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func secureEqual(a, b string) bool {
+	return subtle.ConstantTimeCompare([]byte(a), []byte(b)) == 1
+}
+
 // HandleApprovalEvent 解析飞书审批实例事件
 // 从webhook回调的请求体中提取审批事件信息
 // 支持v1和v2两种事件格式
@@ -47,6 +99,29 @@ func HandleApprovalEvent(body []byte) (*ApprovalEvent, error) {
 	return nil, fmt.Errorf("无法识别的审批事件格式")
 }
 
+// HandleTaskEvent 解析飞书任务变更事件（仅支持v2事件格式）
+// 事件体只包含任务ID和变更类型，任务最新状态需通过 GetTask 获取
+func HandleTaskEvent(body []byte) (*TaskEvent, error) {
+	var envelope WebhookEvent
+	if err := json.Unmarshal(body, &envelope); err != nil {
+		return nil, fmt.Errorf("解析任务事件信封失败: %w", err)
+	}
+	if envelope.Header == nil || envelope.Event == nil {
+		return nil, fmt.Errorf("无法识别的任务事件格式")
+	}
+
+	var event TaskEvent
+	if err := json.Unmarshal(envelope.Event, &event); err != nil {
+		return nil, fmt.Errorf("解析任务事件体失败: %w", err)
+	}
+	if event.TaskID == "" {
+		return nil, fmt.Errorf("任务事件缺少task_id字段")
+	}
+	event.EventID = envelope.Header.EventID
+	event.EventType = envelope.Header.EventType
+	return &event, nil
+}
+
 // HandleVerification 处理飞书URL验证事件
 // 飞书在首次订阅事件时会发送验证请求，需要返回challenge值
 // 返回值为challenge字符串，需原样返回给飞书
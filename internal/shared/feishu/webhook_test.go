@@ -0,0 +1,61 @@
+package feishu
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVerifyEvent(t *testing.T) {
+	body := []byte(`{"schema":"2.0","header":{"event_type":"task.task.update_tenant_v1","token":"tok"},"event":{}}`)
+	challenge := []byte(`{"type":"url_verification","challenge":"c","token":"tok"}`)
+
+	signed := func(key string, b []byte) http.Header {
+		h := http.Header{}
+		h.Set("X-Lark-Request-Timestamp", "1700000000")
+		h.Set("X-Lark-Request-Nonce", "n1")
+		h.Set("X-Lark-Signature", EventSignature("1700000000", "n1", key, b))
+		return h
+	}
+
+	tests := []struct {
+		name    string
+		header  http.Header
+		body    []byte
+		token   string
+		key     string
+		wantErr string
+	}{
+		{"未配置校验参数", http.Header{}, body, "", "", "未配置"},
+		{"token 正确", http.Header{}, body, "tok", "", ""},
+		{"token 错误", http.Header{}, body, "other", "", "token 不匹配"},
+		{"v1 token", http.Header{}, []byte(`{"type":"event_callback","token":"tok"}`), "tok", "", ""},
+		{"签名正确", signed("key", body), body, "", "key", ""},
+		{"签名与 token 均正确", signed("key", body), body, "tok", "key", ""},
+		{"签名密钥错误", signed("wrong", body), body, "", "key", "签名不匹配"},
+		{"缺少签名", http.Header{}, body, "", "key", "缺少事件签名"},
+		{"URL验证不校验签名", http.Header{}, challenge, "tok", "key", ""},
+		{"URL验证仍校验 token", http.Header{}, challenge, "other", "key", "token 不匹配"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := VerifyEvent(tt.header, tt.body, tt.token, tt.key)
+			if tt.wantErr == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.ErrorContains(t, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestIsTaskNotFound(t *testing.T) {
+	notFound := &APIError{Code: codeTaskNotFound, Msg: "task not found", Path: "/task/v2/tasks/x"}
+	assert.True(t, IsTaskNotFound(notFound))
+	assert.True(t, IsTaskNotFound(fmt.Errorf("获取任务失败: %w", notFound)))
+	assert.False(t, IsTaskNotFound(&APIError{Code: 99991663}))
+	assert.False(t, IsTaskNotFound(fmt.Errorf("网络错误")))
+	assert.Equal(t, "飞书API错误[1470404]: task not found (path=/task/v2/tasks/x)", notFound.Error())
+}
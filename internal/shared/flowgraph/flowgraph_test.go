@@ -0,0 +1,273 @@
+package flowgraph
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// 发起 → 金额条件（>5万加财务总监）→ 并行（法务、质量）→ 汇合 → 结束
+func testGraph(t *testing.T) *Graph {
+	t.Helper()
+	g, err := New([]Node{
+		{ID: "start", Type: TypeSubmit, Name: "发起"},
+		{ID: "amount", Type: TypeCondition, Name: "金额判断"},
+		{ID: "cfo", Type: TypeApprove, Name: "财务总监"},
+		{ID: "split", Type: TypeParallel, Name: "会签"},
+		{ID: "legal", Type: TypeApprove, Name: "法务"},
+		{ID: "quality", Type: TypeApprove, Name: "质量"},
+		{ID: "join", Type: TypeJoin, Name: "汇合"},
+		{ID: "end", Type: TypeEnd, Name: "结束"},
+	}, []Edge{
+		{From: "start", To: "amount"},
+		{From: "amount", To: "cfo", Name: "大额", Condition: json.RawMessage(`{"field": "amount", "op": "gt", "value": 50000}`)},
+		{From: "amount", To: "split", Name: "其他", Default: true},
+		{From: "cfo", To: "split"},
+		{From: "split", To: "legal"},
+		{From: "split", To: "quality"},
+		{From: "legal", To: "join"},
+		{From: "quality", To: "join"},
+		{From: "join", To: "end"},
+	})
+	require.NoError(t, err)
+	return g
+}
+
+func problems(t *testing.T, err error) []string {
+	t.Helper()
+	var verr *ValidationError
+	require.True(t, errors.As(err, &verr), "expected ValidationError, got %v", err)
+	return verr.Problems
+}
+
+func TestValidGraph(t *testing.T) {
+	g := testGraph(t)
+	assert.NoError(t, g.Validate())
+	assert.Equal(t, "start", g.Start())
+	assert.False(t, g.Linear())
+
+	next, err := g.Next("cfo")
+	require.NoError(t, err)
+	assert.Equal(t, "split", next)
+	_, err = g.Next("split")
+	assert.Error(t, err)
+}
+
+func TestChoose(t *testing.T) {
+	g := testGraph(t)
+
+	e, err := g.Choose("amount", map[string]interface{}{"amount": float64(80000)})
+	require.NoError(t, err)
+	assert.Equal(t, "cfo", e.To)
+
+	e, err = g.Choose("amount", map[string]interface{}{"amount": float64(100)})
+	require.NoError(t, err)
+	assert.Equal(t, "split", e.To)
+
+	// 条件求值出错视为不满足，走默认出线
+	e, err = g.Choose("amount", map[string]interface{}{})
+	require.NoError(t, err)
+	assert.Equal(t, "split", e.To)
+}
+
+func TestChooseWithoutDefault(t *testing.T) {
+	g, err := New([]Node{
+		{ID: "c", Type: TypeCondition, Name: "变更类型"},
+		{ID: "me", Type: TypeApprove},
+		{ID: "ee", Type: TypeApprove},
+	}, []Edge{
+		{From: "c", To: "me", Condition: json.RawMessage(`"change_type == 'structural'"`)},
+		{From: "c", To: "ee", Condition: json.RawMessage(`"change_type == 'electronic'"`)},
+	})
+	require.NoError(t, err)
+
+	e, err := g.Choose("c", map[string]interface{}{"change_type": "electronic"})
+	require.NoError(t, err)
+	assert.Equal(t, "ee", e.To)
+
+	_, err = g.Choose("c", map[string]interface{}{"change_type": "software"})
+	assert.ErrorContains(t, err, "条件节点[变更类型]没有满足条件的分支")
+}
+
+func TestLinearGraph(t *testing.T) {
+	g, err := New([]Node{
+		{Type: TypeSubmit},
+		{Type: TypeApprove, Name: "主管"},
+		{Type: TypeApprove, Name: "总监"},
+	}, nil)
+	require.NoError(t, err)
+	assert.True(t, g.Linear())
+	assert.Equal(t, "n0", g.Start())
+	assert.NoError(t, g.Validate())
+
+	next, err := g.Next("n1")
+	require.NoError(t, err)
+	assert.Equal(t, "n2", next)
+	next, err = g.Next("n2")
+	require.NoError(t, err)
+	assert.Empty(t, next)
+
+	g, err = New([]Node{{Type: TypeApprove}, {Type: TypeParallel, Name: "并行"}}, nil)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"节点[并行]是并行节点，需要配置连线"}, problems(t, g.Validate()))
+}
+
+func TestNewRejectsBadReferences(t *testing.T) {
+	_, err := New([]Node{{ID: "a"}, {ID: "a"}}, nil)
+	assert.ErrorContains(t, err, "节点 ID 重复")
+
+	_, err = New([]Node{{ID: "a"}}, []Edge{{From: "a", To: "b"}})
+	assert.ErrorContains(t, err, "连线终点不存在")
+}
+
+func TestValidateStructure(t *testing.T) {
+	g, err := New([]Node{
+		{ID: "s", Type: TypeSubmit},
+		{ID: "c", Type: TypeCondition, Name: "判断"},
+		{ID: "a", Type: TypeApprove, Name: "A"},
+		{ID: "b", Type: TypeApprove, Name: "B"},
+		{ID: "x", Type: "notify", Name: "X"},
+		{ID: "e", Type: TypeEnd},
+	}, []Edge{
+		{From: "s", To: "c"},
+		{From: "c", To: "a", Condition: json.RawMessage(`"amount >"`)},
+		{From: "c", To: "b"},
+		{From: "a", To: "e", Condition: json.RawMessage(`"amount > 1"`)},
+		{From: "a", To: "b"},
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{
+		"节点[X]类型无效: notify",
+		"条件节点[判断]的出线[判断→A]条件无效: 表达式错误 [amount >] 位置 8: 表达式不完整",
+		"条件节点[判断]的出线[判断→B]未设置条件（或应设为默认出线）",
+		"连线[A→e]不是条件节点的出线，不能设置条件",
+		"审批节点[A]只能有一条出线，分支请使用条件或并行节点",
+		"节点[B]没有出线，流程必须以结束节点收尾",
+		"节点[X]没有出线，流程必须以结束节点收尾",
+		"流程必须有且只有一个起始节点，当前为: s、X",
+		"节点[X]无法从起始节点到达",
+	}, problems(t, g.Validate()))
+}
+
+func TestValidateCycle(t *testing.T) {
+	g, err := New([]Node{
+		{ID: "s", Type: TypeSubmit},
+		{ID: "a", Type: TypeApprove, Name: "A"},
+		{ID: "c", Type: TypeCondition, Name: "重审"},
+		{ID: "e", Type: TypeEnd},
+	}, []Edge{
+		{From: "s", To: "a"},
+		{From: "a", To: "c"},
+		{From: "c", To: "a", Condition: json.RawMessage(`"again == true"`)},
+		{From: "c", To: "e", Default: true},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"流程存在回路（经过节点[A]）"}, problems(t, g.Validate()))
+}
+
+func TestValidateParallelPairs(t *testing.T) {
+	// 分支 q 未汇合直接结束，汇合节点只剩一条入线
+	g, err := New([]Node{
+		{ID: "s", Type: TypeSubmit},
+		{ID: "p", Type: TypeParallel, Name: "会签"},
+		{ID: "l", Type: TypeApprove, Name: "法务"},
+		{ID: "q", Type: TypeApprove, Name: "质量"},
+		{ID: "j", Type: TypeJoin, Name: "汇合"},
+		{ID: "e", Type: TypeEnd},
+	}, []Edge{
+		{From: "s", To: "p"},
+		{From: "p", To: "l"},
+		{From: "p", To: "q"},
+		{From: "l", To: "j"},
+		{From: "q", To: "e"},
+		{From: "j", To: "e"},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		"汇合节点[汇合]至少需要两条入线",
+		"并行节点[会签]的分支未汇合就到达了结束节点",
+	}, problems(t, g.Validate()))
+
+	// 嵌套并行：内层汇合后再汇合外层
+	g, err = New([]Node{
+		{ID: "s", Type: TypeSubmit},
+		{ID: "p1", Type: TypeParallel},
+		{ID: "p2", Type: TypeParallel},
+		{ID: "a", Type: TypeApprove},
+		{ID: "b", Type: TypeApprove},
+		{ID: "j2", Type: TypeJoin},
+		{ID: "c", Type: TypeApprove},
+		{ID: "j1", Type: TypeJoin},
+		{ID: "e", Type: TypeEnd},
+	}, []Edge{
+		{From: "s", To: "p1"},
+		{From: "p1", To: "p2"},
+		{From: "p1", To: "c"},
+		{From: "p2", To: "a"},
+		{From: "p2", To: "b"},
+		{From: "a", To: "j2"},
+		{From: "b", To: "j2"},
+		{From: "j2", To: "j1"},
+		{From: "c", To: "j1"},
+		{From: "j1", To: "e"},
+	})
+	require.NoError(t, err)
+	assert.NoError(t, g.Validate())
+
+	// 内层分支直接汇合到外层汇合节点
+	g, err = New([]Node{
+		{ID: "s", Type: TypeSubmit},
+		{ID: "p1", Type: TypeParallel, Name: "外层"},
+		{ID: "p2", Type: TypeParallel, Name: "内层"},
+		{ID: "a", Type: TypeApprove},
+		{ID: "b", Type: TypeApprove},
+		{ID: "c", Type: TypeApprove},
+		{ID: "j1", Type: TypeJoin, Name: "外层汇合"},
+		{ID: "e", Type: TypeEnd},
+	}, []Edge{
+		{From: "s", To: "p1"},
+		{From: "p1", To: "p2"},
+		{From: "p1", To: "c"},
+		{From: "p2", To: "a"},
+		{From: "p2", To: "b"},
+		{From: "a", To: "j1"},
+		{From: "b", To: "j1"},
+		{From: "c", To: "j1"},
+		{From: "j1", To: "e"},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		"并行节点[外层]的分支未汇合就到达了结束节点",
+		"汇合节点[外层汇合]汇合了不同并行节点的分支",
+	}, problems(t, g.Validate()))
+
+	// 并行分支在汇合前共用同一个审批节点
+	g, err = New([]Node{
+		{ID: "s", Type: TypeSubmit},
+		{ID: "p", Type: TypeParallel},
+		{ID: "a", Type: TypeApprove},
+		{ID: "b", Type: TypeApprove},
+		{ID: "x", Type: TypeApprove, Name: "共用"},
+		{ID: "y", Type: TypeApprove},
+		{ID: "j", Type: TypeJoin},
+		{ID: "e", Type: TypeEnd},
+	}, []Edge{
+		{From: "s", To: "p"},
+		{From: "p", To: "a"},
+		{From: "p", To: "b"},
+		{From: "p", To: "y"},
+		{From: "a", To: "x"},
+		{From: "b", To: "x"},
+		{From: "x", To: "j"},
+		{From: "y", To: "j"},
+		{From: "j", To: "e"},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		"节点[共用]被多个并行分支共用，请先在汇合节点汇合",
+	}, problems(t, g.Validate()))
+}
@@ -0,0 +1,190 @@
+package flowgraph
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/bitfantasy/nimo/internal/shared/expr"
+)
+
+// =============================================================================
+// 审批流程图 — 节点 + 连线
+// 未配置连线时节点按数组顺序串行（兼容旧的线性流程）
+// =============================================================================
+
+// 节点类型
+const (
+	TypeSubmit    = "submit"    // 发起
+	TypeApprove   = "approve"   // 审批
+	TypeCondition = "condition" // 条件分支：按出线条件选择一条路径
+	TypeParallel  = "parallel"  // 并行拆分：每条出线各成一个分支
+	TypeJoin      = "join"      // 并行汇合：等待对应并行节点的全部分支到达
+	TypeEnd       = "end"       // 结束
+)
+
+// Node 流程节点
+type Node struct {
+	ID   string
+	Type string
+	Name string
+}
+
+// Edge 连线
+type Edge struct {
+	From      string
+	To        string
+	Name      string
+	Condition json.RawMessage // 仅条件节点的出线使用，按表单值求值
+	Default   bool            // 条件节点的默认出线：其他条件都不满足时走这条
+}
+
+// Graph 流程图
+type Graph struct {
+	nodes  []Node
+	index  map[string]int
+	out    map[string][]Edge
+	in     map[string][]Edge
+	linear bool
+}
+
+// New 构建流程图。节点未设置 ID 时按下标生成 n0、n1…；
+// edges 为空时按节点顺序串联
+func New(nodes []Node, edges []Edge) (*Graph, error) {
+	g := &Graph{
+		nodes:  make([]Node, len(nodes)),
+		index:  make(map[string]int, len(nodes)),
+		out:    make(map[string][]Edge),
+		in:     make(map[string][]Edge),
+		linear: len(edges) == 0,
+	}
+	for i, n := range nodes {
+		if n.ID == "" {
+			n.ID = fmt.Sprintf("n%d", i)
+		}
+		if _, dup := g.index[n.ID]; dup {
+			return nil, fmt.Errorf("节点 ID 重复: %s", n.ID)
+		}
+		g.nodes[i] = n
+		g.index[n.ID] = i
+	}
+
+	if g.linear {
+		for i := 0; i+1 < len(g.nodes); i++ {
+			edges = append(edges, Edge{From: g.nodes[i].ID, To: g.nodes[i+1].ID})
+		}
+	}
+	for _, e := range edges {
+		if _, ok := g.index[e.From]; !ok {
+			return nil, fmt.Errorf("连线起点不存在: %s", e.From)
+		}
+		if _, ok := g.index[e.To]; !ok {
+			return nil, fmt.Errorf("连线终点不存在: %s", e.To)
+		}
+		g.out[e.From] = append(g.out[e.From], e)
+		g.in[e.To] = append(g.in[e.To], e)
+	}
+	return g, nil
+}
+
+// Linear 是否为未配置连线的线性流程
+func (g *Graph) Linear() bool { return g.linear }
+
+// Node 按 ID 查找节点，同时返回其在节点数组中的下标
+func (g *Graph) Node(id string) (Node, int, bool) {
+	i, ok := g.index[id]
+	if !ok {
+		return Node{}, -1, false
+	}
+	return g.nodes[i], i, true
+}
+
+// NodeAt 按下标取节点
+func (g *Graph) NodeAt(i int) (Node, bool) {
+	if i < 0 || i >= len(g.nodes) {
+		return Node{}, false
+	}
+	return g.nodes[i], true
+}
+
+// Outgoing 节点的出线（按定义顺序）
+func (g *Graph) Outgoing(id string) []Edge { return g.out[id] }
+
+// Start 起始节点：线性流程取第一个节点，否则取发起节点或唯一没有入线的节点
+func (g *Graph) Start() string {
+	if len(g.nodes) == 0 {
+		return ""
+	}
+	if g.linear {
+		return g.nodes[0].ID
+	}
+	for _, n := range g.nodes {
+		if n.Type == TypeSubmit {
+			return n.ID
+		}
+	}
+	for _, n := range g.nodes {
+		if len(g.in[n.ID]) == 0 {
+			return n.ID
+		}
+	}
+	return ""
+}
+
+// Next 单出线节点的后继；没有出线时返回空
+func (g *Graph) Next(id string) (string, error) {
+	out := g.out[id]
+	switch len(out) {
+	case 0:
+		return "", nil
+	case 1:
+		return out[0].To, nil
+	}
+	return "", fmt.Errorf("节点[%s]有多条出线", g.label(id))
+}
+
+// Choose 条件节点按出线顺序求值，返回第一条满足条件的出线；都不满足时走默认出线
+// 单条条件求值出错视为不满足，没有可走的出线时一并返回这些错误
+func (g *Graph) Choose(id string, vars map[string]interface{}) (Edge, error) {
+	var fallback *Edge
+	var evalErrs []string
+	for _, e := range g.out[id] {
+		if e.Default {
+			if fallback == nil {
+				e := e
+				fallback = &e
+			}
+			continue
+		}
+		ok, err := expr.EvaluateCondition(e.Condition, vars)
+		if err != nil {
+			evalErrs = append(evalErrs, fmt.Sprintf("%s: %v", g.edgeLabel(e), err))
+			continue
+		}
+		if ok {
+			return e, nil
+		}
+	}
+	if fallback != nil {
+		return *fallback, nil
+	}
+	msg := fmt.Sprintf("条件节点[%s]没有满足条件的分支", g.label(id))
+	if len(evalErrs) > 0 {
+		msg += "（" + strings.Join(evalErrs, "; ") + "）"
+	}
+	return Edge{}, fmt.Errorf("%s", msg)
+}
+
+func (g *Graph) label(id string) string {
+	if n, _, ok := g.Node(id); ok && n.Name != "" {
+		return n.Name
+	}
+	return id
+}
+
+func (g *Graph) edgeLabel(e Edge) string {
+	if e.Name != "" {
+		return e.Name
+	}
+	return g.label(e.From) + "→" + g.label(e.To)
+}
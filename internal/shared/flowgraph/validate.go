@@ -0,0 +1,321 @@
+package flowgraph
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/bitfantasy/nimo/internal/shared/expr"
+)
+
+// =============================================================================
+// 流程图校验 — 发布审批定义时调用
+// =============================================================================
+
+// ValidationError 流程图校验失败，Problems 为全部问题
+type ValidationError struct {
+	Problems []string `json:"problems"`
+}
+
+func (e *ValidationError) Error() string {
+	return "流程图无效: " + strings.Join(e.Problems, "; ")
+}
+
+// Validate 校验流程图结构：
+//   - 节点类型合法，至少一个审批节点
+//   - 唯一起始节点，所有节点可达，无环，没有出线的节点必须是结束节点
+//   - 发起/审批/汇合节点只有一条出线；条件节点至少两条出线，非默认出线须有合法条件，最多一条默认出线
+//   - 并行节点至少两条出线，其全部分支必须在同一个汇合节点汇合后才能继续或结束
+func (g *Graph) Validate() error {
+	v := &validator{g: g}
+	v.checkNodes()
+	if g.linear {
+		for _, n := range g.nodes {
+			switch n.Type {
+			case TypeCondition, TypeParallel, TypeJoin:
+				v.addf("节点[%s]是%s节点，需要配置连线", g.label(n.ID), typeName(n.Type))
+			}
+		}
+	} else {
+		v.checkEdges()
+		if v.checkAcyclic() && v.checkReachable() {
+			v.checkParallelPairs()
+		}
+	}
+	if len(v.problems) > 0 {
+		return &ValidationError{Problems: v.problems}
+	}
+	return nil
+}
+
+type validator struct {
+	g        *Graph
+	problems []string
+}
+
+func (v *validator) addf(format string, args ...interface{}) {
+	v.problems = append(v.problems, fmt.Sprintf(format, args...))
+}
+
+func typeName(t string) string {
+	switch t {
+	case TypeSubmit:
+		return "发起"
+	case TypeApprove:
+		return "审批"
+	case TypeCondition:
+		return "条件"
+	case TypeParallel:
+		return "并行"
+	case TypeJoin:
+		return "汇合"
+	case TypeEnd:
+		return "结束"
+	}
+	return t
+}
+
+func (v *validator) checkNodes() {
+	approves, submits := 0, 0
+	for _, n := range v.g.nodes {
+		switch n.Type {
+		case TypeApprove:
+			approves++
+		case TypeSubmit:
+			submits++
+		case TypeCondition, TypeParallel, TypeJoin, TypeEnd:
+		default:
+			v.addf("节点[%s]类型无效: %s", v.g.label(n.ID), n.Type)
+		}
+	}
+	if approves == 0 {
+		v.addf("流程中没有审批节点")
+	}
+	if submits > 1 {
+		v.addf("流程只能有一个发起节点")
+	}
+}
+
+func (v *validator) checkEdges() {
+	g := v.g
+	var starts []string
+	ends := 0
+	for _, n := range g.nodes {
+		out, in := g.out[n.ID], g.in[n.ID]
+		label := g.label(n.ID)
+		if len(in) == 0 {
+			starts = append(starts, label)
+		}
+		if n.Type == TypeSubmit && len(in) > 0 {
+			v.addf("发起节点[%s]不能有入线", label)
+		}
+
+		for _, e := range out {
+			if e.To == e.From {
+				v.addf("节点[%s]的连线指向自身", label)
+			}
+			if n.Type != TypeCondition && len(e.Condition) > 0 && expr.DescribeCondition(e.Condition) != "" {
+				v.addf("连线[%s]不是条件节点的出线，不能设置条件", g.edgeLabel(e))
+			}
+		}
+
+		switch n.Type {
+		case TypeEnd:
+			ends++
+			if len(out) > 0 {
+				v.addf("结束节点[%s]不能有出线", label)
+			}
+		case TypeCondition:
+			v.checkConditionEdges(n, out)
+		case TypeParallel:
+			if len(out) < 2 {
+				v.addf("并行节点[%s]至少需要两条出线", label)
+			}
+		case TypeJoin:
+			if len(in) < 2 {
+				v.addf("汇合节点[%s]至少需要两条入线", label)
+			}
+			if len(out) != 1 {
+				v.addf("汇合节点[%s]必须有且只有一条出线", label)
+			}
+		default:
+			if len(out) == 0 {
+				v.addf("节点[%s]没有出线，流程必须以结束节点收尾", label)
+			} else if len(out) > 1 {
+				v.addf("%s节点[%s]只能有一条出线，分支请使用条件或并行节点", typeName(n.Type), label)
+			}
+		}
+	}
+	if len(starts) != 1 {
+		v.addf("流程必须有且只有一个起始节点，当前为: %s", strings.Join(starts, "、"))
+	}
+	if ends == 0 {
+		v.addf("流程中没有结束节点")
+	}
+}
+
+func (v *validator) checkConditionEdges(n Node, out []Edge) {
+	label := v.g.label(n.ID)
+	if len(out) < 2 {
+		v.addf("条件节点[%s]至少需要两条出线", label)
+	}
+	defaults := 0
+	for _, e := range out {
+		if e.Default {
+			defaults++
+			continue
+		}
+		if expr.DescribeCondition(e.Condition) == "" {
+			v.addf("条件节点[%s]的出线[%s]未设置条件（或应设为默认出线）", label, v.g.edgeLabel(e))
+			continue
+		}
+		if err := expr.ValidateCondition(e.Condition); err != nil {
+			v.addf("条件节点[%s]的出线[%s]条件无效: %v", label, v.g.edgeLabel(e), err)
+		}
+	}
+	if defaults > 1 {
+		v.addf("条件节点[%s]只能有一条默认出线", label)
+	}
+}
+
+// checkAcyclic 有环时流程无法结束
+func (v *validator) checkAcyclic() bool {
+	const (
+		white = iota
+		grey
+		black
+	)
+	color := make(map[string]int, len(v.g.nodes))
+	var cyclic string
+	var visit func(id string) bool
+	visit = func(id string) bool {
+		color[id] = grey
+		for _, e := range v.g.out[id] {
+			switch color[e.To] {
+			case grey:
+				cyclic = e.To
+				return false
+			case white:
+				if !visit(e.To) {
+					return false
+				}
+			}
+		}
+		color[id] = black
+		return true
+	}
+	for _, n := range v.g.nodes {
+		if color[n.ID] == white && !visit(n.ID) {
+			v.addf("流程存在回路（经过节点[%s]）", v.g.label(cyclic))
+			return false
+		}
+	}
+	return true
+}
+
+// checkReachable 所有节点都应能从起始节点到达
+func (v *validator) checkReachable() bool {
+	start := v.g.Start()
+	if start == "" {
+		return false
+	}
+	seen := map[string]bool{start: true}
+	queue := []string{start}
+	for len(queue) > 0 {
+		id := queue[0]
+		queue = queue[1:]
+		for _, e := range v.g.out[id] {
+			if !seen[e.To] {
+				seen[e.To] = true
+				queue = append(queue, e.To)
+			}
+		}
+	}
+	ok := true
+	for _, n := range v.g.nodes {
+		if !seen[n.ID] {
+			v.addf("节点[%s]无法从起始节点到达", v.g.label(n.ID))
+			ok = false
+		}
+	}
+	return ok
+}
+
+// checkParallelPairs 沿所有路径跟踪尚未汇合的并行分支栈（并行节点 + 第几条出线）：
+// 汇合节点只能汇合栈顶并行节点的分支，且每个并行节点只对应一个汇合节点；
+// 汇合前各分支不能共用节点；到达结束节点时栈必须为空
+func (v *validator) checkParallelPairs() {
+	type frame struct {
+		split  string
+		branch int
+	}
+	keyOf := func(stack []frame) string {
+		parts := make([]string, len(stack))
+		for i, f := range stack {
+			parts[i] = fmt.Sprintf("%s#%d", f.split, f.branch)
+		}
+		return strings.Join(parts, ",")
+	}
+
+	g := v.g
+	joinOf := make(map[string]string)  // 并行节点 → 汇合节点
+	splitOf := make(map[string]string) // 汇合节点 → 并行节点
+	stackAt := make(map[string]string) // 节点 → 所在分支
+	reported := make(map[string]bool)
+	report := func(key, format string, args ...interface{}) {
+		if !reported[key] {
+			reported[key] = true
+			v.addf(format, args...)
+		}
+	}
+
+	visited := make(map[string]bool)
+	var walk func(id string, stack []frame)
+	walk = func(id string, stack []frame) {
+		key := id + "|" + keyOf(stack)
+		if visited[key] {
+			return
+		}
+		visited[key] = true
+
+		n, _, _ := g.Node(id)
+		switch n.Type {
+		case TypeEnd:
+			if len(stack) > 0 {
+				split := stack[len(stack)-1].split
+				report("open:"+split, "并行节点[%s]的分支未汇合就到达了结束节点", g.label(split))
+			}
+			return
+		case TypeJoin:
+			if len(stack) == 0 {
+				report("orphan:"+id, "汇合节点[%s]没有对应的并行节点", g.label(id))
+				return
+			}
+			split := stack[len(stack)-1].split
+			if prev, ok := splitOf[id]; ok && prev != split {
+				report("mixed:"+id, "汇合节点[%s]汇合了不同并行节点的分支", g.label(id))
+				return
+			}
+			if prev, ok := joinOf[split]; ok && prev != id {
+				report("split:"+split, "并行节点[%s]的分支汇合到了多个汇合节点", g.label(split))
+				return
+			}
+			splitOf[id], joinOf[split] = split, id
+			stack = stack[:len(stack)-1]
+		default:
+			if prev, ok := stackAt[id]; ok && prev != keyOf(stack) {
+				report("shared:"+id, "节点[%s]被多个并行分支共用，请先在汇合节点汇合", g.label(id))
+				return
+			}
+			stackAt[id] = keyOf(stack)
+		}
+
+		for i, e := range g.out[id] {
+			next := stack
+			if n.Type == TypeParallel {
+				next = append(append([]frame(nil), stack...), frame{split: id, branch: i})
+			}
+			walk(e.To, next)
+		}
+	}
+	walk(g.Start(), nil)
+}
@@ -0,0 +1,127 @@
+package formschema
+
+import (
+	"reflect"
+	"sort"
+)
+
+// =============================================================================
+// 提交版本对比
+// =============================================================================
+
+// 字段变更类型
+const (
+	ChangeAdded    = "added"    // 原先未填写
+	ChangeRemoved  = "removed"  // 改为未填写
+	ChangeModified = "modified" // 值变化
+)
+
+// FieldDiff 两次提交之间单个字段的差异
+type FieldDiff struct {
+	Key          string        `json:"key"`
+	Label        string        `json:"label"`
+	Type         string        `json:"type"`
+	Change       string        `json:"change"`
+	From         interface{}   `json:"from"`
+	To           interface{}   `json:"to"`
+	FilesAdded   []interface{} `json:"files_added,omitempty"`   // 附件字段新增的文件
+	FilesRemoved []interface{} `json:"files_removed,omitempty"` // 附件字段移除的文件
+}
+
+// Diff 对比两次提交的数据，按字段定义顺序返回有变化的字段
+// 不在字段定义中的键（已删除的字段、系统控件数据）排在最后，按键名排序
+func (s Schema) Diff(from, to map[string]interface{}) []FieldDiff {
+	diffs := []FieldDiff{}
+	known := make(map[string]bool, len(s))
+	for _, f := range s {
+		if f.Type == TypeDescription || f.Key == "" {
+			continue
+		}
+		known[f.Key] = true
+		if d, ok := diffField(f, from[f.Key], to[f.Key]); ok {
+			diffs = append(diffs, d)
+		}
+	}
+
+	var extra []string
+	for _, data := range []map[string]interface{}{from, to} {
+		for k := range data {
+			if !known[k] {
+				known[k] = true
+				extra = append(extra, k)
+			}
+		}
+	}
+	sort.Strings(extra)
+	for _, k := range extra {
+		if d, ok := diffField(Field{Key: k}, from[k], to[k]); ok {
+			diffs = append(diffs, d)
+		}
+	}
+	return diffs
+}
+
+func diffField(f Field, a, b interface{}) (FieldDiff, bool) {
+	if (isEmpty(a) && isEmpty(b)) || reflect.DeepEqual(a, b) {
+		return FieldDiff{}, false
+	}
+	d := FieldDiff{Key: f.Key, Label: f.name(), Type: f.Type, From: a, To: b}
+	switch {
+	case isEmpty(a):
+		d.Change = ChangeAdded
+	case isEmpty(b):
+		d.Change = ChangeRemoved
+	default:
+		d.Change = ChangeModified
+	}
+	if f.Type == TypeFile || f.Type == TypeAttachment {
+		d.FilesAdded, d.FilesRemoved = diffFiles(a, b)
+	}
+	return d, true
+}
+
+// diffFiles 按文件标识（id > url > 文件名）对比附件列表
+func diffFiles(a, b interface{}) (added, removed []interface{}) {
+	before, after := fileList(a), fileList(b)
+	beforeIDs := make(map[string]bool, len(before))
+	for _, f := range before {
+		beforeIDs[fileIdentity(f)] = true
+	}
+	afterIDs := make(map[string]bool, len(after))
+	for _, f := range after {
+		id := fileIdentity(f)
+		afterIDs[id] = true
+		if !beforeIDs[id] {
+			added = append(added, f)
+		}
+	}
+	for _, f := range before {
+		if !afterIDs[fileIdentity(f)] {
+			removed = append(removed, f)
+		}
+	}
+	return added, removed
+}
+
+func fileList(v interface{}) []interface{} {
+	switch x := v.(type) {
+	case []interface{}:
+		return x
+	case map[string]interface{}:
+		return []interface{}{x}
+	}
+	return nil
+}
+
+func fileIdentity(f interface{}) string {
+	m, ok := f.(map[string]interface{})
+	if !ok {
+		return scalarString(f)
+	}
+	for _, k := range []string{"id", "url", "filename", "name"} {
+		if s := scalarString(m[k]); s != "" {
+			return k + ":" + s
+		}
+	}
+	return ""
+}
@@ -0,0 +1,190 @@
+package formschema
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testSchema = `[
+	{"key": "title", "label": "标题", "type": "text", "required": true, "max_length": 10},
+	{"key": "code", "label": "编码", "type": "text", "pattern": "^[A-Z]{2}-\\d+$", "pattern_message": "编码格式应为 XX-123"},
+	{"key": "qty", "label": "数量", "type": "number", "min": 1, "max": 100},
+	{"key": "level", "label": "等级", "type": "select", "options": ["A", "B", {"label": "丙", "value": "C"}]},
+	{"key": "markets", "label": "市场", "type": "multiselect", "options": ["CN", "EU", "US"], "max_length": 2},
+	{"key": "has_battery", "label": "带电池", "type": "checkbox"},
+	{"key": "battery_report", "label": "电池报告", "type": "file", "required": true, "accept": ".pdf,image/*", "max_size": 1048576,
+		"show_if": {"field": "has_battery", "op": "eq", "value": true}},
+	{"key": "period", "label": "周期", "type": "daterange"},
+	{"key": "items", "label": "明细", "type": "table", "columns": [
+		{"key": "name", "label": "名称", "type": "text", "required": true},
+		{"key": "price", "label": "单价", "type": "money", "min": 0}
+	]},
+	{"key": "note", "label": "说明", "type": "description"}
+]`
+
+func parseTestSchema(t *testing.T) Schema {
+	t.Helper()
+	schema, err := ParseAndCheck(json.RawMessage(testSchema))
+	require.NoError(t, err)
+	return schema
+}
+
+func fieldErrors(t *testing.T, err error) map[string]string {
+	t.Helper()
+	var verr *ValidationError
+	require.True(t, errors.As(err, &verr), "expected ValidationError, got %v", err)
+	rules := make(map[string]string)
+	for _, fe := range verr.Errors {
+		rules[fe.Key] = fe.Rule
+	}
+	return rules
+}
+
+func TestValidatePasses(t *testing.T) {
+	schema := parseTestSchema(t)
+	err := schema.Validate(map[string]interface{}{
+		"title":       "新品评审",
+		"code":        "EV-12",
+		"qty":         float64(5),
+		"level":       "C",
+		"markets":     []interface{}{"CN", "EU"},
+		"has_battery": true,
+		"battery_report": []interface{}{
+			map[string]interface{}{"filename": "UN38.3.pdf", "size": float64(2048)},
+		},
+		"period": []interface{}{"2026-01-01", "2026-02-01"},
+		"items": []interface{}{
+			map[string]interface{}{"name": "电芯", "price": "12.5"},
+		},
+	})
+	assert.NoError(t, err)
+}
+
+func TestValidateFieldErrors(t *testing.T) {
+	schema := parseTestSchema(t)
+	err := schema.Validate(map[string]interface{}{
+		"title":       "超过十个字符的标题文本内容",
+		"code":        "ev12",
+		"qty":         float64(0),
+		"level":       "D",
+		"markets":     []interface{}{"CN", "EU", "US"},
+		"has_battery": true,
+		"battery_report": []interface{}{
+			map[string]interface{}{"filename": "report.docx", "size": float64(2 << 20)},
+		},
+		"period": []interface{}{"2026-02-01", "2026-01-01"},
+		"items": []interface{}{
+			map[string]interface{}{"price": float64(-1)},
+		},
+	})
+	assert.Equal(t, map[string]string{
+		"title":          RuleMaxLength,
+		"code":           RulePattern,
+		"qty":            RuleMin,
+		"level":          RuleOptions,
+		"markets":        RuleMaxLength,
+		"battery_report": RuleMaxSize,
+		"period":         RuleType,
+		"items[0].name":  RuleRequired,
+		"items[0].price": RuleMin,
+	}, fieldErrors(t, err))
+	assert.Contains(t, err.Error(), "编码格式应为 XX-123")
+}
+
+func TestValidateShowIf(t *testing.T) {
+	schema := parseTestSchema(t)
+
+	// 电池报告隐藏时不要求必填
+	assert.NoError(t, schema.Validate(map[string]interface{}{"title": "T", "has_battery": false}))
+	assert.NoError(t, schema.Validate(map[string]interface{}{"title": "T"}))
+
+	err := schema.Validate(map[string]interface{}{"has_battery": true})
+	assert.Equal(t, map[string]string{
+		"title":          RuleRequired,
+		"battery_report": RuleRequired,
+	}, fieldErrors(t, err))
+}
+
+func TestVisibleChainsHiddenFields(t *testing.T) {
+	schema, err := ParseAndCheck(json.RawMessage(`[
+		{"key": "a", "type": "checkbox"},
+		{"key": "b", "type": "text", "show_if": "a == true"},
+		{"key": "c", "type": "text", "show_if": "b == 'x'"}
+	]`))
+	require.NoError(t, err)
+
+	// b 被隐藏后其残留值不再让 c 显示
+	visible := schema.Visible(map[string]interface{}{"a": false, "b": "x"})
+	assert.False(t, visible["b"])
+	assert.False(t, visible["c"])
+
+	visible = schema.Visible(map[string]interface{}{"a": true, "b": "x"})
+	assert.True(t, visible["b"])
+	assert.True(t, visible["c"])
+}
+
+func TestCheckRejectsBadDefinitions(t *testing.T) {
+	_, err := ParseAndCheck(json.RawMessage(`[
+		{"key": "a", "label": "A", "type": "text", "pattern": "("},
+		{"key": "a", "label": "A2", "type": "number", "min": 5, "max": 1},
+		{"label": "无键", "type": "text"},
+		{"key": "b", "label": "B", "type": "text", "show_if": "a =="}
+	]`))
+	require.Error(t, err)
+	for _, want := range []string{"[A] 正则无效", "key 重复: a", "[A2] 最小值大于最大值", "[无键] 缺少 key", "[B] 显示条件无效"} {
+		assert.Contains(t, err.Error(), want)
+	}
+
+	schema, err := ParseAndCheck(nil)
+	assert.NoError(t, err)
+	assert.NoError(t, schema.Validate(nil))
+}
+
+func TestAccepts(t *testing.T) {
+	assert.True(t, accepts(".pdf, .docx", "A.PDF", ""))
+	assert.True(t, accepts("image/*", "photo.png", ""))
+	assert.True(t, accepts("application/pdf", "x", "application/pdf; charset=binary"))
+	assert.False(t, accepts(".pdf,image/*", "data.xlsx", ""))
+}
+
+func TestDiff(t *testing.T) {
+	schema := parseTestSchema(t)
+	report := func(id, name string) map[string]interface{} {
+		return map[string]interface{}{"id": id, "filename": name}
+	}
+	from := map[string]interface{}{
+		"title":          "v1",
+		"qty":            float64(3),
+		"code":           "EV-1",
+		"battery_report": []interface{}{report("f1", "a.pdf"), report("f2", "b.pdf")},
+		"legacy":         "old",
+	}
+	to := map[string]interface{}{
+		"title":          "v1",
+		"qty":            float64(4),
+		"level":          "A",
+		"battery_report": []interface{}{report("f2", "b.pdf"), report("f3", "c.pdf")},
+		"bom":            map[string]interface{}{"item_count": float64(2)},
+	}
+
+	diffs := schema.Diff(from, to)
+	changes := make([]string, 0, len(diffs))
+	for _, d := range diffs {
+		changes = append(changes, d.Key+":"+d.Change)
+	}
+	assert.Equal(t, []string{
+		"code:removed", "qty:modified", "level:added", "battery_report:modified",
+		"bom:added", "legacy:removed",
+	}, changes)
+
+	files := diffs[3]
+	assert.Equal(t, []interface{}{report("f3", "c.pdf")}, files.FilesAdded)
+	assert.Equal(t, []interface{}{report("f1", "a.pdf")}, files.FilesRemoved)
+	assert.Equal(t, "电池报告", files.Label)
+
+	assert.Empty(t, schema.Diff(from, from))
+}
@@ -0,0 +1,193 @@
+package formschema
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/bitfantasy/nimo/internal/shared/expr"
+)
+
+// =============================================================================
+// 表单字段定义 — 任务表单、模板任务表单、审批表单共用
+// =============================================================================
+
+// 字段类型（未列出的自定义控件如 bom_upload、cmf 只做必填校验）
+const (
+	TypeText        = "text"
+	TypeTextarea    = "textarea"
+	TypeNumber      = "number"
+	TypeMoney       = "money"
+	TypeSelect      = "select"
+	TypeMultiselect = "multiselect"
+	TypeCheckbox    = "checkbox"
+	TypeDate        = "date"
+	TypeDaterange   = "daterange"
+	TypeUser        = "user"
+	TypeFile        = "file"
+	TypeAttachment  = "attachment"
+	TypeTable       = "table"
+	TypeDescription = "description" // 说明文字，不收集数据
+)
+
+// Field 表单字段
+type Field struct {
+	Key            string          `json:"key"`
+	Label          string          `json:"label"`
+	Type           string          `json:"type"`
+	Required       bool            `json:"required"`
+	Options        Options         `json:"options,omitempty"`         // select/multiselect/checkbox 可选值
+	Min            *float64        `json:"min,omitempty"`             // number/money 最小值
+	Max            *float64        `json:"max,omitempty"`             // number/money 最大值
+	MinLength      *int            `json:"min_length,omitempty"`      // 文本长度，或多选/明细表的条数下限
+	MaxLength      *int            `json:"max_length,omitempty"`      // 文本长度，或多选/明细表的条数上限
+	Pattern        string          `json:"pattern,omitempty"`         // 文本正则
+	PatternMessage string          `json:"pattern_message,omitempty"` // 正则不匹配时的提示
+	Accept         string          `json:"accept,omitempty"`          // 附件类型，如 ".pdf,.docx,image/*"
+	MaxSize        int64           `json:"max_size,omitempty"`        // 单个附件大小上限（字节）
+	MaxFiles       int             `json:"max_files,omitempty"`       // 附件个数上限
+	Multiple       bool            `json:"multiple,omitempty"`        // user/file 是否多选
+	Columns        []Field         `json:"columns,omitempty"`         // table 明细列
+	ShowIf         json.RawMessage `json:"show_if,omitempty"`         // 显示条件（expr JSON 条件，变量为其他字段的值）
+}
+
+// Options 可选值，兼容 ["A","B"] 与 [{"label":"A","value":"a"}] 两种写法
+type Options []string
+
+// UnmarshalJSON 实现 json.Unmarshaler
+func (o *Options) UnmarshalJSON(data []byte) error {
+	var raw []interface{}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return fmt.Errorf("options 必须是数组: %w", err)
+	}
+	opts := make(Options, 0, len(raw))
+	for _, item := range raw {
+		if m, ok := item.(map[string]interface{}); ok {
+			if v, ok := m["value"]; ok {
+				item = v
+			} else {
+				item = m["label"]
+			}
+		}
+		opts = append(opts, scalarString(item))
+	}
+	*o = opts
+	return nil
+}
+
+// Schema 表单字段列表
+type Schema []Field
+
+// Parse 解析表单字段 JSON；空定义返回 nil
+func Parse(raw json.RawMessage) (Schema, error) {
+	trimmed := strings.TrimSpace(string(raw))
+	if trimmed == "" || trimmed == "null" {
+		return nil, nil
+	}
+	var schema Schema
+	if err := json.Unmarshal(raw, &schema); err != nil {
+		return nil, fmt.Errorf("表单字段定义不是合法的 JSON: %w", err)
+	}
+	return schema, nil
+}
+
+// ParseAndCheck 解析并检查表单字段定义，保存表单时调用
+func ParseAndCheck(raw json.RawMessage) (Schema, error) {
+	schema, err := Parse(raw)
+	if err != nil {
+		return nil, err
+	}
+	if err := schema.Check(); err != nil {
+		return nil, err
+	}
+	return schema, nil
+}
+
+// Check 检查字段定义：key 唯一、正则和显示条件可编译、上下限不倒挂
+func (s Schema) Check() error {
+	var problems []string
+	seen := make(map[string]bool)
+	for _, f := range s {
+		if f.Type == TypeDescription {
+			continue
+		}
+		name := f.name()
+		if f.Key == "" {
+			problems = append(problems, fmt.Sprintf("字段 [%s] 缺少 key", name))
+			continue
+		}
+		if seen[f.Key] {
+			problems = append(problems, fmt.Sprintf("字段 key 重复: %s", f.Key))
+		}
+		seen[f.Key] = true
+		problems = append(problems, f.check(name)...)
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("表单字段定义无效: %s", strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+func (f Field) check(name string) []string {
+	var problems []string
+	if f.Pattern != "" {
+		if _, err := regexp.Compile(f.Pattern); err != nil {
+			problems = append(problems, fmt.Sprintf("字段 [%s] 正则无效: %v", name, err))
+		}
+	}
+	if err := expr.ValidateCondition(f.ShowIf); err != nil {
+		problems = append(problems, fmt.Sprintf("字段 [%s] 显示条件无效: %v", name, err))
+	}
+	if f.Min != nil && f.Max != nil && *f.Min > *f.Max {
+		problems = append(problems, fmt.Sprintf("字段 [%s] 最小值大于最大值", name))
+	}
+	if f.MinLength != nil && f.MaxLength != nil && *f.MinLength > *f.MaxLength {
+		problems = append(problems, fmt.Sprintf("字段 [%s] 最小长度大于最大长度", name))
+	}
+	if f.MaxSize < 0 || f.MaxFiles < 0 {
+		problems = append(problems, fmt.Sprintf("字段 [%s] 附件限制不能为负数", name))
+	}
+	if f.Type == TypeTable {
+		seen := make(map[string]bool)
+		for _, col := range f.Columns {
+			colName := name + "." + col.name()
+			if col.Key == "" {
+				problems = append(problems, fmt.Sprintf("明细列 [%s] 缺少 key", colName))
+				continue
+			}
+			if seen[col.Key] {
+				problems = append(problems, fmt.Sprintf("明细列 key 重复: %s.%s", f.Key, col.Key))
+			}
+			seen[col.Key] = true
+			problems = append(problems, col.check(colName)...)
+		}
+	}
+	return problems
+}
+
+// name 错误提示中的字段名
+func (f Field) name() string {
+	if f.Label != "" {
+		return f.Label
+	}
+	return f.Key
+}
+
+// Visible 按字段顺序求值显示条件，返回各字段是否显示
+// 被隐藏字段的值不参与后续字段的条件求值；条件引用的字段未填写导致求值出错时视为不显示
+func (s Schema) Visible(data map[string]interface{}) map[string]bool {
+	visible := make(map[string]bool, len(s))
+	vars := make(map[string]interface{}, len(data))
+	for k, v := range data {
+		vars[k] = v
+	}
+	for _, f := range s {
+		ok, err := expr.EvaluateCondition(f.ShowIf, vars)
+		visible[f.Key] = err == nil && ok
+		if !visible[f.Key] {
+			delete(vars, f.Key)
+		}
+	}
+	return visible
+}
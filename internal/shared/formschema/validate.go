@@ -0,0 +1,380 @@
+package formschema
+
+import (
+	"encoding/json"
+	"fmt"
+	"mime"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// =============================================================================
+// 提交数据校验
+// =============================================================================
+
+// 校验规则（FieldError.Rule）
+const (
+	RuleRequired  = "required"
+	RuleType      = "type"
+	RuleMin       = "min"
+	RuleMax       = "max"
+	RuleMinLength = "min_length"
+	RuleMaxLength = "max_length"
+	RulePattern   = "pattern"
+	RuleOptions   = "options"
+	RuleAccept    = "accept"
+	RuleMaxSize   = "max_size"
+	RuleMaxFiles  = "max_files"
+)
+
+// FieldError 字段校验错误
+type FieldError struct {
+	Key     string `json:"key"` // 明细表单元格为 "items[0].qty"
+	Label   string `json:"label"`
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+}
+
+// ValidationError 表单校验错误，包含全部字段错误
+type ValidationError struct {
+	Errors []FieldError `json:"errors"`
+}
+
+func (e *ValidationError) Error() string {
+	msgs := make([]string, 0, len(e.Errors))
+	for _, fe := range e.Errors {
+		msgs = append(msgs, fe.Message)
+	}
+	return "表单校验失败: " + strings.Join(msgs, "; ")
+}
+
+// dateLayouts 日期字段接受的格式
+var dateLayouts = []string{"2006-01-02", time.RFC3339, "2006-01-02 15:04:05", "2006-01-02T15:04:05"}
+
+// Validate 按字段定义校验提交数据；隐藏字段（显示条件不满足）不校验
+// 全部通过返回 nil，否则返回 *ValidationError
+func (s Schema) Validate(data map[string]interface{}) error {
+	if len(s) == 0 {
+		return nil
+	}
+	v := &validator{}
+	visible := s.Visible(data)
+	for _, f := range s {
+		if f.Type == TypeDescription || !visible[f.Key] {
+			continue
+		}
+		v.field(f, f.Key, f.name(), data[f.Key])
+	}
+	if len(v.errs) > 0 {
+		return &ValidationError{Errors: v.errs}
+	}
+	return nil
+}
+
+type validator struct {
+	errs []FieldError
+}
+
+func (v *validator) fail(key, label, rule, format string, args ...interface{}) {
+	v.errs = append(v.errs, FieldError{
+		Key:     key,
+		Label:   label,
+		Rule:    rule,
+		Message: "[" + label + "] " + fmt.Sprintf(format, args...),
+	})
+}
+
+func (v *validator) field(f Field, key, label string, val interface{}) {
+	if isEmpty(val) {
+		if f.Required {
+			v.fail(key, label, RuleRequired, "为必填项")
+		}
+		return
+	}
+
+	switch f.Type {
+	case TypeText, TypeTextarea:
+		s, ok := val.(string)
+		if !ok {
+			v.fail(key, label, RuleType, "应为文本")
+			return
+		}
+		v.length(f, key, label, utf8.RuneCountInString(s), "长度")
+		if f.Pattern != "" {
+			if re, err := regexp.Compile(f.Pattern); err == nil && !re.MatchString(s) {
+				msg := f.PatternMessage
+				if msg == "" {
+					msg = "格式不正确"
+				}
+				v.fail(key, label, RulePattern, "%s", msg)
+			}
+		}
+
+	case TypeNumber, TypeMoney:
+		n, ok := asNumber(val)
+		if !ok {
+			v.fail(key, label, RuleType, "应为数字")
+			return
+		}
+		if f.Min != nil && n < *f.Min {
+			v.fail(key, label, RuleMin, "不能小于 %s", formatNumber(*f.Min))
+		}
+		if f.Max != nil && n > *f.Max {
+			v.fail(key, label, RuleMax, "不能大于 %s", formatNumber(*f.Max))
+		}
+
+	case TypeSelect:
+		if _, ok := val.([]interface{}); ok {
+			v.fail(key, label, RuleType, "只能选择一项")
+			return
+		}
+		v.options(f, key, label, val)
+
+	case TypeMultiselect:
+		items, ok := val.([]interface{})
+		if !ok {
+			v.fail(key, label, RuleType, "应为多选列表")
+			return
+		}
+		v.length(f, key, label, len(items), "选择数量")
+		for _, item := range items {
+			v.options(f, key, label, item)
+		}
+
+	case TypeCheckbox:
+		switch x := val.(type) {
+		case bool:
+		case []interface{}:
+			for _, item := range x {
+				v.options(f, key, label, item)
+			}
+		default:
+			v.fail(key, label, RuleType, "应为勾选值")
+		}
+
+	case TypeDate:
+		if _, ok := asDate(val); !ok {
+			v.fail(key, label, RuleType, "日期格式不正确")
+		}
+
+	case TypeDaterange:
+		items, ok := val.([]interface{})
+		if !ok || len(items) != 2 {
+			v.fail(key, label, RuleType, "应为开始、结束两个日期")
+			return
+		}
+		start, ok1 := asDate(items[0])
+		end, ok2 := asDate(items[1])
+		if !ok1 || !ok2 {
+			v.fail(key, label, RuleType, "日期格式不正确")
+		} else if end.Before(start) {
+			v.fail(key, label, RuleType, "结束日期早于开始日期")
+		}
+
+	case TypeUser:
+		switch x := val.(type) {
+		case string:
+		case []interface{}:
+			for _, item := range x {
+				if _, ok := item.(string); !ok {
+					v.fail(key, label, RuleType, "人员格式不正确")
+					return
+				}
+			}
+			if !f.Multiple && len(x) > 1 {
+				v.fail(key, label, RuleType, "只能选择一人")
+			}
+		default:
+			v.fail(key, label, RuleType, "人员格式不正确")
+		}
+
+	case TypeFile, TypeAttachment:
+		v.files(f, key, label, val)
+
+	case TypeTable:
+		rows, ok := val.([]interface{})
+		if !ok {
+			v.fail(key, label, RuleType, "应为明细行列表")
+			return
+		}
+		v.length(f, key, label, len(rows), "行数")
+		for i, r := range rows {
+			row, ok := r.(map[string]interface{})
+			if !ok {
+				v.fail(fmt.Sprintf("%s[%d]", key, i), label, RuleType, "第 %d 行格式不正确", i+1)
+				continue
+			}
+			for _, col := range f.Columns {
+				v.field(col, fmt.Sprintf("%s[%d].%s", key, i, col.Key), fmt.Sprintf("%s 第%d行 %s", label, i+1, col.name()), row[col.Key])
+			}
+		}
+	}
+}
+
+// length 校验文本长度或条数
+func (v *validator) length(f Field, key, label string, n int, what string) {
+	if f.MinLength != nil && n < *f.MinLength {
+		v.fail(key, label, RuleMinLength, "%s不能少于 %d", what, *f.MinLength)
+	}
+	if f.MaxLength != nil && n > *f.MaxLength {
+		v.fail(key, label, RuleMaxLength, "%s不能超过 %d", what, *f.MaxLength)
+	}
+}
+
+// options 校验取值在可选范围内（未配置可选值时不限）
+func (v *validator) options(f Field, key, label string, val interface{}) {
+	if len(f.Options) == 0 {
+		return
+	}
+	s := scalarString(val)
+	for _, o := range f.Options {
+		if o == s {
+			return
+		}
+	}
+	v.fail(key, label, RuleOptions, "取值 %q 不在可选范围内", s)
+}
+
+// files 校验附件：个数、类型、大小
+// 附件为上传接口返回的对象 {id, url, filename, size}，单个对象或数组均可
+func (v *validator) files(f Field, key, label string, val interface{}) {
+	var items []interface{}
+	switch x := val.(type) {
+	case []interface{}:
+		items = x
+	case map[string]interface{}:
+		items = []interface{}{x}
+	default:
+		v.fail(key, label, RuleType, "附件格式不正确")
+		return
+	}
+
+	if f.MaxFiles > 0 && len(items) > f.MaxFiles {
+		v.fail(key, label, RuleMaxFiles, "最多上传 %d 个文件", f.MaxFiles)
+	} else if f.Type == TypeFile && f.MaxFiles == 0 && !f.Multiple && len(items) > 1 {
+		v.fail(key, label, RuleMaxFiles, "只能上传一个文件")
+	}
+	for _, item := range items {
+		file, ok := item.(map[string]interface{})
+		if !ok {
+			v.fail(key, label, RuleType, "附件格式不正确")
+			continue
+		}
+		name := scalarString(file["filename"])
+		if name == "" {
+			name = scalarString(file["name"])
+		}
+		if f.Accept != "" && !accepts(f.Accept, name, scalarString(file["content_type"])) {
+			v.fail(key, label, RuleAccept, "文件 %s 类型不符合要求（%s）", name, f.Accept)
+		}
+		if size, ok := asNumber(file["size"]); ok && f.MaxSize > 0 && int64(size) > f.MaxSize {
+			v.fail(key, label, RuleMaxSize, "文件 %s 超过大小限制 %s", name, formatSize(f.MaxSize))
+		}
+	}
+}
+
+// accepts 按 accept 规则（扩展名或 MIME，支持 image/* 通配）判断文件类型
+func accepts(accept, filename, contentType string) bool {
+	ext := strings.ToLower(filepath.Ext(filename))
+	if contentType == "" && ext != "" {
+		contentType = mime.TypeByExtension(ext)
+	}
+	contentType = strings.ToLower(strings.TrimSpace(strings.SplitN(contentType, ";", 2)[0]))
+
+	for _, rule := range strings.Split(accept, ",") {
+		rule = strings.ToLower(strings.TrimSpace(rule))
+		switch {
+		case rule == "":
+		case strings.HasPrefix(rule, "."):
+			if ext == rule {
+				return true
+			}
+		case strings.HasSuffix(rule, "/*"):
+			if strings.HasPrefix(contentType, strings.TrimSuffix(rule, "*")) {
+				return true
+			}
+		case rule == contentType:
+			return true
+		}
+	}
+	return false
+}
+
+// isEmpty 未填写：nil、空白字符串、空数组、空对象
+func isEmpty(val interface{}) bool {
+	switch x := val.(type) {
+	case nil:
+		return true
+	case string:
+		return strings.TrimSpace(x) == ""
+	case []interface{}:
+		return len(x) == 0
+	case map[string]interface{}:
+		return len(x) == 0
+	}
+	return false
+}
+
+func asNumber(val interface{}) (float64, bool) {
+	switch x := val.(type) {
+	case float64:
+		return x, true
+	case float32:
+		return float64(x), true
+	case int:
+		return float64(x), true
+	case int64:
+		return float64(x), true
+	case json.Number:
+		n, err := x.Float64()
+		return n, err == nil
+	case string:
+		n, err := strconv.ParseFloat(strings.TrimSpace(x), 64)
+		return n, err == nil
+	}
+	return 0, false
+}
+
+func asDate(val interface{}) (time.Time, bool) {
+	s, ok := val.(string)
+	if !ok {
+		return time.Time{}, false
+	}
+	for _, layout := range dateLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// scalarString 标量转字符串（数字不带多余小数位）
+func scalarString(val interface{}) string {
+	switch x := val.(type) {
+	case nil:
+		return ""
+	case string:
+		return x
+	case float64:
+		return formatNumber(x)
+	}
+	return fmt.Sprint(val)
+}
+
+func formatNumber(n float64) string {
+	return strconv.FormatFloat(n, 'f', -1, 64)
+}
+
+func formatSize(n int64) string {
+	switch {
+	case n >= 1<<20:
+		return formatNumber(float64(n)/(1<<20)) + "MB"
+	case n >= 1<<10:
+		return formatNumber(float64(n)/(1<<10)) + "KB"
+	}
+	return strconv.FormatInt(n, 10) + "B"
+}
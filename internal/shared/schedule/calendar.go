@@ -0,0 +1,219 @@
+package schedule
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// =============================================================================
+// 工作日历 & 工作日坐标轴
+// =============================================================================
+
+// Calendar 工作日历
+type Calendar interface {
+	// IsWorkday 判断某天是否为工作日
+	IsWorkday(day time.Time) bool
+}
+
+// WeekdayCalendar 默认工作日历：周一至周五为工作日
+type WeekdayCalendar struct{}
+
+// IsWorkday 实现 Calendar
+func (WeekdayCalendar) IsWorkday(day time.Time) bool {
+	wd := day.Weekday()
+	return wd != time.Saturday && wd != time.Sunday
+}
+
+// EveryDayCalendar 每天都是工作日（按自然日排期）
+type EveryDayCalendar struct{}
+
+// IsWorkday 实现 Calendar
+func (EveryDayCalendar) IsWorkday(time.Time) bool {
+	return true
+}
+
+// WorkWeek 按星期定义的工作日，下标为 time.Weekday（0 为周日）
+type WorkWeek [7]bool
+
+// ParseWorkWeek 解析工作周，如 "12345" 表示周一至周五，"123456" 含周六，0 为周日
+func ParseWorkWeek(s string) (WorkWeek, error) {
+	var w WorkWeek
+	for _, ch := range s {
+		if ch < '0' || ch > '6' {
+			return w, fmt.Errorf("工作周格式错误: %s（应为 0-6 的数字，0 为周日）", s)
+		}
+		w[ch-'0'] = true
+	}
+	if w == (WorkWeek{}) {
+		return w, fmt.Errorf("工作周不能为空")
+	}
+	return w, nil
+}
+
+// IsWorkday 实现 Calendar
+func (w WorkWeek) IsWorkday(day time.Time) bool {
+	return w[day.Weekday()]
+}
+
+// String 返回 ParseWorkWeek 可解析的格式
+func (w WorkWeek) String() string {
+	s := ""
+	for i, on := range w {
+		if on {
+			s += strconv.Itoa(i)
+		}
+	}
+	return s
+}
+
+// OverrideCalendar 在基础日历上按日期覆盖工作日：
+// 法定节假日（工作日→休息）、调休上班（周末→工作）、个人请假或加班
+type OverrideCalendar struct {
+	Base Calendar
+	days map[string]bool // "2006-01-02" → 是否工作日
+}
+
+// NewOverrideCalendar 创建覆盖日历，base 为空时按周一至周五
+func NewOverrideCalendar(base Calendar) *OverrideCalendar {
+	if base == nil {
+		base = WeekdayCalendar{}
+	}
+	return &OverrideCalendar{Base: base, days: make(map[string]bool)}
+}
+
+// Set 设置某天是否为工作日
+func (c *OverrideCalendar) Set(day time.Time, workday bool) {
+	c.days[Day(day).Format("2006-01-02")] = workday
+}
+
+// IsWorkday 实现 Calendar：有覆盖时以覆盖为准，否则按基础日历
+func (c *OverrideCalendar) IsWorkday(day time.Time) bool {
+	if workday, ok := c.days[Day(day).Format("2006-01-02")]; ok {
+		return workday
+	}
+	return c.Base.IsWorkday(day)
+}
+
+// maxNonWorkdays 连续非工作日上限，防止日历配置错误（全年无工作日）导致死循环
+const maxNonWorkdays = 366
+
+// Day 取 t 在其所在时区的日期，返回该日期的 UTC 零点
+// 数据库 date 列读出为 UTC 零点，time.Now() 为本地时间，统一后日期比较与时区无关
+func Day(t time.Time) time.Time {
+	y, m, d := t.Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+}
+
+// NextWorkday 返回 day 当天（若为工作日）或其后第一个工作日
+func NextWorkday(cal Calendar, day time.Time) time.Time {
+	day = Day(day)
+	for i := 0; i < maxNonWorkdays && !cal.IsWorkday(day); i++ {
+		day = day.AddDate(0, 0, 1)
+	}
+	return day
+}
+
+// WorkdaysBetween 闭区间 [from, to] 内的工作日数（to 早于 from 时为 0）
+func WorkdaysBetween(cal Calendar, from, to time.Time) int {
+	from, to = Day(from), Day(to)
+	n := 0
+	for d := from; !d.After(to); d = d.AddDate(0, 0, 1) {
+		if cal.IsWorkday(d) {
+			n++
+		}
+	}
+	return n
+}
+
+// Timeline 工作日坐标轴：以起始日（或其后第一个工作日）为第 0 个工作日
+// CPM 在整数工作日偏移上计算，Timeline 负责偏移与日期的互相换算
+type Timeline struct {
+	cal  Calendar
+	days []time.Time // days[i] 为第 i 个工作日，按需向后扩展
+}
+
+// NewTimeline 创建工作日坐标轴
+func NewTimeline(cal Calendar, start time.Time) *Timeline {
+	if cal == nil {
+		cal = WeekdayCalendar{}
+	}
+	return &Timeline{cal: cal, days: []time.Time{NextWorkday(cal, start)}}
+}
+
+// Origin 第 0 个工作日
+func (t *Timeline) Origin() time.Time {
+	return t.days[0]
+}
+
+// Date 第 offset 个工作日的日期（offset 可为负）
+func (t *Timeline) Date(offset int) time.Time {
+	if offset < 0 {
+		d := t.days[0]
+		for n := 0; n < -offset; {
+			d = d.AddDate(0, 0, -1)
+			if t.cal.IsWorkday(d) {
+				n++
+			}
+		}
+		return d
+	}
+	t.extend(func() bool { return len(t.days) > offset })
+	return t.days[offset]
+}
+
+// Offset 日期所在工作日的偏移；非工作日按其后第一个工作日计
+func (t *Timeline) Offset(day time.Time) int {
+	day = NextWorkday(t.cal, day)
+	origin := t.days[0]
+	if day.Before(origin) {
+		n := 0
+		for d := day; d.Before(origin); d = d.AddDate(0, 0, 1) {
+			if t.cal.IsWorkday(d) {
+				n++
+			}
+		}
+		return -n
+	}
+	t.extend(func() bool { return !t.days[len(t.days)-1].Before(day) })
+	lo, hi := 0, len(t.days)-1
+	for lo < hi {
+		mid := (lo + hi) / 2
+		if t.days[mid].Before(day) {
+			lo = mid + 1
+		} else {
+			hi = mid
+		}
+	}
+	return lo
+}
+
+// extend 向后追加工作日直到 done 返回 true
+func (t *Timeline) extend(done func() bool) {
+	for !done() {
+		next := NextWorkday(t.cal, t.days[len(t.days)-1].AddDate(0, 0, 1))
+		t.days = append(t.days, next)
+	}
+}
+
+// WorkdayDiff from 到 to 相差的工作日数（to 较晚为正，较早为负）
+// 非工作日按其后第一个工作日计
+func WorkdayDiff(cal Calendar, from, to time.Time) int {
+	from, to = NextWorkday(cal, from), NextWorkday(cal, to)
+	if to.Before(from) {
+		return -WorkdayDiff(cal, to, from)
+	}
+	return WorkdaysBetween(cal, from, to) - 1
+}
+
+// AddWorkdays 从 start 起顺延 days 个工作日（保留时刻），days<=0 时返回 start
+func AddWorkdays(cal Calendar, start time.Time, days int) time.Time {
+	t := start
+	for i := 0; i < days; i++ {
+		t = t.AddDate(0, 0, 1)
+		for n := 0; n < maxNonWorkdays && !cal.IsWorkday(t); n++ {
+			t = t.AddDate(0, 0, 1)
+		}
+	}
+	return t
+}
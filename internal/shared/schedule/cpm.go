@@ -0,0 +1,314 @@
+package schedule
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// =============================================================================
+// 关键路径法（CPM）— 正推最早时间、逆推最晚时间，计算总时差/自由时差和关键路径
+// 时间均为工作日偏移，区间左闭右开：活动占用 [ES, EF)，EF = ES + Duration
+// =============================================================================
+
+// LinkType 依赖类型
+type LinkType string
+
+const (
+	FinishToStart  LinkType = "FS" // 前置完成后才能开始
+	StartToStart   LinkType = "SS" // 前置开始后才能开始
+	FinishToFinish LinkType = "FF" // 前置完成后才能完成
+	StartToFinish  LinkType = "SF" // 前置开始后才能完成
+)
+
+// ParseLinkType 解析依赖类型，兼容 FS/SS/FF/SF 及 finish_to_start 等写法；空值按 FS
+func ParseLinkType(s string) (LinkType, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "", "fs", "finish_to_start":
+		return FinishToStart, nil
+	case "ss", "start_to_start":
+		return StartToStart, nil
+	case "ff", "finish_to_finish":
+		return FinishToFinish, nil
+	case "sf", "start_to_finish":
+		return StartToFinish, nil
+	}
+	return "", fmt.Errorf("未知的依赖类型: %s", s)
+}
+
+// Activity 参与排程的活动
+type Activity struct {
+	ID        string
+	Duration  int  // 工期（工作日），里程碑为 0
+	NotBefore int  // 最早可开始偏移（不早于约束）
+	Fixed     bool // 开始时间已确定（已开始/已完成），ES 取 NotBefore，不受前置约束
+	Completed bool // 已完成，不计入关键路径
+}
+
+// Link 活动间依赖：To 依赖 From
+type Link struct {
+	From string
+	To   string
+	Type LinkType
+	Lag  int // 延隔（工作日），可为负表示提前
+}
+
+// ActivityResult 单个活动的排程结果
+type ActivityResult struct {
+	ID          string `json:"id"`
+	Duration    int    `json:"duration"`
+	EarlyStart  int    `json:"early_start"`
+	EarlyFinish int    `json:"early_finish"`
+	LateStart   int    `json:"late_start"`
+	LateFinish  int    `json:"late_finish"`
+	TotalFloat  int    `json:"total_float"`
+	FreeFloat   int    `json:"free_float"`
+	Critical    bool   `json:"critical"`
+}
+
+// Result 排程结果
+type Result struct {
+	Activities   []ActivityResult `json:"activities"`    // 按拓扑顺序
+	Finish       int              `json:"finish"`        // 项目完工偏移（最大 EF）
+	CriticalPath []string         `json:"critical_path"` // 关键活动，按最早开始排序
+	index        map[string]int
+}
+
+// Get 按 ID 获取活动结果
+func (r *Result) Get(id string) (*ActivityResult, bool) {
+	i, ok := r.index[id]
+	if !ok {
+		return nil, false
+	}
+	return &r.Activities[i], true
+}
+
+// CycleError 依赖成环
+type CycleError struct {
+	Path []string // 环上的活动，首尾相同
+}
+
+func (e *CycleError) Error() string {
+	return "依赖存在循环: " + strings.Join(e.Path, " → ")
+}
+
+// Compute 计算 CPM 排程
+// 活动 ID 必须唯一；引用未知活动的依赖返回错误，依赖成环返回 *CycleError
+func Compute(activities []Activity, links []Link) (*Result, error) {
+	index := make(map[string]int, len(activities))
+	for i, a := range activities {
+		if _, dup := index[a.ID]; dup {
+			return nil, fmt.Errorf("活动 ID 重复: %s", a.ID)
+		}
+		if a.Duration < 0 {
+			return nil, fmt.Errorf("活动 %s 工期不能为负", a.ID)
+		}
+		index[a.ID] = i
+	}
+
+	incoming := make([][]Link, len(activities))
+	outgoing := make([][]Link, len(activities))
+	for _, l := range links {
+		from, ok1 := index[l.From]
+		to, ok2 := index[l.To]
+		if !ok1 || !ok2 {
+			return nil, fmt.Errorf("依赖引用了未知活动: %s → %s", l.From, l.To)
+		}
+		if from == to {
+			return nil, &CycleError{Path: []string{l.From, l.To}}
+		}
+		if l.Type == "" {
+			l.Type = FinishToStart
+		}
+		incoming[to] = append(incoming[to], l)
+		outgoing[from] = append(outgoing[from], l)
+	}
+
+	order, err := topoOrder(activities, index, outgoing, incoming)
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]ActivityResult, len(activities))
+	for i, a := range activities {
+		results[i] = ActivityResult{ID: a.ID, Duration: a.Duration}
+	}
+
+	// 正推：最早开始 / 最早完成
+	finish := 0
+	for n, i := range order {
+		a := activities[i]
+		es := a.NotBefore
+		if !a.Fixed {
+			for _, l := range incoming[i] {
+				if b := startBound(l, &results[index[l.From]], a.Duration); b > es {
+					es = b
+				}
+			}
+		}
+		results[i].EarlyStart = es
+		results[i].EarlyFinish = es + a.Duration
+		if n == 0 || results[i].EarlyFinish > finish {
+			finish = results[i].EarlyFinish
+		}
+	}
+
+	// 逆推：最晚完成 / 最晚开始
+	for n := len(order) - 1; n >= 0; n-- {
+		i := order[n]
+		lf := finish
+		for _, l := range outgoing[i] {
+			if b := finishBound(l, &results[index[l.To]], activities[i].Duration); b < lf {
+				lf = b
+			}
+		}
+		results[i].LateFinish = lf
+		results[i].LateStart = lf - activities[i].Duration
+		results[i].TotalFloat = results[i].LateStart - results[i].EarlyStart
+		results[i].Critical = !activities[i].Completed && results[i].TotalFloat <= 0
+	}
+
+	// 自由时差：不推迟任何后续活动最早开始的机动时间
+	for i := range results {
+		ff := finish - results[i].EarlyFinish
+		for _, l := range outgoing[i] {
+			succ := &results[index[l.To]]
+			if slack := succ.EarlyStart - startBound(l, &results[i], succ.Duration); slack < ff {
+				ff = slack
+			}
+		}
+		if ff < 0 {
+			ff = 0
+		}
+		results[i].FreeFloat = ff
+	}
+
+	sorted := make([]ActivityResult, len(order))
+	res := &Result{Finish: finish, CriticalPath: []string{}, index: make(map[string]int, len(order))}
+	for n, i := range order {
+		sorted[n] = results[i]
+		res.index[results[i].ID] = n
+	}
+	res.Activities = sorted
+
+	critical := make([]ActivityResult, 0)
+	for _, r := range sorted {
+		if r.Critical {
+			critical = append(critical, r)
+		}
+	}
+	sort.SliceStable(critical, func(a, b int) bool { return critical[a].EarlyStart < critical[b].EarlyStart })
+	for _, r := range critical {
+		res.CriticalPath = append(res.CriticalPath, r.ID)
+	}
+	return res, nil
+}
+
+// startBound 前置活动 p 经依赖 l 对后续活动（工期 d）最早开始的约束
+func startBound(l Link, p *ActivityResult, d int) int {
+	switch l.Type {
+	case StartToStart:
+		return p.EarlyStart + l.Lag
+	case FinishToFinish:
+		return p.EarlyFinish + l.Lag - d
+	case StartToFinish:
+		return p.EarlyStart + l.Lag - d
+	default:
+		return p.EarlyFinish + l.Lag
+	}
+}
+
+// finishBound 后续活动 s 经依赖 l 对前置活动（工期 d）最晚完成的约束
+func finishBound(l Link, s *ActivityResult, d int) int {
+	switch l.Type {
+	case StartToStart:
+		return s.LateStart - l.Lag + d
+	case FinishToFinish:
+		return s.LateFinish - l.Lag
+	case StartToFinish:
+		return s.LateFinish - l.Lag + d
+	default:
+		return s.LateStart - l.Lag
+	}
+}
+
+// topoOrder 拓扑排序（Kahn），同层按输入顺序，保证结果稳定
+func topoOrder(activities []Activity, index map[string]int, outgoing, incoming [][]Link) ([]int, error) {
+	indegree := make([]int, len(activities))
+	for i := range activities {
+		indegree[i] = len(incoming[i])
+	}
+	ready := make([]int, 0, len(activities))
+	for i := range activities {
+		if indegree[i] == 0 {
+			ready = append(ready, i)
+		}
+	}
+
+	order := make([]int, 0, len(activities))
+	for len(ready) > 0 {
+		sort.Ints(ready)
+		i := ready[0]
+		ready = ready[1:]
+		order = append(order, i)
+		for _, l := range outgoing[i] {
+			j := index[l.To]
+			indegree[j]--
+			if indegree[j] == 0 {
+				ready = append(ready, j)
+			}
+		}
+	}
+	if len(order) == len(activities) {
+		return order, nil
+	}
+	return nil, &CycleError{Path: findCycle(activities, index, outgoing, indegree)}
+}
+
+// findCycle 在拓扑排序剩余的活动中找出一个环
+func findCycle(activities []Activity, index map[string]int, outgoing [][]Link, indegree []int) []string {
+	const (
+		unvisited = iota
+		visiting
+		done
+	)
+	state := make([]int, len(activities))
+	stack := make([]int, 0)
+	var cycle []string
+
+	var visit func(i int) bool
+	visit = func(i int) bool {
+		state[i] = visiting
+		stack = append(stack, i)
+		for _, l := range outgoing[i] {
+			j := index[l.To]
+			if indegree[j] == 0 {
+				continue
+			}
+			if state[j] == visiting {
+				for k := len(stack) - 1; k >= 0; k-- {
+					if stack[k] == j {
+						for _, m := range stack[k:] {
+							cycle = append(cycle, activities[m].ID)
+						}
+						cycle = append(cycle, activities[j].ID)
+						return true
+					}
+				}
+			}
+			if state[j] == unvisited && visit(j) {
+				return true
+			}
+		}
+		stack = stack[:len(stack)-1]
+		state[i] = done
+		return false
+	}
+
+	for i := range activities {
+		if indegree[i] > 0 && state[i] == unvisited && visit(i) {
+			break
+		}
+	}
+	return cycle
+}
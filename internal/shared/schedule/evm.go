@@ -0,0 +1,64 @@
+package schedule
+
+import "time"
+
+// =============================================================================
+// 挣值管理（EVM）— 计划值 PV、挣值 EV、实际成本 AC 及派生指标
+// 度量单位由调用方决定（PLM 以工时计）
+// =============================================================================
+
+// EarnedValue 挣值指标
+type EarnedValue struct {
+	BAC float64 `json:"bac"` // 完工预算
+	PV  float64 `json:"pv"`  // 计划值：截至状态日按基线应完成的工作量
+	EV  float64 `json:"ev"`  // 挣值：按实际进度已完成的工作量
+	AC  float64 `json:"ac"`  // 实际成本：已投入的工作量
+	SV  float64 `json:"sv"`  // 进度偏差 EV-PV
+	CV  float64 `json:"cv"`  // 成本偏差 EV-AC
+	SPI float64 `json:"spi"` // 进度绩效指数 EV/PV，PV 为 0 时为 0
+	CPI float64 `json:"cpi"` // 成本绩效指数 EV/AC，AC 为 0 时为 0
+	EAC float64 `json:"eac"` // 完工估算
+	ETC float64 `json:"etc"` // 完工尚需估算 EAC-AC
+	VAC float64 `json:"vac"` // 完工偏差 BAC-EAC
+}
+
+// NewEarnedValue 由 BAC/PV/EV/AC 计算派生指标
+// 有成本绩效时 EAC = BAC/CPI，否则按剩余工作按预算完成估算 EAC = AC + (BAC-EV)
+func NewEarnedValue(bac, pv, ev, ac float64) EarnedValue {
+	e := EarnedValue{BAC: bac, PV: pv, EV: ev, AC: ac, SV: ev - pv, CV: ev - ac}
+	if pv > 0 {
+		e.SPI = ev / pv
+	}
+	if ac > 0 {
+		e.CPI = ev / ac
+	}
+	if e.CPI > 0 {
+		e.EAC = bac / e.CPI
+	} else {
+		e.EAC = ac + (bac - ev)
+	}
+	e.ETC = e.EAC - ac
+	e.VAC = bac - e.EAC
+	return e
+}
+
+// Add 汇总两组指标并重新计算派生指标
+func (e EarnedValue) Add(o EarnedValue) EarnedValue {
+	return NewEarnedValue(e.BAC+o.BAC, e.PV+o.PV, e.EV+o.EV, e.AC+o.AC)
+}
+
+// PlannedPercent 截至状态日（含当天）计划区间 [start, end] 应完成的比例，按工作日线性分配
+func PlannedPercent(cal Calendar, start, end, statusDate time.Time) float64 {
+	start, end, statusDate = Day(start), Day(end), Day(statusDate)
+	if statusDate.Before(start) {
+		return 0
+	}
+	if !statusDate.Before(end) {
+		return 1
+	}
+	total := WorkdaysBetween(cal, start, end)
+	if total == 0 {
+		return 1
+	}
+	return float64(WorkdaysBetween(cal, start, statusDate)) / float64(total)
+}
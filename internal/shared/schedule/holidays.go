@@ -0,0 +1,197 @@
+package schedule
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+)
+
+// =============================================================================
+// 节假日导入 — ICS 订阅日历 / JSON
+// =============================================================================
+
+// maxHolidaySpan 单个节假日条目的最长天数，防止错误数据展开成整年
+const maxHolidaySpan = 60
+
+// HolidayEntry 节假日或调休条目
+type HolidayEntry struct {
+	Date      time.Time `json:"date"`
+	Name      string    `json:"name"`
+	IsWorkday bool      `json:"is_workday"` // true 为调休上班，false 为放假
+}
+
+// ParseICS 解析 ICS 节假日日历
+// 每个 VEVENT 展开为 [DTSTART, DTEND) 内的每一天；全天事件缺少 DTEND 时按一天计。
+// 国内节假日订阅源通常用标题中的「班」标记调休上班（如「春节调休（班）」「补班」），据此识别工作日
+func ParseICS(r io.Reader) ([]HolidayEntry, error) {
+	lines, err := unfoldICS(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []HolidayEntry
+	var inEvent bool
+	var start, end *time.Time
+	var summary string
+	for _, line := range lines {
+		name, value := splitICSLine(line)
+		switch {
+		case line == "BEGIN:VEVENT":
+			inEvent, start, end, summary = true, nil, nil, ""
+		case line == "END:VEVENT":
+			if !inEvent {
+				continue
+			}
+			inEvent = false
+			if start == nil {
+				return nil, fmt.Errorf("ICS 事件「%s」缺少 DTSTART", summary)
+			}
+			last := *start
+			if end != nil && end.After(*start) {
+				last = end.AddDate(0, 0, -1)
+			}
+			if last.Sub(*start) > maxHolidaySpan*24*time.Hour {
+				return nil, fmt.Errorf("ICS 事件「%s」跨度超过 %d 天", summary, maxHolidaySpan)
+			}
+			workday := strings.Contains(summary, "班")
+			for d := *start; !d.After(last); d = d.AddDate(0, 0, 1) {
+				entries = append(entries, HolidayEntry{Date: d, Name: summary, IsWorkday: workday})
+			}
+		case !inEvent:
+		case name == "DTSTART" || name == "DTEND":
+			d, err := parseICSDate(value)
+			if err != nil {
+				return nil, err
+			}
+			if name == "DTSTART" {
+				start = &d
+			} else {
+				end = &d
+			}
+		case name == "SUMMARY":
+			summary = strings.TrimSpace(strings.NewReplacer(`\,`, ",", `\;`, ";", `\n`, " ").Replace(value))
+		}
+	}
+	return sortHolidays(entries), nil
+}
+
+// unfoldICS 读取 ICS 行并合并折行（以空格或制表符开头的行续接上一行）
+func unfoldICS(r io.Reader) ([]string, error) {
+	var lines []string
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if (strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t")) && len(lines) > 0 {
+			lines[len(lines)-1] += line[1:]
+			continue
+		}
+		lines = append(lines, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("读取 ICS 失败: %w", err)
+	}
+	return lines, nil
+}
+
+// splitICSLine 拆分属性名和值，去掉属性参数（如 DTSTART;VALUE=DATE:20261001）
+func splitICSLine(line string) (string, string) {
+	i := strings.Index(line, ":")
+	if i < 0 {
+		return line, ""
+	}
+	name := line[:i]
+	if j := strings.Index(name, ";"); j >= 0 {
+		name = name[:j]
+	}
+	return strings.ToUpper(name), line[i+1:]
+}
+
+// parseICSDate 取 ICS 日期/日期时间的日期部分
+func parseICSDate(value string) (time.Time, error) {
+	value = strings.TrimSpace(value)
+	if len(value) < 8 {
+		return time.Time{}, fmt.Errorf("ICS 日期格式错误: %s", value)
+	}
+	d, err := time.Parse("20060102", value[:8])
+	if err != nil {
+		return time.Time{}, fmt.Errorf("ICS 日期格式错误: %s", value)
+	}
+	return d, nil
+}
+
+// holidayJSONItem JSON 条目：单日（date）或区间（start/end，含首尾）
+// 兼容 is_workday 和 holiday-cn 数据的 isOffDay 两种写法，均缺省时按放假
+type holidayJSONItem struct {
+	Date      string `json:"date"`
+	Start     string `json:"start"`
+	End       string `json:"end"`
+	Name      string `json:"name"`
+	IsWorkday *bool  `json:"is_workday"`
+	IsOffDay  *bool  `json:"isOffDay"`
+}
+
+// ParseHolidayJSON 解析 JSON 节假日，支持数组或 {"days": [...]} 两种结构
+func ParseHolidayJSON(data []byte) ([]HolidayEntry, error) {
+	var items []holidayJSONItem
+	if err := json.Unmarshal(data, &items); err != nil {
+		var wrapped struct {
+			Days []holidayJSONItem `json:"days"`
+		}
+		if err2 := json.Unmarshal(data, &wrapped); err2 != nil {
+			return nil, fmt.Errorf("JSON 格式错误: %w", err)
+		}
+		items = wrapped.Days
+	}
+
+	var entries []HolidayEntry
+	for _, item := range items {
+		workday := false
+		if item.IsWorkday != nil {
+			workday = *item.IsWorkday
+		} else if item.IsOffDay != nil {
+			workday = !*item.IsOffDay
+		}
+
+		from, to := item.Date, item.Date
+		if from == "" {
+			from, to = item.Start, item.End
+			if to == "" {
+				to = from
+			}
+		}
+		start, err := time.Parse("2006-01-02", from)
+		if err != nil {
+			return nil, fmt.Errorf("节假日「%s」日期格式错误: %s", item.Name, from)
+		}
+		end, err := time.Parse("2006-01-02", to)
+		if err != nil {
+			return nil, fmt.Errorf("节假日「%s」日期格式错误: %s", item.Name, to)
+		}
+		if end.Before(start) || end.Sub(start) > maxHolidaySpan*24*time.Hour {
+			return nil, fmt.Errorf("节假日「%s」日期区间无效: %s ~ %s", item.Name, from, to)
+		}
+		for d := start; !d.After(end); d = d.AddDate(0, 0, 1) {
+			entries = append(entries, HolidayEntry{Date: d, Name: item.Name, IsWorkday: workday})
+		}
+	}
+	return sortHolidays(entries), nil
+}
+
+// sortHolidays 按日期排序，同一天以后出现的条目为准
+func sortHolidays(entries []HolidayEntry) []HolidayEntry {
+	byDate := make(map[time.Time]HolidayEntry, len(entries))
+	for _, e := range entries {
+		byDate[e.Date] = e
+	}
+	result := make([]HolidayEntry, 0, len(byDate))
+	for _, e := range byDate {
+		result = append(result, e)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Date.Before(result[j].Date) })
+	return result
+}
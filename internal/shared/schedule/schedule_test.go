@@ -0,0 +1,227 @@
+package schedule
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func result(t *testing.T, r *Result, id string) *ActivityResult {
+	t.Helper()
+	a, ok := r.Get(id)
+	if !ok {
+		t.Fatalf("activity %s missing", id)
+	}
+	return a
+}
+
+func TestComputeCriticalPathAndFloat(t *testing.T) {
+	//   A(3) ─FS─▶ B(2) ─FS─▶ D(1)
+	//   A(3) ─FS─▶ C(1) ─FS─▶ D(1)
+	r, err := Compute(
+		[]Activity{{ID: "A", Duration: 3}, {ID: "B", Duration: 2}, {ID: "C", Duration: 1}, {ID: "D", Duration: 1}},
+		[]Link{{From: "A", To: "B"}, {From: "A", To: "C"}, {From: "B", To: "D"}, {From: "C", To: "D"}},
+	)
+	assert.NoError(t, err)
+	assert.Equal(t, 6, r.Finish)
+	assert.Equal(t, []string{"A", "B", "D"}, r.CriticalPath)
+
+	c := result(t, r, "C")
+	assert.Equal(t, 3, c.EarlyStart)
+	assert.Equal(t, 4, c.LateStart)
+	assert.Equal(t, 1, c.TotalFloat)
+	assert.Equal(t, 1, c.FreeFloat)
+	assert.False(t, c.Critical)
+}
+
+func TestComputeDependencyTypesAndLag(t *testing.T) {
+	r, err := Compute(
+		[]Activity{
+			{ID: "P", Duration: 4},
+			{ID: "ss", Duration: 2},
+			{ID: "ff", Duration: 2},
+			{ID: "sf", Duration: 3},
+			{ID: "fs", Duration: 1},
+		},
+		[]Link{
+			{From: "P", To: "ss", Type: StartToStart, Lag: 1},
+			{From: "P", To: "ff", Type: FinishToFinish, Lag: 1},
+			{From: "P", To: "sf", Type: StartToFinish, Lag: 5},
+			{From: "P", To: "fs", Type: FinishToStart, Lag: -1},
+		},
+	)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, result(t, r, "ss").EarlyStart)  // ES(P)+1
+	assert.Equal(t, 5, result(t, r, "ff").EarlyFinish) // EF(P)+1
+	assert.Equal(t, 5, result(t, r, "sf").EarlyFinish) // ES(P)+5
+	assert.Equal(t, 3, result(t, r, "fs").EarlyStart)  // EF(P)-1
+
+	// FF 依赖下前置的最晚完成受后续最晚完成约束
+	p := result(t, r, "P")
+	assert.Equal(t, 4, p.LateFinish)
+	assert.True(t, p.Critical)
+}
+
+func TestComputeFixedAndCompletedActivities(t *testing.T) {
+	// A 已完成（提前完工），B 已开始但拖期，C 未开始且不得早于状态日 5
+	r, err := Compute(
+		[]Activity{
+			{ID: "A", Duration: 2, NotBefore: 0, Fixed: true, Completed: true},
+			{ID: "B", Duration: 6, NotBefore: 2, Fixed: true},
+			{ID: "C", Duration: 2, NotBefore: 5},
+		},
+		[]Link{{From: "A", To: "B"}, {From: "B", To: "C"}},
+	)
+	assert.NoError(t, err)
+	assert.Equal(t, 8, result(t, r, "C").EarlyStart)
+	assert.False(t, result(t, r, "A").Critical)
+	assert.Equal(t, []string{"B", "C"}, r.CriticalPath)
+}
+
+func TestComputeDetectsCycle(t *testing.T) {
+	_, err := Compute(
+		[]Activity{{ID: "A", Duration: 1}, {ID: "B", Duration: 1}, {ID: "C", Duration: 1}, {ID: "D", Duration: 1}},
+		[]Link{{From: "D", To: "A"}, {From: "A", To: "B"}, {From: "B", To: "C"}, {From: "C", To: "A"}},
+	)
+	var cycle *CycleError
+	assert.True(t, errors.As(err, &cycle))
+	assert.Equal(t, []string{"A", "B", "C", "A"}, cycle.Path)
+
+	_, err = Compute([]Activity{{ID: "A"}}, []Link{{From: "A", To: "X"}})
+	assert.Error(t, err)
+}
+
+func TestParseLinkType(t *testing.T) {
+	for in, want := range map[string]LinkType{"": FinishToStart, "finish_to_start": FinishToStart, "ss": StartToStart, "FF": FinishToFinish, "start_to_finish": StartToFinish} {
+		got, err := ParseLinkType(in)
+		assert.NoError(t, err)
+		assert.Equal(t, want, got, in)
+	}
+	_, err := ParseLinkType("XX")
+	assert.Error(t, err)
+}
+
+func TestTimelineSkipsWeekends(t *testing.T) {
+	// 2026-03-06 为周五
+	friday := time.Date(2026, 3, 6, 15, 0, 0, 0, time.UTC)
+	tl := NewTimeline(WeekdayCalendar{}, friday)
+
+	assert.Equal(t, time.Date(2026, 3, 6, 0, 0, 0, 0, time.UTC), tl.Origin())
+	assert.Equal(t, time.Date(2026, 3, 9, 0, 0, 0, 0, time.UTC), tl.Date(1))
+	assert.Equal(t, time.Date(2026, 3, 5, 0, 0, 0, 0, time.UTC), tl.Date(-1))
+	assert.Equal(t, 1, tl.Offset(time.Date(2026, 3, 7, 0, 0, 0, 0, time.UTC))) // 周六按下周一
+	assert.Equal(t, 6, tl.Offset(time.Date(2026, 3, 16, 0, 0, 0, 0, time.UTC)))
+	assert.Equal(t, -5, tl.Offset(time.Date(2026, 2, 27, 0, 0, 0, 0, time.UTC)))
+
+	assert.Equal(t, 6, WorkdaysBetween(WeekdayCalendar{}, friday, time.Date(2026, 3, 13, 0, 0, 0, 0, time.UTC)))
+}
+
+func TestWorkdayDiff(t *testing.T) {
+	cal := WeekdayCalendar{}
+	friday := time.Date(2026, 3, 6, 0, 0, 0, 0, time.UTC)
+	monday := time.Date(2026, 3, 9, 0, 0, 0, 0, time.UTC)
+	assert.Equal(t, 1, WorkdayDiff(cal, friday, monday))
+	assert.Equal(t, -1, WorkdayDiff(cal, monday, friday))
+	assert.Equal(t, 0, WorkdayDiff(cal, monday, time.Date(2026, 3, 8, 0, 0, 0, 0, time.UTC))) // 周日按周一
+}
+
+func TestEarnedValue(t *testing.T) {
+	// 2026-03-09 周一 ~ 03-13 周五，共 5 个工作日；状态日周二已过 2 天
+	cal := WeekdayCalendar{}
+	start := time.Date(2026, 3, 9, 0, 0, 0, 0, time.UTC)
+	end := time.Date(2026, 3, 13, 0, 0, 0, 0, time.UTC)
+	assert.Equal(t, 0.4, PlannedPercent(cal, start, end, time.Date(2026, 3, 10, 18, 0, 0, 0, time.UTC)))
+	assert.Equal(t, 0.0, PlannedPercent(cal, start, end, time.Date(2026, 3, 6, 0, 0, 0, 0, time.UTC)))
+	assert.Equal(t, 1.0, PlannedPercent(cal, start, end, end))
+
+	e := NewEarnedValue(40, 16, 12, 20)
+	assert.Equal(t, -4.0, e.SV)
+	assert.Equal(t, -8.0, e.CV)
+	assert.Equal(t, 0.75, e.SPI)
+	assert.Equal(t, 0.6, e.CPI)
+	assert.InDelta(t, 66.67, e.EAC, 0.01)
+	assert.InDelta(t, 46.67, e.ETC, 0.01)
+
+	// 尚无实际投入：按预算估算完工
+	none := NewEarnedValue(8, 0, 0, 0)
+	assert.Equal(t, 0.0, none.SPI)
+	assert.Equal(t, 8.0, none.EAC)
+
+	sum := e.Add(none)
+	assert.Equal(t, 48.0, sum.BAC)
+	assert.Equal(t, 0.75, sum.SPI)
+}
+
+func TestHolidayCalendar(t *testing.T) {
+	week, err := ParseWorkWeek("12345")
+	assert.NoError(t, err)
+	assert.Equal(t, "12345", week.String())
+	_, err = ParseWorkWeek("17")
+	assert.Error(t, err)
+
+	// 2026-10-01(周四)~10-07 国庆放假，10-10(周六) 调休上班
+	cal := NewOverrideCalendar(week)
+	for d := time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC); d.Day() <= 7; d = d.AddDate(0, 0, 1) {
+		cal.Set(d, false)
+	}
+	cal.Set(time.Date(2026, 10, 10, 0, 0, 0, 0, time.UTC), true)
+
+	assert.False(t, cal.IsWorkday(time.Date(2026, 10, 2, 9, 0, 0, 0, time.Local)))
+	assert.True(t, cal.IsWorkday(time.Date(2026, 10, 10, 0, 0, 0, 0, time.UTC)))
+	assert.True(t, cal.IsWorkday(time.Date(2026, 10, 9, 0, 0, 0, 0, time.UTC)))
+
+	// 9-30(周三) 起顺延 3 个工作日：10-08、10-09、10-10
+	assert.Equal(t, time.Date(2026, 10, 10, 0, 0, 0, 0, time.UTC), AddWorkdays(cal, time.Date(2026, 9, 30, 0, 0, 0, 0, time.UTC), 3))
+	assert.Equal(t, time.Date(2026, 10, 3, 0, 0, 0, 0, time.UTC), AddWorkdays(EveryDayCalendar{}, time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC), 2))
+}
+
+func TestParseICS(t *testing.T) {
+	ics := "BEGIN:VCALENDAR\r\n" +
+		"BEGIN:VEVENT\r\nDTSTART;VALUE=DATE:20261001\r\nDTEND;VALUE=DATE:20261004\r\nSUMMARY:国庆\r\n 节\r\nEND:VEVENT\r\n" +
+		"BEGIN:VEVENT\r\nDTSTART;VALUE=DATE:20261010\r\nSUMMARY:国庆节调休（班）\r\nEND:VEVENT\r\n" +
+		"END:VCALENDAR\r\n"
+	entries, err := ParseICS(strings.NewReader(ics))
+	assert.NoError(t, err)
+	assert.Len(t, entries, 4)
+	assert.Equal(t, "国庆节", entries[0].Name)
+	assert.False(t, entries[2].IsWorkday)
+	assert.Equal(t, time.Date(2026, 10, 3, 0, 0, 0, 0, time.UTC), entries[2].Date)
+	assert.True(t, entries[3].IsWorkday)
+}
+
+func TestParseHolidayJSON(t *testing.T) {
+	entries, err := ParseHolidayJSON([]byte(`[
+		{"start": "2026-10-01", "end": "2026-10-03", "name": "国庆节"},
+		{"date": "2026-10-10", "name": "国庆节调休", "is_workday": true}
+	]`))
+	assert.NoError(t, err)
+	assert.Len(t, entries, 4)
+	assert.True(t, entries[3].IsWorkday)
+
+	entries, err = ParseHolidayJSON([]byte(`{"days": [{"name": "元旦", "date": "2026-01-01", "isOffDay": true}, {"name": "元旦", "date": "2026-01-04", "isOffDay": false}]}`))
+	assert.NoError(t, err)
+	assert.Len(t, entries, 2)
+	assert.False(t, entries[0].IsWorkday)
+	assert.True(t, entries[1].IsWorkday)
+
+	_, err = ParseHolidayJSON([]byte(`[{"date": "2026/01/01"}]`))
+	assert.Error(t, err)
+}
+
+func TestSpreadHours(t *testing.T) {
+	// 2026-03-06 周五 ~ 03-10 周二：3 个工作日
+	cal := WeekdayCalendar{}
+	spread := SpreadHours(cal, time.Date(2026, 3, 6, 0, 0, 0, 0, time.UTC), time.Date(2026, 3, 10, 0, 0, 0, 0, time.UTC), 12)
+	assert.Len(t, spread, 3)
+	assert.Equal(t, 4.0, spread[time.Date(2026, 3, 9, 0, 0, 0, 0, time.UTC)])
+
+	// 只落在周末：计入下周一
+	spread = SpreadHours(cal, time.Date(2026, 3, 7, 0, 0, 0, 0, time.UTC), time.Date(2026, 3, 8, 0, 0, 0, 0, time.UTC), 6)
+	assert.Equal(t, map[time.Time]float64{time.Date(2026, 3, 9, 0, 0, 0, 0, time.UTC): 6}, spread)
+
+	assert.Equal(t, time.Date(2026, 3, 2, 0, 0, 0, 0, time.UTC), WeekStart(time.Date(2026, 3, 8, 23, 0, 0, 0, time.UTC)))
+	assert.Equal(t, time.Date(2026, 3, 9, 0, 0, 0, 0, time.UTC), WeekStart(time.Date(2026, 3, 9, 0, 0, 0, 0, time.UTC)))
+}
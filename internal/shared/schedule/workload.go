@@ -0,0 +1,39 @@
+package schedule
+
+import "time"
+
+// =============================================================================
+// 资源负荷 — 工时按工作日分摊、按周汇总
+// =============================================================================
+
+// SpreadHours 将工时平均分摊到闭区间 [start, end] 内的工作日，返回 日期(UTC 零点)→工时
+// end 早于 start 时按单日处理；区间内没有工作日时全部计入 start 之后第一个工作日
+func SpreadHours(cal Calendar, start, end time.Time, hours float64) map[time.Time]float64 {
+	start, end = Day(start), Day(end)
+	if end.Before(start) {
+		end = start
+	}
+	spread := make(map[time.Time]float64)
+	if hours <= 0 {
+		return spread
+	}
+	n := WorkdaysBetween(cal, start, end)
+	if n == 0 {
+		spread[NextWorkday(cal, start)] = hours
+		return spread
+	}
+	perDay := hours / float64(n)
+	for d := start; !d.After(end); d = d.AddDate(0, 0, 1) {
+		if cal.IsWorkday(d) {
+			spread[d] = perDay
+		}
+	}
+	return spread
+}
+
+// WeekStart 返回 day 所在周的周一（UTC 零点）
+func WeekStart(day time.Time) time.Time {
+	day = Day(day)
+	offset := (int(day.Weekday()) + 6) % 7
+	return day.AddDate(0, 0, -offset)
+}
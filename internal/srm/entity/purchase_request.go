@@ -122,13 +122,3 @@ const (
 	PRItemStatusCompleted  = "completed"
 )
 
-// ValidPRItemTransitions 合法的PR行项状态流转
-var ValidPRItemTransitions = map[string][]string{
-	PRItemStatusPending:    {PRItemStatusQuoting, PRItemStatusSourcing},
-	PRItemStatusQuoting:    {PRItemStatusSourcing},
-	PRItemStatusSourcing:   {PRItemStatusOrdered},
-	PRItemStatusOrdered:    {PRItemStatusShipped},
-	PRItemStatusShipped:    {PRItemStatusReceived},
-	PRItemStatusReceived:   {PRItemStatusInspecting},
-	PRItemStatusInspecting: {PRItemStatusPassed, PRItemStatusFailed},
-}
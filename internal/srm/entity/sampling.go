@@ -36,11 +36,3 @@ const (
 	SamplingStatusPassed    = "passed"    // 验证通过
 	SamplingStatusFailed    = "failed"    // 验证不通过
 )
-
-// ValidSamplingTransitions 合法的打样状态流转
-var ValidSamplingTransitions = map[string][]string{
-	SamplingStatusPreparing: {SamplingStatusShipping},
-	SamplingStatusShipping:  {SamplingStatusArrived},
-	SamplingStatusArrived:   {SamplingStatusVerifying},
-	SamplingStatusVerifying: {SamplingStatusPassed, SamplingStatusFailed},
-}
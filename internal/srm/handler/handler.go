@@ -31,7 +31,6 @@ func NewHandlers(
 	procurementSvc *service.ProcurementService,
 	inspectionSvc *service.InspectionService,
 	dashboardSvc *service.DashboardService,
-	poRepo POItemReceiver,
 	projectSvc *service.SRMProjectService,
 	settlementSvc *service.SettlementService,
 	correctiveActionSvc *service.CorrectiveActionService,
@@ -44,7 +43,7 @@ func NewHandlers(
 	return &Handlers{
 		Supplier:         NewSupplierHandler(supplierSvc),
 		PR:               NewPRHandler(procurementSvc),
-		PO:               NewPOHandler(procurementSvc, poRepo),
+		PO:               NewPOHandler(procurementSvc),
 		Inspection:       NewInspectionHandler(inspectionSvc),
 		Dashboard:        NewDashboardHandler(dashboardSvc),
 		Project:          NewProjectHandler(projectSvc),
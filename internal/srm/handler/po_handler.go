@@ -1,7 +1,6 @@
 package handler
 
 import (
-	"context"
 	"fmt"
 	"strings"
 
@@ -10,19 +9,13 @@ import (
 	"github.com/xuri/excelize/v2"
 )
 
-// POItemReceiver PO行项收货接口
-type POItemReceiver interface {
-	ReceiveItem(ctx context.Context, itemID string, receivedQty float64) error
-}
-
 // POHandler 采购订单处理器
 type POHandler struct {
-	svc    *service.ProcurementService
-	poRepo POItemReceiver
+	svc *service.ProcurementService
 }
 
-func NewPOHandler(svc *service.ProcurementService, poRepo POItemReceiver) *POHandler {
-	return &POHandler{svc: svc, poRepo: poRepo}
+func NewPOHandler(svc *service.ProcurementService) *POHandler {
+	return &POHandler{svc: svc}
 }
 
 // ListPOs 采购订单列表
@@ -226,7 +219,7 @@ func (h *POHandler) ExportPOs(c *gin.Context) {
 // POST /api/v1/srm/purchase-orders/:id/submit
 func (h *POHandler) SubmitPO(c *gin.Context) {
 	id := c.Param("id")
-	po, err := h.svc.SubmitPO(c.Request.Context(), id)
+	po, err := h.svc.SubmitPO(c.Request.Context(), id, GetUserID(c))
 	if err != nil {
 		BadRequest(c, "提交失败: "+err.Error())
 		return
@@ -234,6 +227,18 @@ func (h *POHandler) SubmitPO(c *gin.Context) {
 	Success(c, po)
 }
 
+// SendPO 发送采购订单给供应商
+// POST /api/v1/srm/purchase-orders/:id/send
+func (h *POHandler) SendPO(c *gin.Context) {
+	id := c.Param("id")
+	po, err := h.svc.SendPO(c.Request.Context(), id, GetUserID(c))
+	if err != nil {
+		BadRequest(c, "发送失败: "+err.Error())
+		return
+	}
+	Success(c, po)
+}
+
 // DeletePO 删除采购订单
 // DELETE /api/v1/srm/purchase-orders/:id
 func (h *POHandler) DeletePO(c *gin.Context) {
@@ -265,6 +270,7 @@ func (h *POHandler) GenerateFromBOM(c *gin.Context) {
 // ReceiveItem PO行项收货
 // POST /api/v1/srm/purchase-orders/:id/items/:itemId/receive
 func (h *POHandler) ReceiveItem(c *gin.Context) {
+	poID := c.Param("id")
 	itemID := c.Param("itemId")
 
 	var req service.ReceiveItemRequest
@@ -278,10 +284,11 @@ func (h *POHandler) ReceiveItem(c *gin.Context) {
 		return
 	}
 
-	if err := h.poRepo.ReceiveItem(c.Request.Context(), itemID, req.ReceivedQty); err != nil {
-		InternalError(c, "收货失败: "+err.Error())
+	po, err := h.svc.ReceiveItem(c.Request.Context(), poID, itemID, req.ReceivedQty, GetUserID(c))
+	if err != nil {
+		BadRequest(c, "收货失败: "+err.Error())
 		return
 	}
 
-	Success(c, nil)
+	Success(c, po)
 }
@@ -1,11 +1,13 @@
 package handler
 
 import (
+	"context"
 	"net/http"
 	"testing"
 	"time"
 
 	"github.com/bitfantasy/nimo/internal/plm/testutil"
+	"github.com/bitfantasy/nimo/internal/shared/engine"
 	"github.com/bitfantasy/nimo/internal/srm/entity"
 	"github.com/bitfantasy/nimo/internal/srm/repository"
 	"github.com/bitfantasy/nimo/internal/srm/service"
@@ -21,6 +23,12 @@ func setupSamplingTest(t *testing.T) (*testutil.TestEnv, *SamplingHandler) {
 		&entity.PRItem{},
 		&entity.SamplingRequest{},
 		&entity.ActivityLog{},
+		&engine.StateMachineDefinition{},
+		&engine.StateTransition{},
+		&engine.EntityState{},
+		&engine.TransitionLog{},
+		&engine.ActionOutbox{},
+		&engine.EntityTimer{},
 	); err != nil {
 		t.Fatalf("Failed to migrate tables: %v", err)
 	}
@@ -30,7 +38,14 @@ func setupSamplingTest(t *testing.T) (*testutil.TestEnv, *SamplingHandler) {
 	supplierRepo := repository.NewSupplierRepository(db)
 	activityLogRepo := repository.NewActivityLogRepository(db)
 
-	svc := service.NewSamplingService(samplingRepo, prRepo, supplierRepo, activityLogRepo, db)
+	eng := engine.NewEngine(db, nil)
+	for _, def := range []*engine.StateMachineDefinition{engine.NewSRMPRItemMachine(), engine.NewSRMSamplingMachine()} {
+		if err := eng.RegisterMachine(def); err != nil {
+			t.Fatalf("Failed to register state machine: %v", err)
+		}
+	}
+
+	svc := service.NewSamplingService(samplingRepo, prRepo, supplierRepo, activityLogRepo, db, eng)
 	handler := NewSamplingHandler(svc)
 
 	router := testutil.SetupRouter()
@@ -244,42 +259,80 @@ func TestSamplingMultipleRounds(t *testing.T) {
 	}
 }
 
-// TestPRItemStatusTransitions tests the PR item status transitions
-func TestPRItemStatusTransitions(t *testing.T) {
-	transitions := entity.ValidPRItemTransitions
-
-	// pending → quoting (发起询价)
-	pendingTargets := transitions["pending"]
-	hasQuoting := false
-	for _, s := range pendingTargets {
-		if s == "quoting" {
-			hasQuoting = true
+// TestGeneratePOsFromMixedStatusPR tests that PO generation only orders items the state machine allows
+func TestGeneratePOsFromMixedStatusPR(t *testing.T) {
+	env, _ := setupSamplingTest(t)
+	if err := env.DB.AutoMigrate(&entity.PurchaseOrder{}, &entity.POItem{}); err != nil {
+		t.Fatalf("Failed to migrate PO tables: %v", err)
+	}
+	pendingID, supplierID := seedSamplingTestData(t, env)
+
+	// 同一PR下另有打样中、已发货的行项，均已分配供应商
+	statuses := map[string]string{
+		pendingID:         entity.PRItemStatusPending,
+		"item-sample-002": entity.PRItemStatusSampling,
+		"item-sample-003": entity.PRItemStatusShipped,
+	}
+	for id, status := range statuses {
+		if id != pendingID {
+			item := &entity.PRItem{
+				ID:           id,
+				PRID:         "pr-sample-001",
+				MaterialName: "测试物料 " + id,
+				Quantity:     10,
+				Unit:         "pcs",
+				Status:       status,
+				CreatedAt:    time.Now(),
+				UpdatedAt:    time.Now(),
+			}
+			if err := env.DB.Create(item).Error; err != nil {
+				t.Fatalf("Failed to seed PR item: %v", err)
+			}
+		}
+		if err := env.DB.Model(&entity.PRItem{}).Where("id = ?", id).Update("supplier_id", supplierID).Error; err != nil {
+			t.Fatalf("Failed to assign supplier: %v", err)
 		}
 	}
-	if !hasQuoting {
-		t.Fatal("expected pending → quoting transition to be valid")
+
+	eng := engine.NewEngine(env.DB, nil)
+	if err := eng.RegisterMachine(engine.NewSRMPRItemMachine()); err != nil {
+		t.Fatalf("Failed to register state machine: %v", err)
+	}
+	svc := service.NewProcurementService(repository.NewPRRepository(env.DB), repository.NewPORepository(env.DB), env.DB, eng)
+
+	pos, err := svc.GeneratePOsFromPR(context.Background(), "pr-sample-001", "test-user")
+	if err != nil {
+		t.Fatalf("expected PO generation to succeed, got %v", err)
+	}
+	if len(pos) != 1 {
+		t.Fatalf("expected 1 PO, got %d", len(pos))
+	}
+	// 只有可下单的行项生成PO行
+	if len(pos[0].Items) != 1 || *pos[0].Items[0].PRItemID != pendingID {
+		t.Fatalf("expected 1 PO item for %s, got %+v", pendingID, pos[0].Items)
 	}
 
-	// sampling should NOT be a separate transition target from pending
-	hasSampling := false
-	for _, s := range pendingTargets {
-		if s == "sampling" {
-			hasSampling = true
-		}
+	// 再次生成时没有可下单的行项，不产生重复的PO行
+	if _, err := svc.GeneratePOsFromPR(context.Background(), "pr-sample-001", "test-user"); err == nil {
+		t.Fatal("expected second PO generation to fail with no orderable items")
 	}
-	if hasSampling {
-		t.Fatal("expected pending → sampling transition to be removed")
+	var poCount, poItemCount int64
+	env.DB.Model(&entity.PurchaseOrder{}).Count(&poCount)
+	env.DB.Model(&entity.POItem{}).Count(&poItemCount)
+	if poCount != 1 || poItemCount != 1 {
+		t.Fatalf("expected 1 PO with 1 item after second call, got %d POs and %d items", poCount, poItemCount)
 	}
 
-	// quoting → sourcing
-	quotingTargets := transitions["quoting"]
-	hasSourcing := false
-	for _, s := range quotingTargets {
-		if s == "sourcing" {
-			hasSourcing = true
-		}
+	want := map[string]string{
+		pendingID:         entity.PRItemStatusOrdered,
+		"item-sample-002": entity.PRItemStatusSampling,
+		"item-sample-003": entity.PRItemStatusShipped,
 	}
-	if !hasSourcing {
-		t.Fatal("expected quoting → sourcing transition to be valid")
+	for id, status := range want {
+		var item entity.PRItem
+		env.DB.Where("id = ?", id).First(&item)
+		if item.Status != status {
+			t.Fatalf("expected PR item %s status '%s', got '%s'", id, status, item.Status)
+		}
 	}
 }
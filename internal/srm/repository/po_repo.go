@@ -94,25 +94,6 @@ func (r *PORepository) Delete(ctx context.Context, id string) error {
 	})
 }
 
-// ReceiveItem 收货（更新行项收货数量和状态）
-func (r *PORepository) ReceiveItem(ctx context.Context, itemID string, receivedQty float64) error {
-	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
-		var item entity.POItem
-		if err := tx.Where("id = ?", itemID).First(&item).Error; err != nil {
-			return err
-		}
-
-		item.ReceivedQty += receivedQty
-		if item.ReceivedQty >= item.Quantity {
-			item.Status = entity.POItemStatusReceived
-		} else if item.ReceivedQty > 0 {
-			item.Status = entity.POItemStatusPartial
-		}
-
-		return tx.Save(&item).Error
-	})
-}
-
 // FindItemByID 查找PO行项
 func (r *PORepository) FindItemByID(ctx context.Context, itemID string) (*entity.POItem, error) {
 	var item entity.POItem
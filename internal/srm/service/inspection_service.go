@@ -19,6 +19,7 @@ type InspectionService struct {
 	repo            *repository.InspectionRepository
 	prRepo          *repository.PRRepository
 	poRepo          *repository.PORepository
+	procurementSvc  *ProcurementService
 	inventorySvc    *InventoryService
 	activityLogRepo *repository.ActivityLogRepository
 	feishuClient    *feishu.FeishuClient
@@ -36,6 +37,11 @@ func (s *InspectionService) SetPORepo(repo *repository.PORepository) {
 	s.poRepo = repo
 }
 
+// SetProcurementService 注入采购服务（检验合格数量经状态机收货）
+func (s *InspectionService) SetProcurementService(svc *ProcurementService) {
+	s.procurementSvc = svc
+}
+
 // SetInventoryService 注入库存服务
 func (s *InspectionService) SetInventoryService(svc *InventoryService) {
 	s.inventorySvc = svc
@@ -168,13 +174,15 @@ func (s *InspectionService) CompleteInspection(ctx context.Context, id, userID s
 	}
 
 	// Update PO received quantities for passed/conditional items
-	if (req.Result == "passed" || req.Result == "conditional") && s.poRepo != nil {
+	if req.Result == "passed" || req.Result == "conditional" {
 		for _, item := range inspection.Items {
 			if item.Result == "failed" {
 				continue
 			}
 			if item.POItemID != nil && item.QualifiedQty > 0 {
-				s.poRepo.ReceiveItem(ctx, *item.POItemID, item.QualifiedQty)
+				if err := s.receivePOItem(ctx, *item.POItemID, item.QualifiedQty, userID); err != nil {
+					return nil, fmt.Errorf("检验结果已保存，收货失败: %w", err)
+				}
 			}
 
 			// Auto stock-in for qualified quantity
@@ -207,6 +215,21 @@ func (s *InspectionService) CompleteInspection(ctx context.Context, id, userID s
 	return inspection, nil
 }
 
+// receivePOItem 合格数量按采购收货处理：经采购服务触发 receive 事件，PO 与 PR 行项状态随之流转
+func (s *InspectionService) receivePOItem(ctx context.Context, poItemID string, qty float64, userID string) error {
+	if s.procurementSvc == nil || s.poRepo == nil {
+		return fmt.Errorf("采购服务未配置")
+	}
+	poItem, err := s.poRepo.FindItemByID(ctx, poItemID)
+	if err != nil {
+		return fmt.Errorf("查找PO行项失败: %w", err)
+	}
+	if _, err := s.procurementSvc.ReceiveItem(ctx, poItem.POID, poItem.ID, qty, userID); err != nil {
+		return err
+	}
+	return nil
+}
+
 // sendInspectionFailedNotification 发送检验不合格飞书通知
 func (s *InspectionService) sendInspectionFailedNotification(ctx context.Context, inspection *entity.Inspection) {
 	if s.feishuClient == nil {
@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 
+	"github.com/bitfantasy/nimo/internal/shared/engine"
 	"github.com/bitfantasy/nimo/internal/srm/entity"
 	"github.com/bitfantasy/nimo/internal/srm/repository"
 	"gorm.io/gorm"
@@ -15,41 +16,35 @@ type PRItemService struct {
 	projectRepo     *repository.ProjectRepository
 	activityLogRepo *repository.ActivityLogRepository
 	db              *gorm.DB
+	engine          *engine.Engine
 }
 
-func NewPRItemService(prRepo *repository.PRRepository, projectRepo *repository.ProjectRepository, activityLogRepo *repository.ActivityLogRepository, db *gorm.DB) *PRItemService {
-	return &PRItemService{prRepo: prRepo, projectRepo: projectRepo, activityLogRepo: activityLogRepo, db: db}
+func NewPRItemService(prRepo *repository.PRRepository, projectRepo *repository.ProjectRepository, activityLogRepo *repository.ActivityLogRepository, db *gorm.DB, eng *engine.Engine) *PRItemService {
+	return &PRItemService{prRepo: prRepo, projectRepo: projectRepo, activityLogRepo: activityLogRepo, db: db, engine: eng}
 }
 
-// UpdatePRItemStatus 更新PR行项状态（带状态流转校验和日志）
+// UpdatePRItemStatus 更新PR行项状态（经 srm_pr_item 状态机校验，记录转换日志和操作日志）
 func (s *PRItemService) UpdatePRItemStatus(ctx context.Context, itemID, toStatus, operatorID string) (*entity.PRItem, error) {
 	item, err := s.prRepo.FindItemByID(ctx, itemID)
 	if err != nil {
 		return nil, fmt.Errorf("物料不存在")
 	}
 
-	// 验证状态流转合法性
-	allowedStatuses, ok := entity.ValidPRItemTransitions[item.Status]
-	if !ok {
-		return nil, fmt.Errorf("当前状态 %s 不允许流转", item.Status)
-	}
-	valid := false
-	for _, s := range allowedStatuses {
-		if s == toStatus {
-			valid = true
-			break
-		}
-	}
-	if !valid {
-		return nil, fmt.Errorf("不允许从 %s 流转到 %s", item.Status, toStatus)
-	}
-
+	// 流转由 srm_pr_item 状态机校验和执行；手动变更带 manual 标记，
+	// 打样、pending/quoting 直接下单等流程驱动的转换在状态机中不可用
 	fromStatus := item.Status
-	item.Status = toStatus
-
-	if err := s.prRepo.UpdateItem(ctx, item); err != nil {
-		return nil, fmt.Errorf("更新状态失败: %w", err)
+	if _, err := runStatusTransition(ctx, s.db, s.engine, statusTransition{
+		EntityType: EntityTypePRItem,
+		ID:         item.ID,
+		Current:    fromStatus,
+		Target:     toStatus,
+		EventData:  map[string]interface{}{"manual": true},
+		Operator:   operatorID,
+		Model:      &entity.PRItem{},
+	}); err != nil {
+		return nil, fmt.Errorf("不允许从 %s 流转到 %s: %w", fromStatus, toStatus, err)
 	}
+	item.Status = toStatus
 
 	// 记录ActivityLog
 	if s.activityLogRepo != nil {
@@ -8,6 +8,7 @@ import (
 	"time"
 
 	plmentity "github.com/bitfantasy/nimo/internal/plm/entity"
+	"github.com/bitfantasy/nimo/internal/shared/engine"
 	"github.com/bitfantasy/nimo/internal/shared/feishu"
 	"github.com/bitfantasy/nimo/internal/srm/entity"
 	"github.com/bitfantasy/nimo/internal/srm/repository"
@@ -38,13 +39,15 @@ type ProcurementService struct {
 	db              *gorm.DB
 	feishuClient    *feishu.FeishuClient
 	activityLogRepo *repository.ActivityLogRepository
+	engine          *engine.Engine // PO / PR行项状态流转
 }
 
-func NewProcurementService(prRepo *repository.PRRepository, poRepo *repository.PORepository, db *gorm.DB) *ProcurementService {
+func NewProcurementService(prRepo *repository.PRRepository, poRepo *repository.PORepository, db *gorm.DB, eng *engine.Engine) *ProcurementService {
 	return &ProcurementService{
 		prRepo: prRepo,
 		poRepo: poRepo,
 		db:     db,
+		engine: eng,
 	}
 }
 
@@ -573,15 +576,32 @@ func (s *ProcurementService) AssignSupplierToItem(ctx context.Context, prID, ite
 		item.ExpectedDate = req.ExpectedDate
 	}
 
-	// 更新状态
+	err = s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(item).Error; err != nil {
+			return fmt.Errorf("更新行项失败: %w", err)
+		}
+		// 待处理的行项分配供应商后进入寻源
+		if item.Status != entity.PRItemStatusPending {
+			return nil
+		}
+		_, err := fireStatus(tx, s.engine, statusTransition{
+			EntityType: EntityTypePRItem,
+			ID:         item.ID,
+			Current:    item.Status,
+			Event:      "source",
+			EventData:  map[string]interface{}{"supplier_id": req.SupplierID},
+			Model:      &entity.PRItem{},
+		})
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	s.engine.NotifyOutbox()
 	if item.Status == entity.PRItemStatusPending {
 		item.Status = entity.PRItemStatusSourcing
 	}
 
-	if err := s.prRepo.UpdateItem(ctx, item); err != nil {
-		return nil, fmt.Errorf("更新行项失败: %w", err)
-	}
-
 	s.logActivity(ctx, "pr_item", item.ID, "", "assign_supplier", "",
 		item.Status, fmt.Sprintf("为零件 %s 分配供应商", item.MaterialName), "")
 
@@ -595,17 +615,26 @@ func (s *ProcurementService) GeneratePOsFromPR(ctx context.Context, prID, userID
 		return nil, fmt.Errorf("采购需求不存在")
 	}
 
-	// 筛选已分配供应商的行项
+	// 筛选已分配供应商且可下单的行项；已下单或处于打样、到货、检验等不能下单状态的行项不再生成PO行
 	supplierGroups := make(map[string][]entity.PRItem)
+	assigned := 0
 	for _, item := range pr.Items {
-		if item.SupplierID != nil && *item.SupplierID != "" {
-			supplierGroups[*item.SupplierID] = append(supplierGroups[*item.SupplierID], item)
+		if item.SupplierID == nil || *item.SupplierID == "" {
+			continue
+		}
+		assigned++
+		if !s.prItemOrderable(item) {
+			continue
 		}
+		supplierGroups[*item.SupplierID] = append(supplierGroups[*item.SupplierID], item)
 	}
 
-	if len(supplierGroups) == 0 {
+	if assigned == 0 {
 		return nil, fmt.Errorf("没有已分配供应商的行项")
 	}
+	if len(supplierGroups) == 0 {
+		return nil, fmt.Errorf("没有可下单的行项")
+	}
 
 	var createdPOs []*entity.PurchaseOrder
 
@@ -684,9 +713,16 @@ func (s *ProcurementService) GeneratePOsFromPR(ctx context.Context, prID, userID
 
 			// 更新PRItem状态为ordered
 			for _, item := range items {
-				if err := tx.Model(&entity.PRItem{}).Where("id = ?", item.ID).
-					Update("status", entity.PRItemStatusOrdered).Error; err != nil {
-					return fmt.Errorf("更新PRItem状态失败: %w", err)
+				if _, err := fireStatus(tx, s.engine, statusTransition{
+					EntityType: EntityTypePRItem,
+					ID:         item.ID,
+					Current:    item.Status,
+					Event:      "order",
+					EventData:  map[string]interface{}{"po_id": po.ID, "po_code": po.POCode},
+					Operator:   userID,
+					Model:      &entity.PRItem{},
+				}); err != nil {
+					return fmt.Errorf("行项 %s 无法下单: %w", item.MaterialName, err)
 				}
 			}
 		}
@@ -703,6 +739,7 @@ func (s *ProcurementService) GeneratePOsFromPR(ctx context.Context, prID, userID
 	if err != nil {
 		return nil, err
 	}
+	s.engine.NotifyOutbox()
 
 	for _, po := range createdPOs {
 		s.logActivity(ctx, "po", po.ID, po.POCode, "create", "", entity.POStatusDraft,
@@ -712,6 +749,11 @@ func (s *ProcurementService) GeneratePOsFromPR(ctx context.Context, prID, userID
 	return createdPOs, nil
 }
 
+// prItemOrderable 行项是否可以下单：未下单且状态机允许触发 order
+func (s *ProcurementService) prItemOrderable(item entity.PRItem) bool {
+	return item.Status != entity.PRItemStatusOrdered && canFire(s.engine, EntityTypePRItem, item.Status, "order")
+}
+
 // === 采购订单(PO) ===
 
 // ListPOs 获取PO列表
@@ -857,14 +899,22 @@ func (s *ProcurementService) ApprovePO(ctx context.Context, id, userID string) (
 
 	now := time.Now()
 	oldStatus := po.Status
+	if _, err := runStatusTransition(ctx, s.db, s.engine, statusTransition{
+		EntityType: EntityTypePurchaseOrder,
+		ID:         po.ID,
+		Current:    oldStatus,
+		Event:      "approve",
+		EventData:  poEventData(po),
+		Operator:   userID,
+		Model:      &entity.PurchaseOrder{},
+		Updates:    map[string]interface{}{"approved_by": userID, "approved_at": now},
+	}); err != nil {
+		return nil, err
+	}
 	po.Status = entity.POStatusApproved
 	po.ApprovedBy = &userID
 	po.ApprovedAt = &now
 
-	if err := s.poRepo.Update(ctx, po); err != nil {
-		return nil, err
-	}
-
 	s.logActivity(ctx, "po", po.ID, po.POCode, "status_change", oldStatus, entity.POStatusApproved,
 		"采购订单审批通过", userID)
 
@@ -875,7 +925,7 @@ func (s *ProcurementService) ApprovePO(ctx context.Context, id, userID string) (
 }
 
 // SubmitPO 提交PO审批
-func (s *ProcurementService) SubmitPO(ctx context.Context, id string) (*entity.PurchaseOrder, error) {
+func (s *ProcurementService) SubmitPO(ctx context.Context, id, userID string) (*entity.PurchaseOrder, error) {
 	po, err := s.poRepo.FindByID(ctx, id)
 	if err != nil {
 		return nil, err
@@ -883,13 +933,62 @@ func (s *ProcurementService) SubmitPO(ctx context.Context, id string) (*entity.P
 	if po.Status != entity.POStatusDraft {
 		return nil, fmt.Errorf("只有草稿状态的订单可以提交")
 	}
+	if _, err := runStatusTransition(ctx, s.db, s.engine, statusTransition{
+		EntityType: EntityTypePurchaseOrder,
+		ID:         po.ID,
+		Current:    po.Status,
+		Event:      "submit",
+		EventData:  poEventData(po),
+		Operator:   userID,
+		Model:      &entity.PurchaseOrder{},
+	}); err != nil {
+		return nil, err
+	}
 	po.Status = entity.POStatusSubmitted
-	if err := s.poRepo.Update(ctx, po); err != nil {
+
+	s.logActivity(ctx, "po", po.ID, po.POCode, "status_change", entity.POStatusDraft, entity.POStatusSubmitted,
+		"采购订单提交审批", userID)
+	return po, nil
+}
+
+// SendPO 发送PO给供应商（状态机动作 notify_supplier 负责通知）
+func (s *ProcurementService) SendPO(ctx context.Context, id, userID string) (*entity.PurchaseOrder, error) {
+	po, err := s.poRepo.FindByID(ctx, id)
+	if err != nil {
 		return nil, err
 	}
+	oldStatus := po.Status
+	if _, err := runStatusTransition(ctx, s.db, s.engine, statusTransition{
+		EntityType: EntityTypePurchaseOrder,
+		ID:         po.ID,
+		Current:    oldStatus,
+		Event:      "send",
+		EventData:  poEventData(po),
+		Operator:   userID,
+		Model:      &entity.PurchaseOrder{},
+	}); err != nil {
+		return nil, err
+	}
+	po.Status = entity.POStatusSent
+
+	s.logActivity(ctx, "po", po.ID, po.POCode, "status_change", oldStatus, entity.POStatusSent,
+		"采购订单已发送供应商", userID)
 	return po, nil
 }
 
+// poEventData PO 状态转换的事件数据（供条件评估和动作使用）
+func poEventData(po *entity.PurchaseOrder) map[string]interface{} {
+	data := map[string]interface{}{
+		"po_code":     po.POCode,
+		"supplier_id": po.SupplierID,
+		"type":        po.Type,
+	}
+	if po.TotalAmount != nil {
+		data["total_amount"] = *po.TotalAmount
+	}
+	return data
+}
+
 // DeletePO 删除PO（仅draft状态）
 func (s *ProcurementService) DeletePO(ctx context.Context, id string) error {
 	po, err := s.poRepo.FindByID(ctx, id)
@@ -1117,14 +1216,100 @@ type ReceiveItemRequest struct {
 	ReceivedQty float64 `json:"received_qty" binding:"required"`
 }
 
-// ReceiveItem 收货
-func (s *ProcurementService) ReceiveItem(ctx context.Context, poID, itemID string) error {
-	// 验证PO存在
-	_, err := s.poRepo.FindByID(ctx, poID)
+// ReceiveItem PO行项收货
+// 累加行项收货数量，并通过 receive 事件流转PO状态（全部收齐 → received，否则 → partial）
+func (s *ProcurementService) ReceiveItem(ctx context.Context, poID, itemID string, receivedQty float64, userID string) (*entity.PurchaseOrder, error) {
+	po, err := s.poRepo.FindByID(ctx, poID)
 	if err != nil {
-		return err
+		return nil, err
+	}
+
+	oldStatus := po.Status
+	var toStatus string
+	err = s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var item entity.POItem
+		if err := tx.Where("id = ? AND po_id = ?", itemID, poID).First(&item).Error; err != nil {
+			return fmt.Errorf("行项不存在")
+		}
+		item.ReceivedQty += receivedQty
+		if item.ReceivedQty >= item.Quantity {
+			item.Status = entity.POItemStatusReceived
+		} else {
+			item.Status = entity.POItemStatusPartial
+		}
+		if err := tx.Save(&item).Error; err != nil {
+			return fmt.Errorf("更新行项失败: %w", err)
+		}
+		if item.Status == entity.POItemStatusReceived && item.PRItemID != nil {
+			if err := s.receivePRItem(tx, *item.PRItemID, po, userID); err != nil {
+				return err
+			}
+		}
+
+		var pending int64
+		if err := tx.Model(&entity.POItem{}).
+			Where("po_id = ? AND received_qty < quantity", poID).
+			Count(&pending).Error; err != nil {
+			return err
+		}
+
+		eventData := poEventData(po)
+		eventData["item_id"] = itemID
+		eventData["received_qty"] = receivedQty
+		eventData["fully_received"] = pending == 0
+		updates := map[string]interface{}{}
+		if pending == 0 {
+			updates["actual_date"] = time.Now()
+		}
+		transitionLog, err := fireStatus(tx, s.engine, statusTransition{
+			EntityType: EntityTypePurchaseOrder,
+			ID:         po.ID,
+			Current:    oldStatus,
+			Event:      "receive",
+			EventData:  eventData,
+			Operator:   userID,
+			Model:      &entity.PurchaseOrder{},
+			Updates:    updates,
+		})
+		if err != nil {
+			return err
+		}
+		toStatus = transitionLog.ToState
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	s.engine.NotifyOutbox()
+
+	if toStatus != oldStatus {
+		s.logActivity(ctx, "po", po.ID, po.POCode, "status_change", oldStatus, toStatus,
+			fmt.Sprintf("收货 %.2f", receivedQty), userID)
+	}
+	return s.poRepo.FindByID(ctx, poID)
+}
+
+// receivePRItem PO行项收齐后，对应的PR行项触发 receive；已收货或已进入检验的行项保持原状态
+func (s *ProcurementService) receivePRItem(tx *gorm.DB, prItemID string, po *entity.PurchaseOrder, userID string) error {
+	var prItem entity.PRItem
+	if err := tx.Where("id = ?", prItemID).First(&prItem).Error; err != nil {
+		return fmt.Errorf("查找PR行项失败: %w", err)
+	}
+	if !canFire(s.engine, EntityTypePRItem, prItem.Status, "receive") {
+		return nil
 	}
-	return nil // 实际收货在handler层调用repo
+	if _, err := fireStatus(tx, s.engine, statusTransition{
+		EntityType: EntityTypePRItem,
+		ID:         prItem.ID,
+		Current:    prItem.Status,
+		Event:      "receive",
+		EventData:  map[string]interface{}{"po_id": po.ID, "po_code": po.POCode},
+		Operator:   userID,
+		Model:      &entity.PRItem{},
+	}); err != nil {
+		return fmt.Errorf("行项 %s 收货失败: %w", prItem.MaterialName, err)
+	}
+	return nil
 }
 
 func strPtr(s string) *string {
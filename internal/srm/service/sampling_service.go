@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"time"
 
+	"github.com/bitfantasy/nimo/internal/shared/engine"
 	"github.com/bitfantasy/nimo/internal/shared/feishu"
 	"github.com/bitfantasy/nimo/internal/srm/entity"
 	"github.com/bitfantasy/nimo/internal/srm/repository"
@@ -22,6 +23,7 @@ type SamplingService struct {
 	feishuClient    *feishu.FeishuClient
 	approvalCode    string // 飞书审批定义code（打样验证）
 	db              *gorm.DB
+	engine          *engine.Engine // 打样 / PR行项状态流转
 }
 
 func NewSamplingService(
@@ -30,6 +32,7 @@ func NewSamplingService(
 	supplierRepo *repository.SupplierRepository,
 	activityLogRepo *repository.ActivityLogRepository,
 	db *gorm.DB,
+	eng *engine.Engine,
 ) *SamplingService {
 	return &SamplingService{
 		samplingRepo:    samplingRepo,
@@ -37,6 +40,7 @@ func NewSamplingService(
 		supplierRepo:    supplierRepo,
 		activityLogRepo: activityLogRepo,
 		db:              db,
+		engine:          eng,
 	}
 }
 
@@ -100,23 +104,37 @@ func (s *SamplingService) CreateSamplingRequest(
 		Notes:       req.Notes,
 	}
 
-	if err := s.samplingRepo.Create(ctx, sampling); err != nil {
-		return nil, fmt.Errorf("创建打样请求失败: %w", err)
-	}
-
-	// 更新物料状态为sampling
-	if item.Status == entity.PRItemStatusPending {
-		item.Status = entity.PRItemStatusSampling
-		item.SupplierID = &req.SupplierID
-		if err := s.prRepo.UpdateItem(ctx, item); err != nil {
-			return nil, fmt.Errorf("更新物料状态失败: %w", err)
+	fromStatus := item.Status
+	err = s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(sampling).Error; err != nil {
+			return fmt.Errorf("创建打样请求失败: %w", err)
 		}
+		// 首轮打样：物料状态 pending → sampling
+		if fromStatus != entity.PRItemStatusPending {
+			return nil
+		}
+		_, err := fireStatus(tx, s.engine, statusTransition{
+			EntityType: EntityTypePRItem,
+			ID:         item.ID,
+			Current:    fromStatus,
+			Event:      "start_sampling",
+			EventData:  map[string]interface{}{"supplier_id": req.SupplierID, "round": round},
+			Operator:   operatorID,
+			Model:      &entity.PRItem{},
+			Updates:    map[string]interface{}{"supplier_id": req.SupplierID},
+		})
+		return err
+	})
+	if err != nil {
+		return nil, err
 	}
+	s.engine.NotifyOutbox()
+	item.Status = entity.PRItemStatusSampling
 
 	// 记录操作日志
 	if s.activityLogRepo != nil {
 		content := fmt.Sprintf("发起打样 R%d，供应商: %s，样品数量: %d", round, supplierName, req.SampleQty)
-		s.activityLogRepo.LogActivity(ctx, "pr_item", prItemID, item.MaterialCode, "sampling_create", item.Status, entity.PRItemStatusSampling, content, operatorID, "")
+		s.activityLogRepo.LogActivity(ctx, "pr_item", prItemID, item.MaterialCode, "sampling_create", fromStatus, entity.PRItemStatusSampling, content, operatorID, "")
 	}
 
 	sampling.SupplierName = supplierName
@@ -157,32 +175,30 @@ func (s *SamplingService) UpdateSamplingStatus(
 		return nil, fmt.Errorf("打样记录不存在")
 	}
 
-	// 验证状态流转
-	allowed, ok := entity.ValidSamplingTransitions[sampling.Status]
-	if !ok {
-		return nil, fmt.Errorf("当前状态 %s 不允许流转", sampling.Status)
-	}
-	valid := false
-	for _, s := range allowed {
-		if s == req.Status {
-			valid = true
-			break
-		}
-	}
-	if !valid {
-		return nil, fmt.Errorf("不允许从 %s 流转到 %s", sampling.Status, req.Status)
-	}
-
+	// 状态流转校验由 srm_sampling_request 状态机负责
 	fromStatus := sampling.Status
-	sampling.Status = req.Status
-
+	updates := map[string]interface{}{}
+	var arrivedAt *time.Time
 	if req.Status == entity.SamplingStatusArrived {
 		now := time.Now()
-		sampling.ArrivedAt = &now
+		arrivedAt = &now
+		updates["arrived_at"] = now
 	}
 
-	if err := s.samplingRepo.Update(ctx, sampling); err != nil {
-		return nil, fmt.Errorf("更新打样状态失败: %w", err)
+	if _, err := runStatusTransition(ctx, s.db, s.engine, statusTransition{
+		EntityType: EntityTypeSampling,
+		ID:         sampling.ID,
+		Current:    fromStatus,
+		Target:     req.Status,
+		Operator:   operatorID,
+		Model:      &entity.SamplingRequest{},
+		Updates:    updates,
+	}); err != nil {
+		return nil, err
+	}
+	sampling.Status = req.Status
+	if arrivedAt != nil {
+		sampling.ArrivedAt = arrivedAt
 	}
 
 	// 记录操作日志
@@ -253,13 +269,20 @@ func (s *SamplingService) RequestVerify(
 		sampling.ApprovalID = instanceCode
 	}
 
+	if _, err := runStatusTransition(ctx, s.db, s.engine, statusTransition{
+		EntityType: EntityTypeSampling,
+		ID:         sampling.ID,
+		Current:    sampling.Status,
+		Event:      "request_verify",
+		Operator:   operatorID,
+		Model:      &entity.SamplingRequest{},
+		Updates:    map[string]interface{}{"approval_id": sampling.ApprovalID, "verified_by": operatorID},
+	}); err != nil {
+		return nil, err
+	}
 	sampling.Status = entity.SamplingStatusVerifying
 	sampling.VerifiedBy = operatorID
 
-	if err := s.samplingRepo.Update(ctx, sampling); err != nil {
-		return nil, fmt.Errorf("更新打样状态失败: %w", err)
-	}
-
 	// 记录操作日志
 	if s.activityLogRepo != nil {
 		content := fmt.Sprintf("打样R%d发起研发验证", sampling.Round)
@@ -280,39 +303,61 @@ func (s *SamplingService) HandleVerifyCallback(ctx context.Context, instanceCode
 		return fmt.Errorf("打样状态不是验证中，当前: %s", sampling.Status)
 	}
 
+	var event, result string
+	switch status {
+	case feishu.ApprovalStatusApproved:
+		event, result = "pass", "passed"
+	case feishu.ApprovalStatusRejected:
+		event, result = "fail", "failed"
+	default:
+		return nil
+	}
+
+	item, itemErr := s.prRepo.FindItemByID(ctx, sampling.PRItemID)
 	now := time.Now()
-	sampling.VerifiedAt = &now
-
-	if status == feishu.ApprovalStatusApproved {
-		sampling.Status = entity.SamplingStatusPassed
-		sampling.VerifyResult = "passed"
-
-		// 物料状态 → quoting
-		item, err := s.prRepo.FindItemByID(ctx, sampling.PRItemID)
-		if err == nil {
-			item.Status = entity.PRItemStatusQuoting
-			s.prRepo.UpdateItem(ctx, item)
-
-			if s.activityLogRepo != nil {
-				content := fmt.Sprintf("打样R%d验证通过，进入报价阶段", sampling.Round)
-				s.activityLogRepo.LogActivity(ctx, "pr_item", sampling.PRItemID, item.MaterialCode, "sampling_passed", entity.PRItemStatusSampling, entity.PRItemStatusQuoting, content, sampling.VerifiedBy, "")
-			}
+	err = s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if _, err := fireStatus(tx, s.engine, statusTransition{
+			EntityType: EntityTypeSampling,
+			ID:         sampling.ID,
+			Current:    sampling.Status,
+			Event:      event,
+			Operator:   sampling.VerifiedBy,
+			Model:      &entity.SamplingRequest{},
+			Updates:    map[string]interface{}{"verified_at": now, "verify_result": result},
+		}); err != nil {
+			return err
 		}
-	} else if status == feishu.ApprovalStatusRejected {
-		sampling.Status = entity.SamplingStatusFailed
-		sampling.VerifyResult = "failed"
-
-		// 物料保持sampling状态，可以重新打样
-		if s.activityLogRepo != nil {
-			item, err := s.prRepo.FindItemByID(ctx, sampling.PRItemID)
-			if err == nil {
-				content := fmt.Sprintf("打样R%d验证不通过，可重新打样", sampling.Round)
-				s.activityLogRepo.LogActivity(ctx, "pr_item", sampling.PRItemID, item.MaterialCode, "sampling_failed", entity.SamplingStatusVerifying, entity.SamplingStatusFailed, content, sampling.VerifiedBy, "")
-			}
+		// 验证通过：物料 sampling → quoting；不通过时物料保持 sampling，可以重新打样
+		if event != "pass" || itemErr != nil || item.Status != entity.PRItemStatusSampling {
+			return nil
+		}
+		_, err := fireStatus(tx, s.engine, statusTransition{
+			EntityType: EntityTypePRItem,
+			ID:         item.ID,
+			Current:    item.Status,
+			Event:      "sampling_passed",
+			EventData:  map[string]interface{}{"sampling_id": sampling.ID, "round": sampling.Round},
+			Operator:   sampling.VerifiedBy,
+			Model:      &entity.PRItem{},
+		})
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	s.engine.NotifyOutbox()
+
+	if s.activityLogRepo != nil && itemErr == nil {
+		if event == "pass" {
+			content := fmt.Sprintf("打样R%d验证通过，进入报价阶段", sampling.Round)
+			s.activityLogRepo.LogActivity(ctx, "pr_item", sampling.PRItemID, item.MaterialCode, "sampling_passed", entity.PRItemStatusSampling, entity.PRItemStatusQuoting, content, sampling.VerifiedBy, "")
+		} else {
+			content := fmt.Sprintf("打样R%d验证不通过，可重新打样", sampling.Round)
+			s.activityLogRepo.LogActivity(ctx, "pr_item", sampling.PRItemID, item.MaterialCode, "sampling_failed", entity.SamplingStatusVerifying, entity.SamplingStatusFailed, content, sampling.VerifiedBy, "")
 		}
 	}
 
-	return s.samplingRepo.Update(ctx, sampling)
+	return nil
 }
 
 // EnsureApprovalDefinition 确保打样验证审批定义存在
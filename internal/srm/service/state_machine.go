@@ -0,0 +1,92 @@
+package service
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/bitfantasy/nimo/internal/shared/engine"
+	"gorm.io/gorm"
+)
+
+// SRM 状态机实体类型（与状态机名称一致）
+const (
+	EntityTypePurchaseOrder = "srm_purchase_order"
+	EntityTypePRItem        = "srm_pr_item"
+	EntityTypeSampling      = "srm_sampling_request"
+)
+
+// statusTransition 一次 SRM 实体状态流转
+type statusTransition struct {
+	EntityType string
+	ID         string                 // 业务主键
+	Current    string                 // 业务表中的当前状态
+	Event      string                 // 触发事件；为空时按 Target 反查
+	Target     string                 // 目标状态（"直接修改状态"类接口使用）
+	EventData  map[string]interface{} // 条件评估/动作上下文
+	Operator   string
+	Model      interface{}            // 业务表模型，如 &entity.PurchaseOrder{}
+	Updates    map[string]interface{} // 除 status 外需一并更新的字段
+}
+
+// fireStatus 在事务中通过状态机引擎流转状态，并把新状态写回业务表
+// 业务表状态作为期望状态校验（存量数据首次流转时作为起始状态），不一致说明数据已被并发修改
+func fireStatus(tx *gorm.DB, eng *engine.Engine, t statusTransition) (*engine.TransitionLog, error) {
+	if eng == nil {
+		return nil, fmt.Errorf("状态机引擎未初始化")
+	}
+
+	eventData := map[string]interface{}{"id": t.ID}
+	for k, v := range t.EventData {
+		eventData[k] = v
+	}
+	transitionLog, err := eng.FireInTx(tx, engine.FireRequest{
+		EntityType:      t.EntityType,
+		EntityID:        engine.EntityUUID(t.ID),
+		Event:           t.Event,
+		TargetState:     t.Target,
+		ExpectedState:   t.Current,
+		InitialState:    t.Current,
+		EventData:       eventData,
+		TriggeredBy:     t.Operator,
+		TriggeredByType: "user",
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	updates := map[string]interface{}{"status": transitionLog.ToState}
+	for k, v := range t.Updates {
+		updates[k] = v
+	}
+	if err := tx.Model(t.Model).Where("id = ?", t.ID).Updates(updates).Error; err != nil {
+		return nil, fmt.Errorf("更新状态失败: %w", err)
+	}
+	return transitionLog, nil
+}
+
+// canFire 状态机在 current 状态下是否有 event 的转换规则（不评估条件）
+func canFire(eng *engine.Engine, entityType, current, event string) bool {
+	if eng == nil {
+		return false
+	}
+	machine, err := eng.GetMachine(entityType)
+	if err != nil {
+		return false
+	}
+	return machine.HasEvent(current, event)
+}
+
+// runStatusTransition 单独开启事务执行一次状态流转，提交后唤醒动作派发
+func runStatusTransition(ctx context.Context, db *gorm.DB, eng *engine.Engine, t statusTransition) (*engine.TransitionLog, error) {
+	var transitionLog *engine.TransitionLog
+	err := db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var err error
+		transitionLog, err = fireStatus(tx, eng, t)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	eng.NotifyOutbox()
+	return transitionLog, nil
+}
@@ -0,0 +1,203 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/url"
+	"strings"
+
+	plmentity "github.com/bitfantasy/nimo/internal/plm/entity"
+	"github.com/bitfantasy/nimo/internal/shared/engine"
+	"github.com/bitfantasy/nimo/internal/shared/feishu"
+	"github.com/bitfantasy/nimo/internal/srm/entity"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// SupplierNotifier 状态机动作 notify_supplier 的执行器
+// PO 发送后通知供应商主联系人，并记录到活动日志
+// 联系人有飞书账号时直接发卡片；否则发给配置的采购人员转达
+type SupplierNotifier struct {
+	db           *gorm.DB
+	feishuClient *feishu.FeishuClient
+	buyerOpenID  string
+	webBaseURL   string
+}
+
+func NewSupplierNotifier(db *gorm.DB) *SupplierNotifier {
+	return &SupplierNotifier{db: db}
+}
+
+// SetFeishuClient 注入飞书客户端
+func (n *SupplierNotifier) SetFeishuClient(fc *feishu.FeishuClient) {
+	n.feishuClient = fc
+}
+
+// SetNotifyConfig 注入转达人 open_id 与前端访问地址
+func (n *SupplierNotifier) SetNotifyConfig(buyerOpenID, webBaseURL string) {
+	n.buyerOpenID = buyerOpenID
+	n.webBaseURL = strings.TrimRight(webBaseURL, "/")
+}
+
+// Execute 实现 engine.ActionExecutor
+// 动作在发件箱中失败会重试：已记录过通知的 PO 直接跳过
+func (n *SupplierNotifier) Execute(action engine.TransitionAction, actx engine.ActionContext) error {
+	poID, _ := actx.EventData["id"].(string)
+	if poID == "" {
+		return fmt.Errorf("notify_supplier: 事件数据缺少 id")
+	}
+	ctx := context.Background()
+
+	var po entity.PurchaseOrder
+	if err := n.db.WithContext(ctx).Preload("Supplier").Where("id = ?", poID).First(&po).Error; err != nil {
+		return fmt.Errorf("notify_supplier: 查询采购订单失败: %w", err)
+	}
+
+	var notified int64
+	if err := n.db.WithContext(ctx).Model(&entity.ActivityLog{}).
+		Where("entity_type = ? AND entity_id = ? AND action = ?", "po", po.ID, "notify_supplier").
+		Count(&notified).Error; err != nil {
+		return err
+	}
+	if notified > 0 {
+		return nil
+	}
+
+	var contact entity.SupplierContact
+	hasContact := n.db.WithContext(ctx).
+		Where("supplier_id = ?", po.SupplierID).
+		Order("is_primary DESC, created_at").
+		First(&contact).Error == nil
+
+	supplierName := po.SupplierID
+	if po.Supplier != nil {
+		supplierName = po.Supplier.Name
+	}
+	message, _ := action.Config["message"].(string)
+	contactText := "未维护联系人"
+	if hasContact {
+		contactText = contact.Name
+		if contact.Phone != "" {
+			contactText += " " + contact.Phone
+		}
+		if contact.Email != "" {
+			contactText += " " + contact.Email
+		}
+	}
+
+	recipient, relay := "", false
+	if hasContact {
+		recipient = n.contactOpenID(ctx, &contact)
+	}
+	if recipient == "" && n.buyerOpenID != "" {
+		recipient, relay = n.buyerOpenID, true
+	}
+
+	content := fmt.Sprintf("已通知供应商 %s（%s）", supplierName, contactText)
+	if n.feishuClient != nil && recipient != "" {
+		if err := n.sendCard(ctx, recipient, relay, &po, supplierName, contactText, message); err != nil {
+			return fmt.Errorf("notify_supplier: 发送飞书通知失败: %w", err)
+		}
+		if relay {
+			content = fmt.Sprintf("已通知采购人员转达供应商 %s（%s）", supplierName, contactText)
+		}
+	} else {
+		log.Printf("[SRM] PO %s 未发送飞书通知: 供应商联系人无飞书账号且未配置转达人", po.POCode)
+		content = fmt.Sprintf("待通知供应商 %s（%s），未发送飞书通知", supplierName, contactText)
+	}
+	if message != "" {
+		content += ": " + message
+	}
+	return n.db.WithContext(ctx).Create(&entity.ActivityLog{
+		ID:         uuid.New().String()[:32],
+		EntityType: "po",
+		EntityID:   po.ID,
+		EntityCode: po.POCode,
+		Action:     "notify_supplier",
+		ToStatus:   actx.ToState,
+		Content:    content,
+		OperatorID: "system",
+	}).Error
+}
+
+// contactOpenID 按邮箱或手机号匹配已绑定飞书的用户，找不到返回空
+func (n *SupplierNotifier) contactOpenID(ctx context.Context, contact *entity.SupplierContact) string {
+	if contact.Email == "" && contact.Phone == "" {
+		return ""
+	}
+	query := n.db.WithContext(ctx).Model(&plmentity.User{}).
+		Where("feishu_open_id <> '' AND status = ?", "active")
+	switch {
+	case contact.Email != "" && contact.Phone != "":
+		query = query.Where("email = ? OR mobile = ?", contact.Email, contact.Phone)
+	case contact.Email != "":
+		query = query.Where("email = ?", contact.Email)
+	default:
+		query = query.Where("mobile = ?", contact.Phone)
+	}
+	var user plmentity.User
+	if err := query.First(&user).Error; err != nil {
+		return ""
+	}
+	return user.FeishuOpenID
+}
+
+// sendCard 发送PO已发送供应商的飞书卡片
+// relay 为 true 时收件人是转达的采购人员，卡片提示其通知供应商
+func (n *SupplierNotifier) sendCard(ctx context.Context, openID string, relay bool, po *entity.PurchaseOrder, supplierName, contactText, message string) error {
+	body := fmt.Sprintf("采购订单 **%s** 已发送，请确认交期", po.POCode)
+	if relay {
+		body = fmt.Sprintf("采购订单 **%s** 已发送，请通知供应商", po.POCode)
+	}
+	if message != "" {
+		body += "：" + message
+	}
+
+	card := feishu.InteractiveCard{
+		Config: &feishu.CardConfig{WideScreenMode: true},
+		Header: &feishu.CardHeader{
+			Title:    feishu.CardText{Tag: "plain_text", Content: "📦 采购订单已发送供应商"},
+			Template: "blue",
+		},
+		Elements: []feishu.CardElement{
+			{
+				Tag: "div",
+				Fields: []feishu.CardField{
+					{IsShort: true, Text: feishu.CardText{Tag: "lark_md", Content: fmt.Sprintf("**订单编码**\n%s", po.POCode)}},
+					{IsShort: true, Text: feishu.CardText{Tag: "lark_md", Content: fmt.Sprintf("**供应商**\n%s", supplierName)}},
+					{IsShort: true, Text: feishu.CardText{Tag: "lark_md", Content: fmt.Sprintf("**联系人**\n%s", contactText)}},
+				},
+			},
+			{
+				Tag:  "div",
+				Text: &feishu.CardText{Tag: "lark_md", Content: body},
+			},
+		},
+	}
+	if n.webBaseURL != "" {
+		// SRM采购订单页面链接
+		rawURL := n.webBaseURL + "/srm/purchase-orders"
+		detailURL := fmt.Sprintf("https://applink.feishu.cn/client/web_url/open?url=%s&mode=window", url.QueryEscape(rawURL))
+		card.Elements = append(card.Elements,
+			feishu.CardElement{Tag: "hr"},
+			feishu.CardElement{
+				Tag: "action",
+				Actions: []feishu.CardAction{
+					{
+						Tag:  "button",
+						Text: feishu.CardText{Tag: "plain_text", Content: "查看采购订单"},
+						Type: "primary",
+						URL:  detailURL,
+					},
+				},
+			},
+		)
+	}
+
+	if err := n.feishuClient.SendUserCard(ctx, openID, card); err != nil {
+		return err
+	}
+	log.Printf("[SRM] 飞书供应商通知已发送: %s", po.POCode)
+	return nil
+}